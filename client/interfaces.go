@@ -64,6 +64,7 @@ type ContainerServer interface {
 	// Server functions
 	GetServer() (server *api.Server, ETag string, err error)
 	GetServerResources() (resources *api.Resources, err error)
+	GetMetadataConfiguration() (metadata *api.MetadataConfiguration, err error)
 	UpdateServer(server api.ServerPut, ETag string) (err error)
 	HasExtension(extension string) (exists bool)
 	RequireAuthenticated(authenticated bool)
@@ -89,12 +90,16 @@ type ContainerServer interface {
 	RenameContainer(name string, container api.ContainerPost) (op Operation, err error)
 	MigrateContainer(name string, container api.ContainerPost) (op Operation, err error)
 	DeleteContainer(name string) (op Operation, err error)
+	RestoreContainerFromTrash(name string) (err error)
 
 	ExecContainer(containerName string, exec api.ContainerExecPost, args *ContainerExecArgs) (op Operation, err error)
 	ConsoleContainer(containerName string, console api.ContainerConsolePost, args *ContainerConsoleArgs) (op Operation, err error)
 	GetContainerConsoleLog(containerName string, args *ContainerConsoleLogArgs) (content io.ReadCloser, err error)
 	DeleteContainerConsoleLog(containerName string, args *ContainerConsoleLogArgs) (err error)
 
+	CreateContainerDeviceNode(containerName string, node api.ContainerDeviceNodesPost) (err error)
+	DeleteContainerDeviceNode(containerName string, path string) (err error)
+
 	GetContainerFile(containerName string, path string) (content io.ReadCloser, resp *ContainerFileResponse, err error)
 	CreateContainerFile(containerName string, path string, args ContainerFileArgs) (err error)
 	DeleteContainerFile(containerName string, path string) (err error)
@@ -107,6 +112,7 @@ type ContainerServer interface {
 	RenameContainerSnapshot(containerName string, name string, container api.ContainerSnapshotPost) (op Operation, err error)
 	MigrateContainerSnapshot(containerName string, name string, container api.ContainerSnapshotPost) (op Operation, err error)
 	DeleteContainerSnapshot(containerName string, name string) (op Operation, err error)
+	UpdateContainerSnapshot(containerName string, name string, snapshot api.ContainerSnapshotPut) (err error)
 
 	GetContainerBackupNames(containerName string) (names []string, err error)
 	GetContainerBackups(containername string) (backups []api.ContainerBackup, err error)
@@ -116,8 +122,15 @@ type ContainerServer interface {
 	DeleteContainerBackup(containerName string, name string) (op Operation, err error)
 	GetContainerBackupFile(containerName string, name string, req *BackupFileRequest) (resp *BackupFileResponse, err error)
 	CreateContainerFromBackup(args ContainerBackupArgs) (op Operation, err error)
+	GetContainerExportFile(containerName string, format string, req *BackupFileRequest) (resp *BackupFileResponse, err error)
 
 	GetContainerState(name string) (state *api.ContainerState, ETag string, err error)
+	GetContainerUsage(name string) (usage *api.ContainerUsage, err error)
+	GetContainerProvenance(name string) (provenance *api.ContainerProvenance, err error)
+	GetContainerProfiles(name string) (profiles *api.ContainerProfilesPut, ETag string, err error)
+	UpdateContainerProfiles(name string, profiles api.ContainerProfilesPut, ETag string) (op Operation, err error)
+	GetContainerAnnotations(name string) (annotations map[string]interface{}, ETag string, err error)
+	UpdateContainerAnnotations(name string, annotations map[string]interface{}, ETag string) (err error)
 	UpdateContainerState(name string, state api.ContainerStatePut, ETag string) (op Operation, err error)
 
 	GetContainerLogfiles(name string) (logfiles []string, err error)
@@ -147,6 +160,9 @@ type ContainerServer interface {
 	UpdateImageAlias(name string, alias api.ImageAliasesEntryPut, ETag string) (err error)
 	RenameImageAlias(name string, alias api.ImageAliasesEntryPost) (err error)
 	DeleteImageAlias(name string) (err error)
+	GetImageRetention() (retention *api.ImageRetentionPut, ETag string, err error)
+	UpdateImageRetention(retention api.ImageRetentionPut, ETag string) (err error)
+	PruneImages() (op Operation, err error)
 
 	// Network functions ("network" API extension)
 	GetNetworkNames() (names []string, err error)
@@ -172,9 +188,19 @@ type ContainerServer interface {
 	GetProfile(name string) (profile *api.Profile, ETag string, err error)
 	CreateProfile(profile api.ProfilesPost) (err error)
 	UpdateProfile(name string, profile api.ProfilePut, ETag string) (err error)
+	PreviewProfileUpdate(name string, profile api.ProfilePut, ETag string) (preview *api.ProfileUpdatePreview, err error)
+	GetProfileContainers(name string) (containers []api.ProfileUsedByContainer, err error)
 	RenameProfile(name string, profile api.ProfilePost) (err error)
 	DeleteProfile(name string) (err error)
 
+	// Seccomp policy functions ("seccomp_policies" API extension)
+	GetSeccompPolicyNames() (names []string, err error)
+	GetSeccompPolicies() (policies []api.SeccompPolicy, err error)
+	GetSeccompPolicy(name string) (policy *api.SeccompPolicy, ETag string, err error)
+	CreateSeccompPolicy(policy api.SeccompPoliciesPost) (err error)
+	UpdateSeccompPolicy(name string, policy api.SeccompPolicyPut, ETag string) (err error)
+	DeleteSeccompPolicy(name string) (err error)
+
 	// Storage pool functions ("storage" API extension)
 	GetStoragePoolNames() (names []string, err error)
 	GetStoragePools() (pools []api.StoragePool, err error)
@@ -204,6 +230,14 @@ type ContainerServer interface {
 	GetClusterMembers() (members []api.ClusterMember, err error)
 	GetClusterMember(name string) (member *api.ClusterMember, ETag string, err error)
 	RenameClusterMember(name string, member api.ClusterMemberPost) (err error)
+	UpdateClusterCertificate(certs api.ClusterCertificatePut, ETag string) (err error)
+	GetClusterUpgrade() (upgrade *api.ClusterUpgrade, err error)
+
+	// Warning functions ("warnings" API extension)
+	GetWarnings() (warnings []api.Warning, err error)
+	GetWarning(uuid string) (warning *api.Warning, ETag string, err error)
+	UpdateWarning(uuid string, warning api.WarningPut, ETag string) (err error)
+	DeleteWarning(uuid string) (err error)
 
 	// Internal functions (for internal use)
 	RawQuery(method string, path string, data interface{}, queryETag string) (resp *api.Response, ETag string, err error)
@@ -339,6 +373,10 @@ type ContainerCopyArgs struct {
 
 	// The transfer mode, can be "pull" (default), "push" or "relay"
 	Mode string
+
+	// API extension: container_copy_regenerate_identity
+	// If set, the MAC addresses, machine-id, SSH host keys and hostname of the copy are regenerated
+	RegenerateIdentity bool
 }
 
 // The ContainerSnapshotCopyArgs struct is used to pass additional options during container copy
@@ -352,6 +390,9 @@ type ContainerSnapshotCopyArgs struct {
 	// API extension: container_snapshot_stateful_migration
 	// If set, the container running state will be transferred (live migration)
 	Live bool
+	// API extension: container_copy_regenerate_identity
+	// If set, the MAC addresses, machine-id, SSH host keys and hostname of the copy are regenerated
+	RegenerateIdentity bool
 }
 
 // The ContainerConsoleArgs struct is used to pass additional options during a
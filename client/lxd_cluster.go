@@ -121,3 +121,33 @@ func (r *ProtocolLXD) RenameClusterMember(name string, member api.ClusterMemberP
 
 	return nil
 }
+
+// UpdateClusterCertificate replaces the certificate of all the members of the
+// cluster (or of the server, if it isn't clustered)
+func (r *ProtocolLXD) UpdateClusterCertificate(certs api.ClusterCertificatePut, ETag string) error {
+	if !r.HasExtension("certificate_rotation") {
+		return fmt.Errorf("The server is missing the required \"certificate_rotation\" API extension")
+	}
+
+	_, _, err := r.query("PUT", "/cluster/certificate", certs, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetClusterUpgrade returns the progress of a rolling upgrade of the cluster
+func (r *ProtocolLXD) GetClusterUpgrade() (*api.ClusterUpgrade, error) {
+	if !r.HasExtension("cluster_upgrade") {
+		return nil, fmt.Errorf("The server is missing the required \"cluster_upgrade\" API extension")
+	}
+
+	upgrade := &api.ClusterUpgrade{}
+	_, err := r.queryStruct("GET", "/cluster/upgrade", nil, "", &upgrade)
+	if err != nil {
+		return nil, err
+	}
+
+	return upgrade, nil
+}
@@ -259,6 +259,10 @@ func (r *ProtocolLXD) CopyContainer(source ContainerServer, container api.Contai
 			return nil, fmt.Errorf("The source server is missing the required \"container_push_target\" API extension")
 		}
 
+		if args.RegenerateIdentity && !r.HasExtension("container_copy_regenerate_identity") {
+			return nil, fmt.Errorf("The target server is missing the required \"container_copy_regenerate_identity\" API extension")
+		}
+
 		// Allow overriding the target name
 		if args.Name != "" {
 			req.Name = args.Name
@@ -266,6 +270,7 @@ func (r *ProtocolLXD) CopyContainer(source ContainerServer, container api.Contai
 
 		req.Source.Live = args.Live
 		req.Source.ContainerOnly = args.ContainerOnly
+		req.Source.RegenerateIdentity = args.RegenerateIdentity
 	}
 
 	if req.Source.Live {
@@ -492,6 +497,12 @@ func (r *ProtocolLXD) proxyMigration(targetOp *operation, targetSecrets map[stri
 
 // UpdateContainer updates the container definition
 func (r *ProtocolLXD) UpdateContainer(name string, container api.ContainerPut, ETag string) (Operation, error) {
+	if container.RestoreOnly != "" {
+		if !r.HasExtension("container_snapshot_partial_restore") {
+			return nil, fmt.Errorf("The server is missing the required \"container_snapshot_partial_restore\" API extension")
+		}
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("PUT", fmt.Sprintf("/containers/%s", url.QueryEscape(name)), container, ETag)
 	if err != nil {
@@ -605,6 +616,21 @@ func (r *ProtocolLXD) DeleteContainer(name string) (Operation, error) {
 	return op, nil
 }
 
+// RestoreContainerFromTrash requests that LXD restores a deleted container from the trash
+func (r *ProtocolLXD) RestoreContainerFromTrash(name string) error {
+	if !r.HasExtension("container_trash") {
+		return fmt.Errorf("The server is missing the required \"container_trash\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", fmt.Sprintf("/containers/%s/restore", url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // ExecContainer requests that LXD spawns a command inside the container
 func (r *ProtocolLXD) ExecContainer(containerName string, exec api.ContainerExecPost, args *ContainerExecArgs) (Operation, error) {
 	if exec.RecordOutput {
@@ -613,6 +639,12 @@ func (r *ProtocolLXD) ExecContainer(containerName string, exec api.ContainerExec
 		}
 	}
 
+	if exec.User > 0 || exec.Group > 0 || exec.Cwd != "" {
+		if !r.HasExtension("container_exec_user_group_cwd") {
+			return nil, fmt.Errorf("The server is missing the required \"container_exec_user_group_cwd\" API extension")
+		}
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/exec", url.QueryEscape(containerName)), exec, "")
 	if err != nil {
@@ -932,6 +964,10 @@ func (r *ProtocolLXD) GetContainerSnapshot(containerName string, name string) (*
 
 // CreateContainerSnapshot requests that LXD creates a new snapshot for the container
 func (r *ProtocolLXD) CreateContainerSnapshot(containerName string, snapshot api.ContainerSnapshotsPost) (Operation, error) {
+	if snapshot.Protected && !r.HasExtension("snapshot_image_protection") {
+		return nil, fmt.Errorf("The server is missing the required \"snapshot_image_protection\" API extension")
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/snapshots", url.QueryEscape(containerName)), snapshot, "")
 	if err != nil {
@@ -985,10 +1021,16 @@ func (r *ProtocolLXD) CopyContainerSnapshot(source ContainerServer, snapshot api
 			return nil, fmt.Errorf("The source server is missing the required \"container_push_target\" API extension")
 		}
 
+		if args.RegenerateIdentity && !r.HasExtension("container_copy_regenerate_identity") {
+			return nil, fmt.Errorf("The target server is missing the required \"container_copy_regenerate_identity\" API extension")
+		}
+
 		// Allow overriding the target name
 		if args.Name != "" {
 			req.Name = args.Name
 		}
+
+		req.Source.RegenerateIdentity = args.RegenerateIdentity
 	}
 
 	// Optimization for the local copy case
@@ -1219,6 +1261,21 @@ func (r *ProtocolLXD) DeleteContainerSnapshot(containerName string, name string)
 	return op, nil
 }
 
+// UpdateContainerSnapshot updates the container snapshot to match the provided struct
+func (r *ProtocolLXD) UpdateContainerSnapshot(containerName string, name string, snapshot api.ContainerSnapshotPut) error {
+	if !r.HasExtension("snapshot_image_protection") {
+		return fmt.Errorf("The server is missing the required \"snapshot_image_protection\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/containers/%s/snapshots/%s", url.QueryEscape(containerName), url.QueryEscape(name)), snapshot, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // GetContainerState returns a ContainerState entry for the provided container name
 func (r *ProtocolLXD) GetContainerState(name string) (*api.ContainerState, string, error) {
 	state := api.ContainerState{}
@@ -1232,8 +1289,113 @@ func (r *ProtocolLXD) GetContainerState(name string) (*api.ContainerState, strin
 	return &state, etag, nil
 }
 
+// GetContainerUsage returns the recent resource usage history for the container
+func (r *ProtocolLXD) GetContainerUsage(name string) (*api.ContainerUsage, error) {
+	if !r.HasExtension("container_usage_history") {
+		return nil, fmt.Errorf("The server is missing the required \"container_usage_history\" API extension")
+	}
+
+	usage := api.ContainerUsage{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/usage", url.QueryEscape(name)), nil, "", &usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// GetContainerProvenance returns where each effective config key and device
+// of the container comes from
+func (r *ProtocolLXD) GetContainerProvenance(name string) (*api.ContainerProvenance, error) {
+	if !r.HasExtension("container_provenance") {
+		return nil, fmt.Errorf("The server is missing the required \"container_provenance\" API extension")
+	}
+
+	provenance := api.ContainerProvenance{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/provenance", url.QueryEscape(name)), nil, "", &provenance)
+	if err != nil {
+		return nil, err
+	}
+
+	return &provenance, nil
+}
+
+// GetContainerProfiles returns the profiles of the container along with their priorities
+func (r *ProtocolLXD) GetContainerProfiles(name string) (*api.ContainerProfilesPut, string, error) {
+	if !r.HasExtension("container_profile_priorities") {
+		return nil, "", fmt.Errorf("The server is missing the required \"container_profile_priorities\" API extension")
+	}
+
+	profiles := api.ContainerProfilesPut{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/profiles", url.QueryEscape(name)), nil, "", &profiles)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &profiles, etag, nil
+}
+
+// UpdateContainerProfiles replaces the profiles of the container and their priorities
+func (r *ProtocolLXD) UpdateContainerProfiles(name string, profiles api.ContainerProfilesPut, ETag string) (Operation, error) {
+	if !r.HasExtension("container_profile_priorities") {
+		return nil, fmt.Errorf("The server is missing the required \"container_profile_priorities\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("PUT", fmt.Sprintf("/containers/%s/profiles", url.QueryEscape(name)), profiles, ETag)
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// GetContainerAnnotations returns the free-form annotations of the container
+func (r *ProtocolLXD) GetContainerAnnotations(name string) (map[string]interface{}, string, error) {
+	if !r.HasExtension("container_annotations") {
+		return nil, "", fmt.Errorf("The server is missing the required \"container_annotations\" API extension")
+	}
+
+	annotations := map[string]interface{}{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/annotations", url.QueryEscape(name)), nil, "", &annotations)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return annotations, etag, nil
+}
+
+// UpdateContainerAnnotations replaces the free-form annotations of the container
+func (r *ProtocolLXD) UpdateContainerAnnotations(name string, annotations map[string]interface{}, ETag string) error {
+	if !r.HasExtension("container_annotations") {
+		return fmt.Errorf("The server is missing the required \"container_annotations\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/containers/%s/annotations", url.QueryEscape(name)), annotations, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // UpdateContainerState updates the container to match the requested state
 func (r *ProtocolLXD) UpdateContainerState(name string, state api.ContainerStatePut, ETag string) (Operation, error) {
+	if state.Duration > 0 {
+		if !r.HasExtension("container_freeze_duration") {
+			return nil, fmt.Errorf("The server is missing the required \"container_freeze_duration\" API extension")
+		}
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("PUT", fmt.Sprintf("/containers/%s/state", url.QueryEscape(name)), state, ETag)
 	if err != nil {
@@ -1444,6 +1606,10 @@ func (r *ProtocolLXD) ConsoleContainer(containerName string, console api.Contain
 		return nil, fmt.Errorf("The server is missing the required \"console\" API extension")
 	}
 
+	if console.Protocol != "" && !r.HasExtension("console_protocol") {
+		return nil, fmt.Errorf("The server is missing the required \"console_protocol\" API extension")
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/console", url.QueryEscape(containerName)), console, "")
 	if err != nil {
@@ -1560,6 +1726,36 @@ func (r *ProtocolLXD) DeleteContainerConsoleLog(containerName string, args *Cont
 	return nil
 }
 
+// CreateContainerDeviceNode creates a transient device node inside a running container
+func (r *ProtocolLXD) CreateContainerDeviceNode(containerName string, node api.ContainerDeviceNodesPost) error {
+	if !r.HasExtension("container_device_nodes") {
+		return fmt.Errorf("The server is missing the required \"container_device_nodes\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", fmt.Sprintf("/containers/%s/device-nodes", url.QueryEscape(containerName)), node, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteContainerDeviceNode removes a transient device node from a running container
+func (r *ProtocolLXD) DeleteContainerDeviceNode(containerName string, path string) error {
+	if !r.HasExtension("container_device_nodes") {
+		return fmt.Errorf("The server is missing the required \"container_device_nodes\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/containers/%s/device-nodes?path=%s", url.QueryEscape(containerName), url.QueryEscape(path)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // GetContainerBackupNames returns a list of backup names for the container
 func (r *ProtocolLXD) GetContainerBackupNames(containerName string) ([]string, error) {
 	if !r.HasExtension("container_backup") {
@@ -1676,6 +1872,33 @@ func (r *ProtocolLXD) GetContainerBackupFile(containerName string, name string,
 	uri := fmt.Sprintf("%s/1.0/containers/%s/backups/%s/export", r.httpHost,
 		url.QueryEscape(containerName), url.QueryEscape(name))
 
+	return r.getContainerFile(uri, req)
+}
+
+// GetContainerExportFile requests the container or snapshot as an image in
+// the given format ("oci" being the only supported one)
+func (r *ProtocolLXD) GetContainerExportFile(containerName string, format string, req *BackupFileRequest) (*BackupFileResponse, error) {
+	if !r.HasExtension("container_export_oci") {
+		return nil, fmt.Errorf("The server is missing the required \"container_export_oci\" API extension")
+	}
+
+	// Build the URL
+	var uri string
+	fields := strings.SplitN(containerName, shared.SnapshotDelimiter, 2)
+	if len(fields) == 2 {
+		uri = fmt.Sprintf("%s/1.0/containers/%s/snapshots/%s/export", r.httpHost,
+			url.QueryEscape(fields[0]), url.QueryEscape(fields[1]))
+	} else {
+		uri = fmt.Sprintf("%s/1.0/containers/%s/export", r.httpHost, url.QueryEscape(containerName))
+	}
+
+	uri = fmt.Sprintf("%s?format=%s", uri, url.QueryEscape(format))
+
+	return r.getContainerFile(uri, req)
+}
+
+// Download the file at the given URL into the writer of the request
+func (r *ProtocolLXD) getContainerFile(uri string, req *BackupFileRequest) (*BackupFileResponse, error) {
 	// Prepare the download request
 	request, err := http.NewRequest("GET", uri, nil)
 	if err != nil {
@@ -288,6 +288,12 @@ func (r *ProtocolLXD) CreateImage(image api.ImagesPost, args *ImageCreateArgs) (
 		}
 	}
 
+	if image.Source != nil && image.Source.SHA256 != "" {
+		if !r.HasExtension("image_url_checksum") {
+			return nil, fmt.Errorf("The server is missing the required \"image_url_checksum\" API extension")
+		}
+	}
+
 	// Send the JSON based request
 	if args == nil {
 		op, _, err := r.queryOperation("POST", "/images", image, "")
@@ -659,3 +665,50 @@ func (r *ProtocolLXD) DeleteImageAlias(name string) error {
 
 	return nil
 }
+
+// GetImageRetention returns the retention policies applied to cached images
+func (r *ProtocolLXD) GetImageRetention() (*api.ImageRetentionPut, string, error) {
+	if !r.HasExtension("image_retention_policies") {
+		return nil, "", fmt.Errorf("The server is missing the required \"image_retention_policies\" API extension")
+	}
+
+	retention := api.ImageRetentionPut{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", "/images/retention", nil, "", &retention)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &retention, etag, nil
+}
+
+// UpdateImageRetention replaces the retention policies applied to cached images
+func (r *ProtocolLXD) UpdateImageRetention(retention api.ImageRetentionPut, ETag string) error {
+	if !r.HasExtension("image_retention_policies") {
+		return fmt.Errorf("The server is missing the required \"image_retention_policies\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", "/images/retention", retention, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PruneImages requests that LXD immediately prunes its cached images
+func (r *ProtocolLXD) PruneImages() (Operation, error) {
+	if !r.HasExtension("image_retention_policies") {
+		return nil, fmt.Errorf("The server is missing the required \"image_retention_policies\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", "/images/retention", nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
@@ -78,6 +78,45 @@ func (r *ProtocolLXD) UpdateProfile(name string, profile api.ProfilePut, ETag st
 	return nil
 }
 
+// PreviewProfileUpdate reports what updating the profile would do to the containers using it
+func (r *ProtocolLXD) PreviewProfileUpdate(name string, profile api.ProfilePut, ETag string) (*api.ProfileUpdatePreview, error) {
+	if !r.HasExtension("profile_update_preview") {
+		return nil, fmt.Errorf("The server is missing the required \"profile_update_preview\" API extension")
+	}
+
+	preview := api.ProfileUpdatePreview{}
+
+	// Send the request
+	path := fmt.Sprintf("/profiles/%s?dry-run=1", url.QueryEscape(name))
+	if r.clusterTarget != "" {
+		path += fmt.Sprintf("&target=%s", r.clusterTarget)
+	}
+
+	_, err := r.queryStruct("PUT", path, profile, ETag, &preview)
+	if err != nil {
+		return nil, err
+	}
+
+	return &preview, nil
+}
+
+// GetProfileContainers returns the containers using the profile
+func (r *ProtocolLXD) GetProfileContainers(name string) ([]api.ProfileUsedByContainer, error) {
+	if !r.HasExtension("profile_update_preview") {
+		return nil, fmt.Errorf("The server is missing the required \"profile_update_preview\" API extension")
+	}
+
+	containers := []api.ProfileUsedByContainer{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/profiles/%s/used-by?recursion=1", url.QueryEscape(name)), nil, "", &containers)
+	if err != nil {
+		return nil, err
+	}
+
+	return containers, nil
+}
+
 // RenameProfile renames an existing profile entry
 func (r *ProtocolLXD) RenameProfile(name string, profile api.ProfilePost) error {
 	// Send the request
@@ -0,0 +1,114 @@
+package lxd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Seccomp policy handling functions
+
+// GetSeccompPolicyNames returns a list of available seccomp policy names
+func (r *ProtocolLXD) GetSeccompPolicyNames() ([]string, error) {
+	if !r.HasExtension("seccomp_policies") {
+		return nil, fmt.Errorf("The server is missing the required \"seccomp_policies\" API extension")
+	}
+
+	urls := []string{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/seccomp-policies", nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, url := range urls {
+		fields := strings.Split(url, "/seccomp-policies/")
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
+// GetSeccompPolicies returns a list of available SeccompPolicy structs
+func (r *ProtocolLXD) GetSeccompPolicies() ([]api.SeccompPolicy, error) {
+	if !r.HasExtension("seccomp_policies") {
+		return nil, fmt.Errorf("The server is missing the required \"seccomp_policies\" API extension")
+	}
+
+	policies := []api.SeccompPolicy{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/seccomp-policies?recursion=1", nil, "", &policies)
+	if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// GetSeccompPolicy returns a SeccompPolicy entry for the provided name
+func (r *ProtocolLXD) GetSeccompPolicy(name string) (*api.SeccompPolicy, string, error) {
+	if !r.HasExtension("seccomp_policies") {
+		return nil, "", fmt.Errorf("The server is missing the required \"seccomp_policies\" API extension")
+	}
+
+	policy := api.SeccompPolicy{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/seccomp-policies/%s", url.QueryEscape(name)), nil, "", &policy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &policy, etag, nil
+}
+
+// CreateSeccompPolicy defines a new seccomp policy
+func (r *ProtocolLXD) CreateSeccompPolicy(policy api.SeccompPoliciesPost) error {
+	if !r.HasExtension("seccomp_policies") {
+		return fmt.Errorf("The server is missing the required \"seccomp_policies\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", "/seccomp-policies", policy, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateSeccompPolicy updates the seccomp policy to match the provided SeccompPolicy struct
+func (r *ProtocolLXD) UpdateSeccompPolicy(name string, policy api.SeccompPolicyPut, ETag string) error {
+	if !r.HasExtension("seccomp_policies") {
+		return fmt.Errorf("The server is missing the required \"seccomp_policies\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/seccomp-policies/%s", url.QueryEscape(name)), policy, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSeccompPolicy deletes a seccomp policy
+func (r *ProtocolLXD) DeleteSeccompPolicy(name string) error {
+	if !r.HasExtension("seccomp_policies") {
+		return fmt.Errorf("The server is missing the required \"seccomp_policies\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/seccomp-policies/%s", url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
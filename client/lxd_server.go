@@ -89,6 +89,24 @@ func (r *ProtocolLXD) GetServerResources() (*api.Resources, error) {
 	return &resources, nil
 }
 
+// GetMetadataConfiguration returns the schema of the configuration keys
+// known to the server
+func (r *ProtocolLXD) GetMetadataConfiguration() (*api.MetadataConfiguration, error) {
+	if !r.HasExtension("metadata_configuration") {
+		return nil, fmt.Errorf("The server is missing the required \"metadata_configuration\" API extension")
+	}
+
+	metadata := api.MetadataConfiguration{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/metadata/configuration", nil, "", &metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
 // UseTarget returns a client that will target a specific cluster member.
 // Use this member-specific operations such as specific container
 // placement, preparing a new storage pool or network, ...
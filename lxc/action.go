@@ -102,6 +102,7 @@ type cmdAction struct {
 	flagStateful  bool
 	flagStateless bool
 	flagTimeout   int
+	flagDuration  int
 }
 
 func (c *cmdAction) Command(action string) *cobra.Command {
@@ -114,6 +115,8 @@ func (c *cmdAction) Command(action string) *cobra.Command {
 		cmd.Flags().BoolVar(&c.flagStateful, "stateful", false, i18n.G("Store the container state"))
 	} else if action == "start" {
 		cmd.Flags().BoolVar(&c.flagStateless, "stateless", false, i18n.G("Ignore the container state"))
+	} else if action == "pause" {
+		cmd.Flags().IntVar(&c.flagDuration, "duration", 0, i18n.G("Number of seconds after which to unpause the container")+"``")
 	}
 
 	if shared.StringInSlice(action, []string{"restart", "stop"}) {
@@ -173,6 +176,7 @@ func (c *cmdAction) doAction(action string, conf *config.Config, nameArg string)
 		Timeout:  c.flagTimeout,
 		Force:    c.flagForce,
 		Stateful: state,
+		Duration: c.flagDuration,
 	}
 
 	op, err := d.UpdateContainerState(name, req, "")
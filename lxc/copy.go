@@ -17,15 +17,16 @@ import (
 type cmdCopy struct {
 	global *cmdGlobal
 
-	flagNoProfiles    bool
-	flagProfile       []string
-	flagConfig        []string
-	flagEphemeral     bool
-	flagContainerOnly bool
-	flagMode          string
-	flagStateless     bool
-	flagStorage       string
-	flagTarget        string
+	flagNoProfiles         bool
+	flagProfile            []string
+	flagConfig             []string
+	flagEphemeral          bool
+	flagContainerOnly      bool
+	flagMode               string
+	flagStateless          bool
+	flagStorage            string
+	flagTarget             string
+	flagRegenerateIdentity bool
 }
 
 func (c *cmdCopy) Command() *cobra.Command {
@@ -35,6 +36,12 @@ func (c *cmdCopy) Command() *cobra.Command {
 	cmd.Short = i18n.G("Copy containers within or in between LXD instances")
 	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
 		`Copy containers within or in between LXD instances`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc copy c1 c2
+    Create a copy of container c1 named c2.
+
+lxc copy c1/snap0 c2
+    Create a new container c2 from snapshot snap0 of container c1, leaving c1 untouched.`))
 
 	cmd.RunE = c.Run
 	cmd.Flags().StringArrayVarP(&c.flagConfig, "config", "c", nil, i18n.G("Config key/value to apply to the new container")+"``")
@@ -46,6 +53,7 @@ func (c *cmdCopy) Command() *cobra.Command {
 	cmd.Flags().StringVarP(&c.flagStorage, "storage", "s", "", i18n.G("Storage pool name")+"``")
 	cmd.Flags().StringVar(&c.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
 	cmd.Flags().BoolVar(&c.flagNoProfiles, "no-profiles", false, i18n.G("Create the container with no profiles applied"))
+	cmd.Flags().BoolVar(&c.flagRegenerateIdentity, "regenerate-identity", false, i18n.G("Regenerate the MAC addresses, machine-id, SSH host keys and hostname of the copy"))
 
 	return cmd
 }
@@ -120,9 +128,10 @@ func (c *cmdCopy) copyContainer(conf *config.Config, sourceResource string,
 	if shared.IsSnapshot(sourceName) {
 		// Prepare the container creation request
 		args := lxd.ContainerSnapshotCopyArgs{
-			Name: destName,
-			Mode: mode,
-			Live: stateful,
+			Name:               destName,
+			Mode:               mode,
+			Live:               stateful && !c.flagRegenerateIdentity,
+			RegenerateIdentity: c.flagRegenerateIdentity,
 		}
 
 		// Copy of a snapshot into a new container
@@ -189,10 +198,11 @@ func (c *cmdCopy) copyContainer(conf *config.Config, sourceResource string,
 	} else {
 		// Prepare the container creation request
 		args := lxd.ContainerCopyArgs{
-			Name:          destName,
-			Live:          stateful,
-			ContainerOnly: containerOnly,
-			Mode:          mode,
+			Name:               destName,
+			Live:               stateful && !c.flagRegenerateIdentity,
+			ContainerOnly:      containerOnly,
+			Mode:               mode,
+			RegenerateIdentity: c.flagRegenerateIdentity,
 		}
 
 		// Copy of a container into a new container
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -21,23 +22,28 @@ type cmdExport struct {
 
 	flagContainerOnly    bool
 	flagOptimizedStorage bool
+	flagFormat           string
 }
 
 func (c *cmdExport) Command() *cobra.Command {
 	cmd := &cobra.Command{}
-	cmd.Use = i18n.G("export [<remote>:]<container> [target] [--container-only] [--optimized-storage]")
+	cmd.Use = i18n.G("export [<remote>:]<container>[/<snapshot>] [target] [--container-only] [--optimized-storage] [--format=backup|oci]")
 	cmd.Short = i18n.G("Export container backups")
 	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
-		`Export containers as backup tarballs.`))
+		`Export containers as backup tarballs, or as OCI images.`))
 	cmd.Example = cli.FormatSection("", i18n.G(
 		`lxc export u1 backup0.tar.xz
-    Download a backup tarball of the u1 container.`))
+    Download a backup tarball of the u1 container.
+
+lxc export u1/snap0 u1.tar --format=oci
+    Download the snap0 snapshot of the u1 container as an OCI image layout.`))
 
 	cmd.RunE = c.Run
 	cmd.Flags().BoolVar(&c.flagContainerOnly, "container-only", false,
 		i18n.G("Whether or not to only backup the container (without snapshots)"))
 	cmd.Flags().BoolVar(&c.flagOptimizedStorage, "optimized-storage", false,
 		i18n.G("Use storage driver optimized format (can only be restored on a similar pool)"))
+	cmd.Flags().StringVar(&c.flagFormat, "format", "backup", i18n.G("Format of the export (backup or oci)")+"``")
 
 	return cmd
 }
@@ -62,6 +68,14 @@ func (c *cmdExport) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if c.flagFormat == "oci" {
+		return c.exportOCI(d, name, args)
+	}
+
+	if c.flagFormat != "backup" {
+		return fmt.Errorf(i18n.G("Unknown export format: %s"), c.flagFormat)
+	}
+
 	req := api.ContainerBackupsPost{
 		Name:             "",
 		ExpiryDate:       time.Now().Add(30 * time.Minute),
@@ -121,3 +135,33 @@ func (c *cmdExport) Run(cmd *cobra.Command, args []string) error {
 	progress.Done(i18n.G("Backup exported successfully!"))
 	return nil
 }
+
+func (c *cmdExport) exportOCI(d lxd.ContainerServer, name string, args []string) error {
+	targetName := "image.tar"
+	if len(args) > 1 {
+		targetName = args[1]
+	}
+
+	target, err := os.Create(shared.HostPath(targetName))
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	// Prepare the download request
+	progress := utils.ProgressRenderer{Format: i18n.G("Exporting the image: %s")}
+	req := lxd.BackupFileRequest{
+		BackupFile:      io.WriteSeeker(target),
+		ProgressHandler: progress.UpdateProgress,
+	}
+
+	_, err = d.GetContainerExportFile(name, "oci", &req)
+	if err != nil {
+		os.Remove(targetName)
+		progress.Done("")
+		return err
+	}
+
+	progress.Done(i18n.G("Image exported successfully!"))
+	return nil
+}
@@ -562,6 +562,7 @@ type cmdImageImport struct {
 
 	flagPublic  bool
 	flagAliases []string
+	flagSHA256  string
 }
 
 func (c *cmdImageImport) Command() *cobra.Command {
@@ -575,6 +576,7 @@ Directory import is only available on Linux and must be performed as root.`))
 
 	cmd.Flags().BoolVar(&c.flagPublic, "public", false, i18n.G("Make image public"))
 	cmd.Flags().StringArrayVar(&c.flagAliases, "alias", nil, i18n.G("New aliases to add to the image")+"``")
+	cmd.Flags().StringVar(&c.flagSHA256, "sha256", "", i18n.G("Expected SHA-256 of the image at the URL, which is then downloaded directly")+"``")
 	cmd.RunE = c.Run
 
 	return cmd
@@ -690,7 +692,12 @@ func (c *cmdImageImport) Run(cmd *cobra.Command, args []string) error {
 		image.Source.Mode = "pull"
 		image.Source.Protocol = "direct"
 		image.Source.URL = imageFile
+		image.Source.SHA256 = c.flagSHA256
 	} else {
+		if c.flagSHA256 != "" {
+			return fmt.Errorf(i18n.G("--sha256 can only be used with URLs"))
+		}
+
 		var meta io.ReadCloser
 		var rootfs io.ReadCloser
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
@@ -11,6 +12,7 @@ import (
 	"github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxc/config"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	cli "github.com/lxc/lxd/shared/cmd"
 	"github.com/lxc/lxd/shared/i18n"
 )
@@ -109,6 +111,46 @@ func (c *cmdInfo) remoteInfo(d lxd.ContainerServer) error {
 	return nil
 }
 
+func (c *cmdInfo) usageHistory(samples []api.ContainerUsageSample) {
+	first := samples[0]
+	last := samples[len(samples)-1]
+	window := last.Timestamp.Sub(first.Timestamp)
+
+	fmt.Println(fmt.Sprintf("  %s", fmt.Sprintf(i18n.G("Usage history (last %s):"), window.Round(time.Second))))
+
+	// Average CPU load over the window
+	if window > 0 && last.CPUUsage >= first.CPUUsage {
+		load := float64(last.CPUUsage-first.CPUUsage) / float64(window.Nanoseconds()) * 100
+		fmt.Printf("    %s: %.1f%%\n", i18n.G("CPU (average)"), load)
+	}
+
+	// Memory range over the window
+	min := first.MemoryUsage
+	max := first.MemoryUsage
+	total := int64(0)
+	for _, sample := range samples {
+		if sample.MemoryUsage < min {
+			min = sample.MemoryUsage
+		}
+
+		if sample.MemoryUsage > max {
+			max = sample.MemoryUsage
+		}
+
+		total += sample.MemoryUsage
+	}
+
+	fmt.Printf("    %s: %s / %s / %s\n", i18n.G("Memory (min/avg/max)"),
+		shared.GetByteSizeString(min, 2),
+		shared.GetByteSizeString(total/int64(len(samples)), 2),
+		shared.GetByteSizeString(max, 2))
+
+	// Disk growth over the window
+	if last.DiskUsage != 0 && last.DiskUsage >= first.DiskUsage {
+		fmt.Printf("    %s: %s\n", i18n.G("Disk (change)"), shared.GetByteSizeString(last.DiskUsage-first.DiskUsage, 2))
+	}
+}
+
 func (c *cmdInfo) containerInfo(d lxd.ContainerServer, remote config.Remote, name string, showLog bool) error {
 	ct, _, err := d.GetContainer(name)
 	if err != nil {
@@ -184,6 +226,11 @@ func (c *cmdInfo) containerInfo(d lxd.ContainerServer, remote config.Remote, nam
 			fmt.Printf(diskInfo)
 		}
 
+		// Disk weight
+		if cs.DiskWeight > 0 {
+			fmt.Printf("  "+i18n.G("Disk weight: %d")+"\n", cs.DiskWeight)
+		}
+
 		// CPU usage
 		cpuInfo := ""
 		if cs.CPU.Usage != 0 {
@@ -234,6 +281,22 @@ func (c *cmdInfo) containerInfo(d lxd.ContainerServer, remote config.Remote, nam
 			fmt.Println(fmt.Sprintf("  %s", i18n.G("Network usage:")))
 			fmt.Printf(networkInfo)
 		}
+
+		// Usage history
+		if d.HasExtension("container_usage_history") {
+			usage, err := d.GetContainerUsage(name)
+			if err == nil && len(usage.Samples) > 1 {
+				c.usageHistory(usage.Samples)
+			}
+		}
+
+		// Start timings
+		if cs.StartTimings != nil {
+			fmt.Println(fmt.Sprintf("  %s", fmt.Sprintf(i18n.G("Last start (%dms):"), cs.StartTimings.Total)))
+			for _, phase := range cs.StartTimings.Phases {
+				fmt.Printf("    %s: %dms\n", phase.Name, phase.Duration)
+			}
+		}
 	}
 
 	// List snapshots
@@ -775,6 +775,18 @@ func (c *cmdNetworkInfo) Run(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  %s: %d\n", i18n.G("Packets received"), state.Counters.PacketsReceived)
 	fmt.Printf("  %s: %d\n", i18n.G("Packets sent"), state.Counters.PacketsSent)
 
+	// Aggregate usage of the bridge
+	if state.Usage != nil {
+		fmt.Println("")
+		fmt.Println(i18n.G("Bridge usage:"))
+		fmt.Printf("  %s: %d\n", i18n.G("Interfaces"), state.Usage.Interfaces)
+		fmt.Printf("  %s: %d\n", i18n.G("Active leases"), state.Usage.ActiveLeases)
+		fmt.Printf("  %s: %s\n", i18n.G("Bytes received"), shared.GetByteSizeString(state.Usage.Counters.BytesReceived, 2))
+		fmt.Printf("  %s: %s\n", i18n.G("Bytes sent"), shared.GetByteSizeString(state.Usage.Counters.BytesSent, 2))
+		fmt.Printf("  %s: %d\n", i18n.G("Packets received"), state.Usage.Counters.PacketsReceived)
+		fmt.Printf("  %s: %d\n", i18n.G("Packets sent"), state.Usage.Counters.PacketsSent)
+	}
+
 	return nil
 }
 
@@ -946,18 +958,29 @@ func (c *cmdNetworkListLeases) Run(cmd *cobra.Command, args []string) error {
 
 	data := [][]string{}
 	for _, lease := range leases {
-		data = append(data, []string{lease.Hostname, lease.Hwaddr, lease.Address, strings.ToUpper(lease.Type)})
+		details := []string{lease.Hostname, lease.Hwaddr, lease.Address, strings.ToUpper(lease.Type), lease.Container}
+		if resource.server.IsClustered() {
+			details = append(details, lease.Location)
+		}
+		data = append(data, details)
+	}
+
+	header := []string{
+		i18n.G("HOSTNAME"),
+		i18n.G("MAC ADDRESS"),
+		i18n.G("IP ADDRESS"),
+		i18n.G("TYPE"),
+		i18n.G("CONTAINER"),
+	}
+	if resource.server.IsClustered() {
+		header = append(header, i18n.G("LOCATION"))
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetAutoWrapText(false)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
 	table.SetRowLine(true)
-	table.SetHeader([]string{
-		i18n.G("HOSTNAME"),
-		i18n.G("MAC ADDRESS"),
-		i18n.G("IP ADDRESS"),
-		i18n.G("TYPE")})
+	table.SetHeader(header)
 	sort.Sort(byName(data))
 	table.AppendBulk(data)
 	table.Render()
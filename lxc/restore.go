@@ -14,26 +14,43 @@ import (
 type cmdRestore struct {
 	global *cmdGlobal
 
-	flagStateful bool
+	flagStateful       bool
+	flagTrash          bool
+	flagConfigOnly     bool
+	flagFilesystemOnly bool
 }
 
 func (c *cmdRestore) Command() *cobra.Command {
 	cmd := &cobra.Command{}
-	cmd.Use = i18n.G("restore [<remote>:]<container> <snapshot>")
-	cmd.Short = i18n.G("Restore containers from snapshots")
+	cmd.Use = i18n.G("restore [<remote>:]<container> [<snapshot>]")
+	cmd.Short = i18n.G("Restore containers from snapshots or from the trash")
 	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
-		`Restore containers from snapshots
+		`Restore containers from snapshots or from the trash
 
-If --stateful is passed, then the running state will be restored too.`))
+If --stateful is passed, then the running state will be restored too.
+
+If --config-only or --filesystem-only is passed, then only the configuration
+(devices and profiles included) or only the filesystem of the snapshot is restored.
+
+If --trash is passed, then a deleted container is restored from the trash.`))
 	cmd.Example = cli.FormatSection("", i18n.G(
 		`lxc snapshot u1 snap0
     Create the snapshot.
 
 lxc restore u1 snap0
-    Restore the snapshot.`))
+    Restore the snapshot.
+
+lxc restore u1 --trash
+    Restore the deleted container from the trash.
+
+lxc copy u1/snap0 u2
+    Restore the snapshot into a new container, leaving u1 untouched.`))
 
 	cmd.RunE = c.Run
 	cmd.Flags().BoolVar(&c.flagStateful, "stateful", false, i18n.G("Whether or not to restore the container's running state from snapshot (if available)"))
+	cmd.Flags().BoolVar(&c.flagTrash, "trash", false, i18n.G("Restore the container from the trash"))
+	cmd.Flags().BoolVar(&c.flagConfigOnly, "config-only", false, i18n.G("Only restore the configuration of the snapshot"))
+	cmd.Flags().BoolVar(&c.flagFilesystemOnly, "filesystem-only", false, i18n.G("Only restore the filesystem of the snapshot"))
 
 	return cmd
 }
@@ -42,11 +59,15 @@ func (c *cmdRestore) Run(cmd *cobra.Command, args []string) error {
 	conf := c.global.conf
 
 	// Sanity checks
-	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	exit, err := c.global.CheckArgs(cmd, args, 1, 2)
 	if exit {
 		return err
 	}
 
+	if c.flagTrash != (len(args) == 1) {
+		return fmt.Errorf(i18n.G("Either a snapshot or --trash must be passed"))
+	}
+
 	// Connect to LXD
 	remote, name, err := conf.ParseRemote(args[0])
 	if err != nil {
@@ -58,6 +79,11 @@ func (c *cmdRestore) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// Restore from the trash
+	if c.flagTrash {
+		return d.RestoreContainerFromTrash(name)
+	}
+
 	// Setup the snapshot restore
 	snapname := args[1]
 	if !shared.IsSnapshot(snapname) {
@@ -69,6 +95,16 @@ func (c *cmdRestore) Run(cmd *cobra.Command, args []string) error {
 		Stateful: c.flagStateful,
 	}
 
+	if c.flagConfigOnly && c.flagFilesystemOnly {
+		return fmt.Errorf(i18n.G("--config-only and --filesystem-only can't be used together"))
+	}
+
+	if c.flagConfigOnly {
+		req.RestoreOnly = "config"
+	} else if c.flagFilesystemOnly {
+		req.RestoreOnly = "filesystem"
+	}
+
 	// Restore the snapshot
 	op, err := d.UpdateContainer(name, req, "")
 	if err != nil {
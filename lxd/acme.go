@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Certificates obtained through ACME are renewed when they expire within
+// this delay.
+const acmeRenewBefore = 30 * 24 * time.Hour
+
+// Pending HTTP-01 challenges, mapping their tokens to the expected responses.
+var acmeChallengesLock sync.Mutex
+var acmeChallenges = map[string]string{}
+
+// Answer the HTTP-01 challenges of the ACME server. Besides the HTTPS
+// listener, for setups where a reverse proxy forwards them, challenges are
+// answered on port 80 of the HTTPS address while a certificate is being
+// obtained.
+func acmeChallengeGet(w http.ResponseWriter, r *http.Request) {
+	acmeChallengesLock.Lock()
+	response, ok := acmeChallenges[mux.Vars(r)["token"]]
+	acmeChallengesLock.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(response))
+}
+
+func acmeTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		err := acmeUpdateCert(ctx, d)
+		if err != nil {
+			logger.Error("Failed to obtain the server certificate through ACME", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Daily()
+}
+
+// Obtain a certificate for acme.domain, unless the current one is already
+// for that domain and isn't about to expire, and switch the HTTPS listener
+// to it.
+func acmeUpdateCert(ctx context.Context, d *Daemon) error {
+	var domain, email, caURL, address string
+	err := d.db.Transaction(func(tx *db.NodeTx) error {
+		config, err := node.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		domain, email, caURL = config.ACME()
+		address = config.HTTPSAddress()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if domain == "" {
+		return nil
+	}
+
+	// Members of a cluster share the cluster certificate
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
+		return err
+	}
+
+	if clustered {
+		return fmt.Errorf("ACME isn't supported on clustered servers")
+	}
+
+	current, err := x509.ParseCertificate(d.endpoints.NetworkCert().KeyPair().Certificate[0])
+	if err != nil {
+		return err
+	}
+
+	selfSigned := bytes.Equal(current.RawIssuer, current.RawSubject)
+	if !selfSigned && current.VerifyHostname(domain) == nil && time.Until(current.NotAfter) > acmeRenewBefore {
+		return nil
+	}
+
+	logger.Info("Obtaining the server certificate through ACME", log.Ctx{"domain": domain})
+	certPEM, keyPEM, err := acmeObtainCert(ctx, d, domain, email, caURL, acmeChallengeAddress(address))
+	if err != nil {
+		return err
+	}
+
+	certInfo, err := acmeReplaceCert(d.os.VarDir, certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+
+	d.endpoints.NetworkUpdateCert(certInfo)
+
+	cert, err := x509.ParseCertificate(certInfo.KeyPair().Certificate[0])
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Obtained the server certificate through ACME", log.Ctx{"domain": domain, "expiry": cert.NotAfter})
+	eventSendLifecycle("certificate-updated", fmt.Sprintf("/%s", version.APIVersion),
+		map[string]interface{}{"fingerprint": shared.CertFingerprint(cert), "domain": domain})
+
+	return nil
+}
+
+// Return the address to answer HTTP-01 challenges on, which is port 80 on the
+// host LXD is listening on for HTTPS, or an empty string if LXD isn't
+// available over the network.
+func acmeChallengeAddress(httpsAddress string) string {
+	if httpsAddress == "" {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(util.CanonicalNetworkAddress(httpsAddress))
+	if err != nil {
+		return ""
+	}
+
+	return net.JoinHostPort(host, "80")
+}
+
+// Replace the server certificate in the given directory with the given one,
+// keeping the previous one as server.crt.old and server.key.old. The previous
+// certificate is put back if the new one can't be loaded.
+func acmeReplaceCert(dir string, certPEM []byte, keyPEM []byte) (*shared.CertInfo, error) {
+	_, err := shared.KeyPairFromRaw(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid certificate obtained through ACME: %v", err)
+	}
+
+	renamed := []string{}
+	revert := func() {
+		for _, path := range renamed {
+			os.Rename(path+".old", path)
+		}
+	}
+
+	for _, name := range []string{"server.crt", "server.key"} {
+		path := filepath.Join(dir, name)
+		if !shared.PathExists(path) {
+			continue
+		}
+
+		err := os.Rename(path, path+".old")
+		if err != nil {
+			revert()
+			return nil, err
+		}
+
+		renamed = append(renamed, path)
+	}
+
+	var certInfo *shared.CertInfo
+	err = util.WriteCert(dir, "server", certPEM, keyPEM, nil)
+	if err == nil {
+		certInfo, err = util.LoadCert(dir)
+	}
+
+	if err != nil {
+		revert()
+		return nil, err
+	}
+
+	return certInfo, nil
+}
+
+// Go through an ACME order for the given domain, returning the PEM encoded
+// certificate chain and key. HTTP-01 challenges are also answered on the
+// given address, if any.
+func acmeObtainCert(ctx context.Context, d *Daemon, domain string, email string, caURL string, address string) ([]byte, []byte, error) {
+	accountKey, err := acmeAccountKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := &acme.Client{
+		Key:          accountKey,
+		DirectoryURL: caURL,
+		UserAgent:    version.UserAgent,
+		HTTPClient:   &http.Client{Transport: &http.Transport{Proxy: d.proxy}},
+	}
+
+	account := &acme.Account{}
+	if email != "" {
+		account.Contact = []string{"mailto:" + email}
+	}
+
+	_, err = client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, nil, fmt.Errorf("Failed to register the ACME account: %v", err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domain))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Listen on port 80 for the challenges, if possible
+	var listener net.Listener
+	if address != "" {
+		listener, err = net.Listen("tcp", address)
+		if err != nil {
+			logger.Warn("Unable to listen for ACME challenges", log.Ctx{"address": address, "err": err})
+		}
+	}
+
+	if listener != nil {
+		router := mux.NewRouter()
+		router.HandleFunc("/.well-known/acme-challenge/{token}", acmeChallengeGet)
+
+		server := &http.Server{Handler: router}
+		go server.Serve(listener)
+		defer server.Close()
+	}
+
+	for _, url := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, url)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+
+		var challenge *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == "http-01" {
+				challenge = c
+			}
+		}
+
+		if challenge == nil {
+			return nil, nil, fmt.Errorf("No HTTP-01 challenge offered for %s", authz.Identifier.Value)
+		}
+
+		response, err := client.HTTP01ChallengeResponse(challenge.Token)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		acmeChallengesLock.Lock()
+		acmeChallenges[challenge.Token] = response
+		acmeChallengesLock.Unlock()
+
+		_, err = client.Accept(ctx, challenge)
+		if err == nil {
+			_, err = client.WaitAuthorization(ctx, authz.URI)
+		}
+
+		acmeChallengesLock.Lock()
+		delete(acmeChallenges, challenge.Token)
+		acmeChallengesLock.Unlock()
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to validate %s: %v", authz.Identifier.Value, err)
+		}
+	}
+
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domain},
+		DNSNames: []string{domain},
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := []byte{}
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, nil
+}
+
+// Return the key of the ACME account, generating it on first use.
+func acmeAccountKey() (*ecdsa.PrivateKey, error) {
+	path := shared.VarPath("acme.key")
+
+	content, err := ioutil.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(content)
+		if block == nil {
+			return nil, fmt.Errorf("Invalid ACME account key %s", path)
+		}
+
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+)
+
+// Challenges are answered on port 80 of the host of the HTTPS address only.
+func TestAcmeChallengeAddress(t *testing.T) {
+	cases := map[string]string{
+		"":                 "",
+		"10.0.0.1":         "10.0.0.1:80",
+		"10.0.0.1:8443":    "10.0.0.1:80",
+		"[fd00::1]:8443":   "[fd00::1]:80",
+		"fd00::1":          "[fd00::1]:80",
+		"lxd.example.net":  "lxd.example.net:80",
+		"[::]:8443":        "[::]:80",
+		"0.0.0.0:8443":     "0.0.0.0:80",
+		"example.net:8443": "example.net:80",
+	}
+
+	for address, expected := range cases {
+		assert.Equal(t, expected, acmeChallengeAddress(address), address)
+	}
+}
+
+// Only the tokens of pending challenges are answered.
+func TestAcmeChallengeGet(t *testing.T) {
+	acmeChallengesLock.Lock()
+	acmeChallenges["token1"] = "response1"
+	acmeChallengesLock.Unlock()
+	defer func() {
+		acmeChallengesLock.Lock()
+		delete(acmeChallenges, "token1")
+		acmeChallengesLock.Unlock()
+	}()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/.well-known/acme-challenge/{token}", acmeChallengeGet)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/acme-challenge/token1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "response1", w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/acme-challenge/token2", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// The previous certificate is kept aside, and left in place if the new one
+// is unusable.
+func TestAcmeReplaceCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-acme-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	oldCert, oldKey, err := shared.GenerateMemCert(false)
+	require.NoError(t, err)
+	require.NoError(t, util.WriteCert(dir, "server", oldCert, oldKey, nil))
+
+	newCert, newKey, err := shared.GenerateMemCert(false)
+	require.NoError(t, err)
+
+	_, err = acmeReplaceCert(dir, newCert, oldKey)
+	assert.Error(t, err)
+	assertFileContent(t, filepath.Join(dir, "server.crt"), oldCert)
+	assertFileContent(t, filepath.Join(dir, "server.key"), oldKey)
+
+	certInfo, err := acmeReplaceCert(dir, newCert, newKey)
+	require.NoError(t, err)
+	assert.Equal(t, newCert, certInfo.PublicKey())
+	assertFileContent(t, filepath.Join(dir, "server.crt"), newCert)
+	assertFileContent(t, filepath.Join(dir, "server.key"), newKey)
+	assertFileContent(t, filepath.Join(dir, "server.crt.old"), oldCert)
+	assertFileContent(t, filepath.Join(dir, "server.key.old"), oldKey)
+}
+
+func assertFileContent(t *testing.T, path string, expected []byte) {
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(content))
+}
@@ -28,6 +28,12 @@ func RestServer(d *Daemon) *http.Server {
 		mux.HandleFunc(endpoint, f)
 	}
 
+	for endpoint, f := range d.simpleStreamsHandlerFuncs() {
+		mux.HandleFunc(endpoint, f)
+	}
+
+	mux.HandleFunc("/.well-known/acme-challenge/{token}", acmeChallengeGet)
+
 	for _, c := range api10 {
 		d.createCmd(mux, "1.0", c)
 	}
@@ -42,7 +48,10 @@ func RestServer(d *Daemon) *http.Server {
 		NotFound(nil).Render(w)
 	})
 
-	return &http.Server{Handler: &lxdHttpServer{r: mux, d: d}}
+	return &http.Server{
+		Handler:   &lxdHttpServer{r: mux, d: d},
+		ConnState: localPidMapper.ConnStateHandler,
+	}
 }
 
 type lxdHttpServer struct {
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"os"
+	"strconv"
 
 	"gopkg.in/lxc/go-lxc.v2"
 
@@ -24,7 +25,12 @@ var api10 = []Command{
 	containersCmd,
 	containerCmd,
 	containerConsoleCmd,
+	containerDeviceNodesCmd,
 	containerStateCmd,
+	containerUsageCmd,
+	containerProvenanceCmd,
+	containerProfilesCmd,
+	containerAnnotationsCmd,
 	containerFileCmd,
 	containerLogsCmd,
 	containerLogCmd,
@@ -36,9 +42,13 @@ var api10 = []Command{
 	containerBackupsCmd,
 	containerBackupCmd,
 	containerBackupExportCmd,
+	containerExportCmd,
+	containerSnapshotExportCmd,
+	containerRestoreCmd,
 	aliasCmd,
 	aliasesCmd,
 	eventsCmd,
+	imagesRetentionCmd,
 	imageCmd,
 	imagesCmd,
 	imagesExportCmd,
@@ -57,6 +67,10 @@ var api10 = []Command{
 	certificateFingerprintCmd,
 	profilesCmd,
 	profileCmd,
+	profileUsedByCmd,
+	seccompPoliciesCmd,
+	seccompPolicyCmd,
+	metadataConfigurationCmd,
 	serverResourceCmd,
 	storagePoolsCmd,
 	storagePoolCmd,
@@ -68,6 +82,10 @@ var api10 = []Command{
 	clusterCmd,
 	clusterNodesCmd,
 	clusterNodeCmd,
+	clusterCertificateCmd,
+	clusterUpgradeCmd,
+	warningsCmd,
+	warningCmd,
 }
 
 func api10Get(d *Daemon, r *http.Request) Response {
@@ -172,6 +190,12 @@ func api10Get(d *Daemon, r *http.Request) Response {
 		ServerVersion:          version.Version,
 		ServerClustered:        clustered,
 		ServerName:             serverName,
+		KernelFeatures: map[string]string{
+			"apparmor_stacking": strconv.FormatBool(d.os.AppArmorStacking),
+			"cgroup_unified":    strconv.FormatBool(d.os.CGroupUnified),
+			"shiftfs":           strconv.FormatBool(d.os.Shiftfs),
+			"user_namespaces":   strconv.FormatBool(d.os.IdmapSet != nil),
+		},
 	}
 
 	drivers := readStoragePoolDriversCache()
@@ -277,9 +301,14 @@ func doApi10Update(d *Daemon, req api.ServerPut, patch bool) Response {
 		}
 	}
 
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
+		return SmartError(err)
+	}
+
 	nodeChanged := map[string]string{}
 	var newNodeConfig *node.Config
-	err := d.db.Transaction(func(tx *db.NodeTx) error {
+	err = d.db.Transaction(func(tx *db.NodeTx) error {
 		var err error
 		newNodeConfig, err = node.ConfigLoad(tx)
 		if err != nil {
@@ -290,7 +319,16 @@ func doApi10Update(d *Daemon, req api.ServerPut, patch bool) Response {
 		} else {
 			nodeChanged, err = newNodeConfig.Replace(nodeValues)
 		}
-		return err
+		if err != nil {
+			return err
+		}
+
+		// Cluster members are known by their cluster address
+		value, ok := nodeChanged["cluster.https_address"]
+		if clustered && ok {
+			return config.ErrorList{&config.Error{Name: "cluster.https_address", Value: value, Reason: "cannot be changed on clustered servers"}}
+		}
+		return nil
 	})
 	if err != nil {
 		switch err.(type) {
@@ -357,6 +395,8 @@ func doApi10Update(d *Daemon, req api.ServerPut, patch bool) Response {
 
 func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]string, nodeConfig *node.Config, clusterConfig *cluster.Config) error {
 	maasChanged := false
+	bgpChanged := false
+	sharedChanged := false
 	for key, value := range clusterChanged {
 		switch key {
 		case "core.proxy_http":
@@ -365,10 +405,18 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			fallthrough
 		case "core.proxy_ignore_hosts":
 			daemonConfigSetProxy(d, clusterConfig)
+		case "images.mirrors":
+			fallthrough
+		case "images.proxy":
+			imageStreamCacheExpire()
 		case "maas.api.url":
 			fallthrough
 		case "maas.api.key":
 			maasChanged = true
+		case "bgp.asn":
+			fallthrough
+		case "bgp.peers":
+			bgpChanged = true
 		case "core.macaroon.endpoint":
 			err := d.setupExternalAuthentication(value)
 			if err != nil {
@@ -382,17 +430,56 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			if !d.os.MockMode {
 				d.taskPruneImages.Reset()
 			}
+		case "core.compliance_check_interval":
+			d.taskComplianceCheck.Reset()
+		case "core.usage_history_interval":
+			d.taskUsageHistory.Reset()
+		case "cluster.balance.interval":
+			d.taskBalance.Reset()
+		case "storage.shared_source":
+			fallthrough
+		case "storage.shared_cluster_name":
+			fallthrough
+		case "storage.shared_user_name":
+			sharedChanged = true
 		}
 	}
 	for key, value := range nodeChanged {
 		switch key {
 		case "maas.machine":
 			maasChanged = true
+		case "bgp.routerid":
+			bgpChanged = true
 		case "core.https_address":
 			err := d.endpoints.NetworkUpdateAddress(value)
 			if err != nil {
 				return err
 			}
+		case "core.unix_groups":
+			err := d.localGroupsUpdate()
+			if err != nil {
+				return err
+			}
+		case "cluster.https_address":
+			err := d.endpoints.ClusterUpdateAddress(value)
+			if err != nil {
+				return err
+			}
+		case "cluster.replica_interval":
+			if value == "" || value == "0" {
+				d.replicaSet(nil)
+			}
+			if d.taskReplica != nil {
+				d.taskReplica.Reset()
+			}
+		case "acme.domain":
+			fallthrough
+		case "acme.email":
+			fallthrough
+		case "acme.ca_url":
+			if d.taskACME != nil {
+				d.taskACME.Reset()
+			}
 		}
 	}
 	if maasChanged {
@@ -403,6 +490,18 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			return err
 		}
 	}
+	if bgpChanged {
+		err := d.setupBGP(clusterConfig.BGPASN(), clusterConfig.BGPPeers(), nodeConfig.BGPRouterID())
+		if err != nil {
+			return err
+		}
+	}
+	if sharedChanged && !d.os.MockMode {
+		err := storageSharedSetup(d.State())
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/gorilla/mux"
 	lxd "github.com/lxc/lxd/client"
@@ -20,6 +25,8 @@ import (
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
 	"github.com/pkg/errors"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
 var clusterCmd = Command{
@@ -178,7 +185,7 @@ func clusterPutJoin(d *Daemon, req api.ClusterPut) Response {
 		return BadRequest(fmt.Errorf("No target cluster node certificate provided"))
 	}
 
-	address, err := node.HTTPSAddress(d.db)
+	address, err := node.ClusterAddress(d.db)
 	if err != nil {
 		return SmartError(err)
 	}
@@ -360,7 +367,7 @@ func clusterPutJoin(d *Daemon, req api.ClusterPut) Response {
 			}
 		}
 
-		// FIXME: special case handling MAAS connection if the config
+		// FIXME: special case handling MAAS and BGP if the config
 		// in the cluster is different than what we had locally before
 		// joining. Ideally this should be something transparent or
 		// more generic, perhaps triggering some parts of Daemon.Init.
@@ -389,6 +396,11 @@ func clusterPutJoin(d *Daemon, req api.ClusterPut) Response {
 			return err
 		}
 
+		err = d.setupBGP(clusterConfig.BGPASN(), clusterConfig.BGPPeers(), nodeConfig.BGPRouterID())
+		if err != nil {
+			logger.Warnf("Unable to setup the BGP speaker: %v", err)
+		}
+
 		// Add the cluster flag from the agent
 		version.UserAgentFeatures([]string{"cluster"})
 
@@ -438,7 +450,7 @@ func clusterPutDisable(d *Daemon) Response {
 	}
 
 	// Re-open the cluster database
-	address, err := node.HTTPSAddress(d.db)
+	address, err := node.ClusterAddress(d.db)
 	if err != nil {
 		return SmartError(err)
 	}
@@ -765,6 +777,166 @@ func tryClusterRebalance(d *Daemon) error {
 	return nil
 }
 
+var clusterUpgradeCmd = Command{
+	name: "cluster/upgrade",
+	get:  clusterUpgradeGet,
+}
+
+// Report the progress of a rolling upgrade of the cluster, that is which
+// members are still waiting to be upgraded to the most recent version.
+func clusterUpgradeGet(d *Daemon, r *http.Request) Response {
+	var status *api.ClusterUpgrade
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		status, err = cluster.UpgradeStatus(tx)
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, status)
+}
+
+var clusterCertificateCmd = Command{
+	name: "cluster/certificate",
+	put:  clusterCertificatePut,
+}
+
+// Replace the certificate of the server, or of the whole cluster.
+func clusterCertificatePut(d *Daemon, r *http.Request) Response {
+	req := api.ClusterCertificatePut{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	keypair, err := tls.X509KeyPair([]byte(req.ClusterCertificate), []byte(req.ClusterCertificateKey))
+	if err != nil {
+		return BadRequest(errors.Wrap(err, "Invalid certificate or key"))
+	}
+
+	cert, err := x509.ParseCertificate(keypair.Certificate[0])
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = trustCertificateValid(cert)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Rotate the certificate of the other nodes first, since they're
+	// reached with the current one.
+	revert := func() {}
+	if clustered && !isClusterNotification(r) {
+		revert, err = clusterCertificateRotate(d, req)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	prefix := "server"
+	if clustered {
+		prefix = "cluster"
+	}
+
+	err = util.WriteCert(d.os.VarDir, prefix, []byte(req.ClusterCertificate), []byte(req.ClusterCertificateKey), nil)
+	if err != nil {
+		revert()
+		return SmartError(errors.Wrap(err, "Failed to save certificate"))
+	}
+
+	certInfo, err := util.LoadCert(d.os.VarDir)
+	if err != nil {
+		revert()
+		return SmartError(errors.Wrap(err, "Failed to load certificate"))
+	}
+
+	d.endpoints.NetworkUpdateCert(certInfo)
+	if clustered {
+		d.gateway.UpdateCert(certInfo)
+	}
+
+	eventSendLifecycle("certificate-updated", fmt.Sprintf("/%s/cluster/certificate", version.APIVersion),
+		map[string]interface{}{"fingerprint": shared.CertFingerprint(cert)})
+
+	return EmptySyncResponse
+}
+
+// Rotate the cluster certificate of all other nodes, which must be online.
+//
+// If any of them fails, the nodes which were already rotated get the current
+// certificate back, so the cluster doesn't end up split across two
+// certificates. The returned function does the same, for when the rotation of
+// this node fails afterwards.
+func clusterCertificateRotate(d *Daemon, req api.ClusterCertificatePut) (func(), error) {
+	current := api.ClusterCertificatePut{}
+	data, err := ioutil.ReadFile(filepath.Join(d.os.VarDir, "cluster.crt"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read current certificate")
+	}
+	current.ClusterCertificate = string(data)
+
+	data, err = ioutil.ReadFile(filepath.Join(d.os.VarDir, "cluster.key"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read current certificate key")
+	}
+	current.ClusterCertificateKey = string(data)
+
+	newCert, err := shared.KeyPairFromRaw([]byte(req.ClusterCertificate), []byte(req.ClusterCertificateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), cluster.NotifyAll)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated := []string{}
+	mu := sync.Mutex{}
+	revert := func() {
+		for _, address := range rotated {
+			client, err := cluster.Connect(address, newCert, true)
+			if err == nil {
+				err = client.UpdateClusterCertificate(current, "")
+			}
+			if err != nil {
+				logger.Error("Failed to restore the cluster certificate of node", log.Ctx{"address": address, "err": err})
+			}
+		}
+	}
+
+	err = notifier(func(client lxd.ContainerServer) error {
+		err := client.UpdateClusterCertificate(req, "")
+		if err != nil {
+			return err
+		}
+
+		info, err := client.GetConnectionInfo()
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		rotated = append(rotated, strings.TrimPrefix(info.URL, "https://"))
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		revert()
+		return nil, err
+	}
+
+	return revert, nil
+}
+
 var internalClusterAcceptCmd = Command{name: "cluster/accept", post: internalClusterPostAccept}
 
 func internalClusterPostAccept(d *Daemon, r *http.Request) Response {
@@ -783,7 +955,7 @@ func internalClusterPostAccept(d *Daemon, r *http.Request) Response {
 
 	// Redirect all requests to the leader, which is the one with
 	// knowning what nodes are part of the raft cluster.
-	address, err := node.HTTPSAddress(d.db)
+	address, err := node.ClusterAddress(d.db)
 	if err != nil {
 		return SmartError(err)
 	}
@@ -856,7 +1028,7 @@ var internalClusterRebalanceCmd = Command{name: "cluster/rebalance", post: inter
 func internalClusterPostRebalance(d *Daemon, r *http.Request) Response {
 	// Redirect all requests to the leader, which is the one with with
 	// up-to-date knowledge of what nodes are part of the raft cluster.
-	localAddress, err := node.HTTPSAddress(d.db)
+	localAddress, err := node.ClusterAddress(d.db)
 	if err != nil {
 		return SmartError(err)
 	}
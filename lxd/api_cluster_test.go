@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -522,6 +523,74 @@ func TestCluster_NodeRename(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// Rotating the cluster certificate replaces it on all nodes.
+func TestCluster_CertificateUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping cluster certificate test in short mode.")
+	}
+	daemons, cleanup := newDaemons(t, 2)
+	defer cleanup()
+
+	f := clusterFixture{t: t}
+	f.FormCluster(daemons)
+
+	cert, key, err := shared.GenerateMemCert(false)
+	require.NoError(t, err)
+
+	client := f.ClientUnix(daemons[0])
+	req := api.ClusterCertificatePut{
+		ClusterCertificate:    string(cert),
+		ClusterCertificateKey: string(key),
+	}
+	require.NoError(t, client.UpdateClusterCertificate(req, ""))
+
+	for _, daemon := range daemons {
+		content, err := ioutil.ReadFile(filepath.Join(daemon.os.VarDir, "cluster.crt"))
+		require.NoError(t, err)
+		assert.Equal(t, string(cert), string(content))
+		assert.Equal(t, string(cert), string(daemon.endpoints.NetworkPublicKey()))
+	}
+}
+
+// If the certificate of a node can't be rotated, the nodes which were
+// already rotated get the previous certificate back.
+func TestCluster_CertificateUpdateRollback(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping cluster certificate test in short mode.")
+	}
+	daemons, cleanup := newDaemons(t, 3)
+	defer cleanup()
+
+	f := clusterFixture{t: t}
+	f.FormCluster(daemons)
+
+	path := filepath.Join(daemons[0].os.VarDir, "cluster.crt")
+	previous, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	// Saving the certificate on the last node fails.
+	broken := filepath.Join(daemons[2].os.VarDir, "cluster.crt")
+	require.NoError(t, os.Remove(broken))
+	require.NoError(t, os.Mkdir(broken, 0755))
+
+	cert, key, err := shared.GenerateMemCert(false)
+	require.NoError(t, err)
+
+	client := f.ClientUnix(daemons[0])
+	req := api.ClusterCertificatePut{
+		ClusterCertificate:    string(cert),
+		ClusterCertificateKey: string(key),
+	}
+	assert.Error(t, client.UpdateClusterCertificate(req, ""))
+
+	for _, daemon := range daemons[:2] {
+		content, err := ioutil.ReadFile(filepath.Join(daemon.os.VarDir, "cluster.crt"))
+		require.NoError(t, err)
+		assert.Equal(t, string(previous), string(content))
+		assert.Equal(t, string(previous), string(daemon.endpoints.NetworkPublicKey()))
+	}
+}
+
 // Test helper for cluster-related APIs.
 type clusterFixture struct {
 	t       *testing.T
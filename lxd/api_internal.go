@@ -32,7 +32,12 @@ var apiInternal = []Command{
 	internalContainerOnStartCmd,
 	internalContainerOnStopCmd,
 	internalContainersCmd,
+	internalRecoverCmd,
 	internalSQLCmd,
+	internalDatabaseBackupCmd,
+	internalDatabaseRestoreCmd,
+	internalIdmapsCmd,
+	internalIdmapsDefragmentCmd,
 	internalClusterAcceptCmd,
 	internalClusterRebalanceCmd,
 	internalClusterPromoteCmd,
@@ -315,6 +320,12 @@ func internalImport(d *Daemon, r *http.Request) Response {
 			`is required`))
 	}
 
+	return internalImportContainer(d, req)
+}
+
+// Recreate the database entries of the given container, and of its
+// snapshots, from the backup.yaml file found in its storage volume.
+func internalImportContainer(d *Daemon, req *internalImportPost) Response {
 	storagePoolsPath := shared.VarPath("storage-pools")
 	storagePoolsDir, err := os.Open(storagePoolsPath)
 	if err != nil {
@@ -455,7 +466,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 				return InternalError(err)
 			}
 			snapshotsDir.Close()
-		case "dir":
+		case "dir", "external":
 			snapshotsDirPath := getSnapshotMountPoint(poolName, req.Name)
 			snapshotsDir, err := os.Open(snapshotsDirPath)
 			if err != nil {
@@ -571,7 +582,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		case "btrfs":
 			snapName := fmt.Sprintf("%s/%s", req.Name, od)
 			err = btrfsSnapshotDeleteInternal(poolName, snapName)
-		case "dir":
+		case "dir", "external":
 			snapName := fmt.Sprintf("%s/%s", req.Name, od)
 			err = dirSnapshotDeleteInternal(poolName, snapName)
 		case "lvm":
@@ -623,7 +634,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 				}
 				return BadRequest(needForce)
 			}
-		case "dir":
+		case "dir", "external":
 			snpMntPt := getSnapshotMountPoint(backup.Pool.Name, snap.Name)
 			if !shared.PathExists(snpMntPt) {
 				if req.Force {
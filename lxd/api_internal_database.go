@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var internalDatabaseBackupCmd = Command{name: "database/backup", post: internalDatabaseBackup}
+var internalDatabaseRestoreCmd = Command{name: "database/restore", post: internalDatabaseRestore}
+
+// The file holding a backup of the global database, as a SQL text dump.
+type internalDatabaseFile struct {
+	Path string `json:"path" yaml:"path"`
+}
+
+// Write a consistent snapshot of the global database to a file of this node,
+// by default in the database/backups directory.
+func internalDatabaseBackup(d *Daemon, r *http.Request) Response {
+	req := internalDatabaseFile{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Path == "" {
+		name := fmt.Sprintf("global-%s.sql", time.Now().UTC().Format("20060102150405"))
+		req.Path = shared.VarPath("database", "backups", name)
+	}
+
+	if !filepath.IsAbs(req.Path) {
+		return BadRequest(fmt.Errorf("The backup path must be absolute"))
+	}
+
+	dump, err := d.cluster.Backup()
+	if err != nil {
+		return SmartError(errors.Wrap(err, "Failed to dump the global database"))
+	}
+
+	err = os.MkdirAll(filepath.Dir(req.Path), 0700)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = ioutil.WriteFile(req.Path, []byte(dump), 0600)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	logger.Info("Backed up the global database", log.Ctx{"path": req.Path})
+
+	return SyncResponse(true, req)
+}
+
+// Replace the content of the global database with a backup made by
+// internalDatabaseBackup. The cluster nodes keep their in-memory state, so
+// they should be restarted once this is done.
+func internalDatabaseRestore(d *Daemon, r *http.Request) Response {
+	req := internalDatabaseFile{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Path == "" {
+		return BadRequest(fmt.Errorf("No backup path provided"))
+	}
+
+	dump, err := ioutil.ReadFile(req.Path)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = d.cluster.Restore(string(dump))
+	if err != nil {
+		return SmartError(errors.Wrapf(err, "Failed to restore the global database from %s", req.Path))
+	}
+
+	logger.Warn("Restored the global database, LXD should now be restarted on all nodes", log.Ctx{"path": req.Path})
+
+	return EmptySyncResponse
+}
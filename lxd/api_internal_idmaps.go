@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var internalIdmapsCmd = Command{name: "idmaps", get: internalIdmapsGet}
+var internalIdmapsDefragmentCmd = Command{name: "idmaps/defragment", post: internalIdmapsDefragment}
+
+// The range of host ids available to isolated containers, along with the
+// ranges currently allocated on this node.
+type internalIdmaps struct {
+	Base        int64                     `json:"base" yaml:"base"`
+	Size        int64                     `json:"size" yaml:"size"`
+	Allocations []internalIdmapAllocation `json:"allocations" yaml:"allocations"`
+}
+
+type internalIdmapAllocation struct {
+	Container string `json:"container" yaml:"container"`
+	Base      int64  `json:"base" yaml:"base"`
+	Size      int64  `json:"size" yaml:"size"`
+	Running   bool   `json:"running" yaml:"running"`
+
+	// Set by defragmentation when the allocation got moved.
+	Previous int64 `json:"previous,omitempty" yaml:"previous,omitempty"`
+}
+
+func internalIdmapsGet(d *Daemon, r *http.Request) Response {
+	idmaps, err := internalIdmapsLoad(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, idmaps)
+}
+
+// Pack the idmap ranges of the stopped isolated containers towards the start
+// of the host range. Running containers, containers with an explicit
+// security.idmap.base and shift-protected containers keep their allocation. The moved containers get their
+// filesystem shifted to the new range the next time they start.
+func internalIdmapsDefragment(d *Daemon, r *http.Request) Response {
+	idmapLock.Lock()
+	defer idmapLock.Unlock()
+
+	idmaps, err := internalIdmapsLoad(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	taken := idmap.ByHostid{}
+	movable := []int{}
+	cts := map[string]container{}
+	for i, allocation := range idmaps.Allocations {
+		c, err := containerLoadByName(d.State(), allocation.Container)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		config := c.ExpandedConfig()
+		if allocation.Running || config["security.idmap.base"] != "" || shared.IsTrue(config["security.protection.shift"]) {
+			taken = append(taken, &idmap.IdmapEntry{Hostid: allocation.Base, Maprange: allocation.Size})
+			continue
+		}
+
+		movable = append(movable, i)
+		cts[allocation.Container] = c
+	}
+
+	for _, i := range movable {
+		allocation := &idmaps.Allocations[i]
+
+		base, err := idmapFirstFit(taken, idmaps.Base, idmaps.Base+idmaps.Size, allocation.Size)
+		if err != nil || base >= allocation.Base {
+			base = allocation.Base
+		}
+
+		taken = append(taken, &idmap.IdmapEntry{Hostid: base, Maprange: allocation.Size})
+		if base == allocation.Base {
+			continue
+		}
+
+		c := cts[allocation.Container]
+		rawMaps, err := parseRawIdmap(c.ExpandedConfig()["raw.idmap"])
+		if err != nil {
+			return SmartError(err)
+		}
+
+		set, err := idmapIsolated(base, allocation.Size, rawMaps)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		idmapBytes, err := json.Marshal(set.Idmap)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		err = c.ConfigKeySet("volatile.idmap.next", string(idmapBytes))
+		if err != nil {
+			return SmartError(err)
+		}
+
+		err = c.ConfigKeySet("volatile.idmap.base", fmt.Sprintf("%v", base))
+		if err != nil {
+			return SmartError(err)
+		}
+
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.ContainerIdmapSet(allocation.Container, base, allocation.Size)
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+
+		logger.Info("Moved container idmap", log.Ctx{"container": allocation.Container, "old": allocation.Base, "new": base})
+
+		allocation.Previous = allocation.Base
+		allocation.Base = base
+	}
+
+	sort.Slice(idmaps.Allocations, func(i, j int) bool {
+		return idmaps.Allocations[i].Base < idmaps.Allocations[j].Base
+	})
+
+	return SyncResponse(true, idmaps)
+}
+
+// Return the host range available to isolated containers and the allocations
+// recorded for the containers of this node.
+func internalIdmapsLoad(d *Daemon) (*internalIdmaps, error) {
+	if d.os.IdmapSet == nil || len(d.os.IdmapSet.Idmap) == 0 {
+		return nil, fmt.Errorf("No uid/gid allocation configured")
+	}
+
+	// The first 65536 ids are used by non-isolated containers.
+	hostMap := d.os.IdmapSet.Idmap[0]
+	idmaps := &internalIdmaps{
+		Base:        hostMap.Hostid + 65536,
+		Size:        hostMap.Maprange - 65536,
+		Allocations: []internalIdmapAllocation{},
+	}
+
+	var allocations []db.ContainerIdmap
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		allocations, err = tx.ContainerIdmaps()
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load idmap allocations")
+	}
+
+	for _, allocation := range allocations {
+		c, err := containerLoadByName(d.State(), allocation.Container)
+		if err != nil {
+			return nil, err
+		}
+
+		idmaps.Allocations = append(idmaps.Allocations, internalIdmapAllocation{
+			Container: allocation.Container,
+			Base:      allocation.Base,
+			Size:      allocation.Size,
+			Running:   c.IsRunning(),
+		})
+	}
+
+	return idmaps, nil
+}
+
+// Return the lowest base in the [start, end) host range where size ids don't
+// overlap any of the taken ranges.
+func idmapFirstFit(taken idmap.ByHostid, start int64, end int64, size int64) (int64, error) {
+	sort.Sort(taken)
+
+	offset := start
+	for _, entry := range taken {
+		if offset+size <= entry.Hostid {
+			break
+		}
+
+		if entry.Hostid+entry.Maprange > offset {
+			offset = entry.Hostid + entry.Maprange
+		}
+	}
+
+	if offset+size > end {
+		return -1, fmt.Errorf("Not enough uid/gid available")
+	}
+
+	return offset, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var internalRecoverCmd = Command{name: "recover", get: internalRecoverGet, post: internalRecoverPost}
+
+// The containers and custom volumes found on disk in a storage pool which
+// have no database entries.
+type internalRecoverScan struct {
+	Pool       string   `json:"pool" yaml:"pool"`
+	Containers []string `json:"containers" yaml:"containers"`
+	Volumes    []string `json:"volumes" yaml:"volumes"`
+}
+
+type internalRecoverRequest struct {
+	Pool       string   `json:"pool" yaml:"pool"`
+	Containers []string `json:"containers" yaml:"containers"`
+	Volumes    []string `json:"volumes" yaml:"volumes"`
+	Force      bool     `json:"force" yaml:"force"`
+}
+
+// Return the names of the directories found in the given directory of a
+// storage pool, if any.
+func internalRecoverList(path string) ([]string, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		names = append(names, entry.Name())
+	}
+
+	return names, nil
+}
+
+// Scan the mounted storage pool with the given name for containers and
+// custom volumes which LXD no longer knows about.
+func internalRecoverScanPool(d *Daemon, poolName string) (*internalRecoverScan, error) {
+	poolPath := shared.VarPath("storage-pools", poolName)
+	if !shared.PathExists(poolPath) {
+		return nil, fmt.Errorf("The storage pool \"%s\" isn't mounted at \"%s\"", poolName, poolPath)
+	}
+
+	scan := &internalRecoverScan{
+		Pool:       poolName,
+		Containers: []string{},
+		Volumes:    []string{},
+	}
+
+	names, err := internalRecoverList(filepath.Join(poolPath, "containers"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		_, err := d.cluster.ContainerID(name)
+		if err == nil {
+			continue
+		}
+
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		// Only containers carrying their backup file can be recovered
+		if !shared.PathExists(filepath.Join(getContainerMountPoint(poolName, name), "backup.yaml")) {
+			logger.Warn("Skipping container without backup file", log.Ctx{"pool": poolName, "container": name})
+			continue
+		}
+
+		scan.Containers = append(scan.Containers, name)
+	}
+
+	names, err = internalRecoverList(filepath.Join(poolPath, "custom"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a database entry for the pool, none of its volumes is known
+	poolID, err := d.cluster.StoragePoolGetID(poolName)
+	if err != nil && err != db.ErrNoSuchObject {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if poolID != -1 {
+			_, _, err := d.cluster.StoragePoolNodeVolumeGetType(name, storagePoolVolumeTypeCustom, poolID)
+			if err == nil {
+				continue
+			}
+
+			if err != db.ErrNoSuchObject {
+				return nil, err
+			}
+		}
+
+		scan.Volumes = append(scan.Volumes, name)
+	}
+
+	return scan, nil
+}
+
+func internalRecoverGet(d *Daemon, r *http.Request) Response {
+	poolName := r.FormValue("pool")
+	if poolName == "" {
+		return BadRequest(fmt.Errorf("The name of the storage pool is required"))
+	}
+
+	scan, err := internalRecoverScanPool(d, poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, scan)
+}
+
+func internalRecoverPost(d *Daemon, r *http.Request) Response {
+	req := &internalRecoverRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Pool == "" {
+		return BadRequest(fmt.Errorf("The name of the storage pool is required"))
+	}
+
+	// Only recover what the user confirmed and is still missing
+	scan, err := internalRecoverScanPool(d, req.Pool)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, name := range req.Containers {
+		if !shared.StringInSlice(name, scan.Containers) {
+			return BadRequest(fmt.Errorf("The container \"%s\" can't be recovered from the storage pool \"%s\"", name, req.Pool))
+		}
+	}
+
+	for _, name := range req.Volumes {
+		if !shared.StringInSlice(name, scan.Volumes) {
+			return BadRequest(fmt.Errorf("The storage volume \"%s\" can't be recovered from the storage pool \"%s\"", name, req.Pool))
+		}
+	}
+
+	// Containers go first since their backup file allows recreating the
+	// storage pool entry itself.
+	for _, name := range req.Containers {
+		logger.Info("Recovering container", log.Ctx{"pool": req.Pool, "container": name})
+		resp := internalImportContainer(d, &internalImportPost{Name: name, Force: req.Force})
+		if resp != EmptySyncResponse {
+			return resp
+		}
+	}
+
+	if len(req.Volumes) > 0 {
+		_, err := d.cluster.StoragePoolGetID(req.Pool)
+		if err == db.ErrNoSuchObject {
+			return BadRequest(fmt.Errorf("The storage pool \"%s\" needs to be recovered before its volumes", req.Pool))
+		}
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	for _, name := range req.Volumes {
+		logger.Info("Recovering storage volume", log.Ctx{"pool": req.Pool, "volume": name})
+		err := storagePoolVolumeDBCreate(d.State(), req.Pool, name, "", storagePoolVolumeTypeNameCustom, nil)
+		if err != nil {
+			return SmartError(fmt.Errorf("Failed to recover storage volume \"%s\": %v", name, err))
+		}
+	}
+
+	return EmptySyncResponse
+}
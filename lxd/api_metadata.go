@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/config"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var metadataConfigurationCmd = Command{name: "metadata/configuration", get: metadataConfigurationGet}
+
+// /1.0/metadata/configuration
+// Get the schema of the container and server configuration keys
+func metadataConfigurationGet(d *Daemon, r *http.Request) Response {
+	metadata := api.MetadataConfiguration{
+		Container: map[string]api.MetadataConfigurationKey{},
+		Server:    map[string]api.MetadataConfigurationKey{},
+	}
+
+	for name, key := range shared.ContainerConfigSchema {
+		metadata.Container[name] = api.MetadataConfigurationKey{
+			Type:       key.Type,
+			Default:    key.Default,
+			LiveUpdate: key.LiveUpdate,
+			Deprecated: key.Deprecated,
+		}
+	}
+
+	// Server keys always apply live, whether cluster-wide or node-local
+	for _, schema := range []config.Schema{cluster.ConfigSchema, node.ConfigSchema} {
+		for name, key := range schema {
+			metadata.Server[name] = api.MetadataConfigurationKey{
+				Type:       metadataConfigurationType(key.Type),
+				Default:    key.Default,
+				LiveUpdate: true,
+				Deprecated: key.Deprecated,
+			}
+		}
+	}
+
+	return SyncResponse(true, metadata)
+}
+
+// Get the name of a server configuration key type.
+func metadataConfigurationType(t config.Type) string {
+	switch t {
+	case config.Bool:
+		return "boolean"
+	case config.Int64:
+		return "integer"
+	default:
+		return "string"
+	}
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"time"
@@ -152,6 +155,8 @@ func getBackupInfo(r io.Reader) (*backupInfo, error) {
 	result := backupInfo{}
 	hasBinaryFormat := false
 	hasIndexFile := false
+	var checksums map[string]string
+	hashes := map[string]string{}
 	tr := tar.NewReader(&buf)
 	for {
 		hdr, err := tr.Next()
@@ -169,17 +174,52 @@ func getBackupInfo(r io.Reader) (*backupInfo, error) {
 			}
 
 			hasIndexFile = true
+			continue
+		}
+
+		if hdr.Name == "backup/checksums.yaml" {
+			err = yaml.NewDecoder(tr).Decode(&checksums)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
 		}
 
 		if hdr.Name == "backup/container.bin" {
 			hasBinaryFormat = true
 		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			hash := sha256.New()
+			_, err = io.Copy(hash, tr)
+			if err != nil {
+				return nil, err
+			}
+
+			hashes[hdr.Name] = hex.EncodeToString(hash.Sum(nil))
+		case tar.TypeLink:
+			hashes[hdr.Name] = hashes[hdr.Linkname]
+		}
 	}
 
 	if !hasIndexFile {
 		return nil, fmt.Errorf("Backup is missing index.yaml")
 	}
 
+	// Backups made by older versions come without checksums
+	for name, checksum := range checksums {
+		hash, ok := hashes[name]
+		if !ok {
+			return nil, fmt.Errorf("Backup is missing \"%s\"", name)
+		}
+
+		if hash != checksum {
+			return nil, fmt.Errorf("Checksum mismatch of \"%s\" in backup", name)
+		}
+	}
+
 	result.HasBinaryFormat = hasBinaryFormat
 	return &result, nil
 }
@@ -293,3 +333,59 @@ func createBackupIndexFile(container container, backup backup) error {
 
 	return nil
 }
+
+// createBackupChecksumsFile records the checksum of every file of the backup,
+// to be verified when restoring it.
+func createBackupChecksumsFile(container container, backup backup) error {
+	pool, err := container.StoragePool()
+	if err != nil {
+		return err
+	}
+
+	backupPath := getBackupMountPoint(pool, backup.Name())
+	checksums := map[string]string{}
+	err = filepath.Walk(backupPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(backupPath, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && relPath == "snapshots" && backup.ContainerOnly() {
+			return filepath.SkipDir
+		}
+
+		if !info.Mode().IsRegular() || relPath == "index.yaml" || relPath == "checksums.yaml" {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		hash := sha256.New()
+		_, err = io.Copy(hash, f)
+		if err != nil {
+			return err
+		}
+
+		// Named as in the backup tarball
+		checksums[filepath.Join("backup", relPath)] = hex.EncodeToString(hash.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&checksums)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(backupPath, "checksums.yaml"), data, 0600)
+}
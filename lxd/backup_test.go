@@ -0,0 +1,63 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Build a compressed backup tarball out of the given files.
+func backupTestTarball(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		require.NoError(t, err)
+
+		_, err = tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	var compressed bytes.Buffer
+	err := shared.RunCommandWithFds(&buf, &compressed, "xz", "-c")
+	require.NoError(t, err)
+
+	return &compressed
+}
+
+// The files of a backup are checked against its checksums.yaml, if any.
+func TestGetBackupInfoChecksums(t *testing.T) {
+	files := map[string]string{
+		"backup/index.yaml":                  "name: c1\nbackend: dir\n",
+		"backup/container/rootfs/etc/passwd": "root:x:0:0::/root:/bin/sh\n",
+		"backup/checksums.yaml":              "backup/container/rootfs/etc/passwd: ad9d1c8e89d5e6b1ff3b3cddc3e1d2ce70e8df3c0e6de3b5a6d9b3ad5e5a74bc\n",
+	}
+
+	_, err := getBackupInfo(backupTestTarball(t, files))
+	assert.EqualError(t, err, "Checksum mismatch of \"backup/container/rootfs/etc/passwd\" in backup")
+
+	files["backup/checksums.yaml"] = "backup/container/rootfs/etc/shadow: ad9d1c8e89d5e6b1ff3b3cddc3e1d2ce70e8df3c0e6de3b5a6d9b3ad5e5a74bc\n"
+	_, err = getBackupInfo(backupTestTarball(t, files))
+	assert.EqualError(t, err, "Backup is missing \"backup/container/rootfs/etc/shadow\"")
+
+	files["backup/checksums.yaml"] = "backup/container/rootfs/etc/passwd: 037d604c1c026b9f815eaeda9bad221290c9f6354587d3ca87f54c4b7466fad4\n"
+	info, err := getBackupInfo(backupTestTarball(t, files))
+	require.NoError(t, err)
+	assert.Equal(t, "c1", info.Name)
+
+	// Backups made by older versions
+	delete(files, "backup/checksums.yaml")
+	_, err = getBackupInfo(backupTestTarball(t, files))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/lxc/lxd/lxd/bgp"
+)
+
+// Setup BGP
+func (d *Daemon) setupBGP(asn uint32, peers map[string]uint32, routerID string) error {
+	// An AS number of zero disables the BGP speaker
+	if asn == 0 {
+		return d.bgp.Configure(0, nil, nil)
+	}
+
+	if routerID == "" {
+		d.bgp.Configure(0, nil, nil)
+		return fmt.Errorf("No BGP router ID set for this node (bgp.routerid)")
+	}
+
+	list := []bgp.Peer{}
+	for address, peerASN := range peers {
+		list = append(list, bgp.Peer{Address: net.ParseIP(address), ASN: peerASN})
+	}
+
+	return d.bgp.Configure(asn, net.ParseIP(routerID), list)
+}
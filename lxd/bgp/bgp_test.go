@@ -0,0 +1,124 @@
+package bgp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseCIDR(t *testing.T, value string) net.IPNet {
+	_, subnet, err := net.ParseCIDR(value)
+	require.NoError(t, err)
+
+	return *subnet
+}
+
+// OPEN messages carry 4-octet AS numbers as a capability.
+func TestOpen(t *testing.T) {
+	msg := encodeOpen(open{asn: 4200000001, holdTime: 90, routerID: net.ParseIP("10.0.0.1")})
+
+	kind, body, err := readMessage(&fakeReader{data: msg})
+	require.NoError(t, err)
+	assert.Equal(t, byte(msgOpen), kind)
+
+	o, err := decodeOpen(body)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(4200000001), o.asn)
+	assert.Equal(t, uint16(90), o.holdTime)
+	assert.Equal(t, "10.0.0.1", o.routerID.String())
+	assert.True(t, o.as4)
+}
+
+// IPv4 routes are announced as NLRI with a NEXT_HOP attribute, and IPv6 ones
+// through the MP_REACH_NLRI attribute.
+func TestUpdateMessages(t *testing.T) {
+	attrs := routeAttrs{asn: 65001, as4: true, nextHop4: net.ParseIP("192.0.2.1")}
+	msgs := updateMessages([]net.IPNet{parseCIDR(t, "10.1.0.0/16"), parseCIDR(t, "2001:db8::/64")}, nil, attrs)
+	require.Len(t, msgs, 1)
+
+	_, body, err := readMessage(&fakeReader{data: msgs[0]})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0, 0, // No withdrawn routes
+		0, 20, // Path attributes length
+		0x40, attrOrigin, 1, 0,
+		0x40, attrASPath, 6, 2, 1, 0, 0, 0xfd, 0xe9,
+		0x40, attrNextHop, 4, 192, 0, 2, 1,
+		16, 10, 1, // 10.1.0.0/16
+	}, body)
+
+	msgs = updateMessages(nil, []net.IPNet{parseCIDR(t, "10.1.0.0/16"), parseCIDR(t, "2001:db8::/64")}, attrs)
+	require.Len(t, msgs, 2)
+
+	_, body, err = readMessage(&fakeReader{data: msgs[1]})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0, 0,
+		0, 16,
+		0x90, attrMPUnreach, 0, 12, 0, afiIPv6, safiUnicast, 64, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0,
+	}, body)
+}
+
+// Prefixes are advertised to peers once the session is established, and
+// changes are sent as they happen.
+func TestServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	_, peerPort, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	port = peerPort
+
+	server := NewServer()
+	defer server.Stop()
+
+	server.SetPrefixes("lxdbr0", []net.IPNet{parseCIDR(t, "10.1.0.0/16")})
+	err = server.Configure(65001, net.ParseIP("10.0.0.1"), []Peer{{Address: net.ParseIP("127.0.0.1"), ASN: 65002}})
+	require.NoError(t, err)
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	body, err := expectMessage(conn, msgOpen)
+	require.NoError(t, err)
+
+	o, err := decodeOpen(body)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(65001), o.asn)
+
+	_, err = conn.Write(encodeOpen(open{asn: 65002, holdTime: 30, routerID: net.ParseIP("10.0.0.2")}))
+	require.NoError(t, err)
+
+	_, err = expectMessage(conn, msgKeepalive)
+	require.NoError(t, err)
+
+	_, err = conn.Write(encodeKeepalive())
+	require.NoError(t, err)
+
+	// Full table
+	body, err = expectMessage(conn, msgUpdate)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{16, 10, 1}, body[len(body)-3:])
+
+	// Withdrawal
+	server.SetPrefixes("lxdbr0", nil)
+	body, err = expectMessage(conn, msgUpdate)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 3, 16, 10, 1, 0, 0}, body)
+}
+
+type fakeReader struct {
+	data []byte
+}
+
+func (r *fakeReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
@@ -0,0 +1,334 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Types of BGP messages.
+const (
+	msgOpen         = 1
+	msgUpdate       = 2
+	msgNotification = 3
+	msgKeepalive    = 4
+)
+
+// Types of path attributes.
+const (
+	attrOrigin    = 1
+	attrASPath    = 2
+	attrNextHop   = 3
+	attrLocalPref = 5
+	attrMPReach   = 14
+	attrMPUnreach = 15
+)
+
+// Flags of path attributes.
+const (
+	attrFlagOpt    = 0x80
+	attrFlagTrans  = 0x40
+	attrFlagExtLen = 0x10
+)
+
+const (
+	asTrans         = 23456 // Stands for 4-octet AS numbers with older peers
+	holdTime        = 90    // Proposed hold time, in seconds
+	headerLength    = 19
+	maxMessageSize  = 4096
+	maxUpdatePrefix = 200 // Prefixes per UPDATE message, within its size limit
+)
+
+// Capabilities advertised in the OPEN message.
+const (
+	capMultiprotocol = 1
+	capAS4           = 65
+)
+
+// Address families.
+const (
+	afiIPv4     = 1
+	afiIPv6     = 2
+	safiUnicast = 1
+)
+
+// Frame a message of the given type.
+func message(kind byte, body []byte) []byte {
+	msg := make([]byte, headerLength, headerLength+len(body))
+	for i := 0; i < 16; i++ {
+		msg[i] = 0xff
+	}
+	binary.BigEndian.PutUint16(msg[16:], uint16(headerLength+len(body)))
+	msg[18] = kind
+
+	return append(msg, body...)
+}
+
+// Read a message, returning its type and body.
+func readMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, headerLength)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for i := 0; i < 16; i++ {
+		if header[i] != 0xff {
+			return 0, nil, fmt.Errorf("Invalid message marker")
+		}
+	}
+
+	length := int(binary.BigEndian.Uint16(header[16:]))
+	if length < headerLength || length > maxMessageSize {
+		return 0, nil, fmt.Errorf("Invalid message length %d", length)
+	}
+
+	body := make([]byte, length-headerLength)
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return header[18], body, nil
+}
+
+// Parameters exchanged in OPEN messages.
+type open struct {
+	asn      uint32
+	holdTime uint16
+	routerID net.IP
+	as4      bool // Whether 4-octet AS numbers are supported
+}
+
+func encodeOpen(o open) []byte {
+	asn := uint16(asTrans)
+	if o.asn <= 0xffff {
+		asn = uint16(o.asn)
+	}
+
+	// Capabilities: IPv4 and IPv6 unicast, 4-octet AS numbers
+	caps := []byte{
+		capMultiprotocol, 4, 0, afiIPv4, 0, safiUnicast,
+		capMultiprotocol, 4, 0, afiIPv6, 0, safiUnicast,
+		capAS4, 4, 0, 0, 0, 0,
+	}
+	binary.BigEndian.PutUint32(caps[14:], o.asn)
+
+	body := make([]byte, 10)
+	body[0] = 4
+	binary.BigEndian.PutUint16(body[1:], asn)
+	binary.BigEndian.PutUint16(body[3:], o.holdTime)
+	copy(body[5:9], o.routerID.To4())
+	body[9] = byte(2 + len(caps))
+	body = append(body, 2, byte(len(caps)))
+	body = append(body, caps...)
+
+	return message(msgOpen, body)
+}
+
+func decodeOpen(body []byte) (*open, error) {
+	if len(body) < 10 {
+		return nil, fmt.Errorf("Truncated OPEN message")
+	}
+
+	if body[0] != 4 {
+		return nil, fmt.Errorf("Unsupported BGP version %d", body[0])
+	}
+
+	o := &open{
+		asn:      uint32(binary.BigEndian.Uint16(body[1:])),
+		holdTime: binary.BigEndian.Uint16(body[3:]),
+		routerID: net.IP(append([]byte{}, body[5:9]...)),
+	}
+
+	params := body[10:]
+	if len(params) < int(body[9]) {
+		return nil, fmt.Errorf("Truncated OPEN message")
+	}
+	params = params[:body[9]]
+
+	for len(params) >= 2 {
+		kind, length := params[0], int(params[1])
+		if len(params) < 2+length {
+			return nil, fmt.Errorf("Truncated OPEN parameter")
+		}
+		value := params[2 : 2+length]
+		params = params[2+length:]
+
+		// Only capabilities are of interest
+		if kind != 2 {
+			continue
+		}
+
+		for len(value) >= 2 {
+			code, capLength := value[0], int(value[1])
+			if len(value) < 2+capLength {
+				return nil, fmt.Errorf("Truncated OPEN capability")
+			}
+
+			if code == capAS4 && capLength == 4 {
+				o.as4 = true
+				o.asn = binary.BigEndian.Uint32(value[2:])
+			}
+			value = value[2+capLength:]
+		}
+	}
+
+	return o, nil
+}
+
+func encodeNotification(code byte, subcode byte) []byte {
+	return message(msgNotification, []byte{code, subcode})
+}
+
+func encodeKeepalive() []byte {
+	return message(msgKeepalive, nil)
+}
+
+// Attributes of the routes advertised to a peer.
+type routeAttrs struct {
+	asn      uint32
+	ibgp     bool
+	as4      bool
+	nextHop4 net.IP
+	nextHop6 net.IP
+}
+
+// Encode a path attribute.
+func attr(flags byte, kind byte, value []byte) []byte {
+	if len(value) > 0xff {
+		flags |= attrFlagExtLen
+	}
+
+	b := []byte{flags, kind}
+	if flags&attrFlagExtLen != 0 {
+		b = append(b, byte(len(value)>>8), byte(len(value)))
+	} else {
+		b = append(b, byte(len(value)))
+	}
+
+	return append(b, value...)
+}
+
+// Encode prefixes in their length and significant bytes form.
+func encodePrefixes(prefixes []net.IPNet) []byte {
+	b := []byte{}
+	for _, prefix := range prefixes {
+		ones, _ := prefix.Mask.Size()
+		ip := prefix.IP.To4()
+		if ip == nil {
+			ip = prefix.IP.To16()
+		}
+
+		b = append(b, byte(ones))
+		b = append(b, ip[:(ones+7)/8]...)
+	}
+
+	return b
+}
+
+// Encode the attributes shared by the announcements of both families.
+func (a routeAttrs) common() []byte {
+	b := attr(attrFlagTrans, attrOrigin, []byte{0})
+
+	// Routes are only prepended with our own AS for external peers
+	path := []byte{}
+	if !a.ibgp {
+		if a.as4 {
+			path = []byte{2, 1, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(path[2:], a.asn)
+		} else {
+			asn := uint16(asTrans)
+			if a.asn <= 0xffff {
+				asn = uint16(a.asn)
+			}
+			path = []byte{2, 1, 0, 0}
+			binary.BigEndian.PutUint16(path[2:], asn)
+		}
+	}
+	b = append(b, attr(attrFlagTrans, attrASPath, path)...)
+
+	if a.ibgp {
+		b = append(b, attr(attrFlagTrans, attrLocalPref, []byte{0, 0, 0, 100})...)
+	}
+
+	return b
+}
+
+// Build an UPDATE message out of its withdrawn routes, path attributes and
+// announced IPv4 routes.
+func encodeUpdate(withdrawn []byte, attrs []byte, nlri []byte) []byte {
+	body := []byte{byte(len(withdrawn) >> 8), byte(len(withdrawn))}
+	body = append(body, withdrawn...)
+	body = append(body, byte(len(attrs)>>8), byte(len(attrs)))
+	body = append(body, attrs...)
+	body = append(body, nlri...)
+
+	return message(msgUpdate, body)
+}
+
+// Build the UPDATE messages announcing and withdrawing the given prefixes.
+// Prefixes of a family without next hop aren't announced.
+func updateMessages(announce []net.IPNet, withdraw []net.IPNet, attrs routeAttrs) [][]byte {
+	split := func(prefixes []net.IPNet) ([]net.IPNet, []net.IPNet) {
+		v4 := []net.IPNet{}
+		v6 := []net.IPNet{}
+		for _, prefix := range prefixes {
+			if prefix.IP.To4() != nil {
+				v4 = append(v4, prefix)
+			} else {
+				v6 = append(v6, prefix)
+			}
+		}
+		return v4, v6
+	}
+
+	chunks := func(prefixes []net.IPNet) [][]net.IPNet {
+		result := [][]net.IPNet{}
+		for len(prefixes) > maxUpdatePrefix {
+			result = append(result, prefixes[:maxUpdatePrefix])
+			prefixes = prefixes[maxUpdatePrefix:]
+		}
+		if len(prefixes) > 0 {
+			result = append(result, prefixes)
+		}
+		return result
+	}
+
+	announce4, announce6 := split(announce)
+	withdraw4, withdraw6 := split(withdraw)
+
+	msgs := [][]byte{}
+	for _, chunk := range chunks(withdraw4) {
+		msgs = append(msgs, encodeUpdate(encodePrefixes(chunk), nil, nil))
+	}
+
+	for _, chunk := range chunks(withdraw6) {
+		value := append([]byte{0, afiIPv6, safiUnicast}, encodePrefixes(chunk)...)
+		msgs = append(msgs, encodeUpdate(nil, attr(attrFlagOpt|attrFlagExtLen, attrMPUnreach, value), nil))
+	}
+
+	if attrs.nextHop4 != nil {
+		for _, chunk := range chunks(announce4) {
+			b := attrs.common()
+			b = append(b, attr(attrFlagTrans, attrNextHop, attrs.nextHop4.To4())...)
+			msgs = append(msgs, encodeUpdate(nil, b, encodePrefixes(chunk)))
+		}
+	}
+
+	if attrs.nextHop6 != nil {
+		for _, chunk := range chunks(announce6) {
+			value := []byte{0, afiIPv6, safiUnicast, 16}
+			value = append(value, attrs.nextHop6.To16()...)
+			value = append(value, 0)
+			value = append(value, encodePrefixes(chunk)...)
+
+			b := attrs.common()
+			b = append(b, attr(attrFlagOpt|attrFlagExtLen, attrMPReach, value)...)
+			msgs = append(msgs, encodeUpdate(nil, b, nil))
+		}
+	}
+
+	return msgs
+}
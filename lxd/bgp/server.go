@@ -0,0 +1,156 @@
+package bgp
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+)
+
+// Peer is a BGP router to advertise routes to.
+type Peer struct {
+	Address net.IP
+	ASN     uint32
+}
+
+// Server is a minimal BGP speaker. It connects to its peers and advertises
+// prefixes to them, ignoring the routes they advertise in return.
+type Server struct {
+	mu       sync.Mutex
+	asn      uint32
+	routerID net.IP
+	sessions map[string]*session    // Sessions by peer address
+	prefixes map[string][]net.IPNet // Advertised prefixes by owner
+}
+
+// NewServer returns a new BGP speaker, which doesn't have any peer until it's
+// configured.
+func NewServer() *Server {
+	return &Server{
+		sessions: map[string]*session{},
+		prefixes: map[string][]net.IPNet{},
+	}
+}
+
+// Configure sets the AS number and router ID of the speaker, along with its
+// peers. Sessions are restarted if the AS number or router ID changed, and
+// an AS number of zero stops all of them.
+func (s *Server) Configure(asn uint32, routerID net.IP, peers []Peer) error {
+	if asn != 0 && routerID.To4() == nil {
+		return fmt.Errorf("The BGP router ID must be an IPv4 address")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restart := asn != s.asn || !routerID.Equal(s.routerID)
+	s.asn = asn
+	s.routerID = routerID
+
+	wanted := map[string]Peer{}
+	if asn != 0 {
+		for _, peer := range peers {
+			wanted[peer.Address.String()] = peer
+		}
+	}
+
+	for address, session := range s.sessions {
+		peer, ok := wanted[address]
+		if ok && !restart && peer.ASN == session.peer.ASN {
+			delete(wanted, address)
+			continue
+		}
+
+		session.stop()
+		delete(s.sessions, address)
+	}
+
+	for address, peer := range wanted {
+		session := newSession(s, peer)
+		s.sessions[address] = session
+		go session.run()
+	}
+
+	return nil
+}
+
+// SetPrefixes replaces the prefixes advertised on behalf of the given owner,
+// sending the changes to the established sessions.
+func (s *Server) SetPrefixes(owner string, prefixes []net.IPNet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := s.advertised()
+	if len(prefixes) == 0 {
+		delete(s.prefixes, owner)
+	} else {
+		s.prefixes[owner] = prefixes
+	}
+	after := s.advertised()
+
+	announce := []net.IPNet{}
+	for key, prefix := range after {
+		_, ok := before[key]
+		if !ok {
+			announce = append(announce, prefix)
+		}
+	}
+
+	withdraw := []net.IPNet{}
+	for key, prefix := range before {
+		_, ok := after[key]
+		if !ok {
+			withdraw = append(withdraw, prefix)
+		}
+	}
+
+	if len(announce) == 0 && len(withdraw) == 0 {
+		return
+	}
+
+	for _, session := range s.sessions {
+		session.update(announce, withdraw)
+	}
+}
+
+// Stop closes all sessions.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for address, session := range s.sessions {
+		session.stop()
+		delete(s.sessions, address)
+	}
+}
+
+// Return the prefixes advertised by any owner, by their string form. Must be
+// called with the lock held.
+func (s *Server) advertised() map[string]net.IPNet {
+	prefixes := map[string]net.IPNet{}
+	for _, owned := range s.prefixes {
+		for _, prefix := range owned {
+			prefixes[prefix.String()] = prefix
+		}
+	}
+
+	return prefixes
+}
+
+// Return the list of advertised prefixes, in a stable order. Must be called
+// with the lock held.
+func (s *Server) advertisedList() []net.IPNet {
+	prefixes := s.advertised()
+	keys := []string{}
+	for key := range prefixes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := []net.IPNet{}
+	for _, key := range keys {
+		list = append(list, prefixes[key])
+	}
+
+	return list
+}
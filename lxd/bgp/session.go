@@ -0,0 +1,256 @@
+package bgp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Port of the peers, and delay before reconnecting to them after a failure.
+var port = "179"
+var retryInterval = 30 * time.Second
+
+// Timeout of the session establishment and of each write.
+const timeout = 10 * time.Second
+
+// A session with a peer, which is actively connected to and reconnected to
+// until the session is stopped.
+type session struct {
+	server *Server
+	peer   Peer
+	done   chan struct{}
+
+	mu    sync.Mutex
+	conn  net.Conn   // Set while the session is established
+	attrs routeAttrs // Attributes of the routes sent to the peer
+}
+
+func newSession(server *Server, peer Peer) *session {
+	return &session{
+		server: server,
+		peer:   peer,
+		done:   make(chan struct{}),
+	}
+}
+
+func (s *session) run() {
+	for {
+		err := s.connect()
+		if err != nil {
+			logger.Warn("BGP session failed", log.Ctx{"peer": s.peer.Address.String(), "err": err})
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (s *session) stop() {
+	close(s.done)
+}
+
+// Connect to the peer and keep the session up until it fails or is stopped.
+func (s *session) connect() error {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(s.peer.Address.String(), port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Close the connection as soon as the session is stopped
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-s.done:
+			conn.Close()
+		case <-closed:
+		}
+	}()
+
+	s.server.mu.Lock()
+	asn := s.server.asn
+	routerID := s.server.routerID
+	s.server.mu.Unlock()
+
+	// Exchange OPEN and KEEPALIVE messages
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	_, err = conn.Write(encodeOpen(open{asn: asn, holdTime: holdTime, routerID: routerID}))
+	if err != nil {
+		return err
+	}
+
+	body, err := expectMessage(conn, msgOpen)
+	if err != nil {
+		return err
+	}
+
+	o, err := decodeOpen(body)
+	if err != nil {
+		conn.Write(encodeNotification(2, 0))
+		return err
+	}
+
+	if o.asn != s.peer.ASN {
+		conn.Write(encodeNotification(2, 2))
+		return fmt.Errorf("Unexpected peer AS %d", o.asn)
+	}
+
+	hold := o.holdTime
+	if hold > holdTime {
+		hold = holdTime
+	}
+
+	if hold == 1 || hold == 2 {
+		conn.Write(encodeNotification(2, 6))
+		return fmt.Errorf("Unacceptable hold time %d", hold)
+	}
+
+	_, err = conn.Write(encodeKeepalive())
+	if err != nil {
+		return err
+	}
+
+	_, err = expectMessage(conn, msgKeepalive)
+	if err != nil {
+		return err
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	// Routes are advertised with the local address of the session as next
+	// hop, for the prefixes of its family.
+	attrs := routeAttrs{asn: asn, ibgp: asn == s.peer.ASN, as4: o.as4}
+	local := conn.LocalAddr().(*net.TCPAddr).IP
+	if local.To4() != nil {
+		attrs.nextHop4 = local
+	} else {
+		attrs.nextHop6 = local
+	}
+
+	// Send the full table, without missing concurrent changes
+	s.server.mu.Lock()
+	s.mu.Lock()
+	s.conn = conn
+	s.attrs = attrs
+	err = s.send(updateMessages(s.server.advertisedList(), nil, attrs))
+	s.mu.Unlock()
+	s.server.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	if err != nil {
+		return err
+	}
+
+	logger.Info("BGP session established", log.Ctx{"peer": s.peer.Address.String()})
+
+	if hold > 0 {
+		go s.keepalive(conn, time.Duration(hold)*time.Second/3, closed)
+	}
+
+	// Messages of the peer only extend the hold timer
+	for {
+		if hold > 0 {
+			conn.SetReadDeadline(time.Now().Add(time.Duration(hold) * time.Second))
+		}
+
+		kind, body, err := readMessage(conn)
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+			}
+
+			return err
+		}
+
+		if kind == msgNotification && len(body) >= 2 {
+			return fmt.Errorf("Notification from peer with code %d/%d", body[0], body[1])
+		}
+	}
+}
+
+// Send KEEPALIVE messages at the given interval, until the session ends.
+func (s *session) keepalive(conn net.Conn, interval time.Duration, closed chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		err := s.send([][]byte{encodeKeepalive()})
+		s.mu.Unlock()
+		if err != nil {
+			conn.Close()
+			return
+		}
+	}
+}
+
+// Send changes of the advertised prefixes, if the session is established.
+func (s *session) update(announce []net.IPNet, withdraw []net.IPNet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return
+	}
+
+	err := s.send(updateMessages(announce, withdraw, s.attrs))
+	if err != nil {
+		// The session gets re-established from scratch
+		s.conn.Close()
+	}
+}
+
+// Write messages to the established connection. Must be called with the lock
+// held.
+func (s *session) send(msgs [][]byte) error {
+	for _, msg := range msgs {
+		s.conn.SetWriteDeadline(time.Now().Add(timeout))
+		_, err := s.conn.Write(msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read a message of the given type, failing on any other.
+func expectMessage(conn net.Conn, kind byte) ([]byte, error) {
+	got, body, err := readMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if got == msgNotification && len(body) >= 2 {
+		return nil, fmt.Errorf("Notification from peer with code %d/%d", body[0], body[1])
+	}
+
+	if got != kind {
+		return nil, fmt.Errorf("Unexpected message of type %d", got)
+	}
+
+	return body, nil
+}
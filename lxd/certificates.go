@@ -147,6 +147,12 @@ func certificatesPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Can't use TLS data on non-TLS link"))
 	}
 
+	// Expired certificates would never be trusted anyway
+	err = trustCertificateValid(cert)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	fingerprint := shared.CertFingerprint(cert)
 
 	if !isClusterNotification(r) {
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Client certificates listed in the ca.crl revocation list of the var
+// directory aren't trusted, even if they are in the trust store. The list is
+// reloaded whenever the file changes.
+var trustCRLLock sync.Mutex
+var trustCRL *pkix.CertificateList
+var trustCRLModTime time.Time
+
+// Return the current revocation list, if any.
+func trustCRLLoad() (*pkix.CertificateList, error) {
+	trustCRLLock.Lock()
+	defer trustCRLLock.Unlock()
+
+	path := shared.VarPath("ca.crl")
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			trustCRL = nil
+			trustCRLModTime = time.Time{}
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if trustCRL != nil && fi.ModTime().Equal(trustCRLModTime) {
+		return trustCRL, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both PEM and DER encodings are accepted
+	crl, err := x509.ParseCRL(content)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid certificate revocation list %s: %v", path, err)
+	}
+
+	trustCRL = crl
+	trustCRLModTime = fi.ModTime()
+
+	return crl, nil
+}
+
+// Check whether the given certificate was revoked. If the revocation list
+// can't be loaded, all certificates are considered revoked.
+func trustCertificateRevoked(cert *x509.Certificate) bool {
+	crl, err := trustCRLLoad()
+	if err != nil {
+		logger.Error("Failed to load the certificate revocation list", log.Ctx{"err": err})
+		return true
+	}
+
+	if crl == nil {
+		return false
+	}
+
+	issuer := pkix.Name{}
+	issuer.FillFromRDNSequence(&crl.TBSCertList.Issuer)
+	if issuer.String() != cert.Issuer.String() {
+		return false
+	}
+
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check whether the validity period of a certificate covers the current time.
+func trustCertificateValid(cert *x509.Certificate) error {
+	now := time.Now()
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("Certificate isn't valid until %s", cert.NotBefore.UTC().Format(time.RFC3339))
+	}
+
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("Certificate expired on %s", cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func certificatesExpiryTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		certificatesCheckExpiry(d)
+	}
+
+	return f, task.Daily()
+}
+
+// Warn about the trusted client certificates and the server certificate
+// which expire within core.cert_expiry_warning days, as well as about
+// an outdated revocation list.
+func certificatesCheckExpiry(d *Daemon) {
+	days, err := cluster.ConfigGetInt64(d.cluster, "core.cert_expiry_warning")
+	if err != nil {
+		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+		return
+	}
+
+	// A zero value disables the warnings
+	if days <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+
+	warn := func(kind string, name string, url string, cert *x509.Certificate) {
+		fingerprint := shared.CertFingerprint(cert)
+		if cert.NotAfter.After(deadline) {
+			warningResolve(d.State(), "certificate-expiry", fingerprint)
+			return
+		}
+
+		logger.Warn("Certificate is about to expire", log.Ctx{"type": kind, "name": name, "fingerprint": fingerprint, "expiry": cert.NotAfter})
+		eventSendLifecycle("certificate-expiring", url,
+			map[string]interface{}{"type": kind, "name": name, "expires_at": cert.NotAfter})
+		warningEmit(d.State(), "certificate-expiry", fingerprint,
+			fmt.Sprintf("The %s certificate %s expires on %s", kind, fingerprint[:12], cert.NotAfter.UTC().Format(time.RFC3339)))
+	}
+
+	certs, err := d.cluster.CertificatesGet()
+	if err != nil {
+		logger.Error("Unable to retrieve the trusted certificates", log.Ctx{"err": err})
+		return
+	}
+
+	for _, dbCert := range certs {
+		certBlock, _ := pem.Decode([]byte(dbCert.Certificate))
+		if certBlock == nil {
+			logger.Error("Failed to decode trusted certificate", log.Ctx{"name": dbCert.Name})
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(certBlock.Bytes)
+		if err != nil {
+			logger.Error("Failed to parse trusted certificate", log.Ctx{"name": dbCert.Name, "err": err})
+			continue
+		}
+
+		warn("client", dbCert.Name, fmt.Sprintf("/%s/certificates/%s", version.APIVersion, dbCert.Fingerprint), cert)
+	}
+
+	cert, err := x509.ParseCertificate(d.endpoints.NetworkCert().KeyPair().Certificate[0])
+	if err != nil {
+		logger.Error("Failed to parse the server certificate", log.Ctx{"err": err})
+		return
+	}
+
+	if shared.PathExists(shared.VarPath("cluster.crt")) {
+		warn("cluster", "", fmt.Sprintf("/%s/cluster/certificate", version.APIVersion), cert)
+	} else {
+		warn("server", "", fmt.Sprintf("/%s", version.APIVersion), cert)
+	}
+
+	crl, err := trustCRLLoad()
+	if err != nil {
+		logger.Error("Failed to load the certificate revocation list", log.Ctx{"err": err})
+		return
+	}
+
+	if crl != nil && !crl.TBSCertList.NextUpdate.IsZero() && crl.TBSCertList.NextUpdate.Before(time.Now()) {
+		logger.Warn("Certificate revocation list is outdated", log.Ctx{"next_update": crl.TBSCertList.NextUpdate})
+		warningEmit(d.State(), "certificate-revocation-list", "ca.crl",
+			fmt.Sprintf("The certificate revocation list was due for an update on %s", crl.TBSCertList.NextUpdate.UTC().Format(time.RFC3339)))
+	} else {
+		warningResolve(d.State(), "certificate-revocation-list", "ca.crl")
+	}
+}
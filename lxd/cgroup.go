@@ -6,6 +6,8 @@ import (
 	"os"
 	"path"
 	"strings"
+
+	"github.com/lxc/lxd/shared"
 )
 
 func getInitCgroupPath(controller string) string {
@@ -42,9 +44,19 @@ func getInitCgroupPath(controller string) string {
 	return "/"
 }
 
-func cGroupGet(controller, cgroup, file string) (string, error) {
+// Get the path of a CGroup file of the host. The unified hierarchy has no
+// per-controller tree, so the controller is ignored there.
+func cGroupPath(controller, cgroup, file string) string {
+	if shared.PathExists("/sys/fs/cgroup/cgroup.controllers") {
+		return path.Join("/sys/fs/cgroup", cgroup, file)
+	}
+
 	initPath := getInitCgroupPath(controller)
-	path := path.Join("/sys/fs/cgroup", controller, initPath, cgroup, file)
+	return path.Join("/sys/fs/cgroup", controller, initPath, cgroup, file)
+}
+
+func cGroupGet(controller, cgroup, file string) (string, error) {
+	path := cGroupPath(controller, cgroup, file)
 
 	contents, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -54,8 +66,7 @@ func cGroupGet(controller, cgroup, file string) (string, error) {
 }
 
 func cGroupSet(controller, cgroup, file string, value string) error {
-	initPath := getInitCgroupPath(controller)
-	path := path.Join("/sys/fs/cgroup", controller, initPath, cgroup, file)
+	path := cGroupPath(controller, cgroup, file)
 
 	return ioutil.WriteFile(path, []byte(value), 0755)
 }
@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A ComplianceRule flags a container whose local configuration overrides
+// the value inherited from its profiles.
+//
+// The key may end with "*" to match all keys sharing the given prefix, and
+// the special key "devices" matches local devices shadowing a profile device
+// of the same name. If value is set, only local overrides to that exact
+// value are flagged.
+type ComplianceRule struct {
+	Key   string
+	Value string
+}
+
+// Matches returns true if the rule flags the given key set to the given value.
+func (r ComplianceRule) Matches(key string, value string) bool {
+	if strings.HasSuffix(r.Key, "*") {
+		if !strings.HasPrefix(key, strings.TrimSuffix(r.Key, "*")) {
+			return false
+		}
+	} else if r.Key != key {
+		return false
+	}
+
+	return r.Value == "" || r.Value == value
+}
+
+// CompliancePolicyParse parses the comma separated list of rules stored in
+// core.compliance_policy.
+func CompliancePolicyParse(policy string) ([]ComplianceRule, error) {
+	rules := []ComplianceRule{}
+
+	for _, entry := range strings.Split(policy, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.SplitN(entry, "=", 2)
+		rule := ComplianceRule{Key: strings.TrimSpace(fields[0])}
+		if len(fields) == 2 {
+			rule.Value = strings.TrimSpace(fields[1])
+		}
+
+		if rule.Key == "" {
+			return nil, fmt.Errorf("Invalid compliance rule: %s", entry)
+		}
+
+		if strings.Contains(strings.TrimSuffix(rule.Key, "*"), "*") {
+			return nil, fmt.Errorf("Wildcards are only supported at the end of a key: %s", entry)
+		}
+
+		if rule.Key == "devices" && rule.Value != "" {
+			return nil, fmt.Errorf("The devices rule doesn't take a value: %s", entry)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
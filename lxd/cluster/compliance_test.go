@@ -0,0 +1,38 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Rules are parsed from a comma separated list of keys with optional values.
+func TestCompliancePolicyParse(t *testing.T) {
+	rules, err := cluster.CompliancePolicyParse("security.privileged=true, raw.*,devices,")
+	require.NoError(t, err)
+
+	assert.Equal(t, []cluster.ComplianceRule{
+		{Key: "security.privileged", Value: "true"},
+		{Key: "raw.*"},
+		{Key: "devices"},
+	}, rules)
+
+	_, err = cluster.CompliancePolicyParse("security.*.privileged")
+	assert.EqualError(t, err, "Wildcards are only supported at the end of a key: security.*.privileged")
+
+	_, err = cluster.CompliancePolicyParse("=true")
+	assert.EqualError(t, err, "Invalid compliance rule: =true")
+
+	_, err = cluster.CompliancePolicyParse("devices=eth0")
+	assert.EqualError(t, err, "The devices rule doesn't take a value: devices=eth0")
+}
+
+// Keys are matched exactly or by prefix, along with the value if any.
+func TestComplianceRule_Matches(t *testing.T) {
+	assert.True(t, cluster.ComplianceRule{Key: "raw.*"}.Matches("raw.lxc", "x"))
+	assert.False(t, cluster.ComplianceRule{Key: "raw.*"}.Matches("limits.cpu", "2"))
+	assert.True(t, cluster.ComplianceRule{Key: "security.privileged", Value: "true"}.Matches("security.privileged", "true"))
+	assert.False(t, cluster.ComplianceRule{Key: "security.privileged", Value: "true"}.Matches("security.privileged", "false"))
+}
@@ -5,8 +5,12 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"os/exec"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/scrypt"
@@ -82,6 +86,18 @@ func (c *Config) RemoteCacheExpiry() int64 {
 	return c.m.GetInt64("images.remote_cache_expiry")
 }
 
+// ComplianceCheckInterval returns the configured interval between
+// configuration drift checks.
+func (c *Config) ComplianceCheckInterval() time.Duration {
+	n := c.m.GetInt64("core.compliance_check_interval")
+	return time.Duration(n) * time.Hour
+}
+
+// CompliancePolicy returns the configured list of compliance rules.
+func (c *Config) CompliancePolicy() ([]ComplianceRule, error) {
+	return CompliancePolicyParse(c.m.GetString("core.compliance_policy"))
+}
+
 // ProxyHTTPS returns the configured HTTPS proxy, if any.
 func (c *Config) ProxyHTTPS() string {
 	return c.m.GetString("core.proxy_https")
@@ -97,6 +113,79 @@ func (c *Config) ProxyIgnoreHosts() string {
 	return c.m.GetString("core.proxy_ignore_hosts")
 }
 
+// ImagesProxy returns the proxy to use for image downloads, if any.
+func (c *Config) ImagesProxy() string {
+	return c.m.GetString("images.proxy")
+}
+
+// ImagesMirrors returns the mirrors to download images from instead of their
+// source server, keyed by the URL of the source server.
+func (c *Config) ImagesMirrors() map[string]string {
+	mirrors := map[string]string{}
+	for _, rule := range strings.Split(c.m.GetString("images.mirrors"), ",") {
+		fields := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		source := strings.TrimSuffix(strings.TrimSpace(fields[0]), "/")
+		mirrors[source] = strings.TrimSuffix(strings.TrimSpace(fields[1]), "/")
+	}
+
+	return mirrors
+}
+
+// StorageExternalDrivers returns the paths of the registered external storage
+// drivers, keyed by driver name.
+func (c *Config) StorageExternalDrivers() map[string]string {
+	drivers := map[string]string{}
+	for _, path := range strings.Split(c.m.GetString("storage.external_drivers"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		drivers[filepath.Base(path)] = path
+	}
+
+	return drivers
+}
+
+// StorageShared returns the CephFS source of the filesystem shared by all
+// nodes for images and backups, if any, along with the name of the Ceph
+// cluster and of the user to mount it with.
+func (c *Config) StorageShared() (string, string, string) {
+	source := c.m.GetString("storage.shared_source")
+	clusterName := c.m.GetString("storage.shared_cluster_name")
+	userName := c.m.GetString("storage.shared_user_name")
+	return source, clusterName, userName
+}
+
+// BGPASN returns the AS number of the BGP speaker, zero if it's disabled.
+func (c *Config) BGPASN() uint32 {
+	return uint32(c.m.GetInt64("bgp.asn"))
+}
+
+// BGPPeers returns the AS numbers of the BGP peers, keyed by their address.
+func (c *Config) BGPPeers() map[string]uint32 {
+	peers := map[string]uint32{}
+	for _, peer := range strings.Split(c.m.GetString("bgp.peers"), ",") {
+		fields := strings.SplitN(strings.TrimSpace(peer), "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		asn, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		peers[strings.TrimSpace(fields[0])] = uint32(asn)
+	}
+
+	return peers
+}
+
 // MAASController the configured MAAS url and key, if any.
 func (c *Config) MAASController() (string, string) {
 	url := c.m.GetString("maas.api.url")
@@ -201,22 +290,46 @@ func configGet(cluster *db.Cluster) (*Config, error) {
 
 // ConfigSchema defines available server configuration keys.
 var ConfigSchema = config.Schema{
+	"bgp.asn":                        {Type: config.Int64, Default: "0", Validator: validateASN},
+	"bgp.peers":                      {Validator: validateBGPPeers},
 	"cluster.offline_threshold":      {Type: config.Int64, Default: offlineThresholdDefault(), Validator: offlineThresholdValidator},
+	"containers.trash_expiry":        {Type: config.Int64, Default: "0"},
+	"core.compliance_check_interval": {Type: config.Int64, Default: "0"},
+	"core.compliance_policy":         {Default: "security.privileged=true", Validator: validateCompliancePolicy},
 	"core.https_allowed_headers":     {},
 	"core.https_allowed_methods":     {},
 	"core.https_allowed_origin":      {},
 	"core.https_allowed_credentials": {Type: config.Bool},
+	"core.cert_expiry_warning":       {Type: config.Int64, Default: "30"},
 	"core.proxy_http":                {},
 	"core.proxy_https":               {},
 	"core.proxy_ignore_hosts":        {},
 	"core.trust_password":            {Hidden: true, Setter: passwordSetter},
 	"core.macaroon.endpoint":         {},
+	"core.usage_history_interval":    {Type: config.Int64, Default: "0"},
+	"core.usage_history_size":        {Type: config.Int64, Default: "60", Validator: usageHistorySizeValidator},
 	"images.auto_update_cached":      {Type: config.Bool, Default: "true"},
 	"images.auto_update_interval":    {Type: config.Int64, Default: "6"},
 	"images.compression_algorithm":   {Default: "gzip", Validator: validateCompression},
+	"images.mirrors":                 {Validator: validateImagesMirrors},
+	"images.proxy":                   {Validator: validateImagesProxy},
 	"images.remote_cache_expiry":     {Type: config.Int64, Default: "10"},
 	"maas.api.key":                   {},
 	"maas.api.url":                   {},
+	"migration.compression_level":    {Type: config.Int64, Default: "0", Validator: validateMigrationCompressionLevel},
+	"storage.external_drivers":       {Validator: validateStorageExternalDrivers},
+	"storage.shared_cluster_name":    {Default: "ceph"},
+	"storage.shared_source":          {Validator: validateStorageSharedSource},
+	"storage.shared_user_name":       {Default: "admin"},
+
+	// Balancing of containers across cluster nodes.
+	"cluster.balance.interval":  {Type: config.Int64, Default: "0"},
+	"cluster.balance.live":      {Type: config.Bool},
+	"cluster.balance.threshold": {Type: config.Int64, Default: "20", Validator: validateBalanceThreshold},
+
+	// Suspension of idle containers.
+	"containers.auto_suspend.idle_timeout": {Type: config.Int64, Default: "0"},
+	"containers.auto_suspend.mode":         {Default: "freeze", Validator: validateAutoSuspendMode},
 
 	// Keys deprecated since the implementation of the storage api.
 	"storage.lvm_fstype":           {Setter: deprecatedStorage, Default: "ext4"},
@@ -246,6 +359,62 @@ func offlineThresholdValidator(value string) error {
 	return nil
 }
 
+func usageHistorySizeValidator(value string) error {
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("usage history size is not a number")
+	}
+	if size < 1 || size > usageHistorySizeMax {
+		return fmt.Errorf("value must be between 1 and %d", usageHistorySizeMax)
+	}
+	return nil
+}
+
+// Maximum number of usage samples kept for each container, a day worth of
+// samples taken every minute.
+const usageHistorySizeMax = 1440
+
+func validateAutoSuspendMode(value string) error {
+	if value != "freeze" && value != "stop" {
+		return fmt.Errorf("value must be either 'freeze' or 'stop'")
+	}
+	return nil
+}
+
+func validateBalanceThreshold(value string) error {
+	threshold, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("balance threshold is not a number")
+	}
+	if threshold < 1 || threshold > 100 {
+		return fmt.Errorf("value must be between 1 and 100")
+	}
+	return nil
+}
+
+func validateStorageSharedSource(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(value, "cephfs:") || strings.SplitN(value[len("cephfs:"):], "/", 2)[0] == "" {
+		return fmt.Errorf("value must be of the form cephfs:<fs>/<path>")
+	}
+
+	return nil
+}
+
+func validateMigrationCompressionLevel(value string) error {
+	level, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("compression level is not a number")
+	}
+	if level < 0 || level > 19 {
+		return fmt.Errorf("value must be between 0 and 19")
+	}
+	return nil
+}
+
 func passwordSetter(value string) (string, error) {
 	// Nothing to do on unset
 	if value == "" {
@@ -279,6 +448,131 @@ func validateCompression(value string) error {
 	return err
 }
 
+func validateCompliancePolicy(value string) error {
+	_, err := CompliancePolicyParse(value)
+	return err
+}
+
+func validateStorageExternalDrivers(value string) error {
+	names := map[string]bool{}
+	for _, path := range strings.Split(value, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("driver path '%s' isn't absolute", path)
+		}
+
+		// Drivers are referred to by the name of their executable
+		name := filepath.Base(path)
+		if names[name] {
+			return fmt.Errorf("duplicate driver name '%s'", name)
+		}
+		names[name] = true
+	}
+
+	return nil
+}
+
+func validateImagesProxy(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	return validateHTTPURL(value)
+}
+
+func validateImagesMirrors(value string) error {
+	sources := map[string]bool{}
+	for _, rule := range strings.Split(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		fields := strings.SplitN(rule, "=", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("mirror rule '%s' isn't of the form <source>=<mirror>", rule)
+		}
+
+		source := strings.TrimSuffix(strings.TrimSpace(fields[0]), "/")
+		if sources[source] {
+			return fmt.Errorf("duplicate mirror of '%s'", source)
+		}
+		sources[source] = true
+
+		for _, field := range fields {
+			err := validateHTTPURL(strings.TrimSpace(field))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateASN(value string) error {
+	_, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("'%s' isn't a valid AS number", value)
+	}
+
+	return nil
+}
+
+func validateBGPPeers(value string) error {
+	addresses := map[string]bool{}
+	for _, peer := range strings.Split(value, ",") {
+		peer = strings.TrimSpace(peer)
+		if peer == "" {
+			continue
+		}
+
+		fields := strings.SplitN(peer, "=", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("peer '%s' isn't of the form <address>=<asn>", peer)
+		}
+
+		address := strings.TrimSpace(fields[0])
+		if net.ParseIP(address) == nil {
+			return fmt.Errorf("'%s' isn't an IP address", address)
+		}
+
+		if addresses[address] {
+			return fmt.Errorf("duplicate peer '%s'", address)
+		}
+		addresses[address] = true
+
+		asn := strings.TrimSpace(fields[1])
+		err := validateASN(asn)
+		if err != nil {
+			return err
+		}
+
+		if asn == "0" {
+			return fmt.Errorf("the AS number of peer '%s' can't be zero", address)
+		}
+	}
+
+	return nil
+}
+
+func validateHTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("'%s' isn't a HTTP or HTTPS URL", value)
+	}
+
+	return nil
+}
+
 func deprecatedStorage(value string) (string, error) {
 	if value == "" {
 		return "", nil
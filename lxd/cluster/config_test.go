@@ -64,6 +64,95 @@ func TestConfigLoad_OfflineThresholdValidator(t *testing.T) {
 
 }
 
+// The usage history size is bounded, since a buffer of that size is kept for
+// each container.
+func TestConfigLoad_UsageHistorySizeValidator(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	config, err := cluster.ConfigLoad(tx)
+	require.NoError(t, err)
+
+	_, err = config.Patch(map[string]interface{}{"core.usage_history_size": "0"})
+	require.EqualError(t, err, "cannot set 'core.usage_history_size' to '0': value must be between 1 and 1440")
+
+	_, err = config.Patch(map[string]interface{}{"core.usage_history_size": "100000000"})
+	require.EqualError(t, err, "cannot set 'core.usage_history_size' to '100000000': value must be between 1 and 1440")
+
+	_, err = config.Patch(map[string]interface{}{"core.usage_history_size": "1440"})
+	require.NoError(t, err)
+}
+
+// External storage drivers must be given as absolute paths with distinct
+// names.
+func TestConfigLoad_StorageExternalDriversValidator(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	config, err := cluster.ConfigLoad(tx)
+	require.NoError(t, err)
+
+	_, err = config.Patch(map[string]interface{}{"storage.external_drivers": "bin/nfs"})
+	require.EqualError(t, err, "cannot set 'storage.external_drivers' to 'bin/nfs': driver path 'bin/nfs' isn't absolute")
+
+	_, err = config.Patch(map[string]interface{}{"storage.external_drivers": "/usr/bin/nfs,/usr/local/bin/nfs"})
+	require.EqualError(t, err, "cannot set 'storage.external_drivers' to '/usr/bin/nfs,/usr/local/bin/nfs': duplicate driver name 'nfs'")
+
+	_, err = config.Patch(map[string]interface{}{"storage.external_drivers": "/usr/bin/nfs, /usr/bin/san"})
+	require.NoError(t, err)
+
+	drivers := map[string]string{"nfs": "/usr/bin/nfs", "san": "/usr/bin/san"}
+	assert.Equal(t, drivers, config.StorageExternalDrivers())
+}
+
+// Image mirrors must be given as <source>=<mirror> pairs of URLs.
+func TestConfigLoad_ImagesMirrorsValidator(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	config, err := cluster.ConfigLoad(tx)
+	require.NoError(t, err)
+
+	_, err = config.Patch(map[string]interface{}{"images.mirrors": "https://images.linuxcontainers.org"})
+	require.EqualError(t, err, "cannot set 'images.mirrors' to 'https://images.linuxcontainers.org': mirror rule 'https://images.linuxcontainers.org' isn't of the form <source>=<mirror>")
+
+	_, err = config.Patch(map[string]interface{}{"images.mirrors": "https://images.linuxcontainers.org=mirror"})
+	require.EqualError(t, err, "cannot set 'images.mirrors' to 'https://images.linuxcontainers.org=mirror': 'mirror' isn't a HTTP or HTTPS URL")
+
+	_, err = config.Patch(map[string]interface{}{"images.mirrors": "https://images.linuxcontainers.org/=https://mirror/images, https://cloud-images.ubuntu.com/releases=https://mirror/ubuntu/"})
+	require.NoError(t, err)
+
+	mirrors := map[string]string{
+		"https://images.linuxcontainers.org":       "https://mirror/images",
+		"https://cloud-images.ubuntu.com/releases": "https://mirror/ubuntu",
+	}
+	assert.Equal(t, mirrors, config.ImagesMirrors())
+}
+
+// BGP peers must be given as <address>=<asn> pairs.
+func TestConfigLoad_BGPPeersValidator(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	config, err := cluster.ConfigLoad(tx)
+	require.NoError(t, err)
+
+	_, err = config.Patch(map[string]interface{}{"bgp.peers": "10.0.0.1"})
+	require.EqualError(t, err, "cannot set 'bgp.peers' to '10.0.0.1': peer '10.0.0.1' isn't of the form <address>=<asn>")
+
+	_, err = config.Patch(map[string]interface{}{"bgp.peers": "router=65000"})
+	require.EqualError(t, err, "cannot set 'bgp.peers' to 'router=65000': 'router' isn't an IP address")
+
+	_, err = config.Patch(map[string]interface{}{"bgp.peers": "10.0.0.1=as65000"})
+	require.EqualError(t, err, "cannot set 'bgp.peers' to '10.0.0.1=as65000': 'as65000' isn't a valid AS number")
+
+	_, err = config.Patch(map[string]interface{}{"bgp.peers": "10.0.0.1=65000, 2001:db8::1=4200000000"})
+	require.NoError(t, err)
+
+	peers := map[string]uint32{"10.0.0.1": 65000, "2001:db8::1": 4200000000}
+	assert.Equal(t, peers, config.BGPPeers())
+}
+
 // If some previously set values are missing from the ones passed to Replace(),
 // they are deleted from the configuration.
 func TestConfig_ReplaceDeleteValues(t *testing.T) {
@@ -69,7 +69,7 @@ func eventsUpdateListeners(endpoints *endpoints.Endpoints, cluster *db.Cluster,
 		return // Either we're not clustered or this is a single-node cluster
 	}
 
-	address := endpoints.NetworkAddress()
+	address := endpoints.ClusterAddress()
 
 	ids := make([]int, len(nodes))
 	for i, node := range nodes {
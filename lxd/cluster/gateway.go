@@ -44,7 +44,7 @@ func NewGateway(db *db.Node, cert *shared.CertInfo, options ...Option) (*Gateway
 
 	gateway := &Gateway{
 		db:        db,
-		cert:      cert,
+		cert:      certCopy(cert),
 		options:   o,
 		ctx:       ctx,
 		cancel:    cancel,
@@ -306,10 +306,29 @@ func (g *Gateway) Reset(cert *shared.CertInfo) error {
 	if err != nil {
 		return err
 	}
-	g.cert = cert
+	g.cert = certCopy(cert)
 	return g.init()
 }
 
+// UpdateCert replaces the certificate used for raft and database
+// connections and for heartbeats, when rotating the cluster certificate.
+//
+// The certificate is updated in place, since the raft transport holds a
+// reference to it, and under lock, since it's read by concurrent TLS
+// handshakes.
+func (g *Gateway) UpdateCert(cert *shared.CertInfo) {
+	certMu.Lock()
+	defer certMu.Unlock()
+	*g.cert = *cert
+}
+
+// Return a private copy of the given certificate, so that rotating the one
+// held by the gateway doesn't affect the caller's.
+func certCopy(cert *shared.CertInfo) *shared.CertInfo {
+	c := *cert
+	return &c
+}
+
 // LeaderAddress returns the address of the current raft leader.
 func (g *Gateway) LeaderAddress() (string, error) {
 	// If we aren't clustered, return an error.
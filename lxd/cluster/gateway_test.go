@@ -54,6 +54,39 @@ func TestGateway_Single(t *testing.T) {
 	assert.EqualError(t, err, "node is not clustered")
 }
 
+// After the certificate is rotated, the handlers only accept the new one, and
+// the certificate object passed when creating the gateway is left alone.
+func TestGateway_UpdateCert(t *testing.T) {
+	db, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	cert := shared.TestingKeyPair()
+	gateway := newGateway(t, db, cert)
+	defer gateway.Shutdown()
+
+	newCert := shared.TestingAltKeyPair()
+	gateway.UpdateCert(newCert)
+
+	assert.Equal(t, shared.TestingKeyPair().Fingerprint(), cert.Fingerprint())
+
+	cases := map[*shared.CertInfo]int{
+		cert:    http.StatusForbidden,
+		newCert: http.StatusNotFound,
+	}
+	f := gateway.HandlerFuncs()["/protocol.SQL/Conn"]
+	for info, code := range cases {
+		c, err := x509.ParseCertificate(info.KeyPair().Certificate[0])
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		r := &http.Request{}
+		r.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{c},
+		}
+		f(w, r)
+		assert.Equal(t, code, w.Code)
+	}
+}
+
 // If there's a network address configured, we expose the gRPC endpoint with
 // an HTTP handler.
 func TestGateway_SingleWithNetworkAddress(t *testing.T) {
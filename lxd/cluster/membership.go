@@ -45,7 +45,7 @@ func Bootstrap(state *state.State, gateway *Gateway, name string) error {
 		if err != nil {
 			return errors.Wrap(err, "failed to fetch node configuration")
 		}
-		address = config.HTTPSAddress()
+		address = config.ClusterAddress()
 
 		// Make sure node-local database state is in order.
 		err = membershipCheckNodeStateForBootstrapOrJoin(tx, address)
@@ -225,7 +225,7 @@ func Join(state *state.State, gateway *Gateway, cert *shared.CertInfo, name stri
 		if err != nil {
 			return errors.Wrap(err, "failed to fetch node configuration")
 		}
-		address = config.HTTPSAddress()
+		address = config.ClusterAddress()
 
 		// Make sure node-local database state is in order.
 		err = membershipCheckNodeStateForBootstrapOrJoin(tx, address)
@@ -294,7 +294,7 @@ func Join(state *state.State, gateway *Gateway, cert *shared.CertInfo, name stri
 	// Re-initialize the gateway. This will create a new raft factory an
 	// dqlite driver instance, which will be exposed over gRPC by the
 	// gateway handlers.
-	gateway.cert = cert
+	gateway.cert = certCopy(cert)
 	err = gateway.init()
 	if err != nil {
 		return errors.Wrap(err, "failed to re-initialize gRPC SQL gateway")
@@ -751,6 +751,8 @@ func List(state *state.State) ([]api.ClusterMember, error) {
 		result[i].ServerName = node.Name
 		result[i].URL = fmt.Sprintf("https://%s", node.Address)
 		result[i].Database = shared.StringInSlice(node.Address, addresses)
+		result[i].Schema = node.Schema
+		result[i].APIExtensions = node.APIExtensions
 		if node.IsOffline(offlineThreshold) {
 			result[i].Status = "Offline"
 			result[i].Message = fmt.Sprintf(
@@ -325,11 +325,20 @@ func raftMemoryTransport() raft.Transport {
 // cluster (and optionally CA) certificate both as client and remote
 // certificate.
 func raftDial(cert *shared.CertInfo) (rafthttp.Dial, error) {
-	config, err := tlsClientConfig(cert)
+	_, err := tlsClientConfig(cert)
 	if err != nil {
 		return nil, err
 	}
-	dial := rafthttp.NewDialTLS(config)
+
+	// The TLS configuration is created for every connection, so that it
+	// follows a rotation of the cluster certificate.
+	dial := func(addr string, timeout time.Duration) (net.Conn, error) {
+		config, err := tlsClientConfig(cert)
+		if err != nil {
+			return nil, err
+		}
+		return rafthttp.NewDialTLS(config)(addr, timeout)
+	}
 	return dial, nil
 }
 
@@ -5,16 +5,27 @@ import (
 	"crypto/x509"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 )
 
+// Guards the certificate held by the gateway, which is shared with the raft
+// transport and replaced in place when the cluster certificate is rotated.
+var certMu sync.RWMutex
+
+// Return the key pair and CA of the given certificate, holding the lock.
+func certKeyPairAndCA(info *shared.CertInfo) (tls.Certificate, *x509.Certificate) {
+	certMu.RLock()
+	defer certMu.RUnlock()
+	return info.KeyPair(), info.CA()
+}
+
 // Return a TLS configuration suitable for establishing inter-node network
 // connections using the cluster certificate.
 func tlsClientConfig(info *shared.CertInfo) (*tls.Config, error) {
-	keypair := info.KeyPair()
-	ca := info.CA()
+	keypair, ca := certKeyPairAndCA(info)
 	config := shared.InitTLSConfig()
 	config.Certificates = []tls.Certificate{keypair}
 	config.RootCAs = x509.NewCertPool()
@@ -39,7 +50,8 @@ func tlsClientConfig(info *shared.CertInfo) (*tls.Config, error) {
 
 // Return true if the given request is presenting the given cluster certificate.
 func tlsCheckCert(r *http.Request, info *shared.CertInfo) bool {
-	cert, err := x509.ParseCertificate(info.KeyPair().Certificate[0])
+	keypair, _ := certKeyPairAndCA(info)
+	cert, err := x509.ParseCertificate(keypair.Certificate[0])
 	if err != nil {
 		// Since we have already loaded this certificate, typically
 		// using LoadX509KeyPair, an error should never happen, but
@@ -3,10 +3,14 @@ package cluster
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/pkg/errors"
 )
 
@@ -48,3 +52,52 @@ func NotifyUpgradeCompleted(state *state.State, cert *shared.CertInfo) error {
 		return nil
 	})
 }
+
+// UpgradeStatus returns the progress of a rolling upgrade of the cluster,
+// based on the schema and API versions each node recorded when it last
+// started. The upgrade is complete once all nodes run the most recent
+// version found, otherwise the nodes which are still behind are reported as
+// pending.
+func UpgradeStatus(tx *db.ClusterTx) (*api.ClusterUpgrade, error) {
+	nodes, err := tx.Nodes()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch nodes")
+	}
+
+	status := &api.ClusterUpgrade{
+		Status:  "Upgraded",
+		Message: "all members run the same version",
+		Pending: []string{},
+	}
+	if len(nodes) == 0 {
+		return status, nil
+	}
+
+	version := nodes[0].Version()
+	for _, node := range nodes[1:] {
+		n, err := util.CompareVersions(version, node.Version())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compare the version of node %s", node.Name)
+		}
+		if n == 2 {
+			version = node.Version()
+		}
+	}
+
+	status.Schema = version[0]
+	status.APIExtensions = version[1]
+
+	for _, node := range nodes {
+		if node.Version() != version {
+			status.Pending = append(status.Pending, node.Name)
+		}
+	}
+
+	if len(status.Pending) > 0 {
+		status.Status = "Upgrading"
+		status.Message = fmt.Sprintf(
+			"waiting for member %s to be upgraded", strings.Join(status.Pending, ", "))
+	}
+
+	return status, nil
+}
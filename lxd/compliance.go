@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// A complianceViolation describes a single local override matched by a
+// compliance rule.
+type complianceViolation struct {
+	Key     string
+	Local   string
+	Profile string
+}
+
+// Compare the local configuration and devices of a container with the ones it
+// inherits from its profiles and return the overrides flagged by the rules.
+func complianceCheck(rules []cluster.ComplianceRule, localConfig map[string]string, profileConfig map[string]string, localDevices types.Devices, profileDevices types.Devices) []complianceViolation {
+	violations := []complianceViolation{}
+
+	keys := []string{}
+	for k := range localConfig {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		// Keys which only exist locally can't be drift
+		if strings.HasPrefix(k, "volatile.") {
+			continue
+		}
+
+		local := localConfig[k]
+		profile := profileConfig[k]
+		if local == profile {
+			continue
+		}
+
+		for _, rule := range rules {
+			if rule.Key == "devices" || !rule.Matches(k, local) {
+				continue
+			}
+
+			violations = append(violations, complianceViolation{Key: k, Local: local, Profile: profile})
+			break
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.Key != "devices" {
+			continue
+		}
+
+		for _, name := range localDevices.DeviceNames() {
+			if profileDevices[name] == nil || profileDevices.Contains(name, localDevices[name]) {
+				continue
+			}
+
+			violations = append(violations, complianceViolation{
+				Key:     fmt.Sprintf("devices.%s", name),
+				Local:   localDevices[name]["type"],
+				Profile: profileDevices[name]["type"],
+			})
+		}
+
+		break
+	}
+
+	return violations
+}
+
+// Load the configuration and devices a container inherits from its profiles.
+func complianceProfileValues(s *db.Cluster, c container) (map[string]string, types.Devices, error) {
+	config := map[string]string{}
+	devices := types.Devices{}
+
+	for _, name := range c.Profiles() {
+		profileConfig, err := s.ProfileConfig(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for k, v := range profileConfig {
+			config[k] = v
+		}
+
+		profileDevices, err := s.Devices(name, true)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for k, v := range profileDevices {
+			devices[k] = v
+		}
+	}
+
+	return config, devices, nil
+}
+
+func complianceCheckTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		complianceCheckContainers(ctx, d)
+	}
+
+	schedule := func() (time.Duration, error) {
+		var interval time.Duration
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return errors.Wrap(err, "failed to load cluster configuration")
+			}
+			interval = config.ComplianceCheckInterval()
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		// A zero interval disables the task
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+func complianceCheckContainers(ctx context.Context, d *Daemon) {
+	var rules []cluster.ComplianceRule
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return errors.Wrap(err, "failed to load cluster configuration")
+		}
+		rules, err = config.CompliancePolicy()
+		return err
+	})
+	if err != nil {
+		logger.Error("Unable to fetch the compliance policy", log.Ctx{"err": err})
+		return
+	}
+
+	if len(rules) == 0 {
+		return
+	}
+
+	logger.Infof("Checking containers for configuration drift")
+
+	names, err := d.cluster.ContainersNodeList(db.CTypeRegular)
+	if err != nil {
+		logger.Error("Unable to retrieve the list of containers", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range names {
+		// At each iteration we check if we got cancelled in the
+		// meantime. Anything not checked now will be at the next run.
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		c, err := containerLoadByName(d.State(), name)
+		if err != nil {
+			logger.Error("Failed to load container", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		profileConfig, profileDevices, err := complianceProfileValues(d.cluster, c)
+		if err != nil {
+			logger.Error("Failed to load container profiles", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		violations := complianceCheck(rules, c.LocalConfig(), profileConfig, c.LocalDevices(), profileDevices)
+		for _, v := range violations {
+			logger.Warn("Container configuration overrides its profiles", log.Ctx{"container": name, "key": v.Key, "local": v.Local, "profile": v.Profile})
+
+			eventSendLifecycle("container-compliance-violation",
+				fmt.Sprintf("/1.0/containers/%s", name),
+				map[string]interface{}{
+					"key":     v.Key,
+					"local":   v.Local,
+					"profile": v.Profile,
+				})
+		}
+	}
+
+	logger.Infof("Done checking containers for configuration drift")
+}
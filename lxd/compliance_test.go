@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Only local overrides matching a rule are reported.
+func TestComplianceCheck(t *testing.T) {
+	rules, err := cluster.CompliancePolicyParse("security.privileged=true,raw.*,devices")
+	require.NoError(t, err)
+
+	localConfig := map[string]string{
+		"security.privileged": "true",
+		"security.nesting":    "true",
+		"raw.lxc":             "lxc.aa_profile=unconfined",
+		"limits.cpu":          "2",
+	}
+	profileConfig := map[string]string{
+		"raw.lxc":    "",
+		"limits.cpu": "2",
+	}
+	localDevices := types.Devices{
+		"eth0": {"type": "nic", "nictype": "macvlan", "parent": "eth0"},
+		"data": {"type": "disk", "source": "/srv", "path": "/srv"},
+	}
+	profileDevices := types.Devices{
+		"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+	}
+
+	violations := complianceCheck(rules, localConfig, profileConfig, localDevices, profileDevices)
+	assert.Equal(t, []complianceViolation{
+		{Key: "raw.lxc", Local: "lxc.aa_profile=unconfined"},
+		{Key: "security.privileged", Local: "true"},
+		{Key: "devices.eth0", Local: "nic", Profile: "nic"},
+	}, violations)
+}
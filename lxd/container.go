@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/lxc/go-lxc.v2"
@@ -16,14 +17,25 @@ import (
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/sys"
 	"github.com/lxc/lxd/lxd/types"
-	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/osarch"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
+// Devices which containers can require through the security.devices.* keys
+var containerSecurityDevices = []struct {
+	key    string
+	path   string
+	module string
+}{
+	{key: "security.devices.fuse", path: "/dev/fuse", module: "fuse"},
+	{key: "security.devices.tun", path: "/dev/net/tun", module: "tun"},
+}
+
 // Helper functions
 
 // Returns the parent container name, snapshot name, and whether it actually was
@@ -59,6 +71,32 @@ func containerValidName(name string) error {
 	return nil
 }
 
+// Replace the validators of the config keys whose values are parsed by the
+// daemon itself, so that the schema checks them fully.
+func init() {
+	validators := map[string]func(value string) error{
+		"raw.lxc": lxcValidConfig,
+		"boot.depends_on": func(value string) error {
+			_, err := containerDependenciesParse(value)
+			return err
+		},
+		"snapshots.retention": func(value string) error {
+			_, err := snapshotRetentionParse(value)
+			return err
+		},
+		"linux.lxcfs.files": func(value string) error {
+			_, err := lxcfsParseFiles(value)
+			return err
+		},
+	}
+
+	for key, validator := range validators {
+		schema := shared.ContainerConfigSchema[key]
+		schema.Validator = validator
+		shared.ContainerConfigSchema[key] = schema
+	}
+}
+
 func containerValidConfigKey(os *sys.OS, key string, value string) error {
 	f, err := shared.ConfigKeyChecker(key)
 	if err != nil {
@@ -67,9 +105,6 @@ func containerValidConfigKey(os *sys.OS, key string, value string) error {
 	if err = f(value); err != nil {
 		return err
 	}
-	if key == "raw.lxc" {
-		return lxcValidConfig(value)
-	}
 	if key == "security.syscalls.blacklist_compat" {
 		for _, arch := range os.Architectures {
 			if arch == osarch.ARCH_64BIT_INTEL_X86 ||
@@ -90,163 +125,12 @@ func containerValidDeviceConfigKey(t, k string) bool {
 		return true
 	}
 
-	switch t {
-	case "unix-char", "unix-block":
-		switch k {
-		case "gid":
-			return true
-		case "major":
-			return true
-		case "minor":
-			return true
-		case "mode":
-			return true
-		case "source":
-			return true
-		case "path":
-			return true
-		case "required":
-			return true
-		case "uid":
-			return true
-		default:
-			return false
-		}
-	case "nic":
-		switch k {
-		case "limits.max":
-			return true
-		case "limits.ingress":
-			return true
-		case "limits.egress":
-			return true
-		case "host_name":
-			return true
-		case "hwaddr":
-			return true
-		case "mtu":
-			return true
-		case "name":
-			return true
-		case "nictype":
-			return true
-		case "parent":
-			return true
-		case "vlan":
-			return true
-		case "ipv4.address":
-			return true
-		case "ipv6.address":
-			return true
-		case "security.mac_filtering":
-			return true
-		case "maas.subnet.ipv4":
-			return true
-		case "maas.subnet.ipv6":
-			return true
-		default:
-			return false
-		}
-	case "disk":
-		switch k {
-		case "limits.max":
-			return true
-		case "limits.read":
-			return true
-		case "limits.write":
-			return true
-		case "optional":
-			return true
-		case "path":
-			return true
-		case "readonly":
-			return true
-		case "size":
-			return true
-		case "source":
-			return true
-		case "recursive":
-			return true
-		case "pool":
-			return true
-		case "propagation":
-			return true
-		default:
-			return false
-		}
-	case "usb":
-		switch k {
-		case "vendorid":
-			return true
-		case "productid":
-			return true
-		case "mode":
-			return true
-		case "gid":
-			return true
-		case "uid":
-			return true
-		case "required":
-			return true
-		default:
-			return false
-		}
-	case "gpu":
-		switch k {
-		case "vendorid":
-			return true
-		case "productid":
-			return true
-		case "id":
-			return true
-		case "pci":
-			return true
-		case "mode":
-			return true
-		case "gid":
-			return true
-		case "uid":
-			return true
-		default:
-			return false
-		}
-	case "infiniband":
-		switch k {
-		case "hwaddr":
-			return true
-		case "mtu":
-			return true
-		case "name":
-			return true
-		case "nictype":
-			return true
-		case "parent":
-			return true
-		default:
-			return false
-		}
-	case "proxy":
-		switch k {
-		case "bind":
-			return true
-		case "connect":
-			return true
-		case "gid":
-			return true
-		case "listen":
-			return true
-		case "mode":
-			return true
-		case "uid":
-			return true
-		default:
-			return false
-		}
-	case "none":
-		return false
-	default:
+	driver, err := deviceDriverLoad(t)
+	if err != nil {
 		return false
 	}
+
+	return driver.ValidKey(k)
 }
 
 func allowedUnprivilegedOnlyMap(rawIdmap string) error {
@@ -298,6 +182,14 @@ func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool,
 		return fmt.Errorf("security.syscalls.whitelist is mutually exclusive with security.syscalls.blacklist*")
 	}
 
+	if expanded && shared.IsTrue(config["limits.cpu.isolated"]) && config["limits.cpu"] == "" {
+		return fmt.Errorf("limits.cpu.isolated requires limits.cpu to be set")
+	}
+
+	if expanded && shared.IsTrue(config["zfs.delegate"]) && !shared.IsTrue(config["security.privileged"]) {
+		return fmt.Errorf("zfs.delegate can only be used with privileged containers")
+	}
+
 	if expanded && (config["security.privileged"] == "" || !shared.IsTrue(config["security.privileged"])) && sysOS.IdmapSet == nil {
 		return fmt.Errorf("LXD doesn't have a uid/gid allocation. In this mode, only privileged containers are supported.")
 	}
@@ -319,6 +211,74 @@ func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool,
 	return nil
 }
 
+// What's needed to know about the managed networks nics are attached to,
+// cached as it's needed every time containers with such nics are loaded. The
+// entry of a network is dropped whenever it's changed on this node.
+type containerNetwork struct {
+	Type string
+	MTU  string
+}
+
+var containerNetworksLock sync.Mutex
+var containerNetworks = map[string]containerNetwork{}
+
+func containerNetworkGet(cluster *db.Cluster, name string) (containerNetwork, error) {
+	containerNetworksLock.Lock()
+	network, ok := containerNetworks[name]
+	containerNetworksLock.Unlock()
+	if ok {
+		return network, nil
+	}
+
+	_, info, err := cluster.NetworkGet(name)
+	if err != nil {
+		return containerNetwork{}, err
+	}
+
+	network = containerNetwork{Type: info.Type, MTU: info.Config["bridge.mtu"]}
+
+	containerNetworksLock.Lock()
+	containerNetworks[name] = network
+	containerNetworksLock.Unlock()
+
+	return network, nil
+}
+
+func containerNetworkInvalidate(name string) {
+	containerNetworksLock.Lock()
+	delete(containerNetworks, name)
+	containerNetworksLock.Unlock()
+}
+
+// Resolve a nic attached to a managed network into the nic backing it. The
+// returned device is a copy, the original one is left untouched.
+func containerDeviceNetwork(cluster *db.Cluster, m types.Device) (types.Device, error) {
+	network, err := containerNetworkGet(cluster, m["network"])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load network '%s': %v", m["network"], err)
+	}
+
+	device := types.Device{}
+	for k, v := range m {
+		device[k] = v
+	}
+
+	switch network.Type {
+	case "bridge":
+		device["nictype"] = "bridged"
+		device["parent"] = m["network"]
+	default:
+		return nil, fmt.Errorf("Network '%s' of type %s can't be attached to", m["network"], network.Type)
+	}
+
+	// Use the MTU of the network unless the nic overrides it
+	if device["mtu"] == "" && network.MTU != "" {
+		device["mtu"] = network.MTU
+	}
+
+	return device, nil
+}
+
 func containerValidDevices(db *db.Cluster, devices types.Devices, profile bool, expanded bool) error {
 	// Empty device list
 	if devices == nil {
@@ -332,7 +292,8 @@ func containerValidDevices(db *db.Cluster, devices types.Devices, profile bool,
 			return fmt.Errorf("Missing device type for device '%s'", name)
 		}
 
-		if !shared.StringInSlice(m["type"], []string{"disk", "gpu", "infiniband", "nic", "none", "proxy", "unix-block", "unix-char", "usb"}) {
+		driver, err := deviceDriverLoad(m["type"])
+		if err != nil {
 			return fmt.Errorf("Invalid device type for device '%s'", name)
 		}
 
@@ -342,137 +303,17 @@ func containerValidDevices(db *db.Cluster, devices types.Devices, profile bool,
 			}
 		}
 
-		if m["type"] == "nic" {
-			if m["nictype"] == "" {
-				return fmt.Errorf("Missing nic type")
-			}
-
-			if !shared.StringInSlice(m["nictype"], []string{"bridged", "macvlan", "p2p", "physical", "sriov"}) {
-				return fmt.Errorf("Bad nic type: %s", m["nictype"])
-			}
-
-			if shared.StringInSlice(m["nictype"], []string{"bridged", "macvlan", "physical", "sriov"}) && m["parent"] == "" {
-				return fmt.Errorf("Missing parent for %s type nic", m["nictype"])
-			}
-		} else if m["type"] == "infiniband" {
-			if m["nictype"] == "" {
-				return fmt.Errorf("Missing nic type")
-			}
-
-			if !shared.StringInSlice(m["nictype"], []string{"physical", "sriov"}) {
-				return fmt.Errorf("Bad nic type: %s", m["nictype"])
-			}
-
-			if m["parent"] == "" {
-				return fmt.Errorf("Missing parent for %s type nic", m["nictype"])
-			}
-		} else if m["type"] == "disk" {
-			if !expanded && !shared.StringInSlice(m["path"], diskDevicePaths) {
-				diskDevicePaths = append(diskDevicePaths, m["path"])
-			} else if !expanded {
+		if m["type"] == "disk" && !expanded {
+			if shared.StringInSlice(m["path"], diskDevicePaths) {
 				return fmt.Errorf("More than one disk device uses the same path: %s.", m["path"])
 			}
 
-			if m["path"] == "" {
-				return fmt.Errorf("Disk entry is missing the required \"path\" property.")
-			}
-
-			if m["source"] == "" && m["path"] != "/" {
-				return fmt.Errorf("Disk entry is missing the required \"source\" property.")
-			}
-
-			if m["path"] == "/" && m["source"] != "" {
-				return fmt.Errorf("Root disk entry may not have a \"source\" property set.")
-			}
-
-			if m["size"] != "" && m["path"] != "/" {
-				return fmt.Errorf("Only the root disk may have a size quota.")
-			}
-
-			if (m["path"] == "/" || !shared.IsDir(m["source"])) && m["recursive"] != "" {
-				return fmt.Errorf("The recursive option is only supported for additional bind-mounted paths.")
-			}
-
-			if m["pool"] != "" {
-				if filepath.IsAbs(m["source"]) {
-					return fmt.Errorf("Storage volumes cannot be specified as absolute paths.")
-				}
-
-				_, err := db.StoragePoolGetID(m["pool"])
-				if err != nil {
-					return fmt.Errorf("The \"%s\" storage pool doesn't exist.", m["pool"])
-				}
-			}
-
-			if m["propagation"] != "" {
-				if !util.RuntimeLiblxcVersionAtLeast(3, 0, 0) {
-					return fmt.Errorf("liblxc 3.0 is required for mount propagation configuration")
-				}
-
-				if !shared.StringInSlice(m["propagation"], []string{"private", "shared", "slave", "unbindable", "rprivate", "rshared", "rslave", "runbindable"}) {
-					return fmt.Errorf("Invalid propagation mode '%s'", m["propagation"])
-				}
-			}
-		} else if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
-			if m["source"] == "" && m["path"] == "" {
-				return fmt.Errorf("Unix device entry is missing the required \"source\" or \"path\" property.")
-			}
-
-			if (m["required"] == "" || shared.IsTrue(m["required"])) && (m["major"] == "" || m["minor"] == "") {
-				srcPath, exist := m["source"]
-				if !exist {
-					srcPath = m["path"]
-				}
-				if !shared.PathExists(srcPath) {
-					return fmt.Errorf("The device path doesn't exist on the host and major/minor wasn't specified.")
-				}
-
-				dType, _, _, err := deviceGetAttributes(srcPath)
-				if err != nil {
-					return err
-				}
-
-				if m["type"] == "unix-char" && dType != "c" {
-					return fmt.Errorf("Path specified for unix-char device is a block device.")
-				}
-
-				if m["type"] == "unix-block" && dType != "b" {
-					return fmt.Errorf("Path specified for unix-block device is a character device.")
-				}
-			}
-		} else if m["type"] == "usb" {
-			if m["vendorid"] == "" {
-				return fmt.Errorf("Missing vendorid for USB device.")
-			}
-		} else if m["type"] == "gpu" {
-			if m["pci"] != "" && !shared.PathExists(fmt.Sprintf("/sys/bus/pci/devices/%s", m["pci"])) {
-				return fmt.Errorf("Invalid PCI address (no device found): %s", m["pci"])
-			}
-
-			if m["pci"] != "" && (m["id"] != "" || m["productid"] != "" || m["vendorid"] != "") {
-				return fmt.Errorf("Cannot use id, productid or vendorid when pci is set")
-			}
-
-			if m["id"] != "" && (m["pci"] != "" || m["productid"] != "" || m["vendorid"] != "") {
-				return fmt.Errorf("Cannot use pci, productid or vendorid when id is set")
-			}
-		} else if m["type"] == "proxy" {
-			if m["listen"] == "" {
-				return fmt.Errorf("Proxy device entry is missing the required \"listen\" property.")
-			}
-
-			if m["connect"] == "" {
-				return fmt.Errorf("Proxy device entry is missing the required \"connect\" property.")
-			}
+			diskDevicePaths = append(diskDevicePaths, m["path"])
+		}
 
-			if (!strings.HasPrefix(m["listen"], "unix:") || strings.HasPrefix(m["listen"], "unix:@")) &&
-				(m["uid"] != "" || m["gid"] != "" || m["mode"] != "") {
-				return fmt.Errorf("Only proxy devices for non-abstract unix sockets can carry uid, gid, or mode properties")
-			}
-		} else if m["type"] == "none" {
-			continue
-		} else {
-			return fmt.Errorf("Invalid device type: %s", m["type"])
+		err = driver.Validate(db, m, expanded)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -487,17 +328,25 @@ func containerValidDevices(db *db.Cluster, devices types.Devices, profile bool,
 	return nil
 }
 
+// A containerCommandRunner is a command running inside a container, as
+// returned by container.Exec.
+type containerCommandRunner interface {
+	// Wait for the command to exit and return its exit code.
+	Wait() (int, error)
+}
+
 // The container interface
 type container interface {
 	// Container actions
 	Freeze() error
+	Reboot(timeout time.Duration) error
 	Shutdown(timeout time.Duration) error
 	Start(stateful bool) error
 	Stop(stateful bool) error
 	Unfreeze() error
 
 	// Snapshots & migration & backups
-	Restore(sourceContainer container, stateful bool) error
+	Restore(sourceContainer container, stateful bool, only string) error
 	/* actionScript here is a script called action.sh in the stateDir, to
 	 * be passed to CRIU as --action-script
 	 */
@@ -511,6 +360,7 @@ type container interface {
 
 	Delete() error
 	Export(w io.Writer, properties map[string]string) error
+	ExportOCI(w io.Writer, properties map[string]string) error
 
 	// Live configuration
 	CGroupGet(key string) (string, error)
@@ -535,19 +385,23 @@ type container interface {
 		 * 1. passing in false for wait
 		 *    - equivalent to calling cmd.Run()
 		 * 2. passing in true for wait
-	         *    - start the command and return its PID in the first return
+	         *    - start the command and return it in the first return
 	         *      argument and the PID of the attached process in the second
 	         *      argument. It's the callers responsibility to wait on the
 	         *      command. (Note. The returned PID of the attached process can not
-	         *      be waited upon since it's a child of the lxd forkexec command
-	         *      (the PID returned in the first return argument). It can however
-	         *      be used to e.g. forward signals.)
+	         *      be waited upon since it's a child of the lxd forkexec or
+	         *      forkagent command. It can however be used to e.g. forward
+	         *      signals.)
+	 *
+	 * The command is run as the given uid and gid of the container, from the
+	 * given working directory (or $HOME if empty).
 	*/
-	Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool) (*exec.Cmd, int, int, error)
+	Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool, cwd string, uid uint32, gid uint32, groups []uint32) (containerCommandRunner, int, int, error)
 
 	// Status
 	Render() (interface{}, interface{}, error)
 	RenderState() (*api.ContainerState, error)
+	RenderUsage() api.ContainerUsageSample
 	IsPrivileged() bool
 	IsRunning() bool
 	IsFrozen() bool
@@ -716,15 +570,17 @@ func containerCreateFromImage(d *Daemon, args db.ContainerArgs, hash string) (co
 	}
 	if nodeAddress != "" {
 		// The image is available from another node, let's try to
-		// import it.
-		logger.Debugf("Transferring image %s from node %s", hash, nodeAddress)
-		client, err := cluster.Connect(nodeAddress, d.endpoints.NetworkCert(), false)
-		if err != nil {
-			return nil, err
-		}
-		err = imageImportFromNode(filepath.Join(d.os.VarDir, "images"), client, hash)
-		if err != nil {
-			return nil, err
+		// import it, unless the images directory is shared.
+		if !shared.PathExists(filepath.Join(d.os.VarDir, "images", img.Fingerprint)) {
+			logger.Debugf("Transferring image %s from node %s", hash, nodeAddress)
+			client, err := cluster.Connect(nodeAddress, d.endpoints.NetworkCert(), false)
+			if err != nil {
+				return nil, err
+			}
+			err = imageImportFromNode(filepath.Join(d.os.VarDir, "images"), client, hash)
+			if err != nil {
+				return nil, err
+			}
 		}
 		err = d.cluster.ImageAssociateNode(hash)
 		if err != nil {
@@ -817,6 +673,11 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 				}
 			}
 
+			profilePriorities, err := s.Cluster.ContainerProfilePriorities(snap.Id())
+			if err != nil {
+				return nil, err
+			}
+
 			fields := strings.SplitN(snap.Name(), shared.SnapshotDelimiter, 2)
 			newSnapName := fmt.Sprintf("%s/%s", ct.Name(), fields[1])
 			csArgs := db.ContainerArgs{
@@ -828,6 +689,8 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 				Ephemeral:    snap.IsEphemeral(),
 				Name:         newSnapName,
 				Profiles:     snap.Profiles(),
+
+				ProfilePriorities: profilePriorities,
 			}
 
 			// Create the snapshots.
@@ -1009,27 +872,49 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 		return nil, fmt.Errorf("Requested architecture isn't supported by this host")
 	}
 
-	// Validate profiles
-	profiles, err := s.Cluster.Profiles()
-	if err != nil {
-		return nil, err
+	// Set the timestamps here rather than read them back from the
+	// database, which only stores them with a one second precision
+	if args.CreationDate.IsZero() {
+		args.CreationDate = time.Now().UTC().Truncate(time.Second)
 	}
 
-	checkedProfiles := []string{}
-	for _, profile := range args.Profiles {
-		if !shared.StringInSlice(profile, profiles) {
-			return nil, fmt.Errorf("Requested profile '%s' doesn't exist", profile)
-		}
+	if args.LastUsedDate.IsZero() {
+		args.LastUsedDate = time.Unix(0, 0).UTC()
+	}
 
-		if shared.StringInSlice(profile, checkedProfiles) {
-			return nil, fmt.Errorf("Duplicate profile found in request")
+	// Fetch all the profiles at once, their config and devices are
+	// needed to expand those of the container. This happens in the same
+	// transaction as the creation of the container entry, so that the
+	// profiles can't change in between.
+	var profileConfigs map[string]map[string]string
+	var profileDevices map[string]types.Devices
+	var id int
+	err = s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		profileConfigs, profileDevices, err = tx.ProfilesExpandData(args.Profiles)
+		if err != nil {
+			return err
 		}
 
-		checkedProfiles = append(checkedProfiles, profile)
-	}
+		// Validate profiles
+		checkedProfiles := []string{}
+		for _, profile := range args.Profiles {
+			_, ok := profileConfigs[profile]
+			if !ok {
+				return fmt.Errorf("Requested profile '%s' doesn't exist", profile)
+			}
+
+			if shared.StringInSlice(profile, checkedProfiles) {
+				return fmt.Errorf("Duplicate profile found in request")
+			}
 
-	// Create the container entry
-	id, err := s.Cluster.ContainerCreate(args)
+			checkedProfiles = append(checkedProfiles, profile)
+		}
+
+		// Create the container entry
+		id, err = tx.ContainerCreate(args)
+		return err
+	})
 	if err != nil {
 		if err == db.ErrAlreadyDefined {
 			thing := "Container"
@@ -1046,17 +931,15 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 
 	args.ID = id
 
-	// Read the timestamp from the database
-	dbArgs, err := s.Cluster.ContainerGet(args.Name)
-	if err != nil {
-		s.Cluster.ContainerRemove(args.Name)
-		return nil, err
+	// Setup the container struct and finish creation (storage and idmap)
+	configs := make([]map[string]string, len(args.Profiles))
+	devices := make([]types.Devices, len(args.Profiles))
+	for i, profile := range args.Profiles {
+		configs[i] = profileConfigs[profile]
+		devices[i] = profileDevices[profile]
 	}
-	args.CreationDate = dbArgs.CreationDate
-	args.LastUsedDate = dbArgs.LastUsedDate
 
-	// Setup the container struct and finish creation (storage and idmap)
-	c, err := containerLXCCreate(s, args)
+	c, err := containerLXCCreate(s, args, configs, devices)
 	if err != nil {
 		s.Cluster.ContainerRemove(args.Name)
 		return nil, err
@@ -1131,6 +1014,59 @@ func containerLoadByName(s *state.State, name string) (container, error) {
 	return containerLXCLoad(s, args)
 }
 
+// Load all the containers of the local node, fetching their database records
+// in one go. This is what gets run for every container on startup, so it
+// needs to stay fast on nodes with thousands of them.
+func containerLoadNodeAll(s *state.State) ([]container, error) {
+	var cts []db.ContainerArgs
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		cts, err = tx.ContainerGetAll(db.CTypeRegular, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	containers := make([]container, len(cts))
+	for i, args := range cts {
+		containers[i], err = containerLXCLoad(s, args)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load container \"%s\": %v", args.Name, err)
+		}
+	}
+
+	return containers, nil
+}
+
+// Load all the containers of the local node, skipping (and logging) the ones
+// which fail to load, so that a single broken container doesn't keep the
+// background tasks from dealing with all the others.
+func containerLoadNodeAllLenient(s *state.State) ([]container, error) {
+	var cts []db.ContainerArgs
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		cts, err = tx.ContainerGetAll(db.CTypeRegular, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []container{}
+	for _, args := range cts {
+		c, err := containerLXCLoad(s, args)
+		if err != nil {
+			logger.Error("Failed to load container", log.Ctx{"container": args.Name, "err": err})
+			continue
+		}
+
+		containers = append(containers, c)
+	}
+
+	return containers, nil
+}
+
 func containerBackupLoadByName(s *state.State, name string) (*backup, error) {
 	// Get the DB record
 	args, err := s.Cluster.ContainerGetBackup(name)
@@ -1184,5 +1120,12 @@ func containerBackupCreate(s *state.State, args db.ContainerBackupArgs,
 		return err
 	}
 
+	// Create checksums.yaml to verify the backup against when restoring it
+	err = createBackupChecksumsFile(sourceContainer, *b)
+	if err != nil {
+		s.Cluster.ContainerBackupRemove(args.Name)
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+)
+
+var containerAnnotationsCmd = Command{
+	name: "containers/{name}/annotations",
+	get:  containerAnnotationsGet,
+	put:  containerAnnotationsPut,
+}
+
+// Annotations are a free-form JSON object attached to a container, which LXD
+// stores but doesn't interpret.
+func doContainerAnnotationsGet(d *Daemon, name string) (string, error) {
+	var value string
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		value, err = tx.ContainerAnnotationsGet(name)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if value == "" {
+		value = "{}"
+	}
+
+	return value, nil
+}
+
+func containerAnnotationsGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	value, err := doContainerAnnotationsGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseETag(true, json.RawMessage(value), value)
+}
+
+func containerAnnotationsPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	value, err := doContainerAnnotationsGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	err = util.EtagCheck(r, value)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	// Only accept JSON objects
+	annotations := map[string]interface{}{}
+	err = json.Unmarshal(body, &annotations)
+	if err != nil {
+		return BadRequest(fmt.Errorf("Annotations must be a JSON object: %s", err))
+	}
+
+	buf := bytes.Buffer{}
+	err = json.Compact(&buf, body)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Empty objects are the same as no annotations
+	value = buf.String()
+	if len(annotations) == 0 {
+		value = ""
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ContainerAnnotationsSet(name, value)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
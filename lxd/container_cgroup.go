@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/sys"
+	"github.com/lxc/lxd/shared"
+)
+
+// Get the prefix of the liblxc configuration keys for the CGroup hierarchy in
+// use on the host.
+func cgroupConfigPrefix(os *sys.OS) string {
+	if os.CGroupUnified {
+		return "lxc.cgroup2."
+	}
+
+	return "lxc.cgroup."
+}
+
+// Check that the given CGroup key exists on the hierarchy in use on the host.
+//
+// The legacy files have no counterpart on the unified hierarchy, except for
+// device rules, which liblxc turns into an eBPF program of the container
+// there.
+func cgroupKeyCheck(os *sys.OS, key string) error {
+	if !os.CGroupUnified {
+		return nil
+	}
+
+	if strings.HasPrefix(key, "devices.") {
+		if !os.CGroupDevicesController {
+			return fmt.Errorf("Device rules on the unified CGroup hierarchy need liblxc 4.0 or higher")
+		}
+
+		return nil
+	}
+
+	legacy := false
+	for _, prefix := range []string{"blkio.", "cpuacct.", "net_cls.", "net_prio.", "cpu.cfs_"} {
+		if strings.HasPrefix(key, prefix) {
+			legacy = true
+		}
+	}
+
+	if legacy || strings.HasSuffix(key, "_in_bytes") || shared.StringInSlice(key, []string{"cpu.shares", "memory.swappiness", "cpuset.effective_cpus"}) {
+		return fmt.Errorf("CGroup key %s doesn't exist on the unified hierarchy", key)
+	}
+
+	return nil
+}
+
+// Get the CPU CGroup settings of a container for the CGroup hierarchy in use
+// on the host.
+//
+// On the unified hierarchy, cpu.shares is scaled to cpu.weight so that the
+// default share of 1024 maps to the default weight of 100, and the CFS quota
+// and period are combined into cpu.max.
+func containerCPUSettings(os *sys.OS, config map[string]string) ([]cgroupSetting, error) {
+	cpuShares, cpuCfsQuota, cpuCfsPeriod, err := deviceParseCPU(config["limits.cpu.allowance"], config["limits.cpu.priority"])
+	if err != nil {
+		return nil, err
+	}
+
+	if !os.CGroupUnified {
+		return []cgroupSetting{
+			{"cpu.shares", cpuShares},
+			{"cpu.cfs_period_us", cpuCfsPeriod},
+			{"cpu.cfs_quota_us", cpuCfsQuota},
+		}, nil
+	}
+
+	shares, err := strconv.ParseInt(cpuShares, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	if cpuCfsQuota == "-1" {
+		cpuCfsQuota = "max"
+	}
+
+	return []cgroupSetting{
+		{"cpu.weight", fmt.Sprintf("%d", cgroupClampWeight(shares*100/1024))},
+		{"cpu.max", fmt.Sprintf("%s %s", cpuCfsQuota, cpuCfsPeriod)},
+	}, nil
+}
+
+// Get the CGroup setting for a blkio weight, as returned by
+// deviceParseDiskPriority.
+//
+// On the unified hierarchy, the weight is scaled to io.weight so that the
+// default priority maps to the default weight of 100.
+func containerDiskWeightSetting(os *sys.OS, weight int) cgroupSetting {
+	if !os.CGroupUnified {
+		return cgroupSetting{"blkio.weight", fmt.Sprintf("%d", weight)}
+	}
+
+	return cgroupSetting{"io.weight", fmt.Sprintf("default %d", cgroupClampWeight(int64(weight/5)))}
+}
+
+// Get the CGroup settings for the per block device limits of a container.
+// Limits of 0 lift the corresponding restriction.
+func containerDiskLimitSettings(os *sys.OS, limits map[string]deviceBlockLimit) []cgroupSetting {
+	settings := []cgroupSetting{}
+
+	for block, limit := range limits {
+		if !os.CGroupUnified {
+			settings = append(settings,
+				cgroupSetting{"blkio.throttle.read_bps_device", fmt.Sprintf("%s %d", block, limit.readBps)},
+				cgroupSetting{"blkio.throttle.read_iops_device", fmt.Sprintf("%s %d", block, limit.readIops)},
+				cgroupSetting{"blkio.throttle.write_bps_device", fmt.Sprintf("%s %d", block, limit.writeBps)},
+				cgroupSetting{"blkio.throttle.write_iops_device", fmt.Sprintf("%s %d", block, limit.writeIops)})
+			continue
+		}
+
+		value := func(limit int64) string {
+			if limit <= 0 {
+				return "max"
+			}
+
+			return fmt.Sprintf("%d", limit)
+		}
+
+		settings = append(settings, cgroupSetting{"io.max", fmt.Sprintf("%s rbps=%s riops=%s wbps=%s wiops=%s",
+			block, value(limit.readBps), value(limit.readIops), value(limit.writeBps), value(limit.writeIops))})
+	}
+
+	return settings
+}
+
+// Parse the content of io.weight back into a blkio weight.
+func containerParseDiskWeight(value string) (int64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(value))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != "default" {
+			continue
+		}
+
+		weight, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return -1, err
+		}
+
+		return weight * 5, nil
+	}
+
+	return -1, fmt.Errorf("No default weight in io.weight")
+}
+
+// Parse the CPU usage in nanoseconds out of the content of cpu.stat.
+func containerParseCPUUsage(value string) (int64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(value))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != "usage_usec" {
+			continue
+		}
+
+		usage, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return -1, err
+		}
+
+		return usage * 1000, nil
+	}
+
+	return -1, fmt.Errorf("No usage_usec in cpu.stat")
+}
+
+// Keep a weight within the 1-10000 range of the unified hierarchy.
+func cgroupClampWeight(weight int64) int64 {
+	if weight < 1 {
+		return 1
+	}
+
+	if weight > 10000 {
+		return 10000
+	}
+
+	return weight
+}
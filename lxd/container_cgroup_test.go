@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/sys"
+)
+
+// CPU limits map to cpu.weight and cpu.max on the unified hierarchy, with
+// the default share landing on the default weight.
+func TestContainerCPUSettings(t *testing.T) {
+	config := map[string]string{"limits.cpu.allowance": "25ms/100ms"}
+
+	settings, err := containerCPUSettings(&sys.OS{}, config)
+	require.NoError(t, err)
+	assert.Equal(t, []cgroupSetting{
+		{"cpu.shares", "1024"},
+		{"cpu.cfs_period_us", "100000"},
+		{"cpu.cfs_quota_us", "25000"},
+	}, settings)
+
+	os := &sys.OS{CGroupUnified: true}
+
+	settings, err = containerCPUSettings(os, config)
+	require.NoError(t, err)
+	assert.Equal(t, []cgroupSetting{
+		{"cpu.weight", "100"},
+		{"cpu.max", "25000 100000"},
+	}, settings)
+
+	settings, err = containerCPUSettings(os, map[string]string{"limits.cpu.allowance": "50%"})
+	require.NoError(t, err)
+	assert.Equal(t, []cgroupSetting{
+		{"cpu.weight", "51"},
+		{"cpu.max", "max 100000"},
+	}, settings)
+}
+
+// Disk priorities and limits map to io.weight and io.max on the unified
+// hierarchy, and the weight reads back as the original blkio weight.
+func TestContainerDiskSettings(t *testing.T) {
+	os := &sys.OS{CGroupUnified: true}
+
+	assert.Equal(t, cgroupSetting{"blkio.weight", "500"}, containerDiskWeightSetting(&sys.OS{}, 500))
+	assert.Equal(t, cgroupSetting{"io.weight", "default 100"}, containerDiskWeightSetting(os, 500))
+	assert.Equal(t, cgroupSetting{"io.weight", "default 2"}, containerDiskWeightSetting(os, 10))
+
+	weight, err := containerParseDiskWeight("default 100\n8:0 200\n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(500), weight)
+
+	limits := map[string]deviceBlockLimit{"8:0": {readBps: 1048576, writeIops: 100}}
+	assert.Equal(t, []cgroupSetting{
+		{"io.max", "8:0 rbps=1048576 riops=max wbps=max wiops=100"},
+	}, containerDiskLimitSettings(os, limits))
+}
+
+func TestContainerParseCPUUsage(t *testing.T) {
+	usage, err := containerParseCPUUsage("usage_usec 1500\nuser_usec 1000\nsystem_usec 500\n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500000), usage)
+
+	_, err = containerParseCPUUsage("user_usec 1000\n")
+	assert.Error(t, err)
+}
+
+// Legacy-only keys are refused on the unified hierarchy, while device rules
+// go through as long as liblxc can turn them into an eBPF program.
+func TestCGroupKeyCheck(t *testing.T) {
+	legacy := &sys.OS{}
+	unified := &sys.OS{CGroupUnified: true, CGroupDevicesController: true}
+
+	for _, key := range []string{"net_prio.ifpriomap", "memory.max_usage_in_bytes", "blkio.weight", "cpu.shares", "memory.swappiness"} {
+		assert.NoError(t, cgroupKeyCheck(legacy, key), key)
+		assert.Error(t, cgroupKeyCheck(unified, key), key)
+	}
+
+	for _, key := range []string{"devices.allow", "memory.max", "memory.peak", "io.weight", "pids.max", "cpu.max"} {
+		assert.NoError(t, cgroupKeyCheck(unified, key), key)
+	}
+
+	assert.Error(t, cgroupKeyCheck(&sys.OS{CGroupUnified: true}, "devices.deny"))
+}
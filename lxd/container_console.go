@@ -52,6 +52,32 @@ type consoleWs struct {
 
 	// terminal height
 	height int
+
+	// protocol proxied over the websocket
+	protocol string
+}
+
+// The console protocols which can be requested. Only the text console is
+// available for containers, the graphical ones being reserved for virtual
+// machines.
+var consoleProtocols = []string{"console", "spice", "vnc"}
+
+// Check the console protocol requested for the given container, returning
+// the one to use.
+func containerConsoleProtocol(c container, protocol string) (string, error) {
+	if protocol == "" {
+		return "console", nil
+	}
+
+	if !shared.StringInSlice(protocol, consoleProtocols) {
+		return "", fmt.Errorf("Unknown console protocol: %s", protocol)
+	}
+
+	if protocol != "console" {
+		return "", fmt.Errorf("The %s console protocol isn't supported by containers", protocol)
+	}
+
+	return protocol, nil
 }
 
 func (s *consoleWs) Metadata() interface{} {
@@ -64,7 +90,7 @@ func (s *consoleWs) Metadata() interface{} {
 		}
 	}
 
-	return shared.Jmap{"fds": fds}
+	return shared.Jmap{"fds": fds, "protocol": s.protocol}
 }
 
 func (s *consoleWs) Connect(op *operation, r *http.Request, w http.ResponseWriter) error {
@@ -288,6 +314,11 @@ func containerConsolePost(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	protocol, err := containerConsoleProtocol(c, post.Protocol)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	err = fmt.Errorf("Container is not running")
 	if !c.IsRunning() {
 		return BadRequest(err)
@@ -326,6 +357,7 @@ func containerConsolePost(d *Daemon, r *http.Request) Response {
 	ws.container = c
 	ws.width = post.Width
 	ws.height = post.Height
+	ws.protocol = protocol
 
 	resources := map[string][]string{}
 	resources["containers"] = []string{ws.container.Name()}
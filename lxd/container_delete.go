@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
 )
 
 func containerDelete(d *Daemon, r *http.Request) Response {
@@ -31,11 +33,26 @@ func containerDelete(d *Daemon, r *http.Request) Response {
 	rmct := func(op *operation) error {
 		return c.Delete()
 	}
+	description := "Deleting container"
+
+	// Move the container to the trash instead, unless it's already there
+	expiry, err := cluster.ConfigGetInt64(d.cluster, "containers.trash_expiry")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	_, trashed := containerTrashDate(c)
+	if expiry > 0 && !trashed && !c.IsEphemeral() {
+		rmct = func(op *operation) error {
+			return containerTrash(c)
+		}
+		description = "Moving container to the trash"
+	}
 
 	resources := map[string][]string{}
 	resources["containers"] = []string{name}
 
-	op, err := operationCreate(d.cluster, operationClassTask, "Deleting container", resources, nil, rmct, nil, nil)
+	op, err := operationCreate(d.cluster, operationClassTask, description, resources, nil, rmct, nil, nil)
 	if err != nil {
 		return InternalError(err)
 	}
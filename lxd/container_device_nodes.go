@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Transient device nodes aren't part of the container's configuration, they
+// go away when removed through the API or when the container stops.
+const containerDeviceNodePrefix = "unix.transient"
+
+// Load the container targeted by a device node request
+func containerDeviceNodesLoad(d *Daemon, name string) (*containerLXC, error) {
+	c, err := containerLoadByName(d.State(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, ok := c.(*containerLXC)
+	if !ok {
+		return nil, fmt.Errorf("Device nodes aren't supported by this container")
+	}
+
+	return ct, nil
+}
+
+// Path on the host of the transient device node at the given container path
+func containerDeviceNodePath(c *containerLXC, path string) string {
+	relativeDestPath := strings.TrimPrefix(path, "/")
+	devName := fmt.Sprintf("%s.%s", containerDeviceNodePrefix, strings.Replace(relativeDestPath, "/", "-", -1))
+	return filepath.Join(c.DevicesPath(), devName)
+}
+
+func containerDeviceNodesPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	req := api.ContainerDeviceNodesPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if !shared.StringInSlice(req.Type, []string{"unix-char", "unix-block"}) {
+		return BadRequest(fmt.Errorf("Invalid device node type '%s'", req.Type))
+	}
+
+	if req.Path == "" {
+		req.Path = req.Source
+	}
+
+	if !filepath.IsAbs(req.Path) {
+		return BadRequest(fmt.Errorf("The device node path must be absolute"))
+	}
+
+	if req.Source == "" && req.Major == 0 && req.Minor == 0 {
+		return BadRequest(fmt.Errorf("Either a source or the major and minor numbers must be provided"))
+	}
+
+	ct, err := containerDeviceNodesLoad(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !ct.IsRunning() {
+		return BadRequest(fmt.Errorf("Device nodes are only available in running containers"))
+	}
+
+	// Don't shadow the devices from the container's configuration
+	for _, m := range ct.ExpandedDevices() {
+		if !shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
+			continue
+		}
+
+		path := m["path"]
+		if path == "" {
+			path = m["source"]
+		}
+
+		if filepath.Clean(path) == filepath.Clean(req.Path) {
+			return Conflict(fmt.Errorf("A device already exists at '%s'", req.Path))
+		}
+	}
+
+	if shared.PathExists(containerDeviceNodePath(ct, req.Path)) {
+		return Conflict(fmt.Errorf("A device node already exists at '%s'", req.Path))
+	}
+
+	m := types.Device{
+		"type":   req.Type,
+		"path":   req.Path,
+		"source": req.Source,
+		"mode":   req.Mode,
+	}
+
+	if req.Source == "" {
+		m["major"] = fmt.Sprintf("%d", req.Major)
+		m["minor"] = fmt.Sprintf("%d", req.Minor)
+	}
+
+	if req.UID != 0 {
+		m["uid"] = fmt.Sprintf("%d", req.UID)
+	}
+
+	if req.GID != 0 {
+		m["gid"] = fmt.Sprintf("%d", req.GID)
+	}
+
+	err = ct.insertUnixDevice(containerDeviceNodePrefix, m, false)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func containerDeviceNodesDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	path := r.FormValue("path")
+	if path == "" {
+		return BadRequest(fmt.Errorf("missing path argument"))
+	}
+
+	ct, err := containerDeviceNodesLoad(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !ct.IsRunning() {
+		return BadRequest(fmt.Errorf("Device nodes are only available in running containers"))
+	}
+
+	if !shared.PathExists(containerDeviceNodePath(ct, path)) {
+		return NotFound(fmt.Errorf("No device node found at '%s'", path))
+	}
+
+	err = ct.removeUnixDevice(containerDeviceNodePrefix, types.Device{"path": path}, true)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
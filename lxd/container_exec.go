@@ -6,7 +6,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -29,6 +28,10 @@ type execWs struct {
 	command   []string
 	container container
 	env       map[string]string
+	cwd       string
+	uid       uint32
+	gid       uint32
+	groups    []uint32
 
 	rootUid          int64
 	rootGid          int64
@@ -300,7 +303,7 @@ func (s *execWs) Do(op *operation) error {
 		return cmdErr
 	}
 
-	cmd, _, attachedPid, err := s.container.Exec(s.command, s.env, stdin, stdout, stderr, false)
+	cmd, _, attachedPid, err := s.container.Exec(s.command, s.env, stdin, stdout, stderr, false, s.cwd, s.uid, s.gid, s.groups)
 	if err != nil {
 		return err
 	}
@@ -309,25 +312,100 @@ func (s *execWs) Do(op *operation) error {
 		attachedChildIsBorn <- attachedPid
 	}
 
-	err = cmd.Wait()
-	if err == nil {
-		return finisher(0, nil)
+	exitCode, err := cmd.Wait()
+	if err != nil {
+		logger.Errorf("Failed waiting on command: %s", err)
+		return finisher(-1, nil)
 	}
 
-	exitErr, ok := err.(*exec.ExitError)
-	if ok {
-		status, ok := exitErr.Sys().(syscall.WaitStatus)
-		if ok {
-			return finisher(status.ExitStatus(), nil)
+	return finisher(exitCode, nil)
+}
+
+// Build the environment of a command executed in the container. The
+// environment.* keys of the container are applied first, followed by the
+// given overrides, then when linux.exec_environment is set to "container" the
+// container's own /etc/environment, then the home directory and name of the
+// given uid from the container's passwd file, and finally defaults for any of
+// PATH, HOME, USER and LANG which are still unset. The files of the container
+// are read through the given cache, which may be nil.
+func containerExecEnvironment(c container, files *containerExecFiles, overrides map[string]string, uid uint32) map[string]string {
+	env := map[string]string{}
+
+	for k, v := range c.ExpandedConfig() {
+		if strings.HasPrefix(k, "environment.") {
+			env[strings.TrimPrefix(k, "environment.")] = v
+		}
+	}
+
+	for k, v := range overrides {
+		env[k] = v
+	}
+
+	// Take the defaults from the container itself if requested
+	if c.ExpandedConfig()["linux.exec_environment"] == "container" {
+		for k, v := range containerExecUserEnvironment(c, files, uid) {
+			_, ok := env[k]
+			if !ok {
+				env[k] = v
+			}
+		}
+	}
+
+	// Commands run as another user get its home and name
+	_, hasHome := env["HOME"]
+	_, hasUser := env["USER"]
+	if uid != 0 && (!hasHome || !hasUser) {
+		content, err := files.read(c, "/etc/passwd")
+		if err == nil {
+			user, home, ok := containerExecParsePasswd(content, uid)
+			if ok && !hasHome {
+				env["HOME"] = home
+			}
+			if ok && !hasUser {
+				env["USER"] = user
+			}
 		}
 
-		if status.Signaled() {
-			// 128 + n == Fatal error signal "n"
-			return finisher(128+int(status.Signal()), nil)
+		// Unknown users get neither root's home nor its name
+		_, ok := env["HOME"]
+		if !ok {
+			env["HOME"] = "/"
+		}
+
+		_, ok = env["USER"]
+		if !ok {
+			env["USER"] = fmt.Sprintf("%d", uid)
+		}
+	}
+
+	// Set default value for PATH
+	_, ok := env["PATH"]
+	if !ok {
+		env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+		if c.FileExists("/snap") == nil {
+			env["PATH"] = fmt.Sprintf("%s:/snap/bin", env["PATH"])
 		}
 	}
 
-	return finisher(-1, nil)
+	// Set default value for HOME
+	_, ok = env["HOME"]
+	if !ok {
+		env["HOME"] = "/root"
+	}
+
+	// Set default value for USER
+	_, ok = env["USER"]
+	if !ok {
+		env["USER"] = "root"
+	}
+
+	// Set default value for LANG
+	_, ok = env["LANG"]
+	if !ok {
+		env["LANG"] = "C.UTF-8"
+	}
+
+	return env
 }
 
 func containerExecPost(d *Daemon, r *http.Request) Response {
@@ -374,46 +452,13 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Container is frozen."))
 	}
 
-	env := map[string]string{}
-
-	for k, v := range c.ExpandedConfig() {
-		if strings.HasPrefix(k, "environment.") {
-			env[strings.TrimPrefix(k, "environment.")] = v
-		}
+	if post.Cwd != "" && !filepath.IsAbs(post.Cwd) {
+		return BadRequest(fmt.Errorf("The working directory must be an absolute path"))
 	}
 
-	if post.Environment != nil {
-		for k, v := range post.Environment {
-			env[k] = v
-		}
-	}
-
-	// Set default value for PATH
-	_, ok := env["PATH"]
-	if !ok {
-		env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
-		if c.FileExists("/snap") == nil {
-			env["PATH"] = fmt.Sprintf("%s:/snap/bin", env["PATH"])
-		}
-	}
-
-	// Set default value for HOME
-	_, ok = env["HOME"]
-	if !ok {
-		env["HOME"] = "/root"
-	}
-
-	// Set default value for USER
-	_, ok = env["USER"]
-	if !ok {
-		env["USER"] = "root"
-	}
-
-	// Set default value for USER
-	_, ok = env["LANG"]
-	if !ok {
-		env["LANG"] = "C.UTF-8"
-	}
+	files := &containerExecFiles{}
+	env := containerExecEnvironment(c, files, post.Environment, post.User)
+	groups := containerExecUserGroups(c, files, post.User, post.Group)
 
 	if post.WaitForWS {
 		ws := &execWs{}
@@ -425,7 +470,7 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		}
 
 		if idmapset != nil {
-			ws.rootUid, ws.rootGid = idmapset.ShiftIntoNs(0, 0)
+			ws.rootUid, ws.rootGid = idmapset.ShiftIntoNs(int64(post.User), int64(post.Group))
 		}
 
 		ws.conns = map[int]*websocket.Conn{}
@@ -448,6 +493,10 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		ws.command = post.Command
 		ws.container = c
 		ws.env = env
+		ws.cwd = post.Cwd
+		ws.uid = post.User
+		ws.gid = post.Group
+		ws.groups = groups
 
 		ws.width = post.Width
 		ws.height = post.Height
@@ -483,7 +532,7 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 			defer stderr.Close()
 
 			// Run the command
-			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, stdout, stderr, true)
+			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, stdout, stderr, true, post.Cwd, post.User, post.Group, groups)
 
 			// Update metadata with the right URLs
 			metadata["return"] = cmdResult
@@ -492,7 +541,7 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 				"2": fmt.Sprintf("/%s/containers/%s/logs/%s", version.APIVersion, c.Name(), filepath.Base(stderr.Name())),
 			}
 		} else {
-			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, nil, nil, true)
+			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, nil, nil, true, post.Cwd, post.User, post.Group, groups)
 			metadata["return"] = cmdResult
 		}
 
@@ -514,3 +563,193 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 
 	return OperationResponse(op)
 }
+
+// Build the default environment of the given user inside the container, from
+// the container's /etc/environment and the user's passwd entry. Files which
+// can't be read are skipped.
+func containerExecUserEnvironment(c container, files *containerExecFiles, uid uint32) map[string]string {
+	env := map[string]string{}
+
+	content, err := files.read(c, "/etc/environment")
+	if err == nil {
+		for k, v := range containerExecParseEnvironment(content) {
+			env[k] = v
+		}
+	}
+
+	content, err = files.read(c, "/etc/passwd")
+	if err == nil {
+		user, home, ok := containerExecParsePasswd(content, uid)
+		if ok {
+			env["USER"] = user
+			env["HOME"] = home
+		}
+	}
+
+	return env
+}
+
+// Return the supplementary groups of the given uid inside the container, from
+// its passwd and group files, including the given primary gid. Root and users
+// without a passwd entry only get the primary gid.
+func containerExecUserGroups(c container, files *containerExecFiles, uid uint32, gid uint32) []uint32 {
+	groups := []uint32{gid}
+	if uid == 0 {
+		return groups
+	}
+
+	passwd, err := files.read(c, "/etc/passwd")
+	if err != nil {
+		return groups
+	}
+
+	user, _, ok := containerExecParsePasswd(passwd, uid)
+	if !ok {
+		return groups
+	}
+
+	content, err := files.read(c, "/etc/group")
+	if err != nil {
+		return groups
+	}
+
+	for _, group := range containerExecParseGroups(content, user) {
+		if group != gid {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+// Cache of the files read from a container while preparing a command, so that
+// each of them is only pulled once.
+type containerExecFiles struct {
+	contents map[string]string
+	errs     map[string]error
+}
+
+// Read a (small) file from the container, or return the result of a previous
+// read of it. A nil cache reads the file every time.
+func (f *containerExecFiles) read(c container, path string) (string, error) {
+	if f == nil {
+		return containerExecReadFile(c, path)
+	}
+
+	content, ok := f.contents[path]
+	if ok {
+		return content, f.errs[path]
+	}
+
+	if f.contents == nil {
+		f.contents = map[string]string{}
+		f.errs = map[string]error{}
+	}
+
+	content, err := containerExecReadFile(c, path)
+	f.contents[path] = content
+	f.errs[path] = err
+
+	return content, err
+}
+
+// Read a (small) file from the container.
+func containerExecReadFile(c container, path string) (string, error) {
+	temp, err := ioutil.TempFile("", "lxd_exec_env_")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	_, _, _, type_, _, err := c.FilePull(path, temp.Name())
+	if err != nil {
+		return "", err
+	}
+
+	if type_ != "file" {
+		return "", fmt.Errorf("%s isn't a regular file", path)
+	}
+
+	content, err := ioutil.ReadAll(temp)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// Parse the content of a pam_env style /etc/environment file, made of
+// KEY=VALUE lines with optionally quoted values.
+func containerExecParseEnvironment(content string) map[string]string {
+	env := map[string]string{}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 || !shared.IsEnvironmentName(fields[0]) {
+			continue
+		}
+
+		value := fields[1]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		env[fields[0]] = value
+	}
+
+	return env
+}
+
+// Find the name and home directory of the given uid in the content of a
+// passwd file.
+func containerExecParsePasswd(content string, uid uint32) (string, string, bool) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 {
+			continue
+		}
+
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil || uint32(id) != uid {
+			continue
+		}
+
+		return fields[0], fields[5], true
+	}
+
+	return "", "", false
+}
+
+// Find the ids of the groups listing the given user as a member in the
+// content of a group file.
+func containerExecParseGroups(content string, user string) []uint32 {
+	groups := []uint32{}
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 4 {
+			continue
+		}
+
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		for _, member := range strings.Split(fields[3], ",") {
+			if strings.TrimSpace(member) == user {
+				groups = append(groups, uint32(id))
+				break
+			}
+		}
+	}
+
+	return groups
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// An execAgent is a long-lived forkagent process spawning tasks inside a
+// running container, so that successive execs don't each need to start a
+// new forkexec process.
+type execAgent struct {
+	cmd  *exec.Cmd
+	conn *net.UnixConn
+
+	lock    sync.Mutex
+	nextID  int
+	pending map[int]chan execAgentReply
+	dead    bool
+}
+
+// An execAgentCmd is a task spawned by an execAgent.
+type execAgentCmd struct {
+	replies chan execAgentReply
+}
+
+// Wait for the task to exit and return its exit code.
+func (c *execAgentCmd) Wait() (int, error) {
+	reply, ok := <-c.replies
+	if !ok {
+		return -1, fmt.Errorf("Exec agent went away")
+	}
+
+	if reply.Error != "" {
+		return reply.Status, fmt.Errorf("%s", reply.Error)
+	}
+
+	return reply.Status, nil
+}
+
+var execAgentsLock sync.Mutex
+var execAgents = map[string]*execAgent{}
+
+// Return the exec agent of the given container, spawning it if needed.
+func execAgentGet(c *containerLXC) (*execAgent, error) {
+	execAgentsLock.Lock()
+	defer execAgentsLock.Unlock()
+
+	agent, ok := execAgents[c.name]
+	if ok {
+		agent.lock.Lock()
+		dead := agent.dead
+		agent.lock.Unlock()
+
+		if !dead {
+			return agent, nil
+		}
+	}
+
+	agent, err := execAgentSpawn(c)
+	if err != nil {
+		return nil, err
+	}
+
+	execAgents[c.name] = agent
+
+	return agent, nil
+}
+
+// Stop the exec agent of the given container, if any. Tasks it spawned are
+// left running.
+func execAgentStop(name string) {
+	execAgentsLock.Lock()
+	agent, ok := execAgents[name]
+	delete(execAgents, name)
+	execAgentsLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	// Closing the socket makes the agent exit.
+	agent.conn.Close()
+}
+
+func execAgentSpawn(c *containerLXC) (*execAgent, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_SEQPACKET|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	local := os.NewFile(uintptr(fds[0]), "agent")
+	remote := os.NewFile(uintptr(fds[1]), "agent")
+	defer local.Close()
+	defer remote.Close()
+
+	conn, err := net.FileConn(local)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(c.state.OS.ExecPath, "forkagent", c.name, c.state.OS.LxcPath, filepath.Join(c.LogPath(), "lxc.conf"))
+	cmd.ExtraFiles = []*os.File{remote}
+
+	err = cmd.Start()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	agent := &execAgent{
+		cmd:     cmd,
+		conn:    conn.(*net.UnixConn),
+		pending: map[int]chan execAgentReply{},
+	}
+
+	go agent.read(c.name)
+
+	return agent, nil
+}
+
+// Dispatch the replies of the agent until it goes away.
+func (a *execAgent) read(name string) {
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := a.conn.Read(buf)
+		if err != nil || n == 0 {
+			break
+		}
+
+		reply := execAgentReply{}
+		err = json.Unmarshal(buf[:n], &reply)
+		if err != nil {
+			logger.Warn("Invalid reply from exec agent", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		a.lock.Lock()
+		ch, ok := a.pending[reply.ID]
+		if ok && reply.Exited {
+			delete(a.pending, reply.ID)
+		}
+		a.lock.Unlock()
+
+		if ok {
+			ch <- reply
+			if reply.Exited {
+				close(ch)
+			}
+		}
+	}
+
+	// Fail whatever is still waiting on the agent.
+	a.lock.Lock()
+	a.dead = true
+	for id, ch := range a.pending {
+		close(ch)
+		delete(a.pending, id)
+	}
+	a.lock.Unlock()
+
+	a.conn.Close()
+	a.cmd.Wait()
+
+	logger.Debug("Exec agent exited", log.Ctx{"container": name})
+}
+
+// Spawn a task through the agent, returning it along with the PID of the
+// attached process. The ID of the request is set by the agent.
+func (a *execAgent) run(req execAgentRequest, stdin *os.File, stdout *os.File, stderr *os.File) (*execAgentCmd, int, error) {
+	// Unset streams are connected to /dev/null, like with os/exec.
+	files := []*os.File{stdin, stdout, stderr}
+	for i, f := range files {
+		if f != nil {
+			continue
+		}
+
+		null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+		if err != nil {
+			return nil, -1, err
+		}
+		defer null.Close()
+
+		files[i] = null
+	}
+
+	a.lock.Lock()
+	if a.dead {
+		a.lock.Unlock()
+		return nil, -1, fmt.Errorf("Exec agent went away")
+	}
+
+	id := a.nextID
+	a.nextID++
+	req.ID = id
+
+	replies := make(chan execAgentReply, 2)
+	a.pending[id] = replies
+	a.lock.Unlock()
+
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	rights := syscall.UnixRights(int(files[0].Fd()), int(files[1].Fd()), int(files[2].Fd()))
+	_, _, err = a.conn.WriteMsgUnix(buf, rights, nil)
+	if err != nil {
+		a.lock.Lock()
+		delete(a.pending, id)
+		a.lock.Unlock()
+
+		return nil, -1, err
+	}
+
+	// Wait for the task to be spawned
+	reply, ok := <-replies
+	if !ok {
+		return nil, -1, fmt.Errorf("Exec agent went away")
+	}
+
+	if reply.Exited {
+		return nil, -1, fmt.Errorf("Failed to spawn task: %s", reply.Error)
+	}
+
+	return &execAgentCmd{replies: replies}, reply.PID, nil
+}
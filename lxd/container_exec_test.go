@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const containerExecTestGroup = `root:x:0:
+adm:x:4:syslog,ubuntu
+sudo:x:27:ubuntu
+ubuntu:x:1000:
+docker:x:999: ubuntu2, ubuntu
+broken
+`
+
+// The supplementary groups of a user are the ones listing it as a member.
+func TestContainerExecParseGroups(t *testing.T) {
+	assert.Equal(t, []uint32{4, 27, 999}, containerExecParseGroups(containerExecTestGroup, "ubuntu"))
+	assert.Equal(t, []uint32{4}, containerExecParseGroups(containerExecTestGroup, "syslog"))
+	assert.Equal(t, []uint32{}, containerExecParseGroups(containerExecTestGroup, "ubunt"))
+}
+
+// Users are looked up by uid in the passwd file.
+func TestContainerExecParsePasswd(t *testing.T) {
+	passwd := "root:x:0:0:root:/root:/bin/bash\nubuntu:x:1000:1000:Ubuntu:/home/ubuntu:/bin/bash\n"
+
+	user, home, ok := containerExecParsePasswd(passwd, 1000)
+	assert.True(t, ok)
+	assert.Equal(t, "ubuntu", user)
+	assert.Equal(t, "/home/ubuntu", home)
+
+	_, _, ok = containerExecParsePasswd(passwd, 1001)
+	assert.False(t, ok)
+}
+
+// Container serving files from memory and counting how often each is pulled.
+type containerExecTestContainer struct {
+	container
+	config map[string]string
+	files  map[string]string
+	pulls  map[string]int
+}
+
+func (c *containerExecTestContainer) ExpandedConfig() map[string]string {
+	return c.config
+}
+
+func (c *containerExecTestContainer) FileExists(path string) error {
+	return os.ErrNotExist
+}
+
+func (c *containerExecTestContainer) FilePull(srcpath string, dstpath string) (int64, int64, os.FileMode, string, []string, error) {
+	c.pulls[srcpath]++
+
+	content, ok := c.files[srcpath]
+	if !ok {
+		return -1, -1, 0, "", nil, fmt.Errorf("%s doesn't exist", srcpath)
+	}
+
+	err := ioutil.WriteFile(dstpath, []byte(content), 0600)
+	if err != nil {
+		return -1, -1, 0, "", nil, err
+	}
+
+	return 0, 0, 0644, "file", nil, nil
+}
+
+// The files needed to build the environment and groups of a command are
+// pulled from the container only once.
+func TestContainerExecFiles(t *testing.T) {
+	c := &containerExecTestContainer{
+		config: map[string]string{"linux.exec_environment": "container"},
+		files: map[string]string{
+			"/etc/environment": "LANG=en_US.UTF-8\n",
+			"/etc/passwd":      "ubuntu:x:1000:1000:Ubuntu:/home/ubuntu:/bin/bash\n",
+			"/etc/group":       containerExecTestGroup,
+		},
+		pulls: map[string]int{},
+	}
+
+	files := &containerExecFiles{}
+	env := containerExecEnvironment(c, files, nil, 1000)
+	groups := containerExecUserGroups(c, files, 1000, 1000)
+
+	assert.Equal(t, "ubuntu", env["USER"])
+	assert.Equal(t, "/home/ubuntu", env["HOME"])
+	assert.Equal(t, "en_US.UTF-8", env["LANG"])
+	assert.Equal(t, []uint32{1000, 4, 27, 999}, groups)
+	assert.Equal(t, map[string]int{"/etc/environment": 1, "/etc/passwd": 1, "/etc/group": 1}, c.pulls)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared"
+)
+
+var containerExportCmd = Command{
+	name: "containers/{name}/export",
+	get:  containerExportGet,
+}
+
+var containerSnapshotExportCmd = Command{
+	name: "containers/{name}/snapshots/{snapshotName}/export",
+	get:  containerExportGet,
+}
+
+func containerExportGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	fullName := name
+	snapshotName, ok := mux.Vars(r)["snapshotName"]
+	if ok {
+		fullName = name + shared.SnapshotDelimiter + snapshotName
+	}
+
+	// OCI image layouts are the only format for now
+	format := r.FormValue("format")
+	if format != "" && format != "oci" {
+		return BadRequest(fmt.Errorf("Unsupported export format \"%s\"", format))
+	}
+
+	c, err := containerLoadByName(d.State(), fullName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if c.IsRunning() {
+		return BadRequest(fmt.Errorf("Cannot export a running container as an image"))
+	}
+
+	// Label the image with the image properties of the container
+	properties := map[string]string{}
+	for key, value := range c.ExpandedConfig() {
+		if strings.HasPrefix(key, "image.") {
+			properties[strings.TrimPrefix(key, "image.")] = value
+		}
+	}
+
+	f, err := ioutil.TempFile(shared.VarPath("images"), "lxd_oci_export_")
+	if err != nil {
+		return InternalError(err)
+	}
+
+	// Generate the image as part of an operation, so that it's tracked
+	// alongside the other operations on the container
+	run := func(op *operation) error {
+		return c.ExportOCI(f, properties)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, operationClassTask, "Exporting container", resources, nil, run, nil, nil)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return InternalError(err)
+	}
+
+	chanRun, err := op.Run()
+	if err == nil {
+		err = <-chanRun
+	}
+
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return SmartError(err)
+	}
+
+	ent := fileResponseEntry{
+		path:     f.Name(),
+		filename: fmt.Sprintf("%s.tar", strings.Replace(fullName, shared.SnapshotDelimiter, "_", -1)),
+	}
+
+	return FileResponse(r, []fileResponseEntry{ent}, nil, true)
+}
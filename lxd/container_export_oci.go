@@ -0,0 +1,246 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lxc/lxd/lxd/oci"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/osarch"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Writer keeping track of the digest and size of what's written to it.
+type ociDigester struct {
+	w    io.Writer
+	hash hash.Hash
+	size int64
+}
+
+func newOCIDigester(w io.Writer) *ociDigester {
+	return &ociDigester{w: w, hash: sha256.New()}
+}
+
+func (d *ociDigester) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	d.hash.Write(p[:n])
+	d.size += int64(n)
+	return n, err
+}
+
+func (d *ociDigester) Digest() string {
+	return "sha256:" + hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// ExportOCI writes the rootfs of the container as an OCI image layout, in a
+// tarball made of a single layer, the image configuration (whose labels are
+// set to the given properties) and its manifest, tagged as "latest".
+func (c *containerLXC) ExportOCI(w io.Writer, properties map[string]string) error {
+	ctxMap := log.Ctx{"name": c.name,
+		"created":   c.creationDate,
+		"ephemeral": c.ephemeral,
+		"used":      c.lastUsedDate}
+
+	// Hold the container lock for the whole export, so that the container
+	// can't be started while its rootfs is being read
+	op, err := c.createOperation("export", false, false)
+	if err != nil {
+		return err
+	}
+	defer op.Done(nil)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go op.KeepAlive(stop)
+
+	if c.IsRunning() {
+		return fmt.Errorf("Cannot export a running container as an image")
+	}
+
+	logger.Info("Exporting container as OCI image", ctxMap)
+
+	err = c.exportOCI(w, properties)
+	if err != nil {
+		logger.Error("Failed exporting container as OCI image", log.Ctx{"name": c.name, "err": err})
+		return err
+	}
+
+	logger.Info("Exported container as OCI image", ctxMap)
+	return nil
+}
+
+func (c *containerLXC) exportOCI(w io.Writer, properties map[string]string) error {
+	archName, err := c.exportArchitecture()
+	if err != nil {
+		return err
+	}
+
+	archID, err := osarch.ArchitectureId(archName)
+	if err != nil {
+		return err
+	}
+
+	arch, ok := oci.Architectures[archID]
+	if !ok {
+		return fmt.Errorf("Architecture \"%s\" isn't supported by OCI images", archName)
+	}
+
+	// Start the storage and unshift the container
+	exportDone, err := c.exportStart()
+	if err != nil {
+		return err
+	}
+	defer exportDone()
+
+	// The layer is needed in full to know its digest, keep it next to
+	// the images as it can be large.
+	layerFile, err := ioutil.TempFile(shared.VarPath("images"), "lxd_oci_layer_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(layerFile.Name())
+	defer layerFile.Close()
+
+	layer := newOCIDigester(layerFile)
+	gz := gzip.NewWriter(layer)
+	diff := newOCIDigester(gz)
+	tw := tar.NewWriter(diff)
+
+	// Paths in the layer are relative to the rootfs
+	rootfs := c.RootfsPath()
+	linkmap := map[uint64]string{}
+	err = filepath.Walk(rootfs, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == rootfs {
+			return nil
+		}
+
+		return c.tarStoreFile(linkmap, len(rootfs)+1, tw, path, fi)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+
+	created := time.Now().UTC().Format(time.RFC3339)
+	config, err := json.Marshal(oci.Image{
+		Created:      created,
+		Architecture: arch,
+		OS:           "linux",
+		Config:       oci.ImageConfig{Labels: properties},
+		RootFS:       oci.RootFS{Type: "layers", DiffIDs: []string{diff.Digest()}},
+		History:      []oci.History{{Created: created, CreatedBy: fmt.Sprintf("LXD export of %s", c.name)}},
+	})
+	if err != nil {
+		return err
+	}
+
+	configDigest := newOCIDigester(ioutil.Discard)
+	configDigest.Write(config)
+
+	manifest, err := json.Marshal(oci.Manifest{
+		SchemaVersion: 2,
+		Config:        oci.Descriptor{MediaType: oci.MediaTypeConfig, Digest: configDigest.Digest(), Size: configDigest.size},
+		Layers:        []oci.Descriptor{{MediaType: oci.MediaTypeLayer, Digest: layer.Digest(), Size: layer.size}},
+	})
+	if err != nil {
+		return err
+	}
+
+	manifestDigest := newOCIDigester(ioutil.Discard)
+	manifestDigest.Write(manifest)
+
+	index, err := json.Marshal(oci.Index{
+		SchemaVersion: 2,
+		Manifests: []oci.Descriptor{{
+			MediaType:   oci.MediaTypeManifest,
+			Digest:      manifestDigest.Digest(),
+			Size:        manifestDigest.size,
+			Annotations: map[string]string{"org.opencontainers.image.ref.name": "latest"},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write out the image layout
+	out := tar.NewWriter(w)
+	now := time.Now()
+	writeFile := func(name string, size int64, r io.Reader) error {
+		err := out.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     size,
+			ModTime:  now,
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(out, r)
+		return err
+	}
+
+	ociLayout := []byte(`{"imageLayoutVersion":"1.0.0"}`)
+	err = writeFile("oci-layout", int64(len(ociLayout)), bytes.NewReader(ociLayout))
+	if err != nil {
+		return err
+	}
+
+	blobPath := func(digest string) string {
+		return filepath.Join("blobs", "sha256", digest[len("sha256:"):])
+	}
+
+	_, err = layerFile.Seek(0, 0)
+	if err != nil {
+		return err
+	}
+
+	err = writeFile(blobPath(layer.Digest()), layer.size, layerFile)
+	if err != nil {
+		return err
+	}
+
+	err = writeFile(blobPath(configDigest.Digest()), configDigest.size, bytes.NewReader(config))
+	if err != nil {
+		return err
+	}
+
+	err = writeFile(blobPath(manifestDigest.Digest()), manifestDigest.size, bytes.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+
+	err = writeFile("index.json", int64(len(index)), bytes.NewReader(index))
+	if err != nil {
+		return err
+	}
+
+	return out.Close()
+}
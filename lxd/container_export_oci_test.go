@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The digester passes the data through while computing its digest and size.
+func TestOCIDigester(t *testing.T) {
+	buf := &bytes.Buffer{}
+	d := newOCIDigester(buf)
+
+	d.Write([]byte("hello "))
+	d.Write([]byte("world"))
+
+	sum := sha256.Sum256([]byte("hello world"))
+	assert.Equal(t, "hello world", buf.String())
+	assert.Equal(t, "sha256:"+hex.EncodeToString(sum[:]), d.Digest())
+	assert.Equal(t, int64(11), d.size)
+}
+
+// While exporting, the container lock keeps other operations like starting
+// the container from running.
+func TestContainerExportLock(t *testing.T) {
+	c := &containerLXC{id: 424242}
+
+	op, err := c.createOperation("export", false, false)
+	require.NoError(t, err)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		op.KeepAlive(stop)
+		close(done)
+	}()
+
+	_, err = c.createOperation("start", false, false)
+	assert.EqualError(t, err, "Container is busy running a export operation")
+
+	_, err = c.createOperation("stop", true, true)
+	assert.Error(t, err)
+
+	close(stop)
+	op.Done(nil)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("The operation was still being kept alive")
+	}
+
+	op, err = c.createOperation("start", false, false)
+	require.NoError(t, err)
+	op.Done(nil)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Return when the given container is due to be unfrozen, if it was frozen
+// for a limited duration.
+func containerThawDate(c container) (time.Time, bool) {
+	value, ok := c.LocalConfig()["volatile.thaw.date"]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// Thaw right away rather than leave the container frozen
+		return time.Time{}, true
+	}
+
+	return date, true
+}
+
+// Freeze the given container, unfreezing it automatically after the given
+// number of seconds if not zero.
+func containerFreeze(s *state.State, c container, duration int) error {
+	err := c.Freeze()
+	if err != nil {
+		return err
+	}
+
+	if duration == 0 {
+		// Forget about the thaw date of any previous freeze
+		_, ok := containerThawDate(c)
+		if !ok {
+			return nil
+		}
+
+		return s.Cluster.ContainerConfigRemove(c.Id(), "volatile.thaw.date")
+	}
+
+	date := time.Now().Add(time.Duration(duration) * time.Second)
+	err = c.ConfigKeySet("volatile.thaw.date", date.UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+
+	name := c.Name()
+	time.AfterFunc(time.Until(date), func() {
+		c, err := containerLoadByName(s, name)
+		if err != nil {
+			return
+		}
+
+		containerThawIfDue(s, c)
+	})
+
+	return nil
+}
+
+// Unfreeze the given container, forgetting about any pending thaw.
+func containerUnfreeze(s *state.State, c container) error {
+	err := c.Unfreeze()
+	if err != nil {
+		return err
+	}
+
+	_, ok := containerThawDate(c)
+	if !ok {
+		return nil
+	}
+
+	return s.Cluster.ContainerConfigRemove(c.Id(), "volatile.thaw.date")
+}
+
+// Unfreeze the given container if it was frozen for a limited duration which
+// expired.
+func containerThawIfDue(s *state.State, c container) {
+	date, ok := containerThawDate(c)
+	if !ok || time.Now().Before(date) {
+		return
+	}
+
+	if c.IsFrozen() {
+		logger.Info("Unfreezing container after its freeze duration", log.Ctx{"name": c.Name()})
+		err := c.Unfreeze()
+		if err != nil {
+			logger.Error("Failed to unfreeze container", log.Ctx{"name": c.Name(), "err": err})
+			return
+		}
+	}
+
+	err := s.Cluster.ContainerConfigRemove(c.Id(), "volatile.thaw.date")
+	if err != nil {
+		logger.Error("Failed to clear container thaw date", log.Ctx{"name": c.Name(), "err": err})
+	}
+}
+
+func containersThawTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containersThaw(d.State())
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// Unfreeze the containers of this node whose freeze duration expired while
+// LXD wasn't around to do it.
+func containersThaw(s *state.State) {
+	names, err := s.Cluster.ContainersNodeList(db.CTypeRegular)
+	if err != nil {
+		logger.Error("Unable to retrieve the list of containers", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range names {
+		c, err := containerLoadByName(s, name)
+		if err != nil {
+			logger.Error("Failed to load container", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		containerThawIfDue(s, c)
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The health of a running container with healthcheck.exec set.
+type containerHealth struct {
+	state   api.ContainerStateHealth
+	next    time.Time // When the next probe is due
+	probing bool      // Whether a probe or its action is still running
+	actions int       // Actions applied since the container was last healthy
+}
+
+var healthLock sync.Mutex
+
+// The health of each container being checked, by container name.
+var healthStates = map[string]*containerHealth{}
+
+// The names of the containers of this node with healthcheck.exec set, so that
+// they don't all need to be loaded on every run of the health check task. It's
+// nil when it needs to be rebuilt, which happens whenever a container is
+// created, renamed or updated, and at least every healthContainersTTL.
+var healthContainers map[string]bool
+var healthContainersLoaded time.Time
+
+const healthContainersTTL = 10 * time.Minute
+
+// Get the list of containers with health checks to be rebuilt on the next run
+// of the health check task.
+func containerHealthInvalidate() {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+
+	healthContainers = nil
+}
+
+// Load the containers of this node with healthcheck.exec set.
+func containerHealthLoad(s *state.State) ([]container, error) {
+	healthLock.Lock()
+	names := healthContainers
+	if time.Since(healthContainersLoaded) > healthContainersTTL {
+		names = nil
+	}
+	healthLock.Unlock()
+
+	containers := []container{}
+	if names != nil {
+		for name := range names {
+			c, err := containerLoadByName(s, name)
+			if err != nil {
+				// Deleted, or renamed while being loaded
+				containerHealthInvalidate()
+				continue
+			}
+
+			containers = append(containers, c)
+		}
+
+		return containers, nil
+	}
+
+	all, err := containerLoadNodeAllLenient(s)
+	if err != nil {
+		return nil, err
+	}
+
+	names = map[string]bool{}
+	for _, c := range all {
+		if c.ExpandedConfig()["healthcheck.exec"] == "" {
+			continue
+		}
+
+		names[c.Name()] = true
+		containers = append(containers, c)
+	}
+
+	healthLock.Lock()
+	healthContainers = names
+	healthContainersLoaded = time.Now()
+	healthLock.Unlock()
+
+	return containers, nil
+}
+
+// Return the result of the last health check of the given container, or nil
+// if it isn't being checked.
+func containerHealthGet(name string) *api.ContainerStateHealth {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+
+	health, ok := healthStates[name]
+	if !ok {
+		return nil
+	}
+
+	result := health.state
+	return &result
+}
+
+func containersHealthCheckTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containersHealthCheck(d.State())
+	}
+
+	// Probes are only started at this granularity, whatever their
+	// healthcheck.interval.
+	return f, task.Every(5 * time.Second)
+}
+
+// Run the health probes which are due on the running containers of this node.
+func containersHealthCheck(s *state.State) {
+	containers, err := containerHealthLoad(s)
+	if err != nil {
+		logger.Error("Unable to load the containers", log.Ctx{"err": err})
+		return
+	}
+
+	now := time.Now()
+	seen := map[string]bool{}
+	due := []container{}
+
+	healthLock.Lock()
+	for _, c := range containers {
+		if c.ExpandedConfig()["healthcheck.exec"] == "" || !c.IsRunning() {
+			continue
+		}
+
+		seen[c.Name()] = true
+
+		health, ok := healthStates[c.Name()]
+		if !ok {
+			health = &containerHealth{state: api.ContainerStateHealth{Status: "starting"}}
+			healthStates[c.Name()] = health
+		}
+
+		if health.probing || now.Before(health.next) {
+			continue
+		}
+
+		health.probing = true
+		health.next = now.Add(containerHealthInterval(c))
+		due = append(due, c)
+	}
+
+	// Forget about containers which stopped or had their check removed,
+	// except while their action runs, which may restart them.
+	for name, health := range healthStates {
+		if !seen[name] && !health.probing {
+			delete(healthStates, name)
+		}
+	}
+	healthLock.Unlock()
+
+	// Probes run in the background, so that a slow one doesn't delay the
+	// others. A container isn't probed again until its last probe is done.
+	for _, c := range due {
+		go containerHealthCheck(c)
+	}
+}
+
+func containerHealthInterval(c container) time.Duration {
+	interval, err := strconv.Atoi(c.ExpandedConfig()["healthcheck.interval"])
+	if err != nil || interval <= 0 {
+		interval = 30
+	}
+
+	return time.Duration(interval) * time.Second
+}
+
+// Run the health probe of the given container and record its result, emitting
+// a lifecycle event when the status changes. Once the container failed enough
+// consecutive checks, its healthcheck.action is handed to the supervisor.
+func containerHealthCheck(c container) {
+	result := api.ContainerStateHealth{Status: "healthy", CheckedAt: time.Now().UTC()}
+
+	exitCode, err := containerHealthProbe(c, c.ExpandedConfig()["healthcheck.exec"], containerHealthInterval(c))
+	result.ExitCode = exitCode
+	if err != nil {
+		result.Status = "unhealthy"
+		result.Message = err.Error()
+	} else if exitCode != 0 {
+		result.Status = "unhealthy"
+		result.Message = fmt.Sprintf("Health check exited with status %d", exitCode)
+	}
+
+	healthLock.Lock()
+	health, ok := healthStates[c.Name()]
+	if !ok {
+		// The container went away while being probed.
+		healthLock.Unlock()
+		return
+	}
+
+	if result.Status == "unhealthy" {
+		result.Failures = health.state.Failures + 1
+	} else {
+		health.actions = 0
+	}
+
+	previous := health.state.Status
+	health.state = result
+
+	// Keep the container marked as being probed while the action runs.
+	action := ""
+	if result.Status == "unhealthy" {
+		action = containerSupervisorAction(c.ExpandedConfig(), result.Failures)
+	}
+	health.probing = action != ""
+	healthLock.Unlock()
+
+	if previous != result.Status {
+		containerHealthChanged(c, previous, result)
+	}
+
+	if action == "" {
+		return
+	}
+
+	err = containerSupervise(c, action, result.Failures)
+	if err != nil {
+		logger.Error("Failed to apply health check action", log.Ctx{"container": c.Name(), "action": action, "err": err})
+	}
+
+	// Start over with a fresh state, as after a start of the container,
+	// waiting longer and longer before probing a container which doesn't
+	// recover, so that it isn't restarted in a tight loop.
+	healthLock.Lock()
+	health, ok = healthStates[c.Name()]
+	if ok {
+		health.actions++
+		health.state = api.ContainerStateHealth{Status: "starting"}
+		health.next = time.Now().Add(containerHealthBackoff(containerHealthInterval(c), health.actions))
+		health.probing = false
+	}
+	healthLock.Unlock()
+}
+
+// Return how long to wait before probing a container again after the given
+// number of consecutive actions, doubling the interval after each one, up to
+// an hour.
+func containerHealthBackoff(interval time.Duration, actions int) time.Duration {
+	delay := interval
+	for i := 1; i < actions; i++ {
+		if delay*2 > time.Hour {
+			if delay < time.Hour {
+				delay = time.Hour
+			}
+
+			break
+		}
+
+		delay *= 2
+	}
+
+	return delay
+}
+
+func containerHealthChanged(c container, previous string, result api.ContainerStateHealth) {
+	logger.Info("Container health changed", log.Ctx{"container": c.Name(), "status": result.Status, "previous": previous})
+
+	eventSendLifecycle("container-health-changed",
+		fmt.Sprintf("/1.0/containers/%s", c.Name()),
+		map[string]interface{}{
+			"status":    result.Status,
+			"previous":  previous,
+			"exit_code": result.ExitCode,
+			"message":   result.Message,
+		})
+}
+
+// Run the given shell command in the container as root and return its exit
+// code. A probe which doesn't complete within the timeout is killed, along
+// with its process group, and reported as failed.
+func containerHealthProbe(c container, command string, timeout time.Duration) (int, error) {
+	env := containerExecEnvironment(c, nil, nil, 0)
+
+	cmd, pid, _, err := c.Exec([]string{"/bin/sh", "-c", command}, env, nil, nil, nil, false, "/", 0, 0, nil)
+	if err != nil {
+		return -1, err
+	}
+
+	type waitResult struct {
+		exitCode int
+		err      error
+	}
+
+	done := make(chan waitResult, 1)
+	go func() {
+		exitCode, err := cmd.Wait()
+		done <- waitResult{exitCode: exitCode, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.exitCode, result.err
+	case <-time.After(timeout):
+	}
+
+	if pid > 0 {
+		syscall.Kill(-pid, syscall.SIGKILL)
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		logger.Warn("Timed out health check is still running", log.Ctx{"container": c.Name(), "pid": pid})
+	}
+
+	return -1, fmt.Errorf("Health check timed out after %s", timeout)
+}
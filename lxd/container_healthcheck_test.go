@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The delay before probing a container again doubles with each action, up to
+// an hour.
+func TestContainerHealthBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		7:  32 * time.Minute,
+		8:  time.Hour,
+		50: time.Hour,
+	}
+
+	for actions, delay := range cases {
+		assert.Equal(t, delay, containerHealthBackoff(30*time.Second, actions), "%d actions", actions)
+	}
+
+	assert.Equal(t, 2*time.Hour, containerHealthBackoff(2*time.Hour, 3))
+}
+
+// The action is only applied after healthcheck.retries consecutive failures.
+func TestContainerSupervisorAction(t *testing.T) {
+	config := map[string]string{"healthcheck.action": "restart"}
+	assert.Equal(t, "", containerSupervisorAction(config, 2))
+	assert.Equal(t, "restart", containerSupervisorAction(config, 3))
+
+	config["healthcheck.retries"] = "1"
+	assert.Equal(t, "restart", containerSupervisorAction(config, 1))
+
+	config["healthcheck.action"] = "none"
+	assert.Equal(t, "", containerSupervisorAction(config, 5))
+}
+
+// The cached list of checked containers is used until it's invalidated or
+// gets too old.
+func TestContainerHealthLoad_Cache(t *testing.T) {
+	defer containerHealthInvalidate()
+
+	healthContainers = map[string]bool{}
+	healthContainersLoaded = time.Now()
+
+	// With a fresh cache the database isn't touched at all.
+	containers, err := containerHealthLoad(nil)
+	require.NoError(t, err)
+	assert.Len(t, containers, 0)
+	assert.NotNil(t, healthContainers)
+
+	containerHealthInvalidate()
+	assert.Nil(t, healthContainers)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// How long a hook may run before being killed, so that a hanging script
+// doesn't block the container's life cycle.
+const containerHookTimeout = time.Minute
+
+// Run the host-side script configured through the "hooks.<hook>" key of the
+// container, if any, passing the details of the container along with the
+// given extra variables in its environment.
+func (c *containerLXC) runHook(hook string, env map[string]string) error {
+	path := c.expandedConfig[fmt.Sprintf("hooks.%s", hook)]
+	if path == "" {
+		return nil
+	}
+
+	vars := append(os.Environ(),
+		fmt.Sprintf("LXD_HOOK=%s", hook),
+		fmt.Sprintf("LXD_CONTAINER_NAME=%s", c.name),
+		fmt.Sprintf("LXD_CONTAINER_PATH=%s", c.Path()),
+		fmt.Sprintf("LXD_CONTAINER_ROOTFS=%s", c.RootfsPath()))
+
+	for k, v := range env {
+		vars = append(vars, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return containerHookRun(hook, path, vars, containerHookTimeout)
+}
+
+// Run the given hook script with the given environment, killing it along
+// with anything it spawned if it's still running after the given timeout.
+func containerHookRun(hook string, path string, env []string, timeout time.Duration) error {
+	var output bytes.Buffer
+
+	cmd := exec.Command(path)
+	cmd.Env = env
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("Failed to run the %s hook \"%s\": %v", hook, path, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return fmt.Errorf("The %s hook \"%s\" timed out after %s", hook, path, timeout)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to run the %s hook \"%s\": %v: %s", hook, path, err, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Hooks get the given environment, their output is reported when they fail
+// and they're killed when running for too long.
+func TestContainerHookRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-hooks-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	script := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755))
+		return path
+	}
+
+	ok := script("ok", "test \"$LXD_HOOK\" = pre-start\n")
+	assert.NoError(t, containerHookRun("pre-start", ok, []string{"LXD_HOOK=pre-start"}, time.Minute))
+
+	failing := script("failing", "echo broken\nexit 1\n")
+	err = containerHookRun("pre-start", failing, nil, time.Minute)
+	assert.EqualError(t, err, "Failed to run the pre-start hook \""+failing+"\": exit status 1: broken")
+
+	hanging := script("hanging", "sleep 60 &\nsleep 60\n")
+	start := time.Now()
+	err = containerHookRun("pre-start", hanging, nil, 100*time.Millisecond)
+	assert.EqualError(t, err, "The pre-start hook \""+hanging+"\" timed out after 100ms")
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
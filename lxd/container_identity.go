@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Return a copy of the given device of a copied container without its MAC
+// address, so that a new one gets generated on start.
+func containerRegenerateDevice(m map[string]string) map[string]string {
+	device := map[string]string{}
+	for k, v := range m {
+		if k == "hwaddr" && shared.StringInSlice(m["type"], []string{"nic", "infiniband"}) {
+			continue
+		}
+
+		device[k] = v
+	}
+
+	return device
+}
+
+// Reset the identity stored in the root filesystem of a freshly copied
+// container so that it doesn't collide with its source on the network. The
+// machine-id is emptied so that it's regenerated on boot, the SSH host keys
+// are removed so that they're regenerated by the image's "copy" templates or
+// the distribution, and the hostname is set to the new container name.
+func containerRegenerateIdentity(c container) error {
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	rootfs, err := filepath.EvalSymlinks(c.RootfsPath())
+	if err != nil {
+		return err
+	}
+
+	err = containerRootfsFileTruncate(rootfs, "/etc/machine-id", "")
+	if err != nil {
+		return err
+	}
+
+	err = containerRootfsFileTruncate(rootfs, "/etc/hostname", fmt.Sprintf("%s\n", c.Name()))
+	if err != nil {
+		return err
+	}
+
+	sshPath, err := containerRootfsPath(rootfs, "/etc/ssh")
+	if err != nil || !shared.PathExists(sshPath) {
+		return nil
+	}
+
+	keys, err := filepath.Glob(filepath.Join(sshPath, "ssh_host_*"))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		fi, err := os.Lstat(key)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+
+		err = os.Remove(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resolve the given path inside the root filesystem of a container. Every
+// component is resolved relative to the root filesystem, as if chrooted into
+// it: absolute symlinks start over from its root and ".." never goes above it.
+func containerRootfsPath(rootfs string, path string) (string, error) {
+	current := "/"
+	parts := strings.Split(path, "/")
+	links := 0
+
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+			continue
+		}
+
+		next := filepath.Join(current, part)
+		fi, err := os.Lstat(filepath.Join(rootfs, next))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		links++
+		if links > 40 {
+			return "", fmt.Errorf("Too many levels of symbolic links: %s", path)
+		}
+
+		target, err := os.Readlink(filepath.Join(rootfs, next))
+		if err != nil {
+			return "", err
+		}
+
+		if filepath.IsAbs(target) {
+			current = "/"
+		}
+
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+
+	return filepath.Join(rootfs, current), nil
+}
+
+// Replace the content of the given regular file of the container, if it
+// exists, keeping its ownership and permissions.
+func containerRootfsFileTruncate(rootfs string, path string, content string) error {
+	fullPath, err := containerRootfsPath(rootfs, path)
+	if err != nil {
+		return nil
+	}
+
+	fi, err := os.Lstat(fullPath)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_TRUNC|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Symlinks in the root filesystem are resolved relative to it, whatever
+// component they're on.
+func TestContainerRootfsPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-rootfs-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rootfs := filepath.Join(dir, "rootfs")
+	require.NoError(t, os.MkdirAll(filepath.Join(rootfs, "etc", "real"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "host"), 0755))
+
+	require.NoError(t, os.Symlink(filepath.Join(dir, "host"), filepath.Join(rootfs, "etc", "ssh")))
+	require.NoError(t, os.Symlink("../../../host", filepath.Join(rootfs, "etc", "up")))
+	require.NoError(t, os.Symlink("real", filepath.Join(rootfs, "etc", "rel")))
+
+	cases := map[string]string{
+		"/etc/ssh":       filepath.Join(rootfs, dir, "host"),
+		"/etc/up":        filepath.Join(rootfs, "host"),
+		"/etc/rel":       filepath.Join(rootfs, "etc", "real"),
+		"/etc/rel/file":  filepath.Join(rootfs, "etc", "real", "file"),
+		"/../etc/hosts":  filepath.Join(rootfs, "etc", "hosts"),
+		"/etc/missing/x": filepath.Join(rootfs, "etc", "missing", "x"),
+	}
+
+	for path, expected := range cases {
+		resolved, err := containerRootfsPath(rootfs, path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, resolved, path)
+	}
+
+	require.NoError(t, os.Symlink("loop", filepath.Join(rootfs, "etc", "loop")))
+	_, err = containerRootfsPath(rootfs, "/etc/loop")
+	assert.Error(t, err)
+}
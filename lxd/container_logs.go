@@ -65,6 +65,7 @@ func validLogFileName(fname string) bool {
 	 */
 	return fname == "lxc.log" ||
 		fname == "lxc.conf" ||
+		fname == "run_once.log" ||
 		strings.HasPrefix(fname, "migration_") ||
 		strings.HasPrefix(fname, "snapshot_") ||
 		strings.HasPrefix(fname, "exec_")
@@ -84,6 +84,28 @@ func (op *lxcContainerOperation) Reset() error {
 	return nil
 }
 
+// KeepAlive resets the timeout of the operation until it's done or stop gets
+// closed, for operations which may legitimately take longer than that.
+func (op *lxcContainerOperation) KeepAlive(stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-op.chanDone:
+			return
+		case <-time.After(time.Second * 10):
+		}
+
+		select {
+		case op.chanReset <- true:
+		case <-stop:
+			return
+		case <-op.chanDone:
+			return
+		}
+	}
+}
+
 func (op *lxcContainerOperation) Wait() error {
 	<-op.chanDone
 
@@ -288,7 +310,7 @@ func lxcStatusCode(state lxc.State) api.StatusCode {
 }
 
 // Loader functions
-func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error) {
+func containerLXCCreate(s *state.State, args db.ContainerArgs, profileConfigs []map[string]string, profileDevices []types.Devices) (container, error) {
 	// Create the container struct
 	c := &containerLXC{
 		state:        s,
@@ -311,22 +333,27 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 
 	logger.Info("Creating container", ctxMap)
 
-	// Load the config
-	err := c.init()
-	if err != nil {
-		c.Delete()
-		logger.Error("Failed creating container", ctxMap)
-		return nil, err
-	}
+	// Expand the config using the given profiles
+	c.expandConfigFromProfiles(profileConfigs)
+	c.expandDevicesFromProfiles(profileDevices)
 
 	// Validate expanded config
-	err = containerValidConfig(s.OS, c.expandedConfig, false, true)
+	err := containerValidConfig(s.OS, c.expandedConfig, false, true)
 	if err != nil {
 		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
 		return nil, err
 	}
 
+	if c.expandedConfig["boot.depends_on"] != "" && !c.IsSnapshot() {
+		err = containerValidDependencies(s, c.name, c.expandedConfig["boot.depends_on"])
+		if err != nil {
+			c.Delete()
+			logger.Error("Failed creating container", ctxMap)
+			return nil, err
+		}
+	}
+
 	err = containerValidDevices(s.Cluster, c.expandedDevices, false, true)
 	if err != nil {
 		c.Delete()
@@ -461,6 +488,7 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	networkUpdateStatic(s, "")
 
 	logger.Info("Created container", ctxMap)
+	containerHealthInvalidate()
 	eventSendLifecycle("container-created",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
 
@@ -548,6 +576,9 @@ type containerLXC struct {
 
 	// Progress tracking
 	op *operation
+
+	// Set while reverting a failed update
+	updateReverting bool
 }
 
 func (c *containerLXC) createOperation(action string, reusable bool, reuse bool) (*lxcContainerOperation, error) {
@@ -631,6 +662,14 @@ func idmapSize(state *state.State, isolatedStr string, size string) (int64, erro
 
 var idmapLock sync.Mutex
 
+// idmapRelease drops the idmap range recorded for the given container, once
+// it no longer uses an isolated map.
+func idmapRelease(state *state.State, cName string) error {
+	return state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ContainerIdmapRemove(cName)
+	})
+}
+
 func parseRawIdmap(value string) ([]idmap.IdmapEntry, error) {
 	getRange := func(r string) (int64, int64, error) {
 		entries := strings.Split(r, "-")
@@ -717,6 +756,24 @@ func parseRawIdmap(value string) ([]idmap.IdmapEntry, error) {
 	return ret.Idmap, nil
 }
 
+// idmapIsolated returns the idmap of an isolated container whose allocation
+// starts at the given host id, with the raw.idmap entries applied on top.
+func idmapIsolated(offset int64, size int64, rawMaps []idmap.IdmapEntry) (*idmap.IdmapSet, error) {
+	set := &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{
+		{Isuid: true, Nsid: 0, Hostid: offset, Maprange: size},
+		{Isgid: true, Nsid: 0, Hostid: offset, Maprange: size},
+	}}
+
+	for _, ent := range rawMaps {
+		err := set.AddSafe(ent)
+		if err != nil && err == idmap.ErrHostIdIsSubId {
+			return nil, err
+		}
+	}
+
+	return set, nil
+}
+
 func findIdmap(state *state.State, cName string, isolatedStr string, configBase string, configSize string, rawIdmap string) (*idmap.IdmapSet, int64, error) {
 	isolated := false
 	if shared.IsTrue(isolatedStr) {
@@ -739,6 +796,11 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 			}
 		}
 
+		err = idmapRelease(state, cName)
+		if err != nil {
+			return nil, 0, err
+		}
+
 		return &newIdmapset, 0, nil
 	}
 
@@ -747,17 +809,22 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 		return nil, 0, err
 	}
 
+	idmapLock.Lock()
+	defer idmapLock.Unlock()
+
+	// Record the allocation before releasing the lock, so that containers
+	// created concurrently don't get handed the same range.
 	mkIdmap := func(offset int64, size int64) (*idmap.IdmapSet, error) {
-		set := &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{
-			{Isuid: true, Nsid: 0, Hostid: offset, Maprange: size},
-			{Isgid: true, Nsid: 0, Hostid: offset, Maprange: size},
-		}}
+		set, err := idmapIsolated(offset, size, rawMaps)
+		if err != nil {
+			return nil, err
+		}
 
-		for _, ent := range rawMaps {
-			err := set.AddSafe(ent)
-			if err != nil && err == idmap.ErrHostIdIsSubId {
-				return nil, err
-			}
+		err = state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.ContainerIdmapSet(cName, offset, size)
+		})
+		if err != nil {
+			return nil, err
 		}
 
 		return set, nil
@@ -770,17 +837,19 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 		}
 
 		set, err := mkIdmap(offset, size)
-		if err != nil && err == idmap.ErrHostIdIsSubId {
+		if err != nil {
 			return nil, 0, err
 		}
 
 		return set, offset, nil
 	}
 
-	idmapLock.Lock()
-	defer idmapLock.Unlock()
-
-	cs, err := state.Cluster.ContainersList(db.CTypeRegular)
+	var allocations []db.ContainerIdmap
+	err = state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		allocations, err = tx.ContainerIdmaps()
+		return err
+	})
 	if err != nil {
 		return nil, 0, err
 	}
@@ -788,39 +857,13 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 	offset := state.OS.IdmapSet.Idmap[0].Hostid + 65536
 
 	mapentries := idmap.ByHostid{}
-	for _, name := range cs {
+	for _, allocation := range allocations {
 		/* Don't change our map Just Because. */
-		if name == cName {
+		if allocation.Container == cName {
 			continue
 		}
 
-		container, err := containerLoadByName(state, name)
-		if err != nil {
-			return nil, 0, err
-		}
-
-		if container.IsPrivileged() {
-			continue
-		}
-
-		if !shared.IsTrue(container.ExpandedConfig()["security.idmap.isolated"]) {
-			continue
-		}
-
-		cBase := int64(0)
-		if container.ExpandedConfig()["volatile.idmap.base"] != "" {
-			cBase, err = strconv.ParseInt(container.ExpandedConfig()["volatile.idmap.base"], 10, 64)
-			if err != nil {
-				return nil, 0, err
-			}
-		}
-
-		cSize, err := idmapSize(state, container.ExpandedConfig()["security.idmap.isolated"], container.ExpandedConfig()["security.idmap.size"])
-		if err != nil {
-			return nil, 0, err
-		}
-
-		mapentries = append(mapentries, &idmap.IdmapEntry{Hostid: int64(cBase), Maprange: cSize})
+		mapentries = append(mapentries, &idmap.IdmapEntry{Hostid: allocation.Base, Maprange: allocation.Size})
 	}
 
 	sort.Sort(mapentries)
@@ -833,7 +876,7 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 			}
 
 			set, err := mkIdmap(offset, size)
-			if err != nil && err == idmap.ErrHostIdIsSubId {
+			if err != nil {
 				return nil, 0, err
 			}
 
@@ -848,7 +891,7 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 		offset = mapentries[i-1].Hostid + mapentries[i-1].Maprange
 		if offset+size < mapentries[i].Hostid {
 			set, err := mkIdmap(offset, size)
-			if err != nil && err == idmap.ErrHostIdIsSubId {
+			if err != nil {
 				return nil, 0, err
 			}
 
@@ -859,7 +902,7 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 
 	if offset+size < state.OS.IdmapSet.Idmap[0].Hostid+state.OS.IdmapSet.Idmap[0].Maprange {
 		set, err := mkIdmap(offset, size)
-		if err != nil && err == idmap.ErrHostIdIsSubId {
+		if err != nil {
 			return nil, 0, err
 		}
 
@@ -997,6 +1040,15 @@ func (c *containerLXC) initLXC(config bool) error {
 		return err
 	}
 
+	// Containers requesting the fuse or tun devices fail to start without
+	// them, others get them when the host has them
+	requiredMounts := []string{}
+	for _, dev := range containerSecurityDevices {
+		if shared.IsTrue(c.expandedConfig[dev.key]) {
+			requiredMounts = append(requiredMounts, dev.path)
+		}
+	}
+
 	bindMounts := []string{
 		"/dev/fuse",
 		"/dev/net/tun",
@@ -1007,6 +1059,11 @@ func (c *containerLXC) initLXC(config bool) error {
 		"/sys/kernel/debug",
 		"/sys/kernel/security"}
 
+	// Let ZFS tools inside the container manage its delegated dataset
+	if shared.IsTrue(c.expandedConfig["zfs.delegate"]) {
+		bindMounts = append(bindMounts, "/dev/zfs")
+	}
+
 	if c.IsPrivileged() && !c.state.OS.RunningInUserNS {
 		err = lxcSetConfigItem(cc, "lxc.mount.entry", "mqueue dev/mqueue mqueue rw,relatime,create=dir,optional")
 		if err != nil {
@@ -1016,8 +1073,15 @@ func (c *containerLXC) initLXC(config bool) error {
 		bindMounts = append(bindMounts, "/dev/mqueue")
 	}
 
+	for _, mnt := range requiredMounts {
+		err = lxcSetConfigItem(cc, "lxc.mount.entry", fmt.Sprintf("%s %s none bind,create=file", mnt, strings.TrimPrefix(mnt, "/")))
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, mnt := range bindMounts {
-		if !shared.PathExists(mnt) {
+		if !shared.PathExists(mnt) || shared.StringInSlice(mnt, requiredMounts) {
 			continue
 		}
 
@@ -1040,16 +1104,46 @@ func (c *containerLXC) initLXC(config bool) error {
 		templateConfDir = "/usr/share/lxc/config"
 	}
 
-	if shared.PathExists(fmt.Sprintf("%s/common.conf.d/", templateConfDir)) {
+	if lxcfsManaged(c.expandedConfig) {
+		// Include everything but the lxcfs hook and mount the
+		// requested lxcfs files directly
+		if shared.PathExists(fmt.Sprintf("%s/common.conf.d/", templateConfDir)) {
+			includes, err := lxcfsIncludes(fmt.Sprintf("%s/common.conf.d/", templateConfDir))
+			if err != nil {
+				return err
+			}
+
+			for _, include := range includes {
+				err = lxcSetConfigItem(cc, "lxc.include", include)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		entries, err := lxcfsMountEntries(c.expandedConfig)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			err = lxcSetConfigItem(cc, "lxc.mount.entry", entry)
+			if err != nil {
+				return err
+			}
+		}
+	} else if shared.PathExists(fmt.Sprintf("%s/common.conf.d/", templateConfDir)) {
 		err = lxcSetConfigItem(cc, "lxc.include", fmt.Sprintf("%s/common.conf.d/", templateConfDir))
 		if err != nil {
 			return err
 		}
 	}
 
+	cgroupPrefix := cgroupConfigPrefix(c.state.OS)
+
 	// Configure devices cgroup
 	if c.IsPrivileged() && !c.state.OS.RunningInUserNS && c.state.OS.CGroupDevicesController {
-		err = lxcSetConfigItem(cc, "lxc.cgroup.devices.deny", "a")
+		err = lxcSetConfigItem(cc, cgroupPrefix+"devices.deny", "a")
 		if err != nil {
 			return err
 		}
@@ -1071,7 +1165,19 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 
 		for _, dev := range devices {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.devices.allow", dev)
+			err = lxcSetConfigItem(cc, cgroupPrefix+"devices.allow", dev)
+			if err != nil {
+				return err
+			}
+		}
+
+		if shared.IsTrue(c.expandedConfig["zfs.delegate"]) && shared.PathExists("/dev/zfs") {
+			_, major, minor, err := deviceGetAttributes("/dev/zfs")
+			if err != nil {
+				return err
+			}
+
+			err = lxcSetConfigItem(cc, cgroupPrefix+"devices.allow", fmt.Sprintf("c %d:%d rwm", major, minor))
 			if err != nil {
 				return err
 			}
@@ -1240,75 +1346,13 @@ func (c *containerLXC) initLXC(config bool) error {
 
 	// Memory limits
 	if c.state.OS.CGroupMemoryController {
-		memory := c.expandedConfig["limits.memory"]
-		memoryEnforce := c.expandedConfig["limits.memory.enforce"]
-		memorySwap := c.expandedConfig["limits.memory.swap"]
-		memorySwapPriority := c.expandedConfig["limits.memory.swap.priority"]
-
-		// Configure the memory limits
-		if memory != "" {
-			var valueInt int64
-			if strings.HasSuffix(memory, "%") {
-				percent, err := strconv.ParseInt(strings.TrimSuffix(memory, "%"), 10, 64)
-				if err != nil {
-					return err
-				}
-
-				memoryTotal, err := shared.DeviceTotalMemory()
-				if err != nil {
-					return err
-				}
-
-				valueInt = int64((memoryTotal / 100) * percent)
-			} else {
-				valueInt, err = shared.ParseByteSizeString(memory)
-				if err != nil {
-					return err
-				}
-			}
-
-			if memoryEnforce == "soft" {
-				err = lxcSetConfigItem(cc, "lxc.cgroup.memory.soft_limit_in_bytes", fmt.Sprintf("%d", valueInt))
-				if err != nil {
-					return err
-				}
-			} else {
-				if c.state.OS.CGroupSwapAccounting && (memorySwap == "" || shared.IsTrue(memorySwap)) {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.memory.limit_in_bytes", fmt.Sprintf("%d", valueInt))
-					if err != nil {
-						return err
-					}
-					err = lxcSetConfigItem(cc, "lxc.cgroup.memory.memsw.limit_in_bytes", fmt.Sprintf("%d", valueInt))
-					if err != nil {
-						return err
-					}
-				} else {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.memory.limit_in_bytes", fmt.Sprintf("%d", valueInt))
-					if err != nil {
-						return err
-					}
-				}
-				// Set soft limit to value 10% less than hard limit
-				err = lxcSetConfigItem(cc, "lxc.cgroup.memory.soft_limit_in_bytes", fmt.Sprintf("%.0f", float64(valueInt)*0.9))
-				if err != nil {
-					return err
-				}
-			}
+		settings, err := containerMemorySettings(c.state.OS, c.expandedConfig)
+		if err != nil {
+			return err
 		}
 
-		// Configure the swappiness
-		if memorySwap != "" && !shared.IsTrue(memorySwap) {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.memory.swappiness", "0")
-			if err != nil {
-				return err
-			}
-		} else if memorySwapPriority != "" {
-			priority, err := strconv.Atoi(memorySwapPriority)
-			if err != nil {
-				return err
-			}
-
-			err = lxcSetConfigItem(cc, "lxc.cgroup.memory.swappiness", fmt.Sprintf("%d", 60-10+priority))
+		for _, setting := range settings {
+			err = lxcSetConfigItem(cc, cgroupPrefix+setting.key, setting.value)
 			if err != nil {
 				return err
 			}
@@ -1320,27 +1364,13 @@ func (c *containerLXC) initLXC(config bool) error {
 	cpuAllowance := c.expandedConfig["limits.cpu.allowance"]
 
 	if (cpuPriority != "" || cpuAllowance != "") && c.state.OS.CGroupCPUController {
-		cpuShares, cpuCfsQuota, cpuCfsPeriod, err := deviceParseCPU(cpuAllowance, cpuPriority)
+		settings, err := containerCPUSettings(c.state.OS, c.expandedConfig)
 		if err != nil {
 			return err
 		}
 
-		if cpuShares != "1024" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.shares", cpuShares)
-			if err != nil {
-				return err
-			}
-		}
-
-		if cpuCfsPeriod != "-1" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_period_us", cpuCfsPeriod)
-			if err != nil {
-				return err
-			}
-		}
-
-		if cpuCfsQuota != "-1" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_quota_us", cpuCfsQuota)
+		for _, setting := range settings {
+			err = lxcSetConfigItem(cc, cgroupPrefix+setting.key, setting.value)
 			if err != nil {
 				return err
 			}
@@ -1350,19 +1380,14 @@ func (c *containerLXC) initLXC(config bool) error {
 	// Disk limits
 	if c.state.OS.CGroupBlkioController {
 		diskPriority := c.expandedConfig["limits.disk.priority"]
-		if diskPriority != "" {
-			priorityInt, err := strconv.Atoi(diskPriority)
+		if diskPriority != "" && c.state.OS.CGroupBlkioWeight {
+			weight, err := deviceParseDiskPriority(diskPriority)
 			if err != nil {
 				return err
 			}
 
-			// Minimum valid value is 10
-			priority := priorityInt * 100
-			if priority == 0 {
-				priority = 10
-			}
-
-			err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.weight", fmt.Sprintf("%d", priority))
+			setting := containerDiskWeightSetting(c.state.OS, weight)
+			err = lxcSetConfigItem(cc, cgroupPrefix+setting.key, setting.value)
 			if err != nil {
 				return err
 			}
@@ -1387,33 +1412,10 @@ func (c *containerLXC) initLXC(config bool) error {
 				return err
 			}
 
-			for block, limit := range diskLimits {
-				if limit.readBps > 0 {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.throttle.read_bps_device", fmt.Sprintf("%s %d", block, limit.readBps))
-					if err != nil {
-						return err
-					}
-				}
-
-				if limit.readIops > 0 {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.throttle.read_iops_device", fmt.Sprintf("%s %d", block, limit.readIops))
-					if err != nil {
-						return err
-					}
-				}
-
-				if limit.writeBps > 0 {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.throttle.write_bps_device", fmt.Sprintf("%s %d", block, limit.writeBps))
-					if err != nil {
-						return err
-					}
-				}
-
-				if limit.writeIops > 0 {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.throttle.write_iops_device", fmt.Sprintf("%s %d", block, limit.writeIops))
-					if err != nil {
-						return err
-					}
+			for _, setting := range containerDiskLimitSettings(c.state.OS, diskLimits) {
+				err = lxcSetConfigItem(cc, cgroupPrefix+setting.key, setting.value)
+				if err != nil {
+					return err
 				}
 			}
 		}
@@ -1428,7 +1430,7 @@ func (c *containerLXC) initLXC(config bool) error {
 				return err
 			}
 
-			err = lxcSetConfigItem(cc, "lxc.cgroup.pids.max", fmt.Sprintf("%d", valueInt))
+			err = lxcSetConfigItem(cc, cgroupPrefix+"pids.max", fmt.Sprintf("%d", valueInt))
 			if err != nil {
 				return err
 			}
@@ -1448,250 +1450,42 @@ func (c *containerLXC) initLXC(config bool) error {
 	}
 
 	// Setup devices
-	networkidx := 0
 	for _, k := range c.expandedDevices.DeviceNames() {
-		m := c.expandedDevices[k]
-		if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
-			// destination paths
-			destPath := m["path"]
-			if destPath == "" {
-				destPath = m["source"]
-			}
+		m, err := c.deviceResolveBlockVolume(c.expandedDevices[k])
+		if err != nil {
+			return err
+		}
 
-			srcPath := m["source"]
-			if srcPath == "" {
-				srcPath = m["path"]
-			}
+		driver, err := deviceDriverLoad(m["type"])
+		if err != nil {
+			return err
+		}
 
-			relativeDestPath := strings.TrimPrefix(destPath, "/")
-			sourceDevPath := filepath.Join(c.DevicesPath(), fmt.Sprintf("unix.%s.%s", strings.Replace(k, "/", "-", -1), strings.Replace(relativeDestPath, "/", "-", -1)))
+		err = driver.Config(c, cc, k, m)
+		if err != nil {
+			return err
+		}
+	}
 
-			// Don't add mount entry for devices that don't yet exist
-			if m["required"] != "" && !shared.IsTrue(m["required"]) && srcPath != "" && !shared.PathExists(srcPath) {
-				continue
-			}
+	// Setup shmounts
+	err = lxcSetConfigItem(cc, "lxc.mount.entry", fmt.Sprintf("%s dev/.lxd-mounts none bind,create=dir 0 0", shared.VarPath("shmounts", c.Name())))
+	if err != nil {
+		return err
+	}
 
-			// inform liblxc about the mount
-			err = lxcSetConfigItem(cc, "lxc.mount.entry",
-				fmt.Sprintf("%s %s %s",
-					shared.EscapePathFstab(sourceDevPath),
-					shared.EscapePathFstab(relativeDestPath),
-					"none bind,create=file"))
-			if err != nil {
-				return err
-			}
-		} else if m["type"] == "nic" || m["type"] == "infiniband" {
-			// Fill in some fields from volatile
-			m, err = c.fillNetworkDevice(k, m)
-			if err != nil {
-				return err
-			}
+	// Apply raw.lxc
+	if lxcConfig, ok := c.expandedConfig["raw.lxc"]; ok {
+		f, err := ioutil.TempFile("", "lxd_config_")
+		if err != nil {
+			return err
+		}
 
-			networkKeyPrefix := "lxc.net"
-			if !util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
-				networkKeyPrefix = "lxc.network"
-			}
-
-			// Interface type specific configuration
-			if shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"}) {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.type", networkKeyPrefix, networkidx), "veth")
-				if err != nil {
-					return err
-				}
-			} else if m["nictype"] == "physical" || m["nictype"] == "sriov" {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.type", networkKeyPrefix, networkidx), "phys")
-				if err != nil {
-					return err
-				}
-			} else if m["nictype"] == "macvlan" {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.type", networkKeyPrefix, networkidx), "macvlan")
-				if err != nil {
-					return err
-				}
-
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.macvlan.mode", networkKeyPrefix, networkidx), "bridge")
-				if err != nil {
-					return err
-				}
-			}
-
-			err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.flags", networkKeyPrefix, networkidx), "up")
-			if err != nil {
-				return err
-			}
-
-			if m["nictype"] == "bridged" {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.link", networkKeyPrefix, networkidx), m["parent"])
-				if err != nil {
-					return err
-				}
-			} else if m["nictype"] == "sriov" {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.link", networkKeyPrefix, networkidx), m["host_name"])
-				if err != nil {
-					return err
-				}
-			} else if shared.StringInSlice(m["nictype"], []string{"macvlan", "physical"}) {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.link", networkKeyPrefix, networkidx), networkGetHostDevice(m["parent"], m["vlan"]))
-				if err != nil {
-					return err
-				}
-			}
-
-			// Host Virtual NIC name
-			vethName := ""
-			if m["host_name"] != "" && m["nictype"] != "sriov" {
-				vethName = m["host_name"]
-			} else if shared.IsTrue(m["security.mac_filtering"]) {
-				// We need a known device name for MAC filtering
-				vethName = deviceNextVeth()
-			}
-
-			if vethName != "" {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.veth.pair", networkKeyPrefix, networkidx), vethName)
-				if err != nil {
-					return err
-				}
-			}
-
-			// MAC address
-			if m["hwaddr"] != "" {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.hwaddr", networkKeyPrefix, networkidx), m["hwaddr"])
-				if err != nil {
-					return err
-				}
-			}
-
-			// MTU
-			if m["mtu"] != "" {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.mtu", networkKeyPrefix, networkidx), m["mtu"])
-				if err != nil {
-					return err
-				}
-			}
-
-			// Name
-			if m["name"] != "" {
-				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.name", networkKeyPrefix, networkidx), m["name"])
-				if err != nil {
-					return err
-				}
-			}
-
-			// bump network index
-			networkidx++
-		} else if m["type"] == "disk" {
-			isRootfs := shared.IsRootDiskDevice(m)
-
-			// source paths
-			srcPath := shared.HostPath(m["source"])
-
-			// destination paths
-			destPath := m["path"]
-			relativeDestPath := strings.TrimPrefix(destPath, "/")
-
-			sourceDevPath := filepath.Join(c.DevicesPath(), fmt.Sprintf("disk.%s.%s", strings.Replace(k, "/", "-", -1), strings.Replace(relativeDestPath, "/", "-", -1)))
-
-			// Various option checks
-			isOptional := shared.IsTrue(m["optional"])
-			isReadOnly := shared.IsTrue(m["readonly"])
-			isRecursive := shared.IsTrue(m["recursive"])
-
-			// If we want to mount a storage volume from a storage
-			// pool we created via our storage api, we are always
-			// mounting a directory.
-			isFile := false
-			if m["pool"] == "" {
-				isFile = !shared.IsDir(srcPath) && !deviceIsBlockdev(srcPath)
-			}
-
-			// Deal with a rootfs
-			if isRootfs {
-				if !util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
-					// Set the rootfs backend type if supported (must happen before any other lxc.rootfs)
-					err := lxcSetConfigItem(cc, "lxc.rootfs.backend", "dir")
-					if err == nil {
-						value := cc.ConfigItem("lxc.rootfs.backend")
-						if len(value) == 0 || value[0] != "dir" {
-							lxcSetConfigItem(cc, "lxc.rootfs.backend", "")
-						}
-					}
-				}
-
-				// Set the rootfs path
-				if util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
-					rootfsPath := fmt.Sprintf("dir:%s", c.RootfsPath())
-					err = lxcSetConfigItem(cc, "lxc.rootfs.path", rootfsPath)
-				} else {
-					rootfsPath := c.RootfsPath()
-					err = lxcSetConfigItem(cc, "lxc.rootfs", rootfsPath)
-				}
-				if err != nil {
-					return err
-				}
-
-				// Read-only rootfs (unlikely to work very well)
-				if isReadOnly {
-					err = lxcSetConfigItem(cc, "lxc.rootfs.options", "ro")
-					if err != nil {
-						return err
-					}
-				}
-			} else {
-				rbind := ""
-				options := []string{}
-				if isReadOnly {
-					options = append(options, "ro")
-				}
-
-				if isOptional {
-					options = append(options, "optional")
-				}
-
-				if isRecursive {
-					rbind = "r"
-				}
-
-				if m["propagation"] != "" {
-					options = append(options, m["propagation"])
-				}
-
-				if isFile {
-					options = append(options, "create=file")
-				} else {
-					options = append(options, "create=dir")
-				}
-
-				err = lxcSetConfigItem(cc, "lxc.mount.entry",
-					fmt.Sprintf("%s %s none %sbind,%s",
-						shared.EscapePathFstab(sourceDevPath),
-						shared.EscapePathFstab(relativeDestPath), rbind,
-						strings.Join(options, ",")))
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-
-	// Setup shmounts
-	err = lxcSetConfigItem(cc, "lxc.mount.entry", fmt.Sprintf("%s dev/.lxd-mounts none bind,create=dir 0 0", shared.VarPath("shmounts", c.Name())))
-	if err != nil {
-		return err
-	}
-
-	// Apply raw.lxc
-	if lxcConfig, ok := c.expandedConfig["raw.lxc"]; ok {
-		f, err := ioutil.TempFile("", "lxd_config_")
-		if err != nil {
-			return err
-		}
-
-		err = shared.WriteAll(f, []byte(lxcConfig))
-		f.Close()
-		defer os.Remove(f.Name())
-		if err != nil {
-			return err
-		}
+		err = shared.WriteAll(f, []byte(lxcConfig))
+		f.Close()
+		defer os.Remove(f.Name())
+		if err != nil {
+			return err
+		}
 
 		if err := cc.LoadConfigFile(f.Name()); err != nil {
 			return fmt.Errorf("Failed to load raw.lxc")
@@ -1722,15 +1516,9 @@ func (c *containerLXC) initStorage() error {
 // Config handling
 func (c *containerLXC) expandConfig() error {
 	// Fetch profile configs
-	profileConfigs := make([]map[string]string, len(c.profiles))
-
-	// Apply all the profiles
-	for i, name := range c.profiles {
-		profileConfig, err := c.state.Cluster.ProfileConfig(name)
-		if err != nil {
-			return err
-		}
-		profileConfigs[i] = profileConfig
+	profileConfigs, _, err := profileCacheGet(c.state, c.profiles)
+	if err != nil {
+		return err
 	}
 
 	c.expandConfigFromProfiles(profileConfigs)
@@ -1759,13 +1547,9 @@ func (c *containerLXC) expandConfigFromProfiles(profileConfigs []map[string]stri
 
 func (c *containerLXC) expandDevices() error {
 	// Fetch profile devices
-	profileDevices := make([]types.Devices, len(c.profiles))
-	for _, p := range c.profiles {
-		devices, err := c.state.Cluster.Devices(p, true)
-		if err != nil {
-			return err
-		}
-		profileDevices = append(profileDevices, devices)
+	_, profileDevices, err := profileCacheGet(c.state, c.profiles)
+	if err != nil {
+		return err
 	}
 
 	c.expandDevicesFromProfiles(profileDevices)
@@ -1789,6 +1573,21 @@ func (c *containerLXC) expandDevicesFromProfiles(profileDevices []types.Devices)
 		devices[k] = v
 	}
 
+	// Resolve the nics attached to managed networks, failures are
+	// reported when validating the expanded devices
+	for k, v := range devices {
+		if v["type"] != "nic" || v["network"] == "" {
+			continue
+		}
+
+		device, err := containerDeviceNetwork(c.state.Cluster, v)
+		if err != nil {
+			continue
+		}
+
+		devices[k] = device
+	}
+
 	c.expandedDevices = devices
 }
 
@@ -1796,7 +1595,7 @@ func (c *containerLXC) expandDevicesFromProfiles(profileDevices []types.Devices)
 // liblxc configuration items.
 func (c *containerLXC) setupUnixDevice(prefix string, dev types.Device, major int, minor int, path string, createMustSucceed bool, defaultMode bool) error {
 	if c.IsPrivileged() && !c.state.OS.RunningInUserNS && c.state.OS.CGroupDevicesController {
-		err := lxcSetConfigItem(c.c, "lxc.cgroup.devices.allow", fmt.Sprintf("c %d:%d rwm", major, minor))
+		err := lxcSetConfigItem(c.c, cgroupConfigPrefix(c.state.OS)+"devices.allow", fmt.Sprintf("c %d:%d rwm", major, minor))
 		if err != nil {
 			return err
 		}
@@ -1830,7 +1629,7 @@ func (c *containerLXC) setupUnixDevice(prefix string, dev types.Device, major in
 }
 
 // Start functions
-func (c *containerLXC) startCommon() (string, error) {
+func (c *containerLXC) startCommon(timer *startTimer) (string, error) {
 	// Load the go-lxc struct
 	err := c.initLXC(true)
 	if err != nil {
@@ -1842,6 +1641,12 @@ func (c *containerLXC) startCommon() (string, error) {
 		return "", fmt.Errorf("The container is already running")
 	}
 
+	// Check that we're not in the trash
+	_, trashed := containerTrashDate(c)
+	if trashed {
+		return "", fmt.Errorf("The container is in the trash")
+	}
+
 	// Sanity checks for devices
 	for name, m := range c.expandedDevices {
 		switch m["type"] {
@@ -1888,6 +1693,18 @@ func (c *containerLXC) startCommon() (string, error) {
 		}
 	}
 
+	// Load the modules providing the requested fuse and tun devices
+	for _, dev := range containerSecurityDevices {
+		if !shared.IsTrue(c.expandedConfig[dev.key]) || shared.PathExists(dev.path) {
+			continue
+		}
+
+		err := util.LoadModule(dev.module)
+		if err != nil || !shared.PathExists(dev.path) {
+			return "", fmt.Errorf("The host doesn't provide %s, required by %s", dev.path, dev.key)
+		}
+	}
+
 	var ourStart bool
 	newSize, ok := c.LocalConfig()["volatile.apply_quota"]
 	if ok {
@@ -1915,6 +1732,7 @@ func (c *containerLXC) startCommon() (string, error) {
 		delete(c.localConfig, "volatile.apply_quota")
 		delete(c.expandedConfig, "volatile.apply_quota")
 	}
+	timer.mark("prepare")
 
 	/* Deal with idmap changes */
 	idmap, err := c.IdmapSet()
@@ -1939,6 +1757,10 @@ func (c *containerLXC) startCommon() (string, error) {
 	}
 
 	if !reflect.DeepEqual(idmap, lastIdmap) {
+		if shared.IsTrue(c.expandedConfig["security.protection.shift"]) {
+			return "", fmt.Errorf("Container is protected against filesystem shifting")
+		}
+
 		logger.Debugf("Container idmap changed, remapping")
 		c.updateProgress("Remapping container filesystem")
 
@@ -2012,6 +1834,7 @@ func (c *containerLXC) startCommon() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	timer.mark("idmap")
 
 	// Generate the Seccomp profile
 	if err := SeccompCreateProfile(c); err != nil {
@@ -2028,9 +1851,21 @@ func (c *containerLXC) startCommon() (string, error) {
 	var sriov []string
 	diskDevices := map[string]types.Device{}
 
-	// Create the devices
+	// Create the devices, accounting network devices separately
+	phase := "devices"
 	for _, k := range c.expandedDevices.DeviceNames() {
-		m := c.expandedDevices[k]
+		timer.mark(phase)
+
+		m, err := c.deviceResolveBlockVolume(c.expandedDevices[k])
+		if err != nil {
+			return "", err
+		}
+
+		phase = "devices"
+		if shared.StringInSlice(m["type"], []string{"nic", "infiniband"}) {
+			phase = "network"
+		}
+
 		if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
 			// Unix device
 			paths, err := c.createUnixDevice(fmt.Sprintf("unix.%s", k), m, true)
@@ -2062,7 +1897,7 @@ func (c *containerLXC) startCommon() (string, error) {
 						return "", err
 					}
 				} else {
-					err = lxcSetConfigItem(c.c, "lxc.cgroup.devices.allow", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor))
+					err = lxcSetConfigItem(c.c, cgroupConfigPrefix(c.state.OS)+"devices.allow", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor))
 					if err != nil {
 						return "", fmt.Errorf("Failed to add cgroup rule for device")
 					}
@@ -2288,7 +2123,7 @@ func (c *containerLXC) startCommon() (string, error) {
 			if shared.StringInSlice(m["nictype"], []string{"macvlan", "physical"}) && m["vlan"] != "" {
 				device := networkGetHostDevice(m["parent"], m["vlan"])
 				if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s", device)) {
-					_, err := shared.RunCommand("ip", "link", "add", "link", m["parent"], "name", device, "up", "type", "vlan", "id", m["vlan"])
+					_, err := shared.RunCommand("ip", networkVLANArgs(m["parent"], device, m["vlan"], shared.IsTrue(m["vlan.gvrp"]))...)
 					if err != nil {
 						return "", err
 					}
@@ -2300,6 +2135,8 @@ func (c *containerLXC) startCommon() (string, error) {
 		}
 	}
 
+	timer.mark(phase)
+
 	err = c.addDiskDevices(diskDevices, func(name string, d types.Device) error {
 		_, err := c.createDiskDevice(name, d)
 		return err
@@ -2307,6 +2144,7 @@ func (c *containerLXC) startCommon() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	timer.mark("devices")
 
 	// Create any missing directory
 	err = os.MkdirAll(c.LogPath(), 0700)
@@ -2334,11 +2172,24 @@ func (c *containerLXC) startCommon() (string, error) {
 		}
 	}
 
+	timer.mark("config")
+
 	// Storage is guaranteed to be mountable now.
 	ourStart, err = c.StorageStart()
 	if err != nil {
 		return "", err
 	}
+	timer.mark("storage")
+
+	// Delegate the container's ZFS dataset to it, or take it back if
+	// delegation was turned off since it last ran.
+	err = c.zfsDelegate(shared.IsTrue(c.expandedConfig["zfs.delegate"]))
+	if err != nil {
+		if ourStart {
+			c.StorageStop()
+		}
+		return "", err
+	}
 
 	// Generate the LXC config
 	configPath := filepath.Join(c.LogPath(), "lxc.conf")
@@ -2371,6 +2222,7 @@ func (c *containerLXC) startCommon() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("Error updating last used: %v", err)
 	}
+	timer.mark("config")
 
 	return configPath, nil
 }
@@ -2385,13 +2237,20 @@ func (c *containerLXC) Start(stateful bool) error {
 	}
 	defer op.Done(nil)
 
+	// Take the proxy devices back if LXD was listening on their behalf
+	err = c.wakeRelease()
+	if err != nil {
+		return err
+	}
+
 	err = setupSharedMounts()
 	if err != nil {
 		return fmt.Errorf("Daemon failed to setup shared mounts base: %s.\nDoes security.nesting need to be turned on?", err)
 	}
 
 	// Run the shared start code
-	configPath, err := c.startCommon()
+	timer := newStartTimer()
+	configPath, err := c.startCommon(timer)
 	if err != nil {
 		return err
 	}
@@ -2401,6 +2260,7 @@ func (c *containerLXC) Start(stateful bool) error {
 	if err != nil {
 		return err
 	}
+	timer.mark("storage")
 
 	ctxMap = log.Ctx{"name": c.name,
 		"action":    op.action,
@@ -2441,6 +2301,8 @@ func (c *containerLXC) Start(stateful bool) error {
 			return err
 		}
 
+		containerMonitorWatch(c)
+
 		logger.Info("Started container", ctxMap)
 
 		return err
@@ -2459,6 +2321,8 @@ func (c *containerLXC) Start(stateful bool) error {
 	}
 
 	// Start the LXC container
+	containerStartHookPop(c.name)
+	timer.mark("prepare")
 	out, err := shared.RunCommand(
 		c.state.OS.ExecPath,
 		"forkstart",
@@ -2507,6 +2371,13 @@ func (c *containerLXC) Start(stateful bool) error {
 		return err
 	}
 
+	// The start hook runs as part of forkstart
+	timer.mark("lxc-start")
+	hookDuration, ok := containerStartHookPop(c.name)
+	if ok {
+		timer.split("lxc-start", "hooks", hookDuration)
+	}
+
 	// Start proxy devices
 	err = c.restartProxyDevices()
 	if err != nil {
@@ -2515,6 +2386,50 @@ func (c *containerLXC) Start(stateful bool) error {
 		return err
 	}
 
+	// Setup the bridge ports of the nics requesting isolation or VLANs
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "nic" {
+			continue
+		}
+
+		if shared.IsTrue(m["security.port_isolation"]) {
+			err = c.setNetworkIsolation(name, m)
+			if err != nil {
+				// Attempt to stop the container
+				c.Stop(false)
+				return err
+			}
+		}
+
+		if m["vlan.tagged"] != "" && m["nictype"] == "bridged" {
+			err = c.setNetworkVLANs(name, m)
+			if err != nil {
+				// Attempt to stop the container
+				c.Stop(false)
+				return err
+			}
+		}
+	}
+	timer.mark("devices")
+
+	containerStartTimingsRecord(c.name, timer.timings())
+
+	// Get notified as soon as the container exits
+	containerMonitorWatch(c)
+
+	// Run the site-specific post-start hook
+	err = c.runHook("post-start", map[string]string{"LXD_CONTAINER_PID": fmt.Sprintf("%d", c.InitPID())})
+	if err != nil {
+		logger.Error("Failed to run post-start hook", log.Ctx{"container": c.name, "err": err})
+	}
+
+	// Run the first-boot provisioning script
+	err = c.runOnceStart()
+	if err != nil {
+		logger.Error("Failed to run run.once script", log.Ctx{"container": c.name, "err": err})
+	}
+
 	logger.Info("Started container", ctxMap)
 	eventSendLifecycle("container-started",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
@@ -2526,12 +2441,27 @@ func (c *containerLXC) OnStart() error {
 	// Make sure we can't call go-lxc functions by mistake
 	c.fromHook = true
 
+	// Measure how long the hook takes for the start timings
+	hookStart := time.Now()
+	defer func() {
+		containerStartHookRecord(c.name, time.Since(hookStart))
+	}()
+
 	// Start the storage for this container
 	ourStart, err := c.StorageStartSensitive()
 	if err != nil {
 		return err
 	}
 
+	// Run the site-specific pre-start hook
+	err = c.runHook("pre-start", nil)
+	if err != nil {
+		if ourStart {
+			c.StorageStop()
+		}
+		return err
+	}
+
 	// Load the container AppArmor profile
 	err = AALoadProfile(c)
 	if err != nil {
@@ -2588,6 +2518,12 @@ func (c *containerLXC) OnStart() error {
 		}(c)
 	}
 
+	// Setup the aggregate egress limit, before the nics get redirected to it
+	err = c.setNetworkAggregateLimit()
+	if err != nil {
+		logger.Error("Failed to apply aggregate network limit", log.Ctx{"container": c.name, "err": err})
+	}
+
 	// Apply network limits
 	for _, name := range c.expandedDevices.DeviceNames() {
 		m := c.expandedDevices[name]
@@ -2595,7 +2531,7 @@ func (c *containerLXC) OnStart() error {
 			continue
 		}
 
-		if m["limits.max"] == "" && m["limits.ingress"] == "" && m["limits.egress"] == "" {
+		if m["limits.max"] == "" && m["limits.ingress"] == "" && m["limits.egress"] == "" && !c.networkAggregated(m) {
 			continue
 		}
 
@@ -2641,6 +2577,12 @@ func (c *containerLXC) Stop(stateful bool) error {
 
 	logger.Info("Stopping container", ctxMap)
 
+	// Run the site-specific pre-stop hook
+	err = c.runHook("pre-stop", nil)
+	if err != nil {
+		logger.Error("Failed to run pre-stop hook", log.Ctx{"container": c.name, "err": err})
+	}
+
 	// Handle stateful stop
 	if stateful {
 		// Cleanup any existing state
@@ -2758,6 +2700,12 @@ func (c *containerLXC) Shutdown(timeout time.Duration) error {
 
 	logger.Info("Shutting down container", ctxMap)
 
+	// Run the site-specific pre-stop hook
+	err = c.runHook("pre-stop", nil)
+	if err != nil {
+		logger.Error("Failed to run pre-stop hook", log.Ctx{"container": c.name, "err": err})
+	}
+
 	// Load the go-lxc struct
 	err = c.initLXC(false)
 	if err != nil {
@@ -2785,6 +2733,52 @@ func (c *containerLXC) Shutdown(timeout time.Duration) error {
 	return nil
 }
 
+// Reboot asks the container to reboot, which goes through the reboot target
+// of OnStop, starting it again right away without deleting it if it's
+// ephemeral. It then waits up to the given timeout for the container to be
+// running with a new init process.
+func (c *containerLXC) Reboot(timeout time.Duration) error {
+	ctxMap := log.Ctx{"name": c.name,
+		"action":    "reboot",
+		"created":   c.creationDate,
+		"ephemeral": c.ephemeral,
+		"used":      c.lastUsedDate,
+		"timeout":   timeout}
+
+	// Check that we're running
+	if !c.IsRunning() {
+		return fmt.Errorf("The container isn't running")
+	}
+
+	// Load the go-lxc struct
+	err := c.initLXC(false)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Rebooting container", ctxMap)
+
+	pid := c.InitPID()
+	err = c.c.Reboot()
+	if err != nil {
+		logger.Error("Failed rebooting container", ctxMap)
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+
+		newPid := c.InitPID()
+		if newPid > 0 && newPid != pid && c.IsRunning() {
+			logger.Info("Rebooted container", ctxMap)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("The container didn't reboot within %s", timeout)
+}
+
 func (c *containerLXC) OnStop(target string) error {
 	// Validate target
 	if !shared.StringInSlice(target, []string{"stop", "reboot"}) {
@@ -2792,6 +2786,19 @@ func (c *containerLXC) OnStop(target string) error {
 		return fmt.Errorf("Invalid stop target: %s", target)
 	}
 
+	// Stop watching for the exit of the container, unless its stop was
+	// already handled after its exit
+	if containerMonitorStopped(c.id) {
+		logger.Debug("Ignoring stop hook of already stopped container", log.Ctx{"container": c.Name(), "target": target})
+		return nil
+	}
+
+	return c.onStop(target)
+}
+
+// Handle the stop of the container, either from the liblxc stop hook or from
+// the container monitor.
+func (c *containerLXC) onStop(target string) error {
 	// Get operation
 	op, _ := c.getOperation("")
 	if op != nil && op.action != "stop" {
@@ -2801,8 +2808,19 @@ func (c *containerLXC) OnStop(target string) error {
 	// Make sure we can't call go-lxc functions by mistake
 	c.fromHook = true
 
+	// The exec agent can't spawn anything anymore
+	execAgentStop(c.name)
+
+	// Hand a delegated ZFS dataset back to the host so it can be
+	// unmounted, even if delegation was turned off while it ran. Failing
+	// to do so shouldn't prevent the cleanup below.
+	err := c.zfsDelegate(false)
+	if err != nil {
+		logger.Error("Failed to undelegate ZFS dataset", log.Ctx{"container": c.Name(), "err": err})
+	}
+
 	// Stop the storage for this container
-	_, err := c.StorageStop()
+	_, err = c.StorageStop()
 	if err != nil {
 		if op != nil {
 			op.Done(err)
@@ -2859,12 +2877,32 @@ func (c *containerLXC) OnStop(target string) error {
 			logger.Error("Unable to remove network filters", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		// Release the bridge VLANs of the nics
+		err = c.removeNetworkVLANs(c.expandedDevices)
+		if err != nil {
+			logger.Error("Unable to remove network VLANs", log.Ctx{"container": c.Name(), "err": err})
+		}
+
+		// Remove the aggregate network limit device
+		err = c.removeNetworkAggregateLimit()
+		if err != nil {
+			logger.Error("Unable to remove aggregate network limit", log.Ctx{"container": c.Name(), "err": err})
+		}
+
 		// Clean all proxy devices
 		err = c.removeProxyDevices()
 		if err != nil {
 			logger.Error("Unable to remove proxy devices", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		// Start the container again on incoming connections
+		if target == "stop" && !c.IsEphemeral() {
+			err = containerWakeListen(c.state, c, c.localConfig["volatile.suspended"] != "")
+			if err != nil {
+				logger.Error("Failed to listen on behalf of proxy devices", log.Ctx{"container": c.Name(), "err": err})
+			}
+		}
+
 		// Reboot the container
 		if target == "reboot" {
 			// Start the container again
@@ -2961,6 +2999,15 @@ func (c *containerLXC) Unfreeze() error {
 		logger.Error("Failed unfreezing container", ctxMap)
 	}
 
+	// Bring the proxy devices back if the container was suspended for
+	// being idle
+	if err == nil && c.localConfig["volatile.suspended"] != "" {
+		err = c.wakeRelease()
+		if err == nil {
+			err = c.restartProxyDevices()
+		}
+	}
+
 	logger.Info("Unfroze container", ctxMap)
 	eventSendLifecycle("container-resumed",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
@@ -3044,6 +3091,7 @@ func (c *containerLXC) Render() (interface{}, interface{}, error) {
 		ct.LastUsedAt = c.lastUsedDate
 		ct.Profiles = c.profiles
 		ct.Stateful = c.stateful
+		ct.Availability, ct.AvailabilityReason = containerAvailability(c)
 
 		return &ct, etag, nil
 	}
@@ -3068,12 +3116,42 @@ func (c *containerLXC) RenderState() (*api.ContainerState, error) {
 		status.Network = c.networkState()
 		status.Pid = int64(pid)
 		status.Processes = c.processesState()
+		status.DiskWeight = c.diskWeightState()
+		status.Health = containerHealthGet(c.name)
+
+		if status.Health == nil && c.expandedConfig["healthcheck.exec"] != "" {
+			status.Health = &api.ContainerStateHealth{Status: "starting"}
+		}
 	}
 
+	status.StartTimings = containerStartTimingsGet(c.name)
+
 	return &status, nil
 }
 
-func (c *containerLXC) Snapshots() ([]container, error) {
+// RenderUsage returns a sample of the current resource usage of the
+// container, leaving out the more expensive network information.
+func (c *containerLXC) RenderUsage() api.ContainerUsageSample {
+	sample := api.ContainerUsageSample{
+		Timestamp: time.Now().UTC(),
+	}
+
+	if !c.IsRunning() {
+		return sample
+	}
+
+	sample.CPUUsage = c.cpuState().Usage
+	sample.MemoryUsage = c.memoryState().Usage
+	sample.Processes = c.processesState()
+
+	for _, disk := range c.diskState() {
+		sample.DiskUsage += disk.Usage
+	}
+
+	return sample
+}
+
+func (c *containerLXC) Snapshots() ([]container, error) {
 	// Get all the snapshots
 	snaps, err := c.state.Cluster.ContainerGetSnapshots(c.name)
 	if err != nil {
@@ -3115,57 +3193,65 @@ func (c *containerLXC) Backups() ([]backup, error) {
 	return backups, nil
 }
 
-func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
+// Restore the given snapshot onto the container. When only is "filesystem"
+// or "config", just the root filesystem or just the configuration (devices
+// and profiles included) of the snapshot is restored.
+func (c *containerLXC) Restore(sourceContainer container, stateful bool, only string) error {
 	var ctxMap log.Ctx
 
+	restoreFilesystem := only != "config"
+	restoreConfig := only != "filesystem"
+
 	// Initialize storage interface for the container.
 	err := c.initStorage()
 	if err != nil {
 		return err
 	}
 
-	ourStart, err := c.StorageStart()
-	if err != nil {
-		return err
-	}
-	if ourStart {
-		defer c.StorageStop()
-	}
-
-	// Check if we can restore the container
-	err = c.storage.ContainerCanRestore(c, sourceContainer)
-	if err != nil {
-		return err
-	}
-
-	/* let's also check for CRIU if necessary, before doing a bunch of
-	 * filesystem manipulations
-	 */
-	if shared.PathExists(c.StatePath()) {
-		_, err := exec.LookPath("criu")
-		if err != nil {
-			return fmt.Errorf("Failed to restore container state. CRIU isn't installed.")
-		}
-	}
-
 	// Stop the container
 	wasRunning := false
-	if c.IsRunning() {
-		wasRunning = true
-
-		// This will unmount the container storage.
-		err := c.Stop(false)
+	if restoreFilesystem {
+		ourStart, err := c.StorageStart()
 		if err != nil {
 			return err
 		}
+		if ourStart {
+			defer c.StorageStop()
+		}
 
-		// Ensure that storage is mounted for state path checks.
-		ourStart, err := c.StorageStart()
+		// Check if we can restore the container
+		err = c.storage.ContainerCanRestore(c, sourceContainer)
 		if err != nil {
 			return err
 		}
-		if ourStart {
-			defer c.StorageStop()
+
+		/* let's also check for CRIU if necessary, before doing a bunch of
+		 * filesystem manipulations
+		 */
+		if shared.PathExists(c.StatePath()) {
+			_, err := exec.LookPath("criu")
+			if err != nil {
+				return fmt.Errorf("Failed to restore container state. CRIU isn't installed.")
+			}
+		}
+
+		if c.IsRunning() {
+			wasRunning = true
+
+			// This will unmount the container storage.
+			err := c.Stop(false)
+			if err != nil {
+				return err
+			}
+
+			// Ensure that storage is mounted for state path checks.
+			ourStart, err := c.StorageStart()
+			if err != nil {
+				return err
+			}
+			if ourStart {
+				defer c.StorageStop()
+			}
 		}
 	}
 
@@ -3173,31 +3259,72 @@ func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
 		"created":   c.creationDate,
 		"ephemeral": c.ephemeral,
 		"used":      c.lastUsedDate,
-		"source":    sourceContainer.Name()}
+		"source":    sourceContainer.Name(),
+		"only":      only}
 
 	logger.Info("Restoring container", ctxMap)
 
 	// Restore the rootfs
-	err = c.storage.ContainerRestore(c, sourceContainer)
-	if err != nil {
-		logger.Error("Failed restoring container filesystem", ctxMap)
-		return err
+	if restoreFilesystem {
+		err = c.storage.ContainerRestore(c, sourceContainer)
+		if err != nil {
+			logger.Error("Failed restoring container filesystem", ctxMap)
+			return err
+		}
 	}
 
-	// Restore the configuration
-	args := db.ContainerArgs{
-		Architecture: sourceContainer.Architecture(),
-		Config:       sourceContainer.LocalConfig(),
-		Description:  sourceContainer.Description(),
-		Devices:      sourceContainer.LocalDevices(),
-		Ephemeral:    sourceContainer.IsEphemeral(),
-		Profiles:     sourceContainer.Profiles(),
-	}
+	// Restore the configuration, the delete protection of the snapshot
+	// only applies to the snapshot itself
+	if restoreConfig {
+		config := map[string]string{}
+		for k, v := range sourceContainer.LocalConfig() {
+			config[k] = v
+		}
 
-	err = c.Update(args, false)
-	if err != nil {
-		logger.Error("Failed restoring container configuration", ctxMap)
-		return err
+		delete(config, "security.protection.delete")
+		if c.localConfig["security.protection.delete"] != "" {
+			config["security.protection.delete"] = c.localConfig["security.protection.delete"]
+		}
+
+		// The filesystem is still shifted for the current map
+		if !restoreFilesystem {
+			delete(config, "volatile.last_state.idmap")
+			if c.localConfig["volatile.last_state.idmap"] != "" {
+				config["volatile.last_state.idmap"] = c.localConfig["volatile.last_state.idmap"]
+			}
+		}
+
+		profilePriorities, err := c.state.Cluster.ContainerProfilePriorities(sourceContainer.Id())
+		if err != nil {
+			return err
+		}
+
+		args := db.ContainerArgs{
+			Architecture: sourceContainer.Architecture(),
+			Config:       config,
+			Description:  sourceContainer.Description(),
+			Devices:      sourceContainer.LocalDevices(),
+			Ephemeral:    sourceContainer.IsEphemeral(),
+			Profiles:     sourceContainer.Profiles(),
+
+			ProfilePriorities: profilePriorities,
+		}
+
+		err = c.Update(args, false)
+		if err != nil {
+			logger.Error("Failed restoring container configuration", ctxMap)
+			return err
+		}
+	} else {
+		// The restored filesystem is shifted for the snapshot's map
+		idmap := sourceContainer.LocalConfig()["volatile.last_state.idmap"]
+		if idmap != "" && idmap != c.localConfig["volatile.last_state.idmap"] {
+			err = c.ConfigKeySet("volatile.last_state.idmap", idmap)
+			if err != nil {
+				logger.Error("Failed restoring container configuration", ctxMap)
+				return err
+			}
+		}
 	}
 
 	// The old backup file may be out of date (e.g. it doesn't have all the
@@ -3293,12 +3420,37 @@ func (c *containerLXC) Delete() error {
 
 	logger.Info("Deleting container", ctxMap)
 
-	if c.IsDeleteProtected() && !c.IsSnapshot() {
+	if c.IsDeleteProtected() {
 		err := errors.New("Container is protected")
+		if c.IsSnapshot() {
+			err = errors.New("Snapshot is protected")
+		}
+
 		logger.Warn("Failed to delete container", log.Ctx{"name": c.Name(), "err": err})
 		return err
 	}
 
+	// Stop listening on behalf of the container if it was suspended
+	if !c.IsSnapshot() {
+		containerWakeRelease(c.name)
+	}
+
+	// Refuse to take protected snapshots along with the container
+	if !c.IsSnapshot() {
+		snapshots, err := c.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		for _, sc := range snapshots {
+			if sc.IsDeleteProtected() {
+				err := fmt.Errorf("Snapshot '%s' is protected", sc.Name())
+				logger.Warn("Failed to delete container", log.Ctx{"name": c.Name(), "err": err})
+				return err
+			}
+		}
+	}
+
 	// Attempt to initialize storage interface for the container.
 	c.initStorage()
 
@@ -3379,6 +3531,8 @@ func (c *containerLXC) Delete() error {
 		networkClearLease(c.state, m["parent"], m["hwaddr"])
 	}
 
+	containerStartTimingsForget(c.name)
+
 	logger.Info("Deleted container", ctxMap)
 
 	if c.IsSnapshot() {
@@ -3520,6 +3674,7 @@ func (c *containerLXC) Rename(newName string) error {
 				"snapshot_name": oldName,
 			})
 	} else {
+		containerHealthInvalidate()
 		eventSendLifecycle("container-renamed",
 			fmt.Sprintf("/1.0/containers/%s", oldName), map[string]interface{}{
 				"new_name": newName,
@@ -3541,6 +3696,11 @@ func (c *containerLXC) CGroupGet(key string) (string, error) {
 		return "", fmt.Errorf("Can't get cgroups on a stopped container")
 	}
 
+	err = cgroupKeyCheck(c.state.OS, key)
+	if err != nil {
+		return "", err
+	}
+
 	value := c.c.CgroupItem(key)
 	return strings.Join(value, "\n"), nil
 }
@@ -3557,6 +3717,11 @@ func (c *containerLXC) CGroupSet(key string, value string) error {
 		return fmt.Errorf("Can't set cgroups on a stopped container")
 	}
 
+	err = cgroupKeyCheck(c.state.OS, key)
+	if err != nil {
+		return err
+	}
+
 	err = c.c.SetCgroupItem(key, value)
 	if err != nil {
 		return fmt.Errorf("Failed to set cgroup %s=\"%s\": %s", key, value, err)
@@ -3712,6 +3877,15 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		}
 	}
 
+	for name := range args.ProfilePriorities {
+		if !shared.StringInSlice(name, args.Profiles) {
+			return fmt.Errorf("Profile isn't used by the container: %s", name)
+		}
+	}
+
+	// Apply the profiles in the order given by their priorities
+	args.Profiles = db.ContainerProfilesSort(args.Profiles, args.ProfilePriorities)
+
 	// Validate the new architecture
 	if args.Architecture != 0 {
 		_, err = osarch.ArchitectureName(args.Architecture)
@@ -3787,13 +3961,33 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		return err
 	}
 
+	oldProfilePriorities, err := c.state.Cluster.ContainerProfilePriorities(c.id)
+	if err != nil {
+		return err
+	}
+
 	// Define a function which reverts everything.  Defer this function
 	// so that it doesn't need to be explicitly called in every failing
 	// return path.  Track whether or not we want to undo the changes
 	// using a closure.
 	undoChanges := true
+	applyingChanges := false
 	defer func() {
 		if undoChanges {
+			// Bring whatever was already applied back in line with
+			// the old configuration.
+			if applyingChanges && !c.updateReverting {
+				c.updateRevert(db.ContainerArgs{
+					Architecture:      oldArchitecture,
+					Config:            oldLocalConfig,
+					Description:       oldDescription,
+					Devices:           oldLocalDevices,
+					Ephemeral:         oldEphemeral,
+					Profiles:          oldProfiles,
+					ProfilePriorities: oldProfilePriorities,
+				})
+			}
+
 			c.description = oldDescription
 			c.architecture = oldArchitecture
 			c.ephemeral = oldEphemeral
@@ -3855,6 +4049,13 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		return err
 	}
 
+	if shared.StringInSlice("boot.depends_on", changedConfig) && !c.IsSnapshot() {
+		err = containerValidDependencies(c.state, c.Name(), c.expandedConfig["boot.depends_on"])
+		if err != nil {
+			return err
+		}
+	}
+
 	// Do some validation of the devices diff
 	err = containerValidDevices(c.state.Cluster, c.expandedDevices, false, true)
 	if err != nil {
@@ -3899,6 +4100,11 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 			if err != nil {
 				return err
 			}
+		} else {
+			err = idmapRelease(c.state, c.Name())
+			if err != nil {
+				return err
+			}
 		}
 
 		var jsonIdmap string
@@ -3980,6 +4186,9 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 	oldRootDiskDeviceSize := oldExpandedDevices[oldRootDiskDeviceKey]["size"]
 	newRootDiskDeviceSize := c.expandedDevices[newRootDiskDeviceKey]["size"]
 
+	// Everything was validated, from now on changes get applied.
+	applyingChanges = true
+
 	isRunning := c.IsRunning()
 	// Apply disk quota changes
 	if newRootDiskDeviceSize != oldRootDiskDeviceSize {
@@ -4002,10 +4211,12 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 
 	// Update MAAS
 	updateMAAS := false
-	for _, key := range []string{"maas.subnet.ipv4", "maas.subnet.ipv6", "ipv4.address", "ipv6.address"} {
-		if shared.StringInSlice(key, updateDiff) {
-			updateMAAS = true
-			break
+	for _, diff := range updateDiff {
+		for _, key := range []string{"maas.subnet.ipv4", "maas.subnet.ipv6", "ipv4.address", "ipv6.address"} {
+			if shared.StringInSlice(key, diff) {
+				updateMAAS = true
+				break
+			}
 		}
 	}
 
@@ -4045,6 +4256,8 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 						return err
 					}
 				}
+			} else if key == "linux.exec_agent" && !shared.IsTrue(value) {
+				execAgentStop(c.name)
 			} else if key == "linux.kernel_modules" && value != "" {
 				for _, module := range strings.Split(value, ",") {
 					module = strings.TrimPrefix(module, " ")
@@ -4054,26 +4267,17 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 					}
 				}
 			} else if key == "limits.disk.priority" {
-				if !c.state.OS.CGroupBlkioController {
+				if !c.state.OS.CGroupBlkioController || !c.state.OS.CGroupBlkioWeight {
 					continue
 				}
 
-				priorityInt := 5
-				diskPriority := c.expandedConfig["limits.disk.priority"]
-				if diskPriority != "" {
-					priorityInt, err = strconv.Atoi(diskPriority)
-					if err != nil {
-						return err
-					}
-				}
-
-				// Minimum valid value is 10
-				priority := priorityInt * 100
-				if priority == 0 {
-					priority = 10
+				weight, err := deviceParseDiskPriority(c.expandedConfig["limits.disk.priority"])
+				if err != nil {
+					return err
 				}
 
-				err = c.CGroupSet("blkio.weight", fmt.Sprintf("%d", priority))
+				setting := containerDiskWeightSetting(c.state.OS, weight)
+				err = c.CGroupSet(setting.key, setting.value)
 				if err != nil {
 					return err
 				}
@@ -4083,161 +4287,88 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 					continue
 				}
 
-				// Set the new memory limit
-				memory := c.expandedConfig["limits.memory"]
-				memoryEnforce := c.expandedConfig["limits.memory.enforce"]
-				memorySwap := c.expandedConfig["limits.memory.swap"]
-
-				// Parse memory
-				if memory == "" {
-					memory = "-1"
-				} else if strings.HasSuffix(memory, "%") {
-					percent, err := strconv.ParseInt(strings.TrimSuffix(memory, "%"), 10, 64)
-					if err != nil {
-						return err
-					}
-
-					memoryTotal, err := shared.DeviceTotalMemory()
-					if err != nil {
-						return err
-					}
-
-					memory = fmt.Sprintf("%d", int64((memoryTotal/100)*percent))
-				} else {
-					valueInt, err := shared.ParseByteSizeString(memory)
-					if err != nil {
-						return err
-					}
-					memory = fmt.Sprintf("%d", valueInt)
+				settings, err := containerMemorySettings(c.state.OS, c.expandedConfig)
+				if err != nil {
+					return err
 				}
 
 				// Store the old values for revert
-				oldMemswLimit := ""
-				if c.state.OS.CGroupSwapAccounting {
-					oldMemswLimit, err = c.CGroupGet("memory.memsw.limit_in_bytes")
-					if err != nil {
-						oldMemswLimit = ""
+				reset := containerMemoryResetSettings(c.state.OS)
+				old := []cgroupSetting{}
+				for _, setting := range reset {
+					value, err := c.CGroupGet(setting.key)
+					if err == nil && value != "" {
+						old = append(old, cgroupSetting{setting.key, value})
 					}
 				}
 
-				oldLimit, err := c.CGroupGet("memory.limit_in_bytes")
-				if err != nil {
-					oldLimit = ""
-				}
-
-				oldSoftLimit, err := c.CGroupGet("memory.soft_limit_in_bytes")
-				if err != nil {
-					oldSoftLimit = ""
-				}
-
 				revertMemory := func() {
-					if oldSoftLimit != "" {
-						c.CGroupSet("memory.soft_limit_in_bytes", oldSoftLimit)
-					}
-
-					if oldLimit != "" {
-						c.CGroupSet("memory.limit_in_bytes", oldLimit)
+					for i := len(old) - 1; i >= 0; i-- {
+						c.CGroupSet(old[i].key, old[i].value)
 					}
+				}
 
-					if oldMemswLimit != "" {
-						c.CGroupSet("memory.memsw.limit_in_bytes", oldMemswLimit)
+				// Reset everything, then set the new values
+				for _, setting := range append(reset, settings...) {
+					// The swappiness is only updated along with the swap keys
+					if setting.key == "memory.swappiness" {
+						continue
 					}
-				}
 
-				// Reset everything
-				if c.state.OS.CGroupSwapAccounting {
-					err = c.CGroupSet("memory.memsw.limit_in_bytes", "-1")
+					err = c.CGroupSet(setting.key, setting.value)
 					if err != nil {
 						revertMemory()
 						return err
 					}
 				}
 
-				err = c.CGroupSet("memory.limit_in_bytes", "-1")
-				if err != nil {
-					revertMemory()
-					return err
-				}
+				// Configure the swappiness
+				if !c.state.OS.CGroupUnified && (key == "limits.memory.swap" || key == "limits.memory.swap.priority") {
+					swappiness := fmt.Sprintf("%d", 60-10)
+					for _, setting := range settings {
+						if setting.key == "memory.swappiness" {
+							swappiness = setting.value
+						}
+					}
 
-				err = c.CGroupSet("memory.soft_limit_in_bytes", "-1")
+					err = c.CGroupSet("memory.swappiness", swappiness)
+					if err != nil {
+						return err
+					}
+				}
+			} else if key == "limits.network.priority" {
+				err := c.setNetworkPriority()
 				if err != nil {
-					revertMemory()
 					return err
 				}
-
-				// Set the new values
-				if memoryEnforce == "soft" {
-					// Set new limit
-					err = c.CGroupSet("memory.soft_limit_in_bytes", memory)
+			} else if key == "limits.network.egress" {
+				// The device must exist before the nics get redirected to it
+				if value != "" {
+					err := c.setNetworkAggregateLimit()
 					if err != nil {
-						revertMemory()
 						return err
 					}
-				} else {
-					if c.state.OS.CGroupSwapAccounting && (memorySwap == "" || shared.IsTrue(memorySwap)) {
-						err = c.CGroupSet("memory.limit_in_bytes", memory)
-						if err != nil {
-							revertMemory()
-							return err
-						}
+				}
 
-						err = c.CGroupSet("memory.memsw.limit_in_bytes", memory)
-						if err != nil {
-							revertMemory()
-							return err
-						}
-					} else {
-						err = c.CGroupSet("memory.limit_in_bytes", memory)
-						if err != nil {
-							revertMemory()
-							return err
-						}
+				for _, name := range c.expandedDevices.DeviceNames() {
+					m := c.expandedDevices[name]
+					if m["type"] != "nic" || !shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"}) {
+						continue
 					}
 
-					// Set soft limit to value 10% less than hard limit
-					valueInt, err := strconv.ParseInt(memory, 10, 64)
+					err := c.setNetworkLimits(name, m)
 					if err != nil {
-						revertMemory()
 						return err
 					}
+				}
 
-					err = c.CGroupSet("memory.soft_limit_in_bytes", fmt.Sprintf("%.0f", float64(valueInt)*0.9))
+				if value == "" {
+					err := c.removeNetworkAggregateLimit()
 					if err != nil {
-						revertMemory()
 						return err
 					}
 				}
-
-				// Configure the swappiness
-				if key == "limits.memory.swap" || key == "limits.memory.swap.priority" {
-					memorySwap := c.expandedConfig["limits.memory.swap"]
-					memorySwapPriority := c.expandedConfig["limits.memory.swap.priority"]
-					if memorySwap != "" && !shared.IsTrue(memorySwap) {
-						err = c.CGroupSet("memory.swappiness", "0")
-						if err != nil {
-							return err
-						}
-					} else {
-						priority := 0
-						if memorySwapPriority != "" {
-							priority, err = strconv.Atoi(memorySwapPriority)
-							if err != nil {
-								return err
-							}
-						}
-
-						err = c.CGroupSet("memory.swappiness", fmt.Sprintf("%d", 60-10+priority))
-						if err != nil {
-							return err
-						}
-					}
-				}
-			} else if key == "limits.network.priority" {
-				err := c.setNetworkPriority()
-				if err != nil {
-					return err
-				}
-			} else if key == "limits.cpu" {
+			} else if key == "limits.cpu" || key == "limits.cpu.isolated" {
 				// Trigger a scheduler re-run
 				deviceTaskSchedulerTrigger("container", c.name, "changed")
 			} else if key == "limits.cpu.priority" || key == "limits.cpu.allowance" {
@@ -4247,24 +4378,16 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				}
 
 				// Apply new CPU limits
-				cpuShares, cpuCfsQuota, cpuCfsPeriod, err := deviceParseCPU(c.expandedConfig["limits.cpu.allowance"], c.expandedConfig["limits.cpu.priority"])
-				if err != nil {
-					return err
-				}
-
-				err = c.CGroupSet("cpu.shares", cpuShares)
-				if err != nil {
-					return err
-				}
-
-				err = c.CGroupSet("cpu.cfs_period_us", cpuCfsPeriod)
+				settings, err := containerCPUSettings(c.state.OS, c.expandedConfig)
 				if err != nil {
 					return err
 				}
 
-				err = c.CGroupSet("cpu.cfs_quota_us", cpuCfsQuota)
-				if err != nil {
-					return err
+				for _, setting := range settings {
+					err = c.CGroupSet(setting.key, setting.value)
+					if err != nil {
+						return err
+					}
 				}
 			} else if key == "limits.processes" {
 				if !c.state.OS.CGroupPidsController {
@@ -4290,350 +4413,27 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 			}
 		}
 
-		var usbs []usbDevice
-
 		// Live update the devices
-		for k, m := range removeDevices {
-			if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
-				prefix := fmt.Sprintf("unix.%s", k)
-				destPath := m["path"]
-				if destPath == "" {
-					destPath = m["source"]
-				}
-
-				if !c.deviceExistsInDevicesFolder(prefix, destPath) && (m["required"] != "" && !shared.IsTrue(m["required"])) {
-					continue
-				}
+		err = c.updateDevices(removeDevices, addDevices, updateDevices, updateDiff)
+		if err != nil {
+			return err
+		}
+	}
 
-				err = c.removeUnixDevice(fmt.Sprintf("unix.%s", k), m, true)
-				if err != nil {
-					return err
-				}
-			} else if m["type"] == "disk" && m["path"] != "/" {
-				err = c.removeDiskDevice(k, m)
-				if err != nil {
-					return err
-				}
-			} else if m["type"] == "nic" || m["type"] == "infiniband" {
-				err = c.removeNetworkDevice(k, m)
-				if err != nil {
-					return err
-				}
+	// Cleanup any leftover volatile entries
+	netNames := []string{}
+	for _, k := range c.expandedDevices.DeviceNames() {
+		v := c.expandedDevices[k]
+		if v["type"] == "nic" || v["type"] == "infiniband" {
+			netNames = append(netNames, k)
+		}
+	}
 
-				err = c.removeInfinibandDevices(k, m)
-				if err != nil {
-					return err
-				}
-			} else if m["type"] == "usb" {
-				if usbs == nil {
-					usbs, err = deviceLoadUsb()
-					if err != nil {
-						return err
-					}
-				}
-
-				/* if the device isn't present, we don't need to remove it */
-				for _, usb := range usbs {
-					if usb.vendor != m["vendorid"] || (m["productid"] != "" && usb.product != m["productid"]) {
-						continue
-					}
-
-					err := c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, usb.major, usb.minor, usb.path)
-					if err != nil {
-						return err
-					}
-				}
-			} else if m["type"] == "gpu" {
-				allGpus := deviceWantsAllGPUs(m)
-				gpus, nvidiaDevices, err := deviceLoadGpu(allGpus)
-				if err != nil {
-					return err
-				}
-
-				for _, gpu := range gpus {
-					if (m["vendorid"] != "" && gpu.vendorid != m["vendorid"]) ||
-						(m["pci"] != "" && gpu.pci != m["pci"]) ||
-						(m["productid"] != "" && gpu.productid != m["productid"]) ||
-						(m["id"] != "" && gpu.id != m["id"]) {
-						continue
-					}
-
-					err := c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, gpu.major, gpu.minor, gpu.path)
-					if err != nil {
-						logger.Error("Failed to remove GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
-						return err
-					}
-
-					if !gpu.isNvidia {
-						continue
-					}
-
-					if gpu.nvidia.path != "" {
-						err = c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, gpu.nvidia.major, gpu.nvidia.minor, gpu.nvidia.path)
-						if err != nil {
-							logger.Error("Failed to remove GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
-							return err
-						}
-					} else if !allGpus {
-						errMsg := fmt.Errorf("Failed to detect correct \"/dev/nvidia\" path")
-						logger.Errorf("%s", errMsg)
-						return errMsg
-					}
-				}
-
-				nvidiaExists := false
-				for _, gpu := range gpus {
-					if gpu.nvidia.path != "" {
-						if c.deviceExistsInDevicesFolder(fmt.Sprintf("unix.%s", k), gpu.path) {
-							nvidiaExists = true
-							break
-						}
-					}
-				}
-
-				if !nvidiaExists {
-					for _, gpu := range nvidiaDevices {
-						if shared.IsTrue(c.expandedConfig["nvidia.runtime"]) {
-							if !gpu.isCard {
-								continue
-							}
-						}
-
-						if !c.deviceExistsInDevicesFolder(fmt.Sprintf("unix.%s", k), gpu.path) {
-							continue
-						}
-
-						err = c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, gpu.major, gpu.minor, gpu.path)
-						if err != nil {
-							logger.Error("Failed to remove GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
-							return err
-						}
-					}
-				}
-			} else if m["type"] == "proxy" {
-				err = c.removeProxyDevice(k)
-				if err != nil {
-					return err
-				}
-			}
-		}
-
-		diskDevices := map[string]types.Device{}
-		for k, m := range addDevices {
-			if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
-				err = c.insertUnixDevice(fmt.Sprintf("unix.%s", k), m, true)
-				if err != nil {
-					if m["required"] == "" || shared.IsTrue(m["required"]) {
-						return err
-					}
-				}
-			} else if m["type"] == "disk" && m["path"] != "/" {
-				diskDevices[k] = m
-			} else if m["type"] == "nic" || m["type"] == "infiniband" {
-				var err error
-				var infiniband map[string]IBF
-				if m["type"] == "infiniband" {
-					infiniband, err = deviceLoadInfiniband()
-					if err != nil {
-						return err
-					}
-				}
-
-				m, err = c.insertNetworkDevice(k, m)
-				if err != nil {
-					return err
-				}
-
-				// Plugin in all character devices
-				if m["type"] == "infiniband" {
-					key := m["parent"]
-					if m["nictype"] == "sriov" {
-						key = m["host_name"]
-					}
-
-					ifDev, ok := infiniband[key]
-					if !ok {
-						return fmt.Errorf("Specified infiniband device \"%s\" not found", key)
-					}
-
-					err := c.addInfinibandDevices(k, &ifDev, true)
-					if err != nil {
-						return err
-					}
-				}
-			} else if m["type"] == "usb" {
-				if usbs == nil {
-					usbs, err = deviceLoadUsb()
-					if err != nil {
-						return err
-					}
-				}
-
-				for _, usb := range usbs {
-					if usb.vendor != m["vendorid"] || (m["productid"] != "" && usb.product != m["productid"]) {
-						continue
-					}
-
-					err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, usb.major, usb.minor, usb.path, false)
-					if err != nil {
-						logger.Error("Failed to insert usb device", log.Ctx{"err": err, "usb": usb, "container": c.Name()})
-					}
-				}
-			} else if m["type"] == "gpu" {
-				allGpus := deviceWantsAllGPUs(m)
-				gpus, nvidiaDevices, err := deviceLoadGpu(allGpus)
-				if err != nil {
-					return err
-				}
-
-				sawNvidia := false
-				found := false
-				for _, gpu := range gpus {
-					if (m["vendorid"] != "" && gpu.vendorid != m["vendorid"]) ||
-						(m["pci"] != "" && gpu.pci != m["pci"]) ||
-						(m["productid"] != "" && gpu.productid != m["productid"]) ||
-						(m["id"] != "" && gpu.id != m["id"]) {
-						continue
-					}
-
-					found = true
-
-					err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, gpu.major, gpu.minor, gpu.path, false)
-					if err != nil {
-						logger.Error("Failed to insert GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
-						return err
-					}
-
-					if !gpu.isNvidia {
-						continue
-					}
-
-					if gpu.nvidia.path != "" {
-						err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, gpu.nvidia.major, gpu.nvidia.minor, gpu.nvidia.path, false)
-						if err != nil {
-							logger.Error("Failed to insert GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
-							return err
-						}
-					} else if !allGpus {
-						errMsg := fmt.Errorf("Failed to detect correct \"/dev/nvidia\" path")
-						logger.Errorf("%s", errMsg)
-						return errMsg
-					}
-
-					sawNvidia = true
-				}
-
-				if sawNvidia {
-					for _, gpu := range nvidiaDevices {
-						if shared.IsTrue(c.expandedConfig["nvidia.runtime"]) {
-							if !gpu.isCard {
-								continue
-							}
-						}
-
-						if c.deviceExistsInDevicesFolder(k, gpu.path) {
-							continue
-						}
-
-						err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, gpu.major, gpu.minor, gpu.path, false)
-						if err != nil {
-							logger.Error("Failed to insert GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
-							return err
-						}
-					}
-				}
-
-				if !found {
-					msg := "Failed to detect requested GPU device"
-					logger.Error(msg)
-					return fmt.Errorf(msg)
-				}
-			} else if m["type"] == "proxy" {
-				err = c.insertProxyDevice(k, m)
-				if err != nil {
-					return err
-				}
-			}
-		}
-
-		err = c.addDiskDevices(diskDevices, c.insertDiskDevice)
-		if err != nil {
-			return err
-		}
-
-		updateDiskLimit := false
-		for k, m := range updateDevices {
-			if m["type"] == "disk" {
-				updateDiskLimit = true
-			} else if m["type"] == "nic" || m["type"] == "infiniband" {
-				needsUpdate := false
-				for _, v := range containerNetworkLimitKeys {
-					needsUpdate = shared.StringInSlice(v, updateDiff)
-					if needsUpdate {
-						break
-					}
-				}
-
-				if needsUpdate {
-					// Refresh tc limits
-					err = c.setNetworkLimits(k, m)
-					if err != nil {
-						return err
-					}
-				}
-			} else if m["type"] == "proxy" {
-				err = c.updateProxyDevice(k, m)
-				if err != nil {
-					return err
-				}
-			}
-		}
-
-		// Disk limits parse all devices, so just apply them once
-		if updateDiskLimit && c.state.OS.CGroupBlkioController {
-			diskLimits, err := c.getDiskLimits()
-			if err != nil {
-				return err
-			}
-
-			for block, limit := range diskLimits {
-				err = c.CGroupSet("blkio.throttle.read_bps_device", fmt.Sprintf("%s %d", block, limit.readBps))
-				if err != nil {
-					return err
-				}
-
-				err = c.CGroupSet("blkio.throttle.read_iops_device", fmt.Sprintf("%s %d", block, limit.readIops))
-				if err != nil {
-					return err
-				}
-
-				err = c.CGroupSet("blkio.throttle.write_bps_device", fmt.Sprintf("%s %d", block, limit.writeBps))
-				if err != nil {
-					return err
-				}
-
-				err = c.CGroupSet("blkio.throttle.write_iops_device", fmt.Sprintf("%s %d", block, limit.writeIops))
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-
-	// Cleanup any leftover volatile entries
-	netNames := []string{}
-	for _, k := range c.expandedDevices.DeviceNames() {
-		v := c.expandedDevices[k]
-		if v["type"] == "nic" || v["type"] == "infiniband" {
-			netNames = append(netNames, k)
-		}
-	}
-
-	for k := range c.localConfig {
-		// We only care about volatile
-		if !strings.HasPrefix(k, "volatile.") {
-			continue
-		}
+	for k := range c.localConfig {
+		// We only care about volatile
+		if !strings.HasPrefix(k, "volatile.") {
+			continue
+		}
 
 		// Confirm it's a key of format volatile.<device>.<key>
 		fields := strings.SplitN(k, ".", 3)
@@ -4659,6 +4459,15 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		delete(c.expandedConfig, k)
 	}
 
+	// Keep the profile priorities if the profiles themselves didn't change
+	profilePriorities := args.ProfilePriorities
+	if profilePriorities == nil && reflect.DeepEqual(oldProfiles, c.profiles) {
+		profilePriorities, err = c.state.Cluster.ContainerProfilePriorities(c.id)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Finally, apply the changes to the database
 	err = query.Retry(func() error {
 		tx, err := c.state.Cluster.Begin()
@@ -4678,7 +4487,7 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 			return err
 		}
 
-		err = db.ContainerProfilesInsert(tx, c.id, c.profiles)
+		err = db.ContainerProfilesInsert(tx, c.id, c.profiles, profilePriorities)
 		if err != nil {
 			tx.Rollback()
 			return err
@@ -4790,15 +4599,95 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		}
 	}
 
+	// Refresh the proxy devices listened on behalf of stopped containers
+	if !isRunning && !c.IsSnapshot() && !c.IsEphemeral() && len(removeDevices)+len(addDevices)+len(updateDevices) > 0 {
+		containerWakeRelease(c.name)
+		err = containerWakeListen(c.state, c, c.localConfig["volatile.suspended"] != "")
+		if err != nil {
+			logger.Error("Failed to listen on behalf of proxy devices", log.Ctx{"container": c.Name(), "err": err})
+		}
+	}
+
 	// Success, update the closure to mark that the changes should be kept.
 	undoChanges = false
 
+	containerHealthInvalidate()
 	eventSendLifecycle("container-updated",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
 
 	return nil
 }
 
+// Live update the devices of a running container through their drivers:
+// stop the removed ones, in the reverse order they were added in so nested
+// disks get unmounted first, then start the added ones and finally update the
+// changed ones.
+func (c *containerLXC) updateDevices(removeDevices types.Devices, addDevices types.Devices, updateDevices types.Devices, updateDiff map[string][]string) error {
+	removeNames := removeDevices.DeviceNames()
+	for i := len(removeNames) - 1; i >= 0; i-- {
+		k := removeNames[i]
+		m, err := c.deviceResolveBlockVolume(removeDevices[k])
+		if err != nil {
+			return err
+		}
+
+		driver, err := deviceDriverLoad(m["type"])
+		if err != nil {
+			return err
+		}
+
+		err = driver.Stop(c, k, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, k := range addDevices.DeviceNames() {
+		m, err := c.deviceResolveBlockVolume(addDevices[k])
+		if err != nil {
+			return err
+		}
+
+		driver, err := deviceDriverLoad(m["type"])
+		if err != nil {
+			return err
+		}
+
+		err = driver.Start(c, k, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, k := range updateDevices.DeviceNames() {
+		m := updateDevices[k]
+
+		driver, err := deviceDriverLoad(m["type"])
+		if err != nil {
+			return err
+		}
+
+		err = driver.Update(c, k, m, updateDiff[k])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Revert the changes partially applied by a failed update, by updating the
+// container back to its old configuration.
+func (c *containerLXC) updateRevert(args db.ContainerArgs) {
+	c.updateReverting = true
+	defer func() { c.updateReverting = false }()
+
+	err := c.Update(args, false)
+	if err != nil {
+		logger.Error("Failed to revert the changes of a failed update", log.Ctx{"container": c.name, "err": err})
+	}
+}
+
 func (c *containerLXC) Export(w io.Writer, properties map[string]string) error {
 	ctxMap := log.Ctx{"name": c.name,
 		"created":   c.creationDate,
@@ -4811,42 +4700,13 @@ func (c *containerLXC) Export(w io.Writer, properties map[string]string) error {
 
 	logger.Info("Exporting container", ctxMap)
 
-	// Start the storage
-	ourStart, err := c.StorageStart()
-	if err != nil {
-		logger.Error("Failed exporting container", ctxMap)
-		return err
-	}
-	if ourStart {
-		defer c.StorageStop()
-	}
-
-	// Unshift the container
-	idmap, err := c.LastIdmapSet()
+	// Start the storage and unshift the container
+	exportDone, err := c.exportStart()
 	if err != nil {
 		logger.Error("Failed exporting container", ctxMap)
 		return err
 	}
-
-	if idmap != nil {
-		var err error
-
-		if c.Storage().GetStorageType() == storageTypeZfs {
-			err = idmap.UnshiftRootfs(c.RootfsPath(), zfsIdmapSetSkipper)
-		} else {
-			err = idmap.UnshiftRootfs(c.RootfsPath(), nil)
-		}
-		if err != nil {
-			logger.Error("Failed exporting container", ctxMap)
-			return err
-		}
-
-		if c.Storage().GetStorageType() == storageTypeZfs {
-			defer idmap.ShiftRootfs(c.RootfsPath(), zfsIdmapSetSkipper)
-		} else {
-			defer idmap.ShiftRootfs(c.RootfsPath(), nil)
-		}
-	}
+	defer exportDone()
 
 	// Create the tarball
 	tw := tar.NewWriter(w)
@@ -4884,27 +4744,11 @@ func (c *containerLXC) Export(w io.Writer, properties map[string]string) error {
 		defer os.RemoveAll(tempDir)
 
 		// Get the container's architecture
-		var arch string
-		if c.IsSnapshot() {
-			parentName, _, _ := containerGetParentAndSnapshotName(c.name)
-			parent, err := containerLoadByName(c.state, parentName)
-			if err != nil {
-				tw.Close()
-				logger.Error("Failed exporting container", ctxMap)
-				return err
-			}
-
-			arch, _ = osarch.ArchitectureName(parent.Architecture())
-		} else {
-			arch, _ = osarch.ArchitectureName(c.architecture)
-		}
-
-		if arch == "" {
-			arch, err = osarch.ArchitectureName(c.state.OS.Architectures[0])
-			if err != nil {
-				logger.Error("Failed exporting container", ctxMap)
-				return err
-			}
+		arch, err := c.exportArchitecture()
+		if err != nil {
+			tw.Close()
+			logger.Error("Failed exporting container", ctxMap)
+			return err
 		}
 
 		// Fill in the metadata
@@ -5039,6 +4883,75 @@ func (c *containerLXC) Export(w io.Writer, properties map[string]string) error {
 	return nil
 }
 
+// Start the storage of the container and unshift its rootfs so that it can be
+// exported, returning a function undoing both.
+func (c *containerLXC) exportStart() (func(), error) {
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return nil, err
+	}
+
+	done := func() {
+		if ourStart {
+			c.StorageStop()
+		}
+	}
+
+	idmap, err := c.LastIdmapSet()
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	if idmap == nil {
+		return done, nil
+	}
+
+	if shared.IsTrue(c.expandedConfig["security.protection.shift"]) {
+		done()
+		return nil, fmt.Errorf("Container is protected against filesystem shifting")
+	}
+
+	var skipper func(dir string, absPath string, fi os.FileInfo) bool
+	if c.Storage().GetStorageType() == storageTypeZfs {
+		skipper = zfsIdmapSetSkipper
+	}
+
+	err = idmap.UnshiftRootfs(c.RootfsPath(), skipper)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	return func() {
+		idmap.ShiftRootfs(c.RootfsPath(), skipper)
+		done()
+	}, nil
+}
+
+// Return the name of the architecture of the container, or of its parent for
+// snapshots, falling back to the one of the host.
+func (c *containerLXC) exportArchitecture() (string, error) {
+	var arch string
+	if c.IsSnapshot() {
+		parentName, _, _ := containerGetParentAndSnapshotName(c.name)
+		parent, err := containerLoadByName(c.state, parentName)
+		if err != nil {
+			return "", err
+		}
+
+		arch, _ = osarch.ArchitectureName(parent.Architecture())
+	} else {
+		arch, _ = osarch.ArchitectureName(c.architecture)
+	}
+
+	if arch == "" {
+		return osarch.ArchitectureName(c.state.OS.Architectures[0])
+	}
+
+	return arch, nil
+}
+
 func collectCRIULogFile(c container, imagesDir string, function string, method string) error {
 	t := time.Now().Format(time.RFC3339)
 	newPath := shared.LogPath(c.Name(), fmt.Sprintf("%s_%s_%s.log", function, method, t))
@@ -5132,7 +5045,7 @@ func (c *containerLXC) Migrate(args *CriuMigrationArgs) error {
 	 */
 	if args.cmd == lxc.MIGRATE_RESTORE {
 		// Run the shared start
-		_, err := c.startCommon()
+		_, err := c.startCommon(nil)
 		if err != nil {
 			return err
 		}
@@ -5774,18 +5687,113 @@ func (c *containerLXC) ConsoleLog(opts lxc.ConsoleLogOptions) (string, error) {
 	return string(msg), nil
 }
 
-func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool) (*exec.Cmd, int, int, error) {
+// An execForkexecCmd is a command executed in the container through a dedicated
+// forkexec process.
+type execForkexecCmd struct {
+	cmd *exec.Cmd
+}
+
+// Wait for the command to exit and return its exit code.
+func (c *execForkexecCmd) Wait() (int, error) {
+	err := c.cmd.Wait()
+	if err == nil {
+		return 0, nil
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if ok {
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		if ok {
+			if status.Signaled() {
+				// 128 + n == Fatal error signal "n"
+				return 128 + int(status.Signal()), nil
+			}
+
+			return status.ExitStatus(), nil
+		}
+	}
+
+	return -1, err
+}
+
+func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool, cwd string, uid uint32, gid uint32, groups []uint32) (containerCommandRunner, int, int, error) {
 	envSlice := []string{}
 
 	for k, v := range env {
 		envSlice = append(envSlice, fmt.Sprintf("%s=%s", k, v))
 	}
 
+	var cmd containerCommandRunner
+	var attachedPid int
+	var err error
+	if shared.IsTrue(c.expandedConfig["linux.exec_agent"]) {
+		cmd, attachedPid, err = c.execWithAgent(command, envSlice, cwd, uid, gid, groups, stdin, stdout, stderr)
+	} else {
+		cmd, attachedPid, err = c.execWithForkexec(command, envSlice, cwd, uid, gid, groups, stdin, stdout, stderr)
+	}
+	if err != nil {
+		return nil, -1, -1, err
+	}
+
+	// It's the callers responsibility to wait or not wait.
+	if !wait {
+		return cmd, -1, attachedPid, nil
+	}
+
+	status, err := cmd.Wait()
+	if err != nil {
+		return nil, -1, -1, err
+	}
+
+	return nil, status, attachedPid, nil
+}
+
+// Run the command through the exec agent of the container, spawning the
+// agent if it isn't running yet.
+func (c *containerLXC) execWithAgent(command []string, env []string, cwd string, uid uint32, gid uint32, groups []uint32, stdin *os.File, stdout *os.File, stderr *os.File) (containerCommandRunner, int, error) {
+	agent, err := execAgentGet(c)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	req := execAgentRequest{Command: command, Env: env, Cwd: cwd, UID: uid, GID: gid, Groups: groups}
+	cmd, attachedPid, err := agent.run(req, stdin, stdout, stderr)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return cmd, attachedPid, nil
+}
+
+// Run the command through a dedicated forkexec process.
+func (c *containerLXC) execWithForkexec(command []string, env []string, cwd string, uid uint32, gid uint32, groups []uint32, stdin *os.File, stdout *os.File, stderr *os.File) (containerCommandRunner, int, error) {
 	args := []string{c.state.OS.ExecPath, "forkexec", c.name, c.state.OS.LxcPath, filepath.Join(c.LogPath(), "lxc.conf")}
 
+	args = append(args, "--")
+	args = append(args, "uid")
+	args = append(args, fmt.Sprintf("%d", uid))
+
+	args = append(args, "--")
+	args = append(args, "gid")
+	args = append(args, fmt.Sprintf("%d", gid))
+
+	if len(groups) > 0 {
+		args = append(args, "--")
+		args = append(args, "groups")
+		for _, group := range groups {
+			args = append(args, fmt.Sprintf("%d", group))
+		}
+	}
+
+	if cwd != "" {
+		args = append(args, "--")
+		args = append(args, "cwd")
+		args = append(args, cwd)
+	}
+
 	args = append(args, "--")
 	args = append(args, "env")
-	args = append(args, envSlice...)
+	args = append(args, env...)
 
 	args = append(args, "--")
 	args = append(args, "cmd")
@@ -5801,47 +5809,24 @@ func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.F
 	r, w, err := shared.Pipe()
 	defer r.Close()
 	if err != nil {
-		return nil, -1, -1, err
+		return nil, -1, err
 	}
 
 	cmd.ExtraFiles = []*os.File{w}
 	err = cmd.Start()
 	if err != nil {
 		w.Close()
-		return nil, -1, -1, err
+		return nil, -1, err
 	}
 	w.Close()
 
 	attachedPid := -1
 	if err := json.NewDecoder(r).Decode(&attachedPid); err != nil {
 		logger.Errorf("Failed to retrieve PID of executing child process: %s", err)
-		return nil, -1, -1, err
-	}
-
-	// It's the callers responsibility to wait or not wait.
-	if !wait {
-		return &cmd, -1, attachedPid, nil
-	}
-
-	err = cmd.Wait()
-	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
-		if ok {
-			status, ok := exitErr.Sys().(syscall.WaitStatus)
-			if ok {
-				return nil, status.ExitStatus(), attachedPid, nil
-			}
-
-			if status.Signaled() {
-				// 128 + n == Fatal error signal "n"
-				return nil, 128 + int(status.Signal()), attachedPid, nil
-			}
-		}
-
-		return nil, -1, -1, err
+		return nil, -1, err
 	}
 
-	return nil, 0, attachedPid, nil
+	return &execForkexecCmd{cmd: &cmd}, attachedPid, nil
 }
 
 func (c *containerLXC) cpuState() api.ContainerStateCPU {
@@ -5852,6 +5837,23 @@ func (c *containerLXC) cpuState() api.ContainerStateCPU {
 	}
 
 	// CPU usage in seconds
+	if c.state.OS.CGroupUnified {
+		value, err := c.CGroupGet("cpu.stat")
+		if err != nil {
+			cpu.Usage = -1
+			return cpu
+		}
+
+		valueInt, err := containerParseCPUUsage(value)
+		if err != nil {
+			cpu.Usage = -1
+			return cpu
+		}
+
+		cpu.Usage = valueInt
+		return cpu
+	}
+
 	value, err := c.CGroupGet("cpuacct.usage")
 	if err != nil {
 		cpu.Usage = -1
@@ -5899,6 +5901,39 @@ func (c *containerLXC) diskState() map[string]api.ContainerStateDisk {
 	return disk
 }
 
+// Return the effective blkio weight of the container, or -1 if unavailable.
+func (c *containerLXC) diskWeightState() int64 {
+	if !c.state.OS.CGroupBlkioController || !c.state.OS.CGroupBlkioWeight {
+		return -1
+	}
+
+	if c.state.OS.CGroupUnified {
+		value, err := c.CGroupGet("io.weight")
+		if err != nil {
+			return -1
+		}
+
+		weight, err := containerParseDiskWeight(value)
+		if err != nil {
+			return -1
+		}
+
+		return weight
+	}
+
+	value, err := c.CGroupGet("blkio.weight")
+	if err != nil {
+		return -1
+	}
+
+	weight, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return weight
+}
+
 func (c *containerLXC) memoryState() api.ContainerStateMemory {
 	memory := api.ContainerStateMemory{}
 
@@ -5906,6 +5941,32 @@ func (c *containerLXC) memoryState() api.ContainerStateMemory {
 		return memory
 	}
 
+	// The unified hierarchy reports swap separately, and only recent
+	// kernels track the peak usage there
+	if c.state.OS.CGroupUnified {
+		value, err := c.CGroupGet("memory.current")
+		valueInt, err1 := strconv.ParseInt(value, 10, 64)
+		if err == nil && err1 == nil {
+			memory.Usage = valueInt
+		}
+
+		value, err = c.CGroupGet("memory.peak")
+		valueInt, err1 = strconv.ParseInt(value, 10, 64)
+		if err == nil && err1 == nil {
+			memory.UsagePeak = valueInt
+		}
+
+		if c.state.OS.CGroupSwapAccounting {
+			value, err := c.CGroupGet("memory.swap.current")
+			valueInt, err1 := strconv.ParseInt(value, 10, 64)
+			if err == nil && err1 == nil {
+				memory.SwapUsage = valueInt
+			}
+		}
+
+		return memory
+	}
+
 	// Memory in bytes
 	value, err := c.CGroupGet("memory.usage_in_bytes")
 	valueInt, err1 := strconv.ParseInt(value, 10, 64)
@@ -6174,6 +6235,69 @@ func (c *containerLXC) StorageStop() (bool, error) {
 	return isOurOperation, err
 }
 
+// Return the index in the LXC configuration of the network interface of the
+// given nic or infiniband device.
+func (c *containerLXC) networkIndex(name string) int {
+	index := 0
+	for _, k := range c.expandedDevices.DeviceNames() {
+		if k == name {
+			break
+		}
+
+		m := c.expandedDevices[k]
+		if m["type"] == "nic" || m["type"] == "infiniband" {
+			index++
+		}
+	}
+
+	return index
+}
+
+// Return the index in the LXC configuration of the first tagged VLAN of the
+// given physical nic. The VLANs come after all the nics, so that the indexes
+// of the latter don't depend on them.
+func (c *containerLXC) networkVLANIndex(name string) int {
+	index := 0
+	before := true
+	for _, k := range c.expandedDevices.DeviceNames() {
+		if k == name {
+			before = false
+		}
+
+		m := c.expandedDevices[k]
+		if m["type"] != "nic" && m["type"] != "infiniband" {
+			continue
+		}
+
+		index++
+		if before && m["type"] == "nic" && m["nictype"] == "physical" {
+			index += len(networkParseVLANs(m["vlan.tagged"]))
+		}
+	}
+
+	return index
+}
+
+// Set or clear the delegation of the container's ZFS dataset. Clearing it is
+// a no-op for containers which aren't on ZFS.
+func (c *containerLXC) zfsDelegate(delegate bool) error {
+	err := c.initStorage()
+	if err != nil {
+		return err
+	}
+
+	s, ok := c.storage.(*storageZfs)
+	if !ok {
+		if delegate {
+			return fmt.Errorf("ZFS delegation requires the container to be on a ZFS storage pool")
+		}
+
+		return nil
+	}
+
+	return s.zfsContainerDelegate(c.Name(), delegate)
+}
+
 // Mount handling
 func (c *containerLXC) insertMount(source, target, fstype string, flags int) error {
 	var err error
@@ -6459,9 +6583,10 @@ func (c *containerLXC) insertUnixDevice(prefix string, m types.Device, defaultMo
 	}
 
 	if c.IsPrivileged() && !c.state.OS.RunningInUserNS && c.state.OS.CGroupDevicesController {
-		// Add the new device cgroup rule
+		// Add the new device cgroup rule, which liblxc adds to the
+		// eBPF program of the container on the unified hierarchy
 		if err := c.CGroupSet("devices.allow", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor)); err != nil {
-			return fmt.Errorf("Failed to add cgroup rule for device")
+			return fmt.Errorf("Failed to add cgroup rule for device: %v", err)
 		}
 	}
 
@@ -6531,7 +6656,8 @@ func (c *containerLXC) removeUnixDevice(prefix string, m types.Device, eject boo
 	}
 
 	if c.IsPrivileged() && !c.state.OS.RunningInUserNS && c.state.OS.CGroupDevicesController {
-		// Remove the device cgroup rule
+		// Remove the device cgroup rule, which liblxc removes from the
+		// eBPF program of the container on the unified hierarchy
 		err = c.CGroupSet("devices.deny", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor))
 		if err != nil {
 			return err
@@ -6659,7 +6785,7 @@ func (c *containerLXC) addInfinibandDevicesPerPort(deviceName string, ifDev *IBF
 				return err
 			}
 
-			err = lxcSetConfigItem(c.c, "lxc.cgroup.devices.allow", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor))
+			err = lxcSetConfigItem(c.c, cgroupConfigPrefix(c.state.OS)+"devices.allow", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor))
 			if err != nil {
 				return fmt.Errorf("Failed to add cgroup rule for device")
 			}
@@ -6707,7 +6833,7 @@ func (c *containerLXC) addInfinibandDevicesPerFun(deviceName string, ifDev *IBF,
 				return err
 			}
 
-			err = lxcSetConfigItem(c.c, "lxc.cgroup.devices.allow", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor))
+			err = lxcSetConfigItem(c.c, cgroupConfigPrefix(c.state.OS)+"devices.allow", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor))
 			if err != nil {
 				return fmt.Errorf("Failed to add cgroup rule for device")
 			}
@@ -6871,7 +6997,8 @@ func (c *containerLXC) insertProxyDevice(devName string, m types.Device) error {
 		pidPath,
 		proxyValues.listenAddrGid,
 		proxyValues.listenAddrUid,
-		proxyValues.listenAddrMode)
+		proxyValues.listenAddrMode,
+		proxyValues.connectTimeout)
 	if err != nil {
 		return fmt.Errorf("Error occurred when starting proxy device: %s", err)
 	}
@@ -6992,6 +7119,22 @@ func (c *containerLXC) createNetworkDevice(name string, m types.Device) (string,
 
 			// Attempt to disable IPv6 on the host side interface
 			networkSysctl(fmt.Sprintf("ipv6/conf/%s/disable_ipv6", n1), "1")
+
+			if shared.IsTrue(m["security.port_isolation"]) {
+				err = networkSetPortIsolation(n1, true)
+				if err != nil {
+					deviceRemoveInterface(n2)
+					return "", err
+				}
+			}
+
+			if m["vlan.tagged"] != "" {
+				err = networkSetPortVLANs(m["parent"], n1, networkParseVLANs(m["vlan.tagged"]))
+				if err != nil {
+					deviceRemoveInterface(n2)
+					return "", err
+				}
+			}
 		}
 
 		dev = n2
@@ -7004,7 +7147,7 @@ func (c *containerLXC) createNetworkDevice(name string, m types.Device) (string,
 		if m["vlan"] != "" {
 			device = networkGetHostDevice(m["parent"], m["vlan"])
 			if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s", device)) {
-				_, err := shared.RunCommand("ip", "link", "add", "link", m["parent"], "name", device, "up", "type", "vlan", "id", m["vlan"])
+				_, err := shared.RunCommand("ip", networkVLANArgs(m["parent"], device, m["vlan"], shared.IsTrue(m["vlan.gvrp"]))...)
 				if err != nil {
 					return "", err
 				}
@@ -7451,12 +7594,42 @@ func (c *containerLXC) insertNetworkDevice(name string, m types.Device) (types.D
 		return nil, err
 	}
 
+	// Create the tagged VLANs of physical nics while the parent is still
+	// on the host
+	vlanDevs := map[string]string{}
+	if m["nictype"] == "physical" {
+		for _, vlan := range networkParseVLANs(m["vlan.tagged"]) {
+			vlanDev := deviceNextVeth()
+			_, err := shared.RunCommand("ip", networkVLANArgs(m["parent"], vlanDev, vlan, false)...)
+			if err != nil {
+				for _, dev := range vlanDevs {
+					deviceRemoveInterface(dev)
+				}
+
+				return nil, err
+			}
+
+			vlanDevs[fmt.Sprintf("%s.%s", m["name"], vlan)] = vlanDev
+		}
+	}
+
 	// Add the interface to the container
 	err = c.c.AttachInterface(devName, m["name"])
 	if err != nil {
+		for _, dev := range vlanDevs {
+			deviceRemoveInterface(dev)
+		}
+
 		return nil, fmt.Errorf("Failed to attach interface: %s: %s", devName, err)
 	}
 
+	for vlanName, vlanDev := range vlanDevs {
+		err = c.c.AttachInterface(vlanDev, vlanName)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to attach interface: %s: %s", vlanDev, err)
+		}
+	}
+
 	return m, nil
 }
 
@@ -7487,7 +7660,21 @@ func (c *containerLXC) removeNetworkDevice(name string, m types.Device) error {
 	if err != nil {
 		return err
 	}
-	defer lxc.Release(cc)
+	defer lxc.Release(cc)
+
+	// Remove the tagged VLANs of physical nics
+	if m["nictype"] == "physical" {
+		for _, vlan := range networkParseVLANs(m["vlan.tagged"]) {
+			vlanName := fmt.Sprintf("%s.%s", m["name"], vlan)
+			vlanDev := deviceNextVeth()
+			err = cc.DetachInterfaceRename(vlanName, vlanDev)
+			if err != nil {
+				return fmt.Errorf("Failed to detach interface: %s: %s", vlanName, err)
+			}
+
+			deviceRemoveInterface(vlanDev)
+		}
+	}
 
 	// Remove the interface from the container
 	err = cc.DetachInterfaceRename(m["name"], hostName)
@@ -7500,6 +7687,12 @@ func (c *containerLXC) removeNetworkDevice(name string, m types.Device) error {
 		deviceRemoveInterface(hostName)
 	}
 
+	// Release its bridge VLANs
+	err = c.removeNetworkVLANs(types.Devices{name: m})
+	if err != nil {
+		return err
+	}
+
 	// Remove any filter
 	if m["nictype"] == "bridged" {
 		err = c.removeNetworkFilter(m["hwaddr"], m["parent"])
@@ -7512,6 +7705,27 @@ func (c *containerLXC) removeNetworkDevice(name string, m types.Device) error {
 }
 
 // Disk device handling
+
+// Block custom storage volumes are passed to the container as unix-block
+// devices rather than mounted, return the device to set up in place of the
+// given one.
+func (c *containerLXC) deviceResolveBlockVolume(m types.Device) (types.Device, error) {
+	blockDevice, err := storagePoolVolumeBlockDevice(c.state, m)
+	if err != nil {
+		if shared.IsTrue(m["optional"]) {
+			return m, nil
+		}
+
+		return nil, err
+	}
+
+	if blockDevice == nil {
+		return m, nil
+	}
+
+	return blockDevice, nil
+}
+
 func (c *containerLXC) createDiskDevice(name string, m types.Device) (string, error) {
 	// source paths
 	relativeDestPath := strings.TrimPrefix(m["path"], "/")
@@ -7524,9 +7738,62 @@ func (c *containerLXC) createDiskDevice(name string, m types.Device) (string, er
 	isReadOnly := shared.IsTrue(m["readonly"])
 	isRecursive := shared.IsTrue(m["recursive"])
 
+	// Map the RBD images backing Ceph sources, CephFS filesystems get
+	// mounted with the kernel client once the mount point exists
+	cephfsSource := ""
+	cephfsOptions := ""
+	mappedRBD := ""
+	revert := true
+	defer func() {
+		if revert && mappedRBD != "" {
+			deviceCephRBDUnmap(mappedRBD)
+		}
+	}()
+
 	isFile := false
-	if m["pool"] == "" {
+	imageFSType := ""
+	if m["pool"] == "" && deviceIsCephSource(m["source"]) {
+		clusterName := m["ceph.cluster_name"]
+		if clusterName == "" {
+			clusterName = "ceph"
+		}
+
+		userName := m["ceph.user_name"]
+		if userName == "" {
+			userName = "admin"
+		}
+
+		scheme, name, path, err := deviceParseCephSource(m["source"])
+		if err != nil {
+			return "", err
+		}
+
+		if scheme == "ceph" {
+			mappedRBD, err = deviceCephRBDMap(clusterName, userName, name, path, isReadOnly)
+			if err != nil {
+				if isOptional {
+					return "", nil
+				}
+				return "", fmt.Errorf("Failed to map RBD image %s: %s", m["source"], err)
+			}
+
+			srcPath = mappedRBD
+		} else {
+			cephfsSource, cephfsOptions, err = deviceCephFSMountOptions(clusterName, userName, name, path)
+			if err != nil {
+				if isOptional {
+					return "", nil
+				}
+				return "", fmt.Errorf("Failed to configure CephFS %s: %s", m["source"], err)
+			}
+		}
+	} else if m["pool"] == "" {
+		// ISO and squashfs images get loop-mounted read-only
 		isFile = !shared.IsDir(srcPath) && !deviceIsBlockdev(srcPath)
+		if isFile {
+			imageFSType = deviceImageFSType(srcPath)
+			isFile = imageFSType == ""
+		}
 	} else {
 		// Deal with mounting storage volumes created via the storage
 		// api. Extract the name of the storage volume that we are
@@ -7592,7 +7859,7 @@ func (c *containerLXC) createDiskDevice(name string, m types.Device) (string, er
 	}
 
 	// Check if the source exists
-	if !shared.PathExists(srcPath) {
+	if cephfsSource == "" && !shared.PathExists(srcPath) {
 		if isOptional {
 			return "", nil
 		}
@@ -7630,12 +7897,87 @@ func (c *containerLXC) createDiskDevice(name string, m types.Device) (string, er
 		}
 	}
 
+	// Shifted storage volumes get marked for shiftfs, the container then
+	// mounts them through shiftfs from its own user namespace
+	isShifted := false
+	if m["pool"] != "" {
+		var err error
+		isShifted, err = storagePoolVolumeIsShifted(c.state, m)
+		if err != nil && !isOptional {
+			return "", err
+		}
+	}
+
+	if isShifted {
+		flags := 0
+		if isReadOnly {
+			flags |= syscall.MS_RDONLY
+		}
+
+		err := syscall.Mount(srcPath, devPath, "shiftfs", uintptr(flags), "mark")
+		if err != nil {
+			return "", fmt.Errorf("Unable to mark %s for shiftfs at %s: %s", srcPath, devPath, err)
+		}
+
+		revert = false
+		return devPath, nil
+	}
+
+	if m["passthrough"] == "fuse" {
+		if !shared.IsDir(srcPath) {
+			return "", fmt.Errorf("FUSE passthrough requires a directory as source")
+		}
+
+		idmapset, err := c.LastIdmapSet()
+		if err != nil {
+			return "", err
+		}
+
+		err = deviceMountDiskFUSE(srcPath, devPath, isReadOnly, idmapset)
+		if err != nil {
+			return "", err
+		}
+
+		return devPath, nil
+	}
+
+	if imageFSType != "" {
+		// The loop device goes away along with the mount
+		loopF, err := prepareLoopDev(srcPath, LoFlagsAutoclear|LoFlagsReadOnly)
+		if err != nil {
+			return "", err
+		}
+		defer loopF.Close()
+
+		err = syscall.Mount(loopF.Name(), devPath, imageFSType, syscall.MS_RDONLY, "")
+		if err != nil {
+			return "", fmt.Errorf("Unable to mount %s at %s: %s", srcPath, devPath, err)
+		}
+
+		return devPath, nil
+	}
+
+	if cephfsSource != "" {
+		flags := 0
+		if isReadOnly {
+			flags |= syscall.MS_RDONLY
+		}
+
+		err := syscall.Mount(cephfsSource, devPath, "ceph", uintptr(flags), cephfsOptions)
+		if err != nil {
+			return "", fmt.Errorf("Unable to mount %s at %s: %s", m["source"], devPath, err)
+		}
+
+		return devPath, nil
+	}
+
 	// Mount the fs
 	err := deviceMountDisk(srcPath, devPath, isReadOnly, isRecursive, m["propagation"])
 	if err != nil {
 		return "", err
 	}
 
+	revert = false
 	return devPath, nil
 }
 
@@ -7647,6 +7989,17 @@ func (c *containerLXC) insertDiskDevice(name string, m types.Device) error {
 
 	isRecursive := shared.IsTrue(m["recursive"])
 
+	// shiftfs needs to be mounted from the container's user namespace,
+	// which only happens on startup
+	isShifted, err := storagePoolVolumeIsShifted(c.state, m)
+	if err != nil {
+		return err
+	}
+
+	if isShifted {
+		return fmt.Errorf("Shifted storage volumes can't be attached to running containers")
+	}
+
 	// Create the device on the host
 	devPath, err := c.createDiskDevice(name, m)
 	if err != nil {
@@ -7737,11 +8090,20 @@ func (c *containerLXC) removeDiskDevice(name string, m types.Device) error {
 	}
 
 	// Unmount the host side
+	source := deviceMountSource(devPath)
 	err = syscall.Unmount(devPath, syscall.MNT_DETACH)
 	if err != nil {
 		return err
 	}
 
+	// Unmap the RBD image backing Ceph sources
+	if deviceIsCephSource(m["source"]) && strings.HasPrefix(source, "/dev/rbd") {
+		err = deviceCephRBDUnmap(source)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Remove the host side
 	err = os.Remove(devPath)
 	if err != nil {
@@ -7763,6 +8125,17 @@ func (c *containerLXC) removeDiskDevices() error {
 		return err
 	}
 
+	// Find the mount points of the disks backed by Ceph sources
+	cephDevNames := []string{}
+	for name, m := range c.expandedDevices {
+		if m["type"] != "disk" || !deviceIsCephSource(m["source"]) {
+			continue
+		}
+
+		relativeDestPath := strings.TrimPrefix(m["path"], "/")
+		cephDevNames = append(cephDevNames, fmt.Sprintf("disk.%s.%s", strings.Replace(name, "/", "-", -1), strings.Replace(relativeDestPath, "/", "-", -1)))
+	}
+
 	// Go through all the unix devices
 	for _, f := range dents {
 		// Skip non-disk devices
@@ -7770,8 +8143,16 @@ func (c *containerLXC) removeDiskDevices() error {
 			continue
 		}
 
-		// Always try to unmount the host side
+		// Always try to unmount the host side, unmapping the RBD images
+		// backing Ceph sources
+		source := deviceMountSource(filepath.Join(c.DevicesPath(), f.Name()))
 		_ = syscall.Unmount(filepath.Join(c.DevicesPath(), f.Name()), syscall.MNT_DETACH)
+		if shared.StringInSlice(f.Name(), cephDevNames) && strings.HasPrefix(source, "/dev/rbd") {
+			err := deviceCephRBDUnmap(source)
+			if err != nil {
+				logger.Error("Failed to unmap RBD image", log.Ctx{"err": err, "device": source})
+			}
+		}
 
 		// Remove the entry
 		diskPath := filepath.Join(c.DevicesPath(), f.Name())
@@ -7785,6 +8166,28 @@ func (c *containerLXC) removeDiskDevices() error {
 }
 
 // Block I/O limits
+// setDiskLimits applies the limits of all the disks of a running container.
+// Limits parse all the devices, so they're always applied together.
+func (c *containerLXC) setDiskLimits() error {
+	if !c.state.OS.CGroupBlkioController {
+		return nil
+	}
+
+	diskLimits, err := c.getDiskLimits()
+	if err != nil {
+		return err
+	}
+
+	for _, setting := range containerDiskLimitSettings(c.state.OS, diskLimits) {
+		err = c.CGroupSet(setting.key, setting.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (c *containerLXC) getDiskLimits() (map[string]deviceBlockLimit, error) {
 	result := map[string]deviceBlockLimit{}
 
@@ -8022,6 +8425,135 @@ func (c *containerLXC) getHostInterface(name string) string {
 	return ""
 }
 
+// Toggle the isolation of the bridge port of the given nic, preventing it from
+// exchanging traffic with the other isolated ports of the bridge.
+func (c *containerLXC) setNetworkIsolation(name string, m types.Device) error {
+	if m["nictype"] != "bridged" {
+		return fmt.Errorf("Port isolation is only supported on bridged interfaces")
+	}
+
+	// Fill in some fields from volatile
+	m, err := c.fillNetworkDevice(name, m)
+	if err != nil {
+		return err
+	}
+
+	// Look for the host side interface name
+	veth := c.getHostInterface(m["name"])
+	if veth == "" {
+		return fmt.Errorf("LXC doesn't know about this device and the host_name property isn't set, can't find host side veth name")
+	}
+
+	return networkSetPortIsolation(veth, shared.IsTrue(m["security.port_isolation"]))
+}
+
+// Allow the tagged VLANs of the given nic on its bridge port.
+func (c *containerLXC) setNetworkVLANs(name string, m types.Device) error {
+	if m["nictype"] != "bridged" {
+		return fmt.Errorf("Tagged VLANs are only supported on bridged interfaces")
+	}
+
+	// Fill in some fields from volatile
+	m, err := c.fillNetworkDevice(name, m)
+	if err != nil {
+		return err
+	}
+
+	// Look for the host side interface name
+	veth := c.getHostInterface(m["name"])
+	if veth == "" {
+		return fmt.Errorf("LXC doesn't know about this device and the host_name property isn't set, can't find host side veth name")
+	}
+
+	return networkSetPortVLANs(m["parent"], veth, networkParseVLANs(m["vlan.tagged"]))
+}
+
+// Release the bridge VLANs of the given bridged nics once their ports are
+// gone, keeping the ones still used by the other nics of running containers.
+func (c *containerLXC) removeNetworkVLANs(devices types.Devices) error {
+	vlans := map[string][]string{}
+	for _, m := range devices {
+		if m["type"] != "nic" || m["nictype"] != "bridged" || m["vlan.tagged"] == "" {
+			continue
+		}
+
+		for _, vlan := range networkParseVLANs(m["vlan.tagged"]) {
+			if !shared.StringInSlice(vlan, vlans[m["parent"]]) {
+				vlans[m["parent"]] = append(vlans[m["parent"]], vlan)
+			}
+		}
+	}
+
+	if len(vlans) == 0 {
+		return nil
+	}
+
+	containers, err := containerLoadNodeAllLenient(c.state)
+	if err != nil {
+		return err
+	}
+
+	used := []types.Devices{}
+	for _, ct := range containers {
+		if ct.Name() == c.Name() {
+			continue
+		}
+
+		if ct.IsRunning() {
+			used = append(used, ct.ExpandedDevices())
+		}
+	}
+
+	// The other nics of this container, when it keeps running
+	if c.IsRunning() {
+		own := types.Devices{}
+		for k, m := range c.expandedDevices {
+			_, ok := devices[k]
+			if !ok {
+				own[k] = m
+			}
+		}
+
+		used = append(used, own)
+	}
+
+	for bridge, bridgeVLANs := range vlans {
+		err = networkReleasePortVLANs(bridge, bridgeVLANs, networkBridgeVLANsInUse(bridge, used))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Set the MTU of the given nic inside the container.
+func (c *containerLXC) setNetworkMTU(name string, m types.Device, mtu uint32) error {
+	pid := c.InitPID()
+	if pid < 1 {
+		return fmt.Errorf("Can't set the MTU of a stopped container")
+	}
+
+	// Fill in some fields from volatile
+	m, err := c.fillNetworkDevice(name, m)
+	if err != nil {
+		return err
+	}
+
+	out, err := shared.RunCommand(
+		c.state.OS.ExecPath,
+		"forknet",
+		"mtu",
+		fmt.Sprintf("%d", pid),
+		m["name"],
+		fmt.Sprintf("%d", mtu))
+	if err != nil {
+		return fmt.Errorf("Failed to set the MTU: %s", strings.TrimSpace(out))
+	}
+
+	return nil
+}
+
 func (c *containerLXC) setNetworkLimits(name string, m types.Device) error {
 	// We can only do limits on some network type
 	if m["nictype"] != "bridged" && m["nictype"] != "p2p" {
@@ -8090,13 +8622,19 @@ func (c *containerLXC) setNetworkLimits(name string, m types.Device) error {
 		}
 	}
 
-	if m["limits.egress"] != "" {
+	// Traffic leaving the container also goes through the aggregate limit
+	aggregate := ""
+	if c.networkAggregated(m) {
+		aggregate = c.networkAggregateDevice()
+	}
+
+	if m["limits.egress"] != "" || aggregate != "" {
 		out, err := shared.RunCommand("tc", "qdisc", "add", "dev", veth, "handle", "ffff:0", "ingress")
 		if err != nil {
 			return fmt.Errorf("Failed to create ingress tc qdisc: %s", out)
 		}
 
-		out, err = shared.RunCommand("tc", "filter", "add", "dev", veth, "parent", "ffff:0", "protocol", "all", "u32", "match", "u32", "0", "0", "police", "rate", fmt.Sprintf("%dbit", egressInt), "burst", "1024k", "mtu", "64kb", "drop")
+		out, err = shared.RunCommand("tc", networkEgressFilterArgs(veth, egressInt, aggregate)...)
 		if err != nil {
 			return fmt.Errorf("Failed to create ingress tc qdisc: %s", out)
 		}
@@ -8105,6 +8643,95 @@ func (c *containerLXC) setNetworkLimits(name string, m types.Device) error {
 	return nil
 }
 
+// networkEgressFilterArgs returns the arguments of tc to filter the traffic
+// leaving a container through the given host side interface, policing it at
+// the given rate if not zero and then redirecting it to the given aggregate
+// limit device if any.
+func networkEgressFilterArgs(veth string, egress int64, aggregate string) []string {
+	args := []string{"filter", "add", "dev", veth, "parent", "ffff:0", "protocol", "all", "u32", "match", "u32", "0", "0"}
+	if egress != 0 && aggregate != "" {
+		args = append(args, "action", "police", "rate", fmt.Sprintf("%dbit", egress), "burst", "1024k", "mtu", "64kb", "conform-exceed", "drop/pipe")
+	} else if egress != 0 {
+		args = append(args, "police", "rate", fmt.Sprintf("%dbit", egress), "burst", "1024k", "mtu", "64kb", "drop")
+	}
+
+	if aggregate != "" {
+		args = append(args, "action", "mirred", "egress", "redirect", "dev", aggregate)
+	}
+
+	return args
+}
+
+// networkAggregateDevice returns the name of the ifb device holding the
+// aggregate egress limit of the container.
+func (c *containerLXC) networkAggregateDevice() string {
+	return fmt.Sprintf("lxdifb%d", c.id)
+}
+
+// networkAggregated returns whether the traffic of a nic goes through the
+// aggregate egress limit of the container.
+func (c *containerLXC) networkAggregated(m types.Device) bool {
+	if c.expandedConfig["limits.network.egress"] == "" {
+		return false
+	}
+
+	return m["type"] == "nic" && shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"})
+}
+
+// setNetworkAggregateLimit sets up the ifb device that the traffic leaving
+// all the bridged and p2p nics of the container is redirected to, with a
+// single tc class enforcing limits.network.egress.
+func (c *containerLXC) setNetworkAggregateLimit() error {
+	limit := c.expandedConfig["limits.network.egress"]
+	if limit == "" {
+		return nil
+	}
+
+	limitInt, err := shared.ParseBitSizeString(limit)
+	if err != nil {
+		return err
+	}
+
+	ifb := c.networkAggregateDevice()
+	if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s", ifb)) {
+		out, err := shared.RunCommand("ip", "link", "add", "dev", ifb, "type", "ifb")
+		if err != nil {
+			return fmt.Errorf("Failed to create aggregate limit device: %s", out)
+		}
+	}
+
+	out, err := shared.RunCommand("ip", "link", "set", "dev", ifb, "up")
+	if err != nil {
+		return fmt.Errorf("Failed to bring up aggregate limit device: %s", out)
+	}
+
+	// Clean any existing entry
+	shared.RunCommand("tc", "qdisc", "del", "dev", ifb, "root")
+
+	out, err = shared.RunCommand("tc", "qdisc", "add", "dev", ifb, "root", "handle", "1:0", "htb", "default", "10")
+	if err != nil {
+		return fmt.Errorf("Failed to create root tc qdisc: %s", out)
+	}
+
+	out, err = shared.RunCommand("tc", "class", "add", "dev", ifb, "parent", "1:0", "classid", "1:10", "htb", "rate", fmt.Sprintf("%dbit", limitInt))
+	if err != nil {
+		return fmt.Errorf("Failed to create limit tc class: %s", out)
+	}
+
+	return nil
+}
+
+// removeNetworkAggregateLimit removes the ifb device of the aggregate egress
+// limit, if any.
+func (c *containerLXC) removeNetworkAggregateLimit() error {
+	ifb := c.networkAggregateDevice()
+	if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s", ifb)) {
+		return nil
+	}
+
+	return deviceRemoveInterface(ifb)
+}
+
 // Various state query functions
 func (c *containerLXC) IsStateful() bool {
 	return c.stateful
@@ -8136,6 +8763,12 @@ func (c *containerLXC) IsSnapshot() bool {
 }
 
 func (c *containerLXC) IsDeleteProtected() bool {
+	// Snapshots are only protected through their own config, the
+	// protection of their container and its profiles doesn't apply.
+	if c.IsSnapshot() {
+		return shared.IsTrue(c.localConfig["security.protection.delete"])
+	}
+
 	return shared.IsTrue(c.expandedConfig["security.protection.delete"])
 }
 
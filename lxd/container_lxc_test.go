@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// Only the bridged and p2p nics go through the aggregate egress limit, when
+// the container has one.
+func TestContainerLXC_NetworkAggregated(t *testing.T) {
+	c := &containerLXC{id: 42, expandedConfig: map[string]string{"limits.network.egress": "100Mbit"}}
+
+	assert.Equal(t, "lxdifb42", c.networkAggregateDevice())
+	assert.True(t, c.networkAggregated(types.Device{"type": "nic", "nictype": "bridged"}))
+	assert.True(t, c.networkAggregated(types.Device{"type": "nic", "nictype": "p2p"}))
+	assert.False(t, c.networkAggregated(types.Device{"type": "nic", "nictype": "macvlan"}))
+	assert.False(t, c.networkAggregated(types.Device{"type": "disk", "path": "/"}))
+
+	c.expandedConfig = map[string]string{}
+	assert.False(t, c.networkAggregated(types.Device{"type": "nic", "nictype": "bridged"}))
+}
+
+// The traffic leaving a nic gets policed by its own limit first, and then
+// redirected to the aggregate limit device.
+func TestNetworkEgressFilterArgs(t *testing.T) {
+	match := []string{"filter", "add", "dev", "veth0", "parent", "ffff:0", "protocol", "all", "u32", "match", "u32", "0", "0"}
+
+	assert.Equal(t,
+		append(match, "police", "rate", "1000bit", "burst", "1024k", "mtu", "64kb", "drop"),
+		networkEgressFilterArgs("veth0", 1000, ""))
+
+	assert.Equal(t,
+		append(match, "action", "mirred", "egress", "redirect", "dev", "lxdifb1"),
+		networkEgressFilterArgs("veth0", 0, "lxdifb1"))
+
+	assert.Equal(t,
+		append(match, "action", "police", "rate", "1000bit", "burst", "1024k", "mtu", "64kb", "conform-exceed", "drop/pipe", "action", "mirred", "egress", "redirect", "dev", "lxdifb1"),
+		networkEgressFilterArgs("veth0", 1000, "lxdifb1"))
+}
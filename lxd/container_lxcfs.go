@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Where lxcfs exposes its files on the host
+var lxcfsPath = "/var/lib/lxcfs"
+
+// Files provided by lxcfs, along with the path they cover in containers
+var lxcfsFiles = map[string]string{
+	"cpuinfo":    "proc/cpuinfo",
+	"cpu_online": "sys/devices/system/cpu/online",
+	"diskstats":  "proc/diskstats",
+	"loadavg":    "proc/loadavg",
+	"meminfo":    "proc/meminfo",
+	"stat":       "proc/stat",
+	"swaps":      "proc/swaps",
+	"uptime":     "proc/uptime",
+}
+
+// Parse a comma separated list of lxcfs files
+func lxcfsParseFiles(value string) ([]string, error) {
+	files := []string{}
+
+	for _, file := range strings.Split(value, ",") {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+
+		_, ok := lxcfsFiles[file]
+		if !ok {
+			return nil, fmt.Errorf("Unknown lxcfs file '%s'", file)
+		}
+
+		if shared.StringInSlice(file, files) {
+			return nil, fmt.Errorf("Duplicate lxcfs file '%s'", file)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// Whether the lxcfs files of a container are mounted by LXD, rather than by
+// the mount hook lxcfs ships for all containers
+func lxcfsManaged(config map[string]string) bool {
+	return (config["linux.lxcfs"] != "" && !shared.IsTrue(config["linux.lxcfs"])) || config["linux.lxcfs.files"] != ""
+}
+
+// Get the mount entries for the lxcfs files used by a container, skipping
+// those the running lxcfs doesn't provide
+func lxcfsMountEntries(config map[string]string) ([]string, error) {
+	entries := []string{}
+
+	if config["linux.lxcfs"] != "" && !shared.IsTrue(config["linux.lxcfs"]) {
+		return entries, nil
+	}
+
+	files, err := lxcfsParseFiles(config["linux.lxcfs.files"])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		for file := range lxcfsFiles {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+	}
+
+	for _, file := range files {
+		target := lxcfsFiles[file]
+		source := filepath.Join(lxcfsPath, target)
+		if !shared.PathExists(source) {
+			continue
+		}
+
+		entries = append(entries, fmt.Sprintf("%s %s none bind,create=file,optional", source, target))
+	}
+
+	return entries, nil
+}
+
+// Get the LXC configuration files to include from a directory, leaving out
+// those setting up lxcfs
+func lxcfsIncludes(dir string) ([]string, error) {
+	dents, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	includes := []string{}
+	for _, dent := range dents {
+		if dent.IsDir() || !strings.HasSuffix(dent.Name(), ".conf") || strings.Contains(dent.Name(), "lxcfs") {
+			continue
+		}
+
+		includes = append(includes, filepath.Join(dir, dent.Name()))
+	}
+
+	return includes, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Files are given as a comma separated list of known names.
+func TestLxcfsParseFiles(t *testing.T) {
+	files, err := lxcfsParseFiles("cpuinfo, meminfo,uptime,")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpuinfo", "meminfo", "uptime"}, files)
+
+	_, err = lxcfsParseFiles("cpuinfo,version")
+	assert.Error(t, err)
+
+	_, err = lxcfsParseFiles("cpuinfo,cpuinfo")
+	assert.Error(t, err)
+}
+
+// Only the requested files which lxcfs provides get mounted.
+func TestLxcfsMountEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-lxcfs-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(path string) { lxcfsPath = path }(lxcfsPath)
+	lxcfsPath = dir
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "proc"), 0755))
+	for _, name := range []string{"cpuinfo", "meminfo"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "proc", name), nil, 0644))
+	}
+
+	entries, err := lxcfsMountEntries(map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "proc/cpuinfo") + " proc/cpuinfo none bind,create=file,optional",
+		filepath.Join(dir, "proc/meminfo") + " proc/meminfo none bind,create=file,optional",
+	}, entries)
+
+	entries, err = lxcfsMountEntries(map[string]string{"linux.lxcfs.files": "meminfo,uptime"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "proc/meminfo") + " proc/meminfo none bind,create=file,optional"}, entries)
+
+	entries, err = lxcfsMountEntries(map[string]string{"linux.lxcfs": "false", "linux.lxcfs.files": "meminfo"})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
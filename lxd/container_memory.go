@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/sys"
+	"github.com/lxc/lxd/shared"
+)
+
+// A value to set on a CGroup key of a container.
+type cgroupSetting struct {
+	key   string
+	value string
+}
+
+// Parse limits.memory into a number of bytes.
+func containerParseMemoryLimit(memory string) (int64, error) {
+	if strings.HasSuffix(memory, "%") {
+		percent, err := strconv.ParseInt(strings.TrimSuffix(memory, "%"), 10, 64)
+		if err != nil {
+			return -1, err
+		}
+
+		memoryTotal, err := shared.DeviceTotalMemory()
+		if err != nil {
+			return -1, err
+		}
+
+		return int64((memoryTotal / 100) * percent), nil
+	}
+
+	return shared.ParseByteSizeString(memory)
+}
+
+// Get the memory CGroup settings which lift all the limits of a container.
+func containerMemoryResetSettings(os *sys.OS) []cgroupSetting {
+	settings := []cgroupSetting{}
+
+	if os.CGroupUnified {
+		if os.CGroupSwapAccounting {
+			settings = append(settings, cgroupSetting{"memory.swap.max", "max"})
+		}
+
+		return append(settings,
+			cgroupSetting{"memory.max", "max"},
+			cgroupSetting{"memory.high", "max"})
+	}
+
+	if os.CGroupSwapAccounting {
+		settings = append(settings, cgroupSetting{"memory.memsw.limit_in_bytes", "-1"})
+	}
+
+	return append(settings,
+		cgroupSetting{"memory.limit_in_bytes", "-1"},
+		cgroupSetting{"memory.soft_limit_in_bytes", "-1"})
+}
+
+// Get the memory CGroup settings of a container, in the order they need to
+// be applied, for the CGroup hierarchy in use on the host.
+//
+// On the unified hierarchy, the hard limit maps to memory.max, the soft limit
+// to memory.high, which throttles the container rather than protecting its
+// memory like memory.low would, and disabling swap to memory.swap.max. A hard
+// limit doesn't come with an implicit soft limit there. There is no
+// per-CGroup swappiness either, so limits.memory.swap.priority only applies
+// to the legacy hierarchy.
+func containerMemorySettings(os *sys.OS, config map[string]string) ([]cgroupSetting, error) {
+	settings := []cgroupSetting{}
+
+	memory := config["limits.memory"]
+	memoryEnforce := config["limits.memory.enforce"]
+	memorySwap := config["limits.memory.swap"]
+	memorySwapPriority := config["limits.memory.swap.priority"]
+
+	limitKey := "memory.limit_in_bytes"
+	softLimitKey := "memory.soft_limit_in_bytes"
+	if os.CGroupUnified {
+		limitKey = "memory.max"
+		softLimitKey = "memory.high"
+	}
+
+	// Configure the memory limits
+	if memory != "" {
+		valueInt, err := containerParseMemoryLimit(memory)
+		if err != nil {
+			return nil, err
+		}
+
+		if memoryEnforce == "soft" {
+			settings = append(settings, cgroupSetting{softLimitKey, fmt.Sprintf("%d", valueInt)})
+		} else {
+			settings = append(settings, cgroupSetting{limitKey, fmt.Sprintf("%d", valueInt)})
+
+			if !os.CGroupUnified && os.CGroupSwapAccounting && (memorySwap == "" || shared.IsTrue(memorySwap)) {
+				settings = append(settings, cgroupSetting{"memory.memsw.limit_in_bytes", fmt.Sprintf("%d", valueInt)})
+			}
+
+			// Set soft limit to value 10% less than hard limit
+			if !os.CGroupUnified {
+				settings = append(settings, cgroupSetting{softLimitKey, fmt.Sprintf("%.0f", float64(valueInt)*0.9)})
+			}
+		}
+	}
+
+	// Configure the swap
+	if os.CGroupUnified {
+		if os.CGroupSwapAccounting && memorySwap != "" && !shared.IsTrue(memorySwap) {
+			settings = append(settings, cgroupSetting{"memory.swap.max", "0"})
+		}
+	} else if memorySwap != "" && !shared.IsTrue(memorySwap) {
+		settings = append(settings, cgroupSetting{"memory.swappiness", "0"})
+	} else if memorySwapPriority != "" {
+		priority, err := strconv.Atoi(memorySwapPriority)
+		if err != nil {
+			return nil, err
+		}
+
+		settings = append(settings, cgroupSetting{"memory.swappiness", fmt.Sprintf("%d", 60-10+priority)})
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/sys"
+)
+
+// Hard limits also limit the swap on the legacy hierarchy, along with a soft
+// limit 10% below.
+func TestContainerMemorySettings_Legacy(t *testing.T) {
+	os := &sys.OS{CGroupSwapAccounting: true}
+
+	settings, err := containerMemorySettings(os, map[string]string{"limits.memory": "1GB", "limits.memory.swap.priority": "5"})
+	require.NoError(t, err)
+	assert.Equal(t, []cgroupSetting{
+		{"memory.limit_in_bytes", "1073741824"},
+		{"memory.memsw.limit_in_bytes", "1073741824"},
+		{"memory.soft_limit_in_bytes", "966367642"},
+		{"memory.swappiness", "55"},
+	}, settings)
+
+	settings, err = containerMemorySettings(os, map[string]string{"limits.memory": "1GB", "limits.memory.enforce": "soft", "limits.memory.swap": "false"})
+	require.NoError(t, err)
+	assert.Equal(t, []cgroupSetting{
+		{"memory.soft_limit_in_bytes", "1073741824"},
+		{"memory.swappiness", "0"},
+	}, settings)
+}
+
+// The unified hierarchy uses memory.max and memory.high, without an implicit
+// soft limit, and disables swap through memory.swap.max.
+func TestContainerMemorySettings_Unified(t *testing.T) {
+	os := &sys.OS{CGroupUnified: true, CGroupSwapAccounting: true}
+
+	settings, err := containerMemorySettings(os, map[string]string{"limits.memory": "1GB", "limits.memory.swap": "false", "limits.memory.swap.priority": "5"})
+	require.NoError(t, err)
+	assert.Equal(t, []cgroupSetting{
+		{"memory.max", "1073741824"},
+		{"memory.swap.max", "0"},
+	}, settings)
+
+	settings, err = containerMemorySettings(os, map[string]string{"limits.memory": "1GB", "limits.memory.enforce": "soft"})
+	require.NoError(t, err)
+	assert.Equal(t, []cgroupSetting{{"memory.high", "1073741824"}}, settings)
+
+	assert.Equal(t, []cgroupSetting{
+		{"memory.swap.max", "max"},
+		{"memory.max", "max"},
+		{"memory.high", "max"},
+	}, containerMemoryResetSettings(os))
+
+	_, err = containerMemorySettings(os, map[string]string{"limits.memory": "lots"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The exit of the init process of running containers is reported by the
+// kernel through the netlink process connector. The network facing
+// resources of a container (proxy devices and network filters) are released
+// as soon as its init process is gone, and the usual stop handling is run
+// by LXD itself if the liblxc stop hook doesn't come in shortly after, in
+// which case the stop hook is ignored if it comes in later.
+const (
+	netlinkConnector      = 11
+	cnIdxProc             = 1
+	cnValProc             = 1
+	procCnMcastListen     = 1
+	procEventExit         = 0x80000000
+	containerMonitorGrace = 5 * time.Second
+)
+
+type containerMonitorEntry struct {
+	id     int
+	pid    int
+	exited bool
+
+	// Set once the stop was handled by the monitor
+	handled bool
+
+	// Closed once the network facing resources have been released
+	released chan struct{}
+
+	// Closed once the stop has been handled
+	stopped chan struct{}
+}
+
+var containerMonitorLock sync.Mutex
+var containerMonitorByPid = map[int]*containerMonitorEntry{}
+var containerMonitorByID = map[int]*containerMonitorEntry{}
+
+// The process connector uses the host byte order.
+var containerMonitorByteOrder binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 0 {
+		containerMonitorByteOrder = binary.BigEndian
+	}
+}
+
+// Start watching the init process of the given running container, replacing
+// whatever was watched for a previous run of it.
+func containerMonitorWatch(c container) {
+	pid := c.InitPID()
+	if pid <= 0 {
+		return
+	}
+
+	containerMonitorLock.Lock()
+	defer containerMonitorLock.Unlock()
+
+	old := containerMonitorByID[c.Id()]
+	if old != nil {
+		delete(containerMonitorByPid, old.pid)
+	}
+
+	entry := &containerMonitorEntry{
+		id:       c.Id(),
+		pid:      pid,
+		released: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	containerMonitorByID[entry.id] = entry
+	containerMonitorByPid[entry.pid] = entry
+}
+
+// Stop watching the given container as the liblxc stop hook came in, waiting
+// for its resources to be released if its exit was already reported. Return
+// true if the stop was already handled by the monitor, in which case the hook
+// must be ignored.
+func containerMonitorStopped(id int) bool {
+	containerMonitorLock.Lock()
+	entry := containerMonitorByID[id]
+	if entry == nil {
+		containerMonitorLock.Unlock()
+		return false
+	}
+
+	delete(containerMonitorByID, id)
+	delete(containerMonitorByPid, entry.pid)
+	if entry.handled {
+		containerMonitorLock.Unlock()
+		return true
+	}
+
+	close(entry.stopped)
+	exited := entry.exited
+	containerMonitorLock.Unlock()
+
+	if exited {
+		<-entry.released
+	}
+
+	return false
+}
+
+// Return the stop target matching the wait status of the init process of a
+// container. The kernel kills it with SIGHUP when the container reboots.
+func containerMonitorTarget(exitCode uint32) string {
+	if exitCode&0x7f == uint32(syscall.SIGHUP) {
+		return "reboot"
+	}
+
+	return "stop"
+}
+
+// Handle the exit of a process with the given stop target, if it's the init
+// process of a container.
+func containerMonitorExit(s *state.State, pid int, target string) {
+	containerMonitorLock.Lock()
+	entry := containerMonitorByPid[pid]
+	if entry == nil {
+		containerMonitorLock.Unlock()
+		return
+	}
+
+	delete(containerMonitorByPid, pid)
+	if containerMonitorByID[entry.id] != entry {
+		containerMonitorLock.Unlock()
+		return
+	}
+
+	entry.exited = true
+	containerMonitorLock.Unlock()
+
+	go func() {
+		c, err := containerLoadById(s, entry.id)
+		if err != nil {
+			close(entry.released)
+			logger.Error("Failed to load exited container", log.Ctx{"id": entry.id, "err": err})
+			return
+		}
+
+		ct, ok := c.(*containerLXC)
+		if ok {
+			// The container is being torn down by liblxc
+			ct.fromHook = true
+
+			err = ct.removeProxyDevices()
+			if err != nil {
+				logger.Error("Unable to remove proxy devices", log.Ctx{"container": c.Name(), "err": err})
+			}
+
+			err = ct.removeNetworkFilters()
+			if err != nil {
+				logger.Error("Unable to remove network filters", log.Ctx{"container": c.Name(), "err": err})
+			}
+		}
+		close(entry.released)
+
+		select {
+		case <-entry.stopped:
+			return
+		case <-time.After(containerMonitorGrace):
+		}
+
+		// Nothing was heard from liblxc, handle the stop ourselves unless
+		// it was dealt with or the container started again in the meantime.
+		// The entry is kept around so that a late stop hook is ignored.
+		containerMonitorLock.Lock()
+		if containerMonitorByID[entry.id] != entry {
+			containerMonitorLock.Unlock()
+			return
+		}
+
+		entry.handled = true
+		close(entry.stopped)
+		containerMonitorLock.Unlock()
+
+		logger.Warn("Container exited without running its stop hook", log.Ctx{"container": c.Name(), "target": target})
+
+		c, err = containerLoadById(s, entry.id)
+		if err != nil {
+			logger.Error("Failed to load exited container", log.Ctx{"id": entry.id, "err": err})
+			return
+		}
+
+		ct, ok = c.(*containerLXC)
+		if !ok {
+			return
+		}
+
+		err = ct.onStop(target)
+		if err != nil {
+			logger.Error("Failed to handle container stop", log.Ctx{"container": c.Name(), "err": err})
+		}
+	}()
+}
+
+// Check the watched processes after events were lost.
+func containerMonitorRescan(s *state.State) {
+	containerMonitorLock.Lock()
+	pids := []int{}
+	for pid := range containerMonitorByPid {
+		pids = append(pids, pid)
+	}
+	containerMonitorLock.Unlock()
+
+	// The exit status of the processes is lost
+	for _, pid := range pids {
+		if syscall.Kill(pid, 0) == syscall.ESRCH {
+			containerMonitorExit(s, pid, "stop")
+		}
+	}
+}
+
+// Return the PID and wait status of the process whose exit is reported by the
+// given process connector message, stripped of its netlink header.
+func containerMonitorParse(data []byte) (int, uint32, bool) {
+	// struct cn_msg, followed by struct proc_event
+	if len(data) < 48 {
+		return -1, 0, false
+	}
+
+	if containerMonitorByteOrder.Uint32(data[0:4]) != cnIdxProc || containerMonitorByteOrder.Uint32(data[4:8]) != cnValProc {
+		return -1, 0, false
+	}
+
+	if containerMonitorByteOrder.Uint32(data[20:24]) != procEventExit {
+		return -1, 0, false
+	}
+
+	// Skip the exit of threads other than the main one
+	pid := containerMonitorByteOrder.Uint32(data[36:40])
+	tgid := containerMonitorByteOrder.Uint32(data[40:44])
+	if pid != tgid {
+		return -1, 0, false
+	}
+
+	return int(pid), containerMonitorByteOrder.Uint32(data[44:48]), true
+}
+
+func containerMonitorListen() (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM, netlinkConnector)
+	if err != nil {
+		return -1, err
+	}
+
+	// Let the kernel pick the port ID, the uevent listener uses our PID
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: cnIdxProc,
+	})
+	if err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+
+	// struct nlmsghdr, struct cn_msg and the listen operation
+	msg := make([]byte, syscall.NLMSG_HDRLEN+24)
+	containerMonitorByteOrder.PutUint32(msg[0:4], uint32(len(msg)))
+	containerMonitorByteOrder.PutUint16(msg[4:6], syscall.NLMSG_DONE)
+	containerMonitorByteOrder.PutUint32(msg[16:20], cnIdxProc)
+	containerMonitorByteOrder.PutUint32(msg[20:24], cnValProc)
+	containerMonitorByteOrder.PutUint16(msg[32:34], 4)
+	containerMonitorByteOrder.PutUint32(msg[36:40], procCnMcastListen)
+
+	err = syscall.Sendto(fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+	if err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+
+	return fd, nil
+}
+
+// Watch the running containers and start listening for process exits.
+func containerMonitorStart(s *state.State) {
+	containers, err := containerLoadNodeAllLenient(s)
+	if err != nil {
+		logger.Error("Failed to load containers to monitor", log.Ctx{"err": err})
+		return
+	}
+
+	for _, c := range containers {
+		if c.IsRunning() {
+			containerMonitorWatch(c)
+		}
+	}
+
+	fd, err := containerMonitorListen()
+	if err != nil {
+		logger.Warn("Unable to monitor container exits, relying on liblxc hooks only", log.Ctx{"err": err})
+		return
+	}
+
+	go func() {
+		b := make([]byte, os.Getpagesize())
+		for {
+			n, _, err := syscall.Recvfrom(fd, b, 0)
+			if err == syscall.ENOBUFS {
+				// Some events were dropped by the kernel
+				containerMonitorRescan(s)
+				continue
+			}
+
+			if err == syscall.EINTR {
+				continue
+			}
+
+			if err != nil {
+				logger.Error("Failed to receive process events, relying on liblxc hooks only", log.Ctx{"err": err})
+				syscall.Close(fd)
+				return
+			}
+
+			msgs, err := syscall.ParseNetlinkMessage(b[:n])
+			if err != nil {
+				continue
+			}
+
+			for _, msg := range msgs {
+				pid, exitCode, ok := containerMonitorParse(msg.Data)
+				if !ok {
+					continue
+				}
+
+				containerMonitorExit(s, pid, containerMonitorTarget(exitCode))
+			}
+		}
+	}()
+}
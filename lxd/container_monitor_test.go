@@ -0,0 +1,65 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Build a process connector message reporting the exit of a thread.
+func containerMonitorMessage(what uint32, pid uint32, tgid uint32, exitCode uint32) []byte {
+	data := make([]byte, 52)
+	containerMonitorByteOrder.PutUint32(data[0:4], cnIdxProc)
+	containerMonitorByteOrder.PutUint32(data[4:8], cnValProc)
+	containerMonitorByteOrder.PutUint16(data[16:18], 32)
+	containerMonitorByteOrder.PutUint32(data[20:24], what)
+	containerMonitorByteOrder.PutUint32(data[36:40], pid)
+	containerMonitorByteOrder.PutUint32(data[40:44], tgid)
+	containerMonitorByteOrder.PutUint32(data[44:48], exitCode)
+
+	return data
+}
+
+// Only the exit of the main thread of a process is reported.
+func TestContainerMonitorParse(t *testing.T) {
+	pid, exitCode, ok := containerMonitorParse(containerMonitorMessage(procEventExit, 1234, 1234, 256))
+	assert.True(t, ok)
+	assert.Equal(t, 1234, pid)
+	assert.Equal(t, uint32(256), exitCode)
+
+	_, _, ok = containerMonitorParse(containerMonitorMessage(procEventExit, 1235, 1234, 0))
+	assert.False(t, ok)
+
+	// PROC_EVENT_FORK
+	_, _, ok = containerMonitorParse(containerMonitorMessage(0x00000001, 1234, 1234, 0))
+	assert.False(t, ok)
+
+	_, _, ok = containerMonitorParse(containerMonitorMessage(procEventExit, 1234, 1234, 0)[:44])
+	assert.False(t, ok)
+}
+
+// An init process killed by SIGHUP is a reboot.
+func TestContainerMonitorTarget(t *testing.T) {
+	assert.Equal(t, "reboot", containerMonitorTarget(uint32(syscall.SIGHUP)))
+	assert.Equal(t, "stop", containerMonitorTarget(uint32(syscall.SIGINT)))
+	assert.Equal(t, "stop", containerMonitorTarget(0))
+	assert.Equal(t, "stop", containerMonitorTarget(1<<8))
+}
+
+// The stop hook is ignored once the monitor handled the stop, and only then.
+func TestContainerMonitorStopped(t *testing.T) {
+	entry := &containerMonitorEntry{id: 42, pid: 1234, released: make(chan struct{}), stopped: make(chan struct{})}
+	containerMonitorByID[entry.id] = entry
+	containerMonitorByPid[entry.pid] = entry
+	defer delete(containerMonitorByID, entry.id)
+
+	assert.False(t, containerMonitorStopped(entry.id))
+	assert.Nil(t, containerMonitorByPid[entry.pid])
+
+	entry = &containerMonitorEntry{id: 42, pid: 1235, handled: true, released: make(chan struct{}), stopped: make(chan struct{})}
+	containerMonitorByID[entry.id] = entry
+
+	assert.True(t, containerMonitorStopped(entry.id))
+	assert.False(t, containerMonitorStopped(entry.id))
+}
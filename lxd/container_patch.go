@@ -81,6 +81,12 @@ func containerPatch(d *Daemon, r *http.Request) Response {
 		req.Ephemeral = c.IsEphemeral()
 	}
 
+	// Check if description was passed
+	_, err = reqRaw.GetString("description")
+	if err != nil {
+		req.Description = c.Description()
+	}
+
 	// Check if profiles was passed
 	if req.Profiles == nil {
 		req.Profiles = c.Profiles()
@@ -110,7 +116,28 @@ func containerPatch(d *Daemon, r *http.Request) Response {
 		}
 	}
 
-	// Update container configuration
+	// Config keys and devices set to null get removed
+	for _, field := range []string{"config", "devices"} {
+		entries, ok := reqRaw[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for k, v := range entries {
+			if v != nil {
+				continue
+			}
+
+			if field == "config" {
+				delete(req.Config, k)
+			} else {
+				delete(req.Devices, k)
+			}
+		}
+	}
+
+	// Update container configuration, all the changes being validated
+	// together and reverted together should any of them fail
 	args := db.ContainerArgs{
 		Architecture: architecture,
 		Config:       req.Config,
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+)
+
+// Get the names of the nodes hosting the containers referenced by the value
+// of a placement.affinity or placement.anti-affinity key. The value is a comma
+// separated list of container names and of groups prefixed with "@", a group
+// being all the containers with a matching placement.group key. The container
+// being placed, if it exists already, isn't taken into account.
+func containerPlacementNodes(value string, self string, containerNodes map[string]string, groups map[string]string) map[string]bool {
+	nodes := map[string]bool{}
+	for _, ref := range strings.Split(value, ",") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+
+		if strings.HasPrefix(ref, "@") {
+			for name, group := range groups {
+				if group == ref[1:] && name != self {
+					nodes[containerNodes[name]] = true
+				}
+			}
+
+			continue
+		}
+
+		node, ok := containerNodes[ref]
+		if ok && ref != self {
+			nodes[node] = true
+		}
+	}
+
+	return nodes
+}
+
+// Return whether the value of a placement key references the container with
+// the given name or placement group.
+func containerPlacementReferences(value string, name string, group string) bool {
+	for _, ref := range strings.Split(value, ",") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+
+		if ref == name || (group != "" && ref == "@"+group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get a function accepting the nodes a container with the given expanded
+// configuration may be placed on. The container has to land on a node hosting
+// one of its affinity references if any of them exists already, and never on
+// a node hosting one of its anti-affinity references, nor on a node hosting a
+// container referencing it in its own anti-affinity key.
+func containerPlacementFilter(tx *db.ClusterTx, config map[string]string, self string) (func(node string) bool, error) {
+	containerNodes, err := tx.ContainersByNodeName()
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := tx.ContainersExpandedConfigValue("placement.group")
+	if err != nil {
+		return nil, err
+	}
+
+	antiAffinities, err := tx.ContainersExpandedConfigValue("placement.anti-affinity")
+	if err != nil {
+		return nil, err
+	}
+
+	affinity := containerPlacementNodes(config["placement.affinity"], self, containerNodes, groups)
+	antiAffinity := containerPlacementNodes(config["placement.anti-affinity"], self, containerNodes, groups)
+
+	for name, value := range antiAffinities {
+		if name != self && containerPlacementReferences(value, self, config["placement.group"]) {
+			antiAffinity[containerNodes[name]] = true
+		}
+	}
+
+	return func(node string) bool {
+		if len(affinity) > 0 && !affinity[node] {
+			return false
+		}
+
+		return !antiAffinity[node]
+	}, nil
+}
+
+// Get the expanded placement keys of a new container, out of its local
+// configuration and of the given profiles.
+func containerPlacementConfig(tx *db.ClusterTx, config map[string]string, profiles []string) (map[string]string, error) {
+	if profiles == nil {
+		profiles = []string{"default"}
+	}
+
+	profileConfigs, _, err := tx.ProfilesExpandData(profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := map[string]string{}
+	for _, key := range []string{"placement.affinity", "placement.anti-affinity", "placement.group"} {
+		for _, profile := range profiles {
+			value, ok := profileConfigs[profile][key]
+			if ok {
+				expanded[key] = value
+			}
+		}
+
+		value, ok := config[key]
+		if ok {
+			expanded[key] = value
+		}
+	}
+
+	return expanded, nil
+}
+
+// Get the expanded placement keys of an existing container.
+func containerPlacementConfigLoad(tx *db.ClusterTx, name string) (map[string]string, error) {
+	expanded := map[string]string{}
+	for _, key := range []string{"placement.affinity", "placement.anti-affinity", "placement.group"} {
+		values, err := tx.ContainersExpandedConfigValue(key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, ok := values[name]
+		if ok {
+			expanded[key] = value
+		}
+	}
+
+	return expanded, nil
+}
+
+// Pick the node a new container with the given expanded configuration should
+// be created on, that is the node with the least containers out of the ones
+// allowed by its placement keys.
+func containerPlacementNode(tx *db.ClusterTx, config map[string]string, name string) (string, error) {
+	count, err := tx.NodesCount()
+	if err != nil {
+		return "", err
+	}
+
+	// Placement keys only make sense in a cluster
+	if count < 2 {
+		return tx.NodeWithLeastContainers()
+	}
+
+	filter, err := containerPlacementFilter(tx, config, name)
+	if err != nil {
+		return "", err
+	}
+
+	node, err := tx.NodeWithLeastContainersMatching(filter)
+	if err != nil {
+		return "", err
+	}
+
+	if node == "" {
+		return "", fmt.Errorf("No online node satisfies the placement constraints of the container")
+	}
+
+	return node, nil
+}
+
+// Check that a container with the given expanded configuration may be placed
+// on the given node.
+func containerPlacementCheck(tx *db.ClusterTx, config map[string]string, self string, node string) error {
+	count, err := tx.NodesCount()
+	if err != nil || count < 2 {
+		return err
+	}
+
+	filter, err := containerPlacementFilter(tx, config, self)
+	if err != nil {
+		return err
+	}
+
+	if !filter(node) {
+		return fmt.Errorf("Node \"%s\" doesn't satisfy the placement constraints of the container", node)
+	}
+
+	return nil
+}
+
+// Check that containers may be placed on the nodes of the cluster, which is
+// not the case during a rolling upgrade: the nodes already upgraded don't
+// serve any request until the others catch up, and the others are about to
+// be restarted.
+func containerPlacementUpgradeCheck(tx *db.ClusterTx) error {
+	status, err := cluster.UpgradeStatus(tx)
+	if err != nil {
+		return err
+	}
+
+	if len(status.Pending) > 0 {
+		return fmt.Errorf("Cluster upgrade in progress, %s", status.Message)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Placement references resolve to the nodes of the named containers and of
+// the members of the referenced groups, leaving the placed container out.
+func TestContainerPlacementNodes(t *testing.T) {
+	containerNodes := map[string]string{
+		"c1": "node1",
+		"c2": "node2",
+		"c3": "node3",
+	}
+
+	groups := map[string]string{
+		"c1": "db",
+		"c2": "db",
+		"c3": "web",
+	}
+
+	assert.Equal(t, map[string]bool{"node1": true, "node2": true}, containerPlacementNodes("@db", "", containerNodes, groups))
+	assert.Equal(t, map[string]bool{"node2": true}, containerPlacementNodes("@db", "c1", containerNodes, groups))
+	assert.Equal(t, map[string]bool{"node1": true, "node3": true}, containerPlacementNodes("c1, @web,c4", "", containerNodes, groups))
+	assert.Equal(t, map[string]bool{}, containerPlacementNodes("", "", containerNodes, groups))
+}
+
+// A placement key references a container by its name or by its group.
+func TestContainerPlacementReferences(t *testing.T) {
+	assert.True(t, containerPlacementReferences("c1,@db", "c1", ""))
+	assert.True(t, containerPlacementReferences("c1, @db", "c2", "db"))
+	assert.False(t, containerPlacementReferences("c1,@db", "c2", "web"))
+	assert.False(t, containerPlacementReferences("@", "c2", ""))
+}
@@ -80,6 +80,33 @@ func containerPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Target node is offline"))
 	}
 
+	// Moves have to honor the placement keys of the container too.
+	if targetNode != "" {
+		var config map[string]string
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			config, err = containerPlacementConfigLoad(tx, name)
+			return err
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			return containerPlacementUpgradeCheck(tx)
+		})
+		if err != nil {
+			return Unavailable(err)
+		}
+
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			return containerPlacementCheck(tx, config, name, targetNode)
+		})
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
 	var c container
 
 	// For in-cluster migrations, only forward the request to the source
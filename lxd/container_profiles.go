@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerProfilesCmd = Command{
+	name: "containers/{name}/profiles",
+	get:  containerProfilesGet,
+	put:  containerProfilesPut,
+}
+
+// Return the profiles of the container in the order they're applied, along
+// with their priorities.
+func doContainerProfilesGet(s *state.State, c container) (*api.ContainerProfilesPut, error) {
+	priorities, err := s.Cluster.ContainerProfilePriorities(c.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := api.ContainerProfilesPut{
+		Profiles: []api.ContainerProfilePriority{},
+	}
+
+	for _, name := range c.Profiles() {
+		profiles.Profiles = append(profiles.Profiles, api.ContainerProfilePriority{
+			Name:     name,
+			Priority: priorities[name],
+		})
+	}
+
+	return &profiles, nil
+}
+
+func containerProfilesGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByName(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	profiles, err := doContainerProfilesGet(d.State(), c)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseETag(true, profiles, profiles.Profiles)
+}
+
+// Replace the profiles of a container and their priorities in one go. The
+// expanded config and devices are validated before anything gets committed.
+func containerProfilesPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByName(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	current, err := doContainerProfilesGet(d.State(), c)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	err = util.EtagCheck(r, current.Profiles)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.ContainerProfilesPut{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	profiles := []string{}
+	priorities := map[string]int{}
+	for _, profile := range req.Profiles {
+		if shared.StringInSlice(profile.Name, profiles) {
+			return BadRequest(fmt.Errorf("Duplicate profile found in request: %s", profile.Name))
+		}
+
+		profiles = append(profiles, profile.Name)
+		priorities[profile.Name] = profile.Priority
+	}
+
+	do := func(op *operation) error {
+		args := db.ContainerArgs{
+			Architecture:      c.Architecture(),
+			Config:            c.LocalConfig(),
+			Description:       c.Description(),
+			Devices:           c.LocalDevices(),
+			Ephemeral:         c.IsEphemeral(),
+			Profiles:          profiles,
+			ProfilePriorities: priorities,
+		}
+
+		return c.Update(args, true)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, operationClassTask, "Updating container profiles", resources, nil, do, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
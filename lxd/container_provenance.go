@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var containerProvenanceCmd = Command{
+	name: "containers/{name}/provenance",
+	get:  containerProvenanceGet,
+}
+
+func containerProvenanceGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByName(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	profiles := c.Profiles()
+	profileConfigs := make([]map[string]string, len(profiles))
+	profileDevices := make([]types.Devices, len(profiles))
+	for i, profile := range profiles {
+		profileConfigs[i], err = d.cluster.ProfileConfig(profile)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		profileDevices[i], err = d.cluster.Devices(profile, true)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	provenance := containerProvenance(profiles, profileConfigs, profileDevices, c.LocalConfig(), c.LocalDevices())
+
+	return SyncResponse(true, provenance)
+}
+
+// Work out where each effective config key and device of a container comes
+// from, following the same precedence as the config expansion: profiles are
+// applied in order and the local config goes on top.
+func containerProvenance(profiles []string, profileConfigs []map[string]string, profileDevices []types.Devices, localConfig map[string]string, localDevices types.Devices) api.ContainerProvenance {
+	provenance := api.ContainerProvenance{
+		Config:  map[string]api.ContainerProvenanceEntry{},
+		Devices: map[string]api.ContainerProvenanceEntry{},
+	}
+
+	set := func(entries map[string]api.ContainerProvenanceEntry, key string, source string) {
+		entry, ok := entries[key]
+		if ok {
+			entry.Overrides = append(entry.Overrides, entry.Source)
+		} else {
+			entry.Overrides = []string{}
+		}
+
+		entry.Source = source
+		entries[key] = entry
+	}
+
+	for i, profile := range profiles {
+		source := fmt.Sprintf("/%s/profiles/%s", version.APIVersion, profile)
+
+		for key := range profileConfigs[i] {
+			set(provenance.Config, key, source)
+		}
+
+		for name := range profileDevices[i] {
+			set(provenance.Devices, name, source)
+		}
+	}
+
+	for key := range localConfig {
+		set(provenance.Config, key, "local")
+	}
+
+	for name := range localDevices {
+		set(provenance.Devices, name, "local")
+	}
+
+	return provenance
+}
@@ -80,9 +80,17 @@ func containerPut(d *Daemon, r *http.Request) Response {
 
 		opDescription = "Updating container"
 	} else {
+		if !shared.StringInSlice(configRaw.RestoreOnly, []string{"", "filesystem", "config"}) {
+			return BadRequest(fmt.Errorf("Invalid restore_only value: %s", configRaw.RestoreOnly))
+		}
+
+		if configRaw.RestoreOnly != "" && configRaw.Stateful {
+			return BadRequest(fmt.Errorf("Stateful restores can't be partial"))
+		}
+
 		// Snapshot Restore
 		do = func(op *operation) error {
-			return containerSnapRestore(d.State(), name, configRaw.Restore, configRaw.Stateful)
+			return containerSnapRestore(d.State(), name, configRaw.Restore, configRaw.Stateful, configRaw.RestoreOnly)
 		}
 
 		opDescription = "Restoring snapshot"
@@ -99,7 +107,7 @@ func containerPut(d *Daemon, r *http.Request) Response {
 	return OperationResponse(op)
 }
 
-func containerSnapRestore(s *state.State, name string, snap string, stateful bool) error {
+func containerSnapRestore(s *state.State, name string, snap string, stateful bool, only string) error {
 	// normalize snapshot name
 	if !shared.IsSnapshot(snap) {
 		snap = name + shared.SnapshotDelimiter + snap
@@ -120,7 +128,7 @@ func containerSnapRestore(s *state.State, name string, snap string, stateful boo
 		}
 	}
 
-	err = c.Restore(source, stateful)
+	err = c.Restore(source, stateful, only)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The script is fed on stdin to a small wrapper which saves it to a temporary
+// file inside the container and executes it, so that scripts with their own
+// interpreter line work as well as plain shell ones.
+const scriptWrapper = `f=$(mktemp) || exit 1
+cat > "$f" && chmod 700 "$f" && "$f"
+r=$?
+rm -f "$f"
+exit $r`
+
+// Start the run.once script of the container in the background, unless it
+// already ran since the container was created. The script is marked as
+// running beforehand, so that it's never run twice even if LXD or the
+// container go away in the middle.
+func (c *containerLXC) runOnceStart() error {
+	script := c.expandedConfig["run.once"]
+	if script == "" || c.localConfig["volatile.run_once.status"] != "" {
+		return nil
+	}
+
+	err := c.ConfigKeySet("volatile.run_once.status", "running")
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		var status string
+
+		exitCode, err := c.runOnce(script)
+		if err != nil {
+			logger.Error("Failed to run run.once script", log.Ctx{"container": c.name, "err": err})
+			status = "error"
+		} else {
+			status = fmt.Sprintf("%d", exitCode)
+			logger.Info("Ran run.once script", log.Ctx{"container": c.name, "status": exitCode})
+		}
+
+		// The configuration may have changed while the script ran, so
+		// record its status on a fresh copy of the container.
+		current, err := containerLoadByName(c.state, c.name)
+		if err == nil {
+			err = current.ConfigKeySet("volatile.run_once.status", status)
+		}
+		if err != nil {
+			logger.Error("Failed to record run.once status", log.Ctx{"container": c.name, "err": err})
+		}
+
+		eventSendLifecycle("container-run-once",
+			fmt.Sprintf("/1.0/containers/%s", c.name),
+			map[string]interface{}{
+				"status": status,
+			})
+	}()
+
+	return nil
+}
+
+// Run the given script inside the container as root, logging its output to
+// run_once.log, and return its exit code.
+func (c *containerLXC) runOnce(script string) (int, error) {
+	output, err := os.OpenFile(filepath.Join(c.LogPath(), "run_once.log"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return -1, err
+	}
+	defer output.Close()
+
+	return containerExecScript(c, script, nil, output)
+}
+
+// Run the given script inside the container as root, with its output going
+// to the given file, and return its exit code.
+func containerExecScript(c container, script string, env map[string]string, output *os.File) (int, error) {
+	stdin, err := ioutil.TempFile("", "lxd_script_")
+	if err != nil {
+		return -1, err
+	}
+	defer os.Remove(stdin.Name())
+	defer stdin.Close()
+
+	_, err = stdin.WriteString(script)
+	if err != nil {
+		return -1, err
+	}
+
+	_, err = stdin.Seek(0, 0)
+	if err != nil {
+		return -1, err
+	}
+
+	env = containerExecEnvironment(c, nil, env, 0)
+	_, exitCode, _, err := c.Exec([]string{"/bin/sh", "-c", scriptWrapper}, env, stdin, output, output, true, "/", 0, 0, nil)
+	if err != nil {
+		return -1, err
+	}
+
+	return exitCode, nil
+}
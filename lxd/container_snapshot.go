@@ -12,6 +12,7 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -114,28 +115,8 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Snapshot names may not contain slashes"))
 	}
 
-	fullName := name +
-		shared.SnapshotDelimiter +
-		req.Name
-
 	snapshot := func(op *operation) error {
-		args := db.ContainerArgs{
-			Architecture: c.Architecture(),
-			Config:       c.LocalConfig(),
-			Ctype:        db.CTypeSnapshot,
-			Devices:      c.LocalDevices(),
-			Ephemeral:    c.IsEphemeral(),
-			Name:         fullName,
-			Profiles:     c.Profiles(),
-			Stateful:     req.Stateful,
-		}
-
-		_, err := containerCreateAsSnapshot(d.State(), args, c)
-		if err != nil {
-			return err
-		}
-
-		return nil
+		return containerSnapshotCreate(d.State(), c, req.Name, req.Stateful, req.Protected)
 	}
 
 	resources := map[string][]string{}
@@ -149,6 +130,42 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 	return OperationResponse(op)
 }
 
+// Create a snapshot with the given name of the given container, whose storage
+// must be started.
+func containerSnapshotCreate(s *state.State, c container, name string, stateful bool, protected bool) error {
+	// Snapshots don't inherit the delete protection of their container
+	config := map[string]string{}
+	for k, v := range c.LocalConfig() {
+		config[k] = v
+	}
+
+	delete(config, "security.protection.delete")
+	if protected {
+		config["security.protection.delete"] = "true"
+	}
+
+	profilePriorities, err := s.Cluster.ContainerProfilePriorities(c.Id())
+	if err != nil {
+		return err
+	}
+
+	args := db.ContainerArgs{
+		Architecture: c.Architecture(),
+		Config:       config,
+		Ctype:        db.CTypeSnapshot,
+		Devices:      c.LocalDevices(),
+		Ephemeral:    c.IsEphemeral(),
+		Name:         c.Name() + shared.SnapshotDelimiter + name,
+		Profiles:     c.Profiles(),
+		Stateful:     stateful,
+
+		ProfilePriorities: profilePriorities,
+	}
+
+	_, err = containerCreateAsSnapshot(s, args, c)
+	return err
+}
+
 func snapshotHandler(d *Daemon, r *http.Request) Response {
 	containerName := mux.Vars(r)["name"]
 	snapshotName := mux.Vars(r)["snapshotName"]
@@ -179,6 +196,8 @@ func snapshotHandler(d *Daemon, r *http.Request) Response {
 		return snapshotGet(sc, snapshotName)
 	case "POST":
 		return snapshotPost(d, r, sc, containerName)
+	case "PUT":
+		return snapshotPut(d, r, sc)
 	case "DELETE":
 		return snapshotDelete(sc, snapshotName)
 	default:
@@ -305,6 +324,28 @@ func snapshotPost(d *Daemon, r *http.Request, sc container, containerName string
 	return OperationResponse(op)
 }
 
+// Only the delete protection of a snapshot can be changed.
+func snapshotPut(d *Daemon, r *http.Request, sc container) Response {
+	req := api.ContainerSnapshotPut{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	value := ""
+	if req.Protected {
+		value = "true"
+	}
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ContainerConfigKeySet(sc.Id(), "security.protection.delete", value)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
 func snapshotDelete(sc container, name string) Response {
 	remove := func(op *operation) error {
 		return sc.Delete()
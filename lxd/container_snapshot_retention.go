@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Functions returning the bucket a snapshot falls in for each of the periods
+// of a retention policy, only the most recent snapshot of a bucket is kept.
+var snapshotRetentionPeriods = map[string]func(t time.Time) string{
+	"hourly": func(t time.Time) string { return t.Format("2006-01-02 15") },
+	"daily":  func(t time.Time) string { return t.Format("2006-01-02") },
+	"weekly": func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	},
+	"monthly": func(t time.Time) string { return t.Format("2006-01") },
+	"yearly":  func(t time.Time) string { return t.Format("2006") },
+}
+
+// Parse a grandfather-father-son retention policy such as
+// "hourly=24,daily=7,weekly=4" into the number of snapshots to keep for each
+// period.
+func snapshotRetentionParse(value string) (map[string]int, error) {
+	policy := map[string]int{}
+	total := 0
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.SplitN(entry, "=", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid retention entry '%s', expected <period>=<count>", entry)
+		}
+
+		period := strings.TrimSpace(fields[0])
+		_, ok := snapshotRetentionPeriods[period]
+		if !ok {
+			return nil, fmt.Errorf("Invalid retention period '%s', must be one of hourly, daily, weekly, monthly or yearly", period)
+		}
+
+		_, ok = policy[period]
+		if ok {
+			return nil, fmt.Errorf("Duplicate retention period '%s'", period)
+		}
+
+		count, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("Invalid retention count for '%s': must be zero or more snapshots", period)
+		}
+
+		policy[period] = count
+		total += count
+	}
+
+	if total == 0 {
+		return nil, fmt.Errorf("The retention policy must keep at least one snapshot")
+	}
+
+	return policy, nil
+}
+
+// Return whether each of the snapshots created at the given dates is kept by
+// the retention policy. For each period, the most recent snapshot of each of
+// the latest buckets is kept, up to the configured count.
+func snapshotRetentionKeep(policy map[string]int, dates []time.Time) []bool {
+	keep := make([]bool, len(dates))
+
+	// Most recent snapshots first
+	order := make([]int, len(dates))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return dates[order[i]].After(dates[order[j]])
+	})
+
+	for period, count := range policy {
+		bucket := snapshotRetentionPeriods[period]
+		seen := map[string]bool{}
+
+		for _, i := range order {
+			key := bucket(dates[i].UTC())
+			if seen[key] {
+				continue
+			}
+
+			if len(seen) >= count {
+				break
+			}
+
+			seen[key] = true
+			keep[i] = true
+		}
+	}
+
+	return keep
+}
+
+func snapshotsPruneTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		snapshotsPrune(ctx, d)
+	}
+
+	return f, task.Every(time.Hour)
+}
+
+// Prefix of the names of the snapshots created by "snapshots.schedule", which
+// are the only ones "snapshots.retention" applies to.
+const snapshotScheduledPrefix = "auto-"
+
+// Return whether the given snapshot was created by the snapshot schedule.
+func snapshotIsScheduled(name string) bool {
+	fields := strings.SplitN(name, shared.SnapshotDelimiter, 2)
+	return strings.HasPrefix(fields[len(fields)-1], snapshotScheduledPrefix)
+}
+
+// Return whether a scheduled snapshot is due at the given time for the given
+// period, that is if none of the existing scheduled snapshots, created at the
+// given dates, falls in the same period.
+func snapshotScheduleDue(period string, dates []time.Time, now time.Time) bool {
+	bucket := snapshotRetentionPeriods[period]
+	current := bucket(now.UTC())
+
+	for _, date := range dates {
+		if bucket(date.UTC()) == current {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Take a scheduled snapshot of the given container.
+func snapshotScheduledCreate(s *state.State, c container, now time.Time) error {
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	name := snapshotScheduledPrefix + now.UTC().Format("20060102-150405")
+	return containerSnapshotCreate(s, c, name, false, false)
+}
+
+// Take the snapshots due according to the "snapshots.schedule" of the
+// containers of this node, and delete the scheduled snapshots which aren't
+// kept by their "snapshots.retention" policy. Snapshots created by hand are
+// left alone.
+func snapshotsPrune(ctx context.Context, d *Daemon) {
+	names, err := d.cluster.ContainersNodeList(db.CTypeRegular)
+	if err != nil {
+		logger.Error("Unable to retrieve the list of containers", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range names {
+		// At each iteration we check if we got cancelled in the
+		// meantime. Anything not pruned now will be at the next run.
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		c, err := containerLoadByName(d.State(), name)
+		if err != nil {
+			logger.Error("Failed to load container", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		schedule := c.ExpandedConfig()["snapshots.schedule"]
+		value := c.ExpandedConfig()["snapshots.retention"]
+		if schedule == "" && value == "" {
+			continue
+		}
+
+		snapshots, err := c.Snapshots()
+		if err != nil {
+			logger.Error("Failed to load container snapshots", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		scheduled := []container{}
+		dates := []time.Time{}
+		for _, snap := range snapshots {
+			if snapshotIsScheduled(snap.Name()) {
+				scheduled = append(scheduled, snap)
+				dates = append(dates, snap.CreationDate())
+			}
+		}
+
+		// The new snapshot is the most recent one, always kept by the
+		// retention policy, so it doesn't need to be considered below.
+		now := time.Now()
+		if schedule != "" && snapshotScheduleDue(schedule, dates, now) {
+			logger.Info("Taking scheduled snapshot", log.Ctx{"container": name, "schedule": schedule})
+			err := snapshotScheduledCreate(d.State(), c, now)
+			if err != nil {
+				logger.Error("Failed to take scheduled snapshot", log.Ctx{"container": name, "err": err})
+			}
+		}
+
+		if value == "" {
+			continue
+		}
+
+		policy, err := snapshotRetentionParse(value)
+		if err != nil {
+			logger.Error("Invalid snapshot retention policy", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		keep := snapshotRetentionKeep(policy, dates)
+		for i, snap := range scheduled {
+			if keep[i] || snap.IsDeleteProtected() {
+				continue
+			}
+
+			logger.Info("Pruning snapshot", log.Ctx{"snapshot": snap.Name(), "created": dates[i]})
+			err := snap.Delete()
+			if err != nil {
+				logger.Error("Failed to prune snapshot", log.Ctx{"snapshot": snap.Name(), "err": err})
+			}
+		}
+	}
+}
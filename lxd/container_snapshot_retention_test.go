@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Policies are parsed from a comma separated list of periods with counts.
+func TestSnapshotRetentionParse(t *testing.T) {
+	policy, err := snapshotRetentionParse("hourly=24, daily=7,weekly=4,")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"hourly": 24, "daily": 7, "weekly": 4}, policy)
+
+	_, err = snapshotRetentionParse("minutely=5")
+	assert.Error(t, err)
+
+	_, err = snapshotRetentionParse("daily=7,daily=3")
+	assert.Error(t, err)
+
+	_, err = snapshotRetentionParse("daily=-1")
+	assert.Error(t, err)
+
+	_, err = snapshotRetentionParse("daily=0")
+	assert.Error(t, err)
+}
+
+// The most recent snapshot of each of the latest buckets of each period is
+// kept.
+func TestSnapshotRetentionKeep(t *testing.T) {
+	policy, err := snapshotRetentionParse("hourly=2,daily=3")
+	require.NoError(t, err)
+
+	dates := []time.Time{
+		time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC),
+		time.Date(2018, 6, 3, 9, 0, 0, 0, time.UTC),
+		time.Date(2018, 6, 3, 10, 0, 0, 0, time.UTC),
+		time.Date(2018, 6, 3, 11, 0, 0, 0, time.UTC),
+		time.Date(2018, 6, 3, 11, 30, 0, 0, time.UTC),
+		time.Date(2018, 6, 2, 23, 0, 0, 0, time.UTC),
+		time.Date(2018, 6, 2, 12, 0, 0, 0, time.UTC),
+	}
+
+	keep := snapshotRetentionKeep(policy, dates)
+	assert.Equal(t, []bool{true, false, true, false, true, true, false}, keep)
+}
+
+// Only the snapshots taken by the schedule are subject to retention.
+func TestSnapshotIsScheduled(t *testing.T) {
+	assert.True(t, snapshotIsScheduled("c1/auto-20181010-101010"))
+	assert.True(t, snapshotIsScheduled("auto-20181010-101010"))
+	assert.False(t, snapshotIsScheduled("c1/snap0"))
+	assert.False(t, snapshotIsScheduled("auto-c1/snap0"))
+}
+
+// A scheduled snapshot is due when none was taken in the current period.
+func TestSnapshotScheduleDue(t *testing.T) {
+	now := time.Date(2018, 10, 10, 10, 30, 0, 0, time.UTC)
+
+	assert.True(t, snapshotScheduleDue("hourly", nil, now))
+	assert.False(t, snapshotScheduleDue("hourly", []time.Time{now.Add(-20 * time.Minute)}, now))
+	assert.True(t, snapshotScheduleDue("hourly", []time.Time{now.Add(-40 * time.Minute)}, now))
+	assert.False(t, snapshotScheduleDue("daily", []time.Time{now.Add(-10 * time.Hour)}, now))
+	assert.True(t, snapshotScheduleDue("daily", []time.Time{now.Add(-11 * time.Hour)}, now))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// A startTimer measures how long the successive phases of a container start
+// take. A nil startTimer measures nothing.
+type startTimer struct {
+	started time.Time
+	last    time.Time
+	phases  []api.ContainerStartPhase
+}
+
+func newStartTimer() *startTimer {
+	now := time.Now()
+	return &startTimer{started: now, last: now}
+}
+
+// Account the time elapsed since the previous mark to the given phase. Time
+// accounted several times to the same phase adds up.
+func (t *startTimer) mark(name string) {
+	if t == nil {
+		return
+	}
+
+	now := time.Now()
+	t.add(name, now.Sub(t.last))
+	t.last = now
+}
+
+// Move part of the time accounted to a phase to another one.
+func (t *startTimer) split(from string, to string, duration time.Duration) {
+	if t == nil {
+		return
+	}
+
+	for i := range t.phases {
+		if t.phases[i].Name == from {
+			t.phases[i].Duration -= duration.Nanoseconds() / int64(time.Millisecond)
+		}
+	}
+
+	t.add(to, duration)
+}
+
+func (t *startTimer) add(name string, duration time.Duration) {
+	ms := duration.Nanoseconds() / int64(time.Millisecond)
+
+	for i := range t.phases {
+		if t.phases[i].Name == name {
+			t.phases[i].Duration += ms
+			return
+		}
+	}
+
+	t.phases = append(t.phases, api.ContainerStartPhase{Name: name, Duration: ms})
+}
+
+func (t *startTimer) timings() api.ContainerStartTimings {
+	return api.ContainerStartTimings{
+		StartedAt: t.started.UTC(),
+		Total:     time.Since(t.started).Nanoseconds() / int64(time.Millisecond),
+		Phases:    append([]api.ContainerStartPhase{}, t.phases...),
+	}
+}
+
+var startTimingsLock sync.Mutex
+
+// The timings of the last successful start of each container.
+var startTimings = map[string]api.ContainerStartTimings{}
+
+// How long the start hook of each container currently starting took. The
+// hook runs in its own request, so it's handed over to Start() from here.
+var startHookDurations = map[string]time.Duration{}
+
+func containerStartTimingsRecord(name string, timings api.ContainerStartTimings) {
+	startTimingsLock.Lock()
+	defer startTimingsLock.Unlock()
+
+	startTimings[name] = timings
+}
+
+func containerStartTimingsGet(name string) *api.ContainerStartTimings {
+	startTimingsLock.Lock()
+	defer startTimingsLock.Unlock()
+
+	timings, ok := startTimings[name]
+	if !ok {
+		return nil
+	}
+
+	return &timings
+}
+
+func containerStartTimingsForget(name string) {
+	startTimingsLock.Lock()
+	defer startTimingsLock.Unlock()
+
+	delete(startTimings, name)
+	delete(startHookDurations, name)
+}
+
+func containerStartHookRecord(name string, duration time.Duration) {
+	startTimingsLock.Lock()
+	defer startTimingsLock.Unlock()
+
+	startHookDurations[name] = duration
+}
+
+// Return and clear the duration of the last start hook of the container.
+func containerStartHookPop(name string) (time.Duration, bool) {
+	startTimingsLock.Lock()
+	defer startTimingsLock.Unlock()
+
+	duration, ok := startHookDurations[name]
+	delete(startHookDurations, name)
+
+	return duration, ok
+}
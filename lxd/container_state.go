@@ -73,6 +73,11 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 	case shared.Start:
 		opDescription = "Starting container"
 		do = func(op *operation) error {
+			err := containerStartDependencies(d.State(), c, nil)
+			if err != nil {
+				return err
+			}
+
 			c.SetOperation(op)
 			if err = c.Start(raw.Stateful); err != nil {
 				return err
@@ -172,16 +177,20 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 			return nil
 		}
 	case shared.Freeze:
+		if raw.Duration < 0 {
+			return BadRequest(fmt.Errorf("Invalid freeze duration: must be zero or more seconds"))
+		}
+
 		opDescription = "Freezing container"
 		do = func(op *operation) error {
 			c.SetOperation(op)
-			return c.Freeze()
+			return containerFreeze(d.State(), c, raw.Duration)
 		}
 	case shared.Unfreeze:
 		opDescription = "Unfreezing container"
 		do = func(op *operation) error {
 			c.SetOperation(op)
-			return c.Unfreeze()
+			return containerUnfreeze(d.State(), c)
 		}
 	default:
 		return BadRequest(fmt.Errorf("unknown action %s", raw.Action))
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Return the healthcheck.action of a container with the given expanded config
+// if it has failed enough consecutive health checks for it to be applied, or
+// an empty string.
+func containerSupervisorAction(config map[string]string, failures int) string {
+	action := config["healthcheck.action"]
+	if action == "" || action == "none" {
+		return ""
+	}
+
+	retries, err := strconv.Atoi(config["healthcheck.retries"])
+	if err != nil {
+		retries = 3
+	}
+
+	if failures < retries {
+		return ""
+	}
+
+	return action
+}
+
+// Apply the given healthcheck.action to an unhealthy container.
+func containerSupervise(c container, action string, failures int) error {
+	logger.Warn("Container is unhealthy, applying health check action", log.Ctx{"container": c.Name(), "action": action, "failures": failures})
+
+	switch action {
+	case "stop":
+		err := containerSupervisorStop(c)
+		if err != nil {
+			return err
+		}
+	case "restart":
+		err := containerSupervisorRestart(c)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown health check action: %s", action)
+	}
+
+	eventSendLifecycle("container-health-action",
+		fmt.Sprintf("/1.0/containers/%s", c.Name()),
+		map[string]interface{}{
+			"action":   action,
+			"failures": failures,
+		})
+
+	return nil
+}
+
+// Give the container boot.host_shutdown_timeout seconds to shut down cleanly
+// before stopping it.
+func containerSupervisorStop(c container) error {
+	timeout, err := strconv.Atoi(c.ExpandedConfig()["boot.host_shutdown_timeout"])
+	if err != nil {
+		timeout = 30
+	}
+
+	err = c.Shutdown(time.Duration(timeout) * time.Second)
+	if err == nil {
+		return nil
+	}
+
+	if !c.IsRunning() {
+		return nil
+	}
+
+	return c.Stop(false)
+}
+
+// Reboot the container, giving it boot.host_shutdown_timeout seconds to do
+// so cleanly. A container which doesn't reboot in time is stopped and
+// started again, unless it's ephemeral since stopping it would delete it.
+func containerSupervisorRestart(c container) error {
+	timeout, err := strconv.Atoi(c.ExpandedConfig()["boot.host_shutdown_timeout"])
+	if err != nil {
+		timeout = 30
+	}
+
+	err = c.Reboot(time.Duration(timeout) * time.Second)
+	if err == nil {
+		return nil
+	}
+
+	if c.IsEphemeral() {
+		return err
+	}
+
+	logger.Warn("Container didn't reboot, stopping it", log.Ctx{"container": c.Name(), "err": err})
+	if c.IsRunning() {
+		err = c.Stop(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.Start(false)
+}
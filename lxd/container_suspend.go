@@ -0,0 +1,453 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Containers using less than this share of a CPU and exchanging less than
+// this number of bytes per second over the network are considered idle, so
+// that background noise such as timers or ARP doesn't keep them awake.
+const containerIdleCPUShare = 0.01
+const containerIdleNetworkRate = 1024
+
+// The activity counters of a running container as of the last sample, along
+// with the last time it wasn't idle.
+type containerActivity struct {
+	cpu     int64
+	network int64
+	sampled time.Time
+	active  time.Time
+}
+
+// Record a new sample of the activity counters of the container, returning
+// for how long it's been idle.
+func (a *containerActivity) sample(cpu int64, network int64, now time.Time) time.Duration {
+	// Counters going backward mean that the container got restarted
+	elapsed := now.Sub(a.sampled)
+	idle := cpu >= a.cpu && network >= a.network &&
+		float64(cpu-a.cpu) < containerIdleCPUShare*float64(elapsed) &&
+		float64(network-a.network) < containerIdleNetworkRate*elapsed.Seconds()
+
+	a.cpu = cpu
+	a.network = network
+	a.sampled = now
+
+	if !idle {
+		a.active = now
+	}
+
+	return now.Sub(a.active)
+}
+
+// Return the CPU time used by the container, in nanoseconds, and the number
+// of bytes it exchanged over its network interfaces.
+func (c *containerLXC) activityCounters() (int64, int64) {
+	cpu := c.cpuState().Usage
+
+	network := int64(0)
+	for name, iface := range c.networkState() {
+		if name == "lo" {
+			continue
+		}
+
+		network += iface.Counters.BytesReceived + iface.Counters.BytesSent
+	}
+
+	return cpu, network
+}
+
+// Listeners held on the host side of the proxy devices of suspended
+// containers, indexed by container name.
+var containerWakeListenersLock sync.Mutex
+var containerWakeListeners = map[string][]net.Listener{}
+
+// Serializes suspending and resuming containers, so that a connection coming
+// in while a container is being suspended waits for it to be done.
+var containerWakeLock sync.Mutex
+
+// Listen on behalf of the given container on the host side of its proxy
+// devices, resuming it on the first incoming connection. All of them are used
+// if the container is suspended and only those with "wake_on_connect" if it's
+// stopped. UDP devices are left alone since datagrams can't be held until the
+// container is back.
+func containerWakeListen(s *state.State, c *containerLXC, suspended bool) error {
+	containerWakeListenersLock.Lock()
+	defer containerWakeListenersLock.Unlock()
+
+	_, ok := containerWakeListeners[c.name]
+	if ok {
+		return nil
+	}
+
+	listeners := []net.Listener{}
+	closeListeners := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "proxy" || (m["bind"] != "" && m["bind"] != "host") {
+			continue
+		}
+
+		if !suspended && !shared.IsTrue(m["wake_on_connect"]) {
+			continue
+		}
+
+		addr, err := parseAddr(m["listen"])
+		if err != nil {
+			closeListeners()
+			return err
+		}
+
+		if addr.connType == "udp" {
+			continue
+		}
+
+		for _, a := range addr.addr {
+			if addr.connType == "unix" && !addr.abstract {
+				os.Remove(a)
+			}
+
+			l, err := net.Listen(addr.connType, a)
+			if err != nil {
+				closeListeners()
+				return fmt.Errorf("Failed to listen on %s for proxy device '%s': %v", a, name, err)
+			}
+			listeners = append(listeners, l)
+
+			if addr.connType == "unix" && !addr.abstract {
+				err := containerWakeSocketSetup(a, m)
+				if err != nil {
+					closeListeners()
+					return err
+				}
+			}
+		}
+	}
+
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	containerWakeListeners[c.name] = listeners
+	for _, l := range listeners {
+		go containerWakeAccept(s, c.name, l)
+	}
+
+	return nil
+}
+
+// Apply the ownership and permissions configured on the proxy device to the
+// unix socket listened on by LXD, as forkproxy would.
+func containerWakeSocketSetup(path string, m map[string]string) error {
+	uid := -1
+	gid := -1
+
+	var err error
+	if m["uid"] != "" {
+		uid, err = strconv.Atoi(m["uid"])
+		if err != nil {
+			return err
+		}
+	}
+
+	if m["gid"] != "" {
+		gid, err = strconv.Atoi(m["gid"])
+		if err != nil {
+			return err
+		}
+	}
+
+	if uid != -1 || gid != -1 {
+		err := os.Chown(path, uid, gid)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m["mode"] != "" {
+		mode, err := strconv.ParseUint(m["mode"], 8, 0)
+		if err != nil {
+			return err
+		}
+
+		err = os.Chmod(path, os.FileMode(mode))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop listening on behalf of the given container, handing the host side of
+// its proxy devices back to forkproxy.
+func containerWakeRelease(name string) {
+	containerWakeListenersLock.Lock()
+	defer containerWakeListenersLock.Unlock()
+
+	for _, l := range containerWakeListeners[name] {
+		l.Close()
+	}
+
+	delete(containerWakeListeners, name)
+}
+
+// Stop listening on behalf of all containers but the given ones.
+func containerWakeForget(keep []string) {
+	names := map[string]bool{}
+	for _, name := range keep {
+		names[name] = true
+	}
+
+	containerWakeListenersLock.Lock()
+	held := []string{}
+	for name := range containerWakeListeners {
+		if !names[name] {
+			held = append(held, name)
+		}
+	}
+	containerWakeListenersLock.Unlock()
+
+	for _, name := range held {
+		containerWakeRelease(name)
+	}
+}
+
+// Accept the connections made to a proxy device of a suspended or stopped
+// container, resuming the container and relaying them to the proxy device once it's
+// back in place. This returns once the listener gets released.
+func containerWakeAccept(s *state.State, name string, l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(conn net.Conn) {
+			err := containerWake(s, name)
+			if err != nil {
+				logger.Error("Failed to resume suspended container", log.Ctx{"name": name, "err": err})
+				conn.Close()
+				return
+			}
+
+			// Give forkproxy some time to listen again
+			var target net.Conn
+			for i := 0; i < 10; i++ {
+				target, err = net.Dial(l.Addr().Network(), l.Addr().String())
+				if err == nil {
+					break
+				}
+
+				time.Sleep(500 * time.Millisecond)
+			}
+
+			if err != nil {
+				logger.Error("Failed to relay connection to resumed container", log.Ctx{"name": name, "err": err})
+				conn.Close()
+				return
+			}
+
+			genericRelay(conn, target, false)
+		}(conn)
+	}
+}
+
+// Start the given container if it's stopped, or unfreeze it if it was
+// suspended for being idle.
+func containerWake(s *state.State, name string) error {
+	containerWakeLock.Lock()
+	defer containerWakeLock.Unlock()
+
+	c, err := containerLoadByName(s, name)
+	if err != nil {
+		return err
+	}
+
+	if !c.IsRunning() {
+		logger.Info("Starting container on incoming connection", log.Ctx{"name": name})
+		return c.Start(c.IsStateful())
+	}
+
+	if c.IsFrozen() && c.LocalConfig()["volatile.suspended"] != "" {
+		logger.Info("Resuming suspended container on incoming connection", log.Ctx{"name": name})
+		return c.Unfreeze()
+	}
+
+	return nil
+}
+
+// Suspend the given idle container, either freezing it or stopping it
+// statefully depending on mode, and listen on its behalf on the host side of
+// its proxy devices until it's resumed.
+func containerSuspend(s *state.State, c *containerLXC, mode string) error {
+	containerWakeLock.Lock()
+	defer containerWakeLock.Unlock()
+
+	err := c.removeProxyDevices()
+	if err != nil {
+		return err
+	}
+
+	err = containerWakeListen(s, c, true)
+	if err != nil {
+		c.restartProxyDevices()
+		return err
+	}
+
+	if mode == "stop" {
+		err = c.Stop(true)
+	} else {
+		err = c.Freeze()
+	}
+	if err != nil {
+		containerWakeRelease(c.name)
+		c.restartProxyDevices()
+		return err
+	}
+
+	err = c.ConfigKeySet("volatile.suspended", mode)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Suspended idle container", log.Ctx{"name": c.name, "mode": mode})
+	eventSendLifecycle("container-suspended",
+		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
+
+	return nil
+}
+
+// Release the host side of the proxy devices of the given container as it's
+// being resumed, forgetting that it was suspended if it was.
+func (c *containerLXC) wakeRelease() error {
+	containerWakeRelease(c.name)
+
+	if c.localConfig["volatile.suspended"] == "" {
+		return nil
+	}
+
+	return c.state.Cluster.ContainerConfigRemove(c.id, "volatile.suspended")
+}
+
+func containersAutoSuspendTask(d *Daemon) (task.Func, task.Schedule) {
+	activity := map[string]*containerActivity{}
+
+	f := func(ctx context.Context) {
+		containersAutoSuspend(ctx, d, activity)
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// Suspend the containers of this node which have been idle for longer than
+// "containers.auto_suspend.idle_timeout" minutes, and listen again on behalf
+// of those suspended or stopped before LXD got restarted.
+func containersAutoSuspend(ctx context.Context, d *Daemon, activity map[string]*containerActivity) {
+	timeout, err := cluster.ConfigGetInt64(d.cluster, "containers.auto_suspend.idle_timeout")
+	if err != nil {
+		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+		return
+	}
+
+	mode, err := cluster.ConfigGetString(d.cluster, "containers.auto_suspend.mode")
+	if err != nil {
+		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+		return
+	}
+
+	names, err := d.cluster.ContainersNodeList(db.CTypeRegular)
+	if err != nil {
+		logger.Error("Unable to retrieve the list of containers", log.Ctx{"err": err})
+		return
+	}
+
+	// Drop the state of containers which have been deleted or moved
+	containerWakeForget(names)
+	known := map[string]bool{}
+	for _, name := range names {
+		known[name] = true
+	}
+
+	for name := range activity {
+		if !known[name] {
+			delete(activity, name)
+		}
+	}
+
+	for _, name := range names {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		c, err := containerLoadByName(d.State(), name)
+		if err != nil {
+			logger.Error("Failed to load container", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		ct, ok := c.(*containerLXC)
+		if !ok {
+			continue
+		}
+
+		suspended := ct.localConfig["volatile.suspended"] != ""
+		if suspended || !ct.IsRunning() {
+			delete(activity, name)
+
+			err := containerWakeListen(d.State(), ct, suspended)
+			if err != nil {
+				logger.Error("Failed to listen on behalf of container", log.Ctx{"container": name, "err": err})
+			}
+
+			continue
+		}
+
+		// Ephemeral containers would get deleted when stopped
+		if timeout <= 0 || ct.IsFrozen() || (mode == "stop" && ct.IsEphemeral()) {
+			delete(activity, name)
+			continue
+		}
+
+		now := time.Now()
+		cpu, network := ct.activityCounters()
+
+		last, ok := activity[name]
+		if !ok {
+			activity[name] = &containerActivity{cpu: cpu, network: network, sampled: now, active: now}
+			continue
+		}
+
+		if last.sample(cpu, network, now) < time.Duration(timeout)*time.Minute {
+			continue
+		}
+
+		delete(activity, name)
+		err = containerSuspend(d.State(), ct, mode)
+		if err != nil {
+			logger.Error("Failed to suspend idle container", log.Ctx{"container": name, "err": err})
+		}
+	}
+}
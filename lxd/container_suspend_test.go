@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A container is idle while it uses less CPU and network than the thresholds,
+// and becomes active again as soon as it goes above them or gets restarted.
+func TestContainerActivitySample(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	a := &containerActivity{cpu: 1000, network: 1000, sampled: start, active: start}
+
+	// A millisecond of CPU and a few bytes over a minute
+	now := start.Add(time.Minute)
+	assert.Equal(t, time.Minute, a.sample(1000+int64(time.Millisecond), 1100, now))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, 2*time.Minute, a.sample(1000+int64(2*time.Millisecond), 1200, now))
+
+	// A second of CPU
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), a.sample(1000+int64(time.Second), 1200, now))
+
+	// Lots of network traffic
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), a.sample(1000+int64(time.Second), 1000000, now))
+
+	// Counters reset by a restart
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), a.sample(0, 0, now))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Minute, a.sample(0, 0, now))
+}
+
+// The unix sockets listened on for proxy devices get the mode configured on
+// the device.
+func TestContainerWakeSocketSetup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-suspend-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "socket")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	require.NoError(t, containerWakeSocketSetup(path, map[string]string{"mode": "0600"}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.Error(t, containerWakeSocketSetup(path, map[string]string{"mode": "rw"}))
+	assert.Error(t, containerWakeSocketSetup(path, map[string]string{"uid": "root"}))
+}
+
+// Only the listeners of the containers to keep are left open.
+func TestContainerWakeForget(t *testing.T) {
+	listen := func(name string) net.Listener {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		containerWakeListenersLock.Lock()
+		containerWakeListeners[name] = []net.Listener{l}
+		containerWakeListenersLock.Unlock()
+
+		return l
+	}
+
+	l1 := listen("c1")
+	l2 := listen("c2")
+	defer containerWakeRelease("c1")
+
+	containerWakeForget([]string{"c1"})
+
+	containerWakeListenersLock.Lock()
+	assert.Len(t, containerWakeListeners, 1)
+	assert.Contains(t, containerWakeListeners, "c1")
+	containerWakeListenersLock.Unlock()
+
+	_, err := net.Dial("tcp", l1.Addr().String())
+	assert.NoError(t, err)
+
+	_, err = net.Dial("tcp", l2.Addr().String())
+	assert.Error(t, err)
+}
+
+// Stopped containers are only woken up by their proxy devices with
+// "wake_on_connect", while suspended ones are by all of them.
+func TestContainerWakeListen(t *testing.T) {
+	addrs := []string{}
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addrs = append(addrs, l.Addr().String())
+		l.Close()
+	}
+
+	c := &containerLXC{
+		name: "c1",
+		expandedDevices: types.Devices{
+			"web":  {"type": "proxy", "listen": "tcp:" + addrs[0], "connect": "tcp:127.0.0.1:80", "wake_on_connect": "true"},
+			"ssh":  {"type": "proxy", "listen": "tcp:" + addrs[1], "connect": "tcp:127.0.0.1:22"},
+			"dns":  {"type": "proxy", "listen": "udp:127.0.0.1:5353", "connect": "udp:127.0.0.1:53", "wake_on_connect": "true"},
+			"root": {"type": "disk", "path": "/", "pool": "default"},
+		},
+	}
+
+	require.NoError(t, containerWakeListen(nil, c, false))
+
+	containerWakeListenersLock.Lock()
+	assert.Len(t, containerWakeListeners["c1"], 1)
+	containerWakeListenersLock.Unlock()
+
+	containerWakeRelease("c1")
+
+	require.NoError(t, containerWakeListen(nil, c, true))
+	defer containerWakeRelease("c1")
+
+	containerWakeListenersLock.Lock()
+	assert.Len(t, containerWakeListeners["c1"], 2)
+	containerWakeListenersLock.Unlock()
+}
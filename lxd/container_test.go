@@ -394,6 +394,35 @@ func (suite *containerTestSuite) TestContainer_findIdmap_maxed() {
 	}
 }
 
+// Nics attached to managed networks are resolved without going to the
+// database again until the network changes.
+func (suite *containerTestSuite) TestContainer_DeviceNetwork() {
+	_, err := suite.d.cluster.NetworkCreate("testbr0", "", map[string]string{"bridge.mtu": "1400"})
+	suite.Req.Nil(err)
+	defer containerNetworkInvalidate("testbr0")
+
+	nic := types.Device{"type": "nic", "network": "testbr0"}
+	device, err := containerDeviceNetwork(suite.d.cluster, nic)
+	suite.Req.Nil(err)
+	suite.Req.Equal(types.Device{"type": "nic", "network": "testbr0", "nictype": "bridged", "parent": "testbr0", "mtu": "1400"}, device)
+	suite.Req.Equal(types.Device{"type": "nic", "network": "testbr0"}, nic)
+
+	err = suite.d.cluster.NetworkUpdate("testbr0", "", map[string]string{"bridge.mtu": "9000"})
+	suite.Req.Nil(err)
+
+	device, err = containerDeviceNetwork(suite.d.cluster, nic)
+	suite.Req.Nil(err)
+	suite.Req.Equal("1400", device["mtu"])
+
+	containerNetworkInvalidate("testbr0")
+	device, err = containerDeviceNetwork(suite.d.cluster, nic)
+	suite.Req.Nil(err)
+	suite.Req.Equal("9000", device["mtu"])
+
+	_, err = containerDeviceNetwork(suite.d.cluster, types.Device{"type": "nic", "network": "missing"})
+	suite.Req.NotNil(err)
+}
+
 func TestContainerTestSuite(t *testing.T) {
 	suite.Run(t, new(containerTestSuite))
 }
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerRestoreCmd = Command{
+	name: "containers/{name}/restore",
+	post: containerRestorePost,
+}
+
+// Return when the given container was moved to the trash, if it was.
+func containerTrashDate(c container) (time.Time, bool) {
+	value, ok := c.LocalConfig()["volatile.trash.date"]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// Still consider the container trashed, it'll get purged
+		// on the next run.
+		return time.Time{}, true
+	}
+
+	return date, true
+}
+
+// Move the given container to the trash, keeping all of its data around
+// until it either gets restored or expires. Protected containers can't be
+// trashed any more than they can be deleted.
+func containerTrash(c container) error {
+	if c.IsDeleteProtected() {
+		return fmt.Errorf("Container is protected")
+	}
+
+	err := c.ConfigKeySet("volatile.trash.date", time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Moved container to the trash", log.Ctx{"name": c.Name()})
+	eventSendLifecycle("container-trashed",
+		fmt.Sprintf("/1.0/containers/%s", c.Name()), nil)
+
+	return nil
+}
+
+func containerRestorePost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByName(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	_, trashed := containerTrashDate(c)
+	if !trashed {
+		return BadRequest(fmt.Errorf("The container isn't in the trash"))
+	}
+
+	err = d.cluster.ContainerConfigRemove(c.Id(), "volatile.trash.date")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	logger.Info("Restored container from the trash", log.Ctx{"name": name})
+	eventSendLifecycle("container-trash-restored",
+		fmt.Sprintf("/1.0/containers/%s", name), nil)
+
+	return EmptySyncResponse
+}
+
+func containersTrashPurgeTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containersTrashPurge(ctx, d)
+	}
+
+	return f, task.Every(time.Hour)
+}
+
+// Permanently delete the containers of this node which have been in the trash
+// for longer than "containers.trash_expiry" days.
+func containersTrashPurge(ctx context.Context, d *Daemon) {
+	expiry, err := cluster.ConfigGetInt64(d.cluster, "containers.trash_expiry")
+	if err != nil {
+		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+		return
+	}
+
+	// Containers are kept in the trash until deleted again when the
+	// expiry gets disabled.
+	if expiry <= 0 {
+		return
+	}
+
+	names, err := d.cluster.ContainersNodeList(db.CTypeRegular)
+	if err != nil {
+		logger.Error("Unable to retrieve the list of containers", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range names {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		c, err := containerLoadByName(d.State(), name)
+		if err != nil {
+			logger.Error("Failed to load container", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		date, trashed := containerTrashDate(c)
+		if !trashed || time.Since(date) < time.Duration(expiry)*24*time.Hour {
+			continue
+		}
+
+		logger.Info("Purging expired container from the trash", log.Ctx{"name": name, "trashed": date})
+		err = c.Delete()
+		if err != nil {
+			logger.Error("Failed to purge container from the trash", log.Ctx{"name": name, "err": err})
+		}
+	}
+}
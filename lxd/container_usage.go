@@ -0,0 +1,199 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerUsageCmd = Command{
+	name: "containers/{name}/usage",
+	get:  containerUsageGet,
+}
+
+// A usageHistory is a bounded ring buffer holding the most recent usage
+// samples of a container. It only grows up to its size as samples come in,
+// rather than being allocated upfront.
+type usageHistory struct {
+	samples []api.ContainerUsageSample
+	size    int
+	next    int
+}
+
+// Append a sample, dropping the oldest one if the buffer is full.
+func (h *usageHistory) append(sample api.ContainerUsageSample) {
+	if len(h.samples) < h.size {
+		h.samples = append(h.samples, sample)
+		return
+	}
+
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % h.size
+}
+
+// Return the samples currently in the buffer, from the oldest to the newest.
+func (h *usageHistory) list() []api.ContainerUsageSample {
+	samples := append([]api.ContainerUsageSample{}, h.samples[h.next:]...)
+	return append(samples, h.samples[:h.next]...)
+}
+
+// Change the capacity of the buffer, keeping the most recent samples.
+func (h *usageHistory) resize(size int) {
+	samples := h.list()
+	if len(samples) > size {
+		samples = samples[len(samples)-size:]
+	}
+
+	h.samples = samples
+	h.size = size
+	h.next = 0
+}
+
+var usageHistoriesLock sync.Mutex
+var usageHistories = map[string]*usageHistory{}
+
+func containerUsageRecord(name string, sample api.ContainerUsageSample, size int) {
+	usageHistoriesLock.Lock()
+	defer usageHistoriesLock.Unlock()
+
+	history, ok := usageHistories[name]
+	if !ok {
+		history = &usageHistory{size: size}
+		usageHistories[name] = history
+	} else if history.size != size {
+		history.resize(size)
+	}
+
+	history.append(sample)
+}
+
+func containerUsageList(name string) []api.ContainerUsageSample {
+	usageHistoriesLock.Lock()
+	defer usageHistoriesLock.Unlock()
+
+	history, ok := usageHistories[name]
+	if !ok {
+		return []api.ContainerUsageSample{}
+	}
+
+	return history.list()
+}
+
+// Drop the history of all containers but the given ones.
+func containerUsageForget(keep []string) {
+	usageHistoriesLock.Lock()
+	defer usageHistoriesLock.Unlock()
+
+	names := map[string]bool{}
+	for _, name := range keep {
+		names[name] = true
+	}
+
+	for name := range usageHistories {
+		if !names[name] {
+			delete(usageHistories, name)
+		}
+	}
+}
+
+func containerUsageGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	// Make sure the container exists
+	_, err = containerLoadByName(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	interval, err := cluster.ConfigGetInt64(d.cluster, "core.usage_history_interval")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	usage := api.ContainerUsage{
+		Interval: interval,
+		Samples:  containerUsageList(name),
+	}
+
+	return SyncResponse(true, usage)
+}
+
+func containerUsageTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containerUsageSample(ctx, d)
+	}
+
+	schedule := func() (time.Duration, error) {
+		interval, err := cluster.ConfigGetInt64(d.cluster, "core.usage_history_interval")
+		if err != nil {
+			logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+			return time.Minute, nil
+		}
+
+		if interval <= 0 {
+			// Release the memory used by the samples
+			containerUsageForget(nil)
+		}
+
+		// A zero interval disables the task
+		return time.Duration(interval) * time.Second, nil
+	}
+
+	return f, schedule
+}
+
+func containerUsageSample(ctx context.Context, d *Daemon) {
+	size, err := cluster.ConfigGetInt64(d.cluster, "core.usage_history_size")
+	if err != nil {
+		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+		return
+	}
+
+	names, err := d.cluster.ContainersNodeList(db.CTypeRegular)
+	if err != nil {
+		logger.Error("Unable to retrieve the list of containers", log.Ctx{"err": err})
+		return
+	}
+
+	// Drop the history of containers which have been deleted or moved
+	containerUsageForget(names)
+
+	for _, name := range names {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		c, err := containerLoadByName(d.State(), name)
+		if err != nil {
+			continue
+		}
+
+		if !c.IsRunning() {
+			continue
+		}
+
+		containerUsageRecord(name, c.RenderUsage(), int(size))
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// The history only keeps the most recent samples, growing as they come in.
+func TestUsageHistory(t *testing.T) {
+	sample := func(i int64) api.ContainerUsageSample {
+		return api.ContainerUsageSample{Processes: i}
+	}
+	processes := func(h *usageHistory) []int64 {
+		result := []int64{}
+		for _, s := range h.list() {
+			result = append(result, s.Processes)
+		}
+		return result
+	}
+
+	h := &usageHistory{size: 3}
+	assert.Equal(t, []int64{}, processes(h))
+
+	h.append(sample(1))
+	h.append(sample(2))
+	assert.Equal(t, []int64{1, 2}, processes(h))
+	assert.Len(t, h.samples, 2)
+
+	h.append(sample(3))
+	h.append(sample(4))
+	assert.Equal(t, []int64{2, 3, 4}, processes(h))
+
+	h.resize(2)
+	assert.Equal(t, []int64{3, 4}, processes(h))
+	h.append(sample(5))
+	assert.Equal(t, []int64{4, 5}, processes(h))
+
+	h.resize(4)
+	h.append(sample(6))
+	assert.Equal(t, []int64{4, 5, 6}, processes(h))
+}
@@ -52,6 +52,7 @@ var containerSnapshotCmd = Command{
 	name:   "containers/{name}/snapshots/{snapshotName}",
 	get:    snapshotHandler,
 	post:   snapshotHandler,
+	put:    snapshotHandler,
 	delete: snapshotHandler,
 }
 
@@ -62,6 +63,12 @@ var containerConsoleCmd = Command{
 	delete: containerConsoleLogDelete,
 }
 
+var containerDeviceNodesCmd = Command{
+	name:   "containers/{name}/device-nodes",
+	post:   containerDeviceNodesPost,
+	delete: containerDeviceNodesDelete,
+}
+
 var containerExecCmd = Command{
 	name: "containers/{name}/exec",
 	post: containerExecPost,
@@ -124,50 +131,89 @@ func (slice containerAutostartList) Swap(i, j int) {
 
 func containersRestart(s *state.State) error {
 	// Get all the containers
-	result, err := s.Cluster.ContainersList(db.CTypeRegular)
+	containers, err := containerLoadNodeAll(s)
 	if err != nil {
 		return err
 	}
 
-	containers := []container{}
+	containersAutostart(containers, containers)
 
-	for _, name := range result {
-		c, err := containerLoadByName(s, name)
-		if err != nil {
-			return err
-		}
+	return nil
+}
 
-		containers = append(containers, c)
+// Start the given containers which would have been started along with LXD,
+// following their boot.autostart.priority and boot.depends_on. The stopped
+// containers they depend on are looked up in the all list and started first.
+// Dependencies on containers of other cluster members aren't checked.
+func containersAutostart(all []container, containers []container) {
+	byName := map[string]container{}
+	for _, c := range all {
+		byName[c.Name()] = c
 	}
 
-	sort.Sort(containerAutostartList(containers))
-
-	// Restart the containers
+	wanted := []container{}
+	isWanted := map[string]bool{}
 	for _, c := range containers {
 		config := c.ExpandedConfig()
 		lastState := config["volatile.last_state.power"]
-
 		autoStart := config["boot.autostart"]
-		autoStartDelay := config["boot.autostart.delay"]
 
 		if shared.IsTrue(autoStart) || (autoStart == "" && lastState == "RUNNING") {
 			if c.IsRunning() {
 				continue
 			}
 
-			err = c.Start(false)
-			if err != nil {
-				logger.Errorf("Failed to start container '%s': %v", c.Name(), err)
-			}
+			wanted = append(wanted, c)
+			isWanted[c.Name()] = true
+		}
+	}
 
-			autoStartDelayInt, err := strconv.Atoi(autoStartDelay)
-			if err == nil {
-				time.Sleep(time.Duration(autoStartDelayInt) * time.Second)
+	// Pull in the dependencies which aren't running yet.
+	for i := 0; i < len(wanted); i++ {
+		for _, name := range containerDependencies(wanted[i]) {
+			dep, ok := byName[name]
+			if !ok || isWanted[name] || dep.IsRunning() {
+				continue
 			}
+
+			wanted = append(wanted, dep)
+			isWanted[name] = true
 		}
 	}
 
-	return nil
+	sort.Sort(containerAutostartList(wanted))
+
+	ordered, cyclic := containersStartOrder(wanted)
+	for _, c := range cyclic {
+		logger.Error("Not starting container with a dependency cycle", log.Ctx{"container": c.Name(), "depends_on": c.ExpandedConfig()["boot.depends_on"]})
+	}
+
+	// Restart the containers
+	for _, c := range ordered {
+		ready := true
+		for _, name := range containerDependencies(c) {
+			dep, ok := byName[name]
+			if ok && !dep.IsRunning() {
+				logger.Error("Not starting container, dependency isn't running", log.Ctx{"container": c.Name(), "dependency": name})
+				ready = false
+				break
+			}
+		}
+
+		if !ready {
+			continue
+		}
+
+		err := c.Start(false)
+		if err != nil {
+			logger.Errorf("Failed to start container '%s': %v", c.Name(), err)
+		}
+
+		autoStartDelayInt, err := strconv.Atoi(c.ExpandedConfig()["boot.autostart.delay"])
+		if err == nil {
+			time.Sleep(time.Duration(autoStartDelayInt) * time.Second)
+		}
+	}
 }
 
 type containerStopList []container
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Memory usage of a cluster node, in percent.
+type containersBalanceLoad struct {
+	node db.NodeInfo
+	load float64
+}
+
+func containersBalanceTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containersBalance(ctx, d)
+	}
+
+	schedule := func() (time.Duration, error) {
+		interval, err := cluster.ConfigGetInt64(d.cluster, "cluster.balance.interval")
+		if err != nil {
+			logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+			return time.Minute, nil
+		}
+
+		// A zero interval disables the task
+		return time.Duration(interval) * time.Minute, nil
+	}
+
+	return f, schedule
+}
+
+// Move a container from the cluster node using the most memory to the one
+// using the least, if the former is more than "cluster.balance.threshold"
+// percent above the average. Only the leader does it, one container at a time,
+// so that the loads get measured again before anything else gets moved.
+func containersBalance(ctx context.Context, d *Daemon) {
+	address, err := node.ClusterAddress(d.db)
+	if err != nil || address == "" {
+		return
+	}
+
+	leader, err := d.gateway.LeaderAddress()
+	if err != nil || leader != address {
+		return
+	}
+
+	// Leave containers alone while the cluster is being upgraded
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return containerPlacementUpgradeCheck(tx)
+	})
+	if err != nil {
+		logger.Debugf("Not balancing containers: %v", err)
+		return
+	}
+
+	threshold, err := cluster.ConfigGetInt64(d.cluster, "cluster.balance.threshold")
+	if err != nil {
+		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+		return
+	}
+
+	live, err := cluster.ConfigGetBool(d.cluster, "cluster.balance.live")
+	if err != nil {
+		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+		return
+	}
+
+	var nodes []db.NodeInfo
+	var offlineThreshold time.Duration
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		nodes, err = tx.Nodes()
+		if err != nil {
+			return err
+		}
+
+		offlineThreshold, err = tx.NodeOfflineThreshold()
+		return err
+	})
+	if err != nil {
+		logger.Error("Unable to retrieve the list of nodes", log.Ctx{"err": err})
+		return
+	}
+
+	loads := containersBalanceLoads(d, nodes, offlineThreshold)
+
+	source, target, ok := containersBalancePick(loads, float64(threshold))
+	if !ok {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		return
+	default:
+	}
+
+	ct, err := containersBalanceCandidate(d, source.node, target.node, live)
+	if err != nil {
+		logger.Error("Failed to pick a container to balance", log.Ctx{"node": source.node.Name, "err": err})
+		return
+	}
+
+	if ct == nil {
+		logger.Debugf("No container of node %s can be moved to node %s", source.node.Name, target.node.Name)
+		return
+	}
+
+	logger.Info("Balancing container", log.Ctx{"container": ct.Name, "source": source.node.Name, "target": target.node.Name})
+
+	run := func(op *operation) error {
+		return containerBalanceMove(d, *ct, source.node, target.node)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{ct.Name}
+
+	op, err := operationCreate(d.cluster, operationClassTask, "Balancing container", resources, nil, run, nil, nil)
+	if err != nil {
+		logger.Error("Failed to create container balancing operation", log.Ctx{"container": ct.Name, "err": err})
+		return
+	}
+
+	chanRun, err := op.Run()
+	if err != nil {
+		logger.Error("Failed to start container balancing operation", log.Ctx{"container": ct.Name, "err": err})
+		return
+	}
+
+	err = <-chanRun
+	if err != nil {
+		logger.Error("Failed to balance container", log.Ctx{"container": ct.Name, "err": err})
+		return
+	}
+
+	logger.Info("Balanced container", log.Ctx{"container": ct.Name, "source": source.node.Name, "target": target.node.Name})
+	eventSendLifecycle("container-balanced",
+		fmt.Sprintf("/1.0/containers/%s", ct.Name),
+		map[string]interface{}{
+			"source": source.node.Name,
+			"target": target.node.Name,
+		})
+}
+
+// Pick the node using the most memory and the one using the least, if the
+// former is more than threshold percent above the average and the latter
+// below it.
+func containersBalancePick(loads []containersBalanceLoad, threshold float64) (containersBalanceLoad, containersBalanceLoad, bool) {
+	if len(loads) < 2 {
+		return containersBalanceLoad{}, containersBalanceLoad{}, false
+	}
+
+	average := 0.0
+	for _, load := range loads {
+		average += load.load
+	}
+	average /= float64(len(loads))
+
+	sorted := make([]containersBalanceLoad, len(loads))
+	copy(sorted, loads)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].load > sorted[j].load })
+	source := sorted[0]
+	target := sorted[len(sorted)-1]
+
+	if source.load-average <= threshold || target.load >= average {
+		return source, target, false
+	}
+
+	return source, target, true
+}
+
+// Get the memory usage of the online nodes of the cluster. Nodes whose usage
+// can't be retrieved are left out.
+func containersBalanceLoads(d *Daemon, nodes []db.NodeInfo, offlineThreshold time.Duration) []containersBalanceLoad {
+	loads := []containersBalanceLoad{}
+
+	cert := d.endpoints.NetworkCert()
+	for _, node := range nodes {
+		if node.IsOffline(offlineThreshold) {
+			continue
+		}
+
+		client, err := cluster.Connect(node.Address, cert, true)
+		if err != nil {
+			logger.Warnf("Failed to connect to node %s: %v", node.Name, err)
+			continue
+		}
+
+		res, err := client.GetServerResources()
+		if err != nil {
+			logger.Warnf("Failed to get the resources of node %s: %v", node.Name, err)
+			continue
+		}
+
+		if res.Memory.Total == 0 {
+			continue
+		}
+
+		load := 100 * float64(res.Memory.Used) / float64(res.Memory.Total)
+		loads = append(loads, containersBalanceLoad{node: node, load: load})
+	}
+
+	return loads
+}
+
+// Pick the container of the source node to move to the target node, if any.
+// Stopped containers are preferred, running ones are only considered if they
+// may be moved live. Containers whose placement constraints don't allow the
+// target node are skipped, and so are ephemeral, suspended and trashed ones,
+// as well as those with backups, which are stored on their node.
+func containersBalanceCandidate(d *Daemon, source db.NodeInfo, target db.NodeInfo, live bool) (*api.Container, error) {
+	client, err := cluster.Connect(source.Address, d.endpoints.NetworkCert(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := client.GetContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		if containers[i].IsActive() != containers[j].IsActive() {
+			return !containers[i].IsActive()
+		}
+
+		return containers[i].Name < containers[j].Name
+	})
+
+	for _, ct := range containers {
+		if !containersBalanceMovable(ct, source.Name, live) {
+			continue
+		}
+
+		backups, err := client.GetContainerBackupNames(ct.Name)
+		if err != nil || len(backups) > 0 {
+			continue
+		}
+
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			return containerPlacementCheck(tx, ct.ExpandedConfig, ct.Name, target.Name)
+		})
+		if err != nil {
+			continue
+		}
+
+		return &ct, nil
+	}
+
+	return nil, nil
+}
+
+// Return whether the given container of the source node may be balanced.
+func containersBalanceMovable(ct api.Container, source string, live bool) bool {
+	if ct.Location != source || ct.Ephemeral {
+		return false
+	}
+
+	if ct.IsActive() && !live {
+		return false
+	}
+
+	if ct.Config["volatile.suspended"] != "" || ct.Config["volatile.trash.date"] != "" {
+		return false
+	}
+
+	return true
+}
+
+// Move a container from the source node to the target node.
+//
+// Stopped containers backed by ceph are moved in place. Others go through the
+// regular migration path, live for running containers, into a copy under a
+// temporary name which keeps the volatile keys, such as the MAC addresses, of
+// the original, and gets its creation date, annotations and profile
+// priorities. The original is then renamed out of the way and the copy takes
+// its name, and only then is the original deleted. If anything fails along
+// the way, the original is kept and the copy is deleted.
+func containerBalanceMove(d *Daemon, ct api.Container, source db.NodeInfo, target db.NodeInfo) error {
+	cert := d.endpoints.NetworkCert()
+
+	sourceClient, err := cluster.Connect(source.Address, cert, false)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to connect to node %s", source.Name)
+	}
+
+	targetClient, err := cluster.Connect(target.Address, cert, false)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to connect to node %s", target.Name)
+	}
+
+	running := ct.IsActive()
+
+	poolName, err := d.cluster.ContainerPool(ct.Name)
+	if err != nil {
+		return err
+	}
+
+	_, pool, err := d.cluster.StoragePoolGet(poolName)
+	if err != nil {
+		return err
+	}
+
+	if pool.Driver == "ceph" && !running {
+		req := api.ContainerPost{Name: ct.Name, Migration: true}
+		err = containerBalanceWait(sourceClient.UseTarget(target.Name).MigrateContainer(ct.Name, req))
+		if err != nil {
+			return errors.Wrap(err, "Failed to migrate the container")
+		}
+
+		return nil
+	}
+
+	suffix, err := shared.RandomCryptoString()
+	if err != nil {
+		return err
+	}
+
+	copyName := fmt.Sprintf("lxd-balance-%s", suffix[:12])
+	args := lxd.ContainerCopyArgs{Name: copyName, Mode: "pull", Live: running}
+	op, err := targetClient.UseTarget(target.Name).CopyContainer(sourceClient, ct, &args)
+	if err == nil {
+		err = op.Wait()
+	}
+	if err != nil {
+		containerBalanceCleanup(targetClient, copyName)
+		return errors.Wrap(err, "Failed to copy the container")
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ContainerMetadataCopy(ct.Name, copyName)
+	})
+	if err != nil {
+		containerBalanceCleanup(targetClient, copyName)
+		if running {
+			containerBalanceRestart(sourceClient, ct.Name)
+		}
+		return errors.Wrap(err, "Failed to copy the metadata of the container")
+	}
+
+	// After a live migration the original is stopped, so it can be
+	// renamed as well.
+	oldName := fmt.Sprintf("%s-old", copyName)
+	err = containerBalanceWait(sourceClient.RenameContainer(ct.Name, api.ContainerPost{Name: oldName}))
+	if err != nil {
+		containerBalanceCleanup(targetClient, copyName)
+		if running {
+			containerBalanceRestart(sourceClient, ct.Name)
+		}
+		return errors.Wrap(err, "Failed to rename the original container")
+	}
+
+	err = containerBalanceWait(targetClient.RenameContainer(copyName, api.ContainerPost{Name: ct.Name}))
+	if err != nil {
+		containerBalanceCleanup(targetClient, copyName)
+		errRevert := containerBalanceWait(sourceClient.RenameContainer(oldName, api.ContainerPost{Name: ct.Name}))
+		if errRevert != nil {
+			logger.Error("Failed to restore the name of the original container", log.Ctx{"container": ct.Name, "name": oldName, "err": errRevert})
+		} else if running {
+			containerBalanceRestart(sourceClient, ct.Name)
+		}
+		return errors.Wrap(err, "Failed to rename the copy of the container")
+	}
+
+	err = containerBalanceDelete(sourceClient, oldName)
+	if err != nil {
+		logger.Error("Failed to delete the original of a balanced container", log.Ctx{"container": ct.Name, "name": oldName, "err": err})
+	}
+
+	return nil
+}
+
+// Delete a container for good, that is a second time if the first deletion
+// only moved it to the trash.
+func containerBalanceDelete(client lxd.ContainerServer, name string) error {
+	err := containerBalanceWait(client.DeleteContainer(name))
+	if err != nil {
+		return err
+	}
+
+	_, _, err = client.GetContainer(name)
+	if err != nil {
+		return nil
+	}
+
+	return containerBalanceWait(client.DeleteContainer(name))
+}
+
+// Delete the copy of a container which couldn't be balanced, if it made it,
+// stopping it first if it was live migrated.
+func containerBalanceCleanup(client lxd.ContainerServer, name string) {
+	ct, _, err := client.GetContainer(name)
+	if err != nil {
+		return
+	}
+
+	if ct.IsActive() {
+		req := api.ContainerStatePut{Action: "stop", Timeout: -1, Force: true}
+		err = containerBalanceWait(client.UpdateContainerState(name, req, ""))
+		if err != nil {
+			logger.Error("Failed to stop the copy of a container", log.Ctx{"container": name, "err": err})
+			return
+		}
+	}
+
+	err = containerBalanceDelete(client, name)
+	if err != nil {
+		logger.Error("Failed to delete the copy of a container", log.Ctx{"container": name, "err": err})
+	}
+}
+
+// Start again a container which a failed live migration left stopped.
+func containerBalanceRestart(client lxd.ContainerServer, name string) {
+	ct, _, err := client.GetContainer(name)
+	if err != nil || ct.IsActive() {
+		return
+	}
+
+	req := api.ContainerStatePut{Action: "start", Timeout: -1}
+	err = containerBalanceWait(client.UpdateContainerState(name, req, ""))
+	if err != nil {
+		logger.Error("Failed to restart container", log.Ctx{"container": name, "err": err})
+	}
+}
+
+func containerBalanceWait(op lxd.Operation, err error) error {
+	if err != nil {
+		return err
+	}
+
+	return op.Wait()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// A container gets moved from the most loaded node to the least loaded one
+// only if the former is far enough above the average.
+func TestContainersBalancePick(t *testing.T) {
+	loads := []containersBalanceLoad{
+		{node: db.NodeInfo{Name: "n1"}, load: 50},
+		{node: db.NodeInfo{Name: "n2"}, load: 90},
+		{node: db.NodeInfo{Name: "n3"}, load: 10},
+	}
+
+	source, target, ok := containersBalancePick(loads, 20)
+	assert.True(t, ok)
+	assert.Equal(t, "n2", source.node.Name)
+	assert.Equal(t, "n3", target.node.Name)
+	assert.Equal(t, "n1", loads[0].node.Name)
+
+	_, _, ok = containersBalancePick(loads, 40)
+	assert.False(t, ok)
+
+	_, _, ok = containersBalancePick(loads[:1], 0)
+	assert.False(t, ok)
+}
+
+// Running containers are only moved live, and ephemeral, suspended and
+// trashed ones never.
+func TestContainersBalanceMovable(t *testing.T) {
+	ct := func(status string, ephemeral bool, config map[string]string) api.Container {
+		c := api.Container{Location: "n1", Status: status, StatusCode: api.Stopped}
+		if status == "Running" {
+			c.StatusCode = api.Running
+		}
+		c.Ephemeral = ephemeral
+		c.Config = config
+		return c
+	}
+
+	assert.True(t, containersBalanceMovable(ct("Stopped", false, nil), "n1", false))
+	assert.False(t, containersBalanceMovable(ct("Stopped", false, nil), "n2", false))
+	assert.False(t, containersBalanceMovable(ct("Running", false, nil), "n1", false))
+	assert.True(t, containersBalanceMovable(ct("Running", false, nil), "n1", true))
+	assert.False(t, containersBalanceMovable(ct("Stopped", true, nil), "n1", true))
+	assert.False(t, containersBalanceMovable(ct("Stopped", false, map[string]string{"volatile.suspended": "true"}), "n1", true))
+	assert.False(t, containersBalanceMovable(ct("Stopped", false, map[string]string{"volatile.trash.date": "1"}), "n1", true))
+}
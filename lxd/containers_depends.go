@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+)
+
+// Parse the comma separated list of container names of boot.depends_on.
+func containerDependenciesParse(value string) ([]string, error) {
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		err := containerValidName(name)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid container name '%s' in boot.depends_on: %v", name, err)
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// Return the names of the containers that must be running before the given
+// one is started.
+func containerDependencies(c container) []string {
+	names, err := containerDependenciesParse(c.ExpandedConfig()["boot.depends_on"])
+	if err != nil {
+		return nil
+	}
+
+	return names
+}
+
+// Check that setting boot.depends_on to the given value on the given
+// container doesn't introduce a dependency cycle. The expanded config of the
+// other containers is considered, so cycles going through a profile are
+// detected too.
+func containerValidDependencies(s *state.State, name string, value string) error {
+	var graph map[string]string
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		graph, err = containersDependenciesGraph(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	graph[name] = value
+
+	return containerDependenciesCycle(graph, name)
+}
+
+// Return the expanded value of boot.depends_on of all the containers in the
+// cluster, keyed by container name.
+func containersDependenciesGraph(tx *db.ClusterTx) (map[string]string, error) {
+	containers, err := tx.ContainerGetAll(db.CTypeRegular, false)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, c := range containers {
+		for _, profile := range c.Profiles {
+			if !shared.StringInSlice(profile, names) {
+				names = append(names, profile)
+			}
+		}
+	}
+
+	profiles, _, err := tx.ProfilesExpandData(names)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := map[string]string{}
+	for _, c := range containers {
+		graph[c.Name] = containerDependenciesExpand(c.Config, c.Profiles, profiles)
+	}
+
+	return graph, nil
+}
+
+// Return the value of boot.depends_on a container with the given local config
+// and profiles ends up with: the local value if any, otherwise the one of the
+// last profile setting it.
+func containerDependenciesExpand(config map[string]string, profiles []string, profileConfigs map[string]map[string]string) string {
+	value, ok := config["boot.depends_on"]
+	if ok {
+		return value
+	}
+
+	for _, profile := range profiles {
+		v, ok := profileConfigs[profile]["boot.depends_on"]
+		if ok {
+			value = v
+		}
+	}
+
+	return value
+}
+
+// Check that the given container isn't part of a dependency cycle, given the
+// boot.depends_on value of each container.
+func containerDependenciesCycle(graph map[string]string, name string) error {
+	// Depth-first walk from the container, keeping track of the path to
+	// report the cycle.
+	visited := map[string]bool{}
+	var walk func(current string, path []string) error
+	walk = func(current string, path []string) error {
+		deps, err := containerDependenciesParse(graph[current])
+		if err != nil {
+			return err
+		}
+
+		for _, dep := range deps {
+			if dep == name {
+				return fmt.Errorf("Dependency cycle in boot.depends_on: %s", strings.Join(append(path, dep), " -> "))
+			}
+
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+
+			err := walk(dep, append(path, dep))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return walk(name, []string{name})
+}
+
+// Start the containers the given one depends on which are on this node and
+// aren't running yet, along with their own dependencies, so that starting a
+// single container, or several ones concurrently, honors boot.depends_on the
+// same way autostart does. The path holds the containers being started, to
+// stop on cycles.
+func containerStartDependencies(s *state.State, c container, path []string) error {
+	path = append(path, c.Name())
+
+	for _, name := range containerDependencies(c) {
+		if shared.StringInSlice(name, path) {
+			return fmt.Errorf("Dependency cycle in boot.depends_on: %s", strings.Join(append(path, name), " -> "))
+		}
+
+		var address string
+		err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			address, err = tx.ContainerNodeAddress(name)
+			return err
+		})
+		if err == db.ErrNoSuchObject {
+			return fmt.Errorf("Dependency '%s' doesn't exist", name)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Dependencies on other nodes are left to their own node.
+		if address != "" {
+			continue
+		}
+
+		dep, err := containerLoadByName(s, name)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to load dependency '%s'", name)
+		}
+
+		if dep.IsRunning() {
+			continue
+		}
+
+		err = containerStartDependencies(s, dep, path)
+		if err != nil {
+			return err
+		}
+
+		err = dep.Start(false)
+		// Another request may have started it meanwhile.
+		if err != nil && !dep.IsRunning() {
+			return errors.Wrapf(err, "Failed to start dependency '%s'", name)
+		}
+	}
+
+	return nil
+}
+
+// Order the given containers so that each one comes after the containers it
+// depends on, otherwise keeping their relative order. Dependencies outside of
+// the list are ignored. The containers which can't be ordered because of a
+// dependency cycle are returned separately.
+func containersStartOrder(containers []container) ([]container, []container) {
+	listed := map[string]bool{}
+	for _, c := range containers {
+		listed[c.Name()] = true
+	}
+
+	placed := map[string]bool{}
+	ordered := []container{}
+
+	for len(ordered) < len(containers) {
+		found := false
+		for _, c := range containers {
+			if placed[c.Name()] {
+				continue
+			}
+
+			ready := true
+			for _, dep := range containerDependencies(c) {
+				if listed[dep] && !placed[dep] {
+					ready = false
+					break
+				}
+			}
+
+			if !ready {
+				continue
+			}
+
+			placed[c.Name()] = true
+			ordered = append(ordered, c)
+			found = true
+			break
+		}
+
+		if !found {
+			break
+		}
+	}
+
+	cyclic := []container{}
+	for _, c := range containers {
+		if !placed[c.Name()] {
+			cyclic = append(cyclic, c)
+		}
+	}
+
+	return ordered, cyclic
+}
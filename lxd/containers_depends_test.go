@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The local value of boot.depends_on wins over the profiles, and the last
+// profile setting it wins over the earlier ones.
+func TestContainerDependenciesExpand(t *testing.T) {
+	profiles := map[string]map[string]string{
+		"default": {},
+		"web":     {"boot.depends_on": "db"},
+		"cache":   {"boot.depends_on": "redis"},
+	}
+
+	assert.Equal(t, "", containerDependenciesExpand(map[string]string{}, []string{"default"}, profiles))
+	assert.Equal(t, "db", containerDependenciesExpand(map[string]string{}, []string{"default", "web"}, profiles))
+	assert.Equal(t, "redis", containerDependenciesExpand(map[string]string{}, []string{"web", "cache"}, profiles))
+	assert.Equal(t, "", containerDependenciesExpand(map[string]string{"boot.depends_on": ""}, []string{"web"}, profiles))
+	assert.Equal(t, "dns", containerDependenciesExpand(map[string]string{"boot.depends_on": "dns"}, []string{"web"}, profiles))
+}
+
+// Cycles are reported with the path going through them, however long it is.
+func TestContainerDependenciesCycle(t *testing.T) {
+	graph := map[string]string{
+		"web":   "db, cache",
+		"db":    "",
+		"cache": "db",
+	}
+	assert.NoError(t, containerDependenciesCycle(graph, "web"))
+
+	graph["db"] = "web"
+	assert.EqualError(t, containerDependenciesCycle(graph, "web"), "Dependency cycle in boot.depends_on: web -> db -> web")
+
+	graph["db"] = "cache"
+	assert.EqualError(t, containerDependenciesCycle(graph, "db"), "Dependency cycle in boot.depends_on: db -> cache -> db")
+
+	graph["db"] = "db"
+	assert.EqualError(t, containerDependenciesCycle(graph, "db"), "Dependency cycle in boot.depends_on: db -> db")
+
+	graph["db"] = "in valid"
+	assert.Error(t, containerDependenciesCycle(graph, "web"))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -42,6 +43,8 @@ func containersGet(d *Daemon, r *http.Request) Response {
 func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 	var result map[string][]string // Containers by node address
 	var nodes map[string]string    // Node names by container
+	var trash map[string]string    // Trash dates by container
+	var builds map[string]string   // Image build containers
 	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
 		var err error
 
@@ -55,13 +58,65 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 			return err
 		}
 
+		trash, err = tx.ContainersConfigValue("volatile.trash.date")
+		if err != nil {
+			return err
+		}
+
+		builds, err = tx.ContainersConfigValue("volatile.image_build")
+		if err != nil {
+			return err
+		}
+
 		return nil
 	})
 	if err != nil {
 		return []string{}, err
 	}
 
+	// Containers in the trash are only listed when asked for, and then
+	// they're the only ones listed. Image build containers are never
+	// listed.
+	trashed := shared.IsTrue(r.FormValue("trashed"))
+	for address, containers := range result {
+		listed := []string{}
+		for _, name := range containers {
+			_, inTrash := trash[name]
+			_, isBuild := builds[name]
+			if inTrash == trashed && !isBuild {
+				listed = append(listed, name)
+			}
+		}
+
+		result[address] = listed
+	}
+
 	recursion := util.IsRecursionRequest(r)
+
+	// Filtering requires the full container information
+	filter := containersGetFilter(r)
+	if filter != nil {
+		recursion = true
+	}
+
+	// Fetch the database records of the local containers in one go
+	local := map[string]db.ContainerArgs{}
+	if recursion {
+		var cts []db.ContainerArgs
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			cts, err = tx.ContainerGetAll(db.CTypeRegular, true)
+			return err
+		})
+		if err != nil {
+			return []string{}, err
+		}
+
+		for _, args := range cts {
+			local[args.Name] = args
+		}
+	}
+
 	resultString := []string{}
 	resultList := []*api.Container{}
 	resultMu := sync.Mutex{}
@@ -103,7 +158,7 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 			func(address string, containers []string) {
 				cert := d.endpoints.NetworkCert()
 
-				cs, err := doContainersGetFromNode(address, cert)
+				cs, err := doContainersGetFromNode(address, cert, trashed)
 				if err != nil {
 					for _, name := range containers {
 						resultAppend(name, api.Container{}, err)
@@ -113,6 +168,10 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 				}
 
 				for _, c := range cs {
+					if !shared.StringInSlice(c.Name, containers) {
+						continue
+					}
+
 					resultAppend(c.Name, c, nil)
 				}
 			}(address, containers)
@@ -127,7 +186,13 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 				continue
 			}
 
-			c, err := doContainerGet(d.State(), container)
+			var c *api.Container
+			args, ok := local[container]
+			if ok {
+				c, err = doContainerRender(d.State(), args)
+			} else {
+				c, err = doContainerGet(d.State(), container)
+			}
 			if err != nil {
 				resultAppend(container, api.Container{}, err)
 			} else {
@@ -145,7 +210,57 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 		return resultList[i].Name < resultList[j].Name
 	})
 
-	return resultList, nil
+	if filter == nil {
+		return resultList, nil
+	}
+
+	filtered := []*api.Container{}
+	for _, c := range resultList {
+		if filter(c) {
+			filtered = append(filtered, c)
+		}
+	}
+
+	if util.IsRecursionRequest(r) {
+		return filtered, nil
+	}
+
+	for _, c := range filtered {
+		url := fmt.Sprintf("/%s/containers/%s", version.APIVersion, c.Name)
+		resultString = append(resultString, url)
+	}
+
+	return resultString, nil
+}
+
+// Return a function matching the containers against the description,
+// architecture and status filters of the request, or nil if none is set.
+// Descriptions match case-insensitively on any part of them, statuses
+// case-insensitively and architectures exactly.
+func containersGetFilter(r *http.Request) func(c *api.Container) bool {
+	description := r.FormValue("description")
+	architecture := r.FormValue("architecture")
+	status := r.FormValue("status")
+
+	if description == "" && architecture == "" && status == "" {
+		return nil
+	}
+
+	return func(c *api.Container) bool {
+		if description != "" && !strings.Contains(strings.ToLower(c.Description), strings.ToLower(description)) {
+			return false
+		}
+
+		if architecture != "" && c.Architecture != architecture {
+			return false
+		}
+
+		if status != "" && !strings.EqualFold(c.Status, status) {
+			return false
+		}
+
+		return true
+	}
 }
 
 func doContainerGet(s *state.State, cname string) (*api.Container, error) {
@@ -162,15 +277,43 @@ func doContainerGet(s *state.State, cname string) (*api.Container, error) {
 	return cts.(*api.Container), nil
 }
 
+// Render a container whose database record was already fetched.
+func doContainerRender(s *state.State, args db.ContainerArgs) (*api.Container, error) {
+	c, err := containerLXCLoad(s, args)
+	if err != nil {
+		return nil, err
+	}
+
+	cts, _, err := c.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	return cts.(*api.Container), nil
+}
+
 // Fetch information about the containers on the given remote node, using the
 // rest API and with a timeout of 30 seconds.
-func doContainersGetFromNode(node string, cert *shared.CertInfo) ([]api.Container, error) {
+func doContainersGetFromNode(node string, cert *shared.CertInfo, trashed bool) ([]api.Container, error) {
 	f := func() ([]api.Container, error) {
 		client, err := cluster.Connect(node, cert, true)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to connect to node %s", node)
 		}
-		containers, err := client.GetContainers()
+
+		if !trashed {
+			containers, err := client.GetContainers()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get containers from node %s", node)
+			}
+			return containers, nil
+		}
+
+		containers := []api.Container{}
+		resp, _, err := client.RawQuery("GET", "/1.0/containers?recursion=1&trashed=1", nil, "")
+		if err == nil {
+			err = resp.MetadataAsStruct(&containers)
+		}
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to get containers from node %s", node)
 		}
@@ -190,6 +190,22 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 		return BadRequest(err)
 	}
 
+	if req.Source.RegenerateIdentity {
+		if req.Source.Live {
+			return BadRequest(fmt.Errorf("Regenerating the identity of live migrated containers isn't supported"))
+		}
+
+		for key, value := range req.Devices {
+			req.Devices[key] = containerRegenerateDevice(value)
+		}
+
+		for key := range req.Config {
+			if strings.HasPrefix(key, "volatile.") && strings.HasSuffix(key, ".hwaddr") {
+				delete(req.Config, key)
+			}
+		}
+	}
+
 	// Prepare the container creation request
 	args := db.ContainerArgs{
 		Architecture: architecture,
@@ -414,6 +430,14 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 			return err
 		}
 
+		if req.Source.RegenerateIdentity {
+			err = containerRegenerateIdentity(c)
+			if err != nil {
+				c.Delete()
+				return err
+			}
+		}
+
 		if !migrationArgs.Live {
 			if req.Config["volatile.last_state.power"] == "RUNNING" {
 				return c.Start(false)
@@ -487,15 +511,29 @@ func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
 			continue
 		}
 
+		if req.Source.RegenerateIdentity {
+			value = containerRegenerateDevice(value)
+		}
+
 		req.Devices[key] = value
 	}
 
-	// Profiles override
+	// Profiles override, keeping their priorities when inherited
+	var profilePriorities map[string]int
 	if req.Profiles == nil {
 		req.Profiles = source.Profiles()
+
+		profilePriorities, err = d.cluster.ContainerProfilePriorities(source.Id())
+		if err != nil {
+			return SmartError(err)
+		}
 	}
 
 	if req.Stateful {
+		if req.Source.RegenerateIdentity {
+			return BadRequest(fmt.Errorf("Regenerating the identity of stateful copies isn't supported"))
+		}
+
 		sourceName, _, _ := containerGetParentAndSnapshotName(source.Name())
 		if sourceName != req.Name {
 			return BadRequest(fmt.Errorf(`Copying stateful `+
@@ -516,13 +554,24 @@ func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
 		Name:         req.Name,
 		Profiles:     req.Profiles,
 		Stateful:     req.Stateful,
+
+		ProfilePriorities: profilePriorities,
 	}
 
 	run := func(op *operation) error {
-		_, err := containerCreateAsCopy(d.State(), args, source, req.Source.ContainerOnly)
+		c, err := containerCreateAsCopy(d.State(), args, source, req.Source.ContainerOnly)
 		if err != nil {
 			return err
 		}
+
+		if req.Source.RegenerateIdentity {
+			err = containerRegenerateIdentity(c)
+			if err != nil {
+				c.Delete()
+				return err
+			}
+		}
+
 		return nil
 	}
 
@@ -620,21 +669,69 @@ func containersPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	// No container can be placed while the cluster is being upgraded
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return containerPlacementUpgradeCheck(tx)
+	})
+	if err != nil {
+		return Unavailable(err)
+	}
+
 	targetNode := r.FormValue("target")
-	if targetNode == "" {
+	if targetNode == "" && req.Source.Type == "copy" && req.Source.Source != "" {
+		// Local copies, including new containers created from a
+		// snapshot, happen on the node hosting the source.
+		cert := d.endpoints.NetworkCert()
+		client, err := cluster.ConnectIfContainerIsRemote(d.cluster, req.Source.Source, cert)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		if client != nil {
+			logger.Debugf("Forward container post request to the node of %s", req.Source.Source)
+			op, err := client.CreateContainer(req)
+			if err != nil {
+				return SmartError(err)
+			}
+
+			opAPI := op.Get()
+			return ForwardedOperationResponse(&opAPI)
+		}
+	} else if targetNode == "" {
 		// If no target node was specified, pick the node with the
-		// least number of containers. If there's just one node, or if
-		// the selected node is the local one, this is effectively a
-		// no-op, since NodeWithLeastContainers() will return an empty
-		// string.
+		// least number of containers, out of the ones allowed by the
+		// placement keys of the container. If there's just one node,
+		// or if the selected node is the local one, this is
+		// effectively a no-op.
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := containerPlacementConfig(tx, req.Config, req.Profiles)
+			if err != nil {
+				return err
+			}
+
+			targetNode, err = containerPlacementNode(tx, config, req.Name)
+			return err
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+	} else {
+		var config map[string]string
 		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
 			var err error
-			targetNode, err = tx.NodeWithLeastContainers()
+			config, err = containerPlacementConfig(tx, req.Config, req.Profiles)
 			return err
 		})
 		if err != nil {
 			return SmartError(err)
 		}
+
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			return containerPlacementCheck(tx, config, req.Name, targetNode)
+		})
+		if err != nil {
+			return BadRequest(err)
+		}
 	}
 
 	if targetNode != "" {
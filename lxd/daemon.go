@@ -25,6 +25,7 @@ import (
 	"gopkg.in/macaroon-bakery.v2/bakery/identchecker"
 	"gopkg.in/macaroon-bakery.v2/httpbakery"
 
+	"github.com/lxc/lxd/lxd/bgp"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/endpoints"
@@ -47,6 +48,7 @@ type Daemon struct {
 	os           *sys.OS
 	db           *db.Node
 	maas         *maas.Controller
+	bgp          *bgp.Server
 	cluster      *db.Cluster
 	setupChan    chan struct{} // Closed when basic Daemon setup is completed
 	readyChan    chan struct{} // Closed when LXD is fully ready
@@ -57,8 +59,19 @@ type Daemon struct {
 
 	// Indexes of tasks that need to be reset when their execution interval
 	// changes.
-	taskPruneImages *task.Task
-	taskAutoUpdate  *task.Task
+	taskPruneImages     *task.Task
+	taskAutoUpdate      *task.Task
+	taskComplianceCheck *task.Task
+	taskUsageHistory    *task.Task
+	taskACME            *task.Task
+	taskBalance         *task.Task
+	taskReplica         *task.Task
+
+	// Local replica of the global database, used to serve GET requests
+	// on list endpoints on nodes which aren't database nodes, if enabled.
+	replica     *daemonReplica
+	replicaUsed bool
+	replicaMu   sync.Mutex
 
 	config    *DaemonConfig
 	endpoints *endpoints.Endpoints
@@ -86,6 +99,7 @@ func NewDaemon(config *DaemonConfig, os *sys.OS) *Daemon {
 	return &Daemon{
 		config:       config,
 		os:           os,
+		bgp:          bgp.NewServer(),
 		setupChan:    make(chan struct{}),
 		readyChan:    make(chan struct{}),
 		shutdownChan: make(chan struct{}),
@@ -162,6 +176,10 @@ func (d *Daemon) checkTrustedClient(r *http.Request) error {
 
 	for i := range r.TLS.PeerCertificates {
 		if util.CheckTrustState(*r.TLS.PeerCertificates[i], d.clientCerts) {
+			if trustCertificateRevoked(r.TLS.PeerCertificates[i]) {
+				return fmt.Errorf("revoked certificate")
+			}
+
 			return nil
 		}
 	}
@@ -214,7 +232,7 @@ func isJSONRequest(r *http.Request) bool {
 
 // State creates a new State instance liked to our internal db and os.
 func (d *Daemon) State() *state.State {
-	return state.NewState(d.db, d.cluster, d.maas, d.os)
+	return state.NewState(d.db, d.cluster, d.maas, d.bgp, d.os)
 }
 
 // UnixSocket returns the full path to the unix.socket file that this daemon is
@@ -270,6 +288,20 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 			return
 		}
 
+		// Restrict local clients according to core.unix_groups
+		if r.RemoteAddr == "@" {
+			readOnly, err := d.checkLocalAccess(w, r, version, c.name)
+			if err != nil {
+				logger.Warn("rejecting request from local client", log.Ctx{"err": err})
+				Forbidden(err).Render(w)
+				return
+			}
+
+			if readOnly {
+				r = r.WithContext(context.WithValue(r.Context(), localAccessKey{}, true))
+			}
+		}
+
 		if debug && r.Method != "GET" && isJSONRequest(r) {
 			newBody := &bytes.Buffer{}
 			captured := &bytes.Buffer{}
@@ -288,7 +320,11 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 
 		switch r.Method {
 		case "GET":
-			if c.get != nil {
+			if c.get != nil && version == "1.0" {
+				rd, release := d.readOnly(c.name)
+				defer release()
+				resp = c.get(rd, r)
+			} else if c.get != nil {
 				resp = c.get(d, r)
 			}
 		case "PUT":
@@ -444,6 +480,11 @@ func (d *Daemon) init() error {
 		return errors.Wrap(err, "failed to fetch node address")
 	}
 
+	clusterAddress, err := node.ClusterAddress(d.db)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch node cluster address")
+	}
+
 	/* Setup the web server */
 	config := &endpoints.Config{
 		Dir:                  d.os.VarDir,
@@ -453,17 +494,23 @@ func (d *Daemon) init() error {
 		DevLxdServer:         DevLxdServer(d),
 		LocalUnixSocketGroup: d.config.Group,
 		NetworkAddress:       address,
+		ClusterAddress:       clusterAddress,
 	}
 	d.endpoints, err = endpoints.Up(config)
 	if err != nil {
 		return err
 	}
 
+	err = d.localGroupsUpdate()
+	if err != nil {
+		return errors.Wrap(err, "failed to setup the local unix socket access")
+	}
+
 	/* Open the cluster database */
 	for {
 		logger.Info("Initializing global database")
 		dir := filepath.Join(d.os.VarDir, "database")
-		d.cluster, err = db.OpenCluster("db.bin", d.gateway.Dialer(), address, dir)
+		d.cluster, err = db.OpenCluster("db.bin", d.gateway.Dialer(), clusterAddress, dir)
 		if err == nil {
 			break
 		}
@@ -518,6 +565,14 @@ func (d *Daemon) init() error {
 		}
 	}
 
+	/* Forget the operations of the previous run */
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.OperationsFlush()
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to flush the operations of the previous run")
+	}
+
 	// Setup the user-agent
 	clustered, err := cluster.Enabled(d.db)
 	if err != nil {
@@ -535,6 +590,14 @@ func (d *Daemon) init() error {
 		return err
 	}
 
+	/* Mount the storage shared by all nodes */
+	if !d.os.MockMode {
+		err = storageSharedSetup(d.State())
+		if err != nil {
+			logger.Warn("Unable to setup the shared storage", log.Ctx{"err": err})
+		}
+	}
+
 	/* Apply all patches */
 	err = patchesApplyAll(d)
 	if err != nil {
@@ -557,11 +620,14 @@ func (d *Daemon) init() error {
 	/* Log expiry */
 	d.tasks.Add(expireLogsTask(d.State()))
 
-	/* Setup the proxy handler, external authentication and MAAS */
+	/* Setup the proxy handler, external authentication, MAAS and BGP */
 	macaroonEndpoint := ""
 	maasAPIURL := ""
 	maasAPIKey := ""
 	maasMachine := ""
+	bgpASN := uint32(0)
+	bgpPeers := map[string]uint32{}
+	bgpRouterID := ""
 
 	err = d.db.Transaction(func(tx *db.NodeTx) error {
 		config, err := node.ConfigLoad(tx)
@@ -570,6 +636,7 @@ func (d *Daemon) init() error {
 		}
 
 		maasMachine = config.MAASMachine()
+		bgpRouterID = config.BGPRouterID()
 		return nil
 	})
 	if err != nil {
@@ -588,6 +655,8 @@ func (d *Daemon) init() error {
 		)
 		macaroonEndpoint = config.MacaroonEndpoint()
 		maasAPIURL, maasAPIKey = config.MAASController()
+		bgpASN = config.BGPASN()
+		bgpPeers = config.BGPPeers()
 		return nil
 	})
 	if err != nil {
@@ -599,6 +668,11 @@ func (d *Daemon) init() error {
 		return err
 	}
 
+	err = d.setupBGP(bgpASN, bgpPeers, bgpRouterID)
+	if err != nil {
+		logger.Warn("Unable to setup the BGP speaker", log.Ctx{"err": err})
+	}
+
 	if !d.os.MockMode {
 		// Start the scheduler
 		go deviceEventListener(d.State())
@@ -648,6 +722,48 @@ func (d *Daemon) Ready() error {
 	/* Events */
 	d.tasks.Add(cluster.Events(d.endpoints, d.cluster, eventForward))
 
+	/* Failure of the operations of offline nodes */
+	d.tasks.Add(operationsReapTask(d))
+
+	/* Configuration drift checks */
+	d.taskComplianceCheck = d.tasks.Add(complianceCheckTask(d))
+
+	/* Container usage history */
+	d.taskUsageHistory = d.tasks.Add(containerUsageTask(d))
+
+	/* Recovery of unavailable storage pools */
+	d.tasks.Add(storagePoolsRecoverTask(d))
+
+	/* Monitoring of LVM thin pools */
+	d.tasks.Add(storagePoolsLvmMonitorTask(d))
+
+	/* Health checks of storage pools */
+	d.tasks.Add(storagePoolsHealthTask(d))
+
+	/* Expiry of trashed containers */
+	d.tasks.Add(containersTrashPurgeTask(d))
+
+	/* Pruning of snapshots according to their retention policy */
+	d.tasks.Add(snapshotsPruneTask(d))
+
+	/* Unfreezing of containers frozen for a limited duration */
+	d.tasks.Add(containersThawTask(d))
+
+	/* Suspension of idle containers */
+	d.tasks.Add(containersAutoSuspendTask(d))
+
+	/* Health checks of containers */
+	d.tasks.Add(containersHealthCheckTask(d))
+
+	/* Balancing of containers across cluster nodes */
+	d.taskBalance = d.tasks.Add(containersBalanceTask(d))
+
+	/* Warnings about expiring certificates */
+	d.tasks.Add(certificatesExpiryTask(d))
+
+	/* Replica of the global database */
+	d.taskReplica = d.tasks.Add(replicaTask(d))
+
 	// FIXME: There's no hard reason for which we should not run these
 	//        tasks in mock mode. However it requires that we tweak them so
 	//        they exit gracefully without blocking (something we should do
@@ -662,6 +778,9 @@ func (d *Daemon) Ready() error {
 
 		/* Auto-update instance types */
 		d.tasks.Add(instanceRefreshTypesTask(d))
+
+		/* Server certificate through ACME */
+		d.taskACME = d.tasks.Add(acmeTask(d))
 	}
 
 	d.tasks.Start()
@@ -671,6 +790,11 @@ func (d *Daemon) Ready() error {
 	/* Restore containers */
 	containersRestart(s)
 
+	/* Watch for containers exiting */
+	if !d.os.MockMode {
+		go containerMonitorStart(s)
+	}
+
 	/* Re-balance in case things changed while LXD was down */
 	deviceTaskBalance(s)
 
@@ -725,6 +849,10 @@ func (d *Daemon) Stop() error {
 
 	trackError(d.tasks.Stop(3 * time.Second)) // Give tasks a bit of time to cleanup.
 
+	if d.bgp != nil {
+		d.bgp.Stop()
+	}
+
 	shouldUnmount := false
 	if d.cluster != nil {
 		// It might be that database nodes are all down, in that case
@@ -754,6 +882,7 @@ func (d *Daemon) Stop() error {
 			trackError(err)
 		}
 	}
+	d.replicaSet(nil)
 	if d.db != nil {
 		trackError(d.db.Close())
 	}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/oci"
 	"github.com/lxc/lxd/lxd/sys"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
@@ -103,11 +104,23 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 	var remote lxd.ImageServer
 	var info *api.Image
 
+	var registry *oci.Registry
+	var ociName string
+	var ociReference string
+	var ociManifest *oci.Manifest
+	var ociDigest string
+
 	// Default protocol is LXD
 	if protocol == "" {
 		protocol = "lxd"
 	}
 
+	// Download from the configured mirror of the server, if any
+	downloadServer, proxy, err := d.imageDownloadSource(server)
+	if err != nil {
+		return nil, err
+	}
+
 	// Default the fingerprint to the alias string we received
 	fp := alias
 
@@ -119,10 +132,10 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 			// Add a new entry to the cache
 			refresh := func() (*imageStreamCacheEntry, error) {
 				// Setup simplestreams client
-				remote, err = lxd.ConnectSimpleStreams(server, &lxd.ConnectionArgs{
+				remote, err = lxd.ConnectSimpleStreams(downloadServer, &lxd.ConnectionArgs{
 					TLSServerCert: certificate,
 					UserAgent:     version.UserAgent,
-					Proxy:         d.proxy,
+					Proxy:         proxy,
 				})
 				if err != nil {
 					return nil, err
@@ -200,10 +213,10 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 		}
 	} else if protocol == "lxd" {
 		// Setup LXD client
-		remote, err = lxd.ConnectPublicLXD(server, &lxd.ConnectionArgs{
+		remote, err = lxd.ConnectPublicLXD(downloadServer, &lxd.ConnectionArgs{
 			TLSServerCert: certificate,
 			UserAgent:     version.UserAgent,
-			Proxy:         d.proxy,
+			Proxy:         proxy,
 		})
 		if err != nil {
 			return nil, err
@@ -225,6 +238,37 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 
 			fp = info.Fingerprint
 		}
+	} else if protocol == "oci" {
+		// Setup the registry client
+		httpClient, err := util.HTTPClient(certificate, proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		registry, err = oci.NewRegistry(downloadServer, httpClient, version.UserAgent)
+		if err != nil {
+			return nil, err
+		}
+
+		ociName, ociReference, err = registry.ParseReference(alias)
+		if err != nil {
+			return nil, err
+		}
+
+		// OCI images are identified by the digest of their manifest,
+		// recorded as their oci.digest property, while their fingerprint
+		// is the hash of their files like for any other image.
+		ociManifest, ociDigest, err = registry.GetManifest(ociName, ociReference, d.ociArchitectures())
+		if err != nil {
+			return nil, err
+		}
+
+		fp = strings.TrimPrefix(ociDigest, "sha256:")
+
+		cachedFingerprint, err := d.cluster.ImageGetFromProperty("oci.digest", ociDigest)
+		if err == nil {
+			fp = cachedFingerprint
+		}
 	}
 
 	// If auto-update is on and we're being given the image by
@@ -311,16 +355,17 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 	}
 
 	// Add the download to the queue
+	downloading := fp
 	imagesDownloadingLock.Lock()
-	imagesDownloading[fp] = make(chan bool)
+	imagesDownloading[downloading] = make(chan bool)
 	imagesDownloadingLock.Unlock()
 
 	// Unlock once this func ends.
 	defer func() {
 		imagesDownloadingLock.Lock()
-		if waitChannel, ok := imagesDownloading[fp]; ok {
+		if waitChannel, ok := imagesDownloading[downloading]; ok {
 			close(waitChannel)
-			delete(imagesDownloading, fp)
+			delete(imagesDownloading, downloading)
 		}
 		imagesDownloadingLock.Unlock()
 	}()
@@ -437,12 +482,12 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 		}
 	} else if protocol == "direct" {
 		// Setup HTTP client
-		httpClient, err := util.HTTPClient(certificate, d.proxy)
+		httpClient, err := util.HTTPClient(certificate, proxy)
 		if err != nil {
 			return nil, err
 		}
 
-		req, err := http.NewRequest("GET", server, nil)
+		req, err := http.NewRequest("GET", downloadServer, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -457,7 +502,7 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 		}
 
 		if raw.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("Unable to fetch %s: %s", server, raw.Status)
+			return nil, fmt.Errorf("Unable to fetch %s: %s", downloadServer, raw.Status)
 		}
 
 		// Progress handler
@@ -490,7 +535,7 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 		// Validate hash
 		result := fmt.Sprintf("%x", sha256.Sum(nil))
 		if result != fp {
-			return nil, fmt.Errorf("Hash mismatch for %s: %s != %s", server, result, fp)
+			return nil, fmt.Errorf("Hash mismatch for %s: %s != %s", downloadServer, result, fp)
 		}
 
 		// Parse the image
@@ -506,6 +551,15 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 		info.CreatedAt = time.Unix(imageMeta.CreationDate, 0)
 		info.ExpiresAt = time.Unix(imageMeta.ExpiryDate, 0)
 		info.Properties = imageMeta.Properties
+	} else if protocol == "oci" {
+		info, err = d.imageDownloadOCI(registry, ociName, ociReference, ociManifest, ociDigest, destName, progress)
+		if err != nil {
+			return nil, err
+		}
+
+		fp = info.Fingerprint
+	} else {
+		return nil, fmt.Errorf("Unsupported protocol: %s", protocol)
 	}
 
 	// Override visiblity
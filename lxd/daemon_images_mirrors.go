@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+)
+
+// Return the server to download the images of the given server from, along
+// with the proxy to use for it, as set by images.mirrors and images.proxy.
+// Images keep being recorded as coming from their original server, so that
+// mirrors can be changed without losing track of them.
+func (d *Daemon) imageDownloadSource(server string) (string, func(*http.Request) (*url.URL, error), error) {
+	var config *cluster.Config
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		config, err = cluster.ConfigLoad(tx)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	proxy := d.proxy
+	if config.ImagesProxy() != "" {
+		proxy = shared.ProxyFromConfig(config.ImagesProxy(), config.ImagesProxy(), config.ProxyIgnoreHosts())
+	}
+
+	return imageMirror(config.ImagesMirrors(), server), proxy, nil
+}
+
+// Rewrite the URL of a server according to the longest matching mirror rule.
+func imageMirror(mirrors map[string]string, server string) string {
+	match := ""
+	for source := range mirrors {
+		if server != source && !strings.HasPrefix(server, source+"/") {
+			continue
+		}
+
+		if len(source) > len(match) {
+			match = source
+		}
+	}
+
+	if match == "" {
+		return server
+	}
+
+	return mirrors[match] + strings.TrimPrefix(server, match)
+}
+
+// Make the simplestreams cache entries get refreshed on their next use, after
+// a change of the mirrors or proxy they were fetched through.
+func imageStreamCacheExpire() {
+	imageStreamCacheLock.Lock()
+	defer imageStreamCacheLock.Unlock()
+
+	for _, entry := range imageStreamCache {
+		entry.expiry = time.Time{}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/oci"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/ioprogress"
+	"github.com/lxc/lxd/shared/osarch"
+)
+
+// Return the names of the architectures of the host, as used by OCI images.
+func (d *Daemon) ociArchitectures() []string {
+	architectures := []string{}
+	for _, arch := range d.os.Architectures {
+		name, ok := oci.Architectures[arch]
+		if ok {
+			architectures = append(architectures, name)
+		}
+	}
+
+	return architectures
+}
+
+// Download the layers of an OCI image, flatten them into a rootfs and pack it
+// along with synthesized metadata as a unified image at destName. The digest
+// of the manifest is recorded as the oci.digest property of the image.
+func (d *Daemon) imageDownloadOCI(registry *oci.Registry, name string, reference string, manifest *oci.Manifest, digest string, destName string, progress func(ioprogress.ProgressData)) (*api.Image, error) {
+	image, err := registry.GetImage(name, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	archID := osarch.ARCH_UNKNOWN
+	for id, arch := range oci.Architectures {
+		if arch == image.Architecture {
+			archID = id
+		}
+	}
+
+	archName, err := osarch.ArchitectureName(archID)
+	if err != nil {
+		return nil, fmt.Errorf("Unsupported image architecture: %s", image.Architecture)
+	}
+
+	tmpDir, err := ioutil.TempDir(shared.VarPath("images"), "lxd_oci_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	rootfs := filepath.Join(tmpDir, "rootfs")
+	err = os.Mkdir(rootfs, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	// Flatten the layers, from the bottom one up
+	for i, layer := range manifest.Layers {
+		blob, err := registry.GetBlob(name, layer)
+		if err != nil {
+			return nil, err
+		}
+
+		body := &ioprogress.ProgressReader{
+			ReadCloser: blob,
+			Tracker: &ioprogress.ProgressTracker{
+				Length: layer.Size,
+				Handler: func(percent int64, speed int64) {
+					progress(ioprogress.ProgressData{Text: fmt.Sprintf("Layer %d/%d: %d%% (%s/s)", i+1, len(manifest.Layers), percent, shared.GetByteSizeString(speed, 2))})
+				},
+			},
+		}
+
+		err = oci.ApplyLayer(rootfs, body, layer.MediaType)
+		if err == nil {
+			// Read up to the end of the blob to check its digest
+			_, err = io.Copy(ioutil.Discard, body)
+		}
+		blob.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to apply layer %s: %v", layer.Digest, err)
+		}
+	}
+
+	created, err := time.Parse(time.RFC3339Nano, image.Created)
+	if err != nil {
+		created = time.Now().UTC()
+	}
+
+	// The labels of the image are its closest match to properties
+	properties := map[string]string{}
+	for key, value := range image.Config.Labels {
+		properties[key] = value
+	}
+
+	if properties["description"] == "" {
+		properties["description"] = fmt.Sprintf("%s:%s (OCI)", name, reference)
+	}
+
+	properties["oci.digest"] = digest
+
+	metadata := api.ImageMetadata{
+		Architecture: archName,
+		CreationDate: created.Unix(),
+		Properties:   properties,
+	}
+
+	data, err := yaml.Marshal(&metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "metadata.yaml"), data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pack the image
+	tarfile, err := ioutil.TempFile(shared.VarPath("images"), "lxd_oci_tar_")
+	if err != nil {
+		return nil, err
+	}
+	tarfile.Close()
+	defer os.Remove(tarfile.Name())
+
+	_, err = shared.RunCommand("tar", "-C", tmpDir, "--numeric-owner", "--xattrs", "-cf", tarfile.Name(), "metadata.yaml", "rootfs")
+	if err != nil {
+		return nil, err
+	}
+
+	compress, err := cluster.ConfigGetString(d.cluster, "images.compression_algorithm")
+	if err != nil {
+		return nil, err
+	}
+
+	compressedPath := tarfile.Name()
+	if compress != "none" {
+		compressedPath, err = compressFile(tarfile.Name(), compress)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(compressedPath)
+	}
+
+	err = shared.FileMove(compressedPath, destName)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(destName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like for other images, the fingerprint is the hash of the file
+	f, err := os.Open(destName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return nil, err
+	}
+
+	fp := fmt.Sprintf("%x", hash.Sum(nil))
+
+	info := &api.Image{}
+	info.Fingerprint = fp
+	info.Size = fi.Size()
+	info.Architecture = archName
+	info.CreatedAt = created
+	info.Properties = properties
+
+	return info, nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -49,3 +50,16 @@ func (suite *daemonImagesTestSuite) TestUseCachedImagesIfAvailable() {
 func TestDaemonImagesTestSuite(t *testing.T) {
 	suite.Run(t, new(daemonImagesTestSuite))
 }
+
+// Servers are rewritten according to the longest matching mirror rule.
+func TestImageMirror(t *testing.T) {
+	mirrors := map[string]string{
+		"https://cloud-images.ubuntu.com":          "https://mirror/ubuntu",
+		"https://cloud-images.ubuntu.com/releases": "https://mirror/ubuntu-releases",
+	}
+
+	assert.Equal(t, "https://mirror/ubuntu-releases", imageMirror(mirrors, "https://cloud-images.ubuntu.com/releases"))
+	assert.Equal(t, "https://mirror/ubuntu/daily", imageMirror(mirrors, "https://cloud-images.ubuntu.com/daily"))
+	assert.Equal(t, "https://cloud-images.ubuntu.com.example", imageMirror(mirrors, "https://cloud-images.ubuntu.com.example"))
+	assert.Equal(t, "https://images.linuxcontainers.org", imageMirror(mirrors, "https://images.linuxcontainers.org"))
+}
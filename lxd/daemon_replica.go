@@ -0,0 +1,167 @@
+package main
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+func replicaTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		replicaRefresh(d)
+	}
+
+	schedule := func() (time.Duration, error) {
+		var interval int64
+		err := d.db.Transaction(func(tx *db.NodeTx) error {
+			config, err := node.ConfigLoad(tx)
+			if err != nil {
+				return err
+			}
+
+			interval = config.ReplicaInterval()
+			return nil
+		})
+		if err != nil {
+			logger.Error("Unable to fetch node configuration", log.Ctx{"err": err})
+			return time.Minute, nil
+		}
+
+		// A zero interval disables the task
+		return time.Duration(interval) * time.Second, nil
+	}
+
+	return f, schedule
+}
+
+// The 1.0 list endpoints served from the replica. They're only used for
+// monitoring and don't need to reflect a change made just before, unlike the
+// endpoints of individual objects, which are always served by the database
+// nodes so that clients see their own changes.
+var replicaEndpoints = []string{
+	"cluster/members",
+	"containers",
+	"images",
+	"images/aliases",
+	"networks",
+	"profiles",
+	"storage-pools",
+	"storage-pools/{name}/volumes",
+}
+
+// A replica of the global database, closed once replaced and no longer used
+// by any request.
+type daemonReplica struct {
+	cluster *db.Cluster
+	refs    int
+	closing bool
+}
+
+// Refresh the local replica of the global database. Database nodes don't
+// keep any, since they hold the database themselves. Nodes whose replica
+// wasn't used since the last refresh drop it rather than fetching a new copy
+// of the database, until it's needed again.
+func replicaRefresh(d *Daemon) {
+	if d.gateway.IsDatabaseNode() {
+		d.replicaSet(nil)
+		return
+	}
+
+	d.replicaMu.Lock()
+	used := d.replicaUsed
+	d.replicaUsed = false
+	d.replicaMu.Unlock()
+
+	if !used {
+		d.replicaSet(nil)
+		return
+	}
+
+	replica, err := d.cluster.Replica()
+	if err != nil {
+		logger.Warn("Failed to refresh the replica of the global database", log.Ctx{"err": err})
+		return
+	}
+
+	d.replicaSet(replica)
+}
+
+// Replace the local replica of the global database. The previous one is
+// closed once the requests using it are done.
+func (d *Daemon) replicaSet(replica *db.Cluster) {
+	d.replicaMu.Lock()
+	defer d.replicaMu.Unlock()
+
+	previous := d.replica
+	d.replica = nil
+	if replica != nil {
+		d.replica = &daemonReplica{cluster: replica}
+	}
+
+	if previous != nil {
+		previous.closing = true
+		if previous.refs == 0 {
+			previous.cluster.Close()
+		}
+	}
+}
+
+// Return the daemon to serve a GET request on the given 1.0 endpoint with,
+// along with a function to call once the request is done. For the endpoints
+// listed in replicaEndpoints, it queries the local replica of the global
+// database instead of the database nodes if there's one. The returned daemon
+// doesn't run any background task.
+func (d *Daemon) readOnly(name string) (*Daemon, func()) {
+	if !shared.StringInSlice(name, replicaEndpoints) {
+		return d, func() {}
+	}
+
+	d.replicaMu.Lock()
+	d.replicaUsed = true
+	replica := d.replica
+	if replica != nil {
+		replica.refs++
+	}
+	d.replicaMu.Unlock()
+
+	if replica == nil {
+		return d, func() {}
+	}
+
+	release := func() {
+		d.replicaMu.Lock()
+		defer d.replicaMu.Unlock()
+
+		replica.refs--
+		if replica.refs == 0 && replica.closing {
+			replica.cluster.Close()
+		}
+	}
+
+	rd := &Daemon{
+		clientCerts:  d.clientCerts,
+		os:           d.os,
+		db:           d.db,
+		maas:         d.maas,
+		bgp:          d.bgp,
+		cluster:      replica.cluster,
+		setupChan:    d.setupChan,
+		readyChan:    d.readyChan,
+		shutdownChan: d.shutdownChan,
+		config:       d.config,
+		endpoints:    d.endpoints,
+		gateway:      d.gateway,
+		proxy:        d.proxy,
+		externalAuth: d.externalAuth,
+	}
+
+	return rd, release
+}
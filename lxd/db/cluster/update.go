@@ -30,14 +30,115 @@ func SchemaDotGo() error {
 var SchemaVersion = len(updates)
 
 var updates = map[int]schema.Update{
-	1: updateFromV0,
-	2: updateFromV1,
-	3: updateFromV2,
-	4: updateFromV3,
-	5: updateFromV4,
-	6: updateFromV5,
-	7: updateFromV6,
-	8: updateFromV7,
+	1:  updateFromV0,
+	2:  updateFromV1,
+	3:  updateFromV2,
+	4:  updateFromV3,
+	5:  updateFromV4,
+	6:  updateFromV5,
+	7:  updateFromV6,
+	8:  updateFromV7,
+	9:  updateFromV8,
+	10: updateFromV9,
+	11: updateFromV10,
+	12: updateFromV11,
+	13: updateFromV12,
+	14: updateFromV13,
+}
+
+// Track the warnings raised by each node, such as expiring certificates.
+func updateFromV13(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE warnings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    node_id INTEGER NOT NULL,
+    uuid TEXT NOT NULL,
+    type TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    status TEXT NOT NULL,
+    count INTEGER NOT NULL,
+    first_seen_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    last_message TEXT NOT NULL,
+    UNIQUE (uuid),
+    UNIQUE (node_id, type, entity),
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Persist the idmap range allocated to each isolated container.
+func updateFromV12(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE containers_idmaps (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    container_id INTEGER NOT NULL,
+    base INTEGER NOT NULL,
+    size INTEGER NOT NULL,
+    FOREIGN KEY (container_id) REFERENCES containers (id) ON DELETE CASCADE,
+    UNIQUE (container_id)
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Track the operations whose node went offline while they were running.
+func updateFromV11(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE operations ADD COLUMN failed INTEGER NOT NULL DEFAULT 0")
+	return err
+}
+
+func updateFromV10(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE containers_annotations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    container_id INTEGER NOT NULL,
+    value TEXT NOT NULL,
+    UNIQUE (container_id),
+    FOREIGN KEY (container_id) REFERENCES containers (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV9(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE seccomp_policies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    UNIQUE (name)
+);
+CREATE TABLE seccomp_policies_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    seccomp_policy_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT,
+    UNIQUE (seccomp_policy_id, key),
+    FOREIGN KEY (seccomp_policy_id) REFERENCES seccomp_policies (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV8(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE images_retention_policies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    server TEXT NOT NULL,
+    alias TEXT NOT NULL,
+    expiry INTEGER NOT NULL DEFAULT 0,
+    keep INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (server, alias)
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
 }
 
 func updateFromV7(tx *sql.Tx) error {
@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -36,6 +37,10 @@ type ContainerArgs struct {
 	Name         string
 	Profiles     []string
 	Stateful     bool
+
+	// Priorities of the profiles, those not listed default to their
+	// position in Profiles.
+	ProfilePriorities map[string]int
 }
 
 // ContainerBackupArgs is a value object holding all db-related details
@@ -188,6 +193,84 @@ SELECT containers.name, nodes.name
 	return result, nil
 }
 
+// ContainersConfigValue returns a map associating each container which has
+// the given key set in its local configuration to the value of the key.
+func (c *ClusterTx) ContainersConfigValue(key string) (map[string]string, error) {
+	stmt := `
+SELECT containers.name, containers_config.value
+  FROM containers_config JOIN containers ON containers.id = containers_config.container_id
+  WHERE containers.type=? AND containers_config.key=?
+`
+	rows, err := c.tx.Query(stmt, CTypeRegular, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]string{}
+
+	for rows.Next() {
+		var name string
+		var value string
+		err := rows.Scan(&name, &value)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ContainersExpandedConfigValue returns a map associating each container
+// which has the given key set, either in its local configuration or through
+// its profiles, to the value of the key once the profiles are applied.
+func (c *ClusterTx) ContainersExpandedConfigValue(key string) (map[string]string, error) {
+	stmt := `
+SELECT containers.name, profiles_config.value
+  FROM containers
+  JOIN containers_profiles ON containers_profiles.container_id = containers.id
+  JOIN profiles_config ON profiles_config.profile_id = containers_profiles.profile_id
+  WHERE containers.type=? AND profiles_config.key=?
+  ORDER BY containers_profiles.apply_order
+`
+	type valueRow struct {
+		name  string
+		value string
+	}
+
+	rows := []valueRow{}
+	err := query.SelectObjects(c.tx, func(i int) []interface{} {
+		rows = append(rows, valueRow{})
+		row := &rows[i]
+		return []interface{}{&row.name, &row.value}
+	}, stmt, CTypeRegular, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Profiles applied last win, and the local configuration wins over them
+	result := map[string]string{}
+	for _, row := range rows {
+		result[row.name] = row.value
+	}
+
+	local, err := c.ContainersConfigValue(key)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range local {
+		result[name] = value
+	}
+
+	return result, nil
+}
+
 // ContainerID returns the ID of the container with the given name.
 func (c *ClusterTx) ContainerID(name string) (int64, error) {
 	stmt := "SELECT id FROM containers WHERE name=?"
@@ -205,6 +288,199 @@ func (c *ClusterTx) ContainerID(name string) (int64, error) {
 	}
 }
 
+// ContainerAnnotationsGet returns the annotations of the container with the
+// given name, as a raw JSON document. An empty string is returned if none
+// were set.
+func (c *ClusterTx) ContainerAnnotationsGet(name string) (string, error) {
+	id, err := c.ContainerID(name)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := query.SelectStrings(c.tx, "SELECT value FROM containers_annotations WHERE container_id=?", id)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch container annotations")
+	}
+
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	return values[0], nil
+}
+
+// ContainerAnnotationsSet replaces the annotations of the container with the
+// given name. An empty value removes them.
+func (c *ClusterTx) ContainerAnnotationsSet(name string, value string) error {
+	id, err := c.ContainerID(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.tx.Exec("DELETE FROM containers_annotations WHERE container_id=?", id)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete container annotations")
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	_, err = c.tx.Exec("INSERT INTO containers_annotations (container_id, value) VALUES (?, ?)", id, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to add container annotations")
+	}
+
+	return nil
+}
+
+// ContainerMetadataCopy copies the state which a container copied over the
+// migration path doesn't carry, that is its creation date, its annotations
+// and the priorities of its profiles, from the container with the given name
+// to the one named to. Idmap allocations are node-local and the copy keeps
+// its own.
+func (c *ClusterTx) ContainerMetadataCopy(from string, to string) error {
+	fromID, err := c.ContainerID(from)
+	if err != nil {
+		return err
+	}
+
+	toID, err := c.ContainerID(to)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+UPDATE containers
+  SET creation_date=(SELECT creation_date FROM containers WHERE id=?)
+  WHERE id=?
+`
+	_, err = c.tx.Exec(stmt, fromID, toID)
+	if err != nil {
+		return errors.Wrap(err, "failed to copy container creation date")
+	}
+
+	_, err = c.tx.Exec("DELETE FROM containers_annotations WHERE container_id=?", toID)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete container annotations")
+	}
+
+	stmt = `
+INSERT INTO containers_annotations (container_id, value)
+  SELECT ?, value FROM containers_annotations WHERE container_id=?
+`
+	_, err = c.tx.Exec(stmt, toID, fromID)
+	if err != nil {
+		return errors.Wrap(err, "failed to copy container annotations")
+	}
+
+	stmt = `
+UPDATE containers_profiles
+  SET apply_order=(
+    SELECT source.apply_order FROM containers_profiles AS source
+      WHERE source.container_id=? AND source.profile_id=containers_profiles.profile_id)
+  WHERE container_id=? AND profile_id IN (
+    SELECT profile_id FROM containers_profiles WHERE container_id=?)
+`
+	_, err = c.tx.Exec(stmt, fromID, toID, fromID)
+	if err != nil {
+		return errors.Wrap(err, "failed to copy container profile priorities")
+	}
+
+	return nil
+}
+
+// ContainerIdmap describes the range of host uids and gids allocated to an
+// isolated container.
+type ContainerIdmap struct {
+	Container string
+	Base      int64
+	Size      int64
+}
+
+// ContainerIdmaps returns the idmap ranges allocated to the containers of
+// the local node, ordered by base.
+func (c *ClusterTx) ContainerIdmaps() ([]ContainerIdmap, error) {
+	allocations := []ContainerIdmap{}
+	dest := func(i int) []interface{} {
+		allocations = append(allocations, ContainerIdmap{})
+		return []interface{}{
+			&allocations[i].Container,
+			&allocations[i].Base,
+			&allocations[i].Size,
+		}
+	}
+	stmt := `
+SELECT containers.name, containers_idmaps.base, containers_idmaps.size
+  FROM containers_idmaps JOIN containers ON containers.id = containers_idmaps.container_id
+  WHERE containers.type=? AND containers.node_id=?
+  ORDER BY containers_idmaps.base, containers.name
+`
+	err := query.SelectObjects(c.tx, dest, stmt, CTypeRegular, c.nodeID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch container idmaps")
+	}
+
+	return allocations, nil
+}
+
+// ContainerIdmapSet records the idmap range allocated to the container with
+// the given name, replacing any previous allocation.
+func (c *ClusterTx) ContainerIdmapSet(name string, base int64, size int64) error {
+	id, err := c.ContainerID(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.tx.Exec("DELETE FROM containers_idmaps WHERE container_id=?", id)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete container idmap")
+	}
+
+	_, err = c.tx.Exec("INSERT INTO containers_idmaps (container_id, base, size) VALUES (?, ?, ?)", id, base, size)
+	if err != nil {
+		return errors.Wrap(err, "failed to add container idmap")
+	}
+
+	return nil
+}
+
+// ContainerIdmapRemove releases the idmap range allocated to the container
+// with the given name, if any.
+func (c *ClusterTx) ContainerIdmapRemove(name string) error {
+	id, err := c.ContainerID(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.tx.Exec("DELETE FROM containers_idmaps WHERE container_id=?", id)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete container idmap")
+	}
+
+	return nil
+}
+
+// ContainerConfigKeySet sets the given config key of the container with the
+// given ID, replacing its previous value. An empty value removes the key.
+func (c *ClusterTx) ContainerConfigKeySet(id int, key string, value string) error {
+	_, err := c.tx.Exec("DELETE FROM containers_config WHERE container_id=? AND key=?", id, key)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete container config key")
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	_, err = c.tx.Exec("INSERT INTO containers_config (container_id, key, value) VALUES (?, ?, ?)", id, key, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to add container config key")
+	}
+
+	return nil
+}
+
 // SnapshotIDsAndNames returns a map of snapshot IDs to snapshot names for the
 // container with the given name.
 func (c *ClusterTx) SnapshotIDsAndNames(name string) (map[int]string, error) {
@@ -447,68 +723,251 @@ SELECT containers.id, containers.description, architecture, type, ephemeral, sta
 	return args, nil
 }
 
-// ContainerCreate creates a new container and returns its ID.
-func (c *Cluster) ContainerCreate(args ContainerArgs) (int, error) {
-	_, err := c.ContainerID(args.Name)
-	if err == nil {
-		return 0, ErrAlreadyDefined
+// ContainerGetAll returns all the containers of the given type, sorted by
+// name, or only the ones of this node if nodeOnly is true. Their config,
+// profiles and devices are fetched with a single query each, instead of a few
+// queries per container as ContainerGet does, which matters when loading
+// many containers at once.
+func (c *ClusterTx) ContainerGetAll(cType ContainerType, nodeOnly bool) ([]ContainerArgs, error) {
+	where := "containers.type=?"
+	args := []interface{}{cType}
+	if nodeOnly {
+		where += " AND containers.node_id=?"
+		args = append(args, c.nodeID)
+	}
+
+	containers := []ContainerArgs{}
+	indexes := map[int]int{} // Index of each container by ID
+
+	stmt := fmt.Sprintf(`
+SELECT containers.id, containers.name, containers.description, architecture, type,
+       ephemeral, stateful, creation_date, last_use_date, nodes.name, nodes.address
+  FROM containers JOIN nodes ON containers.node_id = nodes.id
+  WHERE %s
+  ORDER BY containers.name`, where)
+	var used []*time.Time
+	var addresses []string
+	dest := func(i int) []interface{} {
+		containers = append(containers, ContainerArgs{
+			Config:   map[string]string{},
+			Devices:  types.Devices{},
+			Profiles: []string{},
+		})
+		used = append(used, nil)
+		addresses = append(addresses, "")
+		container := &containers[i]
+		return []interface{}{
+			&container.ID, &container.Name, &container.Description,
+			&container.Architecture, &container.Ctype, &container.Ephemeral,
+			&container.Stateful, &container.CreationDate, &used[i],
+			&container.Node, &addresses[i],
+		}
+	}
+	err := query.SelectObjects(c.tx, dest, stmt, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch containers")
 	}
 
-	var id int
-	err = c.Transaction(func(tx *ClusterTx) error {
-		ephemInt := 0
-		if args.Ephemeral == true {
-			ephemInt = 1
-		}
+	for i := range containers {
+		indexes[containers[i].ID] = i
 
-		statefulInt := 0
-		if args.Stateful == true {
-			statefulInt = 1
+		if used[i] != nil {
+			containers[i].LastUsedDate = *used[i]
+		} else {
+			containers[i].LastUsedDate = time.Unix(0, 0).UTC()
 		}
 
-		if args.CreationDate.IsZero() {
-			args.CreationDate = time.Now().UTC()
+		if addresses[i] == "0.0.0.0" {
+			// This means we're not clustered, so omit the node name
+			containers[i].Node = ""
 		}
+	}
 
-		if args.LastUsedDate.IsZero() {
-			args.LastUsedDate = time.Unix(0, 0).UTC()
-		}
+	// Config
+	stmt = fmt.Sprintf(`
+SELECT containers_config.container_id, containers_config.key, containers_config.value
+  FROM containers_config JOIN containers ON containers_config.container_id = containers.id
+  WHERE %s`, where)
+	rows, err := c.tx.Query(stmt, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch containers config")
+	}
 
-		str := fmt.Sprintf("INSERT INTO containers (node_id, name, architecture, type, ephemeral, creation_date, last_use_date, stateful) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
-		stmt, err := tx.tx.Prepare(str)
+	for rows.Next() {
+		var id int
+		var key string
+		var value sql.NullString
+		err := rows.Scan(&id, &key, &value)
 		if err != nil {
-			return err
+			rows.Close()
+			return nil, err
 		}
-		defer stmt.Close()
-		result, err := stmt.Exec(c.nodeID, args.Name, args.Architecture, args.Ctype, ephemInt, args.CreationDate.Unix(), args.LastUsedDate.Unix(), statefulInt)
+
+		containers[indexes[id]].Config[key] = value.String
+	}
+
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Profiles
+	stmt = fmt.Sprintf(`
+SELECT containers_profiles.container_id, profiles.name
+  FROM containers_profiles
+  JOIN profiles ON containers_profiles.profile_id = profiles.id
+  JOIN containers ON containers_profiles.container_id = containers.id
+  WHERE %s
+  ORDER BY containers_profiles.container_id, containers_profiles.apply_order, containers_profiles.id`, where)
+	rows, err = c.tx.Query(stmt, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch containers profiles")
+	}
+
+	for rows.Next() {
+		var id int
+		var name string
+		err := rows.Scan(&id, &name)
 		if err != nil {
-			return err
+			rows.Close()
+			return nil, err
 		}
 
-		id64, err := result.LastInsertId()
+		container := &containers[indexes[id]]
+		container.Profiles = append(container.Profiles, name)
+	}
+
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Devices, along with their config
+	stmt = fmt.Sprintf(`
+SELECT containers_devices.container_id, containers_devices.name, containers_devices.type,
+       containers_devices_config.key, containers_devices_config.value
+  FROM containers_devices
+  JOIN containers ON containers_devices.container_id = containers.id
+  LEFT JOIN containers_devices_config
+    ON containers_devices_config.container_device_id = containers_devices.id
+  WHERE %s`, where)
+	rows, err = c.tx.Query(stmt, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch containers devices")
+	}
+
+	for rows.Next() {
+		var id, dtype int
+		var name string
+		var key, value sql.NullString
+		err := rows.Scan(&id, &name, &dtype, &key, &value)
 		if err != nil {
-			return fmt.Errorf("Error inserting %s into database", args.Name)
-		}
-		// TODO: is this really int64? we should fix it everywhere if so
-		id = int(id64)
-		if err := ContainerConfigInsert(tx.tx, id, args.Config); err != nil {
-			return err
+			rows.Close()
+			return nil, err
 		}
 
-		if err := ContainerProfilesInsert(tx.tx, id, args.Profiles); err != nil {
-			return err
+		devices := containers[indexes[id]].Devices
+		device, ok := devices[name]
+		if !ok {
+			stype, err := dbDeviceTypeToString(dtype)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			device = types.Device{"type": stype}
+			devices[name] = device
 		}
 
-		if err := DevicesAdd(tx.tx, "container", int64(id), args.Devices); err != nil {
-			return err
+		if key.Valid {
+			device[key.String] = value.String
 		}
+	}
 
-		return nil
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return containers, nil
+}
+
+// ContainerCreate creates a new container and returns its ID.
+func (c *Cluster) ContainerCreate(args ContainerArgs) (int, error) {
+	var id int
+	err := c.Transaction(func(tx *ClusterTx) error {
+		var err error
+		id, err = tx.ContainerCreate(args)
+		return err
 	})
 
 	return id, err
 }
 
+// ContainerCreate creates a new container as part of the transaction and
+// returns its ID.
+func (c *ClusterTx) ContainerCreate(args ContainerArgs) (int, error) {
+	_, err := c.ContainerID(args.Name)
+	if err == nil {
+		return 0, ErrAlreadyDefined
+	}
+	if err != ErrNoSuchObject {
+		return 0, err
+	}
+
+	ephemInt := 0
+	if args.Ephemeral == true {
+		ephemInt = 1
+	}
+
+	statefulInt := 0
+	if args.Stateful == true {
+		statefulInt = 1
+	}
+
+	if args.CreationDate.IsZero() {
+		args.CreationDate = time.Now().UTC()
+	}
+
+	if args.LastUsedDate.IsZero() {
+		args.LastUsedDate = time.Unix(0, 0).UTC()
+	}
+
+	str := fmt.Sprintf("INSERT INTO containers (node_id, name, architecture, type, ephemeral, creation_date, last_use_date, stateful) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	stmt, err := c.tx.Prepare(str)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	result, err := stmt.Exec(c.nodeID, args.Name, args.Architecture, args.Ctype, ephemInt, args.CreationDate.Unix(), args.LastUsedDate.Unix(), statefulInt)
+	if err != nil {
+		return 0, err
+	}
+
+	id64, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("Error inserting %s into database", args.Name)
+	}
+	// TODO: is this really int64? we should fix it everywhere if so
+	id := int(id64)
+	if err := ContainerConfigInsert(c.tx, id, args.Config); err != nil {
+		return 0, err
+	}
+
+	if err := ContainerProfilesInsert(c.tx, id, args.Profiles, args.ProfilePriorities); err != nil {
+		return 0, err
+	}
+
+	if err := DevicesAdd(c.tx, "container", int64(id), args.Devices); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
 // ContainerConfigClear removes any config associated with the container with
 // the given ID.
 func ContainerConfigClear(tx *sql.Tx, id int) error {
@@ -584,9 +1043,9 @@ func (c *Cluster) ContainerSetStateful(id int, stateful bool) error {
 }
 
 // ContainerProfilesInsert associates the container with the given ID with the
-// profiles with the given names.
-func ContainerProfilesInsert(tx *sql.Tx, id int, profiles []string) error {
-	applyOrder := 1
+// profiles with the given names. Profiles are applied by increasing priority,
+// which defaults to their position in the list.
+func ContainerProfilesInsert(tx *sql.Tx, id int, profiles []string, priorities map[string]int) error {
 	str := `INSERT INTO containers_profiles (container_id, profile_id, apply_order) VALUES
 		(?, (SELECT id FROM profiles WHERE name=?), ?);`
 	stmt, err := tx.Prepare(str)
@@ -594,19 +1053,50 @@ func ContainerProfilesInsert(tx *sql.Tx, id int, profiles []string) error {
 		return err
 	}
 	defer stmt.Close()
-	for _, p := range profiles {
-		_, err = stmt.Exec(id, p, applyOrder)
+	for i, p := range profiles {
+		_, err = stmt.Exec(id, p, containerProfilePriority(priorities, p, i))
 		if err != nil {
 			logger.Debugf("Error adding profile %s to container: %s",
 				p, err)
 			return err
 		}
-		applyOrder = applyOrder + 1
 	}
 
 	return nil
 }
 
+// ContainerProfilesSort returns the given profiles in the order they get
+// applied given their priorities, profiles with the same priority keeping
+// their relative position.
+func ContainerProfilesSort(profiles []string, priorities map[string]int) []string {
+	indexes := make([]int, len(profiles))
+	for i := range profiles {
+		indexes[i] = i
+	}
+
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a := containerProfilePriority(priorities, profiles[indexes[i]], indexes[i])
+		b := containerProfilePriority(priorities, profiles[indexes[j]], indexes[j])
+		return a < b
+	})
+
+	sorted := make([]string, len(profiles))
+	for i, index := range indexes {
+		sorted[i] = profiles[index]
+	}
+
+	return sorted
+}
+
+func containerProfilePriority(priorities map[string]int, profile string, index int) int {
+	priority, ok := priorities[profile]
+	if !ok {
+		return index + 1
+	}
+
+	return priority
+}
+
 // ContainerProfiles returns a list of profiles for a given container ID.
 func (c *Cluster) ContainerProfiles(id int) ([]string, error) {
 	var name string
@@ -616,7 +1106,7 @@ func (c *Cluster) ContainerProfiles(id int) ([]string, error) {
         SELECT name FROM containers_profiles
         JOIN profiles ON containers_profiles.profile_id=profiles.id
 		WHERE container_id=?
-        ORDER BY containers_profiles.apply_order`
+        ORDER BY containers_profiles.apply_order, containers_profiles.id`
 	inargs := []interface{}{id}
 	outfmt := []interface{}{name}
 
@@ -634,6 +1124,32 @@ func (c *Cluster) ContainerProfiles(id int) ([]string, error) {
 	return profiles, nil
 }
 
+// ContainerProfilePriorities returns the priorities of the profiles of the
+// container with the given ID.
+func (c *Cluster) ContainerProfilePriorities(id int) (map[string]int, error) {
+	var name string
+	var priority int
+
+	query := `
+        SELECT name, apply_order FROM containers_profiles
+        JOIN profiles ON containers_profiles.profile_id=profiles.id
+		WHERE container_id=?`
+	inargs := []interface{}{id}
+	outfmt := []interface{}{name, priority}
+
+	results, err := queryScan(c.db, query, inargs, outfmt)
+	if err != nil {
+		return nil, err
+	}
+
+	priorities := map[string]int{}
+	for _, r := range results {
+		priorities[r[0].(string)] = r[1].(int)
+	}
+
+	return priorities, nil
+}
+
 // ContainerConfig gets the container configuration map from the DB
 func (c *Cluster) ContainerConfig(id int) (map[string]string, error) {
 	var key, value string
@@ -1,6 +1,7 @@
 package db_test
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -63,6 +64,58 @@ func TestContainersByNodeName(t *testing.T) {
 		}, result)
 }
 
+// Only the containers with the given key set are returned, along with its
+// value.
+func TestContainersConfigValue(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, 1, "c2")
+	addContainer(t, tx, 1, "c3")
+
+	_, err := tx.Tx().Exec(`
+INSERT INTO containers_config (container_id, key, value)
+  SELECT id, 'placement.group', 'web' FROM containers WHERE name IN ('c1', 'c3')
+`)
+	require.NoError(t, err)
+
+	result, err := tx.ContainersConfigValue("placement.group")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"c1": "web", "c3": "web"}, result)
+}
+
+// Values set through profiles are returned too, the profiles applied last and
+// the local configuration taking precedence.
+func TestContainersExpandedConfigValue(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, 1, "c2")
+	addContainer(t, tx, 1, "c3")
+	addContainer(t, tx, 1, "c4")
+
+	_, err := tx.Tx().Exec(`
+INSERT INTO profiles (name, description) VALUES ('web', ''), ('db', '');
+INSERT INTO profiles_config (profile_id, key, value)
+  SELECT id, 'placement.group', name FROM profiles WHERE name IN ('web', 'db');
+INSERT INTO containers_profiles (container_id, profile_id, apply_order)
+  SELECT containers.id, profiles.id, 1 FROM containers, profiles
+   WHERE containers.name IN ('c1', 'c2') AND profiles.name = 'web';
+INSERT INTO containers_profiles (container_id, profile_id, apply_order)
+  SELECT containers.id, profiles.id, 2 FROM containers, profiles
+   WHERE containers.name = 'c2' AND profiles.name = 'db';
+INSERT INTO containers_config (container_id, key, value)
+  SELECT id, 'placement.group', 'cache' FROM containers WHERE name IN ('c1', 'c3');
+`)
+	require.NoError(t, err)
+
+	result, err := tx.ContainersExpandedConfigValue("placement.group")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"c1": "cache", "c2": "db", "c3": "cache"}, result)
+}
+
 func TestContainerPool(t *testing.T) {
 	cluster, cleanup := db.NewTestCluster(t)
 	defer cleanup()
@@ -113,6 +166,61 @@ func TestContainersNodeList(t *testing.T) {
 	assert.Equal(t, names, []string{"c1"})
 }
 
+// All containers are loaded at once with the same details as ContainerGet
+// returns, optionally only the ones of the local node.
+func TestContainerGetAll(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	_, err := cluster.ProfileCreate("p1", "", nil, nil)
+	require.NoError(t, err)
+
+	args := db.ContainerArgs{
+		Name:     "c1",
+		Config:   map[string]string{"limits.cpu": "2"},
+		Profiles: []string{"p1"},
+		Devices: types.Devices{
+			"root": types.Device{"path": "/", "pool": "default", "type": "disk"},
+			"eth0": types.Device{"nictype": "bridged", "parent": "lxdbr0", "type": "nic"},
+		},
+	}
+	_, err = cluster.ContainerCreate(args)
+	require.NoError(t, err)
+
+	_, err = cluster.ContainerCreate(db.ContainerArgs{Name: "c0"})
+	require.NoError(t, err)
+
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		nodeID, err := tx.NodeAdd("node2", "1.2.3.4:666")
+		require.NoError(t, err)
+		addContainer(t, tx, nodeID, "c2")
+		return nil
+	})
+	require.NoError(t, err)
+
+	var all, local []db.ContainerArgs
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		all, err = tx.ContainerGetAll(db.CTypeRegular, false)
+		require.NoError(t, err)
+		local, err = tx.ContainerGetAll(db.CTypeRegular, true)
+		require.NoError(t, err)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, all, 3)
+	assert.Equal(t, "c2", all[2].Name)
+	assert.Equal(t, "node2", all[2].Node)
+
+	require.Len(t, local, 2)
+	assert.Equal(t, "c0", local[0].Name)
+
+	expected, err := cluster.ContainerGet("c1")
+	require.NoError(t, err)
+	assert.Equal(t, expected, local[1])
+}
+
 func addContainer(t *testing.T, tx *db.ClusterTx, nodeID int64, name string) {
 	stmt := `
 INSERT INTO containers(node_id, name, architecture, type) VALUES (?, ?, 1, ?)
@@ -120,3 +228,185 @@ INSERT INTO containers(node_id, name, architecture, type) VALUES (?, ?, 1, ?)
 	_, err := tx.Tx().Exec(stmt, nodeID, name, db.CTypeRegular)
 	require.NoError(t, err)
 }
+
+// A container created as part of a transaction is gone if the transaction is
+// rolled back, and can't be created twice.
+func TestContainerCreate_Transaction(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.ContainerCreate(db.ContainerArgs{Name: "c1"})
+		require.NoError(t, err)
+		return fmt.Errorf("boom")
+	})
+	require.EqualError(t, err, "boom")
+
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.ContainerID("c1")
+		return err
+	})
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	_, err = cluster.ContainerCreate(db.ContainerArgs{Name: "c1"})
+	require.NoError(t, err)
+
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.ContainerCreate(db.ContainerArgs{Name: "c1"})
+		return err
+	})
+	assert.Equal(t, db.ErrAlreadyDefined, err)
+}
+
+// Profiles are sorted by priority, the ones without an explicit priority
+// defaulting to their position and ties keeping the list order.
+func TestContainerProfilesSort(t *testing.T) {
+	profiles := []string{"default", "nesting", "gpu", "net"}
+	priorities := map[string]int{"default": 10, "gpu": 2}
+
+	sorted := db.ContainerProfilesSort(profiles, priorities)
+	assert.Equal(t, []string{"nesting", "gpu", "net", "default"}, sorted)
+
+	sorted = db.ContainerProfilesSort(profiles, nil)
+	assert.Equal(t, profiles, sorted)
+}
+
+// A config key can be set, replaced and removed.
+func TestContainerConfigKeySet(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+	id, err := tx.ContainerID("c1")
+	require.NoError(t, err)
+
+	require.NoError(t, tx.ContainerConfigKeySet(int(id), "security.protection.delete", "true"))
+	require.NoError(t, tx.ContainerConfigKeySet(int(id), "security.protection.delete", "false"))
+
+	values, err := tx.ContainersConfigValue("security.protection.delete")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"c1": "false"}, values)
+
+	require.NoError(t, tx.ContainerConfigKeySet(int(id), "security.protection.delete", ""))
+
+	values, err = tx.ContainersConfigValue("security.protection.delete")
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+// Annotations can be set, replaced and removed.
+func TestContainerAnnotations(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+
+	value, err := tx.ContainerAnnotationsGet("c1")
+	require.NoError(t, err)
+	assert.Equal(t, "", value)
+
+	require.NoError(t, tx.ContainerAnnotationsSet("c1", `{"owner": "ci"}`))
+	require.NoError(t, tx.ContainerAnnotationsSet("c1", `{"owner": "cd"}`))
+
+	value, err = tx.ContainerAnnotationsGet("c1")
+	require.NoError(t, err)
+	assert.Equal(t, `{"owner": "cd"}`, value)
+
+	require.NoError(t, tx.ContainerAnnotationsSet("c1", ""))
+
+	value, err = tx.ContainerAnnotationsGet("c1")
+	require.NoError(t, err)
+	assert.Equal(t, "", value)
+
+	_, err = tx.ContainerAnnotationsGet("c2")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
+
+// The creation date, annotations and profile priorities of a container can
+// be copied over to another one, which keeps its own idmap allocation.
+func TestContainerMetadataCopy(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	_, err := cluster.ProfileCreate("p1", "", nil, nil)
+	require.NoError(t, err)
+	_, err = cluster.ProfileCreate("p2", "", nil, nil)
+	require.NoError(t, err)
+
+	created := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	_, err = cluster.ContainerCreate(db.ContainerArgs{
+		Name:              "c1",
+		CreationDate:      created,
+		Profiles:          []string{"p1", "p2"},
+		ProfilePriorities: map[string]int{"p1": 5, "p2": 1},
+	})
+	require.NoError(t, err)
+
+	id, err := cluster.ContainerCreate(db.ContainerArgs{
+		Name:         "c2",
+		CreationDate: time.Now().UTC(),
+		Profiles:     []string{"p1", "p2"},
+	})
+	require.NoError(t, err)
+
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		require.NoError(t, tx.ContainerAnnotationsSet("c1", `{"owner": "ci"}`))
+		require.NoError(t, tx.ContainerIdmapSet("c1", 165536, 65536))
+		require.NoError(t, tx.ContainerIdmapSet("c2", 231072, 65536))
+		return tx.ContainerMetadataCopy("c1", "c2")
+	})
+	require.NoError(t, err)
+
+	args, err := cluster.ContainerGet("c2")
+	require.NoError(t, err)
+	assert.True(t, created.Equal(args.CreationDate))
+
+	priorities, err := cluster.ContainerProfilePriorities(id)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"p1": 5, "p2": 1}, priorities)
+
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		value, err := tx.ContainerAnnotationsGet("c2")
+		require.NoError(t, err)
+		assert.Equal(t, `{"owner": "ci"}`, value)
+
+		allocations, err := tx.ContainerIdmaps()
+		require.NoError(t, err)
+		assert.Contains(t, allocations, db.ContainerIdmap{Container: "c2", Base: 231072, Size: 65536})
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// Idmap allocations are listed by base for the local node only.
+func TestContainerIdmaps(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	nodeID2, err := tx.NodeAdd("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, 1, "c2")
+	addContainer(t, tx, nodeID2, "c3")
+
+	require.NoError(t, tx.ContainerIdmapSet("c1", 300000, 65536))
+	require.NoError(t, tx.ContainerIdmapSet("c2", 165536, 65536))
+	require.NoError(t, tx.ContainerIdmapSet("c3", 165536, 65536))
+
+	allocations, err := tx.ContainerIdmaps()
+	require.NoError(t, err)
+	assert.Equal(t, []db.ContainerIdmap{
+		{Container: "c2", Base: 165536, Size: 65536},
+		{Container: "c1", Base: 300000, Size: 65536},
+	}, allocations)
+
+	require.NoError(t, tx.ContainerIdmapSet("c1", 231072, 65536))
+	require.NoError(t, tx.ContainerIdmapRemove("c2"))
+
+	allocations, err = tx.ContainerIdmaps()
+	require.NoError(t, err)
+	assert.Equal(t, []db.ContainerIdmap{
+		{Container: "c1", Base: 231072, Size: 65536},
+	}, allocations)
+}
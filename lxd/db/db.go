@@ -336,6 +336,79 @@ func (c *Cluster) Begin() (*sql.Tx, error) {
 	return begin(c.db)
 }
 
+// Backup returns a SQL text dump of the whole cluster database. The dump is
+// taken within a single transaction, so it's consistent across all nodes.
+func (c *Cluster) Backup() (string, error) {
+	var dump string
+	err := c.Transaction(func(tx *ClusterTx) error {
+		var err error
+		dump, err = query.Dump(tx.tx, cluster.FreshSchema(), false /* schemaOnly */)
+		return err
+	})
+	return dump, err
+}
+
+// Replica returns a read-only copy of the cluster database as of now, held in
+// memory. It can be used in place of the cluster database to serve read
+// requests without querying the database nodes.
+func (c *Cluster) Replica() (*Cluster, error) {
+	dump, err := c.Backup()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3_with_fk", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+
+	// The in-memory database lives as long as its only connection.
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	_, err = db.Exec(dump)
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "failed to load the replica")
+	}
+
+	_, err = db.Exec("PRAGMA query_only=ON")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Cluster{db: db, nodeID: c.nodeID}, nil
+}
+
+// Restore replaces the whole content of the cluster database with the given
+// SQL text dump, as returned by Backup. The dump must have been taken at the
+// current schema version, otherwise nothing gets changed.
+func (c *Cluster) Restore(dump string) error {
+	err := c.EnterExclusive()
+	if err != nil {
+		return errors.Wrap(err, "failed to acquire cluster database lock")
+	}
+
+	return c.ExitExclusive(func(tx *ClusterTx) error {
+		err := query.Restore(tx.tx, dump)
+		if err != nil {
+			return err
+		}
+
+		versions, err := query.SelectIntegers(tx.tx, "SELECT version FROM schema ORDER BY version")
+		if err != nil {
+			return errors.Wrap(err, "failed to fetch restored schema version")
+		}
+
+		if len(versions) == 0 || versions[len(versions)-1] != cluster.SchemaVersion {
+			return fmt.Errorf("dump doesn't match the current schema version %d", cluster.SchemaVersion)
+		}
+
+		return nil
+	})
+}
+
 // UpdateSchemasDotGo updates the schema.go files in the local/ and cluster/
 // sub-packages.
 func UpdateSchemasDotGo() error {
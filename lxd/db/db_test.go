@@ -1,6 +1,7 @@
 package db_test
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/lxc/lxd/lxd/db"
@@ -45,3 +46,55 @@ func TestCluster_Setup(t *testing.T) {
 	assert.NoError(t, tx.Commit())
 	assert.NoError(t, db.Close())
 }
+
+// A backup of the cluster database can be restored after changes, as long as
+// its schema version matches.
+func TestCluster_BackupRestore(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	_, err := cluster.ProfileCreate("p1", "", nil, nil)
+	require.NoError(t, err)
+
+	dump, err := cluster.Backup()
+	require.NoError(t, err)
+
+	_, err = cluster.ProfileCreate("p2", "", nil, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, cluster.Restore(dump))
+
+	profiles, err := cluster.Profiles()
+	require.NoError(t, err)
+	assert.Contains(t, profiles, "p1")
+	assert.NotContains(t, profiles, "p2")
+
+	schema := regexp.MustCompile(`INSERT INTO schema VALUES\(1,\d+,`)
+	dump = schema.ReplaceAllString(dump, "INSERT INTO schema VALUES(1,1,")
+	assert.Error(t, cluster.Restore(dump))
+}
+
+// A replica of the cluster database holds its content at the time it was
+// made, and can't be written to.
+func TestCluster_Replica(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	_, err := cluster.ProfileCreate("p1", "", nil, nil)
+	require.NoError(t, err)
+
+	replica, err := cluster.Replica()
+	require.NoError(t, err)
+	defer replica.Close()
+
+	_, err = cluster.ProfileCreate("p2", "", nil, nil)
+	require.NoError(t, err)
+
+	profiles, err := replica.Profiles()
+	require.NoError(t, err)
+	assert.Contains(t, profiles, "p1")
+	assert.NotContains(t, profiles, "p2")
+
+	_, err = replica.ProfileCreate("p3", "", nil, nil)
+	assert.Error(t, err)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/osarch"
+	"github.com/pkg/errors"
 )
 
 // ImageSourceProtocol maps image source protocol codes to human-readable
@@ -16,6 +17,7 @@ var ImageSourceProtocol = map[int]string{
 	0: "lxd",
 	1: "direct",
 	2: "simplestreams",
+	3: "oci",
 }
 
 // ImagesGet returns the names of all images (optionally only the public ones).
@@ -41,48 +43,6 @@ func (c *Cluster) ImagesGet(public bool) ([]string, error) {
 	return results, nil
 }
 
-// ImagesGetExpired returns the names of all images that have expired since the
-// given time.
-func (c *Cluster) ImagesGetExpired(expiry int64) ([]string, error) {
-	q := `SELECT fingerprint, last_use_date, upload_date FROM images WHERE cached=1`
-
-	var fpStr string
-	var useStr string
-	var uploadStr string
-
-	inargs := []interface{}{}
-	outfmt := []interface{}{fpStr, useStr, uploadStr}
-	dbResults, err := queryScan(c.db, q, inargs, outfmt)
-	if err != nil {
-		return []string{}, err
-	}
-
-	results := []string{}
-	for _, r := range dbResults {
-		// Figure out the expiry
-		timestamp := r[2]
-		if r[1] != "" {
-			timestamp = r[1]
-		}
-
-		var imageExpiry time.Time
-		err = imageExpiry.UnmarshalText([]byte(timestamp.(string)))
-		if err != nil {
-			return []string{}, err
-		}
-		imageExpiry = imageExpiry.Add(time.Duration(expiry*24) * time.Hour)
-
-		// Check if expired
-		if imageExpiry.After(time.Now()) {
-			continue
-		}
-
-		results = append(results, r[0].(string))
-	}
-
-	return results, nil
-}
-
 // ImageSourceInsert inserts a new image source.
 func (c *Cluster) ImageSourceInsert(id int, server string, protocol string, certificate string, alias string) error {
 	stmt := `INSERT INTO images_source (image_id, server, protocol, certificate, alias) values (?, ?, ?, ?, ?)`
@@ -170,6 +130,32 @@ func (c *Cluster) ImageSourceGetCachedFingerprint(server string, protocol string
 	return fingerprint, nil
 }
 
+// ImageGetFromProperty returns the fingerprint of the most recent image with
+// the given property set to the given value.
+func (c *Cluster) ImageGetFromProperty(key string, value string) (string, error) {
+	q := `SELECT images.fingerprint
+			FROM images_properties
+			INNER JOIN images
+			ON images_properties.image_id=images.id
+			WHERE images_properties.key=? AND images_properties.value=?
+			ORDER BY images.creation_date DESC`
+
+	fingerprint := ""
+
+	arg1 := []interface{}{key, value}
+	arg2 := []interface{}{&fingerprint}
+	err := dbQueryRowScan(c.db, q, arg1, arg2)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNoSuchObject
+		}
+
+		return "", err
+	}
+
+	return fingerprint, nil
+}
+
 // ImageExists returns whether an image with the given fingerprint exists.
 func (c *Cluster) ImageExists(fingerprint string) (bool, error) {
 	var exists bool
@@ -652,3 +638,205 @@ func (c *Cluster) ImageUploadedAt(id int, uploadedAt time.Time) error {
 	err := exec(c.db, "UPDATE images SET upload_date=? WHERE id=?", uploadedAt, id)
 	return err
 }
+
+// ImageCached holds information about a cached image and the remote source
+// it was downloaded from.
+type ImageCached struct {
+	Fingerprint string
+	Server      string
+	Alias       string
+	UploadedAt  time.Time
+	LastUsedAt  time.Time
+}
+
+// ImagesCached returns all cached images along with their remote source.
+//
+// Cached images without a source entry are returned with an empty server and
+// alias.
+func (c *ClusterTx) ImagesCached() ([]ImageCached, error) {
+	images := []ImageCached{}
+	uploaded := []*time.Time{}
+	used := []*time.Time{}
+	dest := func(i int) []interface{} {
+		images = append(images, ImageCached{})
+		uploaded = append(uploaded, nil)
+		used = append(used, nil)
+		return []interface{}{
+			&images[i].Fingerprint,
+			&images[i].Server,
+			&images[i].Alias,
+			&uploaded[i],
+			&used[i],
+		}
+	}
+
+	stmt := `
+SELECT images.fingerprint, COALESCE(images_source.server, ''), COALESCE(images_source.alias, ''),
+       images.upload_date, images.last_use_date
+  FROM images LEFT JOIN images_source ON images_source.image_id = images.id
+  WHERE images.cached = 1
+  ORDER BY images.upload_date DESC`
+	err := query.SelectObjects(c.tx, dest, stmt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch cached images")
+	}
+
+	for i := range images {
+		if uploaded[i] != nil {
+			images[i].UploadedAt = *uploaded[i]
+		}
+
+		if used[i] != nil {
+			images[i].LastUsedAt = *used[i]
+		}
+	}
+
+	return images, nil
+}
+
+// ImagesPublic returns all public images, along with their properties and
+// aliases. All of them are fetched with a fixed number of queries, rather
+// than a few per image like with ImageGet.
+func (c *ClusterTx) ImagesPublic() ([]api.Image, error) {
+	images := []api.Image{}
+	ids := []int{}
+	archs := []int{}
+	created := []*time.Time{}
+	uploaded := []*time.Time{}
+	dest := func(i int) []interface{} {
+		images = append(images, api.Image{})
+		ids = append(ids, -1)
+		archs = append(archs, -1)
+		created = append(created, nil)
+		uploaded = append(uploaded, nil)
+		return []interface{}{
+			&ids[i],
+			&images[i].Fingerprint,
+			&images[i].Filename,
+			&images[i].Size,
+			&archs[i],
+			&created[i],
+			&uploaded[i],
+		}
+	}
+
+	stmt := `
+SELECT id, fingerprint, filename, size, architecture, creation_date, upload_date
+  FROM images
+  WHERE public = 1
+  ORDER BY fingerprint`
+	err := query.SelectObjects(c.tx, dest, stmt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch public images")
+	}
+
+	index := map[int]int{}
+	for i := range images {
+		index[ids[i]] = i
+		images[i].Public = true
+		images[i].Architecture, _ = osarch.ArchitectureName(archs[i])
+		images[i].Properties = map[string]string{}
+		images[i].Aliases = []api.ImageAlias{}
+		if created[i] != nil {
+			images[i].CreatedAt = *created[i]
+		}
+
+		if uploaded[i] != nil {
+			images[i].UploadedAt = *uploaded[i]
+		}
+	}
+
+	type row struct {
+		id    int
+		key   string
+		value string
+	}
+
+	properties := []row{}
+	dest = func(i int) []interface{} {
+		properties = append(properties, row{})
+		return []interface{}{&properties[i].id, &properties[i].key, &properties[i].value}
+	}
+
+	stmt = `
+SELECT images.id, images_properties.key, COALESCE(images_properties.value, '')
+  FROM images_properties JOIN images ON images_properties.image_id = images.id
+  WHERE images.public = 1`
+	err = query.SelectObjects(c.tx, dest, stmt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch public images properties")
+	}
+
+	for _, property := range properties {
+		i, ok := index[property.id]
+		if ok {
+			images[i].Properties[property.key] = property.value
+		}
+	}
+
+	aliases := []row{}
+	dest = func(i int) []interface{} {
+		aliases = append(aliases, row{})
+		return []interface{}{&aliases[i].id, &aliases[i].key, &aliases[i].value}
+	}
+
+	stmt = `
+SELECT images.id, images_aliases.name, COALESCE(images_aliases.description, '')
+  FROM images_aliases JOIN images ON images_aliases.image_id = images.id
+  WHERE images.public = 1
+  ORDER BY images_aliases.name`
+	err = query.SelectObjects(c.tx, dest, stmt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch public images aliases")
+	}
+
+	for _, alias := range aliases {
+		i, ok := index[alias.id]
+		if ok {
+			images[i].Aliases = append(images[i].Aliases, api.ImageAlias{Name: alias.key, Description: alias.value})
+		}
+	}
+
+	return images, nil
+}
+
+// ImageRetentionPolicies returns all configured image retention policies.
+func (c *ClusterTx) ImageRetentionPolicies() ([]api.ImageRetentionPolicy, error) {
+	policies := []api.ImageRetentionPolicy{}
+	dest := func(i int) []interface{} {
+		policies = append(policies, api.ImageRetentionPolicy{})
+		return []interface{}{
+			&policies[i].Server,
+			&policies[i].Alias,
+			&policies[i].Expiry,
+			&policies[i].Keep,
+		}
+	}
+
+	stmt := "SELECT server, alias, expiry, keep FROM images_retention_policies ORDER BY server, alias"
+	err := query.SelectObjects(c.tx, dest, stmt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch image retention policies")
+	}
+
+	return policies, nil
+}
+
+// ImageRetentionPoliciesReplace replaces all image retention policies with
+// the given ones.
+func (c *ClusterTx) ImageRetentionPoliciesReplace(policies []api.ImageRetentionPolicy) error {
+	_, err := c.tx.Exec("DELETE FROM images_retention_policies")
+	if err != nil {
+		return err
+	}
+
+	stmt := "INSERT INTO images_retention_policies (server, alias, expiry, keep) VALUES (?, ?, ?, ?)"
+	for _, policy := range policies {
+		_, err := c.tx.Exec(stmt, policy.Server, policy.Alias, policy.Expiry, policy.Keep)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add retention policy for '%s'", policy.Alias)
+		}
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -37,3 +38,110 @@ func TestImageLocate(t *testing.T) {
 	require.Equal(t, "", address)
 	require.EqualError(t, err, "image not available on any online node")
 }
+
+// Cached images are returned along with the remote they were downloaded from.
+func TestImagesCached(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	err := cluster.ImageInsert(
+		"abc", "x.gz", 16, false, false, "amd64", time.Now(), time.Now(), map[string]string{})
+	require.NoError(t, err)
+
+	err = cluster.ImageInsert(
+		"def", "y.gz", 16, false, false, "amd64", time.Now(), time.Now(), map[string]string{})
+	require.NoError(t, err)
+
+	id, _, err := cluster.ImageGet("abc", false, true)
+	require.NoError(t, err)
+
+	err = cluster.ImageSourceInsert(id, "https://images.example.com", "simplestreams", "", "ubuntu/18.04")
+	require.NoError(t, err)
+
+	err = cluster.ImageLastAccessInit("abc")
+	require.NoError(t, err)
+
+	var images []db.ImageCached
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		images, err = tx.ImagesCached()
+		return err
+	})
+	require.NoError(t, err)
+
+	require.Len(t, images, 1)
+	assert.Equal(t, "abc", images[0].Fingerprint)
+	assert.Equal(t, "https://images.example.com", images[0].Server)
+	assert.Equal(t, "ubuntu/18.04", images[0].Alias)
+}
+
+// Public images are returned along with their properties and aliases.
+func TestImagesPublic(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	err := cluster.ImageInsert(
+		"abc", "x.gz", 16, true, false, "amd64", time.Now(), time.Now(), map[string]string{"os": "Ubuntu"})
+	require.NoError(t, err)
+
+	err = cluster.ImageInsert(
+		"def", "y.gz", 16, false, false, "amd64", time.Now(), time.Now(), map[string]string{"os": "Debian"})
+	require.NoError(t, err)
+
+	id, _, err := cluster.ImageGet("abc", false, true)
+	require.NoError(t, err)
+
+	err = cluster.ImageAliasAdd("ubuntu", id, "")
+	require.NoError(t, err)
+
+	var images []api.Image
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		images, err = tx.ImagesPublic()
+		return err
+	})
+	require.NoError(t, err)
+
+	require.Len(t, images, 1)
+	assert.Equal(t, "abc", images[0].Fingerprint)
+	assert.Equal(t, "x86_64", images[0].Architecture)
+	assert.Equal(t, map[string]string{"os": "Ubuntu"}, images[0].Properties)
+	assert.Equal(t, []api.ImageAlias{{Name: "ubuntu"}}, images[0].Aliases)
+	assert.False(t, images[0].UploadedAt.IsZero())
+}
+
+// Images are found from the value of one of their properties.
+func TestImageGetFromProperty(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	err := cluster.ImageInsert(
+		"abc", "x.gz", 16, false, false, "amd64", time.Now(), time.Now(), map[string]string{"oci.digest": "123"})
+	require.NoError(t, err)
+
+	fingerprint, err := cluster.ImageGetFromProperty("oci.digest", "123")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", fingerprint)
+
+	_, err = cluster.ImageGetFromProperty("oci.digest", "456")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
+
+// Image retention policies are replaced as a whole.
+func TestImageRetentionPoliciesReplace(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	policies := []api.ImageRetentionPolicy{
+		{Server: "https://images.example.com", Alias: "ubuntu/18.04", Keep: 2},
+		{Alias: "alpine/edge", Expiry: 3},
+	}
+
+	err := tx.ImageRetentionPoliciesReplace(policies)
+	require.NoError(t, err)
+
+	err = tx.ImageRetentionPoliciesReplace(policies[1:])
+	require.NoError(t, err)
+
+	result, err := tx.ImageRetentionPolicies()
+	require.NoError(t, err)
+	assert.Equal(t, policies[1:], result)
+}
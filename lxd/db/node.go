@@ -394,6 +394,14 @@ func (c *ClusterTx) NodeOfflineThreshold() (time.Duration, error) {
 // NodeWithLeastContainers returns the name of the non-offline node with
 // with the least number of containers.
 func (c *ClusterTx) NodeWithLeastContainers() (string, error) {
+	return c.NodeWithLeastContainersMatching(func(name string) bool { return true })
+}
+
+// NodeWithLeastContainersMatching returns the name of the non-offline node
+// with the least number of containers, out of the nodes whose name is
+// accepted by the given function. An empty string is returned if no node
+// matches.
+func (c *ClusterTx) NodeWithLeastContainersMatching(match func(name string) bool) (string, error) {
 	threshold, err := c.NodeOfflineThreshold()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get offline threshold")
@@ -406,7 +414,7 @@ func (c *ClusterTx) NodeWithLeastContainers() (string, error) {
 	name := ""
 	containers := -1
 	for _, node := range nodes {
-		if node.IsOffline(threshold) {
+		if node.IsOffline(threshold) || !match(node.Name) {
 			continue
 		}
 		count, err := query.Count(c.tx, "containers", "node_id=?", node.ID)
@@ -237,6 +237,30 @@ INSERT INTO containers (id, node_id, name, architecture, type) VALUES (1, 1, 'fo
 	assert.Equal(t, "buzz", name)
 }
 
+// Nodes not accepted by the match function are skipped, even if they have
+// less containers.
+func TestNodeWithLeastContainersMatching(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.NodeAdd("buzz", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	// Add a container to the default node (ID 1)
+	_, err = tx.Tx().Exec(`
+INSERT INTO containers (id, node_id, name, architecture, type) VALUES (1, 1, 'foo', 1, 1)
+`)
+	require.NoError(t, err)
+
+	name, err := tx.NodeWithLeastContainersMatching(func(name string) bool { return name != "buzz" })
+	require.NoError(t, err)
+	assert.Equal(t, "none", name)
+
+	name, err = tx.NodeWithLeastContainersMatching(func(name string) bool { return false })
+	require.NoError(t, err)
+	assert.Equal(t, "", name)
+}
+
 // If there are nodes, and one of them is offline, return the name of the
 // online node, even if the offline one has more containers.
 func TestNodeWithLeastContainers_OfflineNode(t *testing.T) {
@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/pkg/errors"
@@ -13,6 +14,7 @@ type Operation struct {
 	ID          int64  // Stable database identifier
 	UUID        string // User-visible identifier
 	NodeAddress string // Address of the node the operation is running on
+	Failed      bool   // Whether the node went offline while running it
 }
 
 // OperationsUUIDs returns the UUIDs of all operations associated with this
@@ -22,6 +24,56 @@ func (c *ClusterTx) OperationsUUIDs() ([]string, error) {
 	return query.SelectStrings(c.tx, stmt, c.nodeID)
 }
 
+// OperationsFlush removes all operations associated with this node, which can
+// only have been left behind by a previous run of LXD.
+func (c *ClusterTx) OperationsFlush() error {
+	_, err := c.tx.Exec("DELETE FROM operations WHERE node_id=?", c.nodeID)
+	return err
+}
+
+// OperationsOffline returns the operations not marked as failed yet of the
+// nodes which are offline according to the given threshold.
+func (c *ClusterTx) OperationsOffline(threshold time.Duration) ([]Operation, error) {
+	nodes, err := c.Nodes()
+	if err != nil {
+		return nil, err
+	}
+
+	offline := []Operation{}
+	for _, node := range nodes {
+		if node.ID == c.nodeID || !node.IsOffline(threshold) {
+			continue
+		}
+
+		operations, err := c.operations("node_id=? AND failed=0", node.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		offline = append(offline, operations...)
+	}
+
+	return offline, nil
+}
+
+// OperationFail marks as failed the operation with the given UUID.
+func (c *ClusterTx) OperationFail(uuid string) error {
+	result, err := c.tx.Exec("UPDATE operations SET failed=1 WHERE uuid=?", uuid)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
 // OperationByUUID returns the operation with the given UUID.
 func (c *ClusterTx) OperationByUUID(uuid string) (Operation, error) {
 	null := Operation{}
@@ -71,10 +123,11 @@ func (c *ClusterTx) operations(where string, args ...interface{}) ([]Operation,
 			&operations[i].ID,
 			&operations[i].UUID,
 			&operations[i].NodeAddress,
+			&operations[i].Failed,
 		}
 	}
 	stmt := `
-SELECT operations.id, uuid, nodes.address FROM operations JOIN nodes ON nodes.id = node_id `
+SELECT operations.id, uuid, nodes.address, failed FROM operations JOIN nodes ON nodes.id = node_id `
 	if where != "" {
 		stmt += fmt.Sprintf("WHERE %s ", where)
 	}
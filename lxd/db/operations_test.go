@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/stretchr/testify/assert"
@@ -31,3 +32,50 @@ func TestOperation(t *testing.T) {
 	_, err = tx.OperationByUUID("abcd")
 	assert.Equal(t, db.ErrNoSuchObject, err)
 }
+
+// The operations of offline nodes are listed until marked as failed, while
+// those of this node are flushed.
+func TestOperationsOffline(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.OperationAdd("abcd")
+	require.NoError(t, err)
+
+	nodeID, err := tx.NodeAdd("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+	require.NoError(t, tx.NodeHeartbeat("1.2.3.4:666", time.Now().Add(-time.Minute)))
+
+	_, err = tx.Tx().Exec("INSERT INTO operations (uuid, node_id) VALUES ('efgh', ?)", nodeID)
+	require.NoError(t, err)
+
+	offline, err := tx.OperationsOffline(2 * time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, offline, 0)
+
+	offline, err = tx.OperationsOffline(20 * time.Second)
+	require.NoError(t, err)
+	require.Len(t, offline, 1)
+	assert.Equal(t, "efgh", offline[0].UUID)
+	assert.Equal(t, "1.2.3.4:666", offline[0].NodeAddress)
+	assert.False(t, offline[0].Failed)
+
+	require.NoError(t, tx.OperationFail("efgh"))
+	assert.Equal(t, db.ErrNoSuchObject, tx.OperationFail("missing"))
+
+	offline, err = tx.OperationsOffline(20 * time.Second)
+	require.NoError(t, err)
+	assert.Len(t, offline, 0)
+
+	operation, err := tx.OperationByUUID("efgh")
+	require.NoError(t, err)
+	assert.True(t, operation.Failed)
+
+	require.NoError(t, tx.OperationsFlush())
+
+	_, err = tx.OperationByUUID("abcd")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	_, err = tx.OperationByUUID("efgh")
+	assert.NoError(t, err)
+}
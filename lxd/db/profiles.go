@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared/api"
 )
@@ -266,3 +267,102 @@ DELETE FROM profiles_devices_config WHERE profile_device_id NOT IN (SELECT id FR
 
 	return nil
 }
+
+// ProfilesExpandData returns the config and devices of the profiles with the
+// given names, keyed by name. Profiles which don't exist are omitted. All of
+// them are fetched with a fixed number of queries, so that expanding the
+// profiles of a new container doesn't cost a few round-trips per profile and
+// device.
+func (c *ClusterTx) ProfilesExpandData(names []string) (map[string]map[string]string, map[string]types.Devices, error) {
+	configs := map[string]map[string]string{}
+	devices := map[string]types.Devices{}
+
+	if len(names) == 0 {
+		return configs, devices, nil
+	}
+
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+
+	stmt := fmt.Sprintf("SELECT name FROM profiles WHERE name IN %s", query.Params(len(names)))
+	existing, err := query.SelectStrings(c.tx, stmt, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, name := range existing {
+		configs[name] = map[string]string{}
+		devices[name] = types.Devices{}
+	}
+
+	// Config of all the profiles
+	type configRow struct {
+		profile string
+		key     string
+		value   string
+	}
+
+	configRows := []configRow{}
+	stmt = fmt.Sprintf(`
+SELECT profiles.name, profiles_config.key, profiles_config.value
+  FROM profiles_config JOIN profiles ON profiles_config.profile_id = profiles.id
+ WHERE profiles.name IN %s`, query.Params(len(names)))
+	err = query.SelectObjects(c.tx, func(i int) []interface{} {
+		configRows = append(configRows, configRow{})
+		row := &configRows[i]
+		return []interface{}{&row.profile, &row.key, &row.value}
+	}, stmt, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, row := range configRows {
+		configs[row.profile][row.key] = row.value
+	}
+
+	// Devices of all the profiles, along with their config
+	type deviceRow struct {
+		profile string
+		name    string
+		dtype   int
+		key     sql.NullString
+		value   sql.NullString
+	}
+
+	deviceRows := []deviceRow{}
+	stmt = fmt.Sprintf(`
+SELECT profiles.name, profiles_devices.name, profiles_devices.type,
+       profiles_devices_config.key, profiles_devices_config.value
+  FROM profiles_devices JOIN profiles ON profiles_devices.profile_id = profiles.id
+  LEFT JOIN profiles_devices_config ON profiles_devices_config.profile_device_id = profiles_devices.id
+ WHERE profiles.name IN %s`, query.Params(len(names)))
+	err = query.SelectObjects(c.tx, func(i int) []interface{} {
+		deviceRows = append(deviceRows, deviceRow{})
+		row := &deviceRows[i]
+		return []interface{}{&row.profile, &row.name, &row.dtype, &row.key, &row.value}
+	}, stmt, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, row := range deviceRows {
+		device, ok := devices[row.profile][row.name]
+		if !ok {
+			dtype, err := dbDeviceTypeToString(row.dtype)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			device = types.Device{"type": dtype}
+			devices[row.profile][row.name] = device
+		}
+
+		if row.key.Valid {
+			device[row.key.String] = row.value.String
+		}
+	}
+
+	return configs, devices, nil
+}
@@ -0,0 +1,49 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The config and devices of the requested profiles are returned, keyed by
+// profile name, and missing profiles are omitted.
+func TestProfilesExpandData(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	_, err := cluster.ProfileCreate("web", "", map[string]string{"limits.cpu": "2"}, types.Devices{
+		"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+		"tmp":  {"type": "none"},
+	})
+	require.NoError(t, err)
+
+	_, err = cluster.ProfileCreate("empty", "", map[string]string{}, types.Devices{})
+	require.NoError(t, err)
+
+	var configs map[string]map[string]string
+	var devices map[string]types.Devices
+
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		configs, devices, err = tx.ProfilesExpandData([]string{"web", "empty", "missing"})
+		return err
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]map[string]string{
+		"web":   {"limits.cpu": "2"},
+		"empty": {},
+	}, configs)
+
+	assert.Equal(t, map[string]types.Devices{
+		"web": {
+			"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+			"tmp":  {"type": "none"},
+		},
+		"empty": {},
+	}, devices)
+}
@@ -110,9 +110,9 @@ func dumpTable(tx *sql.Tx, table, schema string) (string, error) {
 			case int64:
 				values[j] = strconv.FormatInt(v, 10)
 			case string:
-				values[j] = fmt.Sprintf("'%s'", v)
+				values[j] = dumpQuote(v)
 			case []byte:
-				values[j] = fmt.Sprintf("'%s'", string(v))
+				values[j] = dumpQuote(string(v))
 			case time.Time:
 				values[j] = strconv.FormatInt(v.Unix(), 10)
 			default:
@@ -128,6 +128,164 @@ func dumpTable(tx *sql.Tx, table, schema string) (string, error) {
 	return strings.Join(statements, "\n") + "\n", nil
 }
 
+// Quote a text value, escaping the single quotes it contains.
+func dumpQuote(value string) string {
+	return fmt.Sprintf("'%s'", strings.Replace(value, "'", "''", -1))
+}
+
+// Restore replaces the rows of all tables with the ones of the given SQL text
+// dump, as generated by Dump. The tables of the dump must match the ones of
+// the database, since their schema is not touched. Rows are inserted in an
+// order satisfying the foreign keys between tables.
+func Restore(tx *sql.Tx, dump string) error {
+	schemas := map[string]string{}   // Schema of each table
+	inserts := map[string][]string{} // INSERT statements of each table
+	sequences := []string{}          // Statements for the sqlite_sequence table
+
+	for _, statement := range dumpSplit(dump) {
+		switch {
+		case strings.HasPrefix(statement, "CREATE TABLE "):
+			table := strings.Split(statement, " ")[2]
+			schemas[table] = statement
+		case strings.HasPrefix(statement, "INSERT INTO sqlite_sequence "):
+			sequences = append(sequences, statement)
+		case strings.HasPrefix(statement, "INSERT INTO "):
+			table := strings.Split(statement, " ")[2]
+			if strings.Contains(table, "(") {
+				table = table[:strings.Index(table, "(")]
+			}
+			inserts[table] = append(inserts[table], statement)
+		}
+	}
+
+	if len(schemas) == 0 {
+		return fmt.Errorf("no table found in dump")
+	}
+
+	for table := range inserts {
+		if _, ok := schemas[table]; !ok {
+			return fmt.Errorf("rows of table %s found in dump without its schema", table)
+		}
+	}
+
+	tables, err := dumpSortTables(schemas)
+	if err != nil {
+		return err
+	}
+
+	// Delete children before their parents, then insert the parents first.
+	for i := len(tables) - 1; i >= 0; i-- {
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", tables[i]))
+		if err != nil {
+			return errors.Wrapf(err, "failed to clear table %s", tables[i])
+		}
+	}
+
+	for _, table := range tables {
+		for _, statement := range inserts[table] {
+			_, err := tx.Exec(statement)
+			if err != nil {
+				return errors.Wrapf(err, "failed to restore row of table %s", table)
+			}
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM sqlite_sequence")
+	if err != nil {
+		return errors.Wrap(err, "failed to clear table sqlite_sequence")
+	}
+
+	for _, statement := range sequences {
+		_, err := tx.Exec(statement)
+		if err != nil {
+			return errors.Wrap(err, "failed to restore table sqlite_sequence")
+		}
+	}
+
+	return nil
+}
+
+// Split a SQL text dump into its statements, without the trailing semicolon.
+// Semicolons within quoted values don't end a statement.
+func dumpSplit(dump string) []string {
+	statements := []string{}
+	quoted := false
+	start := 0
+	for i, c := range dump {
+		switch c {
+		case '\'':
+			quoted = !quoted
+		case ';':
+			if quoted {
+				continue
+			}
+			statement := strings.TrimSpace(dump[start:i])
+			if statement != "" {
+				statements = append(statements, statement)
+			}
+			start = i + 1
+		}
+	}
+	return statements
+}
+
+// Sort the given tables so that every table comes after the ones its foreign
+// keys reference.
+func dumpSortTables(schemas map[string]string) ([]string, error) {
+	names := make([]string, 0, len(schemas))
+	for table := range schemas {
+		names = append(names, table)
+	}
+	sort.Strings(names)
+
+	tables := []string{}
+	done := map[string]bool{}
+	for len(tables) < len(names) {
+		progress := false
+		for _, table := range names {
+			if done[table] {
+				continue
+			}
+			ready := true
+			for _, parent := range dumpReferences(schemas[table]) {
+				if parent != table && !done[parent] && schemas[parent] != "" {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+			tables = append(tables, table)
+			done[table] = true
+			progress = true
+		}
+		if !progress {
+			return nil, fmt.Errorf("circular foreign keys between tables")
+		}
+	}
+
+	return tables, nil
+}
+
+// Return the names of the tables referenced by the foreign keys of the given
+// table schema.
+func dumpReferences(schema string) []string {
+	references := []string{}
+	fields := strings.Fields(schema)
+	for i, field := range fields {
+		if field != "REFERENCES" || i+1 == len(fields) {
+			continue
+		}
+		table := fields[i+1]
+		if strings.Contains(table, "(") {
+			table = table[:strings.Index(table, "(")]
+		}
+		references = append(references, table)
+	}
+	return references
+}
+
 // Schema of the schema table.
 const dumpSchemaTable = `CREATE TABLE schema (
     id         INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
@@ -101,6 +101,33 @@ INSERT INTO storage_pools_config VALUES(1,1,NULL,'k','v');
 `, dump)
 }
 
+// A dump restored over a database with other rows brings back its rows only,
+// including values containing quotes and semicolons.
+func TestRestore(t *testing.T) {
+	tx := newTxForDump(t, "global")
+	_, err := tx.Exec("INSERT INTO storage_pools_config VALUES(2,1,NULL,'x','it''s; quoted')")
+	require.NoError(t, err)
+
+	dump, err := query.Dump(tx, schemas["global"], false /* schemaOnly */)
+	require.NoError(t, err)
+
+	_, err = tx.Exec("DELETE FROM storage_pools_config WHERE id=1")
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO storage_pools VALUES(2,'p2','zfs','',0)")
+	require.NoError(t, err)
+
+	err = query.Restore(tx, dump)
+	require.NoError(t, err)
+
+	restored, err := query.Dump(tx, schemas["global"], false /* schemaOnly */)
+	require.NoError(t, err)
+	assert.Equal(t, dump, restored)
+
+	values, err := query.SelectStrings(tx, "SELECT value FROM storage_pools_config WHERE id=2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"it's; quoted"}, values)
+}
+
 func TestDumpParseSchema(t *testing.T) {
 	cases := []struct {
 		schema string   // Schema name
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// SeccompPolicyNames returns the names of all seccomp policies.
+func (c *ClusterTx) SeccompPolicyNames() ([]string, error) {
+	stmt := "SELECT name FROM seccomp_policies ORDER BY name"
+	names, err := query.SelectStrings(c.tx, stmt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch seccomp policy names")
+	}
+
+	return names, nil
+}
+
+// SeccompPolicyGet returns the seccomp policy with the given name. The UsedBy
+// field is left empty.
+func (c *ClusterTx) SeccompPolicyGet(name string) (*api.SeccompPolicy, error) {
+	policy := api.SeccompPolicy{Name: name, UsedBy: []string{}}
+
+	id, err := c.seccompPolicyID(name)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions, err := query.SelectStrings(c.tx, "SELECT coalesce(description, '') FROM seccomp_policies WHERE id=?", id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch seccomp policy")
+	}
+	policy.Description = descriptions[0]
+
+	policy.Config, err = query.SelectConfig(c.tx, "seccomp_policies_config", "seccomp_policy_id=?", id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch seccomp policy config")
+	}
+
+	return &policy, nil
+}
+
+// SeccompPolicyCreate adds a new seccomp policy.
+func (c *ClusterTx) SeccompPolicyCreate(name string, description string, config map[string]string) (int64, error) {
+	_, err := c.seccompPolicyID(name)
+	if err == nil {
+		return -1, ErrAlreadyDefined
+	}
+	if err != ErrNoSuchObject {
+		return -1, err
+	}
+
+	result, err := c.tx.Exec("INSERT INTO seccomp_policies (name, description) VALUES (?, ?)", name, description)
+	if err != nil {
+		return -1, errors.Wrap(err, "failed to add seccomp policy")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	err = c.seccompPolicyConfigAdd(id, config)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// SeccompPolicyUpdate replaces the description and config of the seccomp
+// policy with the given name.
+func (c *ClusterTx) SeccompPolicyUpdate(name string, description string, config map[string]string) error {
+	id, err := c.seccompPolicyID(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.tx.Exec("UPDATE seccomp_policies SET description=? WHERE id=?", description, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update seccomp policy")
+	}
+
+	_, err = c.tx.Exec("DELETE FROM seccomp_policies_config WHERE seccomp_policy_id=?", id)
+	if err != nil {
+		return errors.Wrap(err, "failed to clear seccomp policy config")
+	}
+
+	return c.seccompPolicyConfigAdd(id, config)
+}
+
+// SeccompPolicyDelete removes the seccomp policy with the given name.
+func (c *ClusterTx) SeccompPolicyDelete(name string) error {
+	id, err := c.seccompPolicyID(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = query.DeleteObject(c.tx, "seccomp_policies", id)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete seccomp policy")
+	}
+
+	return nil
+}
+
+func (c *ClusterTx) seccompPolicyID(name string) (int64, error) {
+	ids, err := query.SelectIntegers(c.tx, "SELECT id FROM seccomp_policies WHERE name=?", name)
+	if err != nil {
+		return -1, errors.Wrap(err, "failed to fetch seccomp policy ID")
+	}
+
+	switch len(ids) {
+	case 0:
+		return -1, ErrNoSuchObject
+	case 1:
+		return int64(ids[0]), nil
+	default:
+		return -1, errors.Errorf("more than one seccomp policy named '%s'", name)
+	}
+}
+
+func (c *ClusterTx) seccompPolicyConfigAdd(id int64, config map[string]string) error {
+	stmt := "INSERT INTO seccomp_policies_config (seccomp_policy_id, key, value) VALUES (?, ?, ?)"
+	for key, value := range config {
+		if value == "" {
+			continue
+		}
+
+		_, err := c.tx.Exec(stmt, id, key, value)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add seccomp policy config key '%s'", key)
+		}
+	}
+
+	return nil
+}
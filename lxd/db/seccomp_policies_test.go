@@ -0,0 +1,64 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeccompPolicyCreate(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	config := map[string]string{"syscalls.blacklist": "reject_force_umount"}
+	_, err := tx.SeccompPolicyCreate("strict", "Strict policy", config)
+	require.NoError(t, err)
+
+	policy, err := tx.SeccompPolicyGet("strict")
+	require.NoError(t, err)
+	assert.Equal(t, "strict", policy.Name)
+	assert.Equal(t, "Strict policy", policy.Description)
+	assert.Equal(t, config, policy.Config)
+
+	_, err = tx.SeccompPolicyCreate("strict", "", nil)
+	assert.Equal(t, db.ErrAlreadyDefined, err)
+}
+
+func TestSeccompPolicyUpdate(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.SeccompPolicyCreate("strict", "", map[string]string{"syscalls.blacklist_compat": "true"})
+	require.NoError(t, err)
+
+	config := map[string]string{"syscalls.blacklist_default": "false"}
+	err = tx.SeccompPolicyUpdate("strict", "Updated", config)
+	require.NoError(t, err)
+
+	policy, err := tx.SeccompPolicyGet("strict")
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", policy.Description)
+	assert.Equal(t, config, policy.Config)
+}
+
+func TestSeccompPolicyDelete(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.SeccompPolicyCreate("strict", "", nil)
+	require.NoError(t, err)
+	_, err = tx.SeccompPolicyCreate("loose", "", nil)
+	require.NoError(t, err)
+
+	err = tx.SeccompPolicyDelete("strict")
+	require.NoError(t, err)
+
+	names, err := tx.SeccompPolicyNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"loose"}, names)
+
+	_, err = tx.SeccompPolicyGet("strict")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+)
+
+// Possible statuses of a warning.
+const (
+	WarningStatusNew          = "new"
+	WarningStatusAcknowledged = "acknowledged"
+	WarningStatusResolved     = "resolved"
+)
+
+// Warning holds information about a condition detected by a node which needs
+// the attention of the administrator, such as an expiring certificate.
+type Warning struct {
+	ID          int64     // Stable database identifier
+	UUID        string    // User-visible identifier
+	NodeAddress string    // Address of the node which raised the warning
+	Type        string    // Kind of condition, e.g. "certificate-expiry"
+	Entity      string    // Object the warning is about, e.g. a fingerprint
+	Status      string    // One of the WarningStatus* values
+	Count       int       // How many times the condition was detected
+	FirstSeenAt time.Time // When the condition was first detected
+	LastSeenAt  time.Time // When the condition was last detected
+	LastMessage string    // Message of the last occurrence
+}
+
+// Warnings returns all warnings in the cluster.
+func (c *ClusterTx) Warnings() ([]Warning, error) {
+	return c.warnings("")
+}
+
+// WarningByUUID returns the warning with the given UUID.
+func (c *ClusterTx) WarningByUUID(uuid string) (Warning, error) {
+	null := Warning{}
+	warnings, err := c.warnings("uuid=?", uuid)
+	if err != nil {
+		return null, err
+	}
+	switch len(warnings) {
+	case 0:
+		return null, ErrNoSuchObject
+	case 1:
+		return warnings[0], nil
+	default:
+		return null, fmt.Errorf("more than one warning matches")
+	}
+}
+
+// WarningUpsert records an occurrence of the warning of the given type about
+// the given entity on this node.
+//
+// If the warning was already raised, its count is increased and its message
+// updated. A resolved warning which occurs again becomes new, while an
+// acknowledged one stays acknowledged.
+func (c *ClusterTx) WarningUpsert(kind, entity, message string) error {
+	now := time.Now().UTC()
+	result, err := c.tx.Exec(`
+UPDATE warnings
+   SET count=count+1, last_seen_at=?, last_message=?,
+       status=CASE WHEN status=? THEN ? ELSE status END
+ WHERE node_id=? AND type=? AND entity=?`,
+		now, message, WarningStatusResolved, WarningStatusNew, c.nodeID, kind, entity)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	_, err = c.tx.Exec(`
+INSERT INTO warnings (node_id, uuid, type, entity, status, count, first_seen_at, last_seen_at, last_message)
+  VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?)`,
+		c.nodeID, uuid.NewRandom().String(), kind, entity, WarningStatusNew, now, now, message)
+	return err
+}
+
+// WarningResolve marks as resolved the warning of the given type about the
+// given entity on this node, if any.
+func (c *ClusterTx) WarningResolve(kind, entity string) error {
+	_, err := c.tx.Exec(
+		"UPDATE warnings SET status=? WHERE node_id=? AND type=? AND entity=?",
+		WarningStatusResolved, c.nodeID, kind, entity)
+	return err
+}
+
+// WarningStatusSet sets the status of the warning with the given UUID.
+func (c *ClusterTx) WarningStatusSet(uuid string, status string) error {
+	switch status {
+	case WarningStatusNew, WarningStatusAcknowledged, WarningStatusResolved:
+	default:
+		return fmt.Errorf("Invalid warning status: %s", status)
+	}
+
+	result, err := c.tx.Exec("UPDATE warnings SET status=? WHERE uuid=?", status, uuid)
+	if err != nil {
+		return err
+	}
+	return warningExpectOne(result.RowsAffected())
+}
+
+// WarningDelete removes the warning with the given UUID.
+func (c *ClusterTx) WarningDelete(uuid string) error {
+	result, err := c.tx.Exec("DELETE FROM warnings WHERE uuid=?", uuid)
+	if err != nil {
+		return err
+	}
+	return warningExpectOne(result.RowsAffected())
+}
+
+// Return ErrNoSuchObject if a statement matched no warning.
+func warningExpectOne(n int64, err error) error {
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoSuchObject
+	}
+	return nil
+}
+
+// Return all warnings in the cluster, filtered by the given clause.
+func (c *ClusterTx) warnings(where string, args ...interface{}) ([]Warning, error) {
+	warnings := []Warning{}
+	dest := func(i int) []interface{} {
+		warnings = append(warnings, Warning{})
+		return []interface{}{
+			&warnings[i].ID,
+			&warnings[i].UUID,
+			&warnings[i].NodeAddress,
+			&warnings[i].Type,
+			&warnings[i].Entity,
+			&warnings[i].Status,
+			&warnings[i].Count,
+			&warnings[i].FirstSeenAt,
+			&warnings[i].LastSeenAt,
+			&warnings[i].LastMessage,
+		}
+	}
+	stmt := `
+SELECT warnings.id, uuid, nodes.address, type, entity, status, count, first_seen_at, last_seen_at, last_message
+  FROM warnings JOIN nodes ON nodes.id = node_id `
+	if where != "" {
+		stmt += fmt.Sprintf("WHERE %s ", where)
+	}
+	stmt += "ORDER BY warnings.id"
+	err := query.SelectObjects(c.tx, dest, stmt, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch warnings")
+	}
+	return warnings, nil
+}
@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A warning raised several times is recorded once, and comes back as new
+// only after having been resolved.
+func TestWarningUpsert(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	require.NoError(t, tx.WarningUpsert("certificate-expiry", "abcd", "Expires in 10 days"))
+	require.NoError(t, tx.WarningUpsert("certificate-expiry", "abcd", "Expires in 9 days"))
+
+	warnings, err := tx.Warnings()
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	warning := warnings[0]
+	assert.Equal(t, 2, warning.Count)
+	assert.Equal(t, "Expires in 9 days", warning.LastMessage)
+	assert.Equal(t, db.WarningStatusNew, warning.Status)
+	assert.Equal(t, "0.0.0.0", warning.NodeAddress)
+
+	require.NoError(t, tx.WarningStatusSet(warning.UUID, db.WarningStatusAcknowledged))
+	require.NoError(t, tx.WarningUpsert("certificate-expiry", "abcd", "Expires in 8 days"))
+	warning, err = tx.WarningByUUID(warning.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, db.WarningStatusAcknowledged, warning.Status)
+
+	require.NoError(t, tx.WarningResolve("certificate-expiry", "abcd"))
+	warning, err = tx.WarningByUUID(warning.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, db.WarningStatusResolved, warning.Status)
+
+	require.NoError(t, tx.WarningUpsert("certificate-expiry", "abcd", "Expires in 1 day"))
+	warning, err = tx.WarningByUUID(warning.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, db.WarningStatusNew, warning.Status)
+	assert.Equal(t, 4, warning.Count)
+}
+
+// Setting an unknown status fails, and so does touching a missing warning.
+func TestWarningStatusSetAndDelete(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	require.NoError(t, tx.WarningUpsert("certificate-expiry", "abcd", "Expires in 10 days"))
+	warnings, err := tx.Warnings()
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	uuid := warnings[0].UUID
+
+	assert.EqualError(t, tx.WarningStatusSet(uuid, "bogus"), "Invalid warning status: bogus")
+	assert.Equal(t, db.ErrNoSuchObject, tx.WarningStatusSet("missing", db.WarningStatusResolved))
+
+	require.NoError(t, tx.WarningDelete(uuid))
+	_, err = tx.WarningByUUID(uuid)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+	assert.Equal(t, db.ErrNoSuchObject, tx.WarningDelete(uuid))
+}
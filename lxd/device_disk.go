@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+)
+
+// Disks, either the root disk, storage volumes or paths and Ceph volumes
+// mounted from the host.
+type deviceDisk struct {
+	deviceKeys
+}
+
+func init() {
+	deviceDriverRegister("disk", &deviceDisk{deviceKeys{
+		"limits.max",
+		"limits.read",
+		"limits.write",
+		"optional",
+		"path",
+		"readonly",
+		"size",
+		"source",
+		"recursive",
+		"pool",
+		"propagation",
+		"passthrough",
+		"ceph.cluster_name",
+		"ceph.user_name",
+	}})
+}
+
+func (d *deviceDisk) Validate(cluster *db.Cluster, m types.Device, expanded bool) error {
+	if m["path"] == "" {
+		return fmt.Errorf("Disk entry is missing the required \"path\" property.")
+	}
+
+	if m["source"] == "" && m["path"] != "/" {
+		return fmt.Errorf("Disk entry is missing the required \"source\" property.")
+	}
+
+	if m["path"] == "/" && m["source"] != "" {
+		return fmt.Errorf("Root disk entry may not have a \"source\" property set.")
+	}
+
+	if m["size"] != "" && m["path"] != "/" {
+		return fmt.Errorf("Only the root disk may have a size quota.")
+	}
+
+	if (m["path"] == "/" || !shared.IsDir(m["source"])) && m["recursive"] != "" {
+		return fmt.Errorf("The recursive option is only supported for additional bind-mounted paths.")
+	}
+
+	if deviceIsCephSource(m["source"]) {
+		if m["pool"] != "" {
+			return fmt.Errorf("Ceph sources can't be used together with a storage pool.")
+		}
+
+		_, _, _, err := deviceParseCephSource(m["source"])
+		if err != nil {
+			return err
+		}
+	} else if m["ceph.cluster_name"] != "" || m["ceph.user_name"] != "" {
+		return fmt.Errorf("The Ceph properties can only be set on disks with a Ceph source.")
+	}
+
+	if m["pool"] != "" {
+		if filepath.IsAbs(m["source"]) {
+			return fmt.Errorf("Storage volumes cannot be specified as absolute paths.")
+		}
+
+		_, err := cluster.StoragePoolGetID(m["pool"])
+		if err != nil {
+			return fmt.Errorf("The \"%s\" storage pool doesn't exist.", m["pool"])
+		}
+	}
+
+	if m["propagation"] != "" {
+		if !util.RuntimeLiblxcVersionAtLeast(3, 0, 0) {
+			return fmt.Errorf("liblxc 3.0 is required for mount propagation configuration")
+		}
+
+		if !shared.StringInSlice(m["propagation"], []string{"private", "shared", "slave", "unbindable", "rprivate", "rshared", "rslave", "runbindable"}) {
+			return fmt.Errorf("Invalid propagation mode '%s'", m["propagation"])
+		}
+	}
+
+	if !shared.StringInSlice(m["passthrough"], []string{"", "bind", "fuse"}) {
+		return fmt.Errorf("Invalid passthrough mode '%s'", m["passthrough"])
+	}
+
+	if m["passthrough"] == "fuse" {
+		if m["path"] == "/" || m["pool"] != "" || deviceIsCephSource(m["source"]) {
+			return fmt.Errorf("FUSE passthrough is only supported for host paths.")
+		}
+
+		if m["propagation"] != "" {
+			return fmt.Errorf("FUSE passthrough doesn't support mount propagation.")
+		}
+	}
+
+	return nil
+}
+
+func (d *deviceDisk) Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	isRootfs := shared.IsRootDiskDevice(m)
+
+	// source paths
+	srcPath := shared.HostPath(m["source"])
+
+	// destination paths
+	destPath := m["path"]
+	relativeDestPath := strings.TrimPrefix(destPath, "/")
+
+	sourceDevPath := filepath.Join(c.DevicesPath(), fmt.Sprintf("disk.%s.%s", strings.Replace(name, "/", "-", -1), strings.Replace(relativeDestPath, "/", "-", -1)))
+
+	// Various option checks
+	isOptional := shared.IsTrue(m["optional"])
+	isReadOnly := shared.IsTrue(m["readonly"])
+	isRecursive := shared.IsTrue(m["recursive"])
+
+	// If we want to mount a storage volume from a storage
+	// pool we created via our storage api or a Ceph source,
+	// we are always mounting a directory.
+	isFile := false
+	if m["pool"] == "" && !deviceIsCephSource(m["source"]) {
+		isFile = !shared.IsDir(srcPath) && !deviceIsBlockdev(srcPath) && deviceImageFSType(srcPath) == ""
+	}
+
+	// Deal with a rootfs
+	if isRootfs {
+		if !util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
+			// Set the rootfs backend type if supported (must happen before any other lxc.rootfs)
+			err := lxcSetConfigItem(cc, "lxc.rootfs.backend", "dir")
+			if err == nil {
+				value := cc.ConfigItem("lxc.rootfs.backend")
+				if len(value) == 0 || value[0] != "dir" {
+					lxcSetConfigItem(cc, "lxc.rootfs.backend", "")
+				}
+			}
+		}
+
+		// Set the rootfs path
+		var err error
+		if util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
+			rootfsPath := fmt.Sprintf("dir:%s", c.RootfsPath())
+			err = lxcSetConfigItem(cc, "lxc.rootfs.path", rootfsPath)
+		} else {
+			rootfsPath := c.RootfsPath()
+			err = lxcSetConfigItem(cc, "lxc.rootfs", rootfsPath)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Read-only rootfs (unlikely to work very well)
+		if isReadOnly {
+			err = lxcSetConfigItem(cc, "lxc.rootfs.options", "ro")
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		rbind := ""
+		options := []string{}
+		if isReadOnly {
+			options = append(options, "ro")
+		}
+
+		if isOptional {
+			options = append(options, "optional")
+		}
+
+		if isRecursive {
+			rbind = "r"
+		}
+
+		if m["propagation"] != "" {
+			options = append(options, m["propagation"])
+		}
+
+		if isFile {
+			options = append(options, "create=file")
+		} else {
+			options = append(options, "create=dir")
+		}
+
+		// Shifted storage volumes are mounted through
+		// shiftfs from within the container
+		isShifted, err := storagePoolVolumeIsShifted(c.state, m)
+		if err != nil && !isOptional {
+			return err
+		}
+
+		if isShifted {
+			err = lxcSetConfigItem(cc, "lxc.mount.entry",
+				fmt.Sprintf("%s %s shiftfs %s",
+					shared.EscapePathFstab(sourceDevPath),
+					shared.EscapePathFstab(relativeDestPath),
+					strings.Join(options, ",")))
+		} else {
+			err = lxcSetConfigItem(cc, "lxc.mount.entry",
+				fmt.Sprintf("%s %s none %sbind,%s",
+					shared.EscapePathFstab(sourceDevPath),
+					shared.EscapePathFstab(relativeDestPath), rbind,
+					strings.Join(options, ",")))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *deviceDisk) Start(c *containerLXC, name string, m types.Device) error {
+	// The root disk can't be changed on a running container
+	if m["path"] == "/" {
+		return nil
+	}
+
+	return c.insertDiskDevice(name, m)
+}
+
+func (d *deviceDisk) Stop(c *containerLXC, name string, m types.Device) error {
+	if m["path"] == "/" {
+		return nil
+	}
+
+	return c.removeDiskDevice(name, m)
+}
+
+func (d *deviceDisk) Update(c *containerLXC, name string, m types.Device, changed []string) error {
+	return c.setDiskLimits()
+}
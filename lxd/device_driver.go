@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+)
+
+// A deviceDriver implements one type of container device: the keys it takes,
+// how its configuration is checked, how it's set up when the container starts
+// and how it's added to, removed from and updated on a running container.
+//
+// Each device type lives in its own device_<type>.go file and registers its
+// driver from an init function.
+type deviceDriver interface {
+	// ValidKey returns whether the key is a configuration key of the device
+	// type. The "type" key is handled by the caller.
+	ValidKey(key string) bool
+
+	// Validate checks the configuration of a device. The expanded flag is
+	// set when checking the devices of a container with its profiles
+	// applied.
+	Validate(cluster *db.Cluster, m types.Device, expanded bool) error
+
+	// Config adds the device to the LXC configuration generated for the
+	// container before it starts.
+	Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error
+
+	// Start adds the device to a running container.
+	Start(c *containerLXC, name string, m types.Device) error
+
+	// Stop removes the device from a running container.
+	Stop(c *containerLXC, name string, m types.Device) error
+
+	// Update applies a change of the live-updatable keys listed in changed
+	// to a running container.
+	Update(c *containerLXC, name string, m types.Device, changed []string) error
+}
+
+var deviceDrivers = map[string]deviceDriver{}
+
+// deviceDriverRegister makes a device type available to containers.
+func deviceDriverRegister(deviceType string, driver deviceDriver) {
+	deviceDrivers[deviceType] = driver
+}
+
+// deviceDriverLoad returns the driver of a device type.
+func deviceDriverLoad(deviceType string) (deviceDriver, error) {
+	driver, ok := deviceDrivers[deviceType]
+	if !ok {
+		return nil, fmt.Errorf("Invalid device type: %s", deviceType)
+	}
+
+	return driver, nil
+}
+
+// deviceKeys implements ValidKey for drivers taking a fixed list of keys.
+type deviceKeys []string
+
+func (keys deviceKeys) ValidKey(key string) bool {
+	return shared.StringInSlice(key, keys)
+}
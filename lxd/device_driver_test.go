@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+)
+
+// Driver recording the calls made to it, and failing those on the device
+// named fail.
+type deviceTestDriver struct {
+	deviceKeys
+	calls []string
+	fail  string
+}
+
+func (d *deviceTestDriver) record(call string, name string) error {
+	d.calls = append(d.calls, fmt.Sprintf("%s %s", call, name))
+	if name == d.fail {
+		return fmt.Errorf("%s failed", name)
+	}
+
+	return nil
+}
+
+func (d *deviceTestDriver) Validate(cluster *db.Cluster, m types.Device, expanded bool) error {
+	return d.record("validate", m["value"])
+}
+
+func (d *deviceTestDriver) Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	return d.record("config", name)
+}
+
+func (d *deviceTestDriver) Start(c *containerLXC, name string, m types.Device) error {
+	return d.record("start", name)
+}
+
+func (d *deviceTestDriver) Stop(c *containerLXC, name string, m types.Device) error {
+	return d.record("stop", name)
+}
+
+func (d *deviceTestDriver) Update(c *containerLXC, name string, m types.Device, changed []string) error {
+	return d.record("update", fmt.Sprintf("%s %s", name, strings.Join(changed, ",")))
+}
+
+// Register the test driver as the "test" device type.
+func deviceTestDriverRegister() (*deviceTestDriver, func()) {
+	driver := &deviceTestDriver{deviceKeys: deviceKeys{"value"}}
+	deviceDriverRegister("test", driver)
+
+	return driver, func() { delete(deviceDrivers, "test") }
+}
+
+// Device keys and configurations are checked by the driver of their type.
+func TestContainerValidDevices_Driver(t *testing.T) {
+	driver, cleanup := deviceTestDriverRegister()
+	defer cleanup()
+
+	assert.True(t, containerValidDeviceConfigKey("test", "type"))
+	assert.True(t, containerValidDeviceConfigKey("test", "value"))
+	assert.False(t, containerValidDeviceConfigKey("test", "path"))
+	assert.False(t, containerValidDeviceConfigKey("unknown", "value"))
+
+	devices := types.Devices{"dev": types.Device{"type": "test", "value": "ok"}}
+	require.NoError(t, containerValidDevices(nil, devices, false, false))
+	assert.Equal(t, []string{"validate ok"}, driver.calls)
+
+	driver.fail = "bad"
+	devices = types.Devices{"dev": types.Device{"type": "test", "value": "bad"}}
+	assert.EqualError(t, containerValidDevices(nil, devices, false, false), "bad failed")
+
+	devices = types.Devices{"dev": types.Device{"type": "test", "path": "/"}}
+	assert.EqualError(t, containerValidDevices(nil, devices, false, false), "Invalid device configuration key for test: path")
+
+	devices = types.Devices{"dev": types.Device{"type": "unknown"}}
+	assert.EqualError(t, containerValidDevices(nil, devices, false, false), "Invalid device type for device 'dev'")
+}
+
+// Removed devices are stopped in the reverse order they were added in, then
+// added devices are started and changed ones updated.
+func TestContainerLXC_UpdateDevices(t *testing.T) {
+	driver, cleanup := deviceTestDriverRegister()
+	defer cleanup()
+
+	c := &containerLXC{}
+	remove := types.Devices{
+		"a": types.Device{"type": "test"},
+		"b": types.Device{"type": "test"},
+	}
+	add := types.Devices{
+		"c": types.Device{"type": "test"},
+		"d": types.Device{"type": "test"},
+	}
+	update := types.Devices{
+		"e": types.Device{"type": "test", "value": "1"},
+	}
+	diff := map[string][]string{"e": {"value"}}
+
+	require.NoError(t, c.updateDevices(remove, add, update, diff))
+	assert.Equal(t, []string{"stop b", "stop a", "start c", "start d", "update e value"}, driver.calls)
+
+	// A failure stops the update where it happened
+	driver.calls = nil
+	driver.fail = "a"
+	assert.EqualError(t, c.updateDevices(remove, add, update, diff), "a failed")
+	assert.Equal(t, []string{"stop b", "stop a"}, driver.calls)
+
+	remove = types.Devices{"x": types.Device{"type": "unknown"}}
+	assert.EqualError(t, c.updateDevices(remove, nil, nil, nil), "Invalid device type: unknown")
+}
+
+// Network devices get consecutive indexes in the LXC configuration, followed
+// by the tagged VLANs of the physical nics.
+func TestContainerLXC_NetworkIndex(t *testing.T) {
+	c := &containerLXC{expandedDevices: types.Devices{
+		"root": types.Device{"type": "disk", "path": "/", "pool": "default"},
+		"ib0":  types.Device{"type": "infiniband", "nictype": "physical", "parent": "ib0"},
+		"eth0": types.Device{"type": "nic", "nictype": "bridged", "parent": "br0"},
+		"eth1": types.Device{"type": "nic", "nictype": "physical", "parent": "eth1", "vlan.tagged": "10,20"},
+		"eth2": types.Device{"type": "nic", "nictype": "physical", "parent": "eth2", "vlan.tagged": "30"},
+	}}
+
+	assert.Equal(t, 0, c.networkIndex("ib0"))
+	assert.Equal(t, 1, c.networkIndex("eth0"))
+	assert.Equal(t, 2, c.networkIndex("eth1"))
+	assert.Equal(t, 3, c.networkIndex("eth2"))
+
+	assert.Equal(t, 4, c.networkVLANIndex("eth1"))
+	assert.Equal(t, 6, c.networkVLANIndex("eth2"))
+}
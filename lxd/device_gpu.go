@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// GPUs, passed through along with the NVIDIA control devices they need.
+type deviceGPU struct {
+	deviceKeys
+}
+
+func init() {
+	deviceDriverRegister("gpu", &deviceGPU{deviceKeys{"vendorid", "productid", "id", "pci", "mode", "gid", "uid"}})
+}
+
+func (d *deviceGPU) Validate(cluster *db.Cluster, m types.Device, expanded bool) error {
+	if m["pci"] != "" && !shared.PathExists(fmt.Sprintf("/sys/bus/pci/devices/%s", m["pci"])) {
+		return fmt.Errorf("Invalid PCI address (no device found): %s", m["pci"])
+	}
+
+	if m["pci"] != "" && (m["id"] != "" || m["productid"] != "" || m["vendorid"] != "") {
+		return fmt.Errorf("Cannot use id, productid or vendorid when pci is set")
+	}
+
+	if m["id"] != "" && (m["pci"] != "" || m["productid"] != "" || m["vendorid"] != "") {
+		return fmt.Errorf("Cannot use pci, productid or vendorid when id is set")
+	}
+
+	return nil
+}
+
+func (d *deviceGPU) Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	return nil
+}
+
+func (d *deviceGPU) Start(c *containerLXC, name string, m types.Device) error {
+	allGpus := deviceWantsAllGPUs(m)
+	gpus, nvidiaDevices, err := deviceLoadGpu(allGpus)
+	if err != nil {
+		return err
+	}
+
+	sawNvidia := false
+	found := false
+	for _, gpu := range gpus {
+		if (m["vendorid"] != "" && gpu.vendorid != m["vendorid"]) ||
+			(m["pci"] != "" && gpu.pci != m["pci"]) ||
+			(m["productid"] != "" && gpu.productid != m["productid"]) ||
+			(m["id"] != "" && gpu.id != m["id"]) {
+			continue
+		}
+
+		found = true
+
+		err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, gpu.major, gpu.minor, gpu.path, false)
+		if err != nil {
+			logger.Error("Failed to insert GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
+			return err
+		}
+
+		if !gpu.isNvidia {
+			continue
+		}
+
+		if gpu.nvidia.path != "" {
+			err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, gpu.nvidia.major, gpu.nvidia.minor, gpu.nvidia.path, false)
+			if err != nil {
+				logger.Error("Failed to insert GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
+				return err
+			}
+		} else if !allGpus {
+			errMsg := fmt.Errorf("Failed to detect correct \"/dev/nvidia\" path")
+			logger.Errorf("%s", errMsg)
+			return errMsg
+		}
+
+		sawNvidia = true
+	}
+
+	if sawNvidia {
+		for _, gpu := range nvidiaDevices {
+			if shared.IsTrue(c.expandedConfig["nvidia.runtime"]) {
+				if !gpu.isCard {
+					continue
+				}
+			}
+
+			if c.deviceExistsInDevicesFolder(name, gpu.path) {
+				continue
+			}
+
+			err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, gpu.major, gpu.minor, gpu.path, false)
+			if err != nil {
+				logger.Error("Failed to insert GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
+				return err
+			}
+		}
+	}
+
+	if !found {
+		msg := "Failed to detect requested GPU device"
+		logger.Error(msg)
+		return fmt.Errorf(msg)
+	}
+
+	return nil
+}
+
+func (d *deviceGPU) Stop(c *containerLXC, name string, m types.Device) error {
+	allGpus := deviceWantsAllGPUs(m)
+	gpus, nvidiaDevices, err := deviceLoadGpu(allGpus)
+	if err != nil {
+		return err
+	}
+
+	for _, gpu := range gpus {
+		if (m["vendorid"] != "" && gpu.vendorid != m["vendorid"]) ||
+			(m["pci"] != "" && gpu.pci != m["pci"]) ||
+			(m["productid"] != "" && gpu.productid != m["productid"]) ||
+			(m["id"] != "" && gpu.id != m["id"]) {
+			continue
+		}
+
+		err := c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, gpu.major, gpu.minor, gpu.path)
+		if err != nil {
+			logger.Error("Failed to remove GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
+			return err
+		}
+
+		if !gpu.isNvidia {
+			continue
+		}
+
+		if gpu.nvidia.path != "" {
+			err = c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, gpu.nvidia.major, gpu.nvidia.minor, gpu.nvidia.path)
+			if err != nil {
+				logger.Error("Failed to remove GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
+				return err
+			}
+		} else if !allGpus {
+			errMsg := fmt.Errorf("Failed to detect correct \"/dev/nvidia\" path")
+			logger.Errorf("%s", errMsg)
+			return errMsg
+		}
+	}
+
+	nvidiaExists := false
+	for _, gpu := range gpus {
+		if gpu.nvidia.path != "" {
+			if c.deviceExistsInDevicesFolder(fmt.Sprintf("unix.%s", name), gpu.path) {
+				nvidiaExists = true
+				break
+			}
+		}
+	}
+
+	if !nvidiaExists {
+		for _, gpu := range nvidiaDevices {
+			if shared.IsTrue(c.expandedConfig["nvidia.runtime"]) {
+				if !gpu.isCard {
+					continue
+				}
+			}
+
+			if !c.deviceExistsInDevicesFolder(fmt.Sprintf("unix.%s", name), gpu.path) {
+				continue
+			}
+
+			err = c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, gpu.major, gpu.minor, gpu.path)
+			if err != nil {
+				logger.Error("Failed to remove GPU device", log.Ctx{"err": err, "gpu": gpu, "container": c.Name()})
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *deviceGPU) Update(c *containerLXC, name string, m types.Device, changed []string) error {
+	return nil
+}
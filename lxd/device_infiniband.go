@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+)
+
+// Infiniband interfaces, along with their character devices.
+type deviceInfiniband struct {
+	deviceKeys
+}
+
+func init() {
+	deviceDriverRegister("infiniband", &deviceInfiniband{deviceKeys{"hwaddr", "mtu", "name", "nictype", "parent"}})
+}
+
+func (d *deviceInfiniband) Validate(cluster *db.Cluster, m types.Device, expanded bool) error {
+	if m["nictype"] == "" {
+		return fmt.Errorf("Missing nic type")
+	}
+
+	if !shared.StringInSlice(m["nictype"], []string{"physical", "sriov"}) {
+		return fmt.Errorf("Bad nic type: %s", m["nictype"])
+	}
+
+	if m["parent"] == "" {
+		return fmt.Errorf("Missing parent for %s type nic", m["nictype"])
+	}
+
+	return nil
+}
+
+func (d *deviceInfiniband) Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	return deviceNicConfig(c, cc, name, m)
+}
+
+func (d *deviceInfiniband) Start(c *containerLXC, name string, m types.Device) error {
+	infiniband, err := deviceLoadInfiniband()
+	if err != nil {
+		return err
+	}
+
+	m, err = c.insertNetworkDevice(name, m)
+	if err != nil {
+		return err
+	}
+
+	// Plugin in all character devices
+	key := m["parent"]
+	if m["nictype"] == "sriov" {
+		key = m["host_name"]
+	}
+
+	ifDev, ok := infiniband[key]
+	if !ok {
+		return fmt.Errorf("Specified infiniband device \"%s\" not found", key)
+	}
+
+	return c.addInfinibandDevices(name, &ifDev, true)
+}
+
+func (d *deviceInfiniband) Stop(c *containerLXC, name string, m types.Device) error {
+	err := c.removeNetworkDevice(name, m)
+	if err != nil {
+		return err
+	}
+
+	return c.removeInfinibandDevices(name, m)
+}
+
+func (d *deviceInfiniband) Update(c *containerLXC, name string, m types.Device, changed []string) error {
+	return nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+)
+
+// Network interfaces, either attached to a managed network or set up from
+// one of the supported nic types.
+type deviceNic struct {
+	deviceKeys
+}
+
+func init() {
+	deviceDriverRegister("nic", &deviceNic{deviceKeys{
+		"limits.max",
+		"limits.ingress",
+		"limits.egress",
+		"host_name",
+		"hwaddr",
+		"mtu",
+		"name",
+		"network",
+		"nictype",
+		"parent",
+		"vlan",
+		"vlan.tagged",
+		"vlan.gvrp",
+		"ipv4.address",
+		"ipv6.address",
+		"security.mac_filtering",
+		"security.port_isolation",
+		"maas.subnet.ipv4",
+		"maas.subnet.ipv6",
+	}})
+}
+
+func (d *deviceNic) Validate(cluster *db.Cluster, m types.Device, expanded bool) error {
+	if m["network"] != "" {
+		if !expanded && (m["nictype"] != "" || m["parent"] != "") {
+			return fmt.Errorf("Nics attached to a network can't set nictype or parent")
+		}
+
+		resolved, err := containerDeviceNetwork(cluster, m)
+		if err != nil {
+			return err
+		}
+
+		m = resolved
+	}
+
+	if m["nictype"] == "" {
+		return fmt.Errorf("Missing nic type")
+	}
+
+	if !shared.StringInSlice(m["nictype"], []string{"bridged", "macvlan", "p2p", "physical", "sriov"}) {
+		return fmt.Errorf("Bad nic type: %s", m["nictype"])
+	}
+
+	if shared.StringInSlice(m["nictype"], []string{"bridged", "macvlan", "physical", "sriov"}) && m["parent"] == "" {
+		return fmt.Errorf("Missing parent for %s type nic", m["nictype"])
+	}
+
+	if shared.IsTrue(m["security.port_isolation"]) && m["nictype"] != "bridged" {
+		return fmt.Errorf("Port isolation is only supported on bridged nics")
+	}
+
+	if shared.IsTrue(m["vlan.gvrp"]) && (m["vlan"] == "" || !shared.StringInSlice(m["nictype"], []string{"macvlan", "physical"})) {
+		return fmt.Errorf("GVRP requires a VLAN on a macvlan or physical nic")
+	}
+
+	if m["vlan.tagged"] != "" {
+		if !shared.StringInSlice(m["nictype"], []string{"bridged", "physical"}) {
+			return fmt.Errorf("Tagged VLANs are only supported on bridged and physical nics")
+		}
+
+		err := networkValidVLANs(m["vlan.tagged"])
+		if err != nil {
+			return err
+		}
+
+		if m["vlan"] != "" && shared.StringInSlice(m["vlan"], networkParseVLANs(m["vlan.tagged"])) {
+			return fmt.Errorf("VLAN %s can't be both the VLAN and a tagged VLAN of the nic", m["vlan"])
+		}
+	}
+
+	return nil
+}
+
+func (d *deviceNic) Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	return deviceNicConfig(c, cc, name, m)
+}
+
+func (d *deviceNic) Start(c *containerLXC, name string, m types.Device) error {
+	m, err := c.insertNetworkDevice(name, m)
+	if err != nil {
+		return err
+	}
+
+	if c.networkAggregated(m) {
+		return c.setNetworkLimits(name, m)
+	}
+
+	return nil
+}
+
+func (d *deviceNic) Stop(c *containerLXC, name string, m types.Device) error {
+	return c.removeNetworkDevice(name, m)
+}
+
+func (d *deviceNic) Update(c *containerLXC, name string, m types.Device, changed []string) error {
+	needsUpdate := false
+	for _, v := range containerNetworkLimitKeys {
+		needsUpdate = shared.StringInSlice(v, changed)
+		if needsUpdate {
+			break
+		}
+	}
+
+	if needsUpdate {
+		// Refresh tc limits
+		err := c.setNetworkLimits(name, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	if shared.StringInSlice("security.port_isolation", changed) {
+		err := c.setNetworkIsolation(name, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add a nic or infiniband device to the network interfaces liblxc sets up,
+// along with the VLANs tagged on physical nics.
+func deviceNicConfig(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	// Fill in some fields from volatile
+	m, err := c.fillNetworkDevice(name, m)
+	if err != nil {
+		return err
+	}
+
+	networkKeyPrefix := "lxc.net"
+	if !util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
+		networkKeyPrefix = "lxc.network"
+	}
+
+	networkidx := c.networkIndex(name)
+
+	// Interface type specific configuration
+	if shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"}) {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.type", networkKeyPrefix, networkidx), "veth")
+		if err != nil {
+			return err
+		}
+	} else if m["nictype"] == "physical" || m["nictype"] == "sriov" {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.type", networkKeyPrefix, networkidx), "phys")
+		if err != nil {
+			return err
+		}
+	} else if m["nictype"] == "macvlan" {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.type", networkKeyPrefix, networkidx), "macvlan")
+		if err != nil {
+			return err
+		}
+
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.macvlan.mode", networkKeyPrefix, networkidx), "bridge")
+		if err != nil {
+			return err
+		}
+	}
+
+	err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.flags", networkKeyPrefix, networkidx), "up")
+	if err != nil {
+		return err
+	}
+
+	if m["nictype"] == "bridged" {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.link", networkKeyPrefix, networkidx), m["parent"])
+		if err != nil {
+			return err
+		}
+	} else if m["nictype"] == "sriov" {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.link", networkKeyPrefix, networkidx), m["host_name"])
+		if err != nil {
+			return err
+		}
+	} else if shared.StringInSlice(m["nictype"], []string{"macvlan", "physical"}) {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.link", networkKeyPrefix, networkidx), networkGetHostDevice(m["parent"], m["vlan"]))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Host Virtual NIC name
+	vethName := ""
+	if m["host_name"] != "" && m["nictype"] != "sriov" {
+		vethName = m["host_name"]
+	} else if shared.IsTrue(m["security.mac_filtering"]) {
+		// We need a known device name for MAC filtering
+		vethName = deviceNextVeth()
+	}
+
+	if vethName != "" {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.veth.pair", networkKeyPrefix, networkidx), vethName)
+		if err != nil {
+			return err
+		}
+	}
+
+	// MAC address
+	if m["hwaddr"] != "" {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.hwaddr", networkKeyPrefix, networkidx), m["hwaddr"])
+		if err != nil {
+			return err
+		}
+	}
+
+	// MTU
+	if m["mtu"] != "" {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.mtu", networkKeyPrefix, networkidx), m["mtu"])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Name
+	if m["name"] != "" {
+		err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.name", networkKeyPrefix, networkidx), m["name"])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Tagged VLANs of physical nics
+	if m["type"] != "nic" || m["nictype"] != "physical" {
+		return nil
+	}
+
+	networkidx = c.networkVLANIndex(name)
+	for _, vlan := range networkParseVLANs(m["vlan.tagged"]) {
+		items := [][]string{
+			{"type", "vlan"},
+			{"link", m["parent"]},
+			{"vlan.id", vlan},
+			{"flags", "up"},
+			{"name", fmt.Sprintf("%s.%s", m["name"], vlan)},
+		}
+
+		for _, item := range items {
+			err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.%s", networkKeyPrefix, networkidx, item[0]), item[1])
+			if err != nil {
+				return err
+			}
+		}
+
+		networkidx++
+	}
+
+	return nil
+}
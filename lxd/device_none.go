@@ -0,0 +1,37 @@
+package main
+
+import (
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+)
+
+// The none device only stops the inheritance of a device from the profiles.
+type deviceNone struct {
+	deviceKeys
+}
+
+func init() {
+	deviceDriverRegister("none", &deviceNone{})
+}
+
+func (d *deviceNone) Validate(cluster *db.Cluster, m types.Device, expanded bool) error {
+	return nil
+}
+
+func (d *deviceNone) Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	return nil
+}
+
+func (d *deviceNone) Start(c *containerLXC, name string, m types.Device) error {
+	return nil
+}
+
+func (d *deviceNone) Stop(c *containerLXC, name string, m types.Device) error {
+	return nil
+}
+
+func (d *deviceNone) Update(c *containerLXC, name string, m types.Device, changed []string) error {
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+)
+
+// Proxy devices, forwarding connections between the host and the container.
+type deviceProxy struct {
+	deviceKeys
+}
+
+func init() {
+	deviceDriverRegister("proxy", &deviceProxy{deviceKeys{"bind", "connect", "gid", "listen", "mode", "uid", "wake_on_connect"}})
+}
+
+func (d *deviceProxy) Validate(cluster *db.Cluster, m types.Device, expanded bool) error {
+	if m["listen"] == "" {
+		return fmt.Errorf("Proxy device entry is missing the required \"listen\" property.")
+	}
+
+	if m["connect"] == "" {
+		return fmt.Errorf("Proxy device entry is missing the required \"connect\" property.")
+	}
+
+	if (!strings.HasPrefix(m["listen"], "unix:") || strings.HasPrefix(m["listen"], "unix:@")) &&
+		(m["uid"] != "" || m["gid"] != "" || m["mode"] != "") {
+		return fmt.Errorf("Only proxy devices for non-abstract unix sockets can carry uid, gid, or mode properties")
+	}
+
+	if shared.IsTrue(m["wake_on_connect"]) && (m["bind"] == "container" || strings.HasPrefix(m["listen"], "udp:")) {
+		return fmt.Errorf("Only proxy devices listening on the host for TCP or unix socket connections can wake their container")
+	}
+
+	return nil
+}
+
+func (d *deviceProxy) Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	return nil
+}
+
+func (d *deviceProxy) Start(c *containerLXC, name string, m types.Device) error {
+	return c.insertProxyDevice(name, m)
+}
+
+func (d *deviceProxy) Stop(c *containerLXC, name string, m types.Device) error {
+	return c.removeProxyDevice(name)
+}
+
+func (d *deviceProxy) Update(c *containerLXC, name string, m types.Device, changed []string) error {
+	return c.updateProxyDevice(name, m)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// Only proxy devices listening on the host for TCP or unix socket connections
+// can wake their container.
+func TestDeviceProxyValidate_WakeOnConnect(t *testing.T) {
+	driver, err := deviceDriverLoad("proxy")
+	assert.NoError(t, err)
+
+	device := func(listen string, bind string) types.Device {
+		return types.Device{"type": "proxy", "listen": listen, "connect": "tcp:127.0.0.1:80", "bind": bind, "wake_on_connect": "true"}
+	}
+
+	assert.NoError(t, driver.Validate(nil, device("tcp:0.0.0.0:80", ""), false))
+	assert.NoError(t, driver.Validate(nil, device("unix:/run/web.socket", "host"), false))
+	assert.Error(t, driver.Validate(nil, device("udp:0.0.0.0:53", ""), false))
+	assert.Error(t, driver.Validate(nil, device("tcp:0.0.0.0:80", "container"), false))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+)
+
+// Character and block device nodes, created from the host device or from a
+// major and minor number.
+type deviceUnix struct {
+	deviceKeys
+}
+
+func init() {
+	keys := deviceKeys{"gid", "major", "minor", "mode", "source", "path", "required", "uid"}
+
+	deviceDriverRegister("unix-char", &deviceUnix{keys})
+	deviceDriverRegister("unix-block", &deviceUnix{keys})
+}
+
+func (d *deviceUnix) Validate(cluster *db.Cluster, m types.Device, expanded bool) error {
+	if m["source"] == "" && m["path"] == "" {
+		return fmt.Errorf("Unix device entry is missing the required \"source\" or \"path\" property.")
+	}
+
+	if (m["required"] == "" || shared.IsTrue(m["required"])) && (m["major"] == "" || m["minor"] == "") {
+		srcPath, exist := m["source"]
+		if !exist {
+			srcPath = m["path"]
+		}
+		if !shared.PathExists(srcPath) {
+			return fmt.Errorf("The device path doesn't exist on the host and major/minor wasn't specified.")
+		}
+
+		dType, _, _, err := deviceGetAttributes(srcPath)
+		if err != nil {
+			return err
+		}
+
+		if m["type"] == "unix-char" && dType != "c" {
+			return fmt.Errorf("Path specified for unix-char device is a block device.")
+		}
+
+		if m["type"] == "unix-block" && dType != "b" {
+			return fmt.Errorf("Path specified for unix-block device is a character device.")
+		}
+	}
+
+	return nil
+}
+
+func (d *deviceUnix) Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	// destination paths
+	destPath := m["path"]
+	if destPath == "" {
+		destPath = m["source"]
+	}
+
+	srcPath := m["source"]
+	if srcPath == "" {
+		srcPath = m["path"]
+	}
+
+	relativeDestPath := strings.TrimPrefix(destPath, "/")
+	sourceDevPath := filepath.Join(c.DevicesPath(), fmt.Sprintf("unix.%s.%s", strings.Replace(name, "/", "-", -1), strings.Replace(relativeDestPath, "/", "-", -1)))
+
+	// Don't add mount entry for devices that don't yet exist
+	if m["required"] != "" && !shared.IsTrue(m["required"]) && srcPath != "" && !shared.PathExists(srcPath) {
+		return nil
+	}
+
+	// inform liblxc about the mount
+	return lxcSetConfigItem(cc, "lxc.mount.entry",
+		fmt.Sprintf("%s %s %s",
+			shared.EscapePathFstab(sourceDevPath),
+			shared.EscapePathFstab(relativeDestPath),
+			"none bind,create=file"))
+}
+
+func (d *deviceUnix) Start(c *containerLXC, name string, m types.Device) error {
+	err := c.insertUnixDevice(fmt.Sprintf("unix.%s", name), m, true)
+	if err != nil {
+		if m["required"] == "" || shared.IsTrue(m["required"]) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *deviceUnix) Stop(c *containerLXC, name string, m types.Device) error {
+	prefix := fmt.Sprintf("unix.%s", name)
+	destPath := m["path"]
+	if destPath == "" {
+		destPath = m["source"]
+	}
+
+	if !c.deviceExistsInDevicesFolder(prefix, destPath) && (m["required"] != "" && !shared.IsTrue(m["required"])) {
+		return nil
+	}
+
+	return c.removeUnixDevice(prefix, m, true)
+}
+
+func (d *deviceUnix) Update(c *containerLXC, name string, m types.Device, changed []string) error {
+	return nil
+}
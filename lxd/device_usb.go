@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// USB devices, passed through by vendor and product ID.
+type deviceUSB struct {
+	deviceKeys
+}
+
+func init() {
+	deviceDriverRegister("usb", &deviceUSB{deviceKeys{"vendorid", "productid", "mode", "gid", "uid", "required"}})
+}
+
+func (d *deviceUSB) Validate(cluster *db.Cluster, m types.Device, expanded bool) error {
+	if m["vendorid"] == "" {
+		return fmt.Errorf("Missing vendorid for USB device.")
+	}
+
+	return nil
+}
+
+func (d *deviceUSB) Config(c *containerLXC, cc *lxc.Container, name string, m types.Device) error {
+	return nil
+}
+
+func (d *deviceUSB) Start(c *containerLXC, name string, m types.Device) error {
+	usbs, err := deviceLoadUsb()
+	if err != nil {
+		return err
+	}
+
+	for _, usb := range usbs {
+		if usb.vendor != m["vendorid"] || (m["productid"] != "" && usb.product != m["productid"]) {
+			continue
+		}
+
+		err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, usb.major, usb.minor, usb.path, false)
+		if err != nil {
+			logger.Error("Failed to insert usb device", log.Ctx{"err": err, "usb": usb, "container": c.Name()})
+		}
+	}
+
+	return nil
+}
+
+func (d *deviceUSB) Stop(c *containerLXC, name string, m types.Device) error {
+	usbs, err := deviceLoadUsb()
+	if err != nil {
+		return err
+	}
+
+	/* if the device isn't present, we don't need to remove it */
+	for _, usb := range usbs {
+		if usb.vendor != m["vendorid"] || (m["productid"] != "" && usb.product != m["productid"]) {
+			continue
+		}
+
+		err := c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, usb.major, usb.minor, usb.path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *deviceUSB) Update(c *containerLXC, name string, m types.Device, changed []string) error {
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/big"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
@@ -23,6 +24,7 @@ import (
 	"github.com/lxc/lxd/lxd/sys"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
 
 	log "github.com/lxc/lxd/shared/log15"
@@ -595,7 +597,12 @@ func deviceTaskBalance(s *state.State) {
 	}
 
 	// Get effective cpus list - those are all guaranteed to be online
-	effectiveCpus, err := cGroupGet("cpuset", "/", "cpuset.effective_cpus")
+	effectiveCpusKey := "cpuset.effective_cpus"
+	if s.OS.CGroupUnified {
+		effectiveCpusKey = "cpuset.cpus.effective"
+	}
+
+	effectiveCpus, err := cGroupGet("cpuset", "/", effectiveCpusKey)
 	if err != nil {
 		// Older kernel - use cpuset.cpus
 		effectiveCpus, err = cGroupGet("cpuset", "/", "cpuset.cpus")
@@ -659,6 +666,9 @@ func deviceTaskBalance(s *state.State) {
 	}
 	fixedContainers := map[int][]container{}
 	balancedContainers := map[container]int{}
+	isolatedContainers := map[container]int{}
+	isolatedFixed := map[container]bool{}
+	reservedCpus := []int{}
 	for _, name := range containers {
 		c, err := containerLoadByName(s, name)
 		if err != nil {
@@ -675,8 +685,13 @@ func deviceTaskBalance(s *state.State) {
 			continue
 		}
 
+		isolated := shared.IsTrue(conf["limits.cpu.isolated"])
+
 		count, err := strconv.Atoi(cpulimit)
-		if err == nil {
+		if err == nil && isolated {
+			// Dedicated CPUs
+			isolatedContainers[c] = min(count, len(cpus))
+		} else if err == nil {
 			// Load-balance
 			count = min(count, len(cpus))
 			balancedContainers[c] = count
@@ -691,6 +706,13 @@ func deviceTaskBalance(s *state.State) {
 					continue
 				}
 
+				if isolated {
+					isolatedFixed[c] = true
+					if !shared.IntInSlice(nr, reservedCpus) {
+						reservedCpus = append(reservedCpus, nr)
+					}
+				}
+
 				_, ok := fixedContainers[nr]
 				if ok {
 					fixedContainers[nr] = append(fixedContainers[nr], c)
@@ -701,6 +723,20 @@ func deviceTaskBalance(s *state.State) {
 		}
 	}
 
+	// Keep the other pinned containers off the CPUs dedicated to isolated
+	// ones, load-balancing those left without CPUs
+	for ctn, count := range deviceTaskEvictReserved(fixedContainers, isolatedFixed, reservedCpus) {
+		logger.Warn("balance: Container pinned to CPUs dedicated to isolated containers", log.Ctx{"name": ctn.Name()})
+		balancedContainers[ctn] = count
+	}
+
+	pinnedCpus := []int{}
+	for nr, ctns := range fixedContainers {
+		if len(ctns) > 0 && !shared.IntInSlice(nr, reservedCpus) {
+			pinnedCpus = append(pinnedCpus, nr)
+		}
+	}
+
 	// Balance things
 	pinning := map[container][]string{}
 	usage := map[int]deviceTaskCPU{}
@@ -733,8 +769,50 @@ func deviceTaskBalance(s *state.State) {
 		}
 	}
 
+	// Dedicate CPUs to the isolated containers, in a stable order so that
+	// they keep the same CPUs across runs
+	isolatedNames := []string{}
+	isolatedByName := map[string]container{}
+	for ctn := range isolatedContainers {
+		isolatedNames = append(isolatedNames, ctn.Name())
+		isolatedByName[ctn.Name()] = ctn
+	}
+	sort.Strings(isolatedNames)
+
+	for _, name := range isolatedNames {
+		ctn := isolatedByName[name]
+		count := isolatedContainers[ctn]
+
+		current := []int{}
+		value, err := ctn.CGroupGet("cpuset.cpus")
+		if err == nil {
+			current, _ = parseCpuset(strings.TrimSpace(value))
+		}
+
+		dedicated := deviceTaskIsolatedCPUs(usage, reservedCpus, pinnedCpus, current, count)
+		if len(dedicated) < count {
+			logger.Warn("balance: Not enough CPUs left to isolate the container", log.Ctx{"name": name, "requested": count, "dedicated": len(dedicated)})
+		}
+
+		if len(dedicated) == 0 {
+			balancedContainers[ctn] = count
+			continue
+		}
+
+		for _, id := range dedicated {
+			pinning[ctn] = append(pinning[ctn], usage[id].strId)
+			*usage[id].count += 1
+		}
+		reservedCpus = append(reservedCpus, dedicated...)
+	}
+
+	// Balance the other containers on the CPUs which aren't dedicated
 	sortedUsage := make(deviceTaskCPUs, 0)
-	for _, value := range usage {
+	for id, value := range usage {
+		if shared.IntInSlice(id, reservedCpus) {
+			continue
+		}
+
 		sortedUsage = append(sortedUsage, value)
 	}
 
@@ -772,6 +850,257 @@ func deviceTaskBalance(s *state.State) {
 	}
 }
 
+// Remove the CPUs reserved for isolated containers from the pinning of the
+// other containers. The containers left without any CPU are removed from the
+// pinning altogether and returned along with the number of CPUs they had, to
+// be load-balanced instead.
+func deviceTaskEvictReserved(fixed map[int][]container, isolated map[container]bool, reserved []int) map[container]int {
+	evicted := map[container]int{}
+	for _, nr := range reserved {
+		kept := []container{}
+		for _, ctn := range fixed[nr] {
+			if isolated[ctn] {
+				kept = append(kept, ctn)
+				continue
+			}
+
+			evicted[ctn]++
+		}
+
+		if len(fixed[nr]) > 0 {
+			fixed[nr] = kept
+		}
+	}
+
+	for _, ctns := range fixed {
+		for _, ctn := range ctns {
+			delete(evicted, ctn)
+		}
+	}
+
+	return evicted
+}
+
+// Pick the CPUs to dedicate to an isolated container among those which aren't
+// reserved yet nor pinned by other containers, preferring the ones it already
+// runs on and then the ones with the fewest containers. One CPU is always left
+// to the other containers.
+func deviceTaskIsolatedCPUs(usage map[int]deviceTaskCPU, reserved []int, pinned []int, current []int, count int) []int {
+	candidates := []int{}
+	for id := range usage {
+		if !shared.IntInSlice(id, reserved) && !shared.IntInSlice(id, pinned) {
+			candidates = append(candidates, id)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+
+		if shared.IntInSlice(a, current) != shared.IntInSlice(b, current) {
+			return shared.IntInSlice(a, current)
+		}
+
+		if *usage[a].count != *usage[b].count {
+			return *usage[a].count < *usage[b].count
+		}
+
+		return a < b
+	})
+
+	if count > len(usage)-len(reserved)-1 {
+		count = len(usage) - len(reserved) - 1
+	}
+
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+
+	if count <= 0 {
+		return nil
+	}
+
+	return candidates[:count]
+}
+
+// A change of the MTU of a host interface.
+type deviceLinkMTU struct {
+	name string
+	mtu  uint32
+}
+
+// Return the current MTUs of the host interfaces.
+func deviceLinkMTUs() map[string]uint32 {
+	mtus := map[string]uint32{}
+	ifaces, err := net.Interfaces()
+	if err == nil {
+		for _, iface := range ifaces {
+			mtus[iface.Name] = uint32(iface.MTU)
+		}
+	}
+
+	return mtus
+}
+
+// Listen to rtnetlink link messages, reporting the changes of MTU of host
+// interfaces.
+func deviceLinkListener() (chan deviceLinkMTU, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+
+	nl := syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: 1 << (syscall.RTNLGRP_LINK - 1),
+	}
+
+	err = syscall.Bind(fd, &nl)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	// Current MTUs, to only report actual changes
+	mtus := deviceLinkMTUs()
+
+	ch := make(chan deviceLinkMTU, 16)
+
+	go func() {
+		defer syscall.Close(fd)
+
+		b := make([]byte, 65536)
+		for {
+			n, err := syscall.Read(fd, b)
+			if err == syscall.EINTR {
+				continue
+			}
+
+			// Messages were lost, start over from the current MTUs
+			if err == syscall.ENOBUFS {
+				logger.Warnf("The rtnetlink listener fell behind, resynchronizing the MTUs")
+				mtus = deviceLinkMTUs()
+				continue
+			}
+
+			// Anything else won't go away by retrying. The channel
+			// is left open, so that it just never receives again.
+			if err != nil {
+				logger.Errorf("Stopping the rtnetlink listener: %v", err)
+				return
+			}
+
+			msgs, err := syscall.ParseNetlinkMessage(b[:n])
+			if err != nil {
+				continue
+			}
+
+			for _, msg := range msgs {
+				if msg.Header.Type == syscall.RTM_DELLINK {
+					attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+					if err != nil {
+						continue
+					}
+
+					for _, attr := range attrs {
+						if attr.Attr.Type == syscall.IFLA_IFNAME {
+							delete(mtus, string(bytes.TrimRight(attr.Value, "\x00")))
+						}
+					}
+
+					continue
+				}
+
+				if msg.Header.Type != syscall.RTM_NEWLINK {
+					continue
+				}
+
+				attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+				if err != nil {
+					continue
+				}
+
+				name := ""
+				mtu := uint32(0)
+				for _, attr := range attrs {
+					switch attr.Attr.Type {
+					case syscall.IFLA_IFNAME:
+						name = string(bytes.TrimRight(attr.Value, "\x00"))
+					case syscall.IFLA_MTU:
+						if len(attr.Value) >= 4 {
+							mtu = *(*uint32)(unsafe.Pointer(&attr.Value[0]))
+						}
+					}
+				}
+
+				if name == "" || mtu == 0 {
+					continue
+				}
+
+				old, ok := mtus[name]
+				mtus[name] = mtu
+				if !ok || old == mtu {
+					continue
+				}
+
+				ch <- deviceLinkMTU{name: name, mtu: mtu}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Propagate the new MTU of a host interface to the macvlan and VLAN
+// interfaces of running containers which don't have their MTU set.
+func deviceNetworkMTU(s *state.State, netif string, mtu uint32) {
+	containers, err := s.Cluster.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return
+	}
+
+	for _, name := range containers {
+		c, err := containerLoadByName(s, name)
+		if err != nil || !c.IsRunning() {
+			continue
+		}
+
+		for _, k := range c.ExpandedDevices().DeviceNames() {
+			m := c.ExpandedDevices()[k]
+			if m["type"] != "nic" || m["mtu"] != "" {
+				continue
+			}
+
+			// Host side VLAN interfaces of macvlan nics follow their
+			// parent, their own change then updating the container
+			if m["nictype"] == "macvlan" && m["vlan"] != "" && m["parent"] == netif {
+				_, err := shared.RunCommand("ip", "link", "set", "dev", networkGetHostDevice(m["parent"], m["vlan"]), "mtu", fmt.Sprintf("%d", mtu))
+				if err != nil {
+					logger.Warn("Failed to update VLAN interface MTU", log.Ctx{"container": name, "device": k, "err": err})
+				}
+
+				continue
+			}
+
+			follows := false
+			switch m["nictype"] {
+			case "macvlan":
+				follows = networkGetHostDevice(m["parent"], m["vlan"]) == netif
+			case "physical":
+				follows = m["vlan"] != "" && m["parent"] == netif
+			}
+
+			if !follows {
+				continue
+			}
+
+			err = c.(*containerLXC).setNetworkMTU(k, m, mtu)
+			if err != nil {
+				logger.Warn("Failed to update container interface MTU", log.Ctx{"container": name, "device": k, "err": err})
+			}
+		}
+	}
+}
+
 func deviceNetworkPriority(s *state.State, netif string) {
 	// Don't bother running when CGroup support isn't there
 	if !s.OS.CGroupNetPrioController {
@@ -869,6 +1198,12 @@ func deviceEventListener(s *state.State) {
 		return
 	}
 
+	// MTU tracking is optional, a nil channel never receives
+	chLinkMTU, err := deviceLinkListener()
+	if err != nil {
+		logger.Errorf("scheduler: Couldn't setup rtnetlink listener: %v", err)
+	}
+
 	for {
 		select {
 		case e := <-chNetlinkCPU:
@@ -889,15 +1224,16 @@ func deviceEventListener(s *state.State) {
 				continue
 			}
 
-			if !s.OS.CGroupNetPrioController {
-				continue
-			}
-
+			// deviceNetworkPriority is a no-op without the net_prio
+			// controller, as on the unified hierarchy
 			logger.Debugf("Scheduler: network: %s has been added: updating network priorities", e[0])
 			deviceNetworkPriority(s, e[0])
 			networkAutoAttach(s.Cluster, e[0])
 		case e := <-chUSB:
 			deviceUSBEvent(s, e)
+		case e := <-chLinkMTU:
+			logger.Debugf("Scheduler: network: %s MTU is now %d: updating child interfaces", e.name, e.mtu)
+			deviceNetworkMTU(s, e.name, e.mtu)
 		case e := <-deviceSchedRebalance:
 			if len(e) != 3 {
 				logger.Errorf("Scheduler: received an invalid rebalance event")
@@ -940,6 +1276,32 @@ func deviceIsBlockdev(path string) bool {
 	return false
 }
 
+// Detect the filesystem of ISO and squashfs image files, returning an empty
+// string for anything else.
+func deviceImageFSType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	magic := make([]byte, 5)
+
+	// squashfs superblocks start with "hsqs"
+	_, err = f.ReadAt(magic[:4], 0)
+	if err == nil && string(magic[:4]) == "hsqs" {
+		return "squashfs"
+	}
+
+	// ISO 9660 volume descriptors start at sector 16 with "CD001"
+	_, err = f.ReadAt(magic, 0x8001)
+	if err == nil && string(magic) == "CD001" {
+		return "iso9660"
+	}
+
+	return ""
+}
+
 func deviceModeOct(strmode string) (int, error) {
 	// Default mode
 	if strmode == "" {
@@ -1076,6 +1438,206 @@ func deviceMountDisk(srcPath string, dstPath string, readonly bool, recursive bo
 	return nil
 }
 
+// Mount a host directory through bindfs, shifting the ownership of its files
+// into the range of the given idmap in userspace. This works on filesystems
+// which can't be shifted in the kernel.
+func deviceMountDiskFUSE(srcPath string, dstPath string, readonly bool, idmapset *idmap.IdmapSet) error {
+	args := []string{"-o", "allow_other"}
+
+	if idmapset != nil {
+		uid, gid := idmapset.ShiftIntoNs(0, 0)
+		if uid < 0 || gid < 0 {
+			return fmt.Errorf("The container's root user isn't mapped")
+		}
+
+		args = append(args, fmt.Sprintf("--uid-offset=%d", uid), fmt.Sprintf("--gid-offset=%d", gid))
+	}
+
+	if readonly {
+		args = append(args, "-r")
+	}
+
+	args = append(args, srcPath, dstPath)
+
+	_, err := shared.RunCommand("bindfs", args...)
+	if err != nil {
+		return fmt.Errorf("Unable to mount %s at %s through bindfs: %s", srcPath, dstPath, err)
+	}
+
+	return nil
+}
+
+// Check whether a disk source refers to a Ceph RBD image or CephFS filesystem
+func deviceIsCephSource(source string) bool {
+	return strings.HasPrefix(source, "ceph:") || strings.HasPrefix(source, "cephfs:")
+}
+
+// Parse a ceph:<pool>/<image> or cephfs:<fs>/<path> disk source into its
+// scheme, pool or filesystem name and image name or path.
+func deviceParseCephSource(source string) (string, string, string, error) {
+	fields := strings.SplitN(source, ":", 2)
+	if len(fields) != 2 || !shared.StringInSlice(fields[0], []string{"ceph", "cephfs"}) {
+		return "", "", "", fmt.Errorf("Invalid Ceph source: %s", source)
+	}
+
+	scheme := fields[0]
+	parts := strings.SplitN(fields[1], "/", 2)
+	name := parts[0]
+	path := ""
+	if len(parts) == 2 {
+		path = parts[1]
+	}
+
+	if name == "" {
+		return "", "", "", fmt.Errorf("Missing pool or filesystem name in Ceph source: %s", source)
+	}
+
+	if scheme == "ceph" && (path == "" || strings.Contains(path, "/")) {
+		return "", "", "", fmt.Errorf("Ceph sources must be in the form ceph:<pool>/<image>: %s", source)
+	}
+
+	return scheme, name, path, nil
+}
+
+// Map an existing RBD image, returning the path of its block device
+func deviceCephRBDMap(clusterName string, userName string, poolName string, imageName string, readonly bool) (string, error) {
+	args := []string{
+		"--id", userName,
+		"--cluster", clusterName,
+		"--pool", poolName,
+		"map", imageName,
+	}
+
+	if readonly {
+		args = append(args, "--read-only")
+	}
+
+	devPath, err := shared.RunCommand("rbd", args...)
+	if err != nil {
+		return "", err
+	}
+
+	idx := strings.Index(devPath, "/dev/rbd")
+	if idx < 0 {
+		return "", fmt.Errorf("Failed to detect mapped device path")
+	}
+
+	return strings.TrimSpace(devPath[idx:]), nil
+}
+
+// Unmap the RBD block device at the given path
+func deviceCephRBDUnmap(devPath string) error {
+	_, err := shared.RunCommand("rbd", "unmap", devPath)
+	return err
+}
+
+// Read a value from a Ceph configuration or keyring file
+func deviceCephConfigValue(path string, keys ...string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		if shared.StringInSlice(strings.TrimSpace(fields[0]), keys) {
+			return strings.TrimSpace(fields[1]), nil
+		}
+	}
+
+	return "", fmt.Errorf("No %s found in %s", keys[0], path)
+}
+
+// Get the source and options to mount a CephFS filesystem with the kernel
+// client, using the monitors and key from the host's Ceph configuration.
+func deviceCephFSMountOptions(clusterName string, userName string, fsName string, path string) (string, string, error) {
+	value, err := deviceCephConfigValue(fmt.Sprintf("/etc/ceph/%s.conf", clusterName), "mon host", "mon_host")
+	if err != nil {
+		return "", "", err
+	}
+
+	// Monitors may be listed with their messenger versions, the kernel
+	// client only speaks v1
+	monitors := []string{}
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		entry = strings.Trim(entry, "[]")
+		if strings.HasPrefix(entry, "v2:") {
+			continue
+		}
+
+		entry = strings.TrimPrefix(entry, "v1:")
+		idx := strings.Index(entry, "/")
+		if idx >= 0 {
+			entry = entry[:idx]
+		}
+
+		monitors = append(monitors, entry)
+	}
+
+	if len(monitors) == 0 {
+		return "", "", fmt.Errorf("No usable Ceph monitors found for cluster %s", clusterName)
+	}
+
+	secret, err := deviceCephConfigValue(fmt.Sprintf("/etc/ceph/%s.client.%s.keyring", clusterName, userName), "key")
+	if err != nil {
+		return "", "", err
+	}
+
+	source := fmt.Sprintf("%s:/%s", strings.Join(monitors, ","), path)
+	options := fmt.Sprintf("name=%s,secret=%s,mds_namespace=%s", userName, secret, fsName)
+
+	return source, options, nil
+}
+
+// Get the device mounted at the given path, if any
+func deviceMountSource(path string) string {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		rows := strings.Fields(scanner.Text())
+		if len(rows) < 10 || rows[4] != path {
+			continue
+		}
+
+		// Go backward to avoid problems with optional fields
+		return rows[len(rows)-2]
+	}
+
+	return ""
+}
+
+// Convert a limits.disk.priority value (0 to 10, defaulting to 5) to a blkio
+// weight.
+func deviceParseDiskPriority(diskPriority string) (int, error) {
+	priority := 5
+	if diskPriority != "" {
+		var err error
+		priority, err = strconv.Atoi(diskPriority)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	// Minimum valid value is 10
+	weight := priority * 100
+	if weight == 0 {
+		weight = 10
+	}
+
+	return weight, nil
+}
+
 func deviceParseCPU(cpuAllowance string, cpuPriority string) (string, string, string, error) {
 	var err error
 
@@ -1857,23 +2419,17 @@ func deviceInotifyDirDeleteEvent(s *state.State, target *sys.InotifyTargetInfo)
 }
 
 func deviceInotifyDirRescan(s *state.State) {
-	containers, err := s.Cluster.ContainersList(db.CTypeRegular)
+	containers, err := containerLoadNodeAllLenient(s)
 	if err != nil {
 		logger.Errorf("Failed to load containers: %s", err)
 		return
 	}
 
-	for _, name := range containers {
-		containerIf, err := containerLoadByName(s, name)
-		if err != nil {
-			logger.Errorf("Failed to load container \"%s\": %s", name, err)
-			continue
-		}
-
+	for _, containerIf := range containers {
 		c, ok := containerIf.(*containerLXC)
 		if !ok {
 			logger.Errorf("Received device event on non-LXC container")
-			return
+			continue
 		}
 
 		if !c.IsRunning() {
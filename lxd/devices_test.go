@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Build the CPU usage of the balancer from the number of containers on each
+// CPU.
+func deviceTaskTestUsage(counts map[int]int) map[int]deviceTaskCPU {
+	usage := map[int]deviceTaskCPU{}
+	for id, count := range counts {
+		count := count
+		usage[id] = deviceTaskCPU{id: id, strId: fmt.Sprintf("%d", id), count: &count}
+	}
+
+	return usage
+}
+
+// Isolated containers get CPUs which are neither reserved nor pinned,
+// preferably the ones they already use, and always leave one CPU to others.
+func TestDeviceTaskIsolatedCPUs(t *testing.T) {
+	usage := deviceTaskTestUsage(map[int]int{0: 0, 1: 2, 2: 0, 3: 1, 4: 0, 5: 0})
+
+	assert.Equal(t, []int{3, 0}, deviceTaskIsolatedCPUs(usage, nil, nil, []int{3}, 2))
+	assert.Equal(t, []int{4, 5}, deviceTaskIsolatedCPUs(usage, []int{0}, []int{2}, nil, 2))
+	assert.Equal(t, []int{0}, deviceTaskIsolatedCPUs(usage, nil, []int{1, 2, 3, 4, 5}, nil, 3))
+	assert.Equal(t, []int{4}, deviceTaskIsolatedCPUs(usage, []int{0, 1, 2, 3}, nil, nil, 4))
+	assert.Nil(t, deviceTaskIsolatedCPUs(usage, []int{0, 1, 2, 3, 4}, nil, nil, 1))
+}
+
+// Pinned containers lose the CPUs reserved for isolated containers, and get
+// load-balanced when they're left without any.
+func TestDeviceTaskEvictReserved(t *testing.T) {
+	c1 := &containerLXC{name: "c1"}
+	c2 := &containerLXC{name: "c2"}
+	c3 := &containerLXC{name: "c3"}
+
+	fixed := map[int][]container{
+		0: {c1},
+		1: {c1, c2, c3},
+		2: {c3},
+	}
+
+	evicted := deviceTaskEvictReserved(fixed, map[container]bool{c1: true}, []int{0, 1})
+	assert.Equal(t, map[container]int{c2: 1}, evicted)
+	assert.Equal(t, map[int][]container{0: {c1}, 1: {c1}, 2: {c3}}, fixed)
+}
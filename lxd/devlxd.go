@@ -304,7 +304,12 @@ type ConnPidMapper struct {
 }
 
 func (m *ConnPidMapper) ConnStateHandler(conn net.Conn, state http.ConnState) {
-	unixConn := conn.(*net.UnixConn)
+	// Only unix socket connections have credentials
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return
+	}
+
 	switch state {
 	case http.StateNew:
 		cred, err := getCred(unixConn)
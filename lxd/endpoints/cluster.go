@@ -0,0 +1,83 @@
+package endpoints
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// ClusterAddress returns the network address of the cluster endpoint, or the
+// one of the network endpoint if there's no separate cluster endpoint.
+func (e *Endpoints) ClusterAddress() string {
+	e.mu.RLock()
+	listener := e.listeners[cluster]
+	e.mu.RUnlock()
+
+	if listener == nil {
+		return e.NetworkAddress()
+	}
+	return listener.Addr().String()
+}
+
+// ClusterUpdateAddress updates the address for the cluster endpoint, shutting
+// it down and restarting it. If the address is empty or the same as the one
+// of the network endpoint, the cluster endpoint is just shut down.
+func (e *Endpoints) ClusterUpdateAddress(address string) error {
+	if address != "" {
+		address = util.CanonicalNetworkAddress(address)
+	}
+
+	networkAddress := e.NetworkAddress()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	listener := e.listeners[cluster]
+	if listener != nil && listener.Addr().String() == address {
+		return nil
+	}
+
+	logger.Infof("Update cluster address")
+
+	// Close the previous socket
+	e.closeListener(cluster)
+
+	if address == "" || address == networkAddress {
+		return nil
+	}
+
+	inner, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot listen on cluster https socket: %v", err)
+	}
+
+	e.listeners[cluster] = clusterTLSListener(inner, e.cert)
+	e.serveHTTP(cluster)
+
+	return nil
+}
+
+// Create a new net.Listener bound to the tcp socket of the cluster endpoint.
+func clusterCreateListener(address string, cert *shared.CertInfo) net.Listener {
+	listener, err := net.Listen("tcp", util.CanonicalNetworkAddress(address))
+	if err != nil {
+		logger.Error("Cannot listen on cluster https socket, skipping...", log.Ctx{"err": err})
+		return nil
+	}
+	return clusterTLSListener(listener, cert)
+}
+
+// Only cluster members and trusted clients talk to the cluster endpoint, so
+// its TLS handshake fails right away for clients without a certificate.
+func clusterTLSListener(inner net.Listener, cert *shared.CertInfo) *networkListener {
+	listener := &networkListener{
+		Listener:          inner,
+		requireClientCert: true,
+	}
+	listener.Config(cert)
+	return listener
+}
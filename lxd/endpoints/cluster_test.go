@@ -0,0 +1,56 @@
+package endpoints_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// If a cluster address different from the network one is set, a separate
+// TCP socket is created for it, which requires a client certificate.
+func TestEndpoints_ClusterCreateTCPSocket(t *testing.T) {
+	endpoints, config, cleanup := newEndpoints(t)
+	defer cleanup()
+
+	config.NetworkAddress = "127.0.0.1:0"
+	config.ClusterAddress = "localhost:0"
+	require.NoError(t, endpoints.Up(config))
+
+	address := endpoints.ClusterAddress()
+	assert.NotEqual(t, endpoints.NetworkAddress(), address)
+
+	assert.Error(t, httpGetOverTLSSocket(address, config.Cert))
+	assert.NoError(t, httpGetOverTLSSocketWithClientCert(address, config.Cert))
+}
+
+// When the cluster address is the same as the network one, no separate socket
+// is created.
+func TestEndpoints_ClusterUpdateAddress(t *testing.T) {
+	endpoints, config, cleanup := newEndpoints(t)
+	defer cleanup()
+
+	config.NetworkAddress = "127.0.0.1:0"
+	require.NoError(t, endpoints.Up(config))
+
+	require.NoError(t, endpoints.ClusterUpdateAddress("localhost:0"))
+	assert.NotEqual(t, endpoints.NetworkAddress(), endpoints.ClusterAddress())
+
+	require.NoError(t, endpoints.ClusterUpdateAddress(""))
+	assert.Equal(t, endpoints.NetworkAddress(), endpoints.ClusterAddress())
+}
+
+// Perform an HTTP GET "/" over TLS, presenting the server certificate as
+// client certificate too, like cluster members do.
+func httpGetOverTLSSocketWithClientCert(addr string, cert *shared.CertInfo) error {
+	tlsConfig, err := shared.GetTLSConfigMem(string(cert.PublicKey()), string(cert.PrivateKey()), "", string(cert.PublicKey()), false)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	_, err = client.Get(fmt.Sprintf("https://%s/", addr))
+	return err
+}
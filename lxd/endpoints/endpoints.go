@@ -46,6 +46,13 @@ type Config struct {
 	//
 	// It can be updated after the endpoints are up using UpdateNetworkAddress().
 	NetworkAddress string
+
+	// ClusterAddress sets the address for the cluster endpoint, used for
+	// the traffic between cluster members. If not set or equal to the
+	// network address, the network endpoint handles that traffic too.
+	//
+	// It can be updated after the endpoints are up using ClusterUpdateAddress().
+	ClusterAddress string
 }
 
 // Up brings up all applicable LXD endpoints and starts accepting HTTP
@@ -86,6 +93,13 @@ type Config struct {
 //
 // The network endpoint socket will use TLS encryption, using the certificate
 // keypair and CA passed via config.Cert.
+//
+// cluster endpoint (TCP socket with TLS)
+// --------------------------------------
+//
+// If a cluster address different from the network one was set via
+// config.ClusterAddress, create a network socket bound to it. It uses the same
+// certificate as the network endpoint, but requires clients to present one.
 func Up(config *Config) (*Endpoints, error) {
 	if config.Dir == "" {
 		return nil, fmt.Errorf("no directory configured")
@@ -140,6 +154,7 @@ func (e *Endpoints) up(config *Config) error {
 		devlxd:  config.DevLxdServer,
 		local:   config.RestServer,
 		network: config.RestServer,
+		cluster: config.RestServer,
 	}
 	e.cert = config.Cert
 
@@ -177,12 +192,18 @@ func (e *Endpoints) up(config *Config) error {
 		e.listeners[network] = networkCreateListener(config.NetworkAddress, e.cert)
 	}
 
+	if config.ClusterAddress != "" && util.CanonicalNetworkAddress(config.ClusterAddress) != util.CanonicalNetworkAddress(config.NetworkAddress) {
+		// Errors here are not fatal and are just logged.
+		e.listeners[cluster] = clusterCreateListener(config.ClusterAddress, e.cert)
+	}
+
 	logger.Infof("Starting /dev/lxd handler:")
 	e.serveHTTP(devlxd)
 
 	logger.Infof("REST API daemon:")
 	e.serveHTTP(local)
 	e.serveHTTP(network)
+	e.serveHTTP(cluster)
 
 	return nil
 }
@@ -193,7 +214,11 @@ func (e *Endpoints) Down() error {
 	defer e.mu.Unlock()
 
 	logger.Infof("Stopping REST API handler:")
-	err := e.closeListener(network)
+	err := e.closeListener(cluster)
+	if err != nil {
+		return err
+	}
+	err = e.closeListener(network)
 	if err != nil {
 		return err
 	}
@@ -283,6 +308,7 @@ const (
 	local kind = iota
 	devlxd
 	network
+	cluster
 )
 
 // Human-readable descriptions of the various kinds of endpoints.
@@ -290,4 +316,5 @@ var descriptions = map[kind]string{
 	local:   "Unix socket",
 	devlxd:  "devlxd socket",
 	network: "TCP socket",
+	cluster: "cluster TCP socket",
 }
@@ -2,6 +2,7 @@ package endpoints
 
 import (
 	"net"
+	"os"
 )
 
 // Create a new net.Listener bound to the unix socket of the local endpoint.
@@ -46,3 +47,23 @@ func localSetAccess(path string, group string) error {
 
 	return nil
 }
+
+// LocalUpdatePermissions changes the file mode of the local endpoint unix
+// socket file. When the socket is open to all users, access has to be
+// restricted by the HTTP server using the credentials of the clients.
+func (e *Endpoints) LocalUpdatePermissions(open bool) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	listener := e.listeners[local]
+	if listener == nil {
+		return nil
+	}
+
+	mode := os.FileMode(0660)
+	if open {
+		mode = 0666
+	}
+
+	return socketUnixSetPermissions(listener.Addr().String(), mode)
+}
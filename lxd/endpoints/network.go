@@ -128,11 +128,13 @@ func (e *Endpoints) NetworkUpdateCert(cert *shared.CertInfo) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.cert = cert
-	listener, ok := e.listeners[network]
-	if !ok {
-		return
+	for _, kind := range []kind{network, cluster} {
+		listener, ok := e.listeners[kind]
+		if !ok || listener == nil {
+			continue
+		}
+		listener.(*networkListener).Config(cert)
 	}
-	listener.(*networkListener).Config(cert)
 }
 
 // Create a new net.Listener bound to the tcp socket of the network endpoint.
@@ -152,6 +154,8 @@ type networkListener struct {
 	net.Listener
 	mu     sync.RWMutex
 	config *tls.Config
+
+	requireClientCert bool // Refuse clients without a certificate.
 }
 
 func networkTLSListener(inner net.Listener, cert *shared.CertInfo) *networkListener {
@@ -178,6 +182,9 @@ func (l *networkListener) Accept() (net.Conn, error) {
 // Config safely swaps the underlying TLS configuration.
 func (l *networkListener) Config(cert *shared.CertInfo) {
 	config := util.ServerTLSConfig(cert)
+	if l.requireClientCert {
+		config.ClientAuth = tls.RequireAnyClientCert
+	}
 
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -68,8 +69,38 @@ type eventListener struct {
 	// nodes. It only used by listeners created internally by LXD nodes
 	// connecting to other LXD nodes to get their local events only.
 	noForward bool
+
+	// Events waiting to be sent, in order, along with the sequence number
+	// of the last one queued.
+	queue     []eventQueued
+	queueLock sync.Mutex
+	queueWake chan struct{}
+	sequence  int64
+
+	// Set once the listener fell too far behind, it then gets disconnected
+	// rather than have events silently dropped.
+	overflow bool
+
+	// If set, the queued events are sent together as a single message at
+	// most once per interval.
+	batch time.Duration
 }
 
+type eventQueued struct {
+	event shared.Jmap
+
+	// Action and source of lifecycle events, used for coalescing
+	key string
+}
+
+// Largest interval at which event batches can be requested
+const eventsBatchMax = 10 * time.Second
+
+// Largest number of events queued for a listener, and how long sending them
+// may take, before the listener is considered too slow and disconnected.
+const eventsQueueMax = 1000
+const eventsWriteTimeout = 30 * time.Second
+
 type eventsServe struct {
 	req *http.Request
 }
@@ -88,6 +119,11 @@ func eventsSocket(r *http.Request, w http.ResponseWriter) error {
 		typeStr = "logging,operation,lifecycle"
 	}
 
+	batch, err := eventsBatchInterval(r)
+	if err != nil {
+		return err
+	}
+
 	c, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return err
@@ -98,6 +134,8 @@ func eventsSocket(r *http.Request, w http.ResponseWriter) error {
 		connection:   c,
 		id:           uuid.NewRandom().String(),
 		messageTypes: strings.Split(typeStr, ","),
+		queueWake:    make(chan struct{}, 1),
+		batch:        batch,
 	}
 
 	// If this request is an internal one initiated by another node wanting
@@ -111,12 +149,34 @@ func eventsSocket(r *http.Request, w http.ResponseWriter) error {
 
 	logger.Debugf("New event listener: %s", listener.id)
 
+	go listener.run()
+
 	<-listener.active
 
 	return nil
 }
 
+// Return the interval at which the events should be batched, if requested.
+func eventsBatchInterval(r *http.Request) (time.Duration, error) {
+	batchStr := r.FormValue("batch")
+	if batchStr == "" {
+		return 0, nil
+	}
+
+	batch, err := strconv.Atoi(batchStr)
+	if err != nil || batch < 0 || time.Duration(batch)*time.Millisecond > eventsBatchMax {
+		return 0, fmt.Errorf("Invalid batch interval: %s", batchStr)
+	}
+
+	return time.Duration(batch) * time.Millisecond, nil
+}
+
 func eventsGet(d *Daemon, r *http.Request) Response {
+	_, err := eventsBatchInterval(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	return &eventsServe{req: r}
 }
 
@@ -132,11 +192,21 @@ func eventSend(eventType string, eventMessage interface{}) error {
 }
 
 func eventBroadcast(event shared.Jmap) error {
-	body, err := json.Marshal(event)
+	// The metadata is the same for all listeners, only encode it once
+	metadata, err := json.Marshal(event["metadata"])
 	if err != nil {
 		return err
 	}
 
+	key := ""
+	if event["type"] == "lifecycle" {
+		lifecycle := api.EventLifecycle{}
+		err := json.Unmarshal(metadata, &lifecycle)
+		if err == nil {
+			key = fmt.Sprintf("%s %s", lifecycle.Action, lifecycle.Source)
+		}
+	}
+
 	_, isForward := event["node"]
 	eventsLock.Lock()
 	listeners := eventListeners
@@ -149,39 +219,134 @@ func eventBroadcast(event shared.Jmap) error {
 			continue
 		}
 
-		go func(listener *eventListener, body []byte) {
-			// Check that the listener still exists
-			if listener == nil {
-				return
+		queued := shared.Jmap{}
+		for k, v := range event {
+			queued[k] = v
+		}
+		queued["metadata"] = json.RawMessage(metadata)
+
+		listener.enqueue(eventQueued{event: queued, key: key})
+	}
+	eventsLock.Unlock()
+
+	return nil
+}
+
+// Number the given event and queue it for sending, unless the listener is
+// too far behind.
+func (l *eventListener) enqueue(queued eventQueued) {
+	l.queueLock.Lock()
+	if len(l.queue) >= eventsQueueMax {
+		l.overflow = true
+	} else if !l.overflow {
+		l.sequence++
+		queued.event["sequence"] = l.sequence
+		l.queue = append(l.queue, queued)
+	}
+	l.queueLock.Unlock()
+
+	select {
+	case l.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// Send the queued events, in order, until the connection fails.
+func (l *eventListener) run() {
+	for range l.queueWake {
+		// Give the batch a chance to fill up
+		if l.batch > 0 {
+			time.Sleep(l.batch)
+		}
+
+		l.queueLock.Lock()
+		queue := l.queue
+		l.queue = nil
+		overflow := l.overflow
+		l.queueLock.Unlock()
+
+		if overflow {
+			logger.Warn("Disconnecting slow event listener", log.Ctx{"listener": l.id, "queued": eventsQueueMax})
+			l.disconnect()
+			return
+		}
+
+		var err error
+		if l.batch > 0 {
+			err = l.write(eventsCoalesce(queue))
+		} else {
+			for _, queued := range queue {
+				err = l.write(queued.event)
+				if err != nil {
+					break
+				}
 			}
+		}
+
+		if err != nil {
+			l.disconnect()
+			return
+		}
+	}
+}
+
+func (l *eventListener) disconnect() {
+	// Remove the listener from the list
+	eventsLock.Lock()
+	delete(eventListeners, l.id)
+	eventsLock.Unlock()
 
-			// Ensure there is only a single even going out at the time
-			listener.lock.Lock()
-			defer listener.lock.Unlock()
+	// Disconnect the listener
+	l.lock.Lock()
+	l.connection.Close()
+	l.active <- false
+	l.done = true
+	l.lock.Unlock()
+	logger.Debugf("Disconnected event listener: %s", l.id)
+}
+
+func (l *eventListener) write(message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
 
-			// Make sure we're not done already
-			if listener.done {
-				return
+	// Don't let a listener which stopped reading hold its queue forever
+	l.connection.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+
+	return l.connection.WriteMessage(websocket.TextMessage, body)
+}
+
+// Collapse the lifecycle events of a batch which share the same action and
+// source into the last one of them, recording how many it stands for.
+func eventsCoalesce(queue []eventQueued) []shared.Jmap {
+	counts := map[string]int{}
+	last := map[string]int{}
+	for i, queued := range queue {
+		if queued.key == "" {
+			continue
+		}
+
+		counts[queued.key]++
+		last[queued.key] = i
+	}
+
+	events := []shared.Jmap{}
+	for i, queued := range queue {
+		if queued.key != "" {
+			if last[queued.key] != i {
+				continue
 			}
 
-			err = listener.connection.WriteMessage(websocket.TextMessage, body)
-			if err != nil {
-				// Remove the listener from the list
-				eventsLock.Lock()
-				delete(eventListeners, listener.id)
-				eventsLock.Unlock()
-
-				// Disconnect the listener
-				listener.connection.Close()
-				listener.active <- false
-				listener.done = true
-				logger.Debugf("Disconnected event listener: %s", listener.id)
+			if counts[queued.key] > 1 {
+				queued.event["coalesced"] = counts[queued.key]
 			}
-		}(listener, body)
+		}
+
+		events = append(events, queued.event)
 	}
-	eventsLock.Unlock()
 
-	return nil
+	return events
 }
 
 // Forward to the local events dispatcher an event received from another node .
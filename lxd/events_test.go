@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Lifecycle events with the same action and source are collapsed into the
+// last one of them, other events are kept as is.
+func TestEventsCoalesce(t *testing.T) {
+	queue := []eventQueued{
+		{event: shared.Jmap{"sequence": 1}, key: "container-started /1.0/containers/c1"},
+		{event: shared.Jmap{"sequence": 2}},
+		{event: shared.Jmap{"sequence": 3}, key: "container-started /1.0/containers/c2"},
+		{event: shared.Jmap{"sequence": 4}, key: "container-started /1.0/containers/c1"},
+		{event: shared.Jmap{"sequence": 5}},
+	}
+
+	events := eventsCoalesce(queue)
+	assert.Equal(t, []shared.Jmap{
+		{"sequence": 2},
+		{"sequence": 3},
+		{"sequence": 4, "coalesced": 2},
+		{"sequence": 5},
+	}, events)
+}
+
+// Events stop being queued once the listener is too far behind, and the
+// listener is flagged for disconnection.
+func TestEventListenerEnqueue_Overflow(t *testing.T) {
+	l := &eventListener{queueWake: make(chan struct{}, 1)}
+
+	for i := 0; i < eventsQueueMax; i++ {
+		l.enqueue(eventQueued{event: shared.Jmap{}})
+	}
+	assert.False(t, l.overflow)
+	assert.Len(t, l.queue, eventsQueueMax)
+	assert.Equal(t, int64(eventsQueueMax), l.queue[eventsQueueMax-1].event["sequence"])
+
+	l.enqueue(eventQueued{event: shared.Jmap{}})
+	assert.True(t, l.overflow)
+	assert.Len(t, l.queue, eventsQueueMax)
+
+	// Nothing is queued anymore, even once the queue is drained
+	l.queue = nil
+	l.enqueue(eventQueued{event: shared.Jmap{}})
+	assert.Len(t, l.queue, 0)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -114,8 +115,6 @@ func compressFile(path string, compress string) (string, error) {
  * exports it as an image.
  */
 func imgPostContInfo(d *Daemon, r *http.Request, req api.ImagesPost, builddir string) (*api.Image, error) {
-	info := api.Image{}
-	info.Properties = map[string]string{}
 	name := req.Source.Name
 	ctype := req.Source.Type
 	if ctype == "" || name == "" {
@@ -135,6 +134,18 @@ func imgPostContInfo(d *Daemon, r *http.Request, req api.ImagesPost, builddir st
 		return nil, fmt.Errorf("Bad type")
 	}
 
+	c, err := containerLoadByName(d.State(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return imageCreateFromContainer(d, c, req, builddir)
+}
+
+// Publish the given stopped container or snapshot as a new image.
+func imageCreateFromContainer(d *Daemon, c container, req api.ImagesPost, builddir string) (*api.Image, error) {
+	info := api.Image{}
+	info.Properties = map[string]string{}
 	info.Filename = req.Filename
 	switch req.Public {
 	case true:
@@ -143,11 +154,6 @@ func imgPostContInfo(d *Daemon, r *http.Request, req api.ImagesPost, builddir st
 		info.Public = false
 	}
 
-	c, err := containerLoadByName(d.State(), name)
-	if err != nil {
-		return nil, err
-	}
-
 	// Build the actual image file
 	tarfile, err := ioutil.TempFile(builddir, "lxd_build_tar_")
 	if err != nil {
@@ -266,13 +272,29 @@ func imgPostURLInfo(d *Daemon, req api.ImagesPost, op *operation) (*api.Image, e
 		return nil, fmt.Errorf("Missing URL")
 	}
 
-	myhttp, err := util.HTTPClient("", d.proxy)
+	// With a pinned checksum, the URL is the image itself
+	if req.Source.SHA256 != "" {
+		hash := strings.ToLower(req.Source.SHA256)
+		_, err = hex.DecodeString(hash)
+		if err != nil || len(hash) != 64 {
+			return nil, fmt.Errorf("Invalid SHA-256 checksum: %s", req.Source.SHA256)
+		}
+
+		return imgPostDirectInfo(d, req, op, req.Source.URL, hash)
+	}
+
+	headURL, proxy, err := d.imageDownloadSource(req.Source.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	myhttp, err := util.HTTPClient("", proxy)
 	if err != nil {
 		return nil, err
 	}
 
 	// Resolve the image URL
-	head, err := http.NewRequest("HEAD", req.Source.URL, nil)
+	head, err := http.NewRequest("HEAD", headURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -301,7 +323,11 @@ func imgPostURLInfo(d *Daemon, req api.ImagesPost, op *operation) (*api.Image, e
 		return nil, fmt.Errorf("Missing LXD-Image-URL header")
 	}
 
-	// Import the image
+	return imgPostDirectInfo(d, req, op, url, hash)
+}
+
+// Import the image at the given URL, which must have the given hash.
+func imgPostDirectInfo(d *Daemon, req api.ImagesPost, op *operation, url string, hash string) (*api.Image, error) {
 	info, err := d.ImageDownload(op, url, "direct", "", "", hash, false, req.AutoUpdate, "", false)
 	if err != nil {
 		return nil, err
@@ -602,11 +628,20 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 		imageUpload = true
 	}
 
-	if !imageUpload && !shared.StringInSlice(req.Source.Type, []string{"container", "snapshot", "image", "url"}) {
+	if !imageUpload && !shared.StringInSlice(req.Source.Type, []string{"container", "snapshot", "image", "url", "build"}) {
 		cleanup(builddir, post)
 		return InternalError(fmt.Errorf("Invalid images JSON"))
 	}
 
+	// Validate build definitions before starting the operation
+	if !imageUpload && req.Source.Type == "build" {
+		_, err := imageBuildParse(req.Source.Definition)
+		if err != nil {
+			cleanup(builddir, post)
+			return BadRequest(err)
+		}
+	}
+
 	/* Forward requests for containers on other nodes */
 	if !imageUpload && shared.StringInSlice(req.Source.Type, []string{"container", "snapshot"}) {
 		name := req.Source.Name
@@ -641,6 +676,9 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 			} else if req.Source.Type == "url" {
 				/* Processing image copy from URL */
 				info, err = imgPostURLInfo(d, req, op)
+			} else if req.Source.Type == "build" {
+				/* Processing image build from a definition */
+				info, err = imgPostBuildInfo(d, req, op, builddir)
 			} else {
 				/* Processing image creation from container */
 				imagePublishLock.Lock()
@@ -678,7 +716,12 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 		return nil
 	}
 
-	op, err := operationCreate(d.cluster, operationClassTask, "Downloading image", nil, nil, run, nil, nil)
+	description := "Downloading image"
+	if !imageUpload && req.Source.Type == "build" {
+		description = "Building image"
+	}
+
+	op, err := operationCreate(d.cluster, operationClassTask, description, nil, nil, run, nil, nil)
 	if err != nil {
 		return InternalError(err)
 	}
@@ -917,8 +960,9 @@ func autoUpdateImage(d *Daemon, op *operation, id int, info *api.Image) error {
 		}
 
 		// If we do have optimized pools, make sure we remove
-		// the volumes associated with the image.
-		if poolName != "" {
+		// the volumes associated with the image, unless it's
+		// protected.
+		if poolName != "" && !imageIsDeleteProtected(info) {
 			err = doDeleteImageFromPool(d.State(), fingerprint, poolName)
 			if err != nil {
 				logger.Error("Error deleting image from pool", log.Ctx{"err": err, "fp": fingerprint})
@@ -932,6 +976,12 @@ func autoUpdateImage(d *Daemon, op *operation, id int, info *api.Image) error {
 		return nil
 	}
 
+	// Keep protected images around, next to their replacement.
+	if imageIsDeleteProtected(info) {
+		setRefreshResult(true)
+		return nil
+	}
+
 	// Remove main image file.
 	fname := filepath.Join(d.os.VarDir, "images", fingerprint)
 	if shared.PathExists(fname) {
@@ -961,16 +1011,22 @@ func autoUpdateImage(d *Daemon, op *operation, id int, info *api.Image) error {
 
 func pruneExpiredImagesTask(d *Daemon) (task.Func, task.Schedule) {
 	f := func(ctx context.Context) {
-		pruneExpiredImages(ctx, d)
+		err := pruneCachedImages(ctx, d)
+		if err != nil {
+			logger.Error("Failed to prune cached images", log.Ctx{"err": err})
+		}
 	}
 
 	// Skip the first run, and instead run an initial pruning synchronously
 	// before we start updating images later on in the start up process.
-	expiry, err := cluster.ConfigGetInt64(d.cluster, "images.remote_cache_expiry")
+	enabled, err := imageRetentionEnabled(d)
 	if err != nil {
-		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
-	} else if expiry > 0 {
-		pruneExpiredImages(context.Background(), d)
+		logger.Error("Unable to fetch image retention configuration", log.Ctx{"err": err})
+	} else if enabled {
+		err := pruneCachedImages(context.Background(), d)
+		if err != nil {
+			logger.Error("Failed to prune cached images", log.Ctx{"err": err})
+		}
 	}
 	first := true
 	schedule := func() (time.Duration, error) {
@@ -980,14 +1036,14 @@ func pruneExpiredImagesTask(d *Daemon) (task.Func, task.Schedule) {
 			return interval, task.ErrSkip
 		}
 
-		expiry, err := cluster.ConfigGetInt64(d.cluster, "images.remote_cache_expiry")
+		enabled, err := imageRetentionEnabled(d)
 		if err != nil {
-			logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
+			logger.Error("Unable to fetch image retention configuration", log.Ctx{"err": err})
 			return interval, nil
 		}
 
 		// Check if we're supposed to prune at all
-		if expiry <= 0 {
+		if !enabled {
 			interval = 0
 		}
 
@@ -997,22 +1053,32 @@ func pruneExpiredImagesTask(d *Daemon) (task.Func, task.Schedule) {
 	return f, schedule
 }
 
-func pruneExpiredImages(ctx context.Context, d *Daemon) {
+func pruneCachedImages(ctx context.Context, d *Daemon) error {
 	logger.Infof("Pruning expired images")
 
 	expiry, err := cluster.ConfigGetInt64(d.cluster, "images.remote_cache_expiry")
 	if err != nil {
-		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
-		return
+		return errors.Wrap(err, "Unable to fetch cluster configuration")
 	}
 
-	// Get the list of expired images.
-	images, err := d.cluster.ImagesGetExpired(expiry)
+	// Get the list of cached images and the retention policies.
+	var cached []db.ImageCached
+	var policies []api.ImageRetentionPolicy
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		cached, err = tx.ImagesCached()
+		if err != nil {
+			return err
+		}
+
+		policies, err = tx.ImageRetentionPolicies()
+		return err
+	})
 	if err != nil {
-		logger.Error("Unable to retrieve the list of expired images", log.Ctx{"err": err})
-		return
+		return errors.Wrap(err, "Unable to retrieve the list of cached images")
 	}
 
+	images := imageRetentionPrunable(cached, policies, expiry, time.Now())
+
 	// Delete them
 	for _, fp := range images {
 		// At each iteration we check if we got cancelled in the
@@ -1020,10 +1086,21 @@ func pruneExpiredImages(ctx context.Context, d *Daemon) {
 		// expired now will be expired at the next run.
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		default:
 		}
 
+		imgID, imgInfo, err := d.cluster.ImageGet(fp, false, false)
+		if err != nil {
+			logger.Debugf("Error retrieving image info %s: %s", fp, err)
+			continue
+		}
+
+		// Protected images never expire.
+		if imageIsDeleteProtected(imgInfo) {
+			continue
+		}
+
 		// Get the IDs of all storage pools on which a storage volume
 		// for the requested image currently exists.
 		poolIDs, err := d.cluster.ImageGetPools(fp)
@@ -1063,11 +1140,6 @@ func pruneExpiredImages(ctx context.Context, d *Daemon) {
 			}
 		}
 
-		imgID, _, err := d.cluster.ImageGet(fp, false, false)
-		if err != nil {
-			logger.Debugf("Error retrieving image info %s: %s", fp, err)
-		}
-
 		// Remove the database entry for the image.
 		if err = d.cluster.ImageDelete(imgID); err != nil {
 			logger.Debugf("Error deleting image %s from database: %s", fp, err)
@@ -1075,6 +1147,8 @@ func pruneExpiredImages(ctx context.Context, d *Daemon) {
 	}
 
 	logger.Infof("Done pruning expired images")
+
+	return nil
 }
 
 func doDeleteImageFromPool(state *state.State, fingerprint string, storagePool string) error {
@@ -1093,9 +1167,25 @@ func doDeleteImageFromPool(state *state.State, fingerprint string, storagePool s
 	return nil
 }
 
+// Return true if the image has the security.protection.delete property set,
+// in which case it can't be deleted, be it explicitly, by the cache expiry or
+// by an auto-update.
+func imageIsDeleteProtected(info *api.Image) bool {
+	return shared.IsTrue(info.Properties["security.protection.delete"])
+}
+
 func imageDelete(d *Daemon, r *http.Request) Response {
 	fingerprint := mux.Vars(r)["fingerprint"]
 
+	_, info, err := d.cluster.ImageGet(fingerprint, false, false)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if imageIsDeleteProtected(info) {
+		return BadRequest(fmt.Errorf("Image '%s' is protected", info.Fingerprint))
+	}
+
 	deleteFromAllPools := func() error {
 		// Use the fingerprint we received in a LIKE query and use the full
 		// fingerprint we receive from the database in all further queries.
@@ -1540,12 +1630,13 @@ func imageExport(d *Daemon, r *http.Request) Response {
 		}
 	}
 
-	// Check if the image is only available on another node.
+	// Check if the image is only available on another node, which isn't
+	// the case if the images directory is shared.
 	address, err := d.cluster.ImageLocate(imgInfo.Fingerprint)
 	if err != nil {
 		return SmartError(err)
 	}
-	if address != "" {
+	if address != "" && !shared.PathExists(shared.VarPath("images", imgInfo.Fingerprint)) {
 		// Forward the request to the other node
 		cert := d.endpoints.NetworkCert()
 		client, err := cluster.Connect(address, cert, false)
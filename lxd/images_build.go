@@ -0,0 +1,444 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/ioprogress"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/osarch"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// An imageBuildDefinition describes how to build an image from an upstream
+// root filesystem. It's a subset of the distrobuilder format.
+type imageBuildDefinition struct {
+	Image struct {
+		Distribution string `yaml:"distribution"`
+		Release      string `yaml:"release"`
+		Architecture string `yaml:"architecture"`
+		Variant      string `yaml:"variant"`
+		Description  string `yaml:"description"`
+	} `yaml:"image"`
+
+	Source struct {
+		URL    string `yaml:"url"`
+		SHA256 string `yaml:"sha256"`
+	} `yaml:"source"`
+
+	Packages struct {
+		Manager string   `yaml:"manager"`
+		Update  bool     `yaml:"update"`
+		Install []string `yaml:"install"`
+		Remove  []string `yaml:"remove"`
+	} `yaml:"packages"`
+
+	Actions []imageBuildAction `yaml:"actions"`
+}
+
+// An imageBuildAction is a script run inside the build container at a given
+// stage of the build.
+type imageBuildAction struct {
+	Trigger string `yaml:"trigger"`
+	Action  string `yaml:"action"`
+}
+
+var imageBuildTriggers = []string{"post-unpack", "post-update", "post-packages"}
+
+// The commands used to update the container and to install and remove
+// packages, for each supported package manager.
+var imageBuildManagers = map[string]struct {
+	update  string
+	install string
+	remove  string
+}{
+	"apk": {
+		update:  "apk update && apk upgrade",
+		install: "apk add --no-cache",
+		remove:  "apk del",
+	},
+	"apt": {
+		update:  "apt-get update && apt-get dist-upgrade -y",
+		install: "apt-get install -y --no-install-recommends",
+		remove:  "apt-get remove -y --purge",
+	},
+	"dnf": {
+		update:  "dnf -y upgrade",
+		install: "dnf -y install",
+		remove:  "dnf -y remove",
+	},
+	"yum": {
+		update:  "yum -y update",
+		install: "yum -y install",
+		remove:  "yum -y remove",
+	},
+}
+
+var imageBuildPackageName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_:=~-]*$`)
+
+// Parse and validate a YAML build definition.
+func imageBuildParse(definition string) (*imageBuildDefinition, error) {
+	def := imageBuildDefinition{}
+	err := yaml.Unmarshal([]byte(definition), &def)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid build definition: %v", err)
+	}
+
+	if def.Image.Distribution == "" {
+		return nil, fmt.Errorf("The build definition must set image.distribution")
+	}
+
+	if def.Image.Architecture == "" {
+		def.Image.Architecture = osarch.ArchitectureDefault
+	}
+
+	_, err = osarch.ArchitectureId(def.Image.Architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	if def.Source.URL == "" {
+		return nil, fmt.Errorf("The build definition must set source.url")
+	}
+
+	if def.Source.SHA256 != "" {
+		hash, err := hex.DecodeString(def.Source.SHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("Invalid SHA-256 checksum: %s", def.Source.SHA256)
+		}
+	}
+
+	packages := append(append([]string{}, def.Packages.Install...), def.Packages.Remove...)
+	if def.Packages.Update || len(packages) > 0 {
+		_, ok := imageBuildManagers[def.Packages.Manager]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported package manager: %q", def.Packages.Manager)
+		}
+	}
+
+	for _, name := range packages {
+		if !imageBuildPackageName.MatchString(name) {
+			return nil, fmt.Errorf("Invalid package name: %q", name)
+		}
+	}
+
+	for _, action := range def.Actions {
+		if !shared.StringInSlice(action.Trigger, imageBuildTriggers) {
+			return nil, fmt.Errorf("Invalid action trigger %q (not one of %s)", action.Trigger, strings.Join(imageBuildTriggers, ", "))
+		}
+	}
+
+	return &def, nil
+}
+
+// Build an image from the given definition and publish it in the local image
+// store. The upstream root filesystem is unpacked into a temporary hidden
+// unprivileged container, with only the root disk and network interfaces of
+// the default profile, which is started to update it, install and remove
+// packages and run the actions, then published and deleted.
+func imgPostBuildInfo(d *Daemon, req api.ImagesPost, op *operation, builddir string) (*api.Image, error) {
+	def, err := imageBuildParse(req.Source.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	arch, err := osarch.ArchitectureId(def.Image.Architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	_, profile, err := d.cluster.ProfileGet("default")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load the default profile")
+	}
+
+	devices, err := imageBuildDevices(profile.Devices)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the upstream root filesystem
+	rootfs := filepath.Join(builddir, "rootfs.tar")
+	err = imageBuildDownload(d, op, def.Source.URL, def.Source.SHA256, rootfs)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix, err := shared.RandomCryptoString()
+	if err != nil {
+		return nil, err
+	}
+
+	args := db.ContainerArgs{
+		Architecture: arch,
+		Ctype:        db.CTypeRegular,
+		Config:       map[string]string{"volatile.image_build": "true"},
+		Description:  "Temporary container for an image build",
+		Devices:      devices,
+		Name:         fmt.Sprintf("lxd-build-%s", suffix[:12]),
+		Profiles:     []string{},
+	}
+
+	c, err := containerCreateAsEmpty(d, args)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if c.IsRunning() {
+			c.Stop(false)
+		}
+
+		err := c.Delete()
+		if err != nil {
+			logger.Error("Failed to delete image build container", log.Ctx{"container": c.Name(), "err": err})
+		}
+	}()
+
+	// Unpack the root filesystem
+	_, err = c.StorageStart()
+	if err != nil {
+		return nil, err
+	}
+
+	sType := c.Storage().GetStorageType()
+	blockBackend := sType == storageTypeLvm || sType == storageTypeCeph
+	err = shared.Unpack(rootfs, c.RootfsPath(), blockBackend, d.os.RunningInUserNS)
+	c.StorageStop()
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(rootfs)
+
+	// The root filesystem was unpacked unshifted, have it shifted to the
+	// container's idmap when starting.
+	err = c.ConfigKeySet("volatile.last_state.idmap", "[]")
+	if err != nil {
+		return nil, err
+	}
+
+	// Customize the container
+	err = c.Start(false)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := os.Create(filepath.Join(builddir, "build.log"))
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+
+	steps := imageBuildSteps(def)
+	for i, step := range steps {
+		op.UpdateMetadata(map[string]interface{}{"build_progress": fmt.Sprintf("%s (%d/%d)", step.name, i+1, len(steps))})
+
+		exitCode, err := containerExecScript(c, step.script, step.env, output)
+		if err != nil {
+			return nil, err
+		}
+
+		if exitCode != 0 {
+			return nil, fmt.Errorf("Build step %q failed with status %d: %s", step.name, exitCode, imageBuildLogTail(output))
+		}
+	}
+
+	err = c.Shutdown(time.Minute)
+	if err != nil && c.IsRunning() {
+		err = c.Stop(false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Publish the result
+	properties := map[string]string{
+		"os":           def.Image.Distribution,
+		"release":      def.Image.Release,
+		"architecture": def.Image.Architecture,
+		"variant":      def.Image.Variant,
+		"description":  def.Image.Description,
+	}
+
+	for k, v := range properties {
+		if v == "" {
+			delete(properties, k)
+		}
+	}
+
+	for k, v := range req.Properties {
+		properties[k] = v
+	}
+
+	req.Properties = properties
+
+	imagePublishLock.Lock()
+	defer imagePublishLock.Unlock()
+
+	return imageCreateFromContainer(d, c, req, builddir)
+}
+
+// A single script run inside the build container.
+type imageBuildStep struct {
+	name   string
+	script string
+	env    map[string]string
+}
+
+// Return the scripts to run inside the build container, in order.
+func imageBuildSteps(def *imageBuildDefinition) []imageBuildStep {
+	steps := []imageBuildStep{}
+
+	actions := func(trigger string) {
+		for _, action := range def.Actions {
+			if action.Trigger == trigger {
+				steps = append(steps, imageBuildStep{name: trigger, script: action.Action})
+			}
+		}
+	}
+
+	manager := imageBuildManagers[def.Packages.Manager]
+	env := map[string]string{}
+	if def.Packages.Manager == "apt" {
+		env["DEBIAN_FRONTEND"] = "noninteractive"
+	}
+
+	actions("post-unpack")
+
+	if def.Packages.Update {
+		steps = append(steps, imageBuildStep{name: "update", script: manager.update, env: env})
+	}
+
+	actions("post-update")
+
+	if len(def.Packages.Install) > 0 {
+		script := fmt.Sprintf("%s %s", manager.install, strings.Join(def.Packages.Install, " "))
+		steps = append(steps, imageBuildStep{name: "install", script: script, env: env})
+	}
+
+	if len(def.Packages.Remove) > 0 {
+		script := fmt.Sprintf("%s %s", manager.remove, strings.Join(def.Packages.Remove, " "))
+		steps = append(steps, imageBuildStep{name: "remove", script: script, env: env})
+	}
+
+	actions("post-packages")
+
+	return steps
+}
+
+// Download the given upstream root filesystem to the given path, checking
+// its checksum if one is given.
+func imageBuildDownload(d *Daemon, op *operation, url string, hash string, path string) error {
+	url, proxy, err := d.imageDownloadSource(url)
+	if err != nil {
+		return err
+	}
+
+	client, err := util.HTTPClient("", proxy)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", version.UserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unable to fetch %s: %s", url, resp.Status)
+	}
+
+	body := &ioprogress.ProgressReader{
+		ReadCloser: resp.Body,
+		Tracker: &ioprogress.ProgressTracker{
+			Length: resp.ContentLength,
+			Handler: func(percent int64, speed int64) {
+				op.UpdateMetadata(map[string]interface{}{"download_progress": fmt.Sprintf("%d%% (%s/s)", percent, shared.GetByteSizeString(speed, 2))})
+			},
+		},
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sum := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, sum), body)
+	if err != nil {
+		return err
+	}
+
+	if hash != "" {
+		result := fmt.Sprintf("%x", sum.Sum(nil))
+		if result != strings.ToLower(hash) {
+			return fmt.Errorf("Hash mismatch for %s: %s != %s", url, result, hash)
+		}
+	}
+
+	return nil
+}
+
+// Return the last lines of the build log, to be included in error messages.
+func imageBuildLogTail(f *os.File) string {
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) > 10 {
+		lines = lines[len(lines)-10:]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Return the devices of the image build container: the root disk and network
+// interfaces of the given profile devices, and none of the others, since the
+// build shouldn't have access to anything else on the host.
+func imageBuildDevices(profileDevices map[string]map[string]string) (types.Devices, error) {
+	name, root, err := shared.GetRootDiskDevice(profileDevices)
+	if err != nil {
+		return nil, errors.Wrap(err, "The default profile has no root disk")
+	}
+
+	devices := types.Devices{name: types.Device{"type": "disk", "path": "/", "pool": root["pool"]}}
+	for name, device := range profileDevices {
+		if device["type"] == "nic" {
+			devices[name] = types.Device{}
+			for k, v := range device {
+				devices[name][k] = v
+			}
+		}
+	}
+
+	return devices, nil
+}
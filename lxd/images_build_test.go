@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Build definitions are validated and turned into scripts run in order.
+func TestImageBuildSteps(t *testing.T) {
+	def, err := imageBuildParse(`
+image:
+  distribution: debian
+  release: stretch
+  architecture: amd64
+source:
+  url: https://example.com/rootfs.tar.xz
+packages:
+  manager: apt
+  update: true
+  install: [nginx, curl]
+actions:
+  - trigger: post-packages
+    action: systemctl enable nginx
+  - trigger: post-unpack
+    action: echo hello
+`)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, step := range imageBuildSteps(def) {
+		names = append(names, step.name)
+	}
+	assert.Equal(t, []string{"post-unpack", "update", "install", "post-packages"}, names)
+
+	steps := imageBuildSteps(def)
+	assert.Equal(t, "apt-get install -y --no-install-recommends nginx curl", steps[2].script)
+	assert.Equal(t, "noninteractive", steps[2].env["DEBIAN_FRONTEND"])
+}
+
+func TestImageBuildParse_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"no distribution": "source: {url: https://example.com/rootfs.tar}",
+		"no source":       "image: {distribution: alpine}",
+		"bad manager":     "image: {distribution: alpine}\nsource: {url: x}\npackages: {manager: pacman, install: [vim]}",
+		"bad package":     "image: {distribution: alpine}\nsource: {url: x}\npackages: {manager: apk, install: ['vim; rm -rf /']}",
+		"bad trigger":     "image: {distribution: alpine}\nsource: {url: x}\nactions: [{trigger: post-files, action: x}]",
+		"bad checksum":    "image: {distribution: alpine}\nsource: {url: x, sha256: abc}",
+	}
+
+	for name, definition := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := imageBuildParse(definition)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// The build container only gets the root disk and the network interfaces of
+// the profile.
+func TestImageBuildDevices(t *testing.T) {
+	devices, err := imageBuildDevices(map[string]map[string]string{
+		"root": {"type": "disk", "path": "/", "pool": "default", "size": "10GB"},
+		"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+		"data": {"type": "disk", "path": "/srv", "source": "/srv"},
+		"kvm":  {"type": "unix-char", "path": "/dev/kvm"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, devices, 2)
+	assert.Equal(t, "default", devices["root"]["pool"])
+	assert.Equal(t, "", devices["root"]["size"])
+	assert.Equal(t, "lxdbr0", devices["eth0"]["parent"])
+
+	_, err = imageBuildDevices(map[string]map[string]string{
+		"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared/api"
+	"golang.org/x/net/context"
+)
+
+var imagesRetentionCmd = Command{name: "images/retention", get: imagesRetentionGet, put: imagesRetentionPut, post: imagesRetentionPost}
+
+func imagesRetentionGet(d *Daemon, r *http.Request) Response {
+	var policies []api.ImageRetentionPolicy
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		policies, err = tx.ImageRetentionPolicies()
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	retention := api.ImageRetentionPut{Policies: policies}
+
+	return SyncResponseETag(true, retention, retention)
+}
+
+func imagesRetentionPut(d *Daemon, r *http.Request) Response {
+	var policies []api.ImageRetentionPolicy
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		policies, err = tx.ImageRetentionPolicies()
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	err = util.EtagCheck(r, api.ImageRetentionPut{Policies: policies})
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.ImageRetentionPut{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	err = imageRetentionValidate(req.Policies)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ImageRetentionPoliciesReplace(req.Policies)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !d.os.MockMode {
+		d.taskPruneImages.Reset()
+	}
+
+	return EmptySyncResponse
+}
+
+func imagesRetentionPost(d *Daemon, r *http.Request) Response {
+	run := func(op *operation) error {
+		return pruneCachedImages(context.Background(), d)
+	}
+
+	op, err := operationCreate(d.cluster, operationClassTask, "Pruning cached images", nil, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+func imageRetentionValidate(policies []api.ImageRetentionPolicy) error {
+	seen := map[string]bool{}
+
+	for _, policy := range policies {
+		if policy.Expiry < 0 {
+			return fmt.Errorf("Invalid expiry for '%s': must be zero or more days", policy.Alias)
+		}
+
+		if policy.Keep < 0 {
+			return fmt.Errorf("Invalid keep for '%s': must be zero or more images", policy.Alias)
+		}
+
+		key := fmt.Sprintf("%s/%s", policy.Server, policy.Alias)
+		if seen[key] {
+			return fmt.Errorf("Duplicate retention policy for server '%s' and alias '%s'", policy.Server, policy.Alias)
+		}
+
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// Find the most specific retention policy applying to images downloaded
+// from the given server and alias. A policy for both the server and the
+// alias wins over one for the alias only, which wins over one for the server
+// only, which wins over the catch-all policy.
+func imageRetentionMatch(policies []api.ImageRetentionPolicy, server string, alias string) *api.ImageRetentionPolicy {
+	var match *api.ImageRetentionPolicy
+	score := -1
+
+	for i, policy := range policies {
+		if policy.Server != "" && policy.Server != server {
+			continue
+		}
+
+		if policy.Alias != "" && policy.Alias != alias {
+			continue
+		}
+
+		s := 0
+		if policy.Alias != "" {
+			s += 2
+		}
+
+		if policy.Server != "" {
+			s++
+		}
+
+		if s > score {
+			match = &policies[i]
+			score = s
+		}
+	}
+
+	return match
+}
+
+// Return the fingerprints of the cached images which should be pruned
+// according to the retention policies and the default expiry (in days). The
+// images are expected to be ordered from the most recently uploaded one.
+func imageRetentionPrunable(images []db.ImageCached, policies []api.ImageRetentionPolicy, expiry int64, now time.Time) []string {
+	prunable := []string{}
+	kept := map[string]int{}
+
+	for _, image := range images {
+		imageExpiry := expiry
+		keep := 0
+
+		policy := imageRetentionMatch(policies, image.Server, image.Alias)
+		if policy != nil {
+			if policy.Expiry > 0 {
+				imageExpiry = policy.Expiry
+			}
+
+			keep = policy.Keep
+		}
+
+		// Only keep the newest images of each alias
+		if keep > 0 {
+			key := fmt.Sprintf("%s/%s", image.Server, image.Alias)
+			if kept[key] >= keep {
+				prunable = append(prunable, image.Fingerprint)
+				continue
+			}
+
+			kept[key]++
+		}
+
+		if imageExpiry <= 0 {
+			continue
+		}
+
+		lastUse := image.UploadedAt
+		if !image.LastUsedAt.IsZero() {
+			lastUse = image.LastUsedAt
+		}
+
+		if lastUse.Add(time.Duration(imageExpiry*24) * time.Hour).After(now) {
+			continue
+		}
+
+		prunable = append(prunable, image.Fingerprint)
+	}
+
+	return prunable
+}
+
+// Whether any image retention policy is currently configured, or cached
+// images expire by default.
+func imageRetentionEnabled(d *Daemon) (bool, error) {
+	expiry, err := cluster.ConfigGetInt64(d.cluster, "images.remote_cache_expiry")
+	if err != nil {
+		return false, err
+	}
+
+	if expiry > 0 {
+		return true, nil
+	}
+
+	var policies []api.ImageRetentionPolicy
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		policies, err = tx.ImageRetentionPolicies()
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(policies) > 0, nil
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/simplestreams"
+)
+
+// The public images of the local image store are published as a read-only
+// simplestreams index, so that other servers can use this one as a
+// simplestreams remote. Each image is its own product, so that its aliases
+// keep pointing to it.
+func (d *Daemon) simpleStreamsHandlerFuncs() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"/streams/v1/index.json":               d.simpleStreamsIndexGet,
+		"/streams/v1/images.json":              d.simpleStreamsImagesGet,
+		"/streams/images/{fingerprint}/{file}": d.simpleStreamsFileGet,
+	}
+}
+
+// Hashes of the image files, which are only computed once as images never
+// change. The lock only guards the map, so that hashing a large file doesn't
+// hold up requests for the other ones.
+var simpleStreamsFilesLock sync.Mutex
+var simpleStreamsFiles = map[string]*simpleStreamsHash{}
+
+type simpleStreamsHash struct {
+	done chan struct{}
+	file simplestreams.SimpleStreamsFile
+	err  error
+}
+
+func (d *Daemon) simpleStreamsIndexGet(w http.ResponseWriter, r *http.Request) {
+	manifest, err := d.simpleStreamsManifest()
+	if err != nil {
+		InternalError(err).Render(w)
+		return
+	}
+
+	products := []string{}
+	for name := range manifest.Products {
+		products = append(products, name)
+	}
+
+	index := simplestreams.SimpleStreamsIndex{
+		Format:  "index:1.0",
+		Updated: manifest.Updated,
+		Index: map[string]simplestreams.SimpleStreamsIndexStream{
+			"images": {
+				DataType: manifest.DataType,
+				Path:     "streams/v1/images.json",
+				Products: products,
+				Updated:  manifest.Updated,
+			},
+		},
+	}
+
+	simpleStreamsRender(w, index)
+}
+
+func (d *Daemon) simpleStreamsImagesGet(w http.ResponseWriter, r *http.Request) {
+	manifest, err := d.simpleStreamsManifest()
+	if err != nil {
+		InternalError(err).Render(w)
+		return
+	}
+
+	simpleStreamsRender(w, manifest)
+}
+
+func (d *Daemon) simpleStreamsFileGet(w http.ResponseWriter, r *http.Request) {
+	fingerprint := mux.Vars(r)["fingerprint"]
+	file := mux.Vars(r)["file"]
+
+	// Only public images are published
+	_, image, err := d.cluster.ImageGet(fingerprint, true, true)
+	if err != nil {
+		SmartError(err).Render(w)
+		return
+	}
+
+	path := shared.VarPath("images", image.Fingerprint)
+	switch file {
+	case "lxd.tar.xz", "lxd_combined.tar.gz":
+	case "root.tar.xz":
+		path += ".rootfs"
+	default:
+		NotFound(nil).Render(w)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		SmartError(err).Render(w)
+		return
+	}
+	defer f.Close()
+
+	http.ServeContent(w, r, file, image.CreatedAt, f)
+}
+
+// Build the simplestreams manifest of the public images stored on this node.
+func (d *Daemon) simpleStreamsManifest() (*simplestreams.SimpleStreamsManifest, error) {
+	var images []api.Image
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		images, err = tx.ImagesPublic()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := simplestreams.SimpleStreamsManifest{
+		DataType: "image-downloads",
+		Format:   "products:1.0",
+		Products: map[string]simplestreams.SimpleStreamsManifestProduct{},
+	}
+
+	updated := time.Time{}
+	paths := map[string]bool{}
+	for i := range images {
+		image := &images[i]
+		path := shared.VarPath("images", image.Fingerprint)
+		paths[path] = true
+		paths[path+".rootfs"] = true
+
+		items, err := simpleStreamsItems(image)
+		if err != nil {
+			return nil, err
+		}
+
+		if items == nil {
+			continue
+		}
+
+		// Version names start with the creation date of the image
+		created := image.CreatedAt
+		if created.Unix() <= 0 {
+			created = image.UploadedAt
+		}
+
+		if image.UploadedAt.After(updated) {
+			updated = image.UploadedAt
+		}
+
+		aliases := []string{}
+		for _, alias := range image.Aliases {
+			aliases = append(aliases, alias.Name)
+		}
+
+		manifest.Products[fmt.Sprintf("lxd:%s", image.Fingerprint)] = simplestreams.SimpleStreamsManifestProduct{
+			Aliases:         strings.Join(aliases, ","),
+			Architecture:    image.Architecture,
+			OperatingSystem: image.Properties["os"],
+			Release:         image.Properties["release"],
+			ReleaseTitle:    image.Properties["release"],
+			Version:         image.Properties["version"],
+			Versions: map[string]simplestreams.SimpleStreamsManifestProductVersion{
+				created.UTC().Format("20060102_1504"): {
+					Label: image.Properties["label"],
+					Items: items,
+				},
+			},
+		}
+	}
+
+	manifest.Updated = updated.UTC().Format(time.RFC1123Z)
+
+	// Forget about the files of the images which are gone
+	simpleStreamsFilesLock.Lock()
+	for path := range simpleStreamsFiles {
+		if !paths[path] {
+			delete(simpleStreamsFiles, path)
+		}
+	}
+	simpleStreamsFilesLock.Unlock()
+
+	return &manifest, nil
+}
+
+// Return the simplestreams items of an image, or nil if the image files aren't
+// on this node.
+func simpleStreamsItems(image *api.Image) (map[string]simplestreams.SimpleStreamsManifestProductVersionItem, error) {
+	path := shared.VarPath("images", image.Fingerprint)
+	if !shared.PathExists(path) {
+		return nil, nil
+	}
+
+	prefix := fmt.Sprintf("streams/images/%s", image.Fingerprint)
+
+	// Unified images
+	if !shared.PathExists(path + ".rootfs") {
+		return map[string]simplestreams.SimpleStreamsManifestProductVersionItem{
+			"lxd_combined.tar.gz": {
+				Path:       prefix + "/lxd_combined.tar.gz",
+				FileType:   "lxd_combined.tar.gz",
+				HashSha256: image.Fingerprint,
+				Size:       image.Size,
+			},
+		}, nil
+	}
+
+	meta, err := simpleStreamsFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rootfs, err := simpleStreamsFile(path + ".rootfs")
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]simplestreams.SimpleStreamsManifestProductVersionItem{
+		"lxd.tar.xz": {
+			Path:                prefix + "/lxd.tar.xz",
+			FileType:            "lxd.tar.xz",
+			HashSha256:          meta.Sha256,
+			Size:                meta.Size,
+			LXDHashSha256:       image.Fingerprint,
+			LXDHashSha256RootXz: image.Fingerprint,
+		},
+		"root.tar.xz": {
+			Path:       prefix + "/root.tar.xz",
+			FileType:   "root.tar.xz",
+			HashSha256: rootfs.Sha256,
+			Size:       rootfs.Size,
+		},
+	}, nil
+}
+
+// Return the hash and size of an image file. Concurrent requests for the
+// same file wait for a single computation of its hash.
+func simpleStreamsFile(path string) (*simplestreams.SimpleStreamsFile, error) {
+	simpleStreamsFilesLock.Lock()
+	h, ok := simpleStreamsFiles[path]
+	if ok {
+		simpleStreamsFilesLock.Unlock()
+		<-h.done
+		if h.err != nil {
+			return nil, h.err
+		}
+
+		file := h.file
+		return &file, nil
+	}
+
+	h = &simpleStreamsHash{done: make(chan struct{})}
+	simpleStreamsFiles[path] = h
+	simpleStreamsFilesLock.Unlock()
+
+	h.file, h.err = simpleStreamsHashFile(path)
+	if h.err != nil {
+		// Try again on the next request
+		simpleStreamsFilesLock.Lock()
+		delete(simpleStreamsFiles, path)
+		simpleStreamsFilesLock.Unlock()
+	}
+	close(h.done)
+
+	if h.err != nil {
+		return nil, h.err
+	}
+
+	file := h.file
+	return &file, nil
+}
+
+func simpleStreamsHashFile(path string) (simplestreams.SimpleStreamsFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return simplestreams.SimpleStreamsFile{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return simplestreams.SimpleStreamsFile{}, err
+	}
+
+	return simplestreams.SimpleStreamsFile{
+		Path:   path,
+		Sha256: fmt.Sprintf("%x", hash.Sum(nil)),
+		Size:   size,
+	}, nil
+}
+
+func simpleStreamsRender(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		logger.Errorf("Failed to render simplestreams index: %v", err)
+	}
+}
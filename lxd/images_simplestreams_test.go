@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The hash of an image file is computed once, even for concurrent requests,
+// and only failures are retried.
+func TestSimpleStreamsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-simplestreams-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "image")
+	defer func() {
+		simpleStreamsFilesLock.Lock()
+		delete(simpleStreamsFiles, path)
+		simpleStreamsFilesLock.Unlock()
+	}()
+
+	_, err = simpleStreamsFile(path)
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0644))
+	expected := fmt.Sprintf("%x", sha256.Sum256([]byte("hello")))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			file, err := simpleStreamsFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, file.Sha256)
+			assert.Equal(t, int64(5), file.Size)
+		}()
+	}
+	wg.Wait()
+
+	// Images never change, so the file isn't hashed again
+	require.NoError(t, ioutil.WriteFile(path, []byte("changed"), 0644))
+	file, err := simpleStreamsFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, file.Sha256)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/shared"
@@ -20,6 +22,22 @@ type initDataCluster struct {
 	api.ClusterPut `yaml:",inline"`
 }
 
+// An image to import, either from local files or from a URL.
+type initDataImage struct {
+	Aliases []string `json:"aliases" yaml:"aliases"`
+	File    string   `json:"file" yaml:"file"`
+	Rootfs  string   `json:"rootfs" yaml:"rootfs"`
+	URL     string   `json:"url" yaml:"url"`
+	SHA256  string   `json:"sha256" yaml:"sha256"`
+	Public  bool     `json:"public" yaml:"public"`
+}
+
+// A container to create, and optionally start.
+type initDataContainer struct {
+	api.ContainersPost `yaml:",inline"`
+	Start              bool `json:"start" yaml:"start"`
+}
+
 // Helper to initialize node-specific entities on a LXD instance using the
 // definitions from the given initDataNode object.
 //
@@ -336,6 +354,165 @@ func initDataNodeApply(d lxd.ContainerServer, config initDataNode) (func(), erro
 	return nil, nil
 }
 
+// Helper to import images and create containers using the definitions from
+// a preseed. Images whose first alias already exists and containers which
+// already exist are left alone.
+//
+// In case of error, the returned function can be used to revert the changes.
+func initDataContainersApply(d lxd.ContainerServer, images []initDataImage, containers []initDataContainer) (func(), error) {
+	// Handle reverts
+	reverts := []func(){}
+	revert := func() {
+		// Lets undo things in reverse order
+		for i := len(reverts) - 1; i >= 0; i-- {
+			reverts[i]()
+		}
+	}
+
+	// Import the images
+	for _, image := range images {
+		if len(image.Aliases) == 0 {
+			return revert, fmt.Errorf("Images must have at least one alias")
+		}
+
+		_, _, err := d.GetImageAlias(image.Aliases[0])
+		if err == nil {
+			continue
+		}
+
+		fingerprint, err := initDataImageImport(d, image)
+		if err != nil {
+			return revert, errors.Wrapf(err, "Failed to import image '%s'", image.Aliases[0])
+		}
+
+		// Setup reverter
+		reverts = append(reverts, func() {
+			op, err := d.DeleteImage(fingerprint)
+			if err == nil {
+				op.Wait()
+			}
+		})
+
+		for _, alias := range image.Aliases {
+			err := d.CreateImageAlias(api.ImageAliasesPost{ImageAliasesEntry: api.ImageAliasesEntry{Name: alias, Target: fingerprint}})
+			if err != nil {
+				return revert, errors.Wrapf(err, "Failed to create image alias '%s'", alias)
+			}
+		}
+	}
+
+	if len(containers) == 0 {
+		return nil, nil
+	}
+
+	// Create the containers
+	containerNames, err := d.GetContainerNames()
+	if err != nil {
+		return revert, errors.Wrap(err, "Failed to retrieve list of containers")
+	}
+
+	for _, container := range containers {
+		if shared.StringInSlice(container.Name, containerNames) {
+			continue
+		}
+
+		op, err := d.CreateContainer(container.ContainersPost)
+		if err == nil {
+			err = op.Wait()
+		}
+		if err != nil {
+			return revert, errors.Wrapf(err, "Failed to create container '%s'", container.Name)
+		}
+
+		// Setup reverter
+		name := container.Name
+		reverts = append(reverts, func() {
+			op, err := d.UpdateContainerState(name, api.ContainerStatePut{Action: "stop", Timeout: -1, Force: true}, "")
+			if err == nil {
+				op.Wait()
+			}
+
+			op, err = d.DeleteContainer(name)
+			if err == nil {
+				op.Wait()
+			}
+		})
+
+		if !container.Start {
+			continue
+		}
+
+		op, err = d.UpdateContainerState(name, api.ContainerStatePut{Action: "start", Timeout: -1}, "")
+		if err == nil {
+			err = op.Wait()
+		}
+		if err != nil {
+			return revert, errors.Wrapf(err, "Failed to start container '%s'", name)
+		}
+	}
+
+	return nil, nil
+}
+
+// Import an image from a preseed, returning its fingerprint.
+func initDataImageImport(d lxd.ContainerServer, image initDataImage) (string, error) {
+	req := api.ImagesPost{}
+	req.Public = image.Public
+
+	var args *lxd.ImageCreateArgs
+	if image.URL != "" {
+		req.Source = &api.ImagesPostSource{}
+		req.Source.Type = "url"
+		req.Source.Mode = "pull"
+		req.Source.Protocol = "direct"
+		req.Source.URL = image.URL
+		req.Source.SHA256 = image.SHA256
+	} else if image.File != "" {
+		meta, err := os.Open(image.File)
+		if err != nil {
+			return "", err
+		}
+		defer meta.Close()
+
+		args = &lxd.ImageCreateArgs{
+			MetaFile: meta,
+			MetaName: filepath.Base(image.File),
+		}
+
+		if image.Rootfs != "" {
+			rootfs, err := os.Open(image.Rootfs)
+			if err != nil {
+				return "", err
+			}
+			defer rootfs.Close()
+
+			args.RootfsFile = rootfs
+			args.RootfsName = filepath.Base(image.Rootfs)
+		}
+
+		req.Filename = args.MetaName
+	} else {
+		return "", fmt.Errorf("Either a file or a URL must be provided")
+	}
+
+	op, err := d.CreateImage(req, args)
+	if err != nil {
+		return "", err
+	}
+
+	err = op.Wait()
+	if err != nil {
+		return "", err
+	}
+
+	fingerprint, ok := op.Get().Metadata["fingerprint"].(string)
+	if !ok {
+		return "", fmt.Errorf("Failed to retrieve the fingerprint of the image")
+	}
+
+	return fingerprint, nil
+}
+
 // Helper to initialize LXD clustering.
 //
 // Used by the 'lxd init' command.
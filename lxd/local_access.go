@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Credentials of the clients connected to the local unix socket.
+var localPidMapper = ConnPidMapper{m: map[*net.UnixConn]*ucred{}}
+
+// Access levels of the unix groups listed in core.unix_groups. When empty,
+// the local unix socket is only accessible to root and to the members of its
+// group, which have full access.
+var localGroupsLock sync.Mutex
+var localGroups = map[string]string{}
+
+// Load core.unix_groups, and open the local unix socket to all users if it's
+// set, so that its members can connect.
+func (d *Daemon) localGroupsUpdate() error {
+	var groups map[string]string
+	err := d.db.Transaction(func(tx *db.NodeTx) error {
+		config, err := node.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		groups = config.UnixGroups()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	localGroupsLock.Lock()
+	localGroups = groups
+	localGroupsLock.Unlock()
+
+	return d.endpoints.LocalUpdatePermissions(len(groups) > 0)
+}
+
+// The 1.0 endpoints which read-only local clients may GET. Endpoints giving
+// access to the content of containers (files, exec, console, logs, exports)
+// or to operation websockets and events, which carry websocket secrets, are
+// left out.
+var localReadOnlyEndpoints = []string{
+	"",
+	"cluster",
+	"cluster/members",
+	"cluster/members/{name}",
+	"containers",
+	"containers/{name}",
+	"containers/{name}/annotations",
+	"containers/{name}/profiles",
+	"containers/{name}/snapshots",
+	"containers/{name}/snapshots/{snapshotName}",
+	"containers/{name}/state",
+	"containers/{name}/usage",
+	"images",
+	"images/aliases",
+	"images/aliases/{name:.*}",
+	"images/{fingerprint}",
+	"networks",
+	"networks/{name}",
+	"networks/{name}/leases",
+	"networks/{name}/state",
+	"operations",
+	"operations/{id}",
+	"operations/{id}/wait",
+	"profiles",
+	"profiles/{name}",
+	"profiles/{name}/used-by",
+	"resources",
+	"storage-pools",
+	"storage-pools/{name}",
+	"storage-pools/{name}/resources",
+	"storage-pools/{name}/volumes",
+	"storage-pools/{name}/volumes/{type}",
+	"storage-pools/{pool}/volumes/{type}/{name:.*}",
+}
+
+type localAccessKey struct{}
+
+// Return whether the given request comes from a read-only local client.
+func localAccessReadOnly(r *http.Request) bool {
+	readOnly, _ := r.Context().Value(localAccessKey{}).(bool)
+	return readOnly
+}
+
+// Check that the local client of the given request is allowed to perform it,
+// returning whether it only has read-only access. Root and the members of the
+// group of the unix socket have full access, while the members of the groups
+// listed in core.unix_groups have the access level set there, read-only
+// clients being limited to GET requests on localReadOnlyEndpoints.
+func (d *Daemon) checkLocalAccess(w http.ResponseWriter, r *http.Request, version string, name string) (bool, error) {
+	localGroupsLock.Lock()
+	groups := localGroups
+	localGroupsLock.Unlock()
+
+	if len(groups) == 0 {
+		return false, nil
+	}
+
+	localPidMapper.mLock.Lock()
+	cred, ok := localPidMapper.m[extractUnderlyingConn(w)]
+	localPidMapper.mLock.Unlock()
+	if !ok {
+		return false, fmt.Errorf("Unable to get the credentials of the client")
+	}
+
+	if cred.uid == 0 {
+		return false, nil
+	}
+
+	// Group IDs of the client
+	gids := []string{strconv.FormatInt(cred.gid, 10)}
+	u, err := user.LookupId(strconv.FormatInt(cred.uid, 10))
+	if err == nil {
+		ids, err := u.GroupIds()
+		if err == nil {
+			gids = append(gids, ids...)
+		}
+	}
+
+	socketGid := strconv.Itoa(os.Getgid())
+	if d.config.Group != "" {
+		g, err := user.LookupGroup(d.config.Group)
+		if err == nil {
+			socketGid = g.Gid
+		}
+	}
+
+	level := ""
+	for _, gid := range gids {
+		if gid == socketGid {
+			return false, nil
+		}
+
+		g, err := user.LookupGroupId(gid)
+		if err != nil {
+			continue
+		}
+
+		switch groups[g.Name] {
+		case "admin":
+			return false, nil
+		case "read-only":
+			level = "read-only"
+		}
+	}
+
+	if level == "" {
+		return false, fmt.Errorf("User %d isn't in any of the allowed groups", cred.uid)
+	}
+
+	if r.Method != "GET" || version != "1.0" || !shared.StringInSlice(name, localReadOnlyEndpoints) {
+		return false, fmt.Errorf("Read-only access")
+	}
+
+	return true, nil
+}
+
+// Remove the websocket secrets from an operation shown to a read-only client.
+func localAccessStripOperation(op *api.Operation) {
+	if op.Class != operationClassTask.String() {
+		op.Metadata = nil
+	}
+}
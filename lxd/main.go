@@ -87,6 +87,10 @@ func main() {
 	callhookCmd := cmdCallhook{global: &globalCmd}
 	app.AddCommand(callhookCmd.Command())
 
+	// database sub-command
+	databaseCmd := cmdDatabase{global: &globalCmd}
+	app.AddCommand(databaseCmd.Command())
+
 	// forkconsole sub-command
 	forkconsoleCmd := cmdForkconsole{global: &globalCmd}
 	app.AddCommand(forkconsoleCmd.Command())
@@ -95,6 +99,10 @@ func main() {
 	forkexecCmd := cmdForkexec{global: &globalCmd}
 	app.AddCommand(forkexecCmd.Command())
 
+	// forkagent sub-command
+	forkagentCmd := cmdForkagent{global: &globalCmd}
+	app.AddCommand(forkagentCmd.Command())
+
 	// forkfile sub-command
 	forkfileCmd := cmdForkfile{global: &globalCmd}
 	app.AddCommand(forkfileCmd.Command())
@@ -119,6 +127,10 @@ func main() {
 	forkstartCmd := cmdForkstart{global: &globalCmd}
 	app.AddCommand(forkstartCmd.Command())
 
+	// idmap sub-command
+	idmapCmd := cmdIdmap{global: &globalCmd}
+	app.AddCommand(idmapCmd.Command())
+
 	// import sub-command
 	importCmd := cmdImport{global: &globalCmd}
 	app.AddCommand(importCmd.Command())
@@ -139,6 +151,10 @@ func main() {
 	netcatCmd := cmdNetcat{global: &globalCmd}
 	app.AddCommand(netcatCmd.Command())
 
+	// recover sub-command
+	recoverCmd := cmdRecover{global: &globalCmd}
+	app.AddCommand(recoverCmd.Command())
+
 	// shutdown sub-command
 	shutdownCmd := cmdShutdown{global: &globalCmd}
 	app.AddCommand(shutdownCmd.Command())
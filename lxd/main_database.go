@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared"
+)
+
+type cmdDatabase struct {
+	global *cmdGlobal
+}
+
+func (c *cmdDatabase) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = "database"
+	cmd.Short = "Back up and restore the LXD global database"
+	cmd.Long = `Description:
+  Back up and restore the LXD global database
+
+  The global database is common to all LXD members in the cluster, and holds
+  the cluster-wide state (such as profiles, containers, etc). Backups are SQL
+  text dumps taken consistently across the cluster, written to a file of the
+  member the command is run on.
+
+  This internal command is meant for disaster recovery.
+`
+	cmd.Hidden = true
+
+	// Backup
+	backupCmd := cmdDatabaseBackup{global: c.global}
+	cmd.AddCommand(backupCmd.Command())
+
+	// Restore
+	restoreCmd := cmdDatabaseRestore{global: c.global}
+	cmd.AddCommand(restoreCmd.Command())
+
+	return cmd
+}
+
+type cmdDatabaseBackup struct {
+	global *cmdGlobal
+}
+
+func (c *cmdDatabaseBackup) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = "backup [<path>]"
+	cmd.Short = "Back up the global database to a file"
+	cmd.Long = `Description:
+  Back up the global database to a file
+
+  If no path is given, the backup is written to the database/backups
+  directory of LXD.
+`
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdDatabaseBackup) Run(cmd *cobra.Command, args []string) error {
+	if len(args) > 1 {
+		cmd.Help()
+		return fmt.Errorf("Too many arguments")
+	}
+
+	// Only root should run this
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This must be run as root")
+	}
+
+	req := internalDatabaseFile{}
+	if len(args) == 1 {
+		path, err := filepath.Abs(shared.HostPath(args[0]))
+		if err != nil {
+			return err
+		}
+
+		req.Path = path
+	}
+
+	d, err := lxd.ConnectLXDUnix("", nil)
+	if err != nil {
+		return err
+	}
+
+	response, _, err := d.RawQuery("POST", "/internal/database/backup", req, "")
+	if err != nil {
+		return err
+	}
+
+	backup := internalDatabaseFile{}
+	err = json.Unmarshal(response.Metadata, &backup)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Global database backed up to %s\n", backup.Path)
+	return nil
+}
+
+type cmdDatabaseRestore struct {
+	global *cmdGlobal
+}
+
+func (c *cmdDatabaseRestore) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = "restore <path>"
+	cmd.Short = "Restore the global database from a backup file"
+	cmd.Long = `Description:
+  Restore the global database from a backup file
+
+  The whole content of the global database is replaced with the one of the
+  backup, which must have been made with the same version of LXD. LXD must
+  then be restarted on all cluster members.
+`
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdDatabaseRestore) Run(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Too many arguments")
+	}
+
+	// Only root should run this
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This must be run as root")
+	}
+
+	d, err := lxd.ConnectLXDUnix("", nil)
+	if err != nil {
+		return err
+	}
+
+	path, err := filepath.Abs(shared.HostPath(args[0]))
+	if err != nil {
+		return err
+	}
+
+	req := internalDatabaseFile{Path: path}
+	_, _, err = d.RawQuery("POST", "/internal/database/restore", req, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Global database restored, LXD should now be restarted on all cluster members\n")
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// An execAgentRequest is sent by LXD to a forkagent process to spawn a new
+// task inside the container. The stdin, stdout and stderr file descriptors
+// of the task are passed along with the request.
+type execAgentRequest struct {
+	ID      int      `json:"id"`
+	Command []string `json:"command"`
+	Env     []string `json:"env"`
+	Cwd     string   `json:"cwd"`
+	UID     uint32   `json:"uid"`
+	GID     uint32   `json:"gid"`
+	Groups  []uint32 `json:"groups"`
+}
+
+// An execAgentReply is sent by a forkagent process once a task has been
+// spawned, and again once it has exited.
+type execAgentReply struct {
+	ID     int    `json:"id"`
+	PID    int    `json:"pid"`
+	Exited bool   `json:"exited"`
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+type cmdForkagent struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForkagent) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forkagent <container name> <containers path> <config>"
+	cmd.Short = "Execute tasks inside the container on request"
+	cmd.Long = `Description:
+  Execute tasks inside the container on request
+
+  This internal command is used to keep a helper around which spawns tasks
+  inside a running container, without having to start a new forkexec
+  process for each of them. Requests are read from file descriptor 3.
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkagent) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	if len(args) != 3 {
+		cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	// Only root should run this
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This must be run as root")
+	}
+
+	name := args[0]
+	lxcpath := args[1]
+	configPath := args[2]
+
+	d, err := lxc.NewContainer(name, lxcpath)
+	if err != nil {
+		return fmt.Errorf("Error initializing container for start: %q", err)
+	}
+
+	err = d.LoadConfigFile(configPath)
+	if err != nil {
+		return fmt.Errorf("Error opening startup config file: %q", err)
+	}
+
+	logPath := shared.LogPath(name, "forkagent.log")
+	if shared.PathExists(logPath) {
+		os.Remove(logPath)
+	}
+
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_SYNC, 0644)
+	if err == nil {
+		syscall.Dup3(int(logFile.Fd()), 1, 0)
+		syscall.Dup3(int(logFile.Fd()), 2, 0)
+	}
+
+	conn, err := net.FileConn(os.NewFile(uintptr(3), "agent"))
+	if err != nil {
+		return fmt.Errorf("Failed to setup request socket: %q", err)
+	}
+
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("Request socket isn't a unix socket")
+	}
+
+	writeLock := sync.Mutex{}
+	send := func(reply execAgentReply) {
+		buf, err := json.Marshal(reply)
+		if err != nil {
+			return
+		}
+
+		writeLock.Lock()
+		defer writeLock.Unlock()
+
+		_, err = unixConn.Write(buf)
+		if err != nil {
+			fmt.Printf("Failed to send reply for request %d: %v\n", reply.ID, err)
+		}
+	}
+
+	buf := make([]byte, 1024*1024)
+	oob := make([]byte, syscall.CmsgSpace(3*4))
+
+	for {
+		n, oobn, _, _, err := unixConn.ReadMsgUnix(buf, oob)
+		if err != nil || n == 0 {
+			// LXD went away, exit once all tasks are gone.
+			return nil
+		}
+
+		fds := []int{}
+		if oobn > 0 {
+			entries, err := syscall.ParseSocketControlMessage(oob[:oobn])
+			if err == nil {
+				for _, msg := range entries {
+					rights, err := syscall.ParseUnixRights(&msg)
+					if err == nil {
+						fds = append(fds, rights...)
+					}
+				}
+			}
+		}
+
+		req := execAgentRequest{}
+		err = json.Unmarshal(buf[:n], &req)
+		if err != nil || len(fds) != 3 {
+			for _, fd := range fds {
+				syscall.Close(fd)
+			}
+
+			send(execAgentReply{ID: req.ID, Exited: true, Status: -1, Error: "Invalid request"})
+			continue
+		}
+
+		opts := lxc.DefaultAttachOptions
+		opts.ClearEnv = true
+		opts.StdinFd = uintptr(fds[0])
+		opts.StdoutFd = uintptr(fds[1])
+		opts.StderrFd = uintptr(fds[2])
+		opts.Env = req.Env
+		opts.UID = int(req.UID)
+		opts.GID = int(req.GID)
+		opts.Groups = []int{}
+		for _, group := range req.Groups {
+			opts.Groups = append(opts.Groups, int(group))
+		}
+
+		for _, env := range req.Env {
+			fields := strings.SplitN(env, "=", 2)
+			if len(fields) == 2 && fields[0] == "HOME" {
+				opts.Cwd = fields[1]
+			}
+		}
+
+		if req.Cwd != "" {
+			opts.Cwd = req.Cwd
+		}
+
+		pid, err := d.RunCommandNoWait(req.Command, opts)
+
+		// The task got its own copy of the file descriptors
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+
+		if err != nil {
+			send(execAgentReply{ID: req.ID, Exited: true, Status: -1, Error: fmt.Sprintf("Failed running command: %q", err)})
+			continue
+		}
+
+		send(execAgentReply{ID: req.ID, PID: pid})
+
+		go func(id int, pid int) {
+			reply := execAgentReply{ID: id, PID: pid, Exited: true, Status: -1}
+
+			var ws syscall.WaitStatus
+			wpid, err := syscall.Wait4(pid, &ws, 0, nil)
+			if err != nil || wpid != pid {
+				reply.Error = fmt.Sprintf("Failed finding process: %q", err)
+			} else if ws.Exited() {
+				reply.Status = ws.ExitStatus()
+			} else if ws.Signaled() {
+				// 128 + n == Fatal error signal "n"
+				reply.Status = 128 + int(ws.Signal())
+			}
+
+			send(reply)
+		}(req.ID, pid)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -20,7 +21,7 @@ type cmdForkexec struct {
 func (c *cmdForkexec) Command() *cobra.Command {
 	// Main subcommand
 	cmd := &cobra.Command{}
-	cmd.Use = "forkexec <container name> <containers path> <config> -- env [key=value...] -- cmd <args...>"
+	cmd.Use = "forkexec <container name> <containers path> <config> [-- uid <uid>] [-- gid <gid>] [-- groups <gid...>] [-- cwd <path>] -- env [key=value...] -- cmd <args...>"
 	cmd.Short = "Execute a task inside the container"
 	cmd.Long = `Description:
   Execute a task inside the container
@@ -92,6 +93,7 @@ func (c *cmdForkexec) Run(cmd *cobra.Command, args []string) error {
 
 	env := []string{}
 	command := []string{}
+	cwd := ""
 
 	section := ""
 	for _, arg := range args[3:] {
@@ -108,10 +110,33 @@ func (c *cmdForkexec) Run(cmd *cobra.Command, args []string) error {
 
 		if section == "env" {
 			fields := strings.SplitN(arg, "=", 2)
-			if len(fields) == 2 && fields[0] == "HOME" {
+			if len(fields) == 2 && fields[0] == "HOME" && cwd == "" {
 				opts.Cwd = fields[1]
 			}
 			env = append(env, arg)
+		} else if section == "uid" {
+			uid, err := strconv.ParseUint(arg, 10, 32)
+			if err != nil {
+				return fmt.Errorf("Invalid uid: %s", arg)
+			}
+			opts.UID = int(uid)
+		} else if section == "gid" {
+			gid, err := strconv.ParseUint(arg, 10, 32)
+			if err != nil {
+				return fmt.Errorf("Invalid gid: %s", arg)
+			}
+			opts.GID = int(gid)
+		} else if section == "groups" {
+			// Supplementary groups, set through setgroups() by
+			// liblxc right before switching to the uid/gid
+			group, err := strconv.ParseUint(arg, 10, 32)
+			if err != nil {
+				return fmt.Errorf("Invalid group: %s", arg)
+			}
+			opts.Groups = append(opts.Groups, int(group))
+		} else if section == "cwd" {
+			cwd = arg
+			opts.Cwd = cwd
 		} else if section == "cmd" {
 			command = append(command, arg)
 		} else {
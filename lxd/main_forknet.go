@@ -7,6 +7,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 )
 
@@ -58,7 +59,7 @@ void forknet() {
 	}
 
 	// Call the subcommands
-	if (strcmp(command, "info") == 0) {
+	if (strcmp(command, "info") == 0 || strcmp(command, "mtu") == 0) {
 		forkdonetinfo(pid);
 	}
 }
@@ -89,6 +90,13 @@ func (c *cmdForknet) Command() *cobra.Command {
 	cmdInfo.RunE = c.RunInfo
 	cmd.AddCommand(cmdInfo)
 
+	// mtu
+	cmdMTU := &cobra.Command{}
+	cmdMTU.Use = "mtu <PID> <interface> <MTU>"
+	cmdMTU.Args = cobra.ExactArgs(3)
+	cmdMTU.RunE = c.RunMTU
+	cmd.AddCommand(cmdMTU)
+
 	return cmd
 }
 
@@ -113,3 +121,8 @@ func (c *cmdForknet) RunInfo(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func (c *cmdForknet) RunMTU(cmd *cobra.Command, args []string) error {
+	_, err := shared.RunCommand("ip", "link", "set", "dev", args[1], "mtu", args[2])
+	return err
+}
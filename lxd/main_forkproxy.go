@@ -299,7 +299,7 @@ type udpSession struct {
 func (c *cmdForkproxy) Command() *cobra.Command {
 	// Main subcommand
 	cmd := &cobra.Command{}
-	cmd.Use = "forkproxy <listen PID> <listen address> <connect PID> <connect address> <fd> <reexec> <log path> <pid path>"
+	cmd.Use = "forkproxy <listen PID> <listen address> <connect PID> <connect address> <log path> <pid path> <listen gid> <listen uid> <listen mode> <connect timeout>"
 	cmd.Short = "Setup network connection proxying"
 	cmd.Long = `Description:
   Setup network connection proxying
@@ -324,7 +324,22 @@ func rearmUDPFd(epFd C.int, connFd C.int) {
 	}
 }
 
-func listenerInstance(epFd C.int, lAddr *proxyAddress, cAddr *proxyAddress, connFd C.int, lStruct *lStruct) error {
+// Connect to the target, retrying for up to the given duration so that
+// connections made while the container is starting wait for its workload.
+func proxyDial(connType string, addr string, timeout time.Duration) (net.Conn, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		conn, err := net.Dial(connType, addr)
+		if err == nil || time.Now().After(deadline) {
+			return conn, err
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func listenerInstance(epFd C.int, lAddr *proxyAddress, cAddr *proxyAddress, connFd C.int, lStruct *lStruct, connectTimeout time.Duration) error {
 	fmt.Printf("Starting %s <-> %s proxy\n", lAddr.connType, cAddr.connType)
 	if lAddr.connType == "udp" {
 		// This only handles udp <-> udp. The C constructor will have
@@ -344,7 +359,7 @@ func listenerInstance(epFd C.int, lAddr *proxyAddress, cAddr *proxyAddress, conn
 				return
 			}
 
-			dstConn, err := net.Dial(cAddr.connType, connectAddr)
+			dstConn, err := proxyDial(cAddr.connType, connectAddr, connectTimeout)
 			if err != nil {
 				fmt.Printf("Error: Failed to connect to target: %v\n", err)
 				rearmUDPFd(epFd, connFd)
@@ -372,19 +387,21 @@ func listenerInstance(epFd C.int, lAddr *proxyAddress, cAddr *proxyAddress, conn
 		// multiple port -> multiple port
 		connectAddr = cAddr.addr[(*lStruct).lAddrIndex]
 	}
-	dstConn, err := net.Dial(cAddr.connType, connectAddr)
-	if err != nil {
-		srcConn.Close()
-		fmt.Printf("Error: Failed to connect to target: %v\n", err)
-		return err
-	}
+	go func() {
+		dstConn, err := proxyDial(cAddr.connType, connectAddr, connectTimeout)
+		if err != nil {
+			srcConn.Close()
+			fmt.Printf("Error: Failed to connect to target: %v\n", err)
+			return
+		}
 
-	if cAddr.connType == "unix" && lAddr.connType == "unix" {
-		// Handle OOB if both src and dst are using unix sockets
-		go unixRelay(srcConn, dstConn)
-	} else {
-		go genericRelay(srcConn, dstConn, false)
-	}
+		if cAddr.connType == "unix" && lAddr.connType == "unix" {
+			// Handle OOB if both src and dst are using unix sockets
+			unixRelay(srcConn, dstConn)
+		} else {
+			genericRelay(srcConn, dstConn, false)
+		}
+	}()
 
 	return nil
 }
@@ -403,7 +420,7 @@ func (c *cmdForkproxy) Run(cmd *cobra.Command, args []string) error {
 	}
 
 	// Sanity checks
-	if len(args) != 9 {
+	if len(args) != 10 {
 		cmd.Help()
 
 		if len(args) == 0 {
@@ -513,6 +530,11 @@ func (c *cmdForkproxy) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	connectTimeout, err := strconv.Atoi(args[9])
+	if err != nil {
+		return err
+	}
+
 	files := []*os.File{}
 	for range lAddr.addr {
 	rAgain:
@@ -622,7 +644,7 @@ func (c *cmdForkproxy) Run(cmd *cobra.Command, args []string) error {
 				continue
 			}
 
-			err := listenerInstance(epFd, lAddr, cAddr, curFd, srcConn)
+			err := listenerInstance(epFd, lAddr, cAddr, curFd, srcConn, time.Duration(connectTimeout)*time.Second)
 			if err != nil {
 				fmt.Printf("Failed to prepare new listener instance: %s", err)
 			}
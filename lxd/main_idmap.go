@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/client"
+)
+
+type cmdIdmap struct {
+	global *cmdGlobal
+}
+
+func (c *cmdIdmap) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = "idmap"
+	cmd.Short = "Inspect and defragment the uid/gid allocations of containers"
+	cmd.Long = `Description:
+  Inspect and defragment the uid/gid allocations of containers
+
+  Containers with security.idmap.isolated get their own range of host uids
+  and gids. Creating and deleting such containers over time can leave gaps
+  in the host range, to the point where a new container doesn't fit anymore
+  even though enough ids are free.
+
+  This internal command is meant for administrators of this LXD node.
+`
+	cmd.Hidden = true
+
+	// Show
+	showCmd := cmdIdmapShow{global: c.global}
+	cmd.AddCommand(showCmd.Command())
+
+	// Defragment
+	defragmentCmd := cmdIdmapDefragment{global: c.global}
+	cmd.AddCommand(defragmentCmd.Command())
+
+	return cmd
+}
+
+type cmdIdmapShow struct {
+	global *cmdGlobal
+}
+
+func (c *cmdIdmapShow) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = "show"
+	cmd.Short = "Show the uid/gid ranges allocated on this node"
+	cmd.Long = `Description:
+  Show the uid/gid ranges allocated on this node
+`
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdIdmapShow) Run(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		cmd.Help()
+		return fmt.Errorf("Too many arguments")
+	}
+
+	return idmapQuery("GET", "/internal/idmaps")
+}
+
+type cmdIdmapDefragment struct {
+	global *cmdGlobal
+}
+
+func (c *cmdIdmapDefragment) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = "defragment"
+	cmd.Short = "Move the ranges of stopped containers to close the gaps"
+	cmd.Long = `Description:
+  Move the ranges of stopped containers to close the gaps
+
+  Running containers, containers with security.idmap.base set and containers
+  with security.protection.shift enabled keep their range. The filesystem of the moved containers is shifted to their new range
+  the next time they start, which may take a while.
+`
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdIdmapDefragment) Run(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		cmd.Help()
+		return fmt.Errorf("Too many arguments")
+	}
+
+	return idmapQuery("POST", "/internal/idmaps/defragment")
+}
+
+// Run the given internal idmaps query and print the resulting allocations.
+func idmapQuery(method string, path string) error {
+	// Only root should run this
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This must be run as root")
+	}
+
+	d, err := lxd.ConnectLXDUnix("", nil)
+	if err != nil {
+		return err
+	}
+
+	response, _, err := d.RawQuery(method, path, nil, "")
+	if err != nil {
+		return err
+	}
+
+	idmaps := internalIdmaps{}
+	err = json.Unmarshal(response.Metadata, &idmaps)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&idmaps)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+	return nil
+}
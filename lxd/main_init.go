@@ -14,6 +14,10 @@ import (
 type cmdInitData struct {
 	Node    initDataNode     `yaml:",inline"`
 	Cluster *initDataCluster `json:"cluster" yaml:"cluster"`
+
+	// Only supported in preseed mode
+	Images     []initDataImage     `json:"images" yaml:"images"`
+	Containers []initDataContainer `json:"containers" yaml:"containers"`
 }
 
 type cmdInit struct {
@@ -110,7 +114,18 @@ func (c *cmdInit) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	return initDataClusterApply(d, config.Cluster)
+	err = initDataClusterApply(d, config.Cluster)
+	if err != nil {
+		return err
+	}
+
+	revert, err = initDataContainersApply(d, config.Images, config.Containers)
+	if err != nil {
+		revert()
+		return err
+	}
+
+	return nil
 }
 
 func (c *cmdInit) availableStorageDrivers(poolType string) []string {
@@ -136,6 +151,11 @@ func (c *cmdInit) availableStorageDrivers(poolType string) []string {
 			continue
 		}
 
+		// External drivers need to be registered first.
+		if driver == "external" {
+			continue
+		}
+
 		// btrfs can work in user namespaces too. (If
 		// source=/some/path/on/btrfs is used.)
 		if shared.RunningInUserNS() && (backingFs != "btrfs" || driver != "btrfs") {
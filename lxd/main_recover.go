@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/client"
+	cli "github.com/lxc/lxd/shared/cmd"
+)
+
+type cmdRecover struct {
+	global *cmdGlobal
+
+	flagForce bool
+}
+
+func (c *cmdRecover) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = "recover <pool>"
+	cmd.Short = "Recover the containers and volumes of a storage pool"
+	cmd.Long = `Description:
+  Recover the containers and volumes of a storage pool
+
+  This command is used for disaster recovery. It scans an existing storage
+  pool for containers and custom storage volumes that LXD no longer knows
+  about and, once confirmed, recreates their database entries.
+
+  As for ` + "`lxd import`" + `, the storage pool must first be mounted at the
+  expected path inside the storage-pools directory, along with the storage
+  volumes of its containers. Containers are recovered from their backup.yaml
+  file, while custom storage volumes get the default configuration of the
+  pool.
+`
+	cmd.RunE = c.Run
+	cmd.Flags().BoolVarP(&c.flagForce, "force", "f", false, "Force the recovery (override existing data or partial restore)")
+
+	return cmd
+}
+
+func (c *cmdRecover) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	if len(args) < 1 {
+		cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	// Only root should run this
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This must be run as root")
+	}
+
+	d, err := lxd.ConnectLXDUnix("", nil)
+	if err != nil {
+		return err
+	}
+
+	// Scan the storage pool
+	response, _, err := d.RawQuery("GET", fmt.Sprintf("/internal/recover?pool=%s", url.QueryEscape(args[0])), nil, "")
+	if err != nil {
+		return err
+	}
+
+	scan := internalRecoverScan{}
+	err = json.Unmarshal(response.Metadata, &scan)
+	if err != nil {
+		return err
+	}
+
+	if len(scan.Containers) == 0 && len(scan.Volumes) == 0 {
+		fmt.Printf("No unknown containers or storage volumes found in the storage pool \"%s\"\n", scan.Pool)
+		return nil
+	}
+
+	if len(scan.Containers) > 0 {
+		fmt.Printf("The following containers will be recovered:\n")
+		for _, name := range scan.Containers {
+			fmt.Printf(" - %s\n", name)
+		}
+	}
+
+	if len(scan.Volumes) > 0 {
+		fmt.Printf("The following custom storage volumes will be recovered:\n")
+		for _, name := range scan.Volumes {
+			fmt.Printf(" - %s\n", name)
+		}
+	}
+
+	if !cli.AskBool("Would you like to proceed? (yes/no) [default=no]: ", "no") {
+		return nil
+	}
+
+	// Recover them
+	req := internalRecoverRequest{
+		Pool:       scan.Pool,
+		Containers: scan.Containers,
+		Volumes:    scan.Volumes,
+		Force:      c.flagForce,
+	}
+
+	_, _, err = d.RawQuery("POST", "/internal/recover", req, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
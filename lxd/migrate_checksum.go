@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// The filesystem data of a migration is hashed on both ends as it goes over
+// the websocket, if both ends agree on it through the migration header. Once
+// the transfer is done, the source sends its digest over the control channel
+// and the sink fails the migration if it doesn't match its own.
+var migrationChecksumLock sync.Mutex
+var migrationChecksumConns = map[*websocket.Conn]hash.Hash{}
+
+// Start hashing what's sent or received over the given websocket.
+func migrationChecksumStart(conn *websocket.Conn) {
+	if conn == nil {
+		return
+	}
+
+	migrationChecksumLock.Lock()
+	defer migrationChecksumLock.Unlock()
+
+	migrationChecksumConns[conn] = sha256.New()
+}
+
+// Stop hashing what goes over the given websocket, returning the digest of
+// what went over it so far or an empty string if it wasn't being hashed.
+func migrationChecksumStop(conn *websocket.Conn) string {
+	migrationChecksumLock.Lock()
+	defer migrationChecksumLock.Unlock()
+
+	h, ok := migrationChecksumConns[conn]
+	if !ok {
+		return ""
+	}
+
+	delete(migrationChecksumConns, conn)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func migrationChecksumGet(conn *websocket.Conn) hash.Hash {
+	migrationChecksumLock.Lock()
+	defer migrationChecksumLock.Unlock()
+
+	return migrationChecksumConns[conn]
+}
+
+type migrationChecksumReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Wrap the reader of data about to be sent over the websocket.
+func migrationChecksumReader(conn *websocket.Conn, r io.ReadCloser) io.ReadCloser {
+	h := migrationChecksumGet(conn)
+	if h == nil {
+		return r
+	}
+
+	return migrationChecksumReadCloser{Reader: io.TeeReader(r, h), Closer: r}
+}
+
+type migrationChecksumWriteCloser struct {
+	io.Writer
+	io.Closer
+}
+
+// Wrap the writer of data received over the websocket.
+func migrationChecksumWriter(conn *websocket.Conn, w io.WriteCloser) io.WriteCloser {
+	h := migrationChecksumGet(conn)
+	if h == nil {
+		return w
+	}
+
+	return migrationChecksumWriteCloser{Writer: io.MultiWriter(w, h), Closer: w}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// The filesystem data of a migration can be compressed with zstd, if both
+// ends agree on it through the migration header. The negotiated compression
+// is attached to the filesystem websocket, which is what the storage drivers
+// and rsync get to see.
+type migrationCompression struct {
+	algorithm string
+	level     int64
+}
+
+var migrationCompressionLock sync.Mutex
+var migrationCompressionConns = map[*websocket.Conn]migrationCompression{}
+
+// Check whether zstd can be used for both block streams and rsync.
+func migrationCompressionSupported() bool {
+	_, err := exec.LookPath("zstd")
+	if err != nil {
+		return false
+	}
+
+	// Only rsync 3.2 and later know about zstd
+	output, err := shared.RunCommand("rsync", "--version")
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(output, "zstd")
+}
+
+// Return the compression to offer to the other end of a migration, along with
+// the level to compress at, based on the server configuration.
+func migrationCompressionOffer(s *state.State) (string, int64) {
+	level, err := cluster.ConfigGetInt64(s.Cluster, "migration.compression_level")
+	if err != nil {
+		logger.Warnf("Failed to get the migration compression level: %v", err)
+		return "", 0
+	}
+
+	if level <= 0 || !migrationCompressionSupported() {
+		return "", 0
+	}
+
+	return "zstd", level
+}
+
+// Return the compression to accept out of what the other end offered.
+func migrationCompressionAccept(offer string) string {
+	if offer != "zstd" || !migrationCompressionSupported() {
+		return ""
+	}
+
+	return offer
+}
+
+func migrationCompressionSet(conn *websocket.Conn, algorithm string, level int64) {
+	if conn == nil || algorithm == "" {
+		return
+	}
+
+	migrationCompressionLock.Lock()
+	defer migrationCompressionLock.Unlock()
+
+	migrationCompressionConns[conn] = migrationCompression{algorithm: algorithm, level: level}
+}
+
+func migrationCompressionUnset(conn *websocket.Conn) {
+	migrationCompressionLock.Lock()
+	defer migrationCompressionLock.Unlock()
+
+	delete(migrationCompressionConns, conn)
+}
+
+func migrationCompressionGet(conn *websocket.Conn) migrationCompression {
+	migrationCompressionLock.Lock()
+	defer migrationCompressionLock.Unlock()
+
+	return migrationCompressionConns[conn]
+}
+
+// Return the extra arguments for the sending end of rsync.
+func migrationCompressionRsyncSendArgs(conn *websocket.Conn) []string {
+	compression := migrationCompressionGet(conn)
+	if compression.algorithm == "" {
+		return nil
+	}
+
+	return []string{
+		"--compress",
+		fmt.Sprintf("--compress-choice=%s", compression.algorithm),
+		fmt.Sprintf("--compress-level=%d", compression.level),
+	}
+}
+
+// Return the extra arguments for the receiving end of rsync.
+func migrationCompressionRsyncRecvArgs(conn *websocket.Conn) []string {
+	compression := migrationCompressionGet(conn)
+	if compression.algorithm == "" {
+		return nil
+	}
+
+	return []string{
+		"-z",
+		fmt.Sprintf("--compress-choice=%s", compression.algorithm),
+	}
+}
+
+// Send the data read from r over the websocket, compressing it if that was
+// negotiated for the connection.
+func migrationSendStream(conn *websocket.Conn, r io.ReadCloser) error {
+	r = migrationChecksumReader(conn, r)
+
+	compression := migrationCompressionGet(conn)
+	if compression.algorithm == "" {
+		<-shared.WebsocketSendStream(conn, r, 4*1024*1024)
+		return nil
+	}
+
+	cmd := exec.Command("zstd", "-q", "-c", fmt.Sprintf("-%d", compression.level))
+	cmd.Stdin = r
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	<-shared.WebsocketSendStream(conn, stdout, 4*1024*1024)
+
+	err = cmd.Wait()
+	if err != nil {
+		return fmt.Errorf("Failed to compress migration data: %v", err)
+	}
+
+	return nil
+}
+
+// Write the data received over the websocket to w, decompressing it if that
+// was negotiated for the connection.
+func migrationRecvStream(w io.WriteCloser, conn *websocket.Conn) error {
+	w = migrationChecksumWriter(conn, w)
+
+	compression := migrationCompressionGet(conn)
+	if compression.algorithm == "" {
+		<-shared.WebsocketRecvStream(w, conn)
+		return nil
+	}
+
+	cmd := exec.Command("zstd", "-q", "-d", "-c")
+	cmd.Stdout = w
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	<-shared.WebsocketRecvStream(stdin, conn)
+	stdin.Close()
+
+	err = cmd.Wait()
+	if err != nil {
+		return fmt.Errorf("Failed to decompress migration data: %v", err)
+	}
+
+	return nil
+}
@@ -375,6 +375,8 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		use_pre_dumps, max_iterations = s.checkForPreDumpSupport()
 	}
 
+	compression, compressionLevel := migrationCompressionOffer(s.container.DaemonState())
+
 	// The protocol says we have to send a header no matter what, so let's
 	// do that, but then immediately send an error.
 	myType := s.container.Storage().MigrationType()
@@ -385,6 +387,17 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		SnapshotNames: snapshotNames,
 		Snapshots:     snapshots,
 		Predump:       proto.Bool(use_pre_dumps),
+		Checksum:      proto.String("sha256"),
+	}
+	migration.Offer(&header)
+
+	if compression != "" {
+		header.Compression = proto.String(compression)
+	}
+
+	// Only have rsync compare checksums if this storage pool wants it
+	if rsyncPoolArgs(s.container.Storage().GetStoragePoolWritable().Config) == nil {
+		header.Withdraw(migration.FeatureRsyncChecksum)
 	}
 
 	err = s.send(&header)
@@ -404,6 +417,12 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		return err
 	}
 
+	err = header.CheckVersion()
+	if err != nil {
+		s.sendControl(err)
+		return err
+	}
+
 	bwlimit := ""
 	if *header.Fs != myType {
 		myType = migration.MigrationFSType_RSYNC
@@ -427,6 +446,23 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		logger.Debugf("The other side does not support pre-copy")
 	}
 
+	// Compress the filesystem data if the other side agreed to it
+	if compression != "" && header.HasFeature(migration.FeatureCompression) && header.GetCompression() == compression {
+		migrationCompressionSet(s.fsConn, compression, compressionLevel)
+		defer migrationCompressionUnset(s.fsConn)
+	}
+
+	if header.HasFeature(migration.FeatureRsyncChecksum) {
+		rsyncChecksumSet(s.fsConn)
+		defer rsyncChecksumUnset(s.fsConn)
+	}
+
+	// Hash the filesystem data if the other side can verify it
+	if header.HasFeature(migration.FeatureChecksum) && header.GetChecksum() == "sha256" {
+		migrationChecksumStart(s.fsConn)
+		defer migrationChecksumStop(s.fsConn)
+	}
+
 	// All failure paths need to do a few things to correctly handle errors before returning.
 	// Unfortunately, handling errors is not well-suited to defer as the code depends on the
 	// status of driver and the error value.  The error value is especially tricky due to the
@@ -634,6 +670,19 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 
 	driver.Cleanup()
 
+	// Let the sink check that it got what we sent
+	checksum := migrationChecksumStop(s.fsConn)
+	if checksum != "" {
+		err = s.send(&migration.MigrationControl{
+			Success:  proto.Bool(true),
+			Checksum: proto.String(checksum),
+		})
+		if err != nil {
+			s.disconnect()
+			return err
+		}
+	}
+
 	msg := migration.MigrationControl{}
 	err = s.recv(&msg)
 	if err != nil {
@@ -791,6 +840,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		Fs:   &myType,
 		Criu: criuType,
 	}
+	migration.Negotiate(&header, &resp)
 
 	// If the storage type the source has doesn't match what we have, then
 	// we have to use rsync.
@@ -808,12 +858,32 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		resp.Predump = proto.Bool(false)
 	}
 
+	// Only agree to compression we know how to undo
+	compression := ""
+	if header.HasFeature(migration.FeatureCompression) {
+		compression = migrationCompressionAccept(header.GetCompression())
+	}
+
+	if compression != "" {
+		resp.Compression = proto.String(compression)
+	}
+
+	checksum := ""
+	if header.HasFeature(migration.FeatureChecksum) && header.GetChecksum() == "sha256" {
+		checksum = header.GetChecksum()
+		resp.Checksum = proto.String(checksum)
+	}
+
 	err = sender(&resp)
 	if err != nil {
 		controller(err)
 		return err
 	}
 
+	// The checksum of the filesystem data, as computed by the source
+	checksums := make(chan string, 1)
+	defer close(checksums)
+
 	restore := make(chan error)
 	go func(c *migrationSink) {
 		imagesDir := ""
@@ -860,6 +930,19 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 				fsConn = c.src.fsConn
 			}
 
+			migrationCompressionSet(fsConn, compression, 0)
+			defer migrationCompressionUnset(fsConn)
+
+			if header.HasFeature(migration.FeatureRsyncChecksum) {
+				rsyncChecksumSet(fsConn)
+				defer rsyncChecksumUnset(fsConn)
+			}
+
+			if checksum != "" {
+				migrationChecksumStart(fsConn)
+				defer migrationChecksumStop(fsConn)
+			}
+
 			sendFinalFsDelta := false
 			if live {
 				sendFinalFsDelta = true
@@ -877,6 +960,20 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 				return
 			}
 
+			if checksum != "" {
+				received := migrationChecksumStop(fsConn)
+				expected, ok := <-checksums
+				if !ok {
+					fsTransfer <- fmt.Errorf("Got no checksum from the source")
+					return
+				}
+
+				if received != expected {
+					fsTransfer <- fmt.Errorf("Checksum mismatch of the transferred filesystem data")
+					return
+				}
+			}
+
 			err = ShiftIfNecessary(c.src.container, srcIdmap)
 			if err != nil {
 				fsTransfer <- err
@@ -977,13 +1074,13 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		restore <- nil
 	}(c)
 
-	var source <-chan migration.MigrationControl
+	controlChannel := c.src.controlChannel
 	if c.push {
-		source = c.dest.controlChannel()
-	} else {
-		source = c.src.controlChannel()
+		controlChannel = c.dest.controlChannel
 	}
 
+	source := controlChannel()
+
 	for {
 		select {
 		case err = <-restore:
@@ -1001,6 +1098,11 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 			if !*msg.Success {
 				disconnector()
 				return fmt.Errorf(*msg.Message)
+			} else if msg.GetChecksum() != "" {
+				checksums <- msg.GetChecksum()
+
+				// Keep listening for failures
+				source = controlChannel()
 			} else {
 				// The source can only tell us it failed (e.g. if
 				// checkpointing failed). We have to tell the source
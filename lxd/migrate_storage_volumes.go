@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/websocket"
 
 	"github.com/lxc/lxd/lxd/migration"
@@ -44,12 +45,24 @@ func (s *migrationSourceWs) DoStorage(migrateOp *operation) error {
 		defer s.storage.StoragePoolVolumeUmount()
 	}
 
+	compression, compressionLevel := migrationCompressionOffer(s.storage.GetState())
+
 	// The protocol says we have to send a header no matter what, so let's
 	// do that, but then immediately send an error.
 	myType := s.storage.MigrationType()
 	header := migration.MigrationHeader{
 		Fs: &myType,
 	}
+	migration.Offer(&header)
+
+	if compression != "" {
+		header.Compression = proto.String(compression)
+	}
+
+	// Only have rsync compare checksums if this storage pool wants it
+	if rsyncPoolArgs(s.storage.GetStoragePoolWritable().Config) == nil {
+		header.Withdraw(migration.FeatureRsyncChecksum)
+	}
 
 	err = s.send(&header)
 	if err != nil {
@@ -72,6 +85,13 @@ func (s *migrationSourceWs) DoStorage(migrateOp *operation) error {
 		return err
 	}
 
+	err = header.CheckVersion()
+	if err != nil {
+		logger.Errorf("Failed to negotiate storage volume migration protocol")
+		s.sendControl(err)
+		return err
+	}
+
 	bwlimit := ""
 	if *header.Fs != myType {
 		myType = migration.MigrationFSType_RSYNC
@@ -86,6 +106,17 @@ func (s *migrationSourceWs) DoStorage(migrateOp *operation) error {
 		}
 	}
 
+	// Compress the filesystem data if the other side agreed to it
+	if compression != "" && header.HasFeature(migration.FeatureCompression) && header.GetCompression() == compression {
+		migrationCompressionSet(s.fsConn, compression, compressionLevel)
+		defer migrationCompressionUnset(s.fsConn)
+	}
+
+	if header.HasFeature(migration.FeatureRsyncChecksum) {
+		rsyncChecksumSet(s.fsConn)
+		defer rsyncChecksumUnset(s.fsConn)
+	}
+
 	abort := func(err error) error {
 		driver.Cleanup()
 		s.sendControl(err)
@@ -216,6 +247,7 @@ func (c *migrationSink) DoStorage(migrateOp *operation) error {
 	resp := migration.MigrationHeader{
 		Fs: &myType,
 	}
+	migration.Negotiate(&header, &resp)
 
 	// If the storage type the source has doesn't match what we have, then
 	// we have to use rsync.
@@ -225,6 +257,15 @@ func (c *migrationSink) DoStorage(migrateOp *operation) error {
 		resp.Fs = &myType
 	}
 
+	// Only agree to compression we know how to undo
+	compression := ""
+	if header.HasFeature(migration.FeatureCompression) {
+		compression = migrationCompressionAccept(header.GetCompression())
+	}
+	if compression != "" {
+		resp.Compression = proto.String(compression)
+	}
+
 	err = sender(&resp)
 	if err != nil {
 		logger.Errorf("Failed to send storage volume migration header")
@@ -239,6 +280,14 @@ func (c *migrationSink) DoStorage(migrateOp *operation) error {
 		fsConn = c.src.fsConn
 	}
 
+	migrationCompressionSet(fsConn, compression, 0)
+	defer migrationCompressionUnset(fsConn)
+
+	if header.HasFeature(migration.FeatureRsyncChecksum) {
+		rsyncChecksumSet(fsConn)
+		defer rsyncChecksumUnset(fsConn)
+	}
+
 	err = mySink(fsConn, migrateOp, c.dest.storage)
 	if err != nil {
 		logger.Errorf("Failed to start storage volume migration sink")
@@ -0,0 +1,107 @@
+package migration
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// ProtocolVersion is the version of the migration protocol spoken by this
+// LXD. It only needs bumping for changes which can't be negotiated as a
+// feature.
+const ProtocolVersion = 1
+
+// Features of the migration protocol which are negotiated through the
+// migration header.
+const (
+	// FeatureCompression allows compressing the filesystem websocket.
+	FeatureCompression = "compression"
+
+	// FeatureChecksum allows checksumming the filesystem data.
+	FeatureChecksum = "checksum"
+
+	// FeatureRsyncChecksum has rsync compare files by checksum. Sources only
+	// offer it when their storage pool has rsync.checksum enabled, as both
+	// ends of the rsync need to agree on it.
+	FeatureRsyncChecksum = "rsync-checksum"
+)
+
+// Features lists the features of the migration protocol supported by this
+// LXD.
+var Features = []string{
+	FeatureCompression,
+	FeatureChecksum,
+	FeatureRsyncChecksum,
+}
+
+// Features which were negotiated through their own header fields before the
+// feature exchange was added, and so are assumed to be known to peers which
+// don't advertise a protocol version.
+var legacyFeatures = []string{
+	FeatureCompression,
+	FeatureChecksum,
+}
+
+// HasFeature returns whether the sender of the header supports the given
+// feature.
+func (m *MigrationHeader) HasFeature(feature string) bool {
+	if m.GetVersion() == 0 {
+		return shared.StringInSlice(feature, legacyFeatures)
+	}
+
+	return shared.StringInSlice(feature, m.GetFeatures())
+}
+
+// Negotiate fills in the version and features of the response to a header,
+// keeping only the features supported by both ends. Features unknown to this
+// LXD are ignored rather than failing the migration.
+func Negotiate(header *MigrationHeader, resp *MigrationHeader) {
+	// Peers predating the feature exchange don't expect one back
+	if header.GetVersion() == 0 {
+		return
+	}
+
+	version := header.GetVersion()
+	if version > ProtocolVersion {
+		version = ProtocolVersion
+	}
+
+	resp.Version = proto.Int32(version)
+	resp.Features = []string{}
+	for _, feature := range Features {
+		if header.HasFeature(feature) {
+			resp.Features = append(resp.Features, feature)
+		}
+	}
+}
+
+// Offer fills in the version and features supported by this LXD in a header.
+func Offer(header *MigrationHeader) {
+	header.Version = proto.Int32(ProtocolVersion)
+	header.Features = Features
+}
+
+// Withdraw removes a feature offered in a header.
+func (m *MigrationHeader) Withdraw(feature string) {
+	features := []string{}
+	for _, f := range m.Features {
+		if f != feature {
+			features = append(features, f)
+		}
+	}
+
+	m.Features = features
+}
+
+// CheckVersion returns an error if the header uses a version of the migration
+// protocol this LXD doesn't speak, so that the migration fails before any data
+// is transferred.
+func (m *MigrationHeader) CheckVersion() error {
+	if m.GetVersion() < 0 || m.GetVersion() > ProtocolVersion {
+		return fmt.Errorf("Unsupported migration protocol version %d", m.GetVersion())
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// A peer predating the feature exchange gets no version or features back, and
+// is assumed to know about the features it negotiates through header fields.
+func TestNegotiate_Legacy(t *testing.T) {
+	header := &MigrationHeader{}
+	resp := &MigrationHeader{}
+
+	Negotiate(header, resp)
+
+	assert.Nil(t, resp.Version)
+	assert.Nil(t, resp.Features)
+	assert.True(t, header.HasFeature(FeatureCompression))
+	assert.NoError(t, resp.CheckVersion())
+}
+
+// Only the features known to both ends are kept.
+func TestNegotiate_Features(t *testing.T) {
+	header := &MigrationHeader{
+		Version:  proto.Int32(ProtocolVersion),
+		Features: []string{FeatureChecksum, "unknown"},
+	}
+	resp := &MigrationHeader{}
+
+	Negotiate(header, resp)
+
+	assert.Equal(t, int32(ProtocolVersion), resp.GetVersion())
+	assert.Equal(t, []string{FeatureChecksum}, resp.Features)
+	assert.True(t, resp.HasFeature(FeatureChecksum))
+	assert.False(t, resp.HasFeature(FeatureCompression))
+}
+
+// A newer peer is answered with the version spoken by this LXD, while a
+// response with a newer version is refused.
+func TestNegotiate_Version(t *testing.T) {
+	header := &MigrationHeader{Version: proto.Int32(ProtocolVersion + 1)}
+	resp := &MigrationHeader{}
+
+	Negotiate(header, resp)
+
+	assert.Equal(t, int32(ProtocolVersion), resp.GetVersion())
+	assert.NoError(t, resp.CheckVersion())
+	assert.Error(t, header.CheckVersion())
+}
+
+// A withdrawn feature isn't negotiated, without affecting the list of
+// features supported by this LXD.
+func TestNegotiate_Withdraw(t *testing.T) {
+	header := &MigrationHeader{}
+	Offer(header)
+	header.Withdraw(FeatureRsyncChecksum)
+	resp := &MigrationHeader{}
+
+	Negotiate(header, resp)
+
+	assert.False(t, resp.HasFeature(FeatureRsyncChecksum))
+	assert.True(t, resp.HasFeature(FeatureChecksum))
+	assert.Contains(t, Features, FeatureRsyncChecksum)
+}
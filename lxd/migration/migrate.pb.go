@@ -294,13 +294,18 @@ func (m *Snapshot) GetLastUsedDate() int64 {
 }
 
 type MigrationHeader struct {
-	Fs               *MigrationFSType `protobuf:"varint,1,req,name=fs,enum=migration.MigrationFSType" json:"fs,omitempty"`
-	Criu             *CRIUType        `protobuf:"varint,2,opt,name=criu,enum=migration.CRIUType" json:"criu,omitempty"`
-	Idmap            []*IDMapType     `protobuf:"bytes,3,rep,name=idmap" json:"idmap,omitempty"`
-	SnapshotNames    []string         `protobuf:"bytes,4,rep,name=snapshotNames" json:"snapshotNames,omitempty"`
-	Snapshots        []*Snapshot      `protobuf:"bytes,5,rep,name=snapshots" json:"snapshots,omitempty"`
-	Predump          *bool            `protobuf:"varint,7,opt,name=predump" json:"predump,omitempty"`
-	XXX_unrecognized []byte           `json:"-"`
+	Fs            *MigrationFSType `protobuf:"varint,1,req,name=fs,enum=migration.MigrationFSType" json:"fs,omitempty"`
+	Criu          *CRIUType        `protobuf:"varint,2,opt,name=criu,enum=migration.CRIUType" json:"criu,omitempty"`
+	Idmap         []*IDMapType     `protobuf:"bytes,3,rep,name=idmap" json:"idmap,omitempty"`
+	SnapshotNames []string         `protobuf:"bytes,4,rep,name=snapshotNames" json:"snapshotNames,omitempty"`
+	Snapshots     []*Snapshot      `protobuf:"bytes,5,rep,name=snapshots" json:"snapshots,omitempty"`
+	Predump       *bool            `protobuf:"varint,7,opt,name=predump" json:"predump,omitempty"`
+	Compression   *string          `protobuf:"bytes,8,opt,name=compression" json:"compression,omitempty"`
+	Checksum      *string          `protobuf:"bytes,9,opt,name=checksum" json:"checksum,omitempty"`
+	// version of the migration protocol and features supported by the sender
+	Version          *int32   `protobuf:"varint,10,opt,name=version" json:"version,omitempty"`
+	Features         []string `protobuf:"bytes,11,rep,name=features" json:"features,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
 }
 
 func (m *MigrationHeader) Reset()                    { *m = MigrationHeader{} }
@@ -350,10 +355,40 @@ func (m *MigrationHeader) GetPredump() bool {
 	return false
 }
 
+func (m *MigrationHeader) GetCompression() string {
+	if m != nil && m.Compression != nil {
+		return *m.Compression
+	}
+	return ""
+}
+
+func (m *MigrationHeader) GetChecksum() string {
+	if m != nil && m.Checksum != nil {
+		return *m.Checksum
+	}
+	return ""
+}
+
+func (m *MigrationHeader) GetVersion() int32 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
+
+func (m *MigrationHeader) GetFeatures() []string {
+	if m != nil {
+		return m.Features
+	}
+	return nil
+}
+
 type MigrationControl struct {
 	Success *bool `protobuf:"varint,1,req,name=success" json:"success,omitempty"`
 	// optional failure message if sending a failure
-	Message          *string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Message *string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	// optional checksum of the filesystem data, sent by the source
+	Checksum         *string `protobuf:"bytes,3,opt,name=checksum" json:"checksum,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -376,6 +411,13 @@ func (m *MigrationControl) GetMessage() string {
 	return ""
 }
 
+func (m *MigrationControl) GetChecksum() string {
+	if m != nil && m.Checksum != nil {
+		return *m.Checksum
+	}
+	return ""
+}
+
 type MigrationSync struct {
 	FinalPreDump     *bool  `protobuf:"varint,1,req,name=finalPreDump" json:"finalPreDump,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
@@ -583,64 +625,67 @@ func init() {
 func init() { proto.RegisterFile("lxd/migration/migrate.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 935 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x94, 0x4d, 0x6f, 0xdb, 0x46,
-	0x13, 0xc7, 0x1f, 0x51, 0x94, 0x2d, 0x0e, 0x65, 0x47, 0xd9, 0x18, 0x0f, 0x88, 0xa4, 0x2f, 0x2a,
-	0x93, 0xa2, 0xaa, 0x0f, 0x76, 0xaa, 0xa0, 0x40, 0x7b, 0xac, 0xe5, 0xba, 0x09, 0x90, 0xb8, 0xc6,
-	0xca, 0x46, 0xd1, 0x5e, 0x88, 0x2d, 0x39, 0x94, 0x17, 0xe6, 0x1b, 0x76, 0x49, 0x3b, 0xf2, 0xa5,
-	0xa7, 0x7e, 0x94, 0x7e, 0x9e, 0x9e, 0xfa, 0x7d, 0x8a, 0xdd, 0xe5, 0xd2, 0x54, 0x1b, 0xa0, 0xb7,
-	0x9d, 0xff, 0xfc, 0x38, 0xb3, 0xf3, 0xc2, 0x85, 0x67, 0xd9, 0xfb, 0xe4, 0x38, 0xe7, 0x6b, 0xc1,
-	0x6a, 0x5e, 0x16, 0xed, 0x09, 0x8f, 0x2a, 0x51, 0xd6, 0x25, 0xf1, 0x3a, 0x47, 0xf8, 0x1b, 0x78,
-	0x6f, 0x4e, 0xdf, 0xb1, 0xea, 0x72, 0x53, 0x21, 0x39, 0x80, 0x11, 0x97, 0x0d, 0x4f, 0x82, 0xc1,
-	0xcc, 0x99, 0x8f, 0xa9, 0x31, 0x8c, 0xba, 0xe6, 0x49, 0xe0, 0x58, 0x75, 0xcd, 0x13, 0xf2, 0x7f,
-	0xd8, 0xb9, 0x2e, 0x65, 0xcd, 0x93, 0x60, 0x38, 0x73, 0xe6, 0x23, 0xda, 0x5a, 0x84, 0x80, 0x5b,
-	0x48, 0x9e, 0x04, 0xae, 0x56, 0xf5, 0x99, 0x3c, 0x85, 0x71, 0xce, 0x2a, 0xc1, 0x8a, 0x35, 0x06,
-	0x23, 0xad, 0x77, 0x76, 0xf8, 0x12, 0x76, 0x96, 0x65, 0x91, 0xf2, 0x35, 0x99, 0xc2, 0xf0, 0x06,
-	0x37, 0x3a, 0xb7, 0x47, 0xd5, 0x51, 0x65, 0xbe, 0x65, 0x59, 0x83, 0x3a, 0xb3, 0x47, 0x8d, 0x11,
-	0xfe, 0x00, 0x3b, 0xa7, 0x78, 0xcb, 0x63, 0xd4, 0xb9, 0x58, 0x8e, 0xed, 0x27, 0xfa, 0x4c, 0xbe,
-	0x84, 0x9d, 0x58, 0xc7, 0x0b, 0x9c, 0xd9, 0x70, 0xee, 0x2f, 0x1e, 0x1f, 0x75, 0xc5, 0x1e, 0x99,
-	0x44, 0xb4, 0x05, 0xc2, 0x3f, 0x1d, 0x18, 0xaf, 0x0a, 0x56, 0xc9, 0xeb, 0xb2, 0xfe, 0x60, 0xac,
-	0x57, 0xe0, 0x67, 0x65, 0xcc, 0xb2, 0xe5, 0x7f, 0x04, 0xec, 0x53, 0xaa, 0xd8, 0x4a, 0x94, 0x29,
-	0xcf, 0x50, 0x06, 0xc3, 0xd9, 0x70, 0xee, 0xd1, 0xce, 0x26, 0x1f, 0x81, 0x87, 0xd5, 0x35, 0xe6,
-	0x28, 0x58, 0xa6, 0x3b, 0x34, 0xa6, 0x0f, 0x02, 0xf9, 0x1a, 0x26, 0x3a, 0x90, 0xa9, 0x4e, 0x06,
-	0xa3, 0x7f, 0xe5, 0x33, 0x1e, 0xba, 0x85, 0x91, 0x10, 0x26, 0x4c, 0xc4, 0xd7, 0xbc, 0xc6, 0xb8,
-	0x6e, 0x04, 0x06, 0x3b, 0xba, 0xc3, 0x5b, 0x9a, 0xba, 0x94, 0xac, 0x59, 0x8d, 0x69, 0x93, 0x05,
-	0xbb, 0x3a, 0x6f, 0x67, 0x93, 0xe7, 0xb0, 0x17, 0x0b, 0xd4, 0x09, 0xa2, 0x84, 0xd5, 0x18, 0x8c,
-	0x67, 0xce, 0x7c, 0x48, 0x27, 0x56, 0x3c, 0x65, 0x35, 0x92, 0x17, 0xb0, 0x9f, 0x31, 0x59, 0x47,
-	0x8d, 0xc4, 0xc4, 0x50, 0x9e, 0xa1, 0x94, 0x7a, 0x25, 0x31, 0x51, 0x54, 0xf8, 0xbb, 0x03, 0x8f,
-	0xde, 0xd9, 0xdb, 0xbe, 0x46, 0x96, 0xa0, 0x20, 0x87, 0xe0, 0xa4, 0x52, 0xb7, 0x75, 0x7f, 0xf1,
-	0xb4, 0x57, 0x4b, 0xc7, 0x9d, 0xad, 0xd4, 0xf2, 0x51, 0x27, 0x95, 0xe4, 0x0b, 0x70, 0x63, 0xc1,
-	0x9b, 0xc0, 0x99, 0x0d, 0xe6, 0xfb, 0x8b, 0x27, 0xfd, 0x4e, 0xd3, 0x37, 0x57, 0x1a, 0xd3, 0x00,
-	0x39, 0x84, 0x11, 0x4f, 0x72, 0x56, 0xe9, 0x0e, 0xfb, 0x8b, 0x83, 0x1e, 0xd9, 0xad, 0x33, 0x35,
-	0x08, 0x79, 0x01, 0x7b, 0xb2, 0x9d, 0xf2, 0x39, 0xcb, 0x51, 0x06, 0xae, 0x9e, 0xca, 0xb6, 0x48,
-	0xbe, 0x02, 0xcf, 0x0a, 0xb6, 0xf3, 0xfd, 0xfc, 0x76, 0x4f, 0xe8, 0x03, 0x45, 0x02, 0xd8, 0xad,
-	0x04, 0x26, 0x4d, 0x5e, 0x05, 0xbb, 0xb3, 0xc1, 0x7c, 0x4c, 0xad, 0x19, 0x9e, 0xc1, 0xb4, 0x2b,
-	0x6f, 0x59, 0x16, 0xb5, 0x28, 0x33, 0x45, 0xcb, 0x26, 0x8e, 0x51, 0xca, 0xf6, 0xf7, 0xb2, 0xa6,
-	0xf2, 0xe4, 0x28, 0x25, 0x5b, 0xa3, 0x2e, 0xdc, 0xa3, 0xd6, 0x0c, 0x5f, 0xc1, 0x5e, 0x17, 0x67,
-	0xb5, 0x29, 0x62, 0x35, 0xeb, 0x94, 0x17, 0x2c, 0xbb, 0x10, 0x78, 0xaa, 0xf2, 0x9a, 0x48, 0x5b,
-	0x5a, 0xf8, 0xc7, 0x10, 0xa6, 0xea, 0x16, 0x91, 0x9a, 0xb0, 0x8c, 0xb0, 0xa8, 0xc5, 0x46, 0x0d,
-	0x39, 0x15, 0x88, 0xf7, 0xbc, 0x58, 0x47, 0x35, 0x6f, 0xf7, 0x7c, 0x8f, 0x4e, 0xac, 0x78, 0xc9,
-	0x73, 0x24, 0x9f, 0x82, 0x9f, 0x8a, 0xf2, 0x1e, 0x0b, 0x83, 0x38, 0x1a, 0x01, 0x23, 0x69, 0xe0,
-	0x33, 0x98, 0xe4, 0x98, 0xeb, 0xe0, 0x9a, 0x18, 0x6a, 0xc2, 0x6f, 0x35, 0x8d, 0x3c, 0x87, 0xbd,
-	0x1c, 0xf3, 0x3b, 0xc1, 0x6b, 0x34, 0x8c, 0x6b, 0x12, 0x59, 0xd1, 0x42, 0x15, 0x5b, 0xa3, 0x8c,
-	0x64, 0xcc, 0x8a, 0x02, 0x13, 0xfd, 0x2a, 0xb8, 0x74, 0xa2, 0xc5, 0x95, 0xd1, 0xc8, 0x4b, 0x38,
-	0x68, 0xa1, 0x1b, 0x5e, 0x55, 0x98, 0x44, 0x15, 0x13, 0x58, 0xd4, 0x7a, 0xbf, 0x5d, 0x4a, 0x0c,
-	0x6b, 0x5c, 0x17, 0xda, 0xf3, 0x10, 0x56, 0x65, 0xaa, 0xb1, 0xd0, 0xab, 0x6e, 0xc3, 0xfe, 0x64,
-	0x34, 0x05, 0x71, 0x91, 0xb3, 0x2a, 0x12, 0x28, 0xcb, 0xec, 0x56, 0xad, 0xfb, 0x40, 0x5d, 0x50,
-	0x8b, 0xd4, 0x68, 0xe4, 0x63, 0x00, 0x13, 0x29, 0x63, 0xf7, 0x1b, 0xbd, 0xea, 0x2e, 0xf5, 0xb4,
-	0xf2, 0x96, 0xdd, 0x6f, 0xac, 0x3b, 0xaa, 0x78, 0x85, 0x32, 0x80, 0xd9, 0xc0, 0xba, 0x2f, 0x94,
-	0xa0, 0x7e, 0x96, 0xce, 0x1d, 0xfd, 0xda, 0xa4, 0x32, 0xf0, 0x35, 0x32, 0xb1, 0xc8, 0x49, 0x93,
-	0xca, 0xf0, 0xaf, 0x01, 0x3c, 0x11, 0x28, 0xeb, 0x52, 0xe0, 0xd6, 0xa8, 0x3e, 0x37, 0x5f, 0xcb,
-	0x28, 0x2e, 0x73, 0x55, 0xb2, 0x79, 0x8e, 0x5d, 0x6a, 0x6a, 0x5b, 0xb6, 0x22, 0x39, 0x84, 0xc7,
-	0xdb, 0xed, 0x89, 0xcb, 0x3b, 0x3d, 0x32, 0x97, 0x3e, 0xea, 0xf7, 0x66, 0x59, 0xde, 0xa9, 0xb9,
-	0xa5, 0xa5, 0xb8, 0xe9, 0x86, 0xdf, 0xce, 0xad, 0xd5, 0xec, 0x68, 0xed, 0x65, 0x7a, 0x63, 0xf3,
-	0x5b, 0x4d, 0x23, 0xdd, 0xc5, 0x5a, 0x51, 0x8d, 0x6d, 0xd0, 0x5d, 0x8c, 0xb6, 0x62, 0xf8, 0x1e,
-	0xfc, 0x7e, 0x39, 0xc7, 0xe0, 0x26, 0x66, 0x55, 0x07, 0x73, 0x7f, 0xf1, 0xac, 0xf7, 0x4f, 0xfd,
-	0x73, 0x49, 0xa9, 0x06, 0xc9, 0x37, 0xb0, 0xdb, 0x26, 0xd0, 0xbf, 0x83, 0xbf, 0xf8, 0xa4, 0xf7,
-	0xcd, 0x07, 0x1a, 0x46, 0x2d, 0x7e, 0xf8, 0x6d, 0xef, 0xf5, 0x31, 0xaf, 0x0a, 0xf1, 0x60, 0x44,
-	0x57, 0x3f, 0x9f, 0x2f, 0xa7, 0xff, 0x53, 0xc7, 0x93, 0x4b, 0x7a, 0xb6, 0x9a, 0x0e, 0xc8, 0x2e,
-	0x0c, 0x7f, 0x39, 0x5b, 0x4d, 0x1d, 0x75, 0xa0, 0x27, 0xa7, 0xd3, 0xe1, 0xe1, 0x31, 0x8c, 0xed,
-	0x13, 0x43, 0xf6, 0x01, 0xd4, 0x39, 0xea, 0x7d, 0x78, 0xf1, 0xfa, 0xbb, 0xab, 0xb7, 0xd3, 0x01,
-	0x19, 0x83, 0x7b, 0xfe, 0xe3, 0xf9, 0xf7, 0x53, 0xe7, 0xef, 0x00, 0x00, 0x00, 0xff, 0xff, 0x8d,
-	0x00, 0xf4, 0x67, 0x61, 0x07, 0x00, 0x00,
+	// 991 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0x4d, 0x6f, 0xdb, 0x46,
+	0x13, 0x7e, 0x45, 0x52, 0x96, 0x38, 0x94, 0x1c, 0x65, 0x13, 0xbc, 0x20, 0x92, 0x7e, 0xa8, 0x4c,
+	0x8a, 0xaa, 0x3e, 0xd8, 0xa9, 0x82, 0x02, 0xed, 0xb1, 0x96, 0xeb, 0x26, 0x40, 0xe2, 0x1a, 0x2b,
+	0x1b, 0x45, 0x7b, 0x21, 0x36, 0xe4, 0x50, 0x5e, 0x98, 0x5f, 0xd8, 0x25, 0xed, 0xc8, 0x97, 0xfe,
+	0x9a, 0xfe, 0x9e, 0x9e, 0xfa, 0x63, 0x7a, 0x2b, 0x76, 0x97, 0xa4, 0xa9, 0x34, 0x40, 0x6f, 0x3b,
+	0xcf, 0x3c, 0x3b, 0xcf, 0xec, 0x7c, 0x90, 0xf0, 0x34, 0x7d, 0x1f, 0x1f, 0x65, 0x7c, 0x23, 0x58,
+	0xc5, 0x8b, 0xbc, 0x39, 0xe1, 0x61, 0x29, 0x8a, 0xaa, 0x20, 0x6e, 0xe7, 0x08, 0x7e, 0x07, 0xf7,
+	0xf5, 0xc9, 0x5b, 0x56, 0x5e, 0x6c, 0x4b, 0x24, 0x8f, 0x61, 0xc8, 0x65, 0xcd, 0x63, 0x7f, 0x30,
+	0xb7, 0x16, 0x63, 0x6a, 0x0c, 0x83, 0x6e, 0x78, 0xec, 0x5b, 0x2d, 0xba, 0xe1, 0x31, 0xf9, 0x3f,
+	0xec, 0x5d, 0x15, 0xb2, 0xe2, 0xb1, 0x6f, 0xcf, 0xad, 0xc5, 0x90, 0x36, 0x16, 0x21, 0xe0, 0xe4,
+	0x92, 0xc7, 0xbe, 0xa3, 0x51, 0x7d, 0x26, 0x4f, 0x60, 0x9c, 0xb1, 0x52, 0xb0, 0x7c, 0x83, 0xfe,
+	0x50, 0xe3, 0x9d, 0x1d, 0xbc, 0x80, 0xbd, 0x55, 0x91, 0x27, 0x7c, 0x43, 0x66, 0x60, 0x5f, 0xe3,
+	0x56, 0x6b, 0xbb, 0x54, 0x1d, 0x95, 0xf2, 0x0d, 0x4b, 0x6b, 0xd4, 0xca, 0x2e, 0x35, 0x46, 0xf0,
+	0x13, 0xec, 0x9d, 0xe0, 0x0d, 0x8f, 0x50, 0x6b, 0xb1, 0x0c, 0x9b, 0x2b, 0xfa, 0x4c, 0xbe, 0x86,
+	0xbd, 0x48, 0xc7, 0xf3, 0xad, 0xb9, 0xbd, 0xf0, 0x96, 0x0f, 0x0f, 0xbb, 0xc7, 0x1e, 0x1a, 0x21,
+	0xda, 0x10, 0x82, 0x3f, 0x2d, 0x18, 0xaf, 0x73, 0x56, 0xca, 0xab, 0xa2, 0xfa, 0x68, 0xac, 0x97,
+	0xe0, 0xa5, 0x45, 0xc4, 0xd2, 0xd5, 0x7f, 0x04, 0xec, 0xb3, 0xd4, 0x63, 0x4b, 0x51, 0x24, 0x3c,
+	0x45, 0xe9, 0xdb, 0x73, 0x7b, 0xe1, 0xd2, 0xce, 0x26, 0x9f, 0x80, 0x8b, 0xe5, 0x15, 0x66, 0x28,
+	0x58, 0xaa, 0x2b, 0x34, 0xa6, 0xf7, 0x00, 0xf9, 0x16, 0x26, 0x3a, 0x90, 0x79, 0x9d, 0xf4, 0x87,
+	0xff, 0xd2, 0x33, 0x1e, 0xba, 0x43, 0x23, 0x01, 0x4c, 0x98, 0x88, 0xae, 0x78, 0x85, 0x51, 0x55,
+	0x0b, 0xf4, 0xf7, 0x74, 0x85, 0x77, 0x30, 0x95, 0x94, 0xac, 0x58, 0x85, 0x49, 0x9d, 0xfa, 0x23,
+	0xad, 0xdb, 0xd9, 0xe4, 0x19, 0x4c, 0x23, 0x81, 0x5a, 0x20, 0x8c, 0x59, 0x85, 0xfe, 0x78, 0x6e,
+	0x2d, 0x6c, 0x3a, 0x69, 0xc1, 0x13, 0x56, 0x21, 0x79, 0x0e, 0xfb, 0x29, 0x93, 0x55, 0x58, 0x4b,
+	0x8c, 0x0d, 0xcb, 0x35, 0x2c, 0x85, 0x5e, 0x4a, 0x8c, 0x15, 0x2b, 0xf8, 0xdb, 0x82, 0x07, 0x6f,
+	0xdb, 0x6c, 0x5f, 0x21, 0x8b, 0x51, 0x90, 0x03, 0xb0, 0x12, 0xa9, 0xcb, 0xba, 0xbf, 0x7c, 0xd2,
+	0x7b, 0x4b, 0xc7, 0x3b, 0x5d, 0xab, 0xe1, 0xa3, 0x56, 0x22, 0xc9, 0x57, 0xe0, 0x44, 0x82, 0xd7,
+	0xbe, 0x35, 0x1f, 0x2c, 0xf6, 0x97, 0x8f, 0xfa, 0x95, 0xa6, 0xaf, 0x2f, 0x35, 0x4d, 0x13, 0xc8,
+	0x01, 0x0c, 0x79, 0x9c, 0xb1, 0x52, 0x57, 0xd8, 0x5b, 0x3e, 0xee, 0x31, 0xbb, 0x71, 0xa6, 0x86,
+	0x42, 0x9e, 0xc3, 0x54, 0x36, 0x5d, 0x3e, 0x63, 0x19, 0x4a, 0xdf, 0xd1, 0x5d, 0xd9, 0x05, 0xc9,
+	0x37, 0xe0, 0xb6, 0x40, 0x5b, 0xf9, 0xbe, 0x7e, 0x3b, 0x27, 0xf4, 0x9e, 0x45, 0x7c, 0x18, 0x95,
+	0x02, 0xe3, 0x3a, 0x2b, 0xfd, 0xd1, 0x7c, 0xb0, 0x18, 0xd3, 0xd6, 0x24, 0x73, 0xf0, 0xa2, 0x22,
+	0x2b, 0x05, 0x4a, 0xc9, 0x8b, 0xdc, 0x1f, 0xcf, 0x07, 0x0b, 0x97, 0xf6, 0x21, 0xd5, 0x90, 0xe8,
+	0x0a, 0xa3, 0x6b, 0x59, 0x67, 0xbe, 0xab, 0xdd, 0x9d, 0xad, 0xe2, 0xde, 0xa0, 0xd0, 0x37, 0x61,
+	0x3e, 0x58, 0x0c, 0x69, 0x6b, 0xaa, 0x5b, 0x09, 0x32, 0xd5, 0x51, 0xe9, 0x7b, 0x66, 0xb6, 0x5a,
+	0x3b, 0x78, 0x07, 0xb3, 0xae, 0xa4, 0xab, 0x22, 0xaf, 0x44, 0x91, 0xaa, 0x48, 0xb2, 0x8e, 0x22,
+	0x94, 0xb2, 0x59, 0xe9, 0xd6, 0x54, 0x9e, 0x0c, 0xa5, 0x64, 0x1b, 0xd4, 0xc5, 0x76, 0x69, 0x6b,
+	0xee, 0x64, 0x66, 0xef, 0x66, 0x16, 0xbc, 0x84, 0x69, 0xa7, 0xb1, 0xde, 0xe6, 0x91, 0x9a, 0xbd,
+	0x84, 0xe7, 0x2c, 0x3d, 0x17, 0x78, 0xa2, 0xea, 0x60, 0x54, 0x76, 0xb0, 0xe0, 0x0f, 0x1b, 0x66,
+	0xaa, 0x2a, 0xa1, 0x9a, 0x38, 0x19, 0x62, 0x5e, 0x89, 0xad, 0x1a, 0xba, 0x44, 0x20, 0xde, 0xf1,
+	0x7c, 0x13, 0x56, 0xbc, 0xd9, 0xbb, 0x29, 0x9d, 0xb4, 0xe0, 0x05, 0xcf, 0x90, 0x7c, 0x0e, 0x5e,
+	0x22, 0x8a, 0x3b, 0xcc, 0x0d, 0xc5, 0xd2, 0x14, 0x30, 0x90, 0x26, 0x7c, 0x01, 0x93, 0x0c, 0x33,
+	0x1d, 0x5c, 0x33, 0x6c, 0xcd, 0xf0, 0x1a, 0x4c, 0x53, 0x9e, 0xc1, 0x34, 0xc3, 0xec, 0x56, 0xf0,
+	0x0a, 0x0d, 0xc7, 0x31, 0x42, 0x2d, 0xd8, 0x92, 0x4a, 0xb6, 0x41, 0x19, 0xca, 0x88, 0xe5, 0x39,
+	0xc6, 0xfa, 0x2b, 0xe5, 0xd0, 0x89, 0x06, 0xd7, 0x06, 0x23, 0x2f, 0xe0, 0x71, 0x43, 0xba, 0xe6,
+	0x65, 0x89, 0x71, 0x58, 0x32, 0x81, 0x79, 0xa5, 0xf7, 0xcd, 0xa1, 0xc4, 0x70, 0x8d, 0xeb, 0x5c,
+	0x7b, 0xee, 0xc3, 0x2a, 0xa5, 0x0a, 0x73, 0x7f, 0xd4, 0x0b, 0xfb, 0x8b, 0xc1, 0x14, 0x89, 0x8b,
+	0x8c, 0x95, 0xa1, 0x40, 0x59, 0xa4, 0x37, 0xa8, 0xa7, 0x65, 0x4a, 0x27, 0x1a, 0xa4, 0x06, 0x23,
+	0x9f, 0x02, 0x98, 0x48, 0x29, 0xbb, 0xdb, 0xea, 0xd5, 0x73, 0xa8, 0xab, 0x91, 0x37, 0xec, 0x6e,
+	0xdb, 0xba, 0xc3, 0x92, 0x97, 0x28, 0xf5, 0xd0, 0x34, 0xee, 0x73, 0x05, 0xa8, 0xe5, 0xed, 0xdc,
+	0xe1, 0xbb, 0x3a, 0x51, 0xc3, 0x33, 0x68, 0x13, 0x51, 0x94, 0xe3, 0x3a, 0x91, 0xc1, 0x5f, 0x03,
+	0x78, 0x24, 0x50, 0x56, 0x85, 0xc0, 0x9d, 0x56, 0x7d, 0x69, 0x6e, 0xcb, 0x50, 0xcd, 0x2f, 0x13,
+	0x68, 0x7e, 0x0f, 0x0e, 0x35, 0x6f, 0x5b, 0x35, 0x20, 0x39, 0x80, 0x87, 0xbb, 0xe5, 0x89, 0x8a,
+	0x5b, 0xdd, 0x32, 0x87, 0x3e, 0xe8, 0xd7, 0x66, 0x55, 0xdc, 0xaa, 0xbe, 0x25, 0x85, 0xb8, 0xee,
+	0x9a, 0xdf, 0xf4, 0xad, 0xc1, 0xda, 0xd6, 0xb6, 0xc9, 0xf4, 0xda, 0xe6, 0x35, 0x98, 0xa6, 0x74,
+	0x89, 0x35, 0xa0, 0x6a, 0xdb, 0xa0, 0x4b, 0x8c, 0x36, 0x60, 0xf0, 0x1e, 0xbc, 0xfe, 0x73, 0x8e,
+	0xc0, 0x89, 0xcd, 0xa8, 0x0e, 0x16, 0xde, 0xf2, 0x69, 0x6f, 0xc7, 0x3f, 0x1c, 0x52, 0xaa, 0x89,
+	0xe4, 0x3b, 0x18, 0x35, 0x02, 0x7a, 0x55, 0xbc, 0xe5, 0x67, 0xbd, 0x3b, 0x1f, 0x29, 0x18, 0x6d,
+	0xe9, 0x07, 0xdf, 0xc3, 0x83, 0x0f, 0xbe, 0x72, 0xc4, 0x85, 0x21, 0x5d, 0xff, 0x7a, 0xb6, 0x9a,
+	0xfd, 0x4f, 0x1d, 0x8f, 0x2f, 0xe8, 0xe9, 0x7a, 0x36, 0x20, 0x23, 0xb0, 0x7f, 0x3b, 0x5d, 0xcf,
+	0x2c, 0x75, 0xa0, 0xc7, 0x27, 0x33, 0xfb, 0xe0, 0x08, 0xc6, 0xed, 0x27, 0x8f, 0xec, 0x03, 0xa8,
+	0x73, 0xd8, 0xbb, 0x78, 0xfe, 0xea, 0x87, 0xcb, 0x37, 0xb3, 0x01, 0x19, 0x83, 0x73, 0xf6, 0xf3,
+	0xd9, 0x8f, 0x33, 0xeb, 0x9f, 0x01, 0x00, 0x0c, 0x14, 0x11, 0x18, 0xf1, 0x07, 0x00, 0x00,
 }
@@ -560,6 +560,12 @@ func networkPost(d *Daemon, r *http.Request) Response {
 }
 
 func networkPut(d *Daemon, r *http.Request) Response {
+	// If a target was specified, forward the request to the relevant node.
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
 	name := mux.Vars(r)["name"]
 
 	// Get the existing network
@@ -568,8 +574,14 @@ func networkPut(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	targetNode := r.FormValue("target")
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
+		return SmartError(err)
+	}
+
 	// Validate the ETag
-	etag := []interface{}{dbInfo.Name, dbInfo.Managed, dbInfo.Type, dbInfo.Description, dbInfo.Config}
+	etag := []interface{}{dbInfo.Name, dbInfo.Managed, dbInfo.Type, dbInfo.Description, networkConfigForEtag(dbInfo.Config, clustered, targetNode)}
 
 	err = util.EtagCheck(r, etag)
 	if err != nil {
@@ -581,20 +593,39 @@ func networkPut(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	if clustered {
+		req.Config, err = networkClusterConfig(dbInfo.Config, req.Config, targetNode)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
 	return doNetworkUpdate(d, name, dbInfo.Config, req)
 }
 
 func networkPatch(d *Daemon, r *http.Request) Response {
+	// If a target was specified, forward the request to the relevant node.
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
 	name := mux.Vars(r)["name"]
 
 	// Get the existing network
 	_, dbInfo, err := d.cluster.NetworkGet(name)
-	if dbInfo != nil {
+	if err != nil {
+		return SmartError(err)
+	}
+
+	targetNode := r.FormValue("target")
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
 		return SmartError(err)
 	}
 
 	// Validate the ETag
-	etag := []interface{}{dbInfo.Name, dbInfo.Managed, dbInfo.Type, dbInfo.Description, dbInfo.Config}
+	etag := []interface{}{dbInfo.Name, dbInfo.Managed, dbInfo.Type, dbInfo.Description, networkConfigForEtag(dbInfo.Config, clustered, targetNode)}
 
 	err = util.EtagCheck(r, etag)
 	if err != nil {
@@ -611,6 +642,13 @@ func networkPatch(d *Daemon, r *http.Request) Response {
 		req.Config = map[string]string{}
 	}
 
+	if clustered {
+		req.Config, err = networkClusterConfig(dbInfo.Config, req.Config, targetNode)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
 	for k, v := range dbInfo.Config {
 		_, ok := req.Config[k]
 		if !ok {
@@ -621,6 +659,48 @@ func networkPatch(d *Daemon, r *http.Request) Response {
 	return doNetworkUpdate(d, name, dbInfo.Config, req)
 }
 
+// Get the configuration of a network covered by its ETag, which in a cluster
+// only includes the node-specific keys when a target node is given.
+func networkConfigForEtag(dbConfig map[string]string, clustered bool, targetNode string) map[string]string {
+	if !clustered || targetNode != "" {
+		return dbConfig
+	}
+
+	config := util.CopyConfig(dbConfig)
+	for _, key := range db.NetworkNodeConfigKeys {
+		delete(config, key)
+	}
+	return config
+}
+
+// Complement the config of a PUT/PATCH request in a cluster. Without a target
+// node, the node-specific keys can't be changed and are taken from the db.
+// With one, only those keys can be changed and the others are taken from the
+// db.
+func networkClusterConfig(dbConfig, reqConfig map[string]string, targetNode string) (map[string]string, error) {
+	config := util.CopyConfig(reqConfig)
+
+	for key, value := range reqConfig {
+		isNodeKey := shared.StringInSlice(key, db.NetworkNodeConfigKeys)
+		if targetNode == "" && isNodeKey {
+			return nil, fmt.Errorf("node-specific config key %s can't be changed without a target node", key)
+		}
+
+		if targetNode != "" && !isNodeKey && value != dbConfig[key] {
+			return nil, fmt.Errorf("config key %s isn't node-specific and can't be changed for a single node", key)
+		}
+	}
+
+	for key, value := range dbConfig {
+		isNodeKey := shared.StringInSlice(key, db.NetworkNodeConfigKeys)
+		if isNodeKey == (targetNode == "") {
+			config[key] = value
+		}
+	}
+
+	return config, nil
+}
+
 func doNetworkUpdate(d *Daemon, name string, oldConfig map[string]string, req api.NetworkPut) Response {
 	// Validate the configuration
 	err := networkValidateConfig(name, req.Config)
@@ -664,32 +744,53 @@ func networkLeasesGet(d *Daemon, r *http.Request) Response {
 		return NotFound(errors.New("Leases not found"))
 	}
 
-	if !shared.PathExists(leaseFile) {
-		return BadRequest(fmt.Errorf("No lease file for network"))
-	}
-
-	// Read all the leases
-	content, err := ioutil.ReadFile(leaseFile)
+	clustered, err := cluster.Enabled(d.db)
 	if err != nil {
 		return SmartError(err)
 	}
 
+	// Other nodes only get asked for the dynamic leases of their own lease
+	// file, attributed to containers by the node which got the request
+	if isClusterNotification(r) {
+		leases, err := networkDynamicLeases(d, leaseFile, clustered)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		return SyncResponse(true, leases)
+	}
+
+	if !clustered && !shared.PathExists(leaseFile) {
+		return BadRequest(fmt.Errorf("No lease file for network"))
+	}
+
 	leases := []api.NetworkLease{}
 
 	// Get all the containers
-	containers, err := d.cluster.ContainersList(db.CTypeRegular)
+	var nodes map[string]string // Node names by container
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		nodes, err = tx.ContainersByNodeName()
+		return err
+	})
 	if err != nil {
 		return SmartError(err)
 	}
 
-	// Get static leases
-	for _, cName := range containers {
+	// Get static leases, and the containers owning each MAC address
+	containers := map[string]string{}
+	for cName := range nodes {
 		// Load the container
 		c, err := containerLoadByName(d.State(), cName)
 		if err != nil {
 			continue
 		}
 
+		location := ""
+		if clustered {
+			location = nodes[cName]
+		}
+
 		// Go through all its devices (including profiles
 		for k, d := range c.ExpandedDevices() {
 			// Skip uninteresting entries
@@ -703,28 +804,110 @@ func networkLeasesGet(d *Daemon, r *http.Request) Response {
 				continue
 			}
 
+			containers[strings.ToLower(d["hwaddr"])] = cName
+
 			// Add the lease
 			if d["ipv4.address"] != "" {
 				leases = append(leases, api.NetworkLease{
-					Hostname: cName,
-					Address:  d["ipv4.address"],
-					Hwaddr:   d["hwaddr"],
-					Type:     "static",
+					Hostname:  cName,
+					Address:   d["ipv4.address"],
+					Hwaddr:    d["hwaddr"],
+					Type:      "static",
+					Container: cName,
+					Location:  location,
 				})
 			}
 
 			if d["ipv6.address"] != "" {
 				leases = append(leases, api.NetworkLease{
-					Hostname: cName,
-					Address:  d["ipv6.address"],
-					Hwaddr:   d["hwaddr"],
-					Type:     "static",
+					Hostname:  cName,
+					Address:   d["ipv6.address"],
+					Hwaddr:    d["hwaddr"],
+					Type:      "static",
+					Container: cName,
+					Location:  location,
 				})
 			}
 		}
 	}
 
-	// Get dynamic leases
+	// Get dynamic leases, from all the nodes of the cluster
+	dynamic, err := networkDynamicLeases(d, leaseFile, clustered)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if clustered {
+		notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), cluster.NotifyAlive)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		mu := sync.Mutex{}
+		err = notifier(func(client lxd.ContainerServer) error {
+			nodeLeases, err := client.GetNetworkLeases(name)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			dynamic = append(dynamic, nodeLeases...)
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	for _, lease := range dynamic {
+		// Look for an existing static entry
+		found := false
+		for _, entry := range leases {
+			if entry.Hwaddr == lease.Hwaddr && entry.Address == lease.Address {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		// Add the lease to the list
+		lease.Container = containers[strings.ToLower(lease.Hwaddr)]
+		leases = append(leases, lease)
+	}
+
+	return SyncResponse(true, leases)
+}
+
+// Parse the dynamic leases of the given dnsmasq lease file, which may not
+// exist yet.
+func networkDynamicLeases(d *Daemon, leaseFile string, clustered bool) ([]api.NetworkLease, error) {
+	leases := []api.NetworkLease{}
+
+	content, err := ioutil.ReadFile(leaseFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return leases, nil
+		}
+
+		return nil, err
+	}
+
+	location := ""
+	if clustered {
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			location, err = tx.NodeName()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	for _, lease := range strings.Split(string(content), "\n") {
 		fields := strings.Fields(lease)
 		if len(fields) >= 5 {
@@ -736,30 +919,18 @@ func networkLeasesGet(d *Daemon, r *http.Request) Response {
 				macStr = fields[4][len(fields[4])-17:]
 			}
 
-			// Look for an existing static entry
-			found := false
-			for _, entry := range leases {
-				if entry.Hwaddr == macStr && entry.Address == fields[2] {
-					found = true
-					break
-				}
-			}
-
-			if found {
-				continue
-			}
-
 			// Add the lease to the list
 			leases = append(leases, api.NetworkLease{
 				Hostname: fields[3],
 				Address:  fields[2],
 				Hwaddr:   macStr,
 				Type:     "dynamic",
+				Location: location,
 			})
 		}
 	}
 
-	return SyncResponse(true, leases)
+	return leases, nil
 }
 
 // The network structs and functions
@@ -828,16 +999,19 @@ func networkShutdown(s *state.State) error {
 func networkStateGet(d *Daemon, r *http.Request) Response {
 	name := mux.Vars(r)["name"]
 
-	// Get some information
+	// Get some information, networks which aren't running don't have any
 	osInfo, _ := net.InterfaceByName(name)
-	_, dbInfo, _ := d.cluster.NetworkGet(name)
-
-	// Sanity check
-	if osInfo == nil && dbInfo == nil {
+	if osInfo == nil {
 		return NotFound(fmt.Errorf("Interface '%s' not found", name))
 	}
 
-	return SyncResponse(true, networkGetState(*osInfo))
+	state := networkGetState(*osInfo)
+	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bridge", name)) {
+		usage := networkGetUsage(name)
+		state.Usage = &usage
+	}
+
+	return SyncResponse(true, state)
 }
 
 type network struct {
@@ -882,6 +1056,8 @@ func (n *network) IsUsed() bool {
 }
 
 func (n *network) Delete(withDatabase bool) error {
+	defer containerNetworkInvalidate(n.name)
+
 	// Bring the network down
 	if n.IsRunning() {
 		err := n.Stop()
@@ -906,6 +1082,8 @@ func (n *network) Delete(withDatabase bool) error {
 }
 
 func (n *network) Rename(name string) error {
+	defer containerNetworkInvalidate(n.name)
+
 	// Sanity checks
 	if n.IsUsed() {
 		return fmt.Errorf("The network is currently in use")
@@ -1164,11 +1342,21 @@ func (n *network) Start() error {
 	}
 
 	// Start building the dnsmasq command line
-	dnsmasqCmd := []string{"dnsmasq", "--strict-order", "--bind-interfaces",
+	dnsmasqCmd := []string{"dnsmasq", "--bind-interfaces",
 		fmt.Sprintf("--pid-file=%s", shared.VarPath("networks", n.name, "dnsmasq.pid")),
 		"--except-interface=lo",
 		fmt.Sprintf("--interface=%s", n.name)}
 
+	// Select how the upstream DNS servers are queried
+	switch n.config["dns.upstreams.policy"] {
+	case "all":
+		dnsmasqCmd = append(dnsmasqCmd, "--all-servers")
+	case "fastest":
+		// Default behavior of dnsmasq
+	default:
+		dnsmasqCmd = append(dnsmasqCmd, "--strict-order")
+	}
+
 	if !debug {
 		// --quiet options are only supported on >2.67
 		minVer, _ := version.NewDottedVersion("2.67")
@@ -1607,8 +1795,47 @@ func (n *network) Start() error {
 			dnsmasqCmd = append(dnsmasqCmd, []string{"-s", dnsDomain, "-S", fmt.Sprintf("/%s/", dnsDomain)}...)
 		}
 
+		// Setup the search domains handed out to the clients
+		if n.config["dns.search"] != "" {
+			domains := []string{}
+			for _, domain := range strings.Split(n.config["dns.search"], ",") {
+				domains = append(domains, strings.TrimSpace(domain))
+			}
+
+			dnsmasqCmd = append(dnsmasqCmd, fmt.Sprintf("--dhcp-option=option:domain-search,%s", strings.Join(domains, ",")))
+			dnsmasqCmd = append(dnsmasqCmd, fmt.Sprintf("--dhcp-option=option6:domain-search,%s", strings.Join(domains, ",")))
+		}
+
+		// Forward queries to the configured upstream servers instead of the
+		// ones of the host
+		if n.config["dns.upstreams"] != "" {
+			dnsmasqCmd = append(dnsmasqCmd, "--no-resolv")
+			for _, server := range strings.Split(n.config["dns.upstreams"], ",") {
+				dnsmasqCmd = append(dnsmasqCmd, fmt.Sprintf("--server=%s", strings.TrimSpace(server)))
+			}
+		}
+
+		// Answer queries for blocked domains with NXDOMAIN
+		dnsmasqRaw := ""
+		for _, path := range strings.Split(n.config["dns.blocklist"], ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+
+			domains, err := networkDNSBlocklist(path)
+			if err != nil {
+				return err
+			}
+
+			for _, domain := range domains {
+				dnsmasqRaw += fmt.Sprintf("address=/%s/\n", domain)
+			}
+		}
+
 		// Create a config file to contain additional config (and to prevent dnsmasq from reading /etc/dnsmasq.conf)
-		err = ioutil.WriteFile(shared.VarPath("networks", n.name, "dnsmasq.raw"), []byte(fmt.Sprintf("%s\n", n.config["raw.dnsmasq"])), 0644)
+		dnsmasqRaw += fmt.Sprintf("%s\n", n.config["raw.dnsmasq"])
+		err = ioutil.WriteFile(shared.VarPath("networks", n.name, "dnsmasq.raw"), []byte(dnsmasqRaw), 0644)
 		if err != nil {
 			return err
 		}
@@ -1652,14 +1879,51 @@ func (n *network) Start() error {
 		}
 	}
 
+	// Advertise the routed subnets through BGP
+	if n.state.BGP != nil {
+		n.state.BGP.SetPrefixes(fmt.Sprintf("network/%s", n.name), n.bgpPrefixes())
+	}
+
 	return nil
 }
 
+// Return the subnets of the network which are routed to it, that is its own
+// subnets when not NATed and its additional routes.
+func (n *network) bgpPrefixes() []net.IPNet {
+	prefixes := []net.IPNet{}
+	for _, family := range []string{"ipv4", "ipv6"} {
+		routes := []string{}
+		if !shared.IsTrue(n.config[family+".nat"]) {
+			routes = append(routes, n.config[family+".address"])
+		}
+
+		if n.config[family+".routes"] != "" {
+			routes = append(routes, strings.Split(n.config[family+".routes"], ",")...)
+		}
+
+		for _, route := range routes {
+			_, subnet, err := net.ParseCIDR(strings.TrimSpace(route))
+			if err != nil {
+				continue
+			}
+
+			prefixes = append(prefixes, *subnet)
+		}
+	}
+
+	return prefixes
+}
+
 func (n *network) Stop() error {
 	if !n.IsRunning() {
 		return fmt.Errorf("The network is already stopped")
 	}
 
+	// Withdraw the routes advertised through BGP
+	if n.state.BGP != nil {
+		n.state.BGP.SetPrefixes(fmt.Sprintf("network/%s", n.name), nil)
+	}
+
 	// Destroy the bridge interface
 	if n.config["bridge.driver"] == "openvswitch" {
 		_, err := shared.RunCommand("ovs-vsctl", "del-br", n.name)
@@ -1725,6 +1989,8 @@ func (n *network) Stop() error {
 }
 
 func (n *network) Update(newNetwork api.NetworkPut) error {
+	defer containerNetworkInvalidate(n.name)
+
 	err := networkFillAuto(newNetwork.Config)
 	if err != nil {
 		return err
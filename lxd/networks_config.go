@@ -89,6 +89,12 @@ var networkConfigKeys = map[string]func(value string) error{
 	"dns.mode": func(value string) error {
 		return shared.IsOneOf(value, []string{"dynamic", "managed", "none"})
 	},
+	"dns.search":    networkValidDomains,
+	"dns.upstreams": networkValidDNSUpstreams,
+	"dns.upstreams.policy": func(value string) error {
+		return shared.IsOneOf(value, []string{"strict", "all", "fastest"})
+	},
+	"dns.blocklist": networkValidDNSBlocklist,
 
 	"raw.dnsmasq": shared.IsAny,
 }
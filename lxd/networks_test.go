@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Without a target node, only the keys shared by all nodes can be changed and
+// the node-specific ones are taken from the database. With one, it's the other
+// way around.
+func TestNetworkClusterConfig(t *testing.T) {
+	dbConfig := map[string]string{
+		"ipv4.address":               "10.0.0.1/24",
+		"bridge.external_interfaces": "eth1",
+	}
+
+	reqConfig := map[string]string{"ipv4.address": "10.0.1.1/24"}
+	config, err := networkClusterConfig(dbConfig, reqConfig, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ipv4.address": "10.0.1.1/24", "bridge.external_interfaces": "eth1"}, config)
+	assert.Equal(t, map[string]string{"ipv4.address": "10.0.1.1/24"}, reqConfig)
+
+	_, err = networkClusterConfig(dbConfig, map[string]string{"bridge.external_interfaces": "eth2"}, "")
+	assert.EqualError(t, err, "node-specific config key bridge.external_interfaces can't be changed without a target node")
+
+	config, err = networkClusterConfig(dbConfig, map[string]string{"bridge.external_interfaces": "eth2"}, "node1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ipv4.address": "10.0.0.1/24", "bridge.external_interfaces": "eth2"}, config)
+
+	// Unchanged shared keys may be sent along, as with a full PUT
+	config, err = networkClusterConfig(dbConfig, map[string]string{"ipv4.address": "10.0.0.1/24"}, "node1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ipv4.address": "10.0.0.1/24"}, config)
+
+	_, err = networkClusterConfig(dbConfig, map[string]string{"ipv4.address": "10.0.1.1/24"}, "node1")
+	assert.EqualError(t, err, "config key ipv4.address isn't node-specific and can't be changed for a single node")
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
@@ -104,6 +105,10 @@ func networkIsInUse(c container, name string) bool {
 			continue
 		}
 
+		if d["network"] == name {
+			return true
+		}
+
 		if !shared.StringInSlice(d["nictype"], []string{"bridged", "macvlan", "physical", "sriov"}) {
 			continue
 		}
@@ -574,6 +579,303 @@ func networkValidNetworkV4(value string) error {
 	return nil
 }
 
+func networkValidDomain(value string) error {
+	match, _ := regexp.MatchString(`^([a-zA-Z0-9]([-a-zA-Z0-9]*[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([-a-zA-Z0-9]*[a-zA-Z0-9])?\.?$`, value)
+	if !match || len(value) > 253 {
+		return fmt.Errorf("Invalid domain name: %s", value)
+	}
+
+	return nil
+}
+
+func networkValidDomains(value string) error {
+	for _, domain := range strings.Split(value, ",") {
+		domain = strings.TrimSpace(domain)
+		if domain == "" {
+			continue
+		}
+
+		err := networkValidDomain(domain)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Upstream DNS servers are addresses with an optional port, either used for
+// all queries or restricted to a domain with the /DOMAIN/ADDRESS form.
+func networkValidDNSUpstreams(value string) error {
+	for _, server := range strings.Split(value, ",") {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+
+		address := server
+		if strings.HasPrefix(server, "/") {
+			fields := strings.SplitN(server[1:], "/", 2)
+			if len(fields) != 2 {
+				return fmt.Errorf("Invalid DNS upstream: %s", server)
+			}
+
+			err := networkValidDomain(fields[0])
+			if err != nil {
+				return err
+			}
+
+			address = fields[1]
+		}
+
+		fields := strings.SplitN(address, "#", 2)
+		if net.ParseIP(fields[0]) == nil {
+			return fmt.Errorf("Invalid DNS upstream address: %s", address)
+		}
+
+		if len(fields) == 2 {
+			err := networkValidPort(fields[1])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func networkValidDNSBlocklist(value string) error {
+	for _, path := range strings.Split(value, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		_, err := networkDNSBlocklist(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read a DNS blocking list, made of one domain per line. Lines in the hosts
+// file format are accepted too, in which case their last field is used.
+func networkDNSBlocklist(path string) ([]string, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("DNS blocking list path must be absolute: %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open DNS blocking list: %v", err)
+	}
+	defer f.Close()
+
+	domains := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		domain := fields[len(fields)-1]
+		err := networkValidDomain(domain)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid entry in DNS blocking list %s: %v", path, err)
+		}
+
+		domains = append(domains, domain)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return domains, nil
+}
+
+// Toggle the isolation of a bridge port. Isolated ports can only exchange
+// traffic with the bridge itself and its non-isolated ports.
+func networkSetPortIsolation(port string, isolated bool) error {
+	if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s/brport", port)) {
+		return fmt.Errorf("Interface %s isn't attached to a native bridge", port)
+	}
+
+	path := fmt.Sprintf("/sys/class/net/%s/brport/isolated", port)
+	if !shared.PathExists(path) {
+		return fmt.Errorf("Bridge port isolation isn't supported by the kernel")
+	}
+
+	value := "0"
+	if isolated {
+		value = "1"
+	}
+
+	return ioutil.WriteFile(path, []byte(value), 0)
+}
+
+// Return the arguments of ip to create a VLAN interface on the given parent,
+// optionally registering the VLAN with the switch through GVRP.
+func networkVLANArgs(parent string, device string, vlan string, gvrp bool) []string {
+	args := []string{"link", "add", "link", parent, "name", device, "up", "type", "vlan", "id", vlan}
+	if gvrp {
+		args = append(args, "gvrp", "on")
+	}
+
+	return args
+}
+
+func networkValidVLANs(value string) error {
+	for _, vlan := range strings.Split(value, ",") {
+		vlan = strings.TrimSpace(vlan)
+		id, err := strconv.Atoi(vlan)
+		if err != nil || id < 1 || id > 4094 {
+			return fmt.Errorf("Invalid VLAN ID: %s", vlan)
+		}
+	}
+
+	return nil
+}
+
+// Parse a comma separated list of VLAN IDs.
+func networkParseVLANs(value string) []string {
+	vlans := []string{}
+	for _, vlan := range strings.Split(value, ",") {
+		vlan = strings.TrimSpace(vlan)
+		if vlan != "" {
+			vlans = append(vlans, vlan)
+		}
+	}
+
+	return vlans
+}
+
+// Return the tagged VLANs used by the bridged nics on the given bridge.
+func networkBridgeVLANsInUse(bridge string, devices []types.Devices) []string {
+	vlans := []string{}
+	for _, devs := range devices {
+		for _, m := range devs {
+			if m["type"] != "nic" || m["nictype"] != "bridged" || m["parent"] != bridge {
+				continue
+			}
+
+			for _, vlan := range networkParseVLANs(m["vlan.tagged"]) {
+				if !shared.StringInSlice(vlan, vlans) {
+					vlans = append(vlans, vlan)
+				}
+			}
+		}
+	}
+
+	return vlans
+}
+
+// Serializes the changes of the VLAN setup of bridges.
+var networkVLANsLock sync.Mutex
+
+// Path of the file recording that VLAN filtering was enabled by LXD on a
+// native bridge, rather than by its administrator.
+func networkVLANFilteringPath(bridge string) string {
+	return shared.VarPath("networks", bridge, "vlan_filtering")
+}
+
+// Allow the given tagged VLANs on a bridge port, along with untagged traffic.
+// VLAN filtering gets enabled on native bridges, which keeps the untagged
+// traffic of their other ports in the default VLAN.
+func networkSetPortVLANs(bridge string, port string, vlans []string) error {
+	networkVLANsLock.Lock()
+	defer networkVLANsLock.Unlock()
+
+	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bridge", bridge)) {
+		filtering, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/bridge/vlan_filtering", bridge))
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(string(filtering)) == "0" {
+			err = os.MkdirAll(shared.VarPath("networks", bridge), 0711)
+			if err != nil {
+				return err
+			}
+
+			err = ioutil.WriteFile(networkVLANFilteringPath(bridge), []byte{}, 0600)
+			if err != nil {
+				return err
+			}
+
+			err = ioutil.WriteFile(fmt.Sprintf("/sys/class/net/%s/bridge/vlan_filtering", bridge), []byte("1"), 0)
+			if err != nil {
+				os.Remove(networkVLANFilteringPath(bridge))
+				return fmt.Errorf("Failed to enable VLAN filtering on bridge %s: %v", bridge, err)
+			}
+		}
+
+		for _, vlan := range vlans {
+			_, err = shared.RunCommand("bridge", "vlan", "add", "dev", port, "vid", vlan)
+			if err != nil {
+				return err
+			}
+
+			// Make the VLAN reachable from the host too
+			_, err = shared.RunCommand("bridge", "vlan", "add", "dev", bridge, "vid", vlan, "self")
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// Openvswitch bridges, where VLAN 0 stands for untagged traffic
+	_, err := shared.RunCommand("ovs-vsctl", "set", "port", port, fmt.Sprintf("trunks=0,%s", strings.Join(vlans, ",")))
+	return err
+}
+
+// Undo the setup of networkSetPortVLANs on a native bridge once the port
+// carrying the given VLANs is gone, keeping the VLANs still in use by other
+// ports. VLAN filtering gets disabled again when no port needs it anymore, if
+// LXD was the one enabling it. Openvswitch ports carry their own VLANs and go
+// away along with them.
+func networkReleasePortVLANs(bridge string, vlans []string, inUse []string) error {
+	networkVLANsLock.Lock()
+	defer networkVLANsLock.Unlock()
+
+	if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bridge", bridge)) {
+		return nil
+	}
+
+	for _, vlan := range vlans {
+		if shared.StringInSlice(vlan, inUse) {
+			continue
+		}
+
+		_, err := shared.RunCommand("bridge", "vlan", "del", "dev", bridge, "vid", vlan, "self")
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(inUse) > 0 || !shared.PathExists(networkVLANFilteringPath(bridge)) {
+		return nil
+	}
+
+	err := ioutil.WriteFile(fmt.Sprintf("/sys/class/net/%s/bridge/vlan_filtering", bridge), []byte("0"), 0)
+	if err != nil {
+		return fmt.Errorf("Failed to disable VLAN filtering on bridge %s: %v", bridge, err)
+	}
+
+	return os.Remove(networkVLANFilteringPath(bridge))
+}
+
 func networkAddressForSubnet(subnet *net.IPNet) (net.IP, string, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -1080,3 +1382,68 @@ func networkGetState(netIf net.Interface) api.NetworkState {
 
 	return network
 }
+
+// Return the usage of a bridge, aggregated over the interfaces attached to
+// it. Counters are from the point of view of the network, so that traffic
+// sent by containers is counted as received.
+func networkGetUsage(name string) api.NetworkStateUsage {
+	usage := api.NetworkStateUsage{}
+
+	members := []string{}
+	entries, err := ioutil.ReadDir(fmt.Sprintf("/sys/class/net/%s/brif", name))
+	if err == nil {
+		for _, entry := range entries {
+			members = append(members, entry.Name())
+		}
+	} else {
+		// Openvswitch bridges don't show their ports in sysfs
+		output, err := shared.RunCommand("ovs-vsctl", "list-ports", name)
+		if err == nil {
+			members = strings.Fields(output)
+		}
+	}
+
+	for _, member := range members {
+		counters := api.NetworkStateCounters{}
+		counters.BytesReceived, _ = shared.ParseNumberFromFile(fmt.Sprintf("/sys/class/net/%s/statistics/rx_bytes", member))
+		counters.BytesSent, _ = shared.ParseNumberFromFile(fmt.Sprintf("/sys/class/net/%s/statistics/tx_bytes", member))
+		counters.PacketsReceived, _ = shared.ParseNumberFromFile(fmt.Sprintf("/sys/class/net/%s/statistics/rx_packets", member))
+		counters.PacketsSent, _ = shared.ParseNumberFromFile(fmt.Sprintf("/sys/class/net/%s/statistics/tx_packets", member))
+
+		usage.Counters.BytesReceived += counters.BytesReceived
+		usage.Counters.BytesSent += counters.BytesSent
+		usage.Counters.PacketsReceived += counters.PacketsReceived
+		usage.Counters.PacketsSent += counters.PacketsSent
+	}
+	usage.Interfaces = len(members)
+
+	content, err := ioutil.ReadFile(shared.VarPath("networks", name, "dnsmasq.leases"))
+	if err == nil {
+		usage.ActiveLeases = networkCountActiveLeases(string(content), time.Now())
+	}
+
+	return usage
+}
+
+// Count the leases of a dnsmasq lease file which haven't expired yet. The
+// first field of each lease is its expiry time, zero for infinite leases.
+func networkCountActiveLeases(content string, now time.Time) int {
+	count := 0
+	for _, lease := range strings.Split(content, "\n") {
+		fields := strings.Fields(lease)
+		if len(fields) < 5 || fields[0] == "duid" {
+			continue
+		}
+
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if expiry == 0 || expiry > now.Unix() {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Upstream servers are addresses with an optional port and domain.
+func TestNetworkValidDNSUpstreams(t *testing.T) {
+	assert.NoError(t, networkValidDNSUpstreams("1.1.1.1, 2001:4860:4860::8888,9.9.9.9#5353,/corp.example.com/10.0.0.53"))
+
+	assert.Error(t, networkValidDNSUpstreams("dns.example.com"))
+	assert.Error(t, networkValidDNSUpstreams("1.1.1.1#port"))
+	assert.Error(t, networkValidDNSUpstreams("/corp.example.com"))
+	assert.Error(t, networkValidDNSUpstreams("/corp_example/10.0.0.53"))
+}
+
+// Blocking lists contain either plain domains or hosts file entries.
+func TestNetworkDNSBlocklist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-networks-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "blocklist")
+	err = ioutil.WriteFile(path, []byte("# Ads\nads.example.com\n0.0.0.0 tracker.example.net # Tracking\n\n"), 0644)
+	require.NoError(t, err)
+
+	domains, err := networkDNSBlocklist(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ads.example.com", "tracker.example.net"}, domains)
+
+	_, err = networkDNSBlocklist("blocklist")
+	assert.Error(t, err)
+
+	err = ioutil.WriteFile(path, []byte("ads_example.com\n"), 0644)
+	require.NoError(t, err)
+
+	_, err = networkDNSBlocklist(path)
+	assert.Error(t, err)
+}
+
+// Leases which have expired are not counted, unlike infinite ones.
+func TestNetworkCountActiveLeases(t *testing.T) {
+	content := `1500003600 00:16:3e:8a:c1:2b 10.0.0.10 c1 *
+1500000000 00:16:3e:8a:c1:2c 10.0.0.11 c2 *
+0 00:16:3e:8a:c1:2d 10.0.0.12 c3 *
+duid 00:01:00:01:22:f1:5c:84:00:16:3e:8a:c1:2b
+1500003600 1234 fd42::10 c1 00:01:00:01:22:f1:5c:84:00:16:3e:8a:c1:2b
+`
+
+	assert.Equal(t, 3, networkCountActiveLeases(content, time.Unix(1500001800, 0)))
+}
+
+// VLAN lists are comma separated IDs between 1 and 4094.
+func TestNetworkParseVLANs(t *testing.T) {
+	assert.NoError(t, networkValidVLANs("10, 20,4094"))
+	assert.Equal(t, []string{"10", "20", "4094"}, networkParseVLANs("10, 20,4094"))
+
+	assert.Error(t, networkValidVLANs("0"))
+	assert.Error(t, networkValidVLANs("4095"))
+	assert.Error(t, networkValidVLANs("10,trunk"))
+}
+
+// Only the tagged VLANs of the bridged nics on the same bridge are in use.
+func TestNetworkBridgeVLANsInUse(t *testing.T) {
+	devices := []types.Devices{
+		{
+			"eth0": {"type": "nic", "nictype": "bridged", "parent": "br0", "vlan.tagged": "10,20"},
+			"eth1": {"type": "nic", "nictype": "bridged", "parent": "br1", "vlan.tagged": "30"},
+		},
+		{
+			"eth0": {"type": "nic", "nictype": "bridged", "parent": "br0", "vlan.tagged": "20,40"},
+			"eth1": {"type": "nic", "nictype": "physical", "parent": "br0", "vlan.tagged": "50"},
+			"root": {"type": "disk", "path": "/", "pool": "default"},
+		},
+	}
+
+	vlans := networkBridgeVLANsInUse("br0", devices)
+	sort.Strings(vlans)
+	assert.Equal(t, []string{"10", "20", "40"}, vlans)
+	assert.Equal(t, []string{"30"}, networkBridgeVLANsInUse("br1", devices))
+	assert.Equal(t, []string{}, networkBridgeVLANsInUse("br2", devices))
+}
@@ -2,9 +2,12 @@ package node
 
 import (
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
 )
 
 // Config holds node-local configuration values for a certain LXD instance.
@@ -37,12 +40,58 @@ func (c *Config) HTTPSAddress() string {
 	return c.m.GetString("core.https_address")
 }
 
+// ClusterAddress returns the address this LXD node should use for the traffic
+// between cluster members, which defaults to the one of the API.
+func (c *Config) ClusterAddress() string {
+	address := c.m.GetString("cluster.https_address")
+	if address == "" {
+		return c.HTTPSAddress()
+	}
+	return address
+}
+
+// UnixGroups returns the access level of the members of each of the unix
+// groups which are granted access to the local unix socket, if any.
+func (c *Config) UnixGroups() map[string]string {
+	groups := map[string]string{}
+	for _, entry := range strings.Split(c.m.GetString("core.unix_groups"), ",") {
+		fields := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if fields[0] == "" {
+			continue
+		}
+
+		level := "admin"
+		if len(fields) == 2 {
+			level = strings.TrimSpace(fields[1])
+		}
+		groups[strings.TrimSpace(fields[0])] = level
+	}
+	return groups
+}
+
 // MAASMachine returns the MAAS machine this instance is associated with, if
 // any.
 func (c *Config) MAASMachine() string {
 	return c.m.GetString("maas.machine")
 }
 
+// ACME returns the domain to obtain the server certificate for through ACME,
+// along with the contact email and the URL of the ACME directory.
+func (c *Config) ACME() (string, string, string) {
+	return c.m.GetString("acme.domain"), c.m.GetString("acme.email"), c.m.GetString("acme.ca_url")
+}
+
+// BGPRouterID returns the router ID of the BGP speaker.
+func (c *Config) BGPRouterID() string {
+	return c.m.GetString("bgp.routerid")
+}
+
+// ReplicaInterval returns the interval in seconds at which the local replica
+// of the global database gets refreshed, or zero if there's none.
+func (c *Config) ReplicaInterval() int64 {
+	return c.m.GetInt64("cluster.replica_interval")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]interface{} {
@@ -78,6 +127,21 @@ func HTTPSAddress(node *db.Node) (string, error) {
 	return config.HTTPSAddress(), nil
 }
 
+// ClusterAddress is a convenience for loading the node configuration and
+// returning the address used for cluster traffic.
+func ClusterAddress(node *db.Node) (string, error) {
+	var config *Config
+	err := node.Transaction(func(tx *db.NodeTx) error {
+		var err error
+		config, err = ConfigLoad(tx)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return config.ClusterAddress(), nil
+}
+
 func (c *Config) update(values map[string]interface{}) (map[string]string, error) {
 	changed, err := c.m.Change(values)
 	if err != nil {
@@ -94,9 +158,62 @@ func (c *Config) update(values map[string]interface{}) (map[string]string, error
 
 // ConfigSchema defines available server configuration keys.
 var ConfigSchema = config.Schema{
+	// Domain name of the server certificate obtained through ACME.
+	"acme.domain": {},
+
+	// Contact email of the ACME account.
+	"acme.email": {},
+
+	// Directory URL of the ACME certificate authority.
+	"acme.ca_url": {Default: "https://acme-v02.api.letsencrypt.org/directory"},
+
+	// Router ID of the BGP speaker of this LXD server.
+	"bgp.routerid": {Validator: validateRouterID},
+
 	// Network address for this LXD server.
 	"core.https_address": {},
 
+	// Network address for the cluster traffic of this LXD server, if
+	// different from the one of the API.
+	"cluster.https_address": {},
+
+	// Interval in seconds at which a cluster member which isn't a database
+	// node refreshes its local replica of the global database, used to
+	// serve GET requests. Zero disables the replica.
+	"cluster.replica_interval": {Type: config.Int64, Default: "0"},
+
+	// Unix groups granted access to the local unix socket, along with
+	// their access level.
+	"core.unix_groups": {Validator: validateUnixGroups},
+
 	// MAAS machine this LXD instance is associated with.
 	"maas.machine": {},
 }
+
+func validateRouterID(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil || ip.To4() == nil {
+		return fmt.Errorf("router ID must be an IPv4 address")
+	}
+
+	return nil
+}
+
+func validateUnixGroups(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if fields[0] == "" {
+			continue
+		}
+
+		if len(fields) == 2 && !shared.StringInSlice(strings.TrimSpace(fields[1]), []string{"admin", "read-only"}) {
+			return fmt.Errorf("invalid access level '%s' for group '%s'", strings.TrimSpace(fields[1]), fields[0])
+		}
+	}
+
+	return nil
+}
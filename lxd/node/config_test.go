@@ -118,3 +118,22 @@ func TestHTTPSAddress(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "127.0.0.1:666", address)
 }
+
+// Groups listed in core.unix_groups default to the admin access level, and
+// only known levels are accepted.
+func TestConfig_UnixGroups(t *testing.T) {
+	tx, cleanup := db.NewTestNodeTx(t)
+	defer cleanup()
+
+	config, err := node.ConfigLoad(tx)
+	require.NoError(t, err)
+
+	_, err = config.Patch(map[string]interface{}{"core.unix_groups": "teachers, students=read-only"})
+	require.NoError(t, err)
+
+	groups := map[string]string{"teachers": "admin", "students": "read-only"}
+	assert.Equal(t, groups, config.UnixGroups())
+
+	_, err = config.Patch(map[string]interface{}{"core.unix_groups": "students=root"})
+	assert.EqualError(t, err, "cannot set 'core.unix_groups' to 'students=root': invalid access level 'root' for group 'students'")
+}
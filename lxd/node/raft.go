@@ -6,7 +6,8 @@ import "github.com/lxc/lxd/lxd/db"
 //
 // This decision is based on the values of the core.https_address config key
 // and on the rows in the raft_nodes table, both stored in the node-level
-// SQLite database.
+// SQLite database. If cluster.https_address is set, it's used instead of
+// core.https_address.
 //
 // The following rules are applied:
 //
@@ -29,7 +30,7 @@ func DetermineRaftNode(tx *db.NodeTx) (*db.RaftNode, error) {
 		return nil, err
 	}
 
-	address := config.HTTPSAddress()
+	address := config.ClusterAddress()
 
 	// If core.https_address is the empty string, then this LXD instance is
 	// not running in clustering mode.
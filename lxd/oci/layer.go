@@ -0,0 +1,263 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Prefix of the names of whiteout files, which mark files of the layers below
+// as deleted, and name of the whiteout marking a directory as opaque.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// ApplyLayer extracts a layer of the given media type on top of the rootfs
+// made of the layers below it.
+func ApplyLayer(rootfs string, r io.Reader, mediaType string) error {
+	switch {
+	case strings.HasSuffix(mediaType, "+gzip") || strings.HasSuffix(mediaType, ".tar.gzip"):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case strings.HasSuffix(mediaType, ".tar"):
+	default:
+		return fmt.Errorf("Unsupported layer type: %s", mediaType)
+	}
+
+	// Paths created by this layer, which opaque directories keep
+	written := map[string]bool{}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+
+		dir, base := filepath.Split(name)
+		parent, err := resolvePath(rootfs, dir)
+		if err != nil {
+			return err
+		}
+
+		if base == whiteoutOpaque {
+			err = removeChildren(parent, written)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			err = os.RemoveAll(filepath.Join(parent, strings.TrimPrefix(base, whiteoutPrefix)))
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		path := filepath.Join(parent, base)
+		err = applyEntry(rootfs, parent, path, hdr, tr)
+		if err != nil {
+			return fmt.Errorf("Failed to extract %s: %v", hdr.Name, err)
+		}
+
+		written[path] = true
+	}
+
+	return nil
+}
+
+func applyEntry(rootfs string, parent string, path string, hdr *tar.Header, r io.Reader) error {
+	err := os.MkdirAll(parent, 0755)
+	if err != nil {
+		return err
+	}
+
+	// Replace whatever the layers below have there, unless both are
+	// directories.
+	fi, err := os.Lstat(path)
+	if err == nil && !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if fi == nil || !fi.IsDir() {
+			err = os.Mkdir(path, 0755)
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		var f *os.File
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(f, r)
+		f.Close()
+	case tar.TypeSymlink:
+		err = os.Symlink(hdr.Linkname, path)
+	case tar.TypeLink:
+		linkDir, linkBase := filepath.Split(filepath.Clean("/" + hdr.Linkname))
+		var target string
+		target, err = resolvePath(rootfs, linkDir)
+		if err != nil {
+			return err
+		}
+
+		err = os.Link(filepath.Join(target, linkBase), path)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		devMode := uint32(syscall.S_IFIFO)
+		if hdr.Typeflag == tar.TypeChar {
+			devMode = syscall.S_IFCHR
+		} else if hdr.Typeflag == tar.TypeBlock {
+			devMode = syscall.S_IFBLK
+		}
+
+		dev := (hdr.Devminor & 0xff) | (hdr.Devmajor&0xfff)<<8 | (hdr.Devminor&^0xff)<<12
+		err = syscall.Mknod(path, devMode|uint32(mode.Perm()), int(dev))
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Hard links share their owner and mode with their target
+	if hdr.Typeflag == tar.TypeLink {
+		return nil
+	}
+
+	err = os.Lchown(path, hdr.Uid, hdr.Gid)
+	if err != nil {
+		return err
+	}
+
+	if hdr.Typeflag == tar.TypeSymlink {
+		return nil
+	}
+
+	// Set the mode after the owner, as changing the owner drops the
+	// setuid and setgid bits.
+	err = os.Chmod(path, mode)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range hdr.PAXRecords {
+		if !strings.HasPrefix(key, "SCHILY.xattr.") {
+			continue
+		}
+
+		err = syscall.Setxattr(path, strings.TrimPrefix(key, "SCHILY.xattr."), []byte(value), 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Chtimes(path, hdr.AccessTime, hdr.ModTime)
+}
+
+// Remove the content of a directory which wasn't written by the current layer.
+func removeChildren(dir string, written map[string]bool) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if written[path] {
+			continue
+		}
+
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resolve a path within the rootfs, following symlinks as if the rootfs was
+// the root directory, so that no layer can make us write outside of it.
+func resolvePath(rootfs string, path string) (string, error) {
+	current := "/"
+	remaining := path
+	links := 0
+
+	for remaining != "" {
+		part := remaining
+		remaining = ""
+
+		i := strings.Index(part, "/")
+		if i >= 0 {
+			part, remaining = part[:i], part[i+1:]
+		}
+
+		if part == "" || part == "." {
+			continue
+		}
+
+		if part == ".." {
+			current = filepath.Dir(current)
+			continue
+		}
+
+		next := filepath.Join(current, part)
+		fi, err := os.Lstat(filepath.Join(rootfs, next))
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		links++
+		if links > 255 {
+			return "", fmt.Errorf("Too many levels of symbolic links in %s", path)
+		}
+
+		target, err := os.Readlink(filepath.Join(rootfs, next))
+		if err != nil {
+			return "", err
+		}
+
+		if filepath.IsAbs(target) {
+			current = "/"
+		}
+
+		remaining = target + "/" + remaining
+	}
+
+	return filepath.Join(rootfs, current), nil
+}
@@ -0,0 +1,92 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Build an uncompressed layer out of the given headers, with the files
+// containing their own name.
+func layerTestTarball(t *testing.T, headers []tar.Header) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range headers {
+		content := ""
+		if hdr.Typeflag == tar.TypeReg {
+			content = hdr.Name
+			hdr.Size = int64(len(content))
+		}
+
+		if hdr.Mode == 0 {
+			hdr.Mode = 0755
+		}
+
+		err := tw.WriteHeader(&hdr)
+		require.NoError(t, err)
+
+		_, err = tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	return &buf
+}
+
+func TestApplyLayer_Whiteouts(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "lxd-oci-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootfs)
+
+	lower := layerTestTarball(t, []tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir},
+		{Name: "etc/hostname", Typeflag: tar.TypeReg},
+		{Name: "etc/hosts", Typeflag: tar.TypeReg},
+		{Name: "opt/", Typeflag: tar.TypeDir},
+		{Name: "opt/old", Typeflag: tar.TypeReg},
+	})
+	require.NoError(t, ApplyLayer(rootfs, lower, "application/vnd.docker.image.rootfs.diff.tar"))
+
+	upper := layerTestTarball(t, []tar.Header{
+		{Name: "etc/.wh.hosts", Typeflag: tar.TypeReg},
+		{Name: "opt/", Typeflag: tar.TypeDir},
+		{Name: "opt/new", Typeflag: tar.TypeReg},
+		{Name: "opt/.wh..wh..opq", Typeflag: tar.TypeReg},
+	})
+	require.NoError(t, ApplyLayer(rootfs, upper, "application/vnd.oci.image.layer.v1.tar"))
+
+	assert.FileExists(t, filepath.Join(rootfs, "etc", "hostname"))
+	assert.NoFileExists(t, filepath.Join(rootfs, "etc", "hosts"))
+	assert.NoFileExists(t, filepath.Join(rootfs, "etc", ".wh.hosts"))
+	assert.NoFileExists(t, filepath.Join(rootfs, "opt", "old"))
+	assert.FileExists(t, filepath.Join(rootfs, "opt", "new"))
+}
+
+// Symlinks of a layer are resolved within the rootfs.
+func TestApplyLayer_Symlinks(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "lxd-oci-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootfs)
+
+	outside, err := ioutil.TempDir("", "lxd-oci-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(outside)
+
+	layer := layerTestTarball(t, []tar.Header{
+		{Name: "escape", Typeflag: tar.TypeSymlink, Linkname: outside},
+		{Name: "escape/file", Typeflag: tar.TypeReg},
+		{Name: "up", Typeflag: tar.TypeSymlink, Linkname: "../../.."},
+		{Name: "up/file", Typeflag: tar.TypeReg},
+	})
+	require.NoError(t, ApplyLayer(rootfs, layer, "application/vnd.oci.image.layer.v1.tar"))
+
+	assert.NoFileExists(t, filepath.Join(outside, "file"))
+	assert.FileExists(t, filepath.Join(rootfs, outside, "file"))
+	assert.FileExists(t, filepath.Join(rootfs, "file"))
+}
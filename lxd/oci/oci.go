@@ -0,0 +1,89 @@
+package oci
+
+import (
+	"github.com/lxc/lxd/shared/osarch"
+)
+
+// Media types of the OCI image specification
+const (
+	MediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	MediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// Media types of the Docker image format, which registries still commonly serve
+const (
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// Architectures maps LXD architecture IDs to their name in OCI images, as
+// used by Go.
+var Architectures = map[int]string{
+	osarch.ARCH_32BIT_INTEL_X86:             "386",
+	osarch.ARCH_64BIT_INTEL_X86:             "amd64",
+	osarch.ARCH_32BIT_ARMV7_LITTLE_ENDIAN:   "arm",
+	osarch.ARCH_64BIT_ARMV8_LITTLE_ENDIAN:   "arm64",
+	osarch.ARCH_64BIT_POWERPC_BIG_ENDIAN:    "ppc64",
+	osarch.ARCH_64BIT_POWERPC_LITTLE_ENDIAN: "ppc64le",
+	osarch.ARCH_64BIT_S390_BIG_ENDIAN:       "s390x",
+}
+
+// Descriptor references a blob by its digest
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+// Platform is what an image of an index runs on
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Index lists the manifests of an image, usually one per platform
+type Index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// Manifest describes the configuration and layers of an image
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// ImageConfig is the execution configuration of an image
+type ImageConfig struct {
+	Labels map[string]string `json:"Labels,omitempty"`
+}
+
+// RootFS lists the digests of the uncompressed layers of an image
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// History records how a layer of an image was created
+type History struct {
+	Created   string `json:"created"`
+	CreatedBy string `json:"created_by"`
+}
+
+// Image is the configuration of an image
+type Image struct {
+	Created      string      `json:"created"`
+	Architecture string      `json:"architecture"`
+	OS           string      `json:"os"`
+	Config       ImageConfig `json:"config"`
+	RootFS       RootFS      `json:"rootfs"`
+	History      []History   `json:"history"`
+}
@@ -0,0 +1,380 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultRegistry is used for references which don't come with a registry
+const DefaultRegistry = "https://registry-1.docker.io"
+
+// Registry is an anonymous client of the distribution API of an OCI registry
+type Registry struct {
+	url       string
+	client    *http.Client
+	userAgent string
+	dockerHub bool
+
+	// Bearer tokens, per repository
+	tokens map[string]string
+}
+
+// NewRegistry returns a client of the given registry, which may be given
+// without a scheme, in which case HTTPS is used.
+func NewRegistry(server string, client *http.Client, userAgent string) (*Registry, error) {
+	if server == "" {
+		server = DefaultRegistry
+	}
+
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("Unsupported registry URL: %s", server)
+	}
+
+	// The Docker Hub is known by the name of its web site
+	if u.Host == "docker.io" || u.Host == "index.docker.io" {
+		u.Host = "registry-1.docker.io"
+	}
+
+	return &Registry{
+		url:       strings.TrimSuffix(u.String(), "/"),
+		client:    client,
+		userAgent: userAgent,
+		dockerHub: u.Host == "registry-1.docker.io",
+		tokens:    map[string]string{},
+	}, nil
+}
+
+// ParseReference splits a "name[:tag]" or "name@digest" reference into the
+// repository name and the tag or digest, which defaults to "latest".
+func (r *Registry) ParseReference(reference string) (string, string, error) {
+	name := reference
+	tag := "latest"
+
+	i := strings.LastIndex(reference, "@")
+	if i < 0 {
+		i = strings.LastIndex(reference, ":")
+		if i >= 0 && strings.Contains(reference[i:], "/") {
+			i = -1
+		}
+	}
+
+	if i >= 0 {
+		name = reference[:i]
+		tag = reference[i+1:]
+	}
+
+	if name == "" || tag == "" {
+		return "", "", fmt.Errorf("Invalid image reference: %s", reference)
+	}
+
+	// Official images of the Docker Hub live in the library namespace
+	if r.dockerHub && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+
+	return name, tag, nil
+}
+
+// GetManifest returns the manifest of the given image and its digest. If the
+// image comes with an index, the manifest of the first of the given
+// architectures it has is used.
+func (r *Registry) GetManifest(name string, reference string, architectures []string) (*Manifest, string, error) {
+	accept := []string{MediaTypeIndex, MediaTypeDockerManifestList, MediaTypeManifest, MediaTypeDockerManifest}
+	body, mediaType, digest, err := r.getManifest(name, reference, accept)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if mediaType == MediaTypeIndex || mediaType == MediaTypeDockerManifestList {
+		index := Index{}
+		err = json.Unmarshal(body, &index)
+		if err != nil {
+			return nil, "", err
+		}
+
+		desc, err := indexManifest(index, architectures)
+		if err != nil {
+			return nil, "", err
+		}
+
+		body, mediaType, digest, err = r.getManifest(name, desc.Digest, []string{MediaTypeManifest, MediaTypeDockerManifest})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if mediaType != MediaTypeManifest && mediaType != MediaTypeDockerManifest {
+		return nil, "", fmt.Errorf("Unsupported manifest type: %s", mediaType)
+	}
+
+	manifest := Manifest{}
+	err = json.Unmarshal(body, &manifest)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &manifest, digest, nil
+}
+
+// GetImage returns the configuration of the image of the given manifest.
+func (r *Registry) GetImage(name string, manifest *Manifest) (*Image, error) {
+	blob, err := r.GetBlob(name, manifest.Config)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	body, err := ioutil.ReadAll(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	image := Image{}
+	err = json.Unmarshal(body, &image)
+	if err != nil {
+		return nil, err
+	}
+
+	return &image, nil
+}
+
+// GetBlob returns a reader of the given blob, which fails at the end of the
+// blob if its digest doesn't match.
+func (r *Registry) GetBlob(name string, desc Descriptor) (io.ReadCloser, error) {
+	h, err := digestHash(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.get(name, fmt.Sprintf("blobs/%s", desc.Digest), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blobReader{ReadCloser: resp.Body, hash: h, digest: desc.Digest}, nil
+}
+
+// Pick the manifest of the first of the given architectures from an index.
+func indexManifest(index Index, architectures []string) (*Descriptor, error) {
+	for _, arch := range architectures {
+		for _, desc := range index.Manifests {
+			if desc.Platform == nil {
+				continue
+			}
+
+			if desc.Platform.OS == "linux" && desc.Platform.Architecture == arch {
+				return &desc, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("The image isn't available for any of the architectures: %s", strings.Join(architectures, ", "))
+}
+
+func (r *Registry) getManifest(name string, reference string, accept []string) ([]byte, string, string, error) {
+	resp, err := r.get(name, fmt.Sprintf("manifests/%s", reference), accept)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	if strings.Contains(reference, ":") && reference != digest {
+		return nil, "", "", fmt.Errorf("Digest mismatch of manifest %s: %s", reference, digest)
+	}
+
+	// Fallback to the media type in the manifest itself
+	mediaType := strings.Split(resp.Header.Get("Content-Type"), ";")[0]
+	if mediaType == "" || mediaType == "application/json" || mediaType == "text/plain" {
+		typed := struct {
+			MediaType string `json:"mediaType"`
+		}{}
+
+		err = json.Unmarshal(body, &typed)
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		mediaType = typed.MediaType
+	}
+
+	return body, mediaType, digest, nil
+}
+
+// Make a GET request against the given repository, getting a token first if
+// the registry asks for one.
+func (r *Registry) get(name string, path string, accept []string) (*http.Response, error) {
+	do := func() (*http.Response, error) {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/v2/%s/%s", r.url, name, path), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if r.userAgent != "" {
+			req.Header.Set("User-Agent", r.userAgent)
+		}
+
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+
+		token := r.tokens[name]
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		return r.client.Do(req)
+	}
+
+	resp, err := do()
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && r.tokens[name] == "" {
+		resp.Body.Close()
+
+		err = r.authenticate(name, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = do()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Unable to fetch %s of %s: %s", path, name, resp.Status)
+	}
+
+	return resp, nil
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Get an anonymous bearer token to pull from the given repository.
+func (r *Registry) authenticate(name string, challenge string) error {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return fmt.Errorf("Unsupported authentication challenge from registry: %s", challenge)
+	}
+
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+
+	if params["realm"] == "" {
+		return fmt.Errorf("No realm in authentication challenge from registry: %s", challenge)
+	}
+
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return err
+	}
+
+	values := u.Query()
+	if params["service"] != "" {
+		values.Set("service", params["service"])
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", name)
+	}
+	values.Set("scope", scope)
+	u.RawQuery = values.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if r.userAgent != "" {
+		req.Header.Set("User-Agent", r.userAgent)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unable to get a token to pull %s: %s", name, resp.Status)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return err
+	}
+
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+
+	if token.Token == "" {
+		return fmt.Errorf("Got no token to pull %s", name)
+	}
+
+	r.tokens[name] = token.Token
+	return nil
+}
+
+func digestHash(digest string) (hash.Hash, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, fmt.Errorf("Unsupported digest: %s", digest)
+	}
+
+	return sha256.New(), nil
+}
+
+type blobReader struct {
+	io.ReadCloser
+	hash   hash.Hash
+	digest string
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+
+	if err == io.EOF {
+		sum := "sha256:" + hex.EncodeToString(b.hash.Sum(nil))
+		if sum != b.digest {
+			return n, fmt.Errorf("Digest mismatch of blob %s: %s", b.digest, sum)
+		}
+	}
+
+	return n, err
+}
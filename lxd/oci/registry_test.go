@@ -0,0 +1,122 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_ParseReference(t *testing.T) {
+	hub, err := NewRegistry("docker.io", http.DefaultClient, "")
+	require.NoError(t, err)
+
+	other, err := NewRegistry("localhost:5000", http.DefaultClient, "")
+	require.NoError(t, err)
+
+	cases := []struct {
+		registry  *Registry
+		reference string
+		name      string
+		tag       string
+	}{
+		{hub, "alpine", "library/alpine", "latest"},
+		{hub, "alpine:3.8", "library/alpine", "3.8"},
+		{hub, "user/image@sha256:abcd", "user/image", "sha256:abcd"},
+		{other, "alpine", "alpine", "latest"},
+		{other, "some/image:1.0", "some/image", "1.0"},
+	}
+
+	for _, c := range cases {
+		name, tag, err := c.registry.ParseReference(c.reference)
+		require.NoError(t, err)
+		assert.Equal(t, c.name, name)
+		assert.Equal(t, c.tag, tag)
+	}
+}
+
+// Manifests of the requested architecture are picked out of indexes, after
+// getting a token from the registry.
+func TestRegistry_GetManifest(t *testing.T) {
+	config := []byte(`{"architecture":"arm64","os":"linux"}`)
+	configDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(config))
+
+	manifest, err := json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeDockerManifest,
+		Config:        Descriptor{MediaType: MediaTypeConfig, Digest: configDigest, Size: int64(len(config))},
+	})
+	require.NoError(t, err)
+	manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+
+	index, err := json.Marshal(Index{
+		SchemaVersion: 2,
+		Manifests: []Descriptor{
+			{MediaType: MediaTypeManifest, Digest: "sha256:0000", Platform: &Platform{Architecture: "amd64", OS: "linux"}},
+			{MediaType: MediaTypeManifest, Digest: manifestDigest, Platform: &Platform{Architecture: "arm64", OS: "linux"}},
+		},
+	})
+	require.NoError(t, err)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			assert.Equal(t, "repository:image:pull", r.URL.Query().Get("scope"))
+			w.Write([]byte(`{"token":"secret"}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/image/manifests/latest":
+			w.Header().Set("Content-Type", MediaTypeIndex)
+			w.Write(index)
+		case "/v2/image/manifests/" + manifestDigest:
+			w.Header().Set("Content-Type", MediaTypeDockerManifest)
+			w.Write(manifest)
+		case "/v2/image/blobs/" + configDigest:
+			w.Write(config)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry, err := NewRegistry(server.URL, server.Client(), "")
+	require.NoError(t, err)
+
+	m, digest, err := registry.GetManifest("image", "latest", []string{"arm64"})
+	require.NoError(t, err)
+	assert.Equal(t, manifestDigest, digest)
+	assert.Equal(t, configDigest, m.Config.Digest)
+
+	image, err := registry.GetImage("image", m)
+	require.NoError(t, err)
+	assert.Equal(t, "arm64", image.Architecture)
+
+	_, _, err = registry.GetManifest("image", "latest", []string{"s390x"})
+	assert.EqualError(t, err, "The image isn't available for any of the architectures: s390x")
+
+	// Blobs which don't match their digest
+	blob, err := registry.GetBlob("image", Descriptor{Digest: configDigest})
+	require.NoError(t, err)
+	blob.Close()
+
+	config[0] = '['
+	blob, err = registry.GetBlob("image", Descriptor{Digest: configDigest})
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(blob)
+	assert.Error(t, err)
+	blob.Close()
+}
@@ -461,18 +461,19 @@ func operationAPIGet(d *Daemon, r *http.Request) Response {
 			return SmartError(err)
 		}
 	} else {
-		var address string
+		var operation db.Operation
 		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
-			operation, err := tx.OperationByUUID(id)
-			if err != nil {
-				return err
-			}
-			address = operation.NodeAddress
-			return nil
+			var err error
+			operation, err = tx.OperationByUUID(id)
+			return err
 		})
 		if err != nil {
 			return SmartError(err)
 		}
+		if operation.Failed {
+			return SyncResponse(true, operationFailedRender(operation))
+		}
+		address := operation.NodeAddress
 		cert := d.endpoints.NetworkCert()
 		client, err := cluster.Connect(address, cert, false)
 		if err != nil {
@@ -484,6 +485,10 @@ func operationAPIGet(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	if localAccessReadOnly(r) {
+		localAccessStripOperation(body)
+	}
+
 	return SyncResponse(true, body)
 }
 
@@ -492,7 +497,20 @@ func operationAPIDelete(d *Daemon, r *http.Request) Response {
 
 	op, err := operationGet(id)
 	if err != nil {
-		return NotFound(err)
+		// Forget about operations whose node went offline
+		_, failedErr := operationFailedGet(d.cluster, id)
+		if failedErr != nil {
+			return NotFound(err)
+		}
+
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.OperationRemove(id)
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+
+		return EmptySyncResponse
 	}
 
 	_, err = op.Cancel()
@@ -537,6 +555,10 @@ func operationsAPIGet(d *Daemon, r *http.Request) Response {
 			continue
 		}
 
+		if localAccessReadOnly(r) {
+			localAccessStripOperation(body)
+		}
+
 		md[status] = append(md[status].([]*api.Operation), body)
 	}
 
@@ -554,7 +576,13 @@ func operationAPIWaitGet(d *Daemon, r *http.Request) Response {
 	id := mux.Vars(r)["id"]
 	op, err := operationGet(id)
 	if err != nil {
-		return NotFound(err)
+		// Operations whose node went offline are already final
+		operation, failedErr := operationFailedGet(d.cluster, id)
+		if failedErr != nil {
+			return NotFound(err)
+		}
+
+		return SyncResponse(true, operationFailedRender(*operation))
 	}
 
 	_, err = op.WaitFinal(timeout)
@@ -567,6 +595,10 @@ func operationAPIWaitGet(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	if localAccessReadOnly(r) {
+		localAccessStripOperation(body)
+	}
+
 	return SyncResponse(true, body)
 }
 
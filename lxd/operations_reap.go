@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+func operationsReapTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		operationsReap(d)
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// How many times the offline threshold a node which doesn't answer must have
+// been missing heartbeats for to be considered dead.
+const operationsReapDeadFactor = 10
+
+// Mark as failed the operations of the cluster nodes which went offline while
+// running them, so that they don't show up as running forever. Only the leader
+// does it, and each operation gets a failure event.
+//
+// Missing heartbeats alone aren't enough, since the node may well still be
+// running the operation on the other side of a network partition. An
+// operation is only failed once its node is confirmed to have restarted,
+// answering again without knowing about it, or to be dead, not answering for
+// much longer than the offline threshold.
+func operationsReap(d *Daemon) {
+	address, err := node.ClusterAddress(d.db)
+	if err != nil || address == "" {
+		return
+	}
+
+	leader, err := d.gateway.LeaderAddress()
+	if err != nil || leader != address {
+		return
+	}
+
+	var offline []db.Operation
+	var dead []db.Operation
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		threshold, err := tx.NodeOfflineThreshold()
+		if err != nil {
+			return err
+		}
+
+		offline, err = tx.OperationsOffline(threshold)
+		if err != nil {
+			return err
+		}
+
+		dead, err = tx.OperationsOffline(threshold * operationsReapDeadFactor)
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to reap the operations of offline nodes", log.Ctx{"err": err})
+		return
+	}
+
+	deadNodes := map[string]bool{}
+	for _, operation := range dead {
+		deadNodes[operation.NodeAddress] = true
+	}
+
+	byNode := map[string][]db.Operation{}
+	for _, operation := range offline {
+		byNode[operation.NodeAddress] = append(byNode[operation.NodeAddress], operation)
+	}
+
+	cert := d.endpoints.NetworkCert()
+	for address, operations := range byNode {
+		running, err := operationsReapProbe(address, cert)
+		if err != nil && !deadNodes[address] {
+			logger.Debug("Not failing the operations of unreachable node yet", log.Ctx{"node": address, "err": err})
+			continue
+		}
+
+		for _, operation := range operationsReapFailed(operations, running, err == nil) {
+			err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+				return tx.OperationFail(operation.UUID)
+			})
+			if err != nil {
+				logger.Error("Failed to mark operation as failed", log.Ctx{"operation": operation.UUID, "err": err})
+				continue
+			}
+
+			logger.Warn("Marked operation of offline node as failed", log.Ctx{"operation": operation.UUID, "node": operation.NodeAddress})
+			eventSend("operation", operationFailedRender(operation))
+		}
+	}
+}
+
+// Ask the given node for the UUIDs of the operations it's running.
+func operationsReapProbe(address string, cert *shared.CertInfo) ([]string, error) {
+	client, err := cluster.Connect(address, cert, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetOperationUUIDs()
+}
+
+// Return the operations of a node to mark as failed: all of them if it's
+// dead, or only the ones it doesn't know about anymore if it answered with
+// the list of the operations it's running.
+func operationsReapFailed(operations []db.Operation, running []string, answered bool) []db.Operation {
+	if !answered {
+		return operations
+	}
+
+	failed := []db.Operation{}
+	for _, operation := range operations {
+		if !shared.StringInSlice(operation.UUID, running) {
+			failed = append(failed, operation)
+		}
+	}
+
+	return failed
+}
+
+// Render an operation whose node went offline while running it. Only its ID
+// is known, the rest being lost with the node.
+func operationFailedRender(operation db.Operation) *api.Operation {
+	now := time.Now()
+
+	return &api.Operation{
+		ID:         operation.UUID,
+		Class:      operationClassTask.String(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Status:     api.Failure.String(),
+		StatusCode: api.Failure,
+		Err:        fmt.Sprintf("Node %s went offline while running the operation", operation.NodeAddress),
+	}
+}
+
+// Get the operation with the given UUID if its node went offline while
+// running it.
+func operationFailedGet(cluster *db.Cluster, id string) (*db.Operation, error) {
+	var operation db.Operation
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		operation, err = tx.OperationByUUID(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !operation.Failed {
+		return nil, db.ErrNoSuchObject
+	}
+
+	return &operation, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/db"
+)
+
+// A node which answers only gets the operations it forgot about failed, while
+// all the operations of a dead node are.
+func TestOperationsReapFailed(t *testing.T) {
+	operations := []db.Operation{{UUID: "abcd"}, {UUID: "efgh"}}
+
+	failed := operationsReapFailed(operations, []string{"abcd"}, true)
+	assert.Equal(t, []db.Operation{{UUID: "efgh"}}, failed)
+
+	failed = operationsReapFailed(operations, []string{"abcd", "efgh"}, true)
+	assert.Len(t, failed, 0)
+
+	failed = operationsReapFailed(operations, nil, false)
+	assert.Equal(t, operations, failed)
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -55,6 +56,7 @@ var patches = []patch{
 	{name: "devices_new_naming_scheme", run: patchDevicesNewNamingScheme},
 	{name: "storage_api_permissions", run: patchStorageApiPermissions},
 	{name: "container_config_regen", run: patchContainerConfigRegen},
+	{name: "container_idmaps", run: patchContainerIdmaps},
 }
 
 type patch struct {
@@ -426,7 +428,7 @@ func upgradeFromStorageTypeBtrfs(name string, d *Daemon, defaultPoolName string,
 					return err
 				}
 
-				output, err := rsyncLocalCopy(oldContainerMntPoint, newContainerMntPoint, "")
+				output, err := rsyncLocalCopy(oldContainerMntPoint, newContainerMntPoint, "", "--checksum")
 				if err != nil {
 					logger.Errorf("Failed to rsync: %s: %s", output, err)
 					return err
@@ -513,7 +515,7 @@ func upgradeFromStorageTypeBtrfs(name string, d *Daemon, defaultPoolName string,
 						return err
 					}
 
-					output, err := rsyncLocalCopy(oldSnapshotMntPoint, newSnapshotMntPoint, "")
+					output, err := rsyncLocalCopy(oldSnapshotMntPoint, newSnapshotMntPoint, "", "--checksum")
 					if err != nil {
 						logger.Errorf("Failed to rsync: %s: %s", output, err)
 						return err
@@ -717,7 +719,7 @@ func upgradeFromStorageTypeDir(name string, d *Daemon, defaultPoolName string, d
 			// First try to rename.
 			err := os.Rename(oldContainerMntPoint, newContainerMntPoint)
 			if err != nil {
-				output, err := rsyncLocalCopy(oldContainerMntPoint, newContainerMntPoint, "")
+				output, err := rsyncLocalCopy(oldContainerMntPoint, newContainerMntPoint, "", "--checksum")
 				if err != nil {
 					logger.Errorf("Failed to rsync: %s: %s", output, err)
 					return err
@@ -764,7 +766,7 @@ func upgradeFromStorageTypeDir(name string, d *Daemon, defaultPoolName string, d
 		if shared.PathExists(oldSnapshotMntPoint) && !shared.PathExists(newSnapshotMntPoint) {
 			err := os.Rename(oldSnapshotMntPoint, newSnapshotMntPoint)
 			if err != nil {
-				output, err := rsyncLocalCopy(oldSnapshotMntPoint, newSnapshotMntPoint, "")
+				output, err := rsyncLocalCopy(oldSnapshotMntPoint, newSnapshotMntPoint, "", "--checksum")
 				if err != nil {
 					logger.Errorf("Failed to rsync: %s: %s", output, err)
 					return err
@@ -1096,7 +1098,7 @@ func upgradeFromStorageTypeLvm(name string, d *Daemon, defaultPoolName string, d
 				}
 
 				// Use rsync to fill the empty volume.
-				output, err := rsyncLocalCopy(oldContainerMntPoint, newContainerMntPoint, "")
+				output, err := rsyncLocalCopy(oldContainerMntPoint, newContainerMntPoint, "", "--checksum")
 				if err != nil {
 					ctStorage.ContainerDelete(ctStruct)
 					return fmt.Errorf("rsync failed: %s", string(output))
@@ -1250,7 +1252,7 @@ func upgradeFromStorageTypeLvm(name string, d *Daemon, defaultPoolName string, d
 					}
 
 					// Use rsync to fill the empty volume.
-					output, err := rsyncLocalCopy(oldSnapshotMntPoint, newSnapshotMntPoint, "")
+					output, err := rsyncLocalCopy(oldSnapshotMntPoint, newSnapshotMntPoint, "", "--checksum")
 					if err != nil {
 						csStorage.ContainerDelete(csStruct)
 						return fmt.Errorf("rsync failed: %s", string(output))
@@ -1937,6 +1939,40 @@ func patchContainerConfigRegen(name string, d *Daemon) error {
 	return nil
 }
 
+// Record the idmap ranges of the existing isolated containers, which used to
+// be tracked only through their volatile.idmap.base key.
+func patchContainerIdmaps(name string, d *Daemon) error {
+	cts, err := containerLoadNodeAll(d.State())
+	if err != nil {
+		return err
+	}
+
+	return d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		for _, c := range cts {
+			if c.IsPrivileged() || !shared.IsTrue(c.ExpandedConfig()["security.idmap.isolated"]) {
+				continue
+			}
+
+			base, err := strconv.ParseInt(c.ExpandedConfig()["volatile.idmap.base"], 10, 64)
+			if err != nil {
+				continue
+			}
+
+			size, err := idmapSize(d.State(), c.ExpandedConfig()["security.idmap.isolated"], c.ExpandedConfig()["security.idmap.size"])
+			if err != nil {
+				return err
+			}
+
+			err = tx.ContainerIdmapSet(c.Name(), base, size)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 func patchStorageApiDirCleanup(name string, d *Daemon) error {
 	fingerprints, err := d.cluster.ImagesGet(false)
 	if err != nil {
@@ -2931,7 +2967,7 @@ func patchUpdateFromV11(d *Daemon) error {
 			// containers/<container>/snapshots/<snap0>
 			// to
 			// snapshots/<container>/<snap0>
-			output, err := rsyncLocalCopy(oldPath, newPath, "")
+			output, err := rsyncLocalCopy(oldPath, newPath, "", "--checksum")
 			if err != nil {
 				logger.Error(
 					"Failed rsync snapshot",
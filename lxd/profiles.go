@@ -97,6 +97,8 @@ func profilesPost(d *Daemon, r *http.Request) Response {
 			fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
 	}
 
+	profileCacheInvalidate(req.Name)
+
 	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/profiles/%s", version.APIVersion, req.Name))
 }
 
@@ -175,6 +177,15 @@ func profilePut(d *Daemon, r *http.Request) Response {
 
 	}
 
+	dryRun := shared.IsTrue(r.FormValue("dry-run"))
+	if dryRun {
+		// Previews are computed by the node the containers are on
+		response := ForwardedResponseIfTargetIsRemote(d, r)
+		if response != nil {
+			return response
+		}
+	}
+
 	id, profile, err := d.cluster.ProfileGet(name)
 	if err != nil {
 		return SmartError(fmt.Errorf("Failed to retrieve profile='%s'", name))
@@ -192,6 +203,10 @@ func profilePut(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	if dryRun {
+		return doProfileUpdatePreviewResponse(d, name, profile, req)
+	}
+
 	err = doProfileUpdate(d, name, id, profile, req)
 
 	if err == nil && !isClusterNotification(r) {
@@ -214,6 +229,16 @@ func profilePut(d *Daemon, r *http.Request) Response {
 func profilePatch(d *Daemon, r *http.Request) Response {
 	// Get the profile
 	name := mux.Vars(r)["name"]
+
+	dryRun := shared.IsTrue(r.FormValue("dry-run"))
+	if dryRun {
+		// Previews are computed by the node the containers are on
+		response := ForwardedResponseIfTargetIsRemote(d, r)
+		if response != nil {
+			return response
+		}
+	}
+
 	id, profile, err := d.cluster.ProfileGet(name)
 	if err != nil {
 		return SmartError(fmt.Errorf("Failed to retrieve profile='%s'", name))
@@ -274,7 +299,65 @@ func profilePatch(d *Daemon, r *http.Request) Response {
 		}
 	}
 
-	return SmartError(doProfileUpdate(d, name, id, profile, req))
+	if dryRun {
+		return doProfileUpdatePreviewResponse(d, name, profile, req)
+	}
+
+	err = doProfileUpdate(d, name, id, profile, req)
+
+	if err == nil && !isClusterNotification(r) {
+		// Notify all other nodes. If a node is down, it will be ignored.
+		notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), cluster.NotifyAlive)
+		if err != nil {
+			return SmartError(err)
+		}
+		err = notifier(func(client lxd.ContainerServer) error {
+			return client.UpdateProfile(name, profile.ProfilePut, "")
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	return SmartError(err)
+}
+
+func doProfileUpdatePreviewResponse(d *Daemon, name string, profile *api.Profile, req api.ProfilePut) Response {
+	preview, err := doProfileUpdatePreview(d, name, profile, req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	return SyncResponse(true, preview)
+}
+
+func profileUsedByGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	profile, err := doProfileGet(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !util.IsRecursionRequest(r) {
+		return SyncResponse(true, profile.UsedBy)
+	}
+
+	containers, err := getProfileContainersInfo(d.cluster, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := []api.ProfileUsedByContainer{}
+	for _, args := range containers {
+		result = append(result, api.ProfileUsedByContainer{
+			Name:     args.Name,
+			Location: args.Node,
+			Profiles: args.Profiles,
+		})
+	}
+
+	return SyncResponse(true, result)
 }
 
 // The handler for the post operation.
@@ -310,6 +393,8 @@ func profilePost(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	profileCacheInvalidate(name, req.Name)
+
 	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/profiles/%s", version.APIVersion, req.Name))
 }
 
@@ -332,7 +417,11 @@ func profileDelete(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	profileCacheInvalidate(name)
+
 	return EmptySyncResponse
 }
 
 var profileCmd = Command{name: "profiles/{name}", get: profileGet, put: profilePut, delete: profileDelete, post: profilePost, patch: profilePatch}
+
+var profileUsedByCmd = Command{name: "profiles/{name}/used-by", get: profileUsedByGet}
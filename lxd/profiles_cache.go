@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
+)
+
+// Cache of the config and devices of profiles, used to expand those of
+// containers without querying the database each time one gets loaded.
+//
+// Entries are dropped whenever a profile changes on this node or a
+// notification about such a change comes from another node, and otherwise
+// expire after profileCacheExpiry, to catch up with the changes which aren't
+// notified to the other nodes (renames and deletions).
+type profileCacheEntry struct {
+	config  map[string]string
+	devices types.Devices
+	fetched time.Time
+}
+
+const profileCacheExpiry = time.Minute
+
+var profileCacheLock sync.Mutex
+var profileCache = map[string]profileCacheEntry{}
+
+// Return copies of the config and devices of the given profiles, in the same
+// order, only querying the database for those which aren't cached.
+func profileCacheGet(s *state.State, names []string) ([]map[string]string, []types.Devices, error) {
+	profileCacheLock.Lock()
+	defer profileCacheLock.Unlock()
+
+	missing := []string{}
+	for _, name := range names {
+		entry, ok := profileCache[name]
+		if !ok || time.Since(entry.fetched) > profileCacheExpiry {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		var configs map[string]map[string]string
+		var devices map[string]types.Devices
+		err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			configs, devices, err = tx.ProfilesExpandData(missing)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		now := time.Now()
+		for _, name := range missing {
+			config, ok := configs[name]
+			if !ok {
+				delete(profileCache, name)
+				return nil, nil, db.ErrNoSuchObject
+			}
+
+			profileCache[name] = profileCacheEntry{
+				config:  config,
+				devices: devices[name],
+				fetched: now,
+			}
+		}
+	}
+
+	// Hand out copies, so that callers can't alter the cache
+	profileConfigs := make([]map[string]string, len(names))
+	profileDevices := make([]types.Devices, len(names))
+	for i, name := range names {
+		entry := profileCache[name]
+
+		profileConfigs[i] = map[string]string{}
+		for k, v := range entry.config {
+			profileConfigs[i][k] = v
+		}
+
+		profileDevices[i] = types.Devices{}
+		for k, m := range entry.devices {
+			device := map[string]string{}
+			for key, value := range m {
+				device[key] = value
+			}
+			profileDevices[i][k] = device
+		}
+	}
+
+	return profileConfigs, profileDevices, nil
+}
+
+// Drop the cached config and devices of the given profiles.
+func profileCacheInvalidate(names ...string) {
+	profileCacheLock.Lock()
+	defer profileCacheLock.Unlock()
+
+	for _, name := range names {
+		delete(profileCache, name)
+	}
+}
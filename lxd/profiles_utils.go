@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/db/query"
@@ -29,40 +31,9 @@ func doProfileUpdate(d *Daemon, name string, id int64, profile *api.Profile, req
 		return errors.Wrapf(err, "failed to query containers associated with profile '%s'", name)
 	}
 
-	// Check if the root device is supposed to be changed or removed.
-	oldProfileRootDiskDeviceKey, oldProfileRootDiskDevice, _ := shared.GetRootDiskDevice(profile.Devices)
-	_, newProfileRootDiskDevice, _ := shared.GetRootDiskDevice(req.Devices)
-	if len(containers) > 0 && oldProfileRootDiskDevice["pool"] != "" && newProfileRootDiskDevice["pool"] == "" || (oldProfileRootDiskDevice["pool"] != newProfileRootDiskDevice["pool"]) {
-		// Check for containers using the device
-		for _, container := range containers {
-			// Check if the device is locally overridden
-			k, v, _ := shared.GetRootDiskDevice(container.Devices)
-			if k != "" && v["pool"] != "" {
-				continue
-			}
-
-			// Check what profile the device comes from
-			profiles := container.Profiles
-			for i := len(profiles) - 1; i >= 0; i-- {
-				_, profile, err := d.cluster.ProfileGet(profiles[i])
-				if err != nil {
-					return err
-				}
-
-				// Check if we find a match for the device
-				_, ok := profile.Devices[oldProfileRootDiskDeviceKey]
-				if ok {
-					// Found the profile
-					if profiles[i] == name {
-						// If it's the current profile, then we can't modify that root device
-						return fmt.Errorf("At least one container relies on this profile's root disk device.")
-					} else {
-						// If it's not, then move on to the next container
-						break
-					}
-				}
-			}
-		}
+	err = doProfileUpdateCheckRootDisk(d, name, profile, req, containers)
+	if err != nil {
+		return err
 	}
 
 	// Update the database
@@ -118,6 +89,9 @@ func doProfileUpdate(d *Daemon, name string, id int64, profile *api.Profile, req
 		return err
 	}
 
+	// The containers need to be expanded with the new profile
+	profileCacheInvalidate(name)
+
 	// Update all the containers on this node using the profile. Must be
 	// done after db.TxCommit due to DB lock.
 	nodeName := ""
@@ -148,6 +122,48 @@ func doProfileUpdate(d *Daemon, name string, id int64, profile *api.Profile, req
 	return nil
 }
 
+// Check that the root disk device of a profile isn't changed or removed while
+// containers rely on it.
+func doProfileUpdateCheckRootDisk(d *Daemon, name string, profile *api.Profile, req api.ProfilePut, containers []db.ContainerArgs) error {
+	// Check if the root device is supposed to be changed or removed.
+	oldProfileRootDiskDeviceKey, oldProfileRootDiskDevice, _ := shared.GetRootDiskDevice(profile.Devices)
+	_, newProfileRootDiskDevice, _ := shared.GetRootDiskDevice(req.Devices)
+	if len(containers) > 0 && oldProfileRootDiskDevice["pool"] != "" && newProfileRootDiskDevice["pool"] == "" || (oldProfileRootDiskDevice["pool"] != newProfileRootDiskDevice["pool"]) {
+		// Check for containers using the device
+		for _, container := range containers {
+			// Check if the device is locally overridden
+			k, v, _ := shared.GetRootDiskDevice(container.Devices)
+			if k != "" && v["pool"] != "" {
+				continue
+			}
+
+			// Check what profile the device comes from
+			profiles := container.Profiles
+			for i := len(profiles) - 1; i >= 0; i-- {
+				_, profile, err := d.cluster.ProfileGet(profiles[i])
+				if err != nil {
+					return err
+				}
+
+				// Check if we find a match for the device
+				_, ok := profile.Devices[oldProfileRootDiskDeviceKey]
+				if ok {
+					// Found the profile
+					if profiles[i] == name {
+						// If it's the current profile, then we can't modify that root device
+						return fmt.Errorf("At least one container relies on this profile's root disk device.")
+					} else {
+						// If it's not, then move on to the next container
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 // Like doProfileUpdate but does not update the database, since it was already
 // updated by doProfileUpdate itself, called on the notifying node.
 func doProfileUpdateCluster(d *Daemon, name string, old api.ProfilePut) error {
@@ -161,6 +177,8 @@ func doProfileUpdateCluster(d *Daemon, name string, old api.ProfilePut) error {
 		return errors.Wrap(err, "failed to query local node name")
 	}
 
+	profileCacheInvalidate(name)
+
 	containers, err := getProfileContainersInfo(d.cluster, name)
 	if err != nil {
 		return errors.Wrapf(err, "failed to query containers associated with profile '%s'", name)
@@ -192,17 +210,35 @@ func doProfileUpdateContainer(d *Daemon, name string, old api.ProfilePut, nodeNa
 		return nil
 	}
 
+	c, err := doProfileUpdateExpand(d, name, old, args)
+	if err != nil {
+		return err
+	}
+
+	return c.Update(db.ContainerArgs{
+		Architecture: c.Architecture(),
+		Config:       c.LocalConfig(),
+		Description:  c.Description(),
+		Devices:      c.LocalDevices(),
+		Ephemeral:    c.IsEphemeral(),
+		Profiles:     c.Profiles(),
+	}, true)
+}
+
+// Instantiate the given container with its config and devices expanded using
+// the given version of the named profile.
+func doProfileUpdateExpand(d *Daemon, name string, put api.ProfilePut, args db.ContainerArgs) (*containerLXC, error) {
 	profileConfigs := make([]map[string]string, len(args.Profiles))
 	for i, profileName := range args.Profiles {
 		if profileName == name {
-			// Use the old config.
-			profileConfigs[i] = old.Config
+			// Use the given config.
+			profileConfigs[i] = put.Config
 			continue
 		}
 		// Use the config currently in the database.
 		profileConfig, err := d.cluster.ProfileConfig(profileName)
 		if err != nil {
-			return errors.Wrapf(err, "failed to load profile config for '%s'", profileName)
+			return nil, errors.Wrapf(err, "failed to load profile config for '%s'", profileName)
 		}
 		profileConfigs[i] = profileConfig
 	}
@@ -210,14 +246,14 @@ func doProfileUpdateContainer(d *Daemon, name string, old api.ProfilePut, nodeNa
 	profileDevices := make([]types.Devices, len(args.Profiles))
 	for i, profileName := range args.Profiles {
 		if profileName == name {
-			// Use the old devices
-			profileDevices[i] = old.Devices
+			// Use the given devices
+			profileDevices[i] = put.Devices
 			continue
 		}
 		// Use the config currently in the database.
 		devices, err := d.cluster.Devices(profileName, true)
 		if err != nil {
-			return errors.Wrapf(err, "failed to load profile devices for '%s'", profileName)
+			return nil, errors.Wrapf(err, "failed to load profile devices for '%s'", profileName)
 		}
 		profileDevices[i] = devices
 	}
@@ -226,14 +262,7 @@ func doProfileUpdateContainer(d *Daemon, name string, old api.ProfilePut, nodeNa
 	c.expandConfigFromProfiles(profileConfigs)
 	c.expandDevicesFromProfiles(profileDevices)
 
-	return c.Update(db.ContainerArgs{
-		Architecture: c.Architecture(),
-		Config:       c.LocalConfig(),
-		Description:  c.Description(),
-		Devices:      c.LocalDevices(),
-		Ephemeral:    c.IsEphemeral(),
-		Profiles:     c.Profiles(),
-	}, true)
+	return c, nil
 }
 
 // Query the db for information about containers associated with the given
@@ -256,3 +285,141 @@ func getProfileContainersInfo(cluster *db.Cluster, profile string) ([]db.Contain
 
 	return containers, nil
 }
+
+// Config keys which containerLXC.Update applies to running containers, or
+// which don't affect them until they next need it.
+func profileUpdateLiveKey(key string) bool {
+	if shared.StringInSlice(key, []string{"raw.apparmor", "security.devlxd", "security.devlxd.images", "linux.exec_agent", "linux.exec_environment", "linux.kernel_modules", "limits.cpu", "limits.processes"}) {
+		return true
+	}
+
+	for _, prefix := range []string{"boot.", "environment.", "image.", "limits.cpu.", "limits.disk.", "limits.memory", "limits.network.", "snapshots.", "user.", "volatile."} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Work out what applying the given change to a profile would do to the
+// containers on this node using it, without changing anything.
+func doProfileUpdatePreview(d *Daemon, name string, profile *api.Profile, req api.ProfilePut) (*api.ProfileUpdatePreview, error) {
+	// Sanity checks
+	err := containerValidConfig(d.os, req.Config, true, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = containerValidDevices(d.cluster, req.Devices, true, false)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := getProfileContainersInfo(d.cluster, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query containers associated with profile '%s'", name)
+	}
+
+	err = doProfileUpdateCheckRootDisk(d, name, profile, req, containers)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeName := ""
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		nodeName, err = tx.NodeName()
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query local node name")
+	}
+
+	preview := api.ProfileUpdatePreview{
+		Containers: []api.ProfileUpdatePreviewContainer{},
+	}
+
+	for _, args := range containers {
+		if args.Node != "" && args.Node != nodeName {
+			continue
+		}
+
+		old, err := doProfileUpdateExpand(d, name, profile.ProfilePut, args)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := doProfileUpdateExpand(d, name, req, args)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := api.ProfileUpdatePreviewContainer{
+			Name:           args.Name,
+			Running:        c.IsRunning(),
+			ChangedConfig:  []string{},
+			ChangedDevices: []string{},
+		}
+
+		// Diff the configurations
+		oldConfig := old.ExpandedConfig()
+		newConfig := c.ExpandedConfig()
+		for key := range oldConfig {
+			if oldConfig[key] != newConfig[key] {
+				entry.ChangedConfig = append(entry.ChangedConfig, key)
+			}
+		}
+
+		for key := range newConfig {
+			_, ok := oldConfig[key]
+			if !ok {
+				entry.ChangedConfig = append(entry.ChangedConfig, key)
+			}
+		}
+
+		sort.Strings(entry.ChangedConfig)
+
+		// Diff the devices
+		removeDevices, addDevices, updateDevices, _ := old.ExpandedDevices().Update(c.ExpandedDevices())
+		for _, devices := range []types.Devices{removeDevices, addDevices, updateDevices} {
+			for devName := range devices {
+				if !shared.StringInSlice(devName, entry.ChangedDevices) {
+					entry.ChangedDevices = append(entry.ChangedDevices, devName)
+				}
+			}
+		}
+
+		sort.Strings(entry.ChangedDevices)
+
+		// Validate the result
+		err = containerValidConfig(d.os, newConfig, false, true)
+		if err == nil {
+			err = containerValidDevices(d.cluster, c.ExpandedDevices(), false, true)
+		}
+
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		// Check whether the changes can be applied live
+		if entry.Running {
+			for _, key := range entry.ChangedConfig {
+				if !profileUpdateLiveKey(key) {
+					entry.RestartRequired = true
+				}
+			}
+
+			for _, devName := range entry.ChangedDevices {
+				device := old.ExpandedDevices()[devName]
+				if device["type"] == "disk" && device["path"] == "/" {
+					entry.RestartRequired = true
+				}
+			}
+		}
+
+		preview.Containers = append(preview.Containers, entry)
+	}
+
+	return &preview, nil
+}
@@ -21,8 +21,13 @@ type proxyProcInfo struct {
 	listenAddrGid  string
 	listenAddrUid  string
 	listenAddrMode string
+	connectTimeout string
 }
 
+// Number of seconds during which connections to a proxy device with
+// "wake_on_connect" are held while its container and workload start.
+const proxyWakeTimeout = 30
+
 func setupProxyProcInfo(c container, device map[string]string) (*proxyProcInfo, error) {
 	pid := c.InitPID()
 	containerPid := strconv.Itoa(int(pid))
@@ -56,6 +61,11 @@ func setupProxyProcInfo(c container, device map[string]string) (*proxyProcInfo,
 		return nil, fmt.Errorf("Invalid binding side given. Must be \"host\" or \"container\".")
 	}
 
+	connectTimeout := "0"
+	if shared.IsTrue(device["wake_on_connect"]) {
+		connectTimeout = strconv.Itoa(proxyWakeTimeout)
+	}
+
 	p := &proxyProcInfo{
 		listenPid:      listenPid,
 		connectPid:     connectPid,
@@ -64,6 +74,7 @@ func setupProxyProcInfo(c container, device map[string]string) (*proxyProcInfo,
 		listenAddrGid:  device["gid"],
 		listenAddrUid:  device["uid"],
 		listenAddrMode: device["mode"],
+		connectTimeout: connectTimeout,
 	}
 
 	return p, nil
@@ -2,11 +2,17 @@ package main
 
 import (
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
 )
 
 // /1.0/resources
@@ -24,31 +30,252 @@ func serverResourcesGet(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	gpu, err := util.GPUResource()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	usb, err := util.USBResource()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	network, err := util.NetworkResource()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	numa, err := util.NUMAResource()
+	if err != nil {
+		return SmartError(err)
+	}
+
 	res.CPU = *cpu
 	res.Memory = *mem
+	res.GPU = *gpu
+	res.USB = *usb
+	res.Network = *network
+	res.NUMA = *numa
+
+	// Requests from other nodes only get the resources of this node, to
+	// fill in their own breakdown.
+	var pools []api.ResourcesStoragePoolUsage
+	if isClusterNotification(r) {
+		pools, err = storagePoolsResourcesLocal.get(func() ([]api.ResourcesStoragePoolUsage, error) {
+			return storagePoolsResources(d.State())
+		})
+	} else {
+		pools, err = storagePoolsResourcesCluster.get(func() ([]api.ResourcesStoragePoolUsage, error) {
+			local, err := storagePoolsResourcesLocal.get(func() ([]api.ResourcesStoragePoolUsage, error) {
+				return storagePoolsResources(d.State())
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			// Don't touch the cached local resources
+			pools := make([]api.ResourcesStoragePoolUsage, len(local))
+			copy(pools, local)
+
+			err = storagePoolsResourcesNodes(d, pools)
+			if err != nil {
+				return nil, err
+			}
+
+			return pools, nil
+		})
+	}
+	if err != nil {
+		return SmartError(err)
+	}
+
+	res.StoragePools = pools
 
 	return SyncResponse(true, res)
 }
 
 var serverResourceCmd = Command{name: "resources", get: serverResourcesGet}
 
-// /1.0/storage-pools/{name}/resources
-// Get resources for a specific storage pool
-func storagePoolResourcesGet(d *Daemon, r *http.Request) Response {
-	poolName := mux.Vars(r)["name"]
+// Gathering the resources of the storage pools means checking every pool and,
+// in a cluster, asking every node for its own, which is too costly to do on
+// every request. They're cached for a little while instead.
+const storagePoolsResourcesTTL = 30 * time.Second
+
+type storagePoolsResourcesCache struct {
+	mu      sync.Mutex
+	pools   []api.ResourcesStoragePoolUsage
+	updated time.Time
+}
 
-	// Get the existing storage pool.
-	s, err := storagePoolInit(d.State(), poolName)
+var storagePoolsResourcesLocal = &storagePoolsResourcesCache{}   // This node only
+var storagePoolsResourcesCluster = &storagePoolsResourcesCache{} // With the breakdown by node
+
+// Return the cached resources, calling the given function to refresh them if
+// they're too old. Concurrent callers wait for a single refresh.
+func (c *storagePoolsResourcesCache) get(load func() ([]api.ResourcesStoragePoolUsage, error)) ([]api.ResourcesStoragePoolUsage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pools != nil && time.Since(c.updated) < storagePoolsResourcesTTL {
+		return c.pools, nil
+	}
+
+	pools, err := load()
 	if err != nil {
-		return InternalError(err)
+		return nil, err
+	}
+
+	c.pools = pools
+	c.updated = time.Now()
+
+	return pools, nil
+}
+
+// Get the resources of all the storage pools of this node. A pool whose
+// resources can't be retrieved is still listed, with empty resources.
+func storagePoolsResources(s *state.State) ([]api.ResourcesStoragePoolUsage, error) {
+	names, err := s.Cluster.StoragePools()
+	if err != nil && err != db.ErrNoSuchObject {
+		return nil, err
 	}
 
-	err = s.StoragePoolCheck()
+	pools := []api.ResourcesStoragePoolUsage{}
+	for _, name := range names {
+		poolID, pool, err := s.Cluster.StoragePoolGet(name)
+		if err != nil {
+			return nil, err
+		}
+
+		usedBy, err := storagePoolUsedByGet(s, poolID, name)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := api.ResourcesStoragePoolUsage{
+			Name:   name,
+			Driver: pool.Driver,
+			UsedBy: usedBy,
+		}
+
+		// Don't try to bring back unavailable pools here, the recovery
+		// task takes care of it.
+		err = storagePoolCheckAvailable(name)
+		if err != nil {
+			pools = append(pools, entry)
+			continue
+		}
+
+		res, err := storagePoolResources(s, name)
+		if err != nil {
+			logger.Warnf("Failed to get the resources of storage pool \"%s\": %v", name, err)
+		} else {
+			entry.ResourcesStoragePool = *res
+		}
+
+		pools = append(pools, entry)
+	}
+
+	return pools, nil
+}
+
+func storagePoolResources(s *state.State, poolName string) (*api.ResourcesStoragePool, error) {
+	pool, err := storagePoolInit(s, poolName)
 	if err != nil {
-		return InternalError(err)
+		return nil, err
+	}
+
+	err = pool.StoragePoolCheck()
+	if err != nil {
+		return nil, err
+	}
+
+	return pool.StoragePoolResources()
+}
+
+// Fill in the resources of the storage pools on each node of a cluster, as
+// reported by the nodes themselves, which are all asked at the same time.
+// Offline nodes and nodes failing to answer are left out of the breakdown.
+func storagePoolsResourcesNodes(d *Daemon, pools []api.ResourcesStoragePoolUsage) error {
+	var nodes []db.NodeInfo
+	var localName string
+	var offlineThreshold time.Duration
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+
+		nodes, err = tx.Nodes()
+		if err != nil {
+			return err
+		}
+
+		localName, err = tx.NodeName()
+		if err != nil {
+			return err
+		}
+
+		offlineThreshold, err = tx.NodeOfflineThreshold()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Not clustered
+	if len(nodes) < 2 {
+		return nil
+	}
+
+	for i := range pools {
+		pools[i].Nodes = map[string]api.ResourcesStoragePool{localName: pools[i].ResourcesStoragePool}
+	}
+
+	cert := d.endpoints.NetworkCert()
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, node := range nodes {
+		if node.Name == localName || node.IsOffline(offlineThreshold) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(node db.NodeInfo) {
+			defer wg.Done()
+
+			client, err := cluster.Connect(node.Address, cert, true)
+			if err != nil {
+				logger.Warnf("Failed to connect to node %s: %v", node.Name, err)
+				return
+			}
+
+			res, err := client.GetServerResources()
+			if err != nil {
+				logger.Warnf("Failed to get the resources of node %s: %v", node.Name, err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for _, remote := range res.StoragePools {
+				for i := range pools {
+					if pools[i].Name == remote.Name {
+						pools[i].Nodes[node.Name] = remote.ResourcesStoragePool
+					}
+				}
+			}
+		}(node)
 	}
+	wg.Wait()
+
+	return nil
+}
+
+// /1.0/storage-pools/{name}/resources
+// Get resources for a specific storage pool
+func storagePoolResourcesGet(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["name"]
 
-	res, err := s.StoragePoolResources()
+	res, err := storagePoolResources(d.State(), poolName)
 	if err != nil {
 		return InternalError(err)
 	}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// The resources of the storage pools are only gathered again once they're too
+// old, and failures aren't cached.
+func TestStoragePoolsResourcesCache(t *testing.T) {
+	cache := &storagePoolsResourcesCache{}
+
+	calls := 0
+	load := func() ([]api.ResourcesStoragePoolUsage, error) {
+		calls++
+		return []api.ResourcesStoragePoolUsage{{Name: "default"}}, nil
+	}
+
+	fail := func() ([]api.ResourcesStoragePoolUsage, error) {
+		return nil, fmt.Errorf("boom")
+	}
+
+	_, err := cache.get(fail)
+	assert.EqualError(t, err, "boom")
+
+	pools, err := cache.get(load)
+	require.NoError(t, err)
+	assert.Equal(t, "default", pools[0].Name)
+
+	_, err = cache.get(load)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	cache.updated = cache.updated.Add(-storagePoolsResourcesTTL)
+	_, err = cache.get(load)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
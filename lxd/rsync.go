@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"sync"
 	"syscall"
 
 	"github.com/gorilla/websocket"
@@ -16,8 +17,50 @@ import (
 	"github.com/lxc/lxd/shared/logger"
 )
 
+// rsyncPoolArgs returns the extra rsync arguments to use for local copies on
+// a storage pool with the given config.
+func rsyncPoolArgs(config map[string]string) []string {
+	if config["rsync.checksum"] != "" && !shared.IsTrue(config["rsync.checksum"]) {
+		return nil
+	}
+
+	return []string{"--checksum"}
+}
+
+// Websockets whose rsync transfers compare files by checksum, as negotiated
+// between the two ends of a migration.
+var rsyncChecksumLock sync.Mutex
+var rsyncChecksumConns = map[*websocket.Conn]bool{}
+
+func rsyncChecksumSet(conn *websocket.Conn) {
+	rsyncChecksumLock.Lock()
+	defer rsyncChecksumLock.Unlock()
+
+	rsyncChecksumConns[conn] = true
+}
+
+func rsyncChecksumUnset(conn *websocket.Conn) {
+	rsyncChecksumLock.Lock()
+	defer rsyncChecksumLock.Unlock()
+
+	delete(rsyncChecksumConns, conn)
+}
+
+// rsyncMigrationArgs returns the extra rsync arguments to use on both ends of
+// a transfer over the given websocket.
+func rsyncMigrationArgs(conn *websocket.Conn) []string {
+	rsyncChecksumLock.Lock()
+	defer rsyncChecksumLock.Unlock()
+
+	if !rsyncChecksumConns[conn] {
+		return nil
+	}
+
+	return []string{"--checksum"}
+}
+
 // rsyncCopy copies a directory using rsync (with the --devices option).
-func rsyncLocalCopy(source string, dest string, bwlimit string) (string, error) {
+func rsyncLocalCopy(source string, dest string, bwlimit string, rsyncArgs ...string) (string, error) {
 	err := os.MkdirAll(dest, 0755)
 	if err != nil {
 		return "", err
@@ -32,18 +75,23 @@ func rsyncLocalCopy(source string, dest string, bwlimit string) (string, error)
 		bwlimit = "0"
 	}
 
-	msg, err := shared.RunCommand("rsync",
+	args := []string{
 		"-a",
 		"-HAX",
 		"--sparse",
 		"--devices",
 		"--delete",
-		"--checksum",
 		"--numeric-ids",
+	}
+
+	args = append(args, rsyncArgs...)
+	args = append(args,
 		"--bwlimit", bwlimit,
 		rsyncVerbosity,
 		shared.AddSlash(source),
 		dest)
+
+	msg, err := shared.RunCommand("rsync", args...)
 	if err != nil {
 		runError, ok := err.(shared.RunError)
 		if ok {
@@ -61,7 +109,7 @@ func rsyncLocalCopy(source string, dest string, bwlimit string) (string, error)
 	return msg, nil
 }
 
-func rsyncSendSetup(name string, path string, bwlimit string, execPath string) (*exec.Cmd, net.Conn, io.ReadCloser, error) {
+func rsyncSendSetup(name string, path string, bwlimit string, execPath string, rsyncArgs ...string) (*exec.Cmd, net.Conn, io.ReadCloser, error) {
 	/*
 	 * The way rsync works, it invokes a subprocess that does the actual
 	 * talking (given to it by a -E argument). Since there isn't an easy
@@ -103,12 +151,15 @@ func rsyncSendSetup(name string, path string, bwlimit string, execPath string) (
 		bwlimit = "0"
 	}
 
-	cmd := exec.Command("rsync",
+	args := []string{
 		"-ar",
 		"--devices",
 		"--numeric-ids",
 		"--partial",
 		"--sparse",
+	}
+	args = append(args, rsyncArgs...)
+	args = append(args,
 		path,
 		"localhost:/tmp/foo",
 		"-e",
@@ -116,6 +167,8 @@ func rsyncSendSetup(name string, path string, bwlimit string, execPath string) (
 		"--bwlimit",
 		bwlimit)
 
+	cmd := exec.Command("rsync", args...)
+
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		return nil, nil, nil, err
@@ -139,7 +192,8 @@ func rsyncSendSetup(name string, path string, bwlimit string, execPath string) (
 // RsyncSend sets up the sending half of an rsync, to recursively send the
 // directory pointed to by path over the websocket.
 func RsyncSend(name string, path string, conn *websocket.Conn, readWrapper func(io.ReadCloser) io.ReadCloser, bwlimit string, execPath string) error {
-	cmd, dataSocket, stderr, err := rsyncSendSetup(name, path, bwlimit, execPath)
+	args := append(migrationCompressionRsyncSendArgs(conn), rsyncMigrationArgs(conn)...)
+	cmd, dataSocket, stderr, err := rsyncSendSetup(name, path, bwlimit, execPath, args...)
 	if err != nil {
 		return err
 	}
@@ -152,6 +206,7 @@ func RsyncSend(name string, path string, conn *websocket.Conn, readWrapper func(
 	if readWrapper != nil {
 		readPipe = readWrapper(dataSocket)
 	}
+	readPipe = migrationChecksumReader(conn, readPipe)
 
 	readDone, writeDone := shared.WebsocketMirror(conn, dataSocket, readPipe, nil, nil)
 
@@ -177,15 +232,19 @@ func RsyncSend(name string, path string, conn *websocket.Conn, readWrapper func(
 // half set up by RsyncSend), putting the contents in the directory specified
 // by path.
 func RsyncRecv(path string, conn *websocket.Conn, writeWrapper func(io.WriteCloser) io.WriteCloser) error {
-	cmd := exec.Command("rsync",
+	args := []string{
 		"--server",
 		"-vlogDtpre.iLsfx",
 		"--numeric-ids",
 		"--devices",
 		"--partial",
 		"--sparse",
-		".",
-		path)
+	}
+	args = append(args, migrationCompressionRsyncRecvArgs(conn)...)
+	args = append(args, rsyncMigrationArgs(conn)...)
+	args = append(args, ".", path)
+
+	cmd := exec.Command("rsync", args...)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -210,6 +269,7 @@ func RsyncRecv(path string, conn *websocket.Conn, writeWrapper func(io.WriteClos
 	if writeWrapper != nil {
 		writePipe = writeWrapper(stdin)
 	}
+	writePipe = migrationChecksumWriter(conn, writePipe)
 
 	readDone, writeDone := shared.WebsocketMirror(conn, writePipe, stdout, nil, nil)
 	output, err := ioutil.ReadAll(stderr)
@@ -5,8 +5,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/osarch"
 )
 
@@ -67,6 +70,11 @@ func SeccompProfilePath(c container) string {
 func ContainerNeedsSeccomp(c container) bool {
 	config := c.ExpandedConfig()
 
+	// The policy is only resolved when generating the profile
+	if config["security.seccomp.policy"] != "" {
+		return true
+	}
+
 	keys := []string{
 		"raw.seccomp",
 		"security.syscalls.whitelist",
@@ -96,9 +104,49 @@ func ContainerNeedsSeccomp(c container) bool {
 	return false
 }
 
-func getSeccompProfileContent(c container) (string, error) {
+// Return the seccomp related configuration of the container. If the container
+// references a seccomp policy, the settings of the policy are used instead of
+// the container's own security.syscalls.* and raw.seccomp keys.
+func getSeccompConfig(c container) (map[string]string, error) {
 	config := c.ExpandedConfig()
 
+	name := config["security.seccomp.policy"]
+	if name == "" {
+		return config, nil
+	}
+
+	var policy *api.SeccompPolicy
+	err := c.DaemonState().Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		policy, err = tx.SeccompPolicyGet(name)
+		return err
+	})
+	if err != nil {
+		if err == db.ErrNoSuchObject {
+			return nil, fmt.Errorf("Seccomp policy '%s' doesn't exist", name)
+		}
+
+		return nil, err
+	}
+
+	result := map[string]string{}
+	for key, value := range policy.Config {
+		if key == "raw" {
+			result["raw.seccomp"] = value
+		} else {
+			result[fmt.Sprintf("security.%s", key)] = value
+		}
+	}
+
+	return result, nil
+}
+
+func getSeccompProfileContent(c container) (string, error) {
+	config, err := getSeccompConfig(c)
+	if err != nil {
+		return "", err
+	}
+
 	raw := config["raw.seccomp"]
 	if raw != "" {
 		return raw, nil
@@ -110,6 +158,17 @@ func getSeccompProfileContent(c container) (string, error) {
 	if whitelist != "" {
 		policy += "whitelist\n[all]\n"
 		policy += whitelist
+
+		// Allow the syscalls needed by the requested devices
+		syscalls := seccompDeviceSyscalls(c.ExpandedConfig())
+		if len(syscalls) > 0 {
+			if !strings.HasSuffix(policy, "\n") {
+				policy += "\n"
+			}
+
+			policy += strings.Join(syscalls, "\n") + "\n"
+		}
+
 		return policy, nil
 	}
 
@@ -137,6 +196,22 @@ func getSeccompProfileContent(c container) (string, error) {
 	return policy, nil
 }
 
+// Syscalls used with the fuse and tun devices, which whitelists get extended
+// with when the devices are requested
+func seccompDeviceSyscalls(config map[string]string) []string {
+	syscalls := []string{}
+
+	if shared.IsTrue(config["security.devices.fuse"]) {
+		syscalls = append(syscalls, "mount", "umount2")
+	}
+
+	if shared.IsTrue(config["security.devices.tun"]) {
+		syscalls = append(syscalls, "ioctl")
+	}
+
+	return syscalls
+}
+
 func SeccompCreateProfile(c container) error {
 	/* Unlike apparmor, there is no way to "cache" profiles, and profiles
 	 * are automatically unloaded when a task dies. Thus, we don't need to
@@ -150,7 +225,7 @@ func SeccompCreateProfile(c container) error {
 
 	profile, err := getSeccompProfileContent(c)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	if err := os.MkdirAll(seccompPath, 0700); err != nil {
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var seccompPoliciesCmd = Command{
+	name: "seccomp-policies",
+	get:  seccompPoliciesGet,
+	post: seccompPoliciesPost,
+}
+
+var seccompPolicyCmd = Command{
+	name:   "seccomp-policies/{name}",
+	get:    seccompPolicyGet,
+	put:    seccompPolicyPut,
+	delete: seccompPolicyDelete,
+}
+
+// The keys supported in the config of a seccomp policy. They mirror the
+// security.syscalls.* and raw.seccomp container keys.
+var seccompPolicyConfigKeys = map[string]func(value string) error{
+	"syscalls.blacklist":         shared.IsAny,
+	"syscalls.blacklist_compat":  shared.IsBool,
+	"syscalls.blacklist_default": shared.IsBool,
+	"syscalls.whitelist":         shared.IsAny,
+	"raw":                        shared.IsAny,
+}
+
+func seccompPolicyValidateConfig(config map[string]string) error {
+	for key, value := range config {
+		validator, ok := seccompPolicyConfigKeys[key]
+		if !ok {
+			return fmt.Errorf("Invalid seccomp policy configuration key: %s", key)
+		}
+
+		err := validator(value)
+		if err != nil {
+			return fmt.Errorf("Invalid value for seccomp policy configuration key '%s': %s", key, err)
+		}
+	}
+
+	if config["syscalls.whitelist"] != "" && config["syscalls.blacklist"] != "" {
+		return fmt.Errorf("syscalls.whitelist is mutually exclusive with syscalls.blacklist")
+	}
+
+	return nil
+}
+
+// Return the URLs of the containers referencing the given seccomp policy,
+// either directly or through one of their profiles.
+func seccompPolicyUsedBy(s *state.State, name string) ([]string, error) {
+	usedBy := []string{}
+
+	names, err := s.Cluster.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ctName := range names {
+		c, err := containerLoadByName(s, ctName)
+		if err != nil {
+			logger.Error("Failed opening container", log.Ctx{"container": ctName})
+			continue
+		}
+
+		if c.ExpandedConfig()["security.seccomp.policy"] == name {
+			usedBy = append(usedBy, fmt.Sprintf("/%s/containers/%s", version.APIVersion, ctName))
+		}
+	}
+
+	return usedBy, nil
+}
+
+func doSeccompPolicyGet(s *state.State, name string) (*api.SeccompPolicy, error) {
+	var policy *api.SeccompPolicy
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		policy, err = tx.SeccompPolicyGet(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	policy.UsedBy, err = seccompPolicyUsedBy(s, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func seccompPoliciesGet(d *Daemon, r *http.Request) Response {
+	var names []string
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		names, err = tx.SeccompPolicyNames()
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	recursion := util.IsRecursionRequest(r)
+
+	resultString := []string{}
+	resultMap := []*api.SeccompPolicy{}
+	for _, name := range names {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/seccomp-policies/%s", version.APIVersion, name))
+			continue
+		}
+
+		policy, err := doSeccompPolicyGet(d.State(), name)
+		if err != nil {
+			logger.Error("Failed to get seccomp policy", log.Ctx{"policy": name})
+			continue
+		}
+
+		resultMap = append(resultMap, policy)
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func seccompPoliciesPost(d *Daemon, r *http.Request) Response {
+	req := api.SeccompPoliciesPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	// Sanity checks
+	if req.Name == "" {
+		return BadRequest(fmt.Errorf("No name provided"))
+	}
+
+	if strings.Contains(req.Name, "/") {
+		return BadRequest(fmt.Errorf("Seccomp policy names may not contain slashes"))
+	}
+
+	if shared.StringInSlice(req.Name, []string{".", ".."}) {
+		return BadRequest(fmt.Errorf("Invalid seccomp policy name '%s'", req.Name))
+	}
+
+	err := seccompPolicyValidateConfig(req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.SeccompPolicyCreate(req.Name, req.Description, req.Config)
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/seccomp-policies/%s", version.APIVersion, req.Name))
+}
+
+func seccompPolicyGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	policy, err := doSeccompPolicyGet(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	etag := []interface{}{policy.Config, policy.Description}
+	return SyncResponseETag(true, policy, etag)
+}
+
+// Changes to a seccomp policy only apply to the containers referencing it the
+// next time they're started.
+func seccompPolicyPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	policy, err := doSeccompPolicyGet(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	etag := []interface{}{policy.Config, policy.Description}
+	err = util.EtagCheck(r, etag)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.SeccompPolicyPut{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	err = seccompPolicyValidateConfig(req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.SeccompPolicyUpdate(name, req.Description, req.Config)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func seccompPolicyDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	policy, err := doSeccompPolicyGet(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(policy.UsedBy) != 0 {
+		return BadRequest(fmt.Errorf("Seccomp policy is currently in use"))
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.SeccompPolicyDelete(name)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
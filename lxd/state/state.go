@@ -1,6 +1,7 @@
 package state
 
 import (
+	"github.com/lxc/lxd/lxd/bgp"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/maas"
 	"github.com/lxc/lxd/lxd/sys"
@@ -13,16 +14,18 @@ type State struct {
 	Node    *db.Node
 	Cluster *db.Cluster
 	MAAS    *maas.Controller
+	BGP     *bgp.Server
 	OS      *sys.OS
 }
 
 // NewState returns a new State object with the given database and operating
 // system components.
-func NewState(node *db.Node, cluster *db.Cluster, maas *maas.Controller, os *sys.OS) *State {
+func NewState(node *db.Node, cluster *db.Cluster, maas *maas.Controller, bgp *bgp.Server, os *sys.OS) *State {
 	return &State{
 		Node:    node,
 		Cluster: cluster,
 		MAAS:    maas,
+		BGP:     bgp,
 		OS:      os,
 	}
 }
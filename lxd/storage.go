@@ -84,12 +84,13 @@ const (
 	storageTypeBtrfs storageType = iota
 	storageTypeCeph
 	storageTypeDir
+	storageTypeExternal
 	storageTypeLvm
 	storageTypeMock
 	storageTypeZfs
 )
 
-var supportedStoragePoolDrivers = []string{"btrfs", "ceph", "dir", "lvm", "zfs"}
+var supportedStoragePoolDrivers = []string{"btrfs", "ceph", "dir", "external", "lvm", "zfs"}
 
 func storageTypeToString(sType storageType) (string, error) {
 	switch sType {
@@ -99,6 +100,8 @@ func storageTypeToString(sType storageType) (string, error) {
 		return "ceph", nil
 	case storageTypeDir:
 		return "dir", nil
+	case storageTypeExternal:
+		return "external", nil
 	case storageTypeLvm:
 		return "lvm", nil
 	case storageTypeMock:
@@ -118,6 +121,8 @@ func storageStringToType(sName string) (storageType, error) {
 		return storageTypeCeph, nil
 	case "dir":
 		return storageTypeDir, nil
+	case "external":
+		return storageTypeExternal, nil
 	case "lvm":
 		return storageTypeLvm, nil
 	case "mock":
@@ -164,6 +169,10 @@ type storage interface {
 	SetStoragePoolVolumeWritable(writable *api.StorageVolumePut)
 	GetStoragePoolVolume() *api.StorageVolume
 
+	// StoragePoolVolumeBlockPath returns the path of the block device of
+	// a block custom storage volume.
+	StoragePoolVolumeBlockPath() (string, error)
+
 	// Functions dealing with container storage volumes.
 	// ContainerCreate creates an empty container (no rootfs/metadata.yaml)
 	ContainerCreate(container container) error
@@ -263,6 +272,13 @@ func storageCoreInit(driver string) (storage, error) {
 			return nil, err
 		}
 		return &dir, nil
+	case storageTypeExternal:
+		external := storageExternal{}
+		err = external.StorageCoreInit()
+		if err != nil {
+			return nil, err
+		}
+		return &external, nil
 	case storageTypeCeph:
 		ceph := storageCeph{}
 		err = ceph.StorageCoreInit()
@@ -297,6 +313,17 @@ func storageCoreInit(driver string) (storage, error) {
 }
 
 func storageInit(s *state.State, poolName string, volumeName string, volumeType int) (storage, error) {
+	err := storagePoolCheckAvailable(poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	return storageLoad(s, poolName, volumeName, volumeType)
+}
+
+// Load the storage interface of the given pool, regardless of whether its
+// backend is available.
+func storageLoad(s *state.State, poolName string, volumeName string, volumeType int) (storage, error) {
 	// Load the storage pool.
 	poolID, pool, err := s.Cluster.StoragePoolGet(poolName)
 	if err != nil {
@@ -347,6 +374,17 @@ func storageInit(s *state.State, poolName string, volumeName string, volumeType
 			return nil, err
 		}
 		return &dir, nil
+	case storageTypeExternal:
+		external := storageExternal{}
+		external.poolID = poolID
+		external.pool = pool
+		external.volume = volume
+		external.s = s
+		err = external.StoragePoolInit()
+		if err != nil {
+			return nil, err
+		}
+		return &external, nil
 	case storageTypeCeph:
 		ceph := storageCeph{}
 		ceph.poolID = poolID
@@ -418,10 +456,19 @@ func storagePoolVolumeAttachInit(s *state.State, poolName string, volumeName str
 		}
 	}
 
-	// get next idmapset
-	nextIdmap, err := c.IdmapSet()
-	if err != nil {
-		return nil, err
+	// get next idmapset, shifted volumes are kept unshifted on disk and
+	// remapped by shiftfs instead
+	var nextIdmap *idmap.IdmapSet
+	shifted := shared.IsTrue(poolVolumePut.Config["security.shifted"])
+	if shifted {
+		if !s.OS.Shiftfs {
+			return nil, fmt.Errorf("Storage volume \"%s\" is shifted but the kernel doesn't support shiftfs", volumeName)
+		}
+	} else {
+		nextIdmap, err = c.IdmapSet()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	nextJsonMap := "[]"
@@ -450,6 +497,12 @@ func storagePoolVolumeAttachInit(s *state.State, poolName string, volumeName str
 			return nil, err
 		}
 
+		// Shifted volumes are only unshifted once, when switching
+		// them to being shifted, whatever the idmaps of their users.
+		if shifted {
+			volumeUsedBy = []string{}
+		}
+
 		if len(volumeUsedBy) > 1 {
 			for _, ctName := range volumeUsedBy {
 				ct, err := containerLoadByName(s, ctName)
@@ -865,19 +918,24 @@ func SetupStorageDriver(s *state.State, forceCheck bool) error {
 
 	}
 
+	// Pools are independent from each other, so a slow backend (e.g. a
+	// remote ceph cluster) shouldn't hold back the others.
+	wg := sync.WaitGroup{}
 	for _, pool := range pools {
-		logger.Debugf("Initializing and checking storage pool \"%s\"", pool)
-		s, err := storagePoolInit(s, pool)
-		if err != nil {
-			logger.Errorf("Error initializing storage pool \"%s\": %s, correct functionality of the storage pool cannot be guaranteed", pool, err)
-			continue
-		}
+		wg.Add(1)
+		go func(pool string) {
+			defer wg.Done()
 
-		err = s.StoragePoolCheck()
-		if err != nil {
-			return err
-		}
+			logger.Debugf("Initializing and checking storage pool \"%s\"", pool)
+			storagePoolMarkAvailable(pool)
+			err := storagePoolProbe(s, pool)
+			if err != nil {
+				logger.Errorf("Storage pool \"%s\" is unavailable: %s", pool, err)
+				storagePoolMarkUnavailable(pool, err)
+			}
+		}(pool)
 	}
+	wg.Wait()
 
 	// Update the storage drivers cache in api_1.0.go.
 	storagePoolDriversCacheUpdate(s.Cluster)
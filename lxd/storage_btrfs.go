@@ -499,6 +499,7 @@ func (s *storageBtrfs) StoragePoolUpdate(writable *api.StoragePoolPut,
 	}
 
 	// "rsync.bwlimit" requires no on-disk modifications.
+	// "rsync.checksum" requires no on-disk modifications.
 
 	if shared.StringInSlice("btrfs.mount_options", changedConfig) {
 		s.setBtrfsMountOptions(writable.Config["btrfs.mount_options"])
@@ -1014,7 +1015,7 @@ func (s *storageBtrfs) doCrossPoolContainerCopy(target container, source contain
 	if !containerOnly {
 		for _, snap := range snapshots {
 			srcSnapshotMntPoint := getSnapshotMountPoint(sourcePool, snap.Name())
-			_, err = rsyncLocalCopy(srcSnapshotMntPoint, destContainerMntPoint, bwlimit)
+			_, err = rsyncLocalCopy(srcSnapshotMntPoint, destContainerMntPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 			if err != nil {
 				logger.Errorf("Failed to rsync into BTRFS storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
 				return err
@@ -1030,7 +1031,7 @@ func (s *storageBtrfs) doCrossPoolContainerCopy(target container, source contain
 	}
 
 	srcContainerMntPoint := getContainerMountPoint(sourcePool, source.Name())
-	_, err = rsyncLocalCopy(srcContainerMntPoint, destContainerMntPoint, bwlimit)
+	_, err = rsyncLocalCopy(srcContainerMntPoint, destContainerMntPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 	if err != nil {
 		logger.Errorf("Failed to rsync into BTRFS storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
 		return err
@@ -1237,7 +1238,7 @@ func (s *storageBtrfs) ContainerRestore(container container, sourceContainer con
 			// Use rsync to fill the empty volume.  Sync by using
 			// the subvolume name.
 			bwlimit := s.pool.Config["rsync.bwlimit"]
-			output, err := rsyncLocalCopy(sourceContainerSubvolumeName, targetContainerSubvolumeName, bwlimit)
+			output, err := rsyncLocalCopy(sourceContainerSubvolumeName, targetContainerSubvolumeName, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 			if err != nil {
 				s.ContainerDelete(container)
 				logger.Errorf("ContainerRestore: rsync failed: %s", string(output))
@@ -1625,7 +1626,7 @@ func (s *storageBtrfs) doContainerBackupCreateVanilla(backup backup, source cont
 	}
 
 	rsync := func(oldPath string, newPath string, bwlimit string) error {
-		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit)
+		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 		if err != nil {
 			s.ContainerBackupDelete(backup.Name())
 			return fmt.Errorf("failed to rsync: %s: %s", string(output), err)
@@ -2072,72 +2073,82 @@ func btrfsSubVolumeCreate(subvol string) error {
 	return nil
 }
 
-func btrfsSubVolumeQGroup(subvol string) (string, error) {
+// btrfsSubVolumeID returns the ID of the given subvolume.
+func btrfsSubVolumeID(subvol string) (string, error) {
+	output, err := shared.RunCommand(
+		"btrfs",
+		"inspect-internal",
+		"rootid",
+		subvol)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get subvolume ID of \"%s\": %s", subvol, output)
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+// btrfsSubVolumeQGroupGet returns the quota group of the given subvolume
+// along with the amount of data it references. db.ErrNoSuchObject is
+// returned if quotas aren't enabled on the filesystem.
+func btrfsSubVolumeQGroupGet(subvol string) (string, int64, error) {
+	id, err := btrfsSubVolumeID(subvol)
+	if err != nil {
+		return "", -1, err
+	}
+
 	output, err := shared.RunCommand(
 		"btrfs",
 		"qgroup",
 		"show",
 		subvol,
 		"-e",
-		"-f")
+		"-f",
+		"--raw")
 
 	if err != nil {
-		return "", db.ErrNoSuchObject
+		return "", -1, db.ErrNoSuchObject
 	}
 
-	var qgroup string
+	// Only look at the subvolume's own quota group, the other ones listed
+	// (if any) account for more than just this subvolume.
+	qgroup := fmt.Sprintf("0/%s", id)
 	for _, line := range strings.Split(output, "\n") {
-		if line == "" || strings.HasPrefix(line, "qgroupid") || strings.HasPrefix(line, "---") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != qgroup {
 			continue
 		}
 
-		fields := strings.Fields(line)
-		if len(fields) != 4 {
-			continue
+		usage, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return "", -1, fmt.Errorf("Failed to parse usage of quota group %s: %s", qgroup, fields[1])
 		}
 
-		qgroup = fields[0]
+		return qgroup, usage, nil
 	}
 
-	if qgroup == "" {
-		return "", fmt.Errorf("Unable to find quota group")
+	return "", -1, fmt.Errorf("Unable to find quota group")
+}
+
+func btrfsSubVolumeQGroup(subvol string) (string, error) {
+	qgroup, _, err := btrfsSubVolumeQGroupGet(subvol)
+	if err != nil {
+		return "", err
 	}
 
 	return qgroup, nil
 }
 
 func (s *storageBtrfs) btrfsPoolVolumeQGroupUsage(subvol string) (int64, error) {
-	output, err := shared.RunCommand(
-		"btrfs",
-		"qgroup",
-		"show",
-		subvol,
-		"-e",
-		"-f")
-
+	_, usage, err := btrfsSubVolumeQGroupGet(subvol)
 	if err != nil {
-		return -1, fmt.Errorf("BTRFS quotas not supported. Try enabling them with \"btrfs quota enable\"")
-	}
-
-	for _, line := range strings.Split(output, "\n") {
-		if line == "" || strings.HasPrefix(line, "qgroupid") || strings.HasPrefix(line, "---") {
-			continue
-		}
-
-		fields := strings.Fields(line)
-		if len(fields) != 4 {
-			continue
-		}
-
-		usage, err := strconv.ParseInt(fields[2], 10, 64)
-		if err != nil {
-			continue
+		if err == db.ErrNoSuchObject {
+			return -1, fmt.Errorf("BTRFS quotas not supported. Try enabling them with \"btrfs quota enable\"")
 		}
 
-		return usage, nil
+		return -1, err
 	}
 
-	return -1, fmt.Errorf("Unable to find current qgroup usage")
+	return usage, nil
 }
 
 func btrfsSubVolumeDelete(subvol string) error {
@@ -2387,7 +2398,7 @@ func (s *btrfsMigrationSourceDriver) send(conn *websocket.Conn, btrfsPath string
 		return err
 	}
 
-	<-shared.WebsocketSendStream(conn, readPipe, 4*1024*1024)
+	streamErr := migrationSendStream(conn, readPipe)
 
 	output, err := ioutil.ReadAll(stderr)
 	if err != nil {
@@ -2397,9 +2408,10 @@ func (s *btrfsMigrationSourceDriver) send(conn *websocket.Conn, btrfsPath string
 	err = cmd.Wait()
 	if err != nil {
 		logger.Errorf("Problem with btrfs send: %s", string(output))
+		return err
 	}
 
-	return err
+	return streamErr
 }
 
 func (s *btrfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, containerOnly bool) error {
@@ -2600,7 +2612,7 @@ func (s *storageBtrfs) MigrationSink(live bool, container container, snapshots [
 			writePipe = writeWrapper(stdin)
 		}
 
-		<-shared.WebsocketRecvStream(writePipe, conn)
+		streamErr := migrationRecvStream(writePipe, conn)
 
 		output, err := ioutil.ReadAll(stderr)
 		if err != nil {
@@ -2613,6 +2625,10 @@ func (s *storageBtrfs) MigrationSink(live bool, container container, snapshots [
 			return err
 		}
 
+		if streamErr != nil {
+			return streamErr
+		}
+
 		receivedSnapshot := fmt.Sprintf("%s/.migration-send", btrfsPath)
 		// handle older lxd versions
 		if !shared.PathExists(receivedSnapshot) {
@@ -2792,11 +2808,7 @@ func (s *storageBtrfs) StorageEntitySetQuota(volumeType int, size int64, data in
 	}
 
 	_, err := btrfsSubVolumeQGroup(subvol)
-	if err != nil {
-		if err != db.ErrNoSuchObject {
-			return err
-		}
-
+	if err == db.ErrNoSuchObject {
 		// Enable quotas
 		poolMntPoint := getStoragePoolMountPoint(s.pool.Name)
 		output, err := shared.RunCommand(
@@ -2804,19 +2816,64 @@ func (s *storageBtrfs) StorageEntitySetQuota(volumeType int, size int64, data in
 		if err != nil && !s.s.OS.RunningInUserNS {
 			return fmt.Errorf("Failed to enable quotas on BTRFS pool: %s", output)
 		}
+
+		// Usage is only accurate once the existing data has been accounted for
+		if err == nil {
+			output, err = shared.RunCommand(
+				"btrfs", "quota", "rescan", "-w", poolMntPoint)
+			if err != nil {
+				return fmt.Errorf("Failed to rescan quotas on BTRFS pool: %s", output)
+			}
+		}
+
+		_, err = btrfsSubVolumeQGroup(subvol)
 	}
 
+	if err != nil && err != db.ErrNoSuchObject {
+		// Subvolumes created before quotas got enabled may lack their
+		// own quota group.
+		id, err := btrfsSubVolumeID(subvol)
+		if err != nil {
+			return err
+		}
+
+		output, err := shared.RunCommand(
+			"btrfs",
+			"qgroup",
+			"create",
+			fmt.Sprintf("0/%s", id),
+			subvol)
+		if err != nil {
+			return fmt.Errorf("Failed to create quota group: %s", output)
+		}
+	}
+
+	limit := "none"
+	if size > 0 {
+		limit = fmt.Sprintf("%d", size)
+	}
+
+	// Limit the data referenced by the subvolume, which unlike the
+	// exclusive data includes the blocks shared with its origin.
 	output, err := shared.RunCommand(
 		"btrfs",
 		"qgroup",
 		"limit",
-		"-e", fmt.Sprintf("%d", size),
+		limit,
 		subvol)
 
 	if err != nil {
 		return fmt.Errorf("Failed to set btrfs quota: %s", output)
 	}
 
+	// Drop any exclusive limit left behind by older versions of LXD
+	shared.RunCommand(
+		"btrfs",
+		"qgroup",
+		"limit",
+		"-e", "none",
+		subvol)
+
 	logger.Debugf(`Set BTRFS quota for "%s"`, s.volume.Name)
 	return nil
 }
@@ -2888,7 +2945,7 @@ func (s *storageBtrfs) StoragePoolVolumeCopy(source *api.StorageVolumeSource) er
 	}
 
 	bwlimit := s.pool.Config["rsync.bwlimit"]
-	_, err = rsyncLocalCopy(srcMountPoint, dstMountPoint, bwlimit)
+	_, err = rsyncLocalCopy(srcMountPoint, dstMountPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 	if err != nil {
 		s.StoragePoolVolumeDelete()
 		logger.Errorf("Failed to rsync into BTRFS storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
@@ -2921,6 +2978,10 @@ func (s *storageBtrfs) GetStoragePoolVolume() *api.StorageVolume {
 	return s.volume
 }
 
+func (s *storageBtrfs) StoragePoolVolumeBlockPath() (string, error) {
+	return "", fmt.Errorf("Block storage volumes aren't supported by the btrfs driver")
+}
+
 func (s *storageBtrfs) GetState() *state.State {
 	return s.s
 }
@@ -702,6 +702,7 @@ func (s *storageCeph) StoragePoolUpdate(writable *api.StoragePoolPut, changedCon
 	}
 
 	// "rsync.bwlimit" requires no on-disk modifications.
+	// "rsync.checksum" requires no on-disk modifications.
 	// "volume.block.filesystem" requires no on-disk modifications.
 	// "volume.block.mount_options" requires no on-disk modifications.
 	// "volume.size" requires no on-disk modifications.
@@ -1029,7 +1030,7 @@ func (s *storageCeph) doCrossPoolContainerCopy(target container, source containe
 	if !containerOnly {
 		for _, snap := range snapshots {
 			srcSnapshotMntPoint := getSnapshotMountPoint(sourcePool, snap.Name())
-			_, err = rsyncLocalCopy(srcSnapshotMntPoint, destContainerMntPoint, bwlimit)
+			_, err = rsyncLocalCopy(srcSnapshotMntPoint, destContainerMntPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 			if err != nil {
 				return err
 			}
@@ -1057,7 +1058,7 @@ func (s *storageCeph) doCrossPoolContainerCopy(target container, source containe
 	}
 
 	srcContainerMntPoint := getContainerMountPoint(sourcePool, source.Name())
-	_, err = rsyncLocalCopy(srcContainerMntPoint, destContainerMntPoint, bwlimit)
+	_, err = rsyncLocalCopy(srcContainerMntPoint, destContainerMntPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 	if err != nil {
 		s.StoragePoolVolumeDelete()
 		logger.Errorf("Failed to rsync into BTRFS storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
@@ -1870,21 +1871,82 @@ func (s *storageCeph) ContainerSnapshotCreateEmpty(c container) error {
 	return nil
 }
 
-func (s *storageCeph) ContainerBackupCreate(backup backup, source container) error {
-	logger.Debugf("Creating backup for container \"%s\" on storage pool \"%s\"", backup.Name(), s.pool.Name)
+// Backups in binary format hold the output of "rbd export-diff" for each
+// snapshot relative to the previous one, and for the container relative to
+// the last snapshot. This avoids mounting and copying the filesystems.
+func (s *storageCeph) doContainerBackupCreateOptimized(backup backup, source container) error {
+	baseMntPoint := getBackupMountPoint(s.pool.Name, backup.Name())
+	// create the path for the backup
+	err := os.MkdirAll(baseMntPoint, 0711)
+	if err != nil {
+		return err
+	}
 
-	// mount storage
-	ourStart, err := source.StorageStart()
+	sourceName := source.Name()
+	prevSnapshotName := ""
+	if !backup.containerOnly {
+		snapshots, err := source.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		targetBackupSnapshotsMntPoint := fmt.Sprintf("%s/snapshots", baseMntPoint)
+		if len(snapshots) > 0 {
+			err = os.MkdirAll(targetBackupSnapshotsMntPoint, 0711)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, snap := range snapshots {
+			_, snapOnlyName, _ := containerGetParentAndSnapshotName(snap.Name())
+			snapshotName := fmt.Sprintf("snapshot_%s", snapOnlyName)
+			target := fmt.Sprintf("%s/%s.bin", targetBackupSnapshotsMntPoint, snapOnlyName)
+			err = cephRBDVolumeExportDiff(s.ClusterName, s.OSDPoolName,
+				sourceName, storagePoolVolumeTypeNameContainer,
+				snapshotName, prevSnapshotName, target, s.UserName)
+			if err != nil {
+				logger.Errorf(`Failed to export RBD storage volume for snapshot "%s" on storage pool "%s": %s`, snap.Name(), s.pool.Name, err)
+				return err
+			}
+
+			prevSnapshotName = snapshotName
+		}
+	}
+
+	// This is costly but we need to ensure that all cached data has
+	// been committed to disk. If we don't then the rbd snapshot of
+	// the underlying filesystem can be inconsistent or - worst case
+	// - empty.
+	syscall.Sync()
+
+	// The container itself is exported from a temporary snapshot, which
+	// gets removed again when loading the backup.
+	snapshotName := fmt.Sprintf("zombie_snapshot_%s", uuid.NewRandom().String())
+	err = cephRBDSnapshotCreate(s.ClusterName, s.OSDPoolName, sourceName,
+		storagePoolVolumeTypeNameContainer, snapshotName, s.UserName)
 	if err != nil {
 		return err
 	}
-	if ourStart {
-		defer source.StorageStop()
+	defer cephRBDSnapshotDelete(s.ClusterName, s.OSDPoolName, sourceName,
+		storagePoolVolumeTypeNameContainer, snapshotName, s.UserName)
+
+	target := fmt.Sprintf("%s/container.bin", baseMntPoint)
+	err = cephRBDVolumeExportDiff(s.ClusterName, s.OSDPoolName, sourceName,
+		storagePoolVolumeTypeNameContainer, snapshotName, prevSnapshotName,
+		target, s.UserName)
+	if err != nil {
+		logger.Errorf(`Failed to export RBD storage volume for container "%s" on storage pool "%s": %s`, sourceName, s.pool.Name, err)
+		return err
 	}
 
+	return nil
+}
+
+func (s *storageCeph) doContainerBackupCreateVanilla(backup backup, source container) error {
 	baseMntPoint := getBackupMountPoint(s.pool.Name, backup.Name())
 	// create the path for the backup
-	err = os.MkdirAll(baseMntPoint, 0711)
+	err := os.MkdirAll(baseMntPoint, 0711)
 	if err != nil {
 		return err
 	}
@@ -1908,6 +1970,30 @@ func (s *storageCeph) ContainerBackupCreate(backup backup, source container) err
 		return err
 	}
 
+	return nil
+}
+
+func (s *storageCeph) ContainerBackupCreate(backup backup, source container) error {
+	logger.Debugf("Creating backup for container \"%s\" on storage pool \"%s\"", backup.Name(), s.pool.Name)
+
+	// mount storage
+	ourStart, err := source.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer source.StorageStop()
+	}
+
+	if backup.optimizedStorage {
+		err = s.doContainerBackupCreateOptimized(backup, source)
+	} else {
+		err = s.doContainerBackupCreateVanilla(backup, source)
+	}
+	if err != nil {
+		return err
+	}
+
 	logger.Debugf("Created backup for container \"%s\" on storage pool \"%s\"", backup.Name(), s.pool.Name)
 	return nil
 }
@@ -1974,13 +2060,119 @@ func (s *storageCeph) ContainerBackupDump(backup backup) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+// This function recreates an rbd container including its snapshots from a
+// backup in binary format:
+// - create an empty rbd storage volume
+// - for each snapshot apply its diff to the storage volume, which also
+//   creates the rbd snapshot
+// - apply the diff of the container and drop the temporary snapshot it came
+//   with.
+func (s *storageCeph) doContainerBackupLoadOptimized(info backupInfo, data io.ReadSeeker) error {
+	if info.Backend != "ceph" {
+		return fmt.Errorf("Backup in binary format was created on a \"%s\" storage pool", info.Backend)
+	}
+
+	containerName, _, _ := containerGetParentAndSnapshotName(info.Name)
+	containerMntPoint := getContainerMountPoint(s.pool.Name, containerName)
+	err := createContainerMountpoint(containerMntPoint, containerPath(info.Name, false), info.Privileged)
+	if err != nil {
+		return err
+	}
+
+	unpackPath := fmt.Sprintf("%s/.backup", containerMntPoint)
+	err = os.MkdirAll(unpackPath, 0700)
+	if err != nil {
+		return err
+	}
+	// The unpacked backup needs to be gone before mounting the container
+	defer os.RemoveAll(unpackPath)
+
+	// Extract container
+	data.Seek(0, 0)
+	err = shared.RunCommandWithFds(data, nil, "tar", "-xJf", "-", "--strip-components=1", "-C", unpackPath, "backup")
+	if err != nil {
+		logger.Errorf("Failed to untar \"%s\" into \"%s\": %s", info.Name, unpackPath, err)
+		return err
+	}
+
+	// The volume gets resized to the size of the backup by the first diff
+	err = cephRBDVolumeCreate(s.ClusterName, s.OSDPoolName, containerName,
+		storagePoolVolumeTypeNameContainer, "0", s.UserName)
+	if err != nil {
+		logger.Errorf(`Failed to create RBD storage volume for container "%s" on storage pool "%s": %s`, containerName, s.pool.Name, err)
+		return err
+	}
+
+	for _, snapshotOnlyName := range info.Snapshots {
+		snapshotBackup := fmt.Sprintf("%s/snapshots/%s.bin", unpackPath, snapshotOnlyName)
+		err = cephRBDVolumeImportDiff(s.ClusterName, s.OSDPoolName,
+			containerName, storagePoolVolumeTypeNameContainer,
+			snapshotBackup, s.UserName)
+		if err != nil {
+			logger.Errorf(`Failed to import RBD storage volume for snapshot "%s" of container "%s" on storage pool "%s": %s`, snapshotOnlyName, containerName, s.pool.Name, err)
+			return err
+		}
+
+		// create mountpoint
+		snapshotMntPoint := getSnapshotMountPoint(s.pool.Name, fmt.Sprintf("%s/%s", containerName, snapshotOnlyName))
+		snapshotMntPointSymlinkTarget := shared.VarPath("storage-pools", s.pool.Name, "snapshots", containerName)
+		snapshotMntPointSymlink := shared.VarPath("snapshots", containerName)
+		err = createSnapshotMountpoint(snapshotMntPoint, snapshotMntPointSymlinkTarget, snapshotMntPointSymlink)
+		if err != nil {
+			return err
+		}
+	}
+
+	containerBackup := fmt.Sprintf("%s/container.bin", unpackPath)
+	err = cephRBDVolumeImportDiff(s.ClusterName, s.OSDPoolName,
+		containerName, storagePoolVolumeTypeNameContainer,
+		containerBackup, s.UserName)
+	if err != nil {
+		logger.Errorf(`Failed to import RBD storage volume for container "%s" on storage pool "%s": %s`, containerName, s.pool.Name, err)
+		return err
+	}
+
+	// Remove the temporary snapshot the container was exported from
+	snapshots, err := cephRBDVolumeListSnapshots(s.ClusterName,
+		s.OSDPoolName, containerName,
+		storagePoolVolumeTypeNameContainer, s.UserName)
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots {
+		if !strings.HasPrefix(snapshot, "zombie_snapshot_") {
+			continue
+		}
+
+		err := cephRBDSnapshotDelete(s.ClusterName, s.OSDPoolName,
+			containerName, storagePoolVolumeTypeNameContainer,
+			snapshot, s.UserName)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.RemoveAll(unpackPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.doContainerMount(containerName)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // This function recreates an rbd container including its snapshots. It
 // recreates the dependencies between the container and the snapshots:
 // - create an empty rbd storage volume
 // - for each snapshot dump the contents into the empty storage volume and
 //   after each dump take a snapshot of the rbd storage volume
 // - dump the container contents into the rbd storage volume.
-func (s *storageCeph) ContainerBackupLoad(info backupInfo, data io.ReadSeeker) error {
+func (s *storageCeph) doContainerBackupLoadVanilla(info backupInfo, data io.ReadSeeker) error {
 	// create the main container
 	err := s.doContainerCreate(info.Name, info.Privileged)
 	if err != nil {
@@ -2039,6 +2231,16 @@ func (s *storageCeph) ContainerBackupLoad(info backupInfo, data io.ReadSeeker) e
 	return nil
 }
 
+func (s *storageCeph) ContainerBackupLoad(info backupInfo, data io.ReadSeeker) error {
+	logger.Debugf(`Loading RBD storage volume for backup "%s" on storage pool "%s"`, info.Name, s.pool.Name)
+
+	if info.HasBinaryFormat {
+		return s.doContainerBackupLoadOptimized(info, data)
+	}
+
+	return s.doContainerBackupLoadVanilla(info, data)
+}
+
 func (s *storageCeph) ImageCreate(fingerprint string) error {
 	logger.Debugf(`Creating RBD storage volume for image "%s" on storage pool "%s"`, fingerprint, s.pool.Name)
 
@@ -2645,7 +2847,7 @@ func (s *storageCeph) StoragePoolVolumeCopy(source *api.StorageVolumeSource) err
 	}
 
 	bwlimit := s.pool.Config["rsync.bwlimit"]
-	_, err = rsyncLocalCopy(srcMountPoint, dstMountPoint, bwlimit)
+	_, err = rsyncLocalCopy(srcMountPoint, dstMountPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 	if err != nil {
 		os.RemoveAll(dstMountPoint)
 		logger.Errorf("Failed to rsync into RBD storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
@@ -2678,6 +2880,10 @@ func (s *storageCeph) GetStoragePoolVolume() *api.StorageVolume {
 	return s.volume
 }
 
+func (s *storageCeph) StoragePoolVolumeBlockPath() (string, error) {
+	return "", fmt.Errorf("Block storage volumes aren't supported by the ceph driver")
+}
+
 func (s *storageCeph) GetState() *state.State {
 	return s.s
 }
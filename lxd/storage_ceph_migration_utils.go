@@ -7,7 +7,6 @@ import (
 
 	"github.com/gorilla/websocket"
 
-	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -65,7 +64,7 @@ func (s *rbdMigrationSourceDriver) rbdSend(conn *websocket.Conn,
 		return err
 	}
 
-	<-shared.WebsocketSendStream(conn, readPipe, 4*1024*1024)
+	streamErr := migrationSendStream(conn, readPipe)
 
 	output, err := ioutil.ReadAll(stderr)
 	if err != nil {
@@ -75,9 +74,10 @@ func (s *rbdMigrationSourceDriver) rbdSend(conn *websocket.Conn,
 	err = cmd.Wait()
 	if err != nil {
 		logger.Errorf(`Failed to perform "rbd export-diff": %s`, string(output))
+		return err
 	}
 
-	return err
+	return streamErr
 }
 
 func (s *storageCeph) rbdRecv(conn *websocket.Conn,
@@ -112,7 +112,7 @@ func (s *storageCeph) rbdRecv(conn *websocket.Conn,
 		writePipe = writeWrapper(stdin)
 	}
 
-	<-shared.WebsocketRecvStream(writePipe, conn)
+	streamErr := migrationRecvStream(writePipe, conn)
 
 	output, err := ioutil.ReadAll(stderr)
 	if err != nil {
@@ -122,7 +122,8 @@ func (s *storageCeph) rbdRecv(conn *websocket.Conn,
 	err = cmd.Wait()
 	if err != nil {
 		logger.Errorf(`Failed to perform "rbd import-diff": %s`, string(output))
+		return err
 	}
 
-	return err
+	return streamErr
 }
@@ -652,6 +652,56 @@ func cephRBDVolumeRestore(clusterName string, poolName string, volumeName string
 	return nil
 }
 
+// cephRBDVolumeExportDiff writes the changes of an RBD storage volume up to
+// the given snapshot into a file. If a parent snapshot is given only the
+// changes since that snapshot are written.
+func cephRBDVolumeExportDiff(clusterName string, poolName string,
+	volumeName string, volumeType string, snapshotName string,
+	parentSnapshotName string, file string, userName string) error {
+	args := []string{
+		"--id", userName,
+		"--cluster", clusterName,
+		"--pool", poolName,
+		"export-diff",
+	}
+
+	if parentSnapshotName != "" {
+		args = append(args, "--from-snap", parentSnapshotName)
+	}
+
+	args = append(args,
+		fmt.Sprintf("%s_%s@%s", volumeType, volumeName, snapshotName),
+		file)
+
+	_, err := shared.RunCommand("rbd", args...)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// cephRBDVolumeImportDiff applies a file written by cephRBDVolumeExportDiff to
+// an RBD storage volume. This also creates the snapshot the changes were
+// exported up to.
+func cephRBDVolumeImportDiff(clusterName string, poolName string,
+	volumeName string, volumeType string, file string,
+	userName string) error {
+	_, err := shared.RunCommand(
+		"rbd",
+		"--id", userName,
+		"--cluster", clusterName,
+		"--pool", poolName,
+		"import-diff",
+		file,
+		fmt.Sprintf("%s_%s", volumeType, volumeName))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // getRBDSize returns the size the RBD storage volume is supposed to be created
 // with
 func (s *storageCeph) getRBDSize() (string, error) {
@@ -1690,7 +1740,7 @@ func (s *storageCeph) cephRBDVolumeBackupCreate(backup backup, source container)
 	}
 
 	rsync := func(oldPath string, newPath string, bwlimit string) error {
-		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit)
+		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 		if err != nil {
 			s.ContainerBackupDelete(backup.Name())
 			return fmt.Errorf("Failed to rsync: %s: %s", string(output), err)
@@ -205,7 +205,10 @@ int prepare_loop_dev(const char *source, char *loop_dev, int flags)
 			goto on_error;
 	}
 
-	fd_img = open(source, O_RDWR | O_CLOEXEC);
+	if (flags & LO_FLAGS_READ_ONLY)
+		fd_img = open(source, O_RDONLY | O_CLOEXEC);
+	else
+		fd_img = open(source, O_RDWR | O_CLOEXEC);
 	if (fd_img < 0)
 		goto on_error;
 
@@ -282,6 +285,9 @@ import (
 // close.
 const LoFlagsAutoclear int = C.LO_FLAGS_AUTOCLEAR
 
+// LoFlagsReadOnly determines whether the loop device is read-only.
+const LoFlagsReadOnly int = C.LO_FLAGS_READ_ONLY
+
 // MS_LAZYTIME retains inode timestamps in memory and updated them on-disk only
 // under certain conditions.
 const MS_LAZYTIME uintptr = C.MS_LAZYTIME
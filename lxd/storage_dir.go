@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -21,6 +22,12 @@ import (
 
 type storageDir struct {
 	storageShared
+
+	// Drivers embedding the DIR driver provide their own way of mounting
+	// the pool, which the DIR code needs to go through as it only ever
+	// calls its own methods.
+	poolMount  func() (bool, error)
+	poolUmount func() (bool, error)
 }
 
 // Only initialize the minimal information we need about a given storage type.
@@ -167,6 +174,10 @@ func (s *storageDir) StoragePoolDelete() error {
 }
 
 func (s *storageDir) StoragePoolMount() (bool, error) {
+	if s.poolMount != nil {
+		return s.poolMount()
+	}
+
 	source := shared.HostPath(s.pool.Config["source"])
 	if source == "" {
 		return false, fmt.Errorf("no \"source\" property found for the storage pool")
@@ -223,6 +234,10 @@ func (s *storageDir) StoragePoolMount() (bool, error) {
 }
 
 func (s *storageDir) StoragePoolUmount() (bool, error) {
+	if s.poolUmount != nil {
+		return s.poolUmount()
+	}
+
 	source := s.pool.Config["source"]
 	if source == "" {
 		return false, fmt.Errorf("no \"source\" property found for the storage pool")
@@ -315,6 +330,7 @@ func (s *storageDir) StoragePoolUpdate(writable *api.StoragePoolPut, changedConf
 	}
 
 	// "rsync.bwlimit" requires no on-disk modifications.
+	// "rsync.checksum" requires no on-disk modifications.
 
 	logger.Infof(`Updated DIR storage pool "%s"`, s.pool.Name)
 	return nil
@@ -618,10 +634,9 @@ func (s *storageDir) copyContainer(target container, source container) error {
 		return err
 	}
 
-	bwlimit := s.pool.Config["rsync.bwlimit"]
-	output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
+	err = dirLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, s.pool.Config)
 	if err != nil {
-		return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
+		return fmt.Errorf("failed to copy container: %s", err)
 	}
 
 	err = s.setUnprivUserACL(source, targetContainerMntPoint)
@@ -652,10 +667,9 @@ func (s *storageDir) copySnapshot(target container, targetPool string, source co
 		return err
 	}
 
-	bwlimit := s.pool.Config["rsync.bwlimit"]
-	output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
+	err = dirLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, s.pool.Config)
 	if err != nil {
-		return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
+		return fmt.Errorf("failed to copy container: %s", err)
 	}
 
 	return nil
@@ -832,11 +846,9 @@ func (s *storageDir) ContainerRestore(container container, sourceContainer conta
 	targetPath := container.Path()
 	sourcePath := sourceContainer.Path()
 
-	// Restore using rsync
-	bwlimit := s.pool.Config["rsync.bwlimit"]
-	output, err := rsyncLocalCopy(sourcePath, targetPath, bwlimit)
+	err = dirLocalCopy(sourcePath, targetPath, s.pool.Config)
 	if err != nil {
-		return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
+		return fmt.Errorf("failed to copy container: %s", err)
 	}
 
 	// Now allow unprivileged users to access its data.
@@ -868,11 +880,11 @@ func (s *storageDir) ContainerSnapshotCreate(snapshotContainer container, source
 		return err
 	}
 
-	rsync := func(snapshotContainer container, oldPath string, newPath string, bwlimit string) error {
-		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit)
+	copyDir := func(snapshotContainer container, oldPath string, newPath string) error {
+		err := dirLocalCopy(oldPath, newPath, s.pool.Config)
 		if err != nil {
 			s.ContainerDelete(snapshotContainer)
-			return fmt.Errorf("failed to rsync: %s: %s", string(output), err)
+			return fmt.Errorf("failed to copy: %s", err)
 		}
 		return nil
 	}
@@ -888,8 +900,7 @@ func (s *storageDir) ContainerSnapshotCreate(snapshotContainer container, source
 	_, sourcePool, _ := sourceContainer.Storage().GetContainerPoolInfo()
 	sourceContainerName := sourceContainer.Name()
 	sourceContainerMntPoint := getContainerMountPoint(sourcePool, sourceContainerName)
-	bwlimit := s.pool.Config["rsync.bwlimit"]
-	err = rsync(snapshotContainer, sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
+	err = copyDir(snapshotContainer, sourceContainerMntPoint, targetContainerMntPoint)
 	if err != nil {
 		return err
 	}
@@ -897,16 +908,16 @@ func (s *storageDir) ContainerSnapshotCreate(snapshotContainer container, source
 	if sourceContainer.IsRunning() {
 		// This is done to ensure consistency when snapshotting. But we
 		// probably shouldn't fail just because of that.
-		logger.Debugf("Trying to freeze and rsync again to ensure consistency")
+		logger.Debugf("Trying to freeze and copy again to ensure consistency")
 
 		err := sourceContainer.Freeze()
 		if err != nil {
-			logger.Errorf("Trying to freeze and rsync again failed")
+			logger.Errorf("Trying to freeze and copy again failed")
 			goto onSuccess
 		}
 		defer sourceContainer.Unfreeze()
 
-		err = rsync(snapshotContainer, sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
+		err = copyDir(snapshotContainer, sourceContainerMntPoint, targetContainerMntPoint)
 		if err != nil {
 			return err
 		}
@@ -1084,11 +1095,11 @@ func (s *storageDir) ContainerBackupCreate(backup backup, sourceContainer contai
 		}
 	}
 
-	rsync := func(oldPath string, newPath string, bwlimit string) error {
-		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit)
+	copyDir := func(oldPath string, newPath string) error {
+		err := dirLocalCopy(oldPath, newPath, s.pool.Config)
 		if err != nil {
 			s.ContainerBackupDelete(backup.Name())
-			return fmt.Errorf("failed to rsync: %s: %s", string(output), err)
+			return fmt.Errorf("failed to copy: %s", err)
 		}
 		return nil
 	}
@@ -1104,8 +1115,7 @@ func (s *storageDir) ContainerBackupCreate(backup backup, sourceContainer contai
 	_, sourcePool, _ := sourceContainer.Storage().GetContainerPoolInfo()
 	sourceContainerMntPoint := getContainerMountPoint(sourcePool,
 		sourceContainer.Name())
-	bwlimit := s.pool.Config["rsync.bwlimit"]
-	err = rsync(sourceContainerMntPoint, targetBackupContainerMntPoint, bwlimit)
+	err = copyDir(sourceContainerMntPoint, targetBackupContainerMntPoint)
 	if err != nil {
 		return err
 	}
@@ -1113,15 +1123,15 @@ func (s *storageDir) ContainerBackupCreate(backup backup, sourceContainer contai
 	if sourceContainer.IsRunning() {
 		// This is done to ensure consistency when snapshotting. But we
 		// probably shouldn't fail just because of that.
-		logger.Debugf("Trying to freeze and rsync again to ensure consistency")
+		logger.Debugf("Trying to freeze and copy again to ensure consistency")
 
 		err := sourceContainer.Freeze()
 		if err != nil {
-			logger.Errorf("Trying to freeze and rsync again failed")
+			logger.Errorf("Trying to freeze and copy again failed")
 		}
 		defer sourceContainer.Unfreeze()
 
-		err = rsync(sourceContainerMntPoint, targetBackupContainerMntPoint, bwlimit)
+		err = copyDir(sourceContainerMntPoint, targetBackupContainerMntPoint)
 		if err != nil {
 			return err
 		}
@@ -1139,7 +1149,7 @@ func (s *storageDir) ContainerBackupCreate(backup backup, sourceContainer contai
 			_, snapName, _ := containerGetParentAndSnapshotName(ct.Name())
 			target := fmt.Sprintf("%s/%s", targetBackupSnapshotsMntPoint, snapName)
 
-			err = rsync(snapshotMntPoint, target, bwlimit)
+			err = copyDir(snapshotMntPoint, target)
 			if err != nil {
 				return err
 			}
@@ -1389,11 +1399,10 @@ func (s *storageDir) StoragePoolVolumeCopy(source *api.StorageVolumeSource) erro
 
 	srcMountPoint := getStoragePoolVolumeMountPoint(source.Pool, source.Name)
 	dstMountPoint := getStoragePoolVolumeMountPoint(s.pool.Name, s.volume.Name)
-	bwlimit := s.pool.Config["rsync.bwlimit"]
-	_, err = rsyncLocalCopy(srcMountPoint, dstMountPoint, bwlimit)
+	err = dirLocalCopy(srcMountPoint, dstMountPoint, s.pool.Config)
 	if err != nil {
 		os.RemoveAll(dstMountPoint)
-		logger.Errorf("Failed to rsync into DIR storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
+		logger.Errorf("Failed to copy into DIR storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
 		return err
 	}
 
@@ -1417,6 +1426,36 @@ func (s *storageDir) GetStoragePoolVolume() *api.StorageVolume {
 	return s.volume
 }
 
+func (s *storageDir) StoragePoolVolumeBlockPath() (string, error) {
+	return "", fmt.Errorf("Block storage volumes aren't supported by the dir driver")
+}
+
 func (s *storageDir) GetState() *state.State {
 	return s.s
 }
+
+// Copy a directory tree within the host, without going through rsync but
+// still honoring the rsync settings of the pool.
+func dirLocalCopy(source string, dest string, config map[string]string) error {
+	options := shared.DirSyncOptions{
+		Checksum: config["rsync.checksum"] == "" || shared.IsTrue(config["rsync.checksum"]),
+	}
+
+	bwlimit := config["rsync.bwlimit"]
+	if bwlimit != "" {
+		// As for rsync, plain numbers are in KiB per second
+		_, err := strconv.ParseInt(bwlimit, 10, 64)
+		if err == nil {
+			bwlimit = bwlimit + "kB"
+		}
+
+		limit, err := shared.ParseByteSizeString(bwlimit)
+		if err != nil {
+			return err
+		}
+
+		options.BandwidthLimit = limit
+	}
+
+	return shared.DirSync(source, dest, options)
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// External storage drivers are executables registered through the
+// "storage.external_drivers" server configuration key. They're only
+// responsible for providing a filesystem at the storage pool's mountpoint,
+// everything stored on top of it is handled like on DIR storage pools.
+//
+// Drivers are run as "<driver> <action>" with a storageExternalRequest as
+// JSON on their standard input, and must exit non-zero with an error message
+// on their standard error on failure. The actions are:
+// - validate: check the pool configuration (on creation)
+// - create: set up the backing storage of a new pool
+// - delete: tear down the backing storage of a pool
+// - mount: make the pool's filesystem available at the given path
+// - umount: remove the pool's filesystem from the given path
+// - update: apply changes to the pool configuration
+type storageExternal struct {
+	storageDir
+}
+
+type storageExternalRequest struct {
+	Name    string            `json:"name"`
+	Config  map[string]string `json:"config"`
+	Path    string            `json:"path"`
+	Changed []string          `json:"changed,omitempty"`
+}
+
+// Only initialize the minimal information we need about a given storage type.
+func (s *storageExternal) StorageCoreInit() error {
+	s.sType = storageTypeExternal
+	typeName, err := storageTypeToString(s.sType)
+	if err != nil {
+		return err
+	}
+	s.sTypeName = typeName
+	s.sTypeVersion = "1"
+
+	// Have the DIR code mount the pool through the driver
+	s.storageDir.poolMount = s.StoragePoolMount
+	s.storageDir.poolUmount = s.StoragePoolUmount
+
+	logger.Debugf("Initializing an external driver")
+	return nil
+}
+
+// Initialize a full storage interface.
+func (s *storageExternal) StoragePoolInit() error {
+	err := s.StorageCoreInit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Return the path of the executable of the pool's driver.
+func (s *storageExternal) driverPath() (string, error) {
+	name := s.pool.Config["external.driver"]
+
+	var drivers map[string]string
+	err := s.s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		drivers = config.StorageExternalDrivers()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	path, ok := drivers[name]
+	if !ok {
+		return "", fmt.Errorf("External storage driver \"%s\" isn't registered", name)
+	}
+
+	return path, nil
+}
+
+func (s *storageExternal) driverRun(action string, config map[string]string, changed []string) error {
+	path, err := s.driverPath()
+	if err != nil {
+		return err
+	}
+
+	req := storageExternalRequest{
+		Name:    s.pool.Name,
+		Config:  config,
+		Path:    getStoragePoolMountPoint(s.pool.Name),
+		Changed: changed,
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	return shared.RunCommandWithFds(bytes.NewReader(data), nil, path, action)
+}
+
+func (s *storageExternal) StoragePoolCheck() error {
+	logger.Debugf("Checking external storage pool \"%s\"", s.pool.Name)
+
+	_, err := s.driverPath()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *storageExternal) StoragePoolCreate() error {
+	logger.Infof("Creating external storage pool \"%s\"", s.pool.Name)
+
+	// LXD sees the pool as a directory, so that the DIR storage code can
+	// deal with everything inside it.
+	poolMntPoint := getStoragePoolMountPoint(s.pool.Name)
+	s.pool.Config["source"] = poolMntPoint
+
+	err := s.driverRun("validate", s.pool.Config, nil)
+	if err != nil {
+		return err
+	}
+
+	revert := true
+	if !shared.PathExists(poolMntPoint) {
+		err := os.MkdirAll(poolMntPoint, 0711)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if !revert {
+				return
+			}
+			os.Remove(poolMntPoint)
+		}()
+	}
+
+	err = s.driverRun("create", s.pool.Config, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !revert {
+			return
+		}
+		s.driverRun("delete", s.pool.Config, nil)
+	}()
+
+	_, err = s.StoragePoolMount()
+	if err != nil {
+		return err
+	}
+
+	revert = false
+
+	logger.Infof("Created external storage pool \"%s\"", s.pool.Name)
+	return nil
+}
+
+func (s *storageExternal) StoragePoolDelete() error {
+	logger.Infof("Deleting external storage pool \"%s\"", s.pool.Name)
+
+	_, err := s.StoragePoolUmount()
+	if err != nil {
+		return err
+	}
+
+	err = s.driverRun("delete", s.pool.Config, nil)
+	if err != nil {
+		return err
+	}
+
+	poolMntPoint := getStoragePoolMountPoint(s.pool.Name)
+	if shared.IsMountPoint(poolMntPoint) {
+		return fmt.Errorf("The storage pool is still mounted on \"%s\"", poolMntPoint)
+	}
+
+	if shared.PathExists(poolMntPoint) {
+		err := os.RemoveAll(poolMntPoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Infof("Deleted external storage pool \"%s\"", s.pool.Name)
+	return nil
+}
+
+func (s *storageExternal) StoragePoolMount() (bool, error) {
+	poolMntPoint := getStoragePoolMountPoint(s.pool.Name)
+
+	poolMountLockID := getPoolMountLockID(s.pool.Name)
+	lxdStorageMapLock.Lock()
+	if waitChannel, ok := lxdStorageOngoingOperationMap[poolMountLockID]; ok {
+		lxdStorageMapLock.Unlock()
+		if _, ok := <-waitChannel; ok {
+			logger.Warnf("Received value over semaphore, this should not have happened")
+		}
+		// Give the benefit of the doubt and assume that the other
+		// thread actually succeeded in mounting the storage pool.
+		return false, nil
+	}
+
+	lxdStorageOngoingOperationMap[poolMountLockID] = make(chan bool)
+	lxdStorageMapLock.Unlock()
+
+	removeLockFromMap := func() {
+		lxdStorageMapLock.Lock()
+		if waitChannel, ok := lxdStorageOngoingOperationMap[poolMountLockID]; ok {
+			close(waitChannel)
+			delete(lxdStorageOngoingOperationMap, poolMountLockID)
+		}
+		lxdStorageMapLock.Unlock()
+	}
+	defer removeLockFromMap()
+
+	if shared.IsMountPoint(poolMntPoint) {
+		return false, nil
+	}
+
+	logger.Debugf("Mounting external storage pool \"%s\"", s.pool.Name)
+
+	err := s.driverRun("mount", s.pool.Config, nil)
+	if err != nil {
+		logger.Errorf(`Failed to mount external storage pool "%s" onto "%s": %s`, s.pool.Name, poolMntPoint, err)
+		return false, err
+	}
+
+	logger.Debugf("Mounted external storage pool \"%s\"", s.pool.Name)
+	return true, nil
+}
+
+func (s *storageExternal) StoragePoolUmount() (bool, error) {
+	poolMntPoint := getStoragePoolMountPoint(s.pool.Name)
+
+	poolUmountLockID := getPoolUmountLockID(s.pool.Name)
+	lxdStorageMapLock.Lock()
+	if waitChannel, ok := lxdStorageOngoingOperationMap[poolUmountLockID]; ok {
+		lxdStorageMapLock.Unlock()
+		if _, ok := <-waitChannel; ok {
+			logger.Warnf("Received value over semaphore, this should not have happened")
+		}
+		// Give the benefit of the doubt and assume that the other
+		// thread actually succeeded in unmounting the storage pool.
+		return false, nil
+	}
+
+	lxdStorageOngoingOperationMap[poolUmountLockID] = make(chan bool)
+	lxdStorageMapLock.Unlock()
+
+	removeLockFromMap := func() {
+		lxdStorageMapLock.Lock()
+		if waitChannel, ok := lxdStorageOngoingOperationMap[poolUmountLockID]; ok {
+			close(waitChannel)
+			delete(lxdStorageOngoingOperationMap, poolUmountLockID)
+		}
+		lxdStorageMapLock.Unlock()
+	}
+	defer removeLockFromMap()
+
+	if !shared.IsMountPoint(poolMntPoint) {
+		return false, nil
+	}
+
+	logger.Debugf("Unmounting external storage pool \"%s\"", s.pool.Name)
+
+	err := s.driverRun("umount", s.pool.Config, nil)
+	if err != nil {
+		return false, err
+	}
+
+	logger.Debugf("Unmounted external storage pool \"%s\"", s.pool.Name)
+	return true, nil
+}
+
+func (s *storageExternal) StoragePoolUpdate(writable *api.StoragePoolPut, changedConfig []string) error {
+	logger.Infof(`Updating external storage pool "%s"`, s.pool.Name)
+
+	// Driver specific keys are validated by the driver itself
+	changeable := changeableStoragePoolProperties["external"]
+	unchangeable := []string{}
+	driverChanges := []string{}
+	for _, change := range changedConfig {
+		if strings.HasPrefix(change, "external.") && change != "external.driver" {
+			driverChanges = append(driverChanges, change)
+			continue
+		}
+
+		if !shared.StringInSlice(change, changeable) {
+			unchangeable = append(unchangeable, change)
+		}
+	}
+
+	if len(unchangeable) > 0 {
+		return updateStoragePoolError(unchangeable, "external")
+	}
+
+	if len(driverChanges) > 0 {
+		err := s.driverRun("update", writable.Config, driverChanges)
+		if err != nil {
+			return err
+		}
+	}
+
+	// "rsync.bwlimit" requires no on-disk modifications.
+	// "rsync.checksum" requires no on-disk modifications.
+
+	logger.Infof(`Updated external storage pool "%s"`, s.pool.Name)
+	return nil
+}
+
+func (s *storageExternal) StoragePoolResources() (*api.ResourcesStoragePool, error) {
+	_, err := s.StoragePoolMount()
+	if err != nil {
+		return nil, err
+	}
+
+	poolMntPoint := getStoragePoolMountPoint(s.pool.Name)
+
+	return storageResource(poolMntPoint)
+}
+
+func (s *storageExternal) StoragePoolVolumeMount() (bool, error) {
+	return s.StoragePoolMount()
+}
+
+func (s *storageExternal) StoragePoolVolumeUpdate(writable *api.StorageVolumePut, changedConfig []string) error {
+	logger.Infof(`Updating external storage volume "%s"`, s.pool.Name)
+
+	changeable := changeableStoragePoolVolumeProperties["external"]
+	unchangeable := []string{}
+	for _, change := range changedConfig {
+		if !shared.StringInSlice(change, changeable) {
+			unchangeable = append(unchangeable, change)
+		}
+	}
+
+	if len(unchangeable) > 0 {
+		return updateStoragePoolVolumeError(unchangeable, "external")
+	}
+
+	logger.Infof(`Updated external storage volume "%s"`, s.pool.Name)
+	return nil
+}
+
+func (s *storageExternal) ContainerMount(c container) (bool, error) {
+	return s.StoragePoolMount()
+}
+
+func (s *storageExternal) ContainerSnapshotStart(c container) (bool, error) {
+	return s.StoragePoolMount()
+}
+
+func (s *storageExternal) StoragePoolVolumeBlockPath() (string, error) {
+	return "", fmt.Errorf("Block storage volumes aren't supported by the external driver")
+}
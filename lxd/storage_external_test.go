@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The DIR code embedded in the external driver mounts and unmounts the pool
+// through the driver rather than treating it as a plain directory.
+func TestStorageExternal_PoolMount(t *testing.T) {
+	s := &storageExternal{}
+	assert.NoError(t, s.StorageCoreInit())
+	assert.NotNil(t, s.storageDir.poolMount)
+	assert.NotNil(t, s.storageDir.poolUmount)
+
+	calls := []string{}
+	s.storageDir.poolMount = func() (bool, error) {
+		calls = append(calls, "mount")
+		return true, nil
+	}
+	s.storageDir.poolUmount = func() (bool, error) {
+		calls = append(calls, "umount")
+		return true, nil
+	}
+
+	ours, err := s.storageDir.StoragePoolMount()
+	assert.NoError(t, err)
+	assert.True(t, ours)
+
+	ours, err = s.storageDir.StoragePoolUmount()
+	assert.NoError(t, err)
+	assert.True(t, ours)
+
+	assert.Equal(t, []string{"mount", "umount"}, calls)
+}
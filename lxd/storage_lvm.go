@@ -478,6 +478,12 @@ func (s *storageLvm) StoragePoolVolumeCreate() error {
 		return err
 	}
 
+	// Block volumes are handed out as a raw LV
+	isBlock := storagePoolVolumeIsBlock(s.volume.Config)
+	if isBlock {
+		lvFsType = ""
+	}
+
 	if s.useThinpool {
 		err = lvmCreateThinpool(s.s, s.sTypeVersion, poolName, thinPoolName, lvFsType)
 		if err != nil {
@@ -495,14 +501,16 @@ func (s *storageLvm) StoragePoolVolumeCreate() error {
 		}
 	}()
 
-	customPoolVolumeMntPoint := getStoragePoolVolumeMountPoint(s.pool.Name, s.volume.Name)
-	err = os.MkdirAll(customPoolVolumeMntPoint, 0711)
-	if err != nil {
-		return err
+	if !isBlock {
+		customPoolVolumeMntPoint := getStoragePoolVolumeMountPoint(s.pool.Name, s.volume.Name)
+		err = os.MkdirAll(customPoolVolumeMntPoint, 0711)
+		if err != nil {
+			return err
+		}
 	}
 
 	// apply quota
-	if s.volume.Config["size"] != "" {
+	if s.volume.Config["size"] != "" && !isBlock {
 		size, err := shared.ParseByteSizeString(s.volume.Config["size"])
 		if err != nil {
 			return err
@@ -570,6 +578,11 @@ func (s *storageLvm) StoragePoolVolumeDelete() error {
 func (s *storageLvm) StoragePoolVolumeMount() (bool, error) {
 	logger.Debugf("Mounting LVM storage volume \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
 
+	// Block volumes are never mounted
+	if storagePoolVolumeIsBlock(s.volume.Config) {
+		return false, nil
+	}
+
 	customPoolVolumeMntPoint := getStoragePoolVolumeMountPoint(s.pool.Name, s.volume.Name)
 	poolName := s.getOnDiskPoolName()
 	lvFsType := s.getLvmFilesystem()
@@ -620,6 +633,10 @@ func (s *storageLvm) StoragePoolVolumeMount() (bool, error) {
 func (s *storageLvm) StoragePoolVolumeUmount() (bool, error) {
 	logger.Debugf("Unmounting LVM storage volume \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
 
+	if storagePoolVolumeIsBlock(s.volume.Config) {
+		return false, nil
+	}
+
 	customPoolVolumeMntPoint := getStoragePoolVolumeMountPoint(s.pool.Name, s.volume.Name)
 
 	customUmountLockID := getCustomUmountLockID(s.pool.Name, s.volume.Name)
@@ -698,6 +715,9 @@ func (s *storageLvm) StoragePoolUpdate(writable *api.StoragePoolPut, changedConf
 	// "volume.block.filesystem" requires no on-disk modifications.
 	// "volume.size" requires no on-disk modifications.
 	// "rsync.bwlimit" requires no on-disk modifications.
+	// "rsync.checksum" requires no on-disk modifications.
+	// "lvm.thinpool_*_threshold" and "lvm.thinpool_autoextend_percent"
+	// require no on-disk modifications.
 
 	revert := true
 
@@ -1485,7 +1505,7 @@ func (s *storageLvm) ContainerRestore(target container, source container) error
 		defer target.Unfreeze()
 
 		bwlimit := s.pool.Config["rsync.bwlimit"]
-		output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
+		output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 		if err != nil {
 			return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
 		}
@@ -1682,7 +1702,7 @@ func (s *storageLvm) ContainerBackupCreate(backup backup, sourceContainer contai
 	}
 
 	rsync := func(oldPath string, newPath string, bwlimit string) error {
-		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit)
+		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 		if err != nil {
 			s.ContainerBackupDelete(backup.Name())
 			return fmt.Errorf("failed to rsync: %s: %s", string(output), err)
@@ -2164,6 +2184,11 @@ func (s *storageLvm) StorageEntitySetQuota(volumeType int, size int64, data inte
 	default:
 		lvDevPath = getLvmDevPath(poolName, storagePoolVolumeAPIEndpointCustom, s.volume.Name)
 		mountpoint = getStoragePoolVolumeMountPoint(s.pool.Name, s.volume.Name)
+
+		// There's no filesystem to resize on block volumes
+		if storagePoolVolumeIsBlock(s.volume.Config) {
+			fsType = ""
+		}
 	}
 
 	oldSize, err := shared.ParseByteSizeString(s.volume.Config["size"])
@@ -2313,7 +2338,7 @@ func (s *storageLvm) StoragePoolVolumeCopy(source *api.StorageVolumeSource) erro
 	}
 
 	bwlimit := s.pool.Config["rsync.bwlimit"]
-	_, err = rsyncLocalCopy(srcMountPoint, dstMountPoint, bwlimit)
+	_, err = rsyncLocalCopy(srcMountPoint, dstMountPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 	if err != nil {
 		os.RemoveAll(dstMountPoint)
 		logger.Errorf("Failed to rsync into LVM storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
@@ -2340,6 +2365,14 @@ func (s *storageLvm) GetStoragePoolVolume() *api.StorageVolume {
 	return s.volume
 }
 
+func (s *storageLvm) StoragePoolVolumeBlockPath() (string, error) {
+	if !storagePoolVolumeIsBlock(s.volume.Config) {
+		return "", fmt.Errorf("Storage volume \"%s\" isn't a block volume", s.volume.Name)
+	}
+
+	return getLvmDevPath(s.getOnDiskPoolName(), storagePoolVolumeAPIEndpointCustom, s.volume.Name), nil
+}
+
 func (s *storageLvm) GetState() *state.State {
 	return s.s
 }
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Thin pools which are currently above their warning threshold, so that the
+// warning is only logged once until usage goes back down.
+var lvmThinPoolsWarnedLock sync.Mutex
+var lvmThinPoolsWarned = map[string]bool{}
+
+// Thin pools whose last extension failed, typically because the volume group
+// ran out of free space, along with when to try again. The delay doubles on
+// every failure, so that a failing lvextend isn't run every minute.
+var lvmThinPoolsExtendBackoffLock sync.Mutex
+var lvmThinPoolsExtendBackoff = map[string]lvmThinPoolBackoff{}
+
+type lvmThinPoolBackoff struct {
+	next  time.Time
+	delay time.Duration
+}
+
+const lvmThinPoolExtendDelayMin = time.Minute
+const lvmThinPoolExtendDelayMax = time.Hour
+
+// Return true if the given thin pool may be extended at the given time.
+func lvmThinPoolExtendAllowed(name string, now time.Time) bool {
+	lvmThinPoolsExtendBackoffLock.Lock()
+	defer lvmThinPoolsExtendBackoffLock.Unlock()
+
+	backoff, ok := lvmThinPoolsExtendBackoff[name]
+	return !ok || !now.Before(backoff.next)
+}
+
+// Record a failed extension of the given thin pool, returning how long to
+// wait before trying again.
+func lvmThinPoolExtendFailed(name string, now time.Time) time.Duration {
+	lvmThinPoolsExtendBackoffLock.Lock()
+	defer lvmThinPoolsExtendBackoffLock.Unlock()
+
+	delay := lvmThinPoolExtendDelayMin
+	backoff, ok := lvmThinPoolsExtendBackoff[name]
+	if ok {
+		delay = backoff.delay * 2
+		if delay > lvmThinPoolExtendDelayMax {
+			delay = lvmThinPoolExtendDelayMax
+		}
+	}
+
+	lvmThinPoolsExtendBackoff[name] = lvmThinPoolBackoff{next: now.Add(delay), delay: delay}
+
+	return delay
+}
+
+// Forget about past failed extensions of the given thin pool.
+func lvmThinPoolExtendSucceeded(name string) {
+	lvmThinPoolsExtendBackoffLock.Lock()
+	defer lvmThinPoolsExtendBackoffLock.Unlock()
+
+	delete(lvmThinPoolsExtendBackoff, name)
+}
+
+func storagePoolsLvmMonitorTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		storagePoolsLvmMonitor(d.State())
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// Check the data and metadata usage of the thin pools backing LVM storage
+// pools, warning about the ones filling up and extending them if configured
+// to, since running out of space corrupts every volume on the thin pool.
+func storagePoolsLvmMonitor(s *state.State) {
+	names, err := s.Cluster.StoragePools()
+	if err != nil {
+		if err != db.ErrNoSuchObject {
+			logger.Error("Failed to get storage pools", log.Ctx{"err": err})
+		}
+		return
+	}
+
+	for _, name := range names {
+		_, pool, err := s.Cluster.StoragePoolGet(name)
+		if err != nil || pool.Driver != "lvm" {
+			continue
+		}
+
+		if storagePoolCheckAvailable(name) != nil {
+			continue
+		}
+
+		st, err := storageLoad(s, name, "", -1)
+		if err != nil {
+			continue
+		}
+
+		lvm, ok := st.(*storageLvm)
+		if !ok || !lvm.usesThinpool() {
+			continue
+		}
+
+		err = lvm.thinPoolCheck()
+		if err != nil {
+			logger.Error("Failed to check LVM thin pool", log.Ctx{"pool": name, "err": err})
+		}
+	}
+}
+
+func (s *storageLvm) thinPoolCheck() error {
+	vgName := s.getOnDiskPoolName()
+	thinPoolName := s.getLvmThinpoolName()
+
+	data, metadata, err := lvmThinPoolUsage(vgName, thinPoolName)
+	if err != nil {
+		return err
+	}
+
+	// Extend the thin pool before warning about it
+	threshold := s.pool.Config["lvm.thinpool_autoextend_threshold"]
+	if threshold != "" && threshold != "0" {
+		limit, _ := strconv.ParseFloat(threshold, 64)
+
+		percent := 20
+		if s.pool.Config["lvm.thinpool_autoextend_percent"] != "" {
+			percent, _ = strconv.Atoi(s.pool.Config["lvm.thinpool_autoextend_percent"])
+		}
+
+		if (data >= limit || metadata >= limit) && lvmThinPoolExtendAllowed(s.pool.Name, time.Now()) {
+			err := s.thinPoolExtend(vgName, thinPoolName, percent, data >= limit, metadata >= limit)
+			if err != nil {
+				delay := lvmThinPoolExtendFailed(s.pool.Name, time.Now())
+				logger.Error("Failed to extend LVM thin pool", log.Ctx{"pool": s.pool.Name, "err": err, "retry": delay})
+				warningEmit(s.s, "lvm-thinpool-extend", s.pool.Name, err.Error())
+			} else {
+				lvmThinPoolExtendSucceeded(s.pool.Name)
+				warningResolve(s.s, "lvm-thinpool-extend", s.pool.Name)
+
+				data, metadata, err = lvmThinPoolUsage(vgName, thinPoolName)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	warn := float64(80)
+	if s.pool.Config["lvm.thinpool_warn_threshold"] != "" {
+		warn, _ = strconv.ParseFloat(s.pool.Config["lvm.thinpool_warn_threshold"], 64)
+	}
+
+	lvmThinPoolsWarnedLock.Lock()
+	defer lvmThinPoolsWarnedLock.Unlock()
+
+	if warn > 0 && (data >= warn || metadata >= warn) {
+		if !lvmThinPoolsWarned[s.pool.Name] {
+			logger.Warn("LVM thin pool is running out of space", log.Ctx{"pool": s.pool.Name, "data": data, "metadata": metadata})
+			warningEmit(s.s, "lvm-thinpool-usage", s.pool.Name,
+				fmt.Sprintf("Thin pool usage is %.1f%% of data and %.1f%% of metadata", data, metadata))
+			lvmThinPoolsWarned[s.pool.Name] = true
+		}
+
+		return nil
+	}
+
+	if lvmThinPoolsWarned[s.pool.Name] {
+		logger.Info("LVM thin pool usage is back below the warning threshold", log.Ctx{"pool": s.pool.Name, "data": data, "metadata": metadata})
+		warningResolve(s.s, "lvm-thinpool-usage", s.pool.Name)
+		delete(lvmThinPoolsWarned, s.pool.Name)
+	}
+
+	return nil
+}
+
+// Extend the data and/or metadata of the thin pool by the given percentage.
+func (s *storageLvm) thinPoolExtend(vgName string, thinPoolName string, percent int, data bool, metadata bool) error {
+	if data {
+		err := lvmThinPoolExtend(vgName, thinPoolName, percent, false)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Extended LVM thin pool data", log.Ctx{"pool": s.pool.Name, "percent": percent})
+	}
+
+	if metadata {
+		err := lvmThinPoolExtend(vgName, thinPoolName, percent, true)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Extended LVM thin pool metadata", log.Ctx{"pool": s.pool.Name, "percent": percent})
+	}
+
+	return nil
+}
+
+// lvmThinPoolUsage returns the data and metadata usage of the given thin
+// pool, in percent.
+func lvmThinPoolUsage(vgName string, thinPoolName string) (float64, float64, error) {
+	output, err := shared.TryRunCommand(
+		"lvs",
+		"--noheadings",
+		"-o", "data_percent,metadata_percent",
+		fmt.Sprintf("%s/%s", vgName, thinPoolName))
+	if err != nil {
+		return -1, -1, fmt.Errorf("Failed to get usage of thin pool \"%s\": %s", thinPoolName, output)
+	}
+
+	fields := strings.Fields(strings.Replace(output, ",", ".", -1))
+	if len(fields) != 2 {
+		return -1, -1, fmt.Errorf("Unexpected usage of thin pool \"%s\": %s", thinPoolName, output)
+	}
+
+	data, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	metadata, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	return data, metadata, nil
+}
+
+// lvmThinPoolExtend grows the data or metadata of the given thin pool by the
+// given percentage of its current size.
+func lvmThinPoolExtend(vgName string, thinPoolName string, percent int, metadata bool) error {
+	lvPath := fmt.Sprintf("%s/%s", vgName, thinPoolName)
+
+	args := []string{"-l", fmt.Sprintf("+%d%%LV", percent), lvPath}
+	if metadata {
+		output, err := shared.TryRunCommand(
+			"lvs",
+			"--noheadings",
+			"--units", "b",
+			"--nosuffix",
+			"-o", "lv_metadata_size",
+			lvPath)
+		if err != nil {
+			return fmt.Errorf("Failed to get metadata size of thin pool \"%s\": %s", thinPoolName, output)
+		}
+
+		size, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		args = []string{"--poolmetadatasize", fmt.Sprintf("+%db", size*int64(percent)/100), lvPath}
+	}
+
+	output, err := shared.TryRunCommand("lvextend", args...)
+	if err != nil {
+		return fmt.Errorf("Failed to extend thin pool \"%s\": %s", thinPoolName, output)
+	}
+
+	return nil
+}
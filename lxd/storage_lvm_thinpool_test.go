@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A failing extension is retried after a delay doubling on each failure, up
+// to the maximum, and any success resets it.
+func TestLvmThinPoolExtendBackoff(t *testing.T) {
+	defer lvmThinPoolExtendSucceeded("pool1")
+
+	now := time.Now()
+	assert.True(t, lvmThinPoolExtendAllowed("pool1", now))
+
+	assert.Equal(t, time.Minute, lvmThinPoolExtendFailed("pool1", now))
+	assert.False(t, lvmThinPoolExtendAllowed("pool1", now.Add(30*time.Second)))
+	assert.True(t, lvmThinPoolExtendAllowed("pool1", now.Add(time.Minute)))
+	assert.True(t, lvmThinPoolExtendAllowed("pool2", now))
+
+	assert.Equal(t, 2*time.Minute, lvmThinPoolExtendFailed("pool1", now))
+	assert.Equal(t, 4*time.Minute, lvmThinPoolExtendFailed("pool1", now))
+	for i := 0; i < 10; i++ {
+		lvmThinPoolExtendFailed("pool1", now)
+	}
+	assert.Equal(t, time.Hour, lvmThinPoolExtendFailed("pool1", now))
+	assert.False(t, lvmThinPoolExtendAllowed("pool1", now.Add(59*time.Minute)))
+
+	lvmThinPoolExtendSucceeded("pool1")
+	assert.True(t, lvmThinPoolExtendAllowed("pool1", now))
+	assert.Equal(t, time.Minute, lvmThinPoolExtendFailed("pool1", now))
+}
@@ -34,6 +34,11 @@ func (s *storageLvm) lvExtend(lvPath string, lvSize int64, fsType string, fsMntP
 		return fmt.Errorf("could not extend LV \"%s\": %s", lvPath, msg)
 	}
 
+	// Raw block volume, nothing to grow
+	if fsType == "" {
+		return nil
+	}
+
 	switch volumeType {
 	case storagePoolVolumeTypeContainer:
 		c := data.(container)
@@ -70,6 +75,11 @@ func (s *storageLvm) lvReduce(lvPath string, lvSize int64, fsType string, fsMntP
 		return fmt.Errorf(`The size of the storage volume would be ` +
 			`less than 1MB`)
 	}
+	// Shrinking a raw block volume would truncate whatever it holds
+	if fsType == "" {
+		return fmt.Errorf("Block storage volumes can't be shrunk")
+	}
+
 	cleanupFunc, err := shrinkVolumeFilesystem(s, volumeType, fsType, lvPath, fsMntPoint, lvSize, data)
 	if cleanupFunc != nil {
 		defer cleanupFunc()
@@ -428,7 +438,7 @@ func (s *storageLvm) copyContainerLv(target container, source container, readonl
 	}
 
 	bwlimit := s.pool.Config["rsync.bwlimit"]
-	output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
+	output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 	if err != nil {
 		return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
 	}
@@ -848,6 +858,11 @@ func lvmCreateLv(vgName string, thinPoolName string, lvName string, lvFsType str
 		return fmt.Errorf("Could not create thin LV named %s", lvmPoolVolumeName)
 	}
 
+	// Raw block volume, leave it to the container to format it
+	if lvFsType == "" {
+		return nil
+	}
+
 	fsPath := getLvmDevPath(vgName, volumeType, lvName)
 
 	output, err = makeFSType(fsPath, lvFsType, nil)
@@ -212,7 +212,9 @@ func rsyncMigrationSink(live bool, container container, snapshots []*migration.S
 		return fmt.Errorf("the container's root device is missing the pool property")
 	}
 
-	isDirBackend := container.Storage().GetStorageType() == storageTypeDir
+	// External storage pools store containers the same way as DIR ones.
+	sType := container.Storage().GetStorageType()
+	isDirBackend := sType == storageTypeDir || sType == storageTypeExternal
 	if isDirBackend {
 		if !containerOnly {
 			for _, snap := range snapshots {
@@ -271,6 +271,10 @@ func (s *storageMock) GetStoragePoolVolume() *api.StorageVolume {
 	return nil
 }
 
+func (s *storageMock) StoragePoolVolumeBlockPath() (string, error) {
+	return "", nil
+}
+
 func (s *storageMock) GetState() *state.State {
 	return nil
 }
@@ -48,6 +48,8 @@ func storagePoolsGet(d *Daemon, r *http.Request) Response {
 				return SmartError(err)
 			}
 			pl.UsedBy = poolUsedBy
+			pl.Availability, pl.AvailabilityReason = storagePoolAvailability(pool)
+			pl.State, pl.StateReason = storagePoolState(pool)
 
 			resultMap = append(resultMap, *pl)
 		}
@@ -274,6 +276,8 @@ func storagePoolGet(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 	pool.UsedBy = poolUsedBy
+	pool.Availability, pool.AvailabilityReason = storagePoolAvailability(poolName)
+	pool.State, pool.StateReason = storagePoolState(poolName)
 
 	targetNode := r.FormValue("target")
 
@@ -288,6 +292,18 @@ func storagePoolGet(d *Daemon, r *http.Request) Response {
 		for _, key := range db.StoragePoolNodeConfigKeys {
 			delete(pool.Config, key)
 		}
+
+		// Report the state of the pool across the cluster, unless
+		// another node is asking for ours.
+		if !isClusterNotification(r) {
+			local := api.StoragePoolState{State: pool.State, Reason: pool.StateReason}
+			pool.NodesState, err = storagePoolNodesState(d, poolName, local)
+			if err != nil {
+				return SmartError(err)
+			}
+
+			pool.State, pool.StateReason = storagePoolStateWorst(pool.NodesState)
+		}
 	}
 
 	etag := []interface{}{pool.Name, pool.Driver, pool.Config}
@@ -298,6 +314,12 @@ func storagePoolGet(d *Daemon, r *http.Request) Response {
 // /1.0/storage-pools/{name}
 // Replace pool properties.
 func storagePoolPut(d *Daemon, r *http.Request) Response {
+	// If a target was specified, forward the request to the relevant node.
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
 	poolName := mux.Vars(r)["name"]
 
 	// Get the existing storage pool.
@@ -316,6 +338,11 @@ func storagePoolPut(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	// With a target node, only the node-specific keys get changed.
+	if clustered && r.FormValue("target") != "" {
+		return storagePoolNodeConfigUpdate(d, r, dbInfo, req.Config)
+	}
+
 	config := dbInfo.Config
 	if clustered {
 		err := storagePoolValidateClusterConfig(req.Config)
@@ -373,6 +400,12 @@ func storagePoolPut(d *Daemon, r *http.Request) Response {
 // /1.0/storage-pools/{name}
 // Change pool properties.
 func storagePoolPatch(d *Daemon, r *http.Request) Response {
+	// If a target was specified, forward the request to the relevant node.
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
 	poolName := mux.Vars(r)["name"]
 
 	// Get the existing network
@@ -391,8 +424,12 @@ func storagePoolPatch(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	// With a target node, only the node-specific keys get changed, on top
+	// of the current ones.
+	nodeUpdate := clustered && r.FormValue("target") != ""
+
 	config := dbInfo.Config
-	if clustered {
+	if clustered && !nodeUpdate {
 		err := storagePoolValidateClusterConfig(req.Config)
 		if err != nil {
 			return BadRequest(err)
@@ -400,12 +437,15 @@ func storagePoolPatch(d *Daemon, r *http.Request) Response {
 		config = storagePoolClusterConfigForEtag(config)
 	}
 
-	// Validate the ETag
-	etag := []interface{}{dbInfo.Name, dbInfo.Driver, config}
+	// Validate the ETag, the one of node-specific updates being checked
+	// along with them
+	if !nodeUpdate {
+		etag := []interface{}{dbInfo.Name, dbInfo.Driver, config}
 
-	err = util.EtagCheck(r, etag)
-	if err != nil {
-		return PreconditionFailed(err)
+		err = util.EtagCheck(r, etag)
+		if err != nil {
+			return PreconditionFailed(err)
+		}
 	}
 
 	// Config stacking
@@ -420,6 +460,10 @@ func storagePoolPatch(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	if nodeUpdate {
+		return storagePoolNodeConfigUpdate(d, r, dbInfo, req.Config)
+	}
+
 	// Validate the configuration
 	err = storagePoolValidateConfig(poolName, dbInfo.Driver, req.Config, dbInfo.Config)
 	if err != nil {
@@ -457,12 +501,50 @@ func storagePoolPatch(d *Daemon, r *http.Request) Response {
 	return EmptySyncResponse
 }
 
+// Update the node-specific configuration of a storage pool on this node, as
+// requested with a target node. The other keys are taken from the database.
+func storagePoolNodeConfigUpdate(d *Daemon, r *http.Request, dbInfo *api.StoragePool, reqConfig map[string]string) Response {
+	// Validate the ETag, which covers the node-specific values too
+	etag := []interface{}{dbInfo.Name, dbInfo.Driver, dbInfo.Config}
+
+	err := util.EtagCheck(r, etag)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	err = storagePoolValidateNodeConfig(reqConfig, dbInfo.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	config := storagePoolNodeFillWithClusterConfig(dbInfo.Config, reqConfig)
+
+	err = storagePoolValidateConfig(dbInfo.Name, dbInfo.Driver, config, dbInfo.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// A pool still pending on this node only needs its database entry
+	// updated, it gets created with it later on.
+	if dbInfo.Status == "Pending" {
+		err = d.cluster.StoragePoolUpdate(dbInfo.Name, dbInfo.Description, config)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		return EmptySyncResponse
+	}
+
+	err = storagePoolUpdate(d.State(), dbInfo.Name, dbInfo.Description, config, true)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
+
 // This helper makes sure that, when clustered, we're not changing
-// node-specific values.
-//
-// POSSIBLY TODO: for now we don't have any node-specific values that can be
-// modified. If we ever get some, we'll need to extend the PUT/PATCH APIs to
-// accept a targetNode query parameter.
+// node-specific values without a target node.
 func storagePoolValidateClusterConfig(reqConfig map[string]string) error {
 	for key := range reqConfig {
 		if shared.StringInSlice(key, db.StoragePoolNodeConfigKeys) {
@@ -472,6 +554,17 @@ func storagePoolValidateClusterConfig(reqConfig map[string]string) error {
 	return nil
 }
 
+// This helper makes sure that, with a target node, we're only changing
+// node-specific values.
+func storagePoolValidateNodeConfig(reqConfig, dbConfig map[string]string) error {
+	for key, value := range reqConfig {
+		if !shared.StringInSlice(key, db.StoragePoolNodeConfigKeys) && value != dbConfig[key] {
+			return fmt.Errorf("config key %s isn't node-specific and can't be changed for a single node", key)
+		}
+	}
+	return nil
+}
+
 // This helper deletes any node-specific values from the config object, since
 // they should not be part of the calculated etag.
 func storagePoolClusterConfigForEtag(dbConfig map[string]string) map[string]string {
@@ -492,6 +585,18 @@ func storagePoolClusterFillWithNodeConfig(dbConfig, reqConfig map[string]string)
 	return config
 }
 
+// This helper complements the node-specific values of a PUT/PATCH request
+// config with the other values, as taken from the db.
+func storagePoolNodeFillWithClusterConfig(dbConfig, reqConfig map[string]string) map[string]string {
+	config := storagePoolClusterConfigForEtag(dbConfig)
+	for _, key := range db.StoragePoolNodeConfigKeys {
+		if reqConfig[key] != "" {
+			config[key] = reqConfig[key]
+		}
+	}
+	return config
+}
+
 // /1.0/storage-pools/{name}
 // Delete storage pool.
 func storagePoolDelete(d *Daemon, r *http.Request) Response {
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Storage pools whose backend couldn't be brought up on this node, along
+// with the reason. Any operation touching them is refused until they
+// recover.
+var storagePoolsUnavailableLock sync.Mutex
+var storagePoolsUnavailable = map[string]string{}
+
+func storagePoolMarkUnavailable(name string, reason error) {
+	storagePoolsUnavailableLock.Lock()
+	defer storagePoolsUnavailableLock.Unlock()
+
+	storagePoolsUnavailable[name] = reason.Error()
+}
+
+func storagePoolMarkAvailable(name string) {
+	storagePoolsUnavailableLock.Lock()
+	defer storagePoolsUnavailableLock.Unlock()
+
+	delete(storagePoolsUnavailable, name)
+}
+
+// Return "available" or "unavailable" for the given storage pool, along with
+// the reason in the latter case.
+func storagePoolAvailability(name string) (string, string) {
+	storagePoolsUnavailableLock.Lock()
+	defer storagePoolsUnavailableLock.Unlock()
+
+	reason, ok := storagePoolsUnavailable[name]
+	if !ok {
+		return "available", ""
+	}
+
+	return "unavailable", reason
+}
+
+// Return an error if the given storage pool is unavailable.
+func storagePoolCheckAvailable(name string) error {
+	availability, reason := storagePoolAvailability(name)
+	if availability == "unavailable" {
+		return fmt.Errorf("Storage pool \"%s\" is unavailable: %s", name, reason)
+	}
+
+	return nil
+}
+
+// Return the availability of the storage pool of the given container.
+func containerAvailability(c container) (string, string) {
+	storagePoolsUnavailableLock.Lock()
+	none := len(storagePoolsUnavailable) == 0
+	storagePoolsUnavailableLock.Unlock()
+
+	// Skip the database lookup in the common case
+	if none {
+		return "available", ""
+	}
+
+	poolName, err := c.StoragePool()
+	if err != nil {
+		return "available", ""
+	}
+
+	return storagePoolAvailability(poolName)
+}
+
+// Check that the backend of the given storage pool is usable, and doesn't
+// report itself as unusable.
+func storagePoolProbe(s *state.State, name string) error {
+	st, err := storageLoad(s, name, "", -1)
+	if err != nil {
+		return err
+	}
+
+	err = st.StoragePoolCheck()
+	if err != nil {
+		return err
+	}
+
+	_, err = st.StoragePoolMount()
+	if err != nil {
+		return err
+	}
+
+	health, reason, err := storagePoolHealthGet(st)
+	if err == nil && health == "unavailable" {
+		return fmt.Errorf("%s", reason)
+	}
+
+	return nil
+}
+
+func storagePoolsRecoverTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		storagePoolsRecover(d.State())
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// Probe the unavailable storage pools again, and bring back the ones whose
+// backend reappeared, starting the containers which should be running.
+func storagePoolsRecover(s *state.State) {
+	storagePoolsUnavailableLock.Lock()
+	names := []string{}
+	for name := range storagePoolsUnavailable {
+		names = append(names, name)
+	}
+	storagePoolsUnavailableLock.Unlock()
+
+	for _, name := range names {
+		err := storagePoolProbe(s, name)
+		if err != nil {
+			logger.Debug("Storage pool still unavailable", log.Ctx{"pool": name, "err": err})
+			continue
+		}
+
+		storagePoolMarkAvailable(name)
+		logger.Info("Storage pool is available again", log.Ctx{"pool": name})
+
+		err = storagePoolContainersRestart(s, name)
+		if err != nil {
+			logger.Error("Failed to restart containers", log.Ctx{"pool": name, "err": err})
+		}
+	}
+}
+
+// Start the containers of this node on the given storage pool which would
+// have been started along with LXD. A container which fails to load is
+// skipped, so that it doesn't hold back the others.
+func storagePoolContainersRestart(s *state.State, poolName string) error {
+	names, err := s.Cluster.ContainersNodeList(db.CTypeRegular)
+	if err != nil {
+		return err
+	}
+
+	all := []container{}
+	for _, name := range names {
+		c, err := containerLoadByName(s, name)
+		if err != nil {
+			logger.Error("Failed to load container", log.Ctx{"container": name, "pool": poolName, "err": err})
+			continue
+		}
+
+		all = append(all, c)
+	}
+
+	containers := []container{}
+	for _, c := range all {
+		pool, err := c.StoragePool()
+		if err != nil || pool != poolName {
+			continue
+		}
+
+		containers = append(containers, c)
+	}
+
+	containersAutostart(all, containers)
+
+	return nil
+}
@@ -17,28 +17,43 @@ func updateStoragePoolError(unchangeable []string, driverName string) error {
 var changeableStoragePoolProperties = map[string][]string{
 	"btrfs": {
 		"rsync.bwlimit",
+		"rsync.checksum",
 		"btrfs.mount_options"},
 
 	"ceph": {
+		"rsync.bwlimit",
+		"rsync.checksum",
 		"volume.block.filesystem",
 		"volume.block.mount_options",
 		"volume.size"},
 
 	"dir": {
-		"rsync.bwlimit"},
+		"rsync.bwlimit",
+		"rsync.checksum"},
+
+	"external": {
+		"rsync.bwlimit",
+		"rsync.checksum"},
 
 	"lvm": {
+		"lvm.thinpool_autoextend_percent",
+		"lvm.thinpool_autoextend_threshold",
 		"lvm.thinpool_name",
+		"lvm.thinpool_warn_threshold",
 		"lvm.vg_name",
+		"rsync.bwlimit",
+		"rsync.checksum",
 		"volume.block.filesystem",
 		"volume.block.mount_options",
 		"volume.size"},
 
 	"zfs": {
-		"rsync_bwlimit",
+		"rsync.bwlimit",
+		"rsync.checksum",
 		"volume.zfs.remove_snapshots",
 		"volume.zfs.use_refquota",
-		"zfs.clone_copy"},
+		"zfs.clone_copy",
+		"zfs.send_flags"},
 }
 
 var storagePoolConfigKeys = map[string]func(value string) error{
@@ -64,10 +79,17 @@ var storagePoolConfigKeys = map[string]func(value string) error{
 	"ceph.rbd.clone_copy": shared.IsBool,
 	"ceph.user.name":      shared.IsAny,
 
+	// valid drivers: external
+	// (Any other "external." key is validated by the driver itself.)
+	"external.driver": shared.IsAny,
+
 	// valid drivers: lvm
-	"lvm.thinpool_name": shared.IsAny,
-	"lvm.use_thinpool":  shared.IsBool,
-	"lvm.vg_name":       shared.IsAny,
+	"lvm.thinpool_autoextend_percent":   storagePoolValidatePercentage,
+	"lvm.thinpool_autoextend_threshold": storagePoolValidatePercentage,
+	"lvm.thinpool_name":                 shared.IsAny,
+	"lvm.thinpool_warn_threshold":       storagePoolValidatePercentage,
+	"lvm.use_thinpool":                  shared.IsBool,
+	"lvm.vg_name":                       shared.IsAny,
 
 	// valid drivers: btrfs, lvm, zfs
 	"size": func(value string) error {
@@ -113,7 +135,33 @@ var storagePoolConfigKeys = map[string]func(value string) error{
 	// valid drivers: zfs
 	"zfs.clone_copy": shared.IsBool,
 	"zfs.pool_name":  shared.IsAny,
+	"zfs.send_flags": func(value string) error {
+		for _, flag := range strings.Fields(value) {
+			err := shared.IsOneOf(flag, []string{"-c", "-e", "-L"})
+			if err != nil {
+				return fmt.Errorf("Unsupported zfs send flag: %s", flag)
+			}
+		}
+
+		return nil
+	},
+
+	// valid drivers: btrfs, ceph, dir, external, lvm, zfs
 	"rsync.bwlimit":  shared.IsAny,
+	"rsync.checksum": shared.IsBool,
+}
+
+func storagePoolValidatePercentage(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	percent, err := strconv.Atoi(value)
+	if err != nil || percent < 0 || percent > 100 {
+		return fmt.Errorf("Invalid percentage: %s", value)
+	}
+
+	return nil
 }
 
 func storagePoolValidateConfig(name string, driver string, config map[string]string, oldConfig map[string]string) error {
@@ -131,6 +179,10 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 		}
 	}
 
+	if driver == "external" && oldConfig == nil && config["external.driver"] == "" {
+		return fmt.Errorf("the key external.driver is required for EXTERNAL storage pools")
+	}
+
 	v, ok := config["rsync.bwlimit"]
 	if ok && v != "" {
 		_, err := shared.ParseByteSizeString(v)
@@ -153,7 +205,7 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 		}
 
 		prfx := strings.HasPrefix
-		if driver == "dir" || driver == "ceph" {
+		if driver == "dir" || driver == "ceph" || driver == "external" {
 			if key == "size" {
 				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
 			}
@@ -171,6 +223,21 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 			}
 		}
 
+		if driver != "external" {
+			if prfx(key, "external.") {
+				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
+			}
+		} else {
+			// The pool's mountpoint is used as its source.
+			if key == "source" && val != getStoragePoolMountPoint(name) {
+				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
+			}
+
+			if prfx(key, "external.") && key != "external.driver" {
+				continue
+			}
+		}
+
 		// Validate storage pool config keys.
 		validator, ok := storagePoolConfigKeys[key]
 		if !ok {
@@ -187,7 +254,7 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 }
 
 func storagePoolFillDefault(name string, driver string, config map[string]string) error {
-	if driver == "dir" || driver == "ceph" {
+	if driver == "dir" || driver == "ceph" || driver == "external" {
 		if config["size"] != "" {
 			return fmt.Errorf(`The "size" property does not apply `+
 				`to %s storage pools`, driver)
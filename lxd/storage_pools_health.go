@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Storage pools whose backend reports being degraded on this node, along
+// with the reason. Backends reporting being unusable are marked unavailable
+// instead, see storagePoolsUnavailable.
+var storagePoolsDegradedLock sync.Mutex
+var storagePoolsDegraded = map[string]string{}
+
+// State of the storage pools on this node as last reported through a
+// lifecycle event.
+var storagePoolsStateReported = map[string]string{}
+
+// Return the state of the given storage pool on this node ("online",
+// "degraded" or "unavailable"), along with the reason when it isn't online.
+func storagePoolState(name string) (string, string) {
+	availability, reason := storagePoolAvailability(name)
+	if availability == "unavailable" {
+		return "unavailable", reason
+	}
+
+	storagePoolsDegradedLock.Lock()
+	defer storagePoolsDegradedLock.Unlock()
+
+	reason, ok := storagePoolsDegraded[name]
+	if ok {
+		return "degraded", reason
+	}
+
+	return "online", ""
+}
+
+func storagePoolsHealthTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		storagePoolsHealthCheck(d.State())
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// Ask the drivers of the available storage pools about the health of their
+// backend, marking unavailable the ones reporting being unusable, and emit a
+// lifecycle event for every pool whose state changed.
+func storagePoolsHealthCheck(s *state.State) {
+	names, err := s.Cluster.StoragePools()
+	if err != nil {
+		if err != db.ErrNoSuchObject {
+			logger.Error("Failed to get storage pools", log.Ctx{"err": err})
+		}
+		return
+	}
+
+	// Forget about deleted pools
+	storagePoolsDegradedLock.Lock()
+	for name := range storagePoolsDegraded {
+		if !shared.StringInSlice(name, names) {
+			delete(storagePoolsDegraded, name)
+		}
+	}
+	for name := range storagePoolsStateReported {
+		if !shared.StringInSlice(name, names) {
+			delete(storagePoolsStateReported, name)
+		}
+	}
+	storagePoolsDegradedLock.Unlock()
+
+	for _, name := range names {
+		if storagePoolCheckAvailable(name) == nil {
+			storagePoolHealthUpdate(s, name)
+		}
+
+		current, reason := storagePoolState(name)
+
+		storagePoolsDegradedLock.Lock()
+		previous, ok := storagePoolsStateReported[name]
+		storagePoolsStateReported[name] = current
+		storagePoolsDegradedLock.Unlock()
+
+		if !ok {
+			previous = "online"
+		}
+
+		if previous == current {
+			continue
+		}
+
+		logger.Info("Storage pool changed state", log.Ctx{"pool": name, "state": current, "previous": previous, "reason": reason})
+		eventSendLifecycle("storage-pool-state-changed",
+			fmt.Sprintf("/1.0/storage-pools/%s", name),
+			map[string]interface{}{
+				"state":    current,
+				"previous": previous,
+				"reason":   reason,
+			})
+	}
+}
+
+// Check the health of the backend of the given available storage pool.
+func storagePoolHealthUpdate(s *state.State, name string) {
+	st, err := storageLoad(s, name, "", -1)
+	if err != nil {
+		return
+	}
+
+	health, reason, err := storagePoolHealthGet(st)
+	if err != nil {
+		logger.Debug("Failed to check storage pool health", log.Ctx{"pool": name, "err": err})
+		return
+	}
+
+	storagePoolsDegradedLock.Lock()
+	if health == "degraded" {
+		storagePoolsDegraded[name] = reason
+	} else {
+		delete(storagePoolsDegraded, name)
+	}
+	storagePoolsDegradedLock.Unlock()
+
+	if health == "unavailable" {
+		// Recovered by storagePoolsRecover once the backend is
+		// healthy again.
+		storagePoolMarkUnavailable(name, fmt.Errorf("%s", reason))
+	}
+}
+
+// Return the state of the backend of the given storage pool. Drivers without
+// a way to check it are considered online as long as the pool is available.
+func storagePoolHealthGet(st storage) (string, string, error) {
+	switch st := st.(type) {
+	case *storageZfs:
+		return st.zfsPoolHealth()
+	case *storageCeph:
+		return st.cephHealth()
+	case *storageLvm:
+		if !st.usesThinpool() {
+			break
+		}
+
+		lvmThinPoolsWarnedLock.Lock()
+		warned := lvmThinPoolsWarned[st.pool.Name]
+		lvmThinPoolsWarnedLock.Unlock()
+
+		if warned {
+			return "degraded", "The thin pool is running out of space", nil
+		}
+	}
+
+	return "online", "", nil
+}
+
+func (s *storageZfs) zfsPoolHealth() (string, string, error) {
+	// Datasets share the health of their pool
+	poolName := strings.SplitN(s.getOnDiskPoolName(), "/", 2)[0]
+
+	output, err := shared.RunCommand("zpool", "list", "-H", "-o", "health", poolName)
+	if err != nil {
+		return "", "", err
+	}
+
+	health := strings.TrimSpace(output)
+	switch health {
+	case "ONLINE":
+		return "online", "", nil
+	case "DEGRADED":
+		return "degraded", fmt.Sprintf("ZFS pool \"%s\" is %s", poolName, health), nil
+	}
+
+	return "unavailable", fmt.Sprintf("ZFS pool \"%s\" is %s", poolName, health), nil
+}
+
+func (s *storageCeph) cephHealth() (string, string, error) {
+	output, err := shared.RunCommand(
+		"ceph",
+		"--name", fmt.Sprintf("client.%s", s.UserName),
+		"--cluster", s.ClusterName,
+		"health")
+	if err != nil {
+		return "", "", err
+	}
+
+	fields := strings.SplitN(strings.TrimSpace(output), " ", 2)
+	reason := ""
+	if len(fields) > 1 {
+		reason = strings.TrimSpace(fields[1])
+	}
+
+	switch fields[0] {
+	case "HEALTH_OK":
+		return "online", "", nil
+	case "HEALTH_WARN":
+		return "degraded", reason, nil
+	}
+
+	return "unavailable", reason, nil
+}
+
+// Return the state of the given storage pool on every node of the cluster,
+// by node name, given its state on this node. Nodes which are offline or
+// can't be asked are reported as unavailable.
+func storagePoolNodesState(d *Daemon, poolName string, local api.StoragePoolState) (map[string]api.StoragePoolState, error) {
+	var nodes []db.NodeInfo
+	var localName string
+	var offlineThreshold time.Duration
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+
+		nodes, err = tx.Nodes()
+		if err != nil {
+			return err
+		}
+
+		localName, err = tx.NodeName()
+		if err != nil {
+			return err
+		}
+
+		offlineThreshold, err = tx.NodeOfflineThreshold()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[string]api.StoragePoolState{localName: local}
+
+	cert := d.endpoints.NetworkCert()
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, node := range nodes {
+		if node.Name == localName {
+			continue
+		}
+
+		if node.IsOffline(offlineThreshold) {
+			states[node.Name] = api.StoragePoolState{State: "unavailable", Reason: "Node is offline"}
+			continue
+		}
+
+		wg.Add(1)
+		go func(node db.NodeInfo) {
+			defer wg.Done()
+
+			state := api.StoragePoolState{}
+
+			client, err := cluster.Connect(node.Address, cert, true)
+			if err == nil {
+				var pool *api.StoragePool
+				pool, _, err = client.GetStoragePool(poolName)
+				if err == nil {
+					state = api.StoragePoolState{State: pool.State, Reason: pool.StateReason}
+				}
+			}
+
+			if err != nil {
+				state = api.StoragePoolState{State: "unavailable", Reason: fmt.Sprintf("Failed to get the pool state: %v", err)}
+			}
+
+			mu.Lock()
+			states[node.Name] = state
+			mu.Unlock()
+		}(node)
+	}
+	wg.Wait()
+
+	return states, nil
+}
+
+// Return the worst of the given states of a storage pool across the nodes,
+// along with the reasons of the nodes in that state.
+func storagePoolStateWorst(states map[string]api.StoragePoolState) (string, string) {
+	rank := map[string]int{"online": 0, "degraded": 1, "unavailable": 2}
+
+	worst := "online"
+	for _, state := range states {
+		if rank[state.State] > rank[worst] {
+			worst = state.State
+		}
+	}
+
+	if worst == "online" {
+		return worst, ""
+	}
+
+	names := []string{}
+	for name, state := range states {
+		if state.State == worst {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	reasons := []string{}
+	for _, name := range names {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", name, states[name].Reason))
+	}
+
+	return worst, strings.Join(reasons, "; ")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// An unavailable pool is reported as such whatever its backend health, which
+// is only considered for available pools.
+func TestStoragePoolState(t *testing.T) {
+	defer func() {
+		storagePoolMarkAvailable("p1")
+		storagePoolsDegradedLock.Lock()
+		delete(storagePoolsDegraded, "p1")
+		storagePoolsDegradedLock.Unlock()
+	}()
+
+	state, reason := storagePoolState("p1")
+	assert.Equal(t, "online", state)
+	assert.Equal(t, "", reason)
+
+	storagePoolsDegradedLock.Lock()
+	storagePoolsDegraded["p1"] = "Lost redundancy"
+	storagePoolsDegradedLock.Unlock()
+
+	state, reason = storagePoolState("p1")
+	assert.Equal(t, "degraded", state)
+	assert.Equal(t, "Lost redundancy", reason)
+
+	storagePoolMarkUnavailable("p1", fmt.Errorf("Missing backend"))
+	state, reason = storagePoolState("p1")
+	assert.Equal(t, "unavailable", state)
+	assert.Equal(t, "Missing backend", reason)
+}
+
+// The cluster-wide state is the worst one, with the reasons of the nodes in
+// that state.
+func TestStoragePoolStateWorst(t *testing.T) {
+	state, reason := storagePoolStateWorst(map[string]api.StoragePoolState{
+		"node1": {State: "online"},
+		"node2": {State: "online"},
+	})
+	assert.Equal(t, "online", state)
+	assert.Equal(t, "", reason)
+
+	state, reason = storagePoolStateWorst(map[string]api.StoragePoolState{
+		"node1": {State: "online"},
+		"node2": {State: "degraded", Reason: "HEALTH_WARN"},
+		"node3": {State: "degraded", Reason: "Thin pool full"},
+	})
+	assert.Equal(t, "degraded", state)
+	assert.Equal(t, "node2: HEALTH_WARN; node3: Thin pool full", reason)
+
+	state, reason = storagePoolStateWorst(map[string]api.StoragePoolState{
+		"node1": {State: "unavailable", Reason: "Node is offline"},
+		"node2": {State: "degraded", Reason: "HEALTH_WARN"},
+	})
+	assert.Equal(t, "unavailable", state)
+	assert.Equal(t, "node1: Node is offline", reason)
+}
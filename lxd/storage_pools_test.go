@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// With a target node, only the node-specific keys may differ from the
+// database, and the other keys are taken from it.
+func TestStoragePoolNodeConfig(t *testing.T) {
+	dbConfig := map[string]string{"source": "/srv/old", "rsync.bwlimit": "10MB"}
+
+	assert.NoError(t, storagePoolValidateNodeConfig(map[string]string{"source": "/srv/new", "rsync.bwlimit": "10MB"}, dbConfig))
+	assert.Error(t, storagePoolValidateNodeConfig(map[string]string{"rsync.bwlimit": "20MB"}, dbConfig))
+
+	config := storagePoolNodeFillWithClusterConfig(dbConfig, map[string]string{"source": "/srv/new", "rsync.bwlimit": "20MB"})
+	assert.Equal(t, map[string]string{"source": "/srv/new", "rsync.bwlimit": "10MB"}, config)
+}
+
+type storagePoolsTestSuite struct {
+	lxdTestSuite
+}
+
+// The node-specific keys of a pool pending on this node get updated in the
+// database, while changes to other keys and stale ETags are refused.
+func (suite *storagePoolsTestSuite) TestStoragePoolNodeConfigUpdate() {
+	_, err := suite.d.cluster.StoragePoolCreate("pool1", "", "dir", map[string]string{"source": "/srv/old", "rsync.bwlimit": "10MB"})
+	suite.Req.Nil(err)
+
+	_, dbInfo, err := suite.d.cluster.StoragePoolGet("pool1")
+	suite.Req.Nil(err)
+	dbInfo.Status = "Pending"
+
+	r := httptest.NewRequest("PUT", "/1.0/storage-pools/pool1?target=node1", nil)
+	resp := storagePoolNodeConfigUpdate(suite.d, r, dbInfo, map[string]string{"rsync.bwlimit": "20MB"})
+	suite.Req.Equal(http.StatusBadRequest, resp.(*errorResponse).code)
+
+	r.Header.Set("If-Match", "stale")
+	resp = storagePoolNodeConfigUpdate(suite.d, r, dbInfo, map[string]string{"source": "/srv/new"})
+	suite.Req.Equal(http.StatusPreconditionFailed, resp.(*errorResponse).code)
+
+	r.Header.Del("If-Match")
+	resp = storagePoolNodeConfigUpdate(suite.d, r, dbInfo, map[string]string{"source": "/srv/new", "rsync.bwlimit": "10MB"})
+	suite.Req.Equal(EmptySyncResponse, resp)
+
+	_, dbInfo, err = suite.d.cluster.StoragePoolGet("pool1")
+	suite.Req.Nil(err)
+	suite.Req.Equal(map[string]string{"source": "/srv/new", "rsync.bwlimit": "10MB"}, dbInfo.Config)
+}
+
+func TestStoragePoolsTestSuite(t *testing.T) {
+	suite.Run(t, new(storagePoolsTestSuite))
+}
@@ -8,10 +8,13 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
-var supportedPoolTypes = []string{"btrfs", "ceph", "dir", "lvm", "zfs"}
+var supportedPoolTypes = []string{"btrfs", "ceph", "dir", "external", "lvm", "zfs"}
 
 func storagePoolUpdate(state *state.State, name, newDescription string, newConfig map[string]string, withDB bool) error {
 	s, err := storagePoolInit(state, name)
@@ -275,6 +278,12 @@ func doStoragePoolCreateInternal(state *state.State, poolName, poolDescription s
 	// Success, update the closure to mark that the changes should be kept.
 	tryUndo = false
 
+	// Have the backups of the new pool land on the shared storage too
+	err = storageSharedSetup(state)
+	if err != nil {
+		logger.Warn("Unable to setup the shared storage", log.Ctx{"pool": poolName, "err": err})
+	}
+
 	return nil
 }
 
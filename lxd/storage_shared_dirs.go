@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Get the directories of this node which live on the shared filesystem when
+// "storage.shared_source" is set, keyed by their path relative to it. Those
+// are the images directory, and the backups directory of every storage pool.
+func storageSharedDirs(s *state.State) (map[string]string, error) {
+	dirs := map[string]string{
+		"images": shared.VarPath("images"),
+	}
+
+	pools, err := s.Cluster.StoragePools()
+	if err != nil && err != db.ErrNoSuchObject {
+		return nil, err
+	}
+
+	for _, pool := range pools {
+		dirs[filepath.Join("backups", pool)] = shared.VarPath("storage-pools", pool, "backups")
+	}
+
+	return dirs, nil
+}
+
+// Get the settings the shared filesystem currently mounted at the given path
+// was set up for, as recorded next to it when it got mounted. The source the
+// kernel reports for the mount can't be relied on, since it normalizes
+// monitor addresses and paths.
+func storageSharedSourceGet(mntPath string) string {
+	if !shared.IsMountPoint(mntPath) {
+		return ""
+	}
+
+	content, err := ioutil.ReadFile(mntPath + ".source")
+	if err != nil {
+		// Mounted by an older version, consider it stale
+		return "unknown"
+	}
+
+	return strings.TrimSpace(string(content))
+}
+
+// Record the settings the shared filesystem mounted at the given path was
+// set up for.
+func storageSharedSourceSet(mntPath string, settings string) error {
+	if settings == "" {
+		err := os.Remove(mntPath + ".source")
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	return ioutil.WriteFile(mntPath+".source", []byte(settings+"\n"), 0600)
+}
+
+// Unmount the shared filesystem and the directories bind-mounted from it. The
+// content of the shared directories is first mirrored into the local ones, so
+// that they don't go back to what they held before the filesystem got shared.
+func storageSharedRelease(mntPath string, dirs map[string]string) error {
+	for name, dir := range dirs {
+		if !shared.IsMountPoint(dir) {
+			continue
+		}
+
+		err := tryUnmount(dir, syscall.MNT_DETACH)
+		if err != nil {
+			return fmt.Errorf("Failed to unmount %s: %s", dir, err)
+		}
+
+		target := filepath.Join(mntPath, name)
+		if !shared.PathExists(target) {
+			continue
+		}
+
+		_, err = shared.RunCommand("rsync", "-a", "-HAX", "--delete", shared.AddSlash(target), dir)
+		if err != nil {
+			return fmt.Errorf("Failed to copy %s from the shared storage: %s", dir, err)
+		}
+	}
+
+	err := tryUnmount(mntPath, syscall.MNT_DETACH)
+	if err != nil {
+		return fmt.Errorf("Failed to unmount %s: %s", mntPath, err)
+	}
+
+	return storageSharedSourceSet(mntPath, "")
+}
+
+// Mount the CephFS filesystem set in "storage.shared_source" and bind-mount
+// its directories over the images and backups directories of this node, or
+// undo all of it if the key was unset or changed. Anything found in the local
+// directories is first copied over to the shared filesystem, so that images
+// and backups this node already had remain available, and copied back when
+// the filesystem is released.
+func storageSharedSetup(s *state.State) error {
+	var source, clusterName, userName string
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		source, clusterName, userName = config.StorageShared()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dirs, err := storageSharedDirs(s)
+	if err != nil {
+		return err
+	}
+
+	mntPath := shared.VarPath("shared")
+
+	cephfsSource := ""
+	cephfsOptions := ""
+	if source != "" {
+		_, fsName, fsPath, err := deviceParseCephSource(source)
+		if err != nil {
+			return err
+		}
+
+		cephfsSource, cephfsOptions, err = deviceCephFSMountOptions(clusterName, userName, fsName, fsPath)
+		if err != nil {
+			return fmt.Errorf("Failed to configure CephFS %s: %s", source, err)
+		}
+	}
+
+	// Release the previous filesystem if it's not the one to use anymore
+	settings := ""
+	if source != "" {
+		settings = strings.Join([]string{source, clusterName, userName}, " ")
+	}
+
+	mounted := storageSharedSourceGet(mntPath)
+	if mounted != "" && mounted != settings {
+		err := storageSharedRelease(mntPath, dirs)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Unmounted shared storage", log.Ctx{"path": mntPath})
+	}
+
+	if cephfsSource == "" {
+		return nil
+	}
+
+	if !shared.IsMountPoint(mntPath) {
+		err := os.MkdirAll(mntPath, 0711)
+		if err != nil {
+			return err
+		}
+
+		err = tryMount(cephfsSource, mntPath, "ceph", 0, cephfsOptions)
+		if err != nil {
+			return fmt.Errorf("Failed to mount %s at %s: %s", source, mntPath, err)
+		}
+
+		err = storageSharedSourceSet(mntPath, settings)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Mounted shared storage", log.Ctx{"source": source, "path": mntPath})
+	}
+
+	for name, dir := range dirs {
+		if shared.IsMountPoint(dir) {
+			continue
+		}
+
+		target := filepath.Join(mntPath, name)
+		err := os.MkdirAll(target, 0700)
+		if err != nil {
+			return err
+		}
+
+		if shared.PathExists(dir) {
+			_, err = shared.RunCommand("rsync", "-a", "-HAX", "--ignore-existing", shared.AddSlash(dir), target)
+			if err != nil {
+				return fmt.Errorf("Failed to copy %s to the shared storage: %s", dir, err)
+			}
+		} else {
+			err = os.MkdirAll(dir, 0700)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = syscall.Mount(target, dir, "none", syscall.MS_BIND, "")
+		if err != nil {
+			return fmt.Errorf("Failed to mount %s at %s: %s", target, dir, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// The settings of the shared filesystem are recorded next to its mount point
+// and only trusted while it's mounted.
+func TestStorageSharedSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-shared-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mntPath := filepath.Join(dir, "shared")
+	require.NoError(t, os.Mkdir(mntPath, 0711))
+
+	settings := "cephfs:fs/lxd ceph admin"
+	require.NoError(t, storageSharedSourceSet(mntPath, settings))
+
+	content, err := ioutil.ReadFile(mntPath + ".source")
+	require.NoError(t, err)
+	assert.Equal(t, settings+"\n", string(content))
+
+	// Not a mount point, so nothing is mounted whatever the record says
+	assert.Equal(t, "", storageSharedSourceGet(mntPath))
+
+	require.NoError(t, storageSharedSourceSet(mntPath, ""))
+	assert.False(t, shared.PathExists(mntPath+".source"))
+	require.NoError(t, storageSharedSourceSet(mntPath, ""))
+}
@@ -11,6 +11,9 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
@@ -410,6 +413,25 @@ func storagePoolVolumeTypePost(d *Daemon, r *http.Request) Response {
 	}
 	r.Body = shared.BytesReadCloser{Buf: &buf}
 
+	// A migration request without a push target, sent with a target node
+	// which doesn't have the volume, is meant to move the volume to that
+	// node.
+	targetNode := r.FormValue("target")
+	if targetNode != "" && req.Migration && req.Target == nil {
+		if req.Pool != "" && req.Pool != poolName {
+			return BadRequest(fmt.Errorf("Storage volumes can't be moved to another pool and node at once"))
+		}
+
+		moving, err := storagePoolVolumeIsClusterMove(d, poolID, volumeName, targetNode)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		if moving {
+			return storagePoolVolumeTypePostClusteringMigrate(d, r, poolName, poolID, volumeName, req.Name, targetNode)
+		}
+	}
+
 	response := ForwardedResponseIfTargetIsRemote(d, r)
 	if response != nil {
 		return response
@@ -535,6 +557,178 @@ func storagePoolVolumeTypePost(d *Daemon, r *http.Request) Response {
 	return OperationResponse(op)
 }
 
+// Check whether a migration request targeted at the given node is meant to
+// move the custom volume there, that is if the node doesn't have it yet.
+func storagePoolVolumeIsClusterMove(d *Daemon, poolID int64, volumeName string, targetNode string) (bool, error) {
+	moving := false
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		addresses, err := tx.StorageVolumeNodeAddresses(poolID, volumeName, storagePoolVolumeTypeCustom)
+		if err != nil {
+			return err
+		}
+
+		// Load cluster configuration.
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return errors.Wrap(err, "Failed to load LXD config")
+		}
+
+		// Load target node.
+		node, err := tx.NodeByName(targetNode)
+		if err != nil {
+			return errors.Wrap(err, "Failed to get target node")
+		}
+
+		localAddress, err := tx.NodeAddress()
+		if err != nil {
+			return err
+		}
+
+		address := node.Address
+		if address == localAddress {
+			address = ""
+		}
+
+		if shared.StringInSlice(address, addresses) {
+			return nil
+		}
+
+		if node.IsOffline(config.OfflineThreshold()) {
+			return fmt.Errorf("Target node is offline")
+		}
+
+		moving = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return moving, nil
+}
+
+// Move a custom volume to another cluster node. The target node pulls the
+// volume using the regular storage migration protocol, after which the local
+// copy gets deleted.
+func storagePoolVolumeTypePostClusteringMigrate(d *Daemon, r *http.Request, poolName string, poolID int64, oldName string, newName string, newNode string) Response {
+	// Forward the request to the node holding the volume
+	cert := d.endpoints.NetworkCert()
+	client, err := cluster.ConnectIfVolumeIsRemote(d.cluster, poolID, oldName, storagePoolVolumeTypeCustom, cert)
+	if err != nil {
+		return SmartError(err)
+	}
+	if client != nil {
+		return ForwardedResponse(client, r)
+	}
+
+	volumeUsedBy, err := storagePoolVolumeUsedByGet(d.State(), oldName, storagePoolVolumeTypeNameCustom)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(volumeUsedBy) > 0 {
+		return BadRequest(fmt.Errorf("The storage volume is still in use by containers or profiles"))
+	}
+
+	s, err := storagePoolVolumeInit(d.State(), poolName, oldName, storagePoolVolumeTypeCustom)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	volume := s.GetStoragePoolVolume()
+
+	ws, err := NewStorageMigrationSource(s)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	resources := map[string][]string{}
+	resources["storage_volumes"] = []string{fmt.Sprintf("%s/volumes/custom/%s", poolName, oldName)}
+
+	run := func(op *operation) error {
+		var localAddress string
+		var targetAddress string
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			localAddress, err = tx.NodeAddress()
+			if err != nil {
+				return err
+			}
+
+			node, err := tx.NodeByName(newNode)
+			if err != nil {
+				return err
+			}
+			targetAddress = node.Address
+
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "Failed to get node addresses")
+		}
+
+		client, err := cluster.Connect(targetAddress, cert, false)
+		if err != nil {
+			return errors.Wrap(err, "Failed to connect to target node")
+		}
+
+		// Set up the migration source the target node will pull from
+		sourceOp, err := operationCreate(d.cluster, operationClassWebsocket, "Migrating storage volume", resources, ws.Metadata(), ws.DoStorage, nil, ws.Connect)
+		if err != nil {
+			return err
+		}
+
+		_, err = sourceOp.Run()
+		if err != nil {
+			return err
+		}
+
+		req := api.StorageVolumesPost{
+			StorageVolumePut: volume.StorageVolumePut,
+			Name:             newName,
+			Type:             storagePoolVolumeTypeNameCustom,
+			Source: api.StorageVolumeSource{
+				Type:        "migration",
+				Mode:        "pull",
+				Operation:   fmt.Sprintf("https://%s%s", localAddress, sourceOp.url),
+				Websockets:  map[string]string{"control": ws.controlSecret, "fs": ws.fsSecret},
+				Certificate: string(cert.PublicKey()),
+			},
+		}
+
+		path := fmt.Sprintf("/storage-pools/%s/volumes/custom", poolName)
+		targetOp, _, err := client.RawOperation("POST", path, req, "")
+		if err != nil {
+			return errors.Wrap(err, "Failed to create storage volume on target node")
+		}
+
+		err = targetOp.Wait()
+		if err != nil {
+			return errors.Wrap(err, "Failed to migrate storage volume to target node")
+		}
+
+		_, err = sourceOp.WaitFinal(-1)
+		if err != nil {
+			return err
+		}
+
+		// The volume now lives on the target node
+		err = s.StoragePoolVolumeDelete()
+		if err != nil {
+			return errors.Wrap(err, "Failed to delete source storage volume")
+		}
+
+		return nil
+	}
+
+	op, err := operationCreate(d.cluster, operationClassTask, "Moving storage volume", resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
 // /1.0/storage-pools/{pool}/volumes/{type}/{name}
 // Get storage volume of a given volume type on a given storage pool.
 func storagePoolVolumeTypeGet(d *Daemon, r *http.Request) Response {
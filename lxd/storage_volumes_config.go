@@ -49,19 +49,26 @@ func updateStoragePoolVolumeError(unchangeable []string, driverName string) erro
 // property which can be manipulated by setting a root disk device "size"
 // property.
 var changeableStoragePoolVolumeProperties = map[string][]string{
-	"btrfs": {"size"},
+	"btrfs": {
+		"security.shifted",
+		"size"},
 
 	"ceph": {
 		"block.mount_options",
+		"security.shifted",
 		"size"},
 
-	"dir": {""},
+	"dir": {"security.shifted"},
+
+	"external": {"security.shifted"},
 
 	"lvm": {
 		"block.mount_options",
+		"security.shifted",
 		"size"},
 
 	"zfs": {
+		"security.shifted",
 		"size",
 		"zfs.remove_snapshots",
 		"zfs.use_refquota"},
@@ -80,6 +87,17 @@ var storageVolumeConfigKeys = map[string]func(value string) ([]string, error){
 	"block.mount_options": func(value string) ([]string, error) {
 		return []string{"ceph", "lvm"}, shared.IsAny(value)
 	},
+	"content_type": func(value string) ([]string, error) {
+		err := shared.IsOneOf(value, []string{"filesystem", "block"})
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{"lvm", "zfs"}, nil
+	},
+	"security.shifted": func(value string) ([]string, error) {
+		return supportedPoolTypes, shared.IsBool(value)
+	},
 	"size": func(value string) ([]string, error) {
 		if value == "" {
 			return []string{"btrfs", "ceph", "lvm", "zfs"}, nil
@@ -144,17 +162,35 @@ func storageVolumeValidateConfig(name string, config map[string]string, parentPo
 			}
 		}
 
-		if parentPool.Driver == "dir" {
+		if storagePoolVolumeIsBlock(config) {
+			if !shared.StringInSlice(parentPool.Driver, []string{"lvm", "zfs"}) {
+				return fmt.Errorf("block storage volumes aren't supported by the %s driver", parentPool.Driver)
+			}
+
 			if config["block.mount_options"] != "" {
-				return fmt.Errorf("the key block.mount_options cannot be used with dir storage volumes")
+				return fmt.Errorf("the key block.mount_options cannot be used with block storage volumes")
 			}
 
 			if config["block.filesystem"] != "" {
-				return fmt.Errorf("the key block.filesystem cannot be used with dir storage volumes")
+				return fmt.Errorf("the key block.filesystem cannot be used with block storage volumes")
+			}
+
+			if config["security.shifted"] != "" {
+				return fmt.Errorf("the key security.shifted cannot be used with block storage volumes")
+			}
+		}
+
+		if parentPool.Driver == "dir" || parentPool.Driver == "external" {
+			if config["block.mount_options"] != "" {
+				return fmt.Errorf("the key block.mount_options cannot be used with %s storage volumes", parentPool.Driver)
+			}
+
+			if config["block.filesystem"] != "" {
+				return fmt.Errorf("the key block.filesystem cannot be used with %s storage volumes", parentPool.Driver)
 			}
 
 			if config["size"] != "" {
-				return fmt.Errorf("the key size cannot be used with dir storage volumes")
+				return fmt.Errorf("the key size cannot be used with %s storage volumes", parentPool.Driver)
 			}
 		}
 	}
@@ -163,8 +199,16 @@ func storageVolumeValidateConfig(name string, config map[string]string, parentPo
 }
 
 func storageVolumeFillDefault(name string, config map[string]string, parentPool *api.StoragePool) error {
-	if parentPool.Driver == "dir" {
+	if parentPool.Driver == "dir" || parentPool.Driver == "external" {
 		config["size"] = ""
+	} else if storagePoolVolumeIsBlock(config) {
+		// Block volumes have no filesystem and always need a size.
+		if config["size"] == "0" || config["size"] == "" {
+			config["size"] = parentPool.Config["volume.size"]
+		}
+		if config["size"] == "0" || config["size"] == "" {
+			config["size"] = "10GB"
+		}
 	} else if parentPool.Driver == "lvm" || parentPool.Driver == "ceph" {
 		if config["block.filesystem"] == "" {
 			config["block.filesystem"] = parentPool.Config["volume.block.filesystem"]
@@ -202,3 +246,9 @@ func storageVolumeFillDefault(name string, config map[string]string, parentPool
 
 	return nil
 }
+
+// Return whether the given volume config is the one of a block volume, which
+// holds a raw block device rather than a filesystem.
+func storagePoolVolumeIsBlock(config map[string]string) bool {
+	return config["content_type"] == "block"
+}
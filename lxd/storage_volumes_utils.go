@@ -7,6 +7,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/version"
@@ -136,6 +137,31 @@ func storagePoolVolumeUpdate(state *state.State, poolName string, volumeName str
 		}
 	}
 
+	// Containers mount shifted volumes differently, so they must be
+	// stopped to switch.
+	if shared.StringInSlice("security.shifted", changedConfig) {
+		volumeTypeName, err := storagePoolVolumeTypeToName(volumeType)
+		if err != nil {
+			return err
+		}
+
+		volumeUsedBy, err := storagePoolVolumeUsedByContainersGet(state, volumeName, volumeTypeName)
+		if err != nil {
+			return err
+		}
+
+		for _, ctName := range volumeUsedBy {
+			ct, err := containerLoadByName(state, ctName)
+			if err != nil {
+				return err
+			}
+
+			if ct.IsRunning() {
+				return fmt.Errorf("Can't change security.shifted while the volume is used by running container \"%s\"", ctName)
+			}
+		}
+	}
+
 	// Apply config changes if there are any
 	if len(changedConfig) != 0 {
 		newWritable.Description = newDescription
@@ -172,6 +198,89 @@ func storagePoolVolumeUpdate(state *state.State, poolName string, volumeName str
 	return nil
 }
 
+// Return whether the given device is a disk backed by a custom storage
+// volume, as opposed to the root disk or a host path.
+func storagePoolVolumeIsCustomDisk(m types.Device) bool {
+	if m["type"] != "disk" || m["pool"] == "" || m["source"] == "" {
+		return false
+	}
+
+	return !shared.IsRootDiskDevice(m)
+}
+
+// Return whether the given disk device refers to a custom storage volume
+// with security.shifted set. Such volumes are kept unshifted on disk and get
+// mounted into containers through shiftfs, which remaps them to the idmap of
+// each container.
+func storagePoolVolumeIsShifted(s *state.State, m types.Device) (bool, error) {
+	if !storagePoolVolumeIsCustomDisk(m) {
+		return false, nil
+	}
+
+	volumeName := filepath.Clean(m["source"])
+	volumeName = strings.TrimPrefix(volumeName, fmt.Sprintf("%s/", storagePoolVolumeTypeNameCustom))
+
+	poolID, err := s.Cluster.StoragePoolGetID(m["pool"])
+	if err != nil {
+		return false, err
+	}
+
+	_, volume, err := s.Cluster.StoragePoolNodeVolumeGetType(volumeName, storagePoolVolumeTypeCustom, poolID)
+	if err != nil {
+		return false, err
+	}
+
+	return shared.IsTrue(volume.Config["security.shifted"]), nil
+}
+
+// Return the unix-block device through which the given disk device should be
+// passed to the container if it refers to a block custom storage volume, or
+// nil otherwise.
+func storagePoolVolumeBlockDevice(s *state.State, m types.Device) (types.Device, error) {
+	if !storagePoolVolumeIsCustomDisk(m) {
+		return nil, nil
+	}
+
+	volumeName := filepath.Clean(m["source"])
+	volumeName = strings.TrimPrefix(volumeName, fmt.Sprintf("%s/", storagePoolVolumeTypeNameCustom))
+
+	poolID, err := s.Cluster.StoragePoolGetID(m["pool"])
+	if err != nil {
+		return nil, err
+	}
+
+	_, volume, err := s.Cluster.StoragePoolNodeVolumeGetType(volumeName, storagePoolVolumeTypeCustom, poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !storagePoolVolumeIsBlock(volume.Config) {
+		return nil, nil
+	}
+
+	st, err := storagePoolVolumeInit(s, m["pool"], volumeName, storagePoolVolumeTypeCustom)
+	if err != nil {
+		return nil, err
+	}
+
+	devPath, err := st.StoragePoolVolumeBlockPath()
+	if err != nil {
+		return nil, err
+	}
+
+	blockDevice := types.Device{
+		"type":   "unix-block",
+		"source": devPath,
+		"path":   m["path"],
+	}
+
+	if shared.IsTrue(m["optional"]) {
+		blockDevice["required"] = "false"
+	}
+
+	return blockDevice, nil
+}
+
 func storagePoolVolumeUsedByContainersGet(s *state.State, volumeName string,
 	volumeTypeName string) ([]string, error) {
 	cts, err := s.Cluster.ContainersList(db.CTypeRegular)
@@ -525,6 +634,29 @@ func storagePoolVolumeDBCreateInternal(state *state.State, poolName string, vol
 	volumeTypeName := vol.Type
 	volumeConfig := vol.Config
 
+	if vol.Source.Name != "" {
+		isBlock := storagePoolVolumeIsBlock(vol.Config)
+
+		// Local copies don't necessarily pass the source config along
+		if !isBlock && vol.Source.Type == "copy" && vol.Source.Pool != "" {
+			sourcePoolID, err := state.Cluster.StoragePoolGetID(vol.Source.Pool)
+			if err != nil {
+				return nil, err
+			}
+
+			_, source, err := state.Cluster.StoragePoolNodeVolumeGetType(vol.Source.Name, storagePoolVolumeTypeCustom, sourcePoolID)
+			if err != nil {
+				return nil, err
+			}
+
+			isBlock = storagePoolVolumeIsBlock(source.Config)
+		}
+
+		if isBlock {
+			return nil, fmt.Errorf("Copying block storage volumes isn't supported")
+		}
+	}
+
 	if vol.Source.Name != "" {
 		// Initialize instance of new pool to translate properties
 		// between storage drivers.
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/types"
+)
+
+func TestStoragePoolVolumeIsCustomDisk(t *testing.T) {
+	cases := []struct {
+		device types.Device
+		custom bool
+	}{
+		{types.Device{"type": "disk", "pool": "default", "path": "/"}, false},
+		{types.Device{"type": "disk", "pool": "default", "path": "/mnt"}, false},
+		{types.Device{"type": "disk", "source": "/srv", "path": "/mnt"}, false},
+		{types.Device{"type": "nic", "pool": "default", "source": "vol"}, false},
+		{types.Device{"type": "disk", "pool": "default", "source": "vol", "path": "/mnt"}, true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.custom, storagePoolVolumeIsCustomDisk(c.device), "%v", c.device)
+	}
+}
+
+// The root disk is never looked up as a custom volume.
+func TestStoragePoolVolumeBlockDevice_RootDisk(t *testing.T) {
+	device, err := storagePoolVolumeBlockDevice(nil, types.Device{"type": "disk", "pool": "default", "path": "/"})
+	require.NoError(t, err)
+	assert.Nil(t, device)
+
+	shifted, err := storagePoolVolumeIsShifted(nil, types.Device{"type": "disk", "pool": "default", "path": "/"})
+	require.NoError(t, err)
+	assert.False(t, shifted)
+}
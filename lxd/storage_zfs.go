@@ -365,6 +365,23 @@ func (s *storageZfs) StoragePoolVolumeCreate() error {
 	dataset := fmt.Sprintf("%s/%s", poolName, fs)
 	customPoolVolumeMntPoint := getStoragePoolVolumeMountPoint(s.pool.Name, s.volume.Name)
 
+	// Block volumes are zvols, handed out as is
+	if storagePoolVolumeIsBlock(s.volume.Config) {
+		size, err := shared.ParseByteSizeString(s.volume.Config["size"])
+		if err != nil {
+			return err
+		}
+
+		msg, err := zfsPoolVolumeBlockCreate(dataset, size)
+		if err != nil {
+			logger.Errorf("Failed to create ZFS storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, msg)
+			return err
+		}
+
+		logger.Infof("Created ZFS storage volume \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
+		return nil
+	}
+
 	msg, err := zfsPoolVolumeCreate(dataset, "mountpoint=none", "canmount=noauto")
 	if err != nil {
 		logger.Errorf("Failed to create ZFS storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, msg)
@@ -454,9 +471,11 @@ func (s *storageZfs) StoragePoolVolumeDelete() error {
 				return err
 			}
 		} else {
-			err := zfsPoolVolumeSet(poolName, fs, "mountpoint", "none")
-			if err != nil {
-				return err
+			if !storagePoolVolumeIsBlock(s.volume.Config) {
+				err := zfsPoolVolumeSet(poolName, fs, "mountpoint", "none")
+				if err != nil {
+					return err
+				}
 			}
 
 			err = zfsPoolVolumeRename(poolName, fs, fmt.Sprintf("deleted/custom/%s", uuid.NewRandom().String()))
@@ -488,6 +507,11 @@ func (s *storageZfs) StoragePoolVolumeDelete() error {
 func (s *storageZfs) StoragePoolVolumeMount() (bool, error) {
 	logger.Debugf("Mounting ZFS storage volume \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
 
+	// Block volumes are never mounted
+	if storagePoolVolumeIsBlock(s.volume.Config) {
+		return false, nil
+	}
+
 	fs := fmt.Sprintf("custom/%s", s.volume.Name)
 	customPoolVolumeMntPoint := getStoragePoolVolumeMountPoint(s.pool.Name, s.volume.Name)
 
@@ -531,6 +555,10 @@ func (s *storageZfs) StoragePoolVolumeMount() (bool, error) {
 func (s *storageZfs) StoragePoolVolumeUmount() (bool, error) {
 	logger.Debugf("Unmounting ZFS storage volume \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
 
+	if storagePoolVolumeIsBlock(s.volume.Config) {
+		return false, nil
+	}
+
 	fs := fmt.Sprintf("custom/%s", s.volume.Name)
 	customPoolVolumeMntPoint := getStoragePoolVolumeMountPoint(s.pool.Name, s.volume.Name)
 
@@ -607,8 +635,10 @@ func (s *storageZfs) StoragePoolUpdate(writable *api.StoragePoolPut, changedConf
 	}
 
 	// "rsync.bwlimit" requires no on-disk modifications.
+	// "rsync.checksum" requires no on-disk modifications.
 	// "volume.zfs.remove_snapshots" requires no on-disk modifications.
 	// "volume.zfs.use_refquota" requires no on-disk modifications.
+	// "zfs.send_flags" requires no on-disk modifications.
 
 	logger.Infof(`Updated ZFS storage pool "%s"`, s.pool.Name)
 	return nil
@@ -968,7 +998,7 @@ func (s *storageZfs) copyWithoutSnapshotsSparse(target container, source contain
 		}()
 
 		bwlimit := s.pool.Config["rsync.bwlimit"]
-		output, err := rsyncLocalCopy(sourceContainerPath, targetContainerPath, bwlimit)
+		output, err := rsyncLocalCopy(sourceContainerPath, targetContainerPath, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 		if err != nil {
 			return fmt.Errorf("rsync failed: %s", string(output))
 		}
@@ -1021,7 +1051,10 @@ func (s *storageZfs) copyWithoutSnapshotFull(target container, source container)
 		}()
 	}
 
-	zfsSendCmd := exec.Command("zfs", "send", sourceDataset)
+	args := []string{"send"}
+	args = append(args, s.zfsSendFlags()...)
+	args = append(args, sourceDataset)
+	zfsSendCmd := exec.Command("zfs", args...)
 
 	zfsRecvCmd := exec.Command("zfs", "receive", targetDataset)
 
@@ -1099,7 +1132,9 @@ func (s *storageZfs) copyWithSnapshots(target container, source container, paren
 	poolName := s.getOnDiskPoolName()
 	sourceParentName, sourceSnapOnlyName, _ := containerGetParentAndSnapshotName(sourceName)
 	currentSnapshotDataset := fmt.Sprintf("%s/containers/%s@snapshot-%s", poolName, sourceParentName, sourceSnapOnlyName)
-	args := []string{"send", currentSnapshotDataset}
+	args := []string{"send"}
+	args = append(args, s.zfsSendFlags()...)
+	args = append(args, currentSnapshotDataset)
 	if parentSnapshot != "" {
 		parentName, parentSnaponlyName, _ := containerGetParentAndSnapshotName(parentSnapshot)
 		parentSnapshotDataset := fmt.Sprintf("%s/containers/%s@snapshot-%s", poolName, parentName, parentSnaponlyName)
@@ -1179,7 +1214,7 @@ func (s *storageZfs) doCrossPoolContainerCopy(target container, source container
 	if !containerOnly {
 		for _, snap := range snapshots {
 			srcSnapshotMntPoint := getSnapshotMountPoint(sourcePool, snap.Name())
-			_, err = rsyncLocalCopy(srcSnapshotMntPoint, destContainerMntPoint, bwlimit)
+			_, err = rsyncLocalCopy(srcSnapshotMntPoint, destContainerMntPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 			if err != nil {
 				logger.Errorf("Failed to rsync into ZFS storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
 				return err
@@ -1195,7 +1230,7 @@ func (s *storageZfs) doCrossPoolContainerCopy(target container, source container
 	}
 
 	srcContainerMntPoint := getContainerMountPoint(sourcePool, source.Name())
-	_, err = rsyncLocalCopy(srcContainerMntPoint, destContainerMntPoint, bwlimit)
+	_, err = rsyncLocalCopy(srcContainerMntPoint, destContainerMntPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 	if err != nil {
 		logger.Errorf("Failed to rsync into ZFS storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
 		return err
@@ -1283,7 +1318,9 @@ func (s *storageZfs) ContainerCopy(target container, source container, container
 		}
 
 		currentSnapshotDataset := fmt.Sprintf("%s/containers/%s@%s", poolName, source.Name(), tmpSnapshotName)
-		args := []string{"send", currentSnapshotDataset}
+		args := []string{"send"}
+		args = append(args, s.zfsSendFlags()...)
+		args = append(args, currentSnapshotDataset)
 		if prevSnapOnlyName != "" {
 			parentSnapshotDataset := fmt.Sprintf("%s/containers/%s@snapshot-%s", poolName, source.Name(), prevSnapOnlyName)
 			args = append(args, "-i", parentSnapshotDataset)
@@ -1939,7 +1976,7 @@ func (s *storageZfs) doContainerBackupCreateVanilla(backup backup, source contai
 	}
 
 	rsync := func(oldPath string, newPath string, bwlimit string) error {
-		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit)
+		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 		if err != nil {
 			s.ContainerBackupDelete(backup.Name())
 			return fmt.Errorf("failed to rsync: %s: %s", string(output), err)
@@ -2473,7 +2510,9 @@ func (s *zfsMigrationSourceDriver) Snapshots() []container {
 func (s *zfsMigrationSourceDriver) send(conn *websocket.Conn, zfsName string, zfsParent string, readWrapper func(io.ReadCloser) io.ReadCloser) error {
 	sourceParentName, _, _ := containerGetParentAndSnapshotName(s.container.Name())
 	poolName := s.zfs.getOnDiskPoolName()
-	args := []string{"send", fmt.Sprintf("%s/containers/%s@%s", poolName, sourceParentName, zfsName)}
+	args := []string{"send"}
+	args = append(args, s.zfs.zfsSendFlags()...)
+	args = append(args, fmt.Sprintf("%s/containers/%s@%s", poolName, sourceParentName, zfsName))
 	if zfsParent != "" {
 		args = append(args, "-i", fmt.Sprintf("%s/containers/%s@%s", poolName, s.container.Name(), zfsParent))
 	}
@@ -2499,7 +2538,7 @@ func (s *zfsMigrationSourceDriver) send(conn *websocket.Conn, zfsName string, zf
 		return err
 	}
 
-	<-shared.WebsocketSendStream(conn, readPipe, 4*1024*1024)
+	streamErr := migrationSendStream(conn, readPipe)
 
 	output, err := ioutil.ReadAll(stderr)
 	if err != nil {
@@ -2509,9 +2548,10 @@ func (s *zfsMigrationSourceDriver) send(conn *websocket.Conn, zfsName string, zf
 	err = cmd.Wait()
 	if err != nil {
 		logger.Errorf("Problem with zfs send: %s", string(output))
+		return err
 	}
 
-	return err
+	return streamErr
 }
 
 func (s *zfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, containerOnly bool) error {
@@ -2660,7 +2700,7 @@ func (s *storageZfs) MigrationSink(live bool, container container, snapshots []*
 			writePipe = writeWrapper(stdin)
 		}
 
-		<-shared.WebsocketRecvStream(writePipe, conn)
+		streamErr := migrationRecvStream(writePipe, conn)
 
 		output, err := ioutil.ReadAll(stderr)
 		if err != nil {
@@ -2670,8 +2710,9 @@ func (s *storageZfs) MigrationSink(live bool, container container, snapshots []*
 		err = cmd.Wait()
 		if err != nil {
 			logger.Errorf("Problem with zfs recv: %s", string(output))
+			return err
 		}
-		return err
+		return streamErr
 	}
 
 	/* In some versions of zfs we can write `zfs recv -F` to mounted
@@ -2821,6 +2862,31 @@ func (s *storageZfs) StorageEntitySetQuota(volumeType int, size int64, data inte
 	}
 
 	poolName := s.getOnDiskPoolName()
+
+	// The size of block volumes is the one of their zvol
+	if volumeType == storagePoolVolumeTypeCustom && storagePoolVolumeIsBlock(s.volume.Config) {
+		oldSize, err := shared.ParseByteSizeString(s.volume.Config["size"])
+		if err != nil {
+			return err
+		}
+
+		if size == 0 || size == oldSize {
+			return nil
+		}
+
+		if size < oldSize {
+			return fmt.Errorf("Block storage volumes can't be shrunk")
+		}
+
+		err = zfsPoolVolumeSet(poolName, fs, "volsize", fmt.Sprintf("%d", size))
+		if err != nil {
+			return err
+		}
+
+		logger.Debugf(`Set ZFS quota for "%s"`, s.volume.Name)
+		return nil
+	}
+
 	var err error
 	if size > 0 {
 		err = zfsPoolVolumeSet(poolName, fs, property, fmt.Sprintf("%d", size))
@@ -2963,7 +3029,7 @@ func (s *storageZfs) StoragePoolVolumeCopy(source *api.StorageVolumeSource) erro
 	}
 
 	bwlimit := s.pool.Config["rsync.bwlimit"]
-	_, err = rsyncLocalCopy(srcMountPoint, dstMountPoint, bwlimit)
+	_, err = rsyncLocalCopy(srcMountPoint, dstMountPoint, bwlimit, rsyncPoolArgs(s.pool.Config)...)
 	if err != nil {
 		os.RemoveAll(dstMountPoint)
 		logger.Errorf("Failed to rsync into ZFS storage volume \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
@@ -2996,6 +3062,14 @@ func (s *storageZfs) GetStoragePoolVolume() *api.StorageVolume {
 	return s.volume
 }
 
+func (s *storageZfs) StoragePoolVolumeBlockPath() (string, error) {
+	if !storagePoolVolumeIsBlock(s.volume.Config) {
+		return "", fmt.Errorf("Storage volume \"%s\" isn't a block volume", s.volume.Name)
+	}
+
+	return filepath.Join("/dev/zvol", s.getOnDiskPoolName(), "custom", s.volume.Name), nil
+}
+
 func (s *storageZfs) GetState() *state.State {
 	return s.s
 }
@@ -49,6 +49,11 @@ func zfsPoolVolumeCreate(dataset string, properties ...string) (string, error) {
 	return shared.RunCommand(cmd[0], cmd[1:]...)
 }
 
+// Create a sparse zvol of the given size, in bytes.
+func zfsPoolVolumeBlockCreate(dataset string, size int64) (string, error) {
+	return shared.RunCommand("zfs", "create", "-s", "-V", fmt.Sprintf("%d", size), "-p", dataset)
+}
+
 func zfsPoolCheck(pool string) error {
 	output, err := shared.RunCommand(
 		"zfs", "get", "-H", "-o", "value", "type", pool)
@@ -581,6 +586,31 @@ func zfsFilesystemEntityExists(pool string, path string) bool {
 	return detectedName == vdev
 }
 
+// zfsContainerDelegate sets or clears the zoned property on the dataset of
+// the given container, marking it as managed from within the container.
+func (s *storageZfs) zfsContainerDelegate(name string, delegate bool) error {
+	poolName := s.getOnDiskPoolName()
+	fs := fmt.Sprintf("containers/%s", name)
+
+	value := "off"
+	if delegate {
+		value = "on"
+	}
+
+	current, err := zfsFilesystemEntityPropertyGet(poolName, fs, "zoned")
+	if err == nil && current == value {
+		return nil
+	}
+
+	return zfsPoolVolumeSet(poolName, fs, "zoned", value)
+}
+
+// zfsSendFlags returns the extra flags to pass to "zfs send" when copying
+// or migrating datasets of this pool.
+func (s *storageZfs) zfsSendFlags() []string {
+	return strings.Fields(s.pool.Config["zfs.send_flags"])
+}
+
 func (s *storageZfs) doContainerMount(name string, privileged bool) (bool, error) {
 	logger.Debugf("Mounting ZFS storage volume for container \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
 
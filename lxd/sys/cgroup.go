@@ -2,7 +2,11 @@ package sys
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -19,7 +23,17 @@ func (s *OS) initCGroup() {
 		&s.CGroupNetPrioController,
 		&s.CGroupPidsController,
 		&s.CGroupSwapAccounting,
+		&s.CGroupBlkioWeight,
 	}
+
+	// On the unified hierarchy, the available controllers are listed at
+	// its root.
+	if shared.PathExists("/sys/fs/cgroup/cgroup.controllers") {
+		s.CGroupUnified = true
+		s.initCGroupUnified()
+		return
+	}
+
 	for i, flag := range flags {
 		*flag = shared.PathExists("/sys/fs/cgroup/" + cGroups[i].path)
 		if !*flag {
@@ -28,6 +42,63 @@ func (s *OS) initCGroup() {
 	}
 }
 
+// Detect CGroup support on the unified hierarchy.
+func (s *OS) initCGroupUnified() {
+	content, err := ioutil.ReadFile("/sys/fs/cgroup/cgroup.controllers")
+	if err != nil {
+		logger.Warnf("Couldn't read the unified CGroup controllers: %v", err)
+		return
+	}
+
+	controllers := strings.Fields(string(content))
+
+	flags := []*bool{
+		&s.CGroupCPUController,
+		&s.CGroupCPUsetController,
+		&s.CGroupBlkioController,
+		&s.CGroupMemoryController,
+		&s.CGroupPidsController,
+	}
+
+	for i, flag := range flags {
+		*flag = shared.StringInSlice(cGroupsUnified[i].name, controllers)
+		if !*flag {
+			logger.Warnf(cGroupsUnified[i].warn)
+		}
+	}
+
+	// CPU usage is always accounted for in cpu.stat
+	s.CGroupCPUacctController = true
+
+	// Device access is filtered through eBPF programs rather than through
+	// a controller, which liblxc supports since 4.0.
+	s.CGroupDevicesController = util.RuntimeLiblxcVersionAtLeast(4, 0, 0)
+	if !s.CGroupDevicesController {
+		logger.Warnf(cGroupMissing("devices controller", "device access control won't work"))
+	}
+
+	// Network priorities have no equivalent on the unified hierarchy
+	s.CGroupNetPrioController = false
+	logger.Warnf(cGroupMissing("network class controller", "network limits will be ignored"))
+
+	// Swap accounting and I/O weights show up in the child CGroups only
+	if s.CGroupMemoryController {
+		matches, _ := filepath.Glob("/sys/fs/cgroup/*/memory.swap.max")
+		s.CGroupSwapAccounting = len(matches) > 0
+		if !s.CGroupSwapAccounting {
+			logger.Warnf(cGroupDisabled("memory swap accounting", "swap limits will be ignored"))
+		}
+	}
+
+	if s.CGroupBlkioController {
+		matches, _ := filepath.Glob("/sys/fs/cgroup/*/io.weight")
+		s.CGroupBlkioWeight = len(matches) > 0
+		if !s.CGroupBlkioWeight {
+			logger.Warnf(cGroupDisabled("io.weight", "I/O priorities will be ignored"))
+		}
+	}
+}
+
 func cGroupMissing(name, message string) string {
 	return fmt.Sprintf("Couldn't find the CGroup %s, %s.", name, message)
 }
@@ -49,4 +120,16 @@ var cGroups = []struct {
 	{"net_prio", cGroupMissing("network class controller", "network limits will be ignored")},
 	{"pids", cGroupMissing("pids controller", "process limits will be ignored")},
 	{"memory/memory.memsw.limit_in_bytes", cGroupDisabled("memory swap accounting", "swap limits will be ignored")},
+	{"blkio/blkio.weight", cGroupDisabled("blkio.weight", "I/O priorities will be ignored")},
+}
+
+var cGroupsUnified = []struct {
+	name string
+	warn string
+}{
+	{"cpu", cGroupMissing("CPU controller", "CPU time limits will be ignored")},
+	{"cpuset", cGroupMissing("CPUset controller", "CPU pinning will be ignored")},
+	{"io", cGroupMissing("io controller", "I/O limits will be ignored")},
+	{"memory", cGroupMissing("memory controller", "memory limits will be ignored")},
+	{"pids", cGroupMissing("pids controller", "process limits will be ignored")},
 }
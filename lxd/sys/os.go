@@ -48,6 +48,7 @@ type OS struct {
 	AppArmorAdmin           bool
 	AppArmorConfined        bool
 	CGroupBlkioController   bool
+	CGroupBlkioWeight       bool
 	CGroupCPUController     bool
 	CGroupCPUacctController bool
 	CGroupCPUsetController  bool
@@ -56,7 +57,9 @@ type OS struct {
 	CGroupNetPrioController bool
 	CGroupPidsController    bool
 	CGroupSwapAccounting    bool
+	CGroupUnified           bool // Whether the host uses the unified (v2) CGroup hierarchy
 	InotifyWatch            InotifyInfo
+	Shiftfs                 bool // Whether shiftfs is available for shifted storage volumes
 
 	MockMode bool // If true some APIs will be mocked (for testing)
 }
@@ -99,5 +102,9 @@ func (s *OS) Init() error {
 	s.initAppArmor()
 	s.initCGroup()
 
+	// shiftfs may be built as a module
+	util.LoadModule("shiftfs")
+	s.Shiftfs = util.HasFilesystem("shiftfs")
+
 	return nil
 }
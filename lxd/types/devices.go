@@ -25,11 +25,12 @@ func (list Devices) Contains(k string, d Device) bool {
 	return deviceEquals(old, d)
 }
 
-// Update returns the difference between two sets
-func (list Devices) Update(newlist Devices) (map[string]Device, map[string]Device, map[string]Device, []string) {
-	rmlist := map[string]Device{}
-	addlist := map[string]Device{}
-	updatelist := map[string]Device{}
+// Update returns the difference between two sets, along with the keys which
+// changed on each of the modified devices
+func (list Devices) Update(newlist Devices) (Devices, Devices, Devices, map[string][]string) {
+	rmlist := Devices{}
+	addlist := Devices{}
+	updatelist := Devices{}
 
 	for key, d := range list {
 		if !newlist.Contains(key, d) {
@@ -43,7 +44,7 @@ func (list Devices) Update(newlist Devices) (map[string]Device, map[string]Devic
 		}
 	}
 
-	updateDiff := []string{}
+	updateDiff := map[string][]string{}
 	for key, d := range addlist {
 		srcOldDevice := rmlist[key]
 		var oldDevice Device
@@ -59,9 +60,9 @@ func (list Devices) Update(newlist Devices) (map[string]Device, map[string]Devic
 			continue
 		}
 
-		updateDiff = deviceEqualsDiffKeys(oldDevice, newDevice)
+		updateDiff[key] = deviceEqualsDiffKeys(oldDevice, newDevice)
 
-		for _, k := range []string{"limits.max", "limits.read", "limits.write", "limits.egress", "limits.ingress", "ipv4.address", "ipv6.address"} {
+		for _, k := range []string{"limits.max", "limits.read", "limits.write", "limits.egress", "limits.ingress", "ipv4.address", "ipv6.address", "security.port_isolation"} {
 			delete(oldDevice, k)
 			delete(newDevice, k)
 		}
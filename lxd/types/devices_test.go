@@ -2,6 +2,7 @@ package types
 
 import (
 	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -20,3 +21,39 @@ func TestSortableDevices(t *testing.T) {
 		t.Error("devices sorted incorrectly")
 	}
 }
+
+func TestDevicesUpdate(t *testing.T) {
+	old := Devices{
+		"eth0": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+		"eth1": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr1", "limits.max": "10Mbit"},
+		"eth2": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr2"},
+	}
+
+	newDevices := Devices{
+		"eth0": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "limits.ingress": "1Mbit"},
+		"eth1": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr1", "security.port_isolation": "true"},
+		"eth2": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr3"},
+	}
+
+	rmlist, addlist, updatelist, updateDiff := old.Update(newDevices)
+
+	// Limit changes are applied live, other changes recreate the device
+	if len(rmlist) != 1 || len(addlist) != 1 || rmlist["eth2"] == nil || addlist["eth2"] == nil {
+		t.Errorf("unexpected removed or added devices: %v %v", rmlist, addlist)
+	}
+
+	if len(updatelist) != 2 || updatelist["eth0"] == nil || updatelist["eth1"] == nil {
+		t.Errorf("unexpected updated devices: %v", updatelist)
+	}
+
+	// Each device gets its own list of changed keys
+	if !reflect.DeepEqual(updateDiff["eth0"], []string{"limits.ingress"}) {
+		t.Errorf("unexpected changes for eth0: %v", updateDiff["eth0"])
+	}
+
+	diff := updateDiff["eth1"]
+	sort.Strings(diff)
+	if !reflect.DeepEqual(diff, []string{"limits.max", "security.port_isolation"}) {
+		t.Errorf("unexpected changes for eth1: %v", diff)
+	}
+}
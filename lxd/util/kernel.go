@@ -1,7 +1,10 @@
 package util
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/lxc/lxd/shared"
 )
@@ -16,3 +19,23 @@ func LoadModule(module string) error {
 	_, err := shared.RunCommand("modprobe", module)
 	return err
 }
+
+// HasFilesystem returns whether the running kernel supports the filesystem
+// with the given name, as listed in /proc/filesystems.
+func HasFilesystem(name string) bool {
+	f, err := os.Open("/proc/filesystems")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[len(fields)-1] == name {
+			return true
+		}
+	}
+
+	return false
+}
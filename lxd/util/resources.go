@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
 )
 
 type thread struct {
@@ -255,9 +257,99 @@ func CPUResource() (*api.ResourcesCPU, error) {
 		cur.FrequencyTurbo = v.frequencyTurbo
 	}
 
+	c.Flags, err = cpuFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Vulnerabilities, err = cpuVulnerabilities()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Virtualization = cpuVirtualization(c.Flags)
+
 	return &c, nil
 }
 
+// Get the flags of the CPU, as listed for its first thread in /proc/cpuinfo.
+// They're called "Features" on ARM.
+func cpuFlags() ([]string, error) {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "flags") && !strings.HasPrefix(line, "Features") {
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+
+		return strings.Fields(line[i+1:]), nil
+	}
+
+	return []string{}, scanner.Err()
+}
+
+// Get the state of the mitigations of the CPU vulnerabilities known to the
+// kernel, by vulnerability.
+func cpuVulnerabilities() (map[string]string, error) {
+	path := "/sys/devices/system/cpu/vulnerabilities"
+
+	ents, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	vulnerabilities := map[string]string{}
+	for _, ent := range ents {
+		value, err := readSysfsValue(filepath.Join(path, ent.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		vulnerabilities[ent.Name()] = value
+	}
+
+	return vulnerabilities, nil
+}
+
+// Get the hardware virtualization support of the system out of the CPU flags
+// and the kvm modules.
+func cpuVirtualization(flags []string) api.ResourcesCPUVirtualization {
+	virt := api.ResourcesCPUVirtualization{}
+
+	module := ""
+	if shared.StringInSlice("vmx", flags) {
+		virt.Type = "vmx"
+		module = "kvm_intel"
+	} else if shared.StringInSlice("svm", flags) {
+		virt.Type = "svm"
+		module = "kvm_amd"
+	}
+
+	virt.KVM = shared.PathExists("/dev/kvm")
+
+	if module != "" {
+		nested, err := readSysfsValue(fmt.Sprintf("/sys/module/%s/parameters/nested", module))
+		virt.Nested = err == nil && (nested == "Y" || nested == "1")
+	}
+
+	return virt
+}
+
 // MemoryResource returns the system memory information
 func MemoryResource() (*api.ResourcesMemory, error) {
 	var buffers uint64
@@ -346,5 +438,524 @@ func MemoryResource() (*api.ResourcesMemory, error) {
 	mem.Total = total * 1024
 	mem.Used = (total - free - cached - buffers) * 1024
 
+	mem.Hugepages, err = hugepagesResource("/sys/kernel/mm/hugepages")
+	if err != nil {
+		return nil, err
+	}
+
 	return &mem, err
 }
+
+const sysBusPCI = "/sys/bus/pci/devices"
+const sysBusUSB = "/sys/bus/usb/devices"
+const sysClassNet = "/sys/class/net"
+const sysDevicesNode = "/sys/devices/system/node"
+
+// Read a single value out of a sysfs file.
+func readSysfsValue(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// GPUResource returns the GPUs of the system, that is the PCI display
+// controllers
+func GPUResource() (*api.ResourcesGPU, error) {
+	return gpuResource(sysBusPCI)
+}
+
+// Get the GPUs of the given sysfs PCI devices directory. A device which
+// can't be read, e.g. because it's going away, is left out.
+func gpuResource(path string) (*api.ResourcesGPU, error) {
+	gpu := api.ResourcesGPU{Cards: []api.ResourcesGPUCard{}}
+
+	ents, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &gpu, nil
+		}
+
+		return nil, err
+	}
+
+	for _, ent := range ents {
+		devPath := filepath.Join(path, ent.Name())
+
+		// Display controllers are of PCI class 0x03
+		class, err := readSysfsValue(filepath.Join(devPath, "class"))
+		if err != nil || !strings.HasPrefix(class, "0x03") {
+			continue
+		}
+
+		card, err := gpuCard(devPath)
+		if err != nil {
+			logger.Warnf("Failed to read GPU %s: %v", ent.Name(), err)
+			continue
+		}
+
+		gpu.Cards = append(gpu.Cards, *card)
+	}
+
+	gpu.Total = uint64(len(gpu.Cards))
+
+	return &gpu, nil
+}
+
+// Get the details of the display controller at the given sysfs path.
+func gpuCard(devPath string) (*api.ResourcesGPUCard, error) {
+	vendorID, err := readSysfsValue(filepath.Join(devPath, "vendor"))
+	if err != nil {
+		return nil, err
+	}
+
+	productID, err := readSysfsValue(filepath.Join(devPath, "device"))
+	if err != nil {
+		return nil, err
+	}
+
+	card := api.ResourcesGPUCard{
+		PCIAddress: filepath.Base(devPath),
+		VendorID:   strings.TrimPrefix(vendorID, "0x"),
+		ProductID:  strings.TrimPrefix(productID, "0x"),
+	}
+
+	driver, err := os.Readlink(filepath.Join(devPath, "driver"))
+	if err == nil {
+		card.Driver = filepath.Base(driver)
+	}
+
+	drmEnts, err := ioutil.ReadDir(filepath.Join(devPath, "drm"))
+	if err == nil {
+		for _, drmEnt := range drmEnts {
+			if strings.HasPrefix(drmEnt.Name(), "card") {
+				card.ID = strings.TrimPrefix(drmEnt.Name(), "card")
+			}
+		}
+	}
+
+	card.Mdev, err = gpuMdevTypes(devPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &card, nil
+}
+
+// Get the mediated device types supported by a PCI device, if any.
+func gpuMdevTypes(devPath string) (map[string]api.ResourcesGPUCardMdev, error) {
+	typesPath := filepath.Join(devPath, "mdev_supported_types")
+
+	ents, err := ioutil.ReadDir(typesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	types := map[string]api.ResourcesGPUCardMdev{}
+	for _, ent := range ents {
+		typePath := filepath.Join(typesPath, ent.Name())
+
+		deviceAPI, err := readSysfsValue(filepath.Join(typePath, "device_api"))
+		if err != nil {
+			return nil, err
+		}
+
+		available, err := shared.ParseNumberFromFile(filepath.Join(typePath, "available_instances"))
+		if err != nil {
+			return nil, err
+		}
+
+		mdev := api.ResourcesGPUCardMdev{
+			API:       deviceAPI,
+			Available: uint64(available),
+		}
+
+		// The name and description are optional
+		mdev.Name, _ = readSysfsValue(filepath.Join(typePath, "name"))
+		mdev.Description, _ = readSysfsValue(filepath.Join(typePath, "description"))
+
+		types[ent.Name()] = mdev
+	}
+
+	return types, nil
+}
+
+// USBResource returns the USB devices of the system, leaving out the root
+// hubs
+func USBResource() (*api.ResourcesUSB, error) {
+	return usbResource(sysBusUSB)
+}
+
+// Get the USB devices of the given sysfs USB devices directory. A device
+// which can't be read, e.g. because it was just unplugged, is left out.
+func usbResource(path string) (*api.ResourcesUSB, error) {
+	usb := api.ResourcesUSB{Devices: []api.ResourcesUSBDevice{}}
+
+	ents, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &usb, nil
+		}
+
+		return nil, err
+	}
+
+	for _, ent := range ents {
+		devPath := filepath.Join(path, ent.Name())
+
+		// Interfaces don't have IDs of their own, and root hubs are
+		// named after their bus
+		if strings.HasPrefix(ent.Name(), "usb") || !shared.PathExists(filepath.Join(devPath, "idVendor")) {
+			continue
+		}
+
+		device, err := usbDevice(devPath)
+		if err != nil {
+			logger.Warnf("Failed to read USB device %s: %v", ent.Name(), err)
+			continue
+		}
+
+		usb.Devices = append(usb.Devices, *device)
+	}
+
+	usb.Total = uint64(len(usb.Devices))
+
+	return &usb, nil
+}
+
+// Get the details of the USB device at the given sysfs path.
+func usbDevice(devPath string) (*api.ResourcesUSBDevice, error) {
+	busNum, err := shared.ParseNumberFromFile(filepath.Join(devPath, "busnum"))
+	if err != nil {
+		return nil, err
+	}
+
+	devNum, err := shared.ParseNumberFromFile(filepath.Join(devPath, "devnum"))
+	if err != nil {
+		return nil, err
+	}
+
+	vendorID, err := readSysfsValue(filepath.Join(devPath, "idVendor"))
+	if err != nil {
+		return nil, err
+	}
+
+	productID, err := readSysfsValue(filepath.Join(devPath, "idProduct"))
+	if err != nil {
+		return nil, err
+	}
+
+	device := api.ResourcesUSBDevice{
+		BusAddress:    uint64(busNum),
+		DeviceAddress: uint64(devNum),
+		VendorID:      vendorID,
+		ProductID:     productID,
+	}
+
+	// The manufacturer and product strings are optional
+	device.Vendor, _ = readSysfsValue(filepath.Join(devPath, "manufacturer"))
+	device.Product, _ = readSysfsValue(filepath.Join(devPath, "product"))
+
+	return &device, nil
+}
+
+// NetworkResource returns the physical network interfaces of the system,
+// along with their SR-IOV capabilities
+func NetworkResource() (*api.ResourcesNetwork, error) {
+	return networkResource(sysClassNet)
+}
+
+// Get the physical network interfaces of the given sysfs network class
+// directory. An interface which can't be read, e.g. because it's being
+// renamed or moved to another network namespace, is left out.
+func networkResource(path string) (*api.ResourcesNetwork, error) {
+	network := api.ResourcesNetwork{Cards: []api.ResourcesNetworkCard{}}
+
+	ents, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &network, nil
+		}
+
+		return nil, err
+	}
+
+	for _, ent := range ents {
+		netPath := filepath.Join(path, ent.Name())
+
+		// Virtual interfaces aren't backed by a device
+		devPath := filepath.Join(netPath, "device")
+		if !shared.PathExists(devPath) {
+			continue
+		}
+
+		// Leave out SR-IOV virtual functions, they're accounted for on
+		// their physical function
+		if shared.PathExists(filepath.Join(devPath, "physfn")) {
+			continue
+		}
+
+		card, err := networkCard(netPath)
+		if err != nil {
+			logger.Warnf("Failed to read network interface %s: %v", ent.Name(), err)
+			continue
+		}
+
+		network.Cards = append(network.Cards, *card)
+	}
+
+	network.Total = uint64(len(network.Cards))
+
+	return &network, nil
+}
+
+// Get the details of the network interface at the given sysfs path.
+func networkCard(netPath string) (*api.ResourcesNetworkCard, error) {
+	devPath := filepath.Join(netPath, "device")
+
+	address, err := readSysfsValue(filepath.Join(netPath, "address"))
+	if err != nil {
+		return nil, err
+	}
+
+	mtu, err := shared.ParseNumberFromFile(filepath.Join(netPath, "mtu"))
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := readSysfsValue(filepath.Join(netPath, "operstate"))
+	if err != nil {
+		return nil, err
+	}
+
+	card := api.ResourcesNetworkCard{
+		Name:    filepath.Base(netPath),
+		Address: address,
+		MTU:     uint64(mtu),
+		State:   state,
+	}
+
+	driver, err := os.Readlink(filepath.Join(devPath, "driver"))
+	if err == nil {
+		card.Driver = filepath.Base(driver)
+	}
+
+	subsystem, err := os.Readlink(filepath.Join(devPath, "subsystem"))
+	if err == nil && filepath.Base(subsystem) == "pci" {
+		device, err := filepath.EvalSymlinks(devPath)
+		if err == nil {
+			card.PCIAddress = filepath.Base(device)
+		}
+	}
+
+	master, err := os.Readlink(filepath.Join(netPath, "master"))
+	if err == nil && shared.PathExists(filepath.Join(filepath.Dir(netPath), filepath.Base(master), "bridge")) {
+		card.Bridge = filepath.Base(master)
+	}
+
+	card.SRIOV, err = networkSRIOV(devPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &card, nil
+}
+
+// Get the SR-IOV virtual functions of a network device, if it supports them.
+// Enabled virtual functions whose interface left the host, usually for the
+// network namespace of a container, are in use.
+func networkSRIOV(devPath string) (*api.ResourcesNetworkCardSRIOV, error) {
+	totalPath := filepath.Join(devPath, "sriov_totalvfs")
+	if !shared.PathExists(totalPath) {
+		return nil, nil
+	}
+
+	total, err := shared.ParseNumberFromFile(totalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := shared.ParseNumberFromFile(filepath.Join(devPath, "sriov_numvfs"))
+	if err != nil {
+		return nil, err
+	}
+
+	available := total - current
+	for i := int64(0); i < current; i++ {
+		empty, err := shared.PathIsEmpty(filepath.Join(devPath, fmt.Sprintf("virtfn%d", i), "net"))
+		if err == nil && !empty {
+			available++
+		}
+	}
+
+	return &api.ResourcesNetworkCardSRIOV{
+		CurrentVFs:   uint64(current),
+		MaximumVFs:   uint64(total),
+		AvailableVFs: uint64(available),
+	}, nil
+}
+
+// Get the hugepages configured in a sysfs hugepages directory, by page size.
+func hugepagesResource(path string) ([]api.ResourcesMemoryHugepages, error) {
+	ents, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	hugepages := []api.ResourcesMemoryHugepages{}
+	for _, ent := range ents {
+		// Directories are named hugepages-<size>kB
+		size, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(ent.Name(), "hugepages-"), "kB"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		total, err := shared.ParseNumberFromFile(filepath.Join(path, ent.Name(), "nr_hugepages"))
+		if err != nil {
+			return nil, err
+		}
+
+		free, err := shared.ParseNumberFromFile(filepath.Join(path, ent.Name(), "free_hugepages"))
+		if err != nil {
+			return nil, err
+		}
+
+		hugepages = append(hugepages, api.ResourcesMemoryHugepages{
+			Size:  size * 1024,
+			Total: uint64(total),
+			Free:  uint64(free),
+		})
+	}
+
+	return hugepages, nil
+}
+
+// NUMAResource returns the CPUs, memory and hugepages of each NUMA node of
+// the system
+func NUMAResource() (*api.ResourcesNUMA, error) {
+	numa := api.ResourcesNUMA{Nodes: []api.ResourcesNUMANode{}}
+
+	ents, err := ioutil.ReadDir(sysDevicesNode)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &numa, nil
+		}
+
+		return nil, err
+	}
+
+	for _, ent := range ents {
+		if !strings.HasPrefix(ent.Name(), "node") {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimPrefix(ent.Name(), "node"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		nodePath := filepath.Join(sysDevicesNode, ent.Name())
+
+		cpuList, err := readSysfsValue(filepath.Join(nodePath, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+
+		cpus, err := parseCPUList(cpuList)
+		if err != nil {
+			return nil, err
+		}
+
+		memory, err := numaNodeMemory(nodePath)
+		if err != nil {
+			return nil, err
+		}
+
+		numa.Nodes = append(numa.Nodes, api.ResourcesNUMANode{
+			ID:     id,
+			CPUs:   cpus,
+			Memory: *memory,
+		})
+	}
+
+	numa.Total = uint64(len(numa.Nodes))
+
+	return &numa, nil
+}
+
+// Get the memory and hugepages of a NUMA node. The lines of its meminfo file
+// are prefixed with the node, e.g. "Node 0 MemTotal:  8056940 kB".
+func numaNodeMemory(nodePath string) (*api.ResourcesMemory, error) {
+	content, err := ioutil.ReadFile(filepath.Join(nodePath, "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]uint64{}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 || fields[4] != "kB" {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		values[strings.TrimSuffix(fields[2], ":")] = value
+	}
+
+	mem := api.ResourcesMemory{
+		Total: values["MemTotal"] * 1024,
+		Used:  (values["MemTotal"] - values["MemFree"] - values["FilePages"]) * 1024,
+	}
+
+	mem.Hugepages, err = hugepagesResource(filepath.Join(nodePath, "hugepages"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &mem, nil
+}
+
+// Parse a list of CPUs as found in sysfs, e.g. "0-3,8-11".
+func parseCPUList(list string) ([]uint64, error) {
+	cpus := []uint64{}
+	if list == "" {
+		return cpus, nil
+	}
+
+	for _, chunk := range strings.Split(list, ",") {
+		fields := strings.SplitN(chunk, "-", 2)
+
+		low, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CPU list: %s", list)
+		}
+
+		high := low
+		if len(fields) == 2 {
+			high, err = strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid CPU list: %s", list)
+			}
+		}
+
+		for i := low; i <= high; i++ {
+			cpus = append(cpus, i)
+		}
+	}
+
+	return cpus, nil
+}
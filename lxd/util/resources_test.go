@@ -0,0 +1,156 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("0-3,8,10-11")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 2, 3, 8, 10, 11}, cpus)
+
+	cpus, err = parseCPUList("")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{}, cpus)
+
+	_, err = parseCPUList("0-x")
+	assert.Error(t, err)
+}
+
+// The memory of a NUMA node is read from its meminfo file and hugepages
+// directory.
+func TestNUMANodeMemory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-util-numa-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	meminfo := `Node 0 MemTotal:        8056940 kB
+Node 0 MemFree:         2048000 kB
+Node 0 FilePages:       1024000 kB
+Node 0 HugePages_Total:     0
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "meminfo"), []byte(meminfo), 0644))
+
+	pages := filepath.Join(dir, "hugepages", "hugepages-2048kB")
+	require.NoError(t, os.MkdirAll(pages, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pages, "nr_hugepages"), []byte("16\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pages, "free_hugepages"), []byte("4\n"), 0644))
+
+	mem, err := numaNodeMemory(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(8056940*1024), mem.Total)
+	assert.Equal(t, uint64((8056940-2048000-1024000)*1024), mem.Used)
+	assert.Equal(t, []api.ResourcesMemoryHugepages{{Size: 2048 * 1024, Total: 16, Free: 4}}, mem.Hugepages)
+}
+
+// Write the given sysfs attributes under the given directory.
+func writeSysfs(t *testing.T, dir string, attrs map[string]string) {
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for name, value := range attrs {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644))
+	}
+}
+
+// Display controllers are listed, other PCI devices and unreadable display
+// controllers aren't.
+func TestGPUResource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-util-gpu-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeSysfs(t, filepath.Join(dir, "0000:00:02.0"), map[string]string{"class": "0x030000", "vendor": "0x8086", "device": "0x5917"})
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "0000:00:02.0", "drm", "card0"), 0755))
+	writeSysfs(t, filepath.Join(dir, "0000:00:1f.6"), map[string]string{"class": "0x020000", "vendor": "0x8086", "device": "0x15d7"})
+	writeSysfs(t, filepath.Join(dir, "0000:01:00.0"), map[string]string{"class": "0x030200"})
+
+	gpu, err := gpuResource(dir)
+	require.NoError(t, err)
+	require.Len(t, gpu.Cards, 1)
+	assert.Equal(t, uint64(1), gpu.Total)
+
+	card := gpu.Cards[0]
+	assert.Equal(t, "0000:00:02.0", card.PCIAddress)
+	assert.Equal(t, "8086", card.VendorID)
+	assert.Equal(t, "5917", card.ProductID)
+	assert.Equal(t, "0", card.ID)
+
+	gpu, err = gpuResource(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Len(t, gpu.Cards, 0)
+}
+
+// USB devices are listed, root hubs, interfaces and unreadable devices
+// aren't.
+func TestUSBResource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-util-usb-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeSysfs(t, filepath.Join(dir, "usb1"), map[string]string{"busnum": "1", "devnum": "1", "idVendor": "1d6b", "idProduct": "0002"})
+	writeSysfs(t, filepath.Join(dir, "1-1"), map[string]string{"busnum": "1", "devnum": "4", "idVendor": "046d", "idProduct": "c52b", "product": "USB Receiver"})
+	writeSysfs(t, filepath.Join(dir, "1-1:1.0"), map[string]string{"bInterfaceClass": "03"})
+	writeSysfs(t, filepath.Join(dir, "1-2"), map[string]string{"busnum": "1", "idVendor": "0781", "idProduct": "5581"})
+
+	usb, err := usbResource(dir)
+	require.NoError(t, err)
+	require.Len(t, usb.Devices, 1)
+	assert.Equal(t, uint64(1), usb.Total)
+
+	device := usb.Devices[0]
+	assert.Equal(t, uint64(1), device.BusAddress)
+	assert.Equal(t, uint64(4), device.DeviceAddress)
+	assert.Equal(t, "046d", device.VendorID)
+	assert.Equal(t, "c52b", device.ProductID)
+	assert.Equal(t, "USB Receiver", device.Product)
+	assert.Equal(t, "", device.Vendor)
+}
+
+// Physical interfaces are listed with their bridge and SR-IOV capabilities,
+// virtual and unreadable interfaces aren't.
+func TestNetworkResource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-util-net-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	devices := filepath.Join(dir, "devices")
+	class := filepath.Join(dir, "net")
+
+	// A physical interface in a bridge, with two of its eight virtual
+	// functions enabled and one of them in use
+	writeSysfs(t, filepath.Join(devices, "0000:03:00.0"), map[string]string{"sriov_totalvfs": "8", "sriov_numvfs": "2"})
+	writeSysfs(t, filepath.Join(devices, "0000:03:00.0", "virtfn0", "net", "enp3s0f0v0"), nil)
+	require.NoError(t, os.MkdirAll(filepath.Join(devices, "0000:03:00.0", "virtfn1", "net"), 0755))
+	writeSysfs(t, filepath.Join(class, "eth0"), map[string]string{"address": "00:16:3e:00:00:01", "mtu": "1500", "operstate": "up"})
+	require.NoError(t, os.Symlink(filepath.Join(devices, "0000:03:00.0"), filepath.Join(class, "eth0", "device")))
+	writeSysfs(t, filepath.Join(class, "br0", "bridge"), nil)
+	require.NoError(t, os.Symlink("../br0", filepath.Join(class, "eth0", "master")))
+
+	// An interface going away, with its attributes already gone
+	writeSysfs(t, filepath.Join(devices, "0000:04:00.0"), nil)
+	require.NoError(t, os.MkdirAll(filepath.Join(class, "eth1"), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(devices, "0000:04:00.0"), filepath.Join(class, "eth1", "device")))
+
+	// A virtual interface
+	writeSysfs(t, filepath.Join(class, "veth1234"), map[string]string{"address": "00:16:3e:00:00:02", "mtu": "1500", "operstate": "up"})
+
+	network, err := networkResource(class)
+	require.NoError(t, err)
+	require.Len(t, network.Cards, 1)
+	assert.Equal(t, uint64(1), network.Total)
+
+	card := network.Cards[0]
+	assert.Equal(t, "eth0", card.Name)
+	assert.Equal(t, "00:16:3e:00:00:01", card.Address)
+	assert.Equal(t, uint64(1500), card.MTU)
+	assert.Equal(t, "up", card.State)
+	assert.Equal(t, "br0", card.Bridge)
+	assert.Equal(t, &api.ResourcesNetworkCardSRIOV{CurrentVFs: 2, MaximumVFs: 8, AvailableVFs: 7}, card.SRIOV)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var warningsCmd = Command{
+	name: "warnings",
+	get:  warningsGet,
+}
+
+var warningCmd = Command{
+	name:   "warnings/{uuid}",
+	get:    warningGet,
+	put:    warningPut,
+	delete: warningDelete,
+}
+
+// Record an occurrence of the warning of the given type about the given
+// entity on this node. Failures are only logged, since warnings are raised
+// by code which is itself reporting a problem.
+func warningEmit(s *state.State, kind string, entity string, message string) {
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningUpsert(kind, entity, message)
+	})
+	if err != nil {
+		logger.Error("Failed to record warning", log.Ctx{"type": kind, "entity": entity, "err": err})
+	}
+}
+
+// Mark as resolved the warning of the given type about the given entity on
+// this node, if any.
+func warningResolve(s *state.State, kind string, entity string) {
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningResolve(kind, entity)
+	})
+	if err != nil {
+		logger.Error("Failed to resolve warning", log.Ctx{"type": kind, "entity": entity, "err": err})
+	}
+}
+
+// Convert a database warning to its API representation, with the name of
+// the node which raised it as location.
+func warningToAPI(warning db.Warning, names map[string]string) api.Warning {
+	return api.Warning{
+		WarningPut:  api.WarningPut{Status: warning.Status},
+		UUID:        warning.UUID,
+		Location:    names[warning.NodeAddress],
+		Type:        warning.Type,
+		Entity:      warning.Entity,
+		Count:       warning.Count,
+		FirstSeenAt: warning.FirstSeenAt,
+		LastSeenAt:  warning.LastSeenAt,
+		LastMessage: warning.LastMessage,
+	}
+}
+
+// Return the warnings matching the given UUID, or all of them if it's empty.
+func doWarningsGet(s *state.State, uuid string) ([]api.Warning, error) {
+	var warnings []db.Warning
+	names := map[string]string{}
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		nodes, err := tx.Nodes()
+		if err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			names[node.Address] = node.Name
+		}
+
+		if uuid == "" {
+			warnings, err = tx.Warnings()
+			return err
+		}
+
+		warning, err := tx.WarningByUUID(uuid)
+		if err != nil {
+			return err
+		}
+
+		warnings = []db.Warning{warning}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []api.Warning{}
+	for _, warning := range warnings {
+		result = append(result, warningToAPI(warning, names))
+	}
+
+	return result, nil
+}
+
+func warningsGet(d *Daemon, r *http.Request) Response {
+	warnings, err := doWarningsGet(d.State(), "")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if util.IsRecursionRequest(r) {
+		return SyncResponse(true, warnings)
+	}
+
+	urls := []string{}
+	for _, warning := range warnings {
+		urls = append(urls, fmt.Sprintf("/%s/warnings/%s", version.APIVersion, warning.UUID))
+	}
+
+	return SyncResponse(true, urls)
+}
+
+func warningGet(d *Daemon, r *http.Request) Response {
+	uuid := mux.Vars(r)["uuid"]
+
+	warnings, err := doWarningsGet(d.State(), uuid)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	warning := warnings[0]
+	return SyncResponseETag(true, warning, warning.WarningPut)
+}
+
+func warningPut(d *Daemon, r *http.Request) Response {
+	uuid := mux.Vars(r)["uuid"]
+
+	warnings, err := doWarningsGet(d.State(), uuid)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	err = util.EtagCheck(r, warnings[0].WarningPut)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.WarningPut{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	statuses := []string{db.WarningStatusNew, db.WarningStatusAcknowledged, db.WarningStatusResolved}
+	if !shared.StringInSlice(req.Status, statuses) {
+		return BadRequest(fmt.Errorf("Invalid warning status: %s", req.Status))
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningStatusSet(uuid, req.Status)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func warningDelete(d *Daemon, r *http.Request) Response {
+	uuid := mux.Vars(r)["uuid"]
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningDelete(uuid)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Warnings raised by a node can be listed, acknowledged and deleted.
+func TestWarnings(t *testing.T) {
+	daemon, cleanup := newDaemon(t)
+	defer cleanup()
+
+	f := clusterFixture{t: t}
+	client := f.ClientUnix(daemon)
+
+	warningEmit(daemon.State(), "certificate-expiry", "abcd", "Expires soon")
+	warningEmit(daemon.State(), "certificate-expiry", "abcd", "Expires sooner")
+
+	warnings, err := client.GetWarnings()
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	warning := warnings[0]
+	assert.Equal(t, "none", warning.Location)
+	assert.Equal(t, "certificate-expiry", warning.Type)
+	assert.Equal(t, 2, warning.Count)
+	assert.Equal(t, "Expires sooner", warning.LastMessage)
+	assert.Equal(t, "new", warning.Status)
+
+	err = client.UpdateWarning(warning.UUID, api.WarningPut{Status: "bogus"}, "")
+	assert.EqualError(t, err, "Invalid warning status: bogus")
+
+	require.NoError(t, client.UpdateWarning(warning.UUID, api.WarningPut{Status: "acknowledged"}, ""))
+	got, _, err := client.GetWarning(warning.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "acknowledged", got.Status)
+
+	warningResolve(daemon.State(), "certificate-expiry", "abcd")
+	got, _, err = client.GetWarning(warning.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "resolved", got.Status)
+
+	require.NoError(t, client.DeleteWarning(warning.UUID))
+	_, _, err = client.GetWarning(warning.UUID)
+	assert.Error(t, err)
+}
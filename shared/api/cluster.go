@@ -57,4 +57,33 @@ type ClusterMember struct {
 	Database   bool   `json:"database" yaml:"database"`
 	Status     string `json:"status" yaml:"status"`
 	Message    string `json:"message" yaml:"message"`
+
+	// API extension: cluster_upgrade
+	Schema        int `json:"schema" yaml:"schema"`
+	APIExtensions int `json:"api_extensions" yaml:"api_extensions"`
+}
+
+// ClusterUpgrade represents the progress of a rolling upgrade of the members
+// of a LXD cluster.
+//
+// API extension: cluster_upgrade
+type ClusterUpgrade struct {
+	Status  string `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+
+	// Most recent version found across the members
+	Schema        int `json:"schema" yaml:"schema"`
+	APIExtensions int `json:"api_extensions" yaml:"api_extensions"`
+
+	// Members still running an older version
+	Pending []string `json:"pending" yaml:"pending"`
+}
+
+// ClusterCertificatePut represents the certificate and key pair for all
+// members of a LXD cluster (or for the server, if it isn't clustered).
+//
+// API extension: certificate_rotation
+type ClusterCertificatePut struct {
+	ClusterCertificate    string `json:"cluster_certificate" yaml:"cluster_certificate"`
+	ClusterCertificateKey string `json:"cluster_certificate_key" yaml:"cluster_certificate_key"`
 }
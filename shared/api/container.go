@@ -53,6 +53,9 @@ type ContainerPut struct {
 	Restore  string `json:"restore,omitempty" yaml:"restore,omitempty"`
 	Stateful bool   `json:"stateful" yaml:"stateful"`
 
+	// API extension: container_snapshot_partial_restore
+	RestoreOnly string `json:"restore_only,omitempty" yaml:"restore_only,omitempty"`
+
 	// API extension: entity_description
 	Description string `json:"description" yaml:"description"`
 }
@@ -73,6 +76,10 @@ type Container struct {
 
 	// API extension: clustering
 	Location string `json:"location" yaml:"location"`
+
+	// API extension: storage_pool_availability
+	Availability       string `json:"availability" yaml:"availability"`
+	AvailabilityReason string `json:"availability_reason,omitempty" yaml:"availability_reason,omitempty"`
 }
 
 // Writable converts a full Container struct into a ContainerPut struct (filters read-only fields)
@@ -121,4 +128,43 @@ type ContainerSource struct {
 
 	// API extension: container_only_migration
 	ContainerOnly bool `json:"container_only,omitempty" yaml:"container_only,omitempty"`
+
+	// API extension: container_copy_regenerate_identity
+	RegenerateIdentity bool `json:"regenerate_identity,omitempty" yaml:"regenerate_identity,omitempty"`
+}
+
+// ContainerProvenance represents where each of the effective config keys and
+// devices of a LXD container comes from
+//
+// API extension: container_provenance
+type ContainerProvenance struct {
+	Config  map[string]ContainerProvenanceEntry `json:"config" yaml:"config"`
+	Devices map[string]ContainerProvenanceEntry `json:"devices" yaml:"devices"`
+}
+
+// ContainerProvenanceEntry represents the source of a config key or device,
+// either "local" or the URL of a profile, along with the sources it overrides
+// (in order of application)
+//
+// API extension: container_provenance
+type ContainerProvenanceEntry struct {
+	Source    string   `json:"source" yaml:"source"`
+	Overrides []string `json:"overrides" yaml:"overrides"`
+}
+
+// ContainerProfilesPut represents the profiles of a LXD container along with
+// their priorities
+//
+// API extension: container_profile_priorities
+type ContainerProfilesPut struct {
+	Profiles []ContainerProfilePriority `json:"profiles" yaml:"profiles"`
+}
+
+// ContainerProfilePriority represents a profile applied to a LXD container,
+// profiles being applied by increasing priority
+//
+// API extension: container_profile_priorities
+type ContainerProfilePriority struct {
+	Name     string `json:"name" yaml:"name"`
+	Priority int    `json:"priority" yaml:"priority"`
 }
@@ -14,4 +14,11 @@ type ContainerConsoleControl struct {
 type ContainerConsolePost struct {
 	Width  int `json:"width" yaml:"width"`
 	Height int `json:"height" yaml:"height"`
+
+	// The protocol to proxy over the websocket, "console" (text console)
+	// if empty. The graphical "spice" and "vnc" protocols are reserved for
+	// virtual machines.
+	//
+	// API extension: console_protocol
+	Protocol string `json:"protocol" yaml:"protocol"`
 }
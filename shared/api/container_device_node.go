@@ -0,0 +1,16 @@
+package api
+
+// ContainerDeviceNodesPost represents a request to create a transient device
+// node inside a running container
+//
+// API extension: container_device_nodes
+type ContainerDeviceNodesPost struct {
+	Type   string `json:"type" yaml:"type"`
+	Path   string `json:"path" yaml:"path"`
+	Source string `json:"source" yaml:"source"`
+	Major  int64  `json:"major" yaml:"major"`
+	Minor  int64  `json:"minor" yaml:"minor"`
+	Mode   string `json:"mode" yaml:"mode"`
+	UID    int64  `json:"uid" yaml:"uid"`
+	GID    int64  `json:"gid" yaml:"gid"`
+}
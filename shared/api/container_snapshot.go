@@ -8,6 +8,16 @@ import (
 type ContainerSnapshotsPost struct {
 	Name     string `json:"name" yaml:"name"`
 	Stateful bool   `json:"stateful" yaml:"stateful"`
+
+	// API extension: snapshot_image_protection
+	Protected bool `json:"protected" yaml:"protected"`
+}
+
+// ContainerSnapshotPut represents the modifiable fields of a LXD container snapshot
+//
+// API extension: snapshot_image_protection
+type ContainerSnapshotPut struct {
+	Protected bool `json:"protected" yaml:"protected"`
 }
 
 // ContainerSnapshotPost represents the fields required to rename/move a LXD container snapshot
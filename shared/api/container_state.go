@@ -1,11 +1,18 @@
 package api
 
+import (
+	"time"
+)
+
 // ContainerStatePut represents the modifiable fields of a LXD container's state
 type ContainerStatePut struct {
 	Action   string `json:"action" yaml:"action"`
 	Timeout  int    `json:"timeout" yaml:"timeout"`
 	Force    bool   `json:"force" yaml:"force"`
 	Stateful bool   `json:"stateful" yaml:"stateful"`
+
+	// API extension: container_freeze_duration
+	Duration int `json:"duration,omitempty" yaml:"duration,omitempty"`
 }
 
 // ContainerState represents a LXD container's state
@@ -20,6 +27,49 @@ type ContainerState struct {
 
 	// API extension: container_cpu_time
 	CPU ContainerStateCPU `json:"cpu" yaml:"cpu"`
+
+	// API extension: container_start_timings
+	StartTimings *ContainerStartTimings `json:"start_timings" yaml:"start_timings"`
+
+	// API extension: container_disk_weight
+	DiskWeight int64 `json:"disk_weight" yaml:"disk_weight"`
+
+	// API extension: container_healthcheck
+	Health *ContainerStateHealth `json:"health" yaml:"health"`
+}
+
+// ContainerStateHealth represents the result of the last health check of a
+// LXD container
+//
+// API extension: container_healthcheck
+type ContainerStateHealth struct {
+	// One of "starting", "healthy" or "unhealthy"
+	Status    string    `json:"status" yaml:"status"`
+	ExitCode  int       `json:"exit_code" yaml:"exit_code"`
+	Message   string    `json:"message" yaml:"message"`
+	CheckedAt time.Time `json:"checked_at" yaml:"checked_at"`
+
+	// API extension: container_healthcheck_action
+	Failures int `json:"failures" yaml:"failures"`
+}
+
+// ContainerStartTimings represents how long the last start of a LXD container
+// took, overall and in each of its phases (in milliseconds)
+//
+// API extension: container_start_timings
+type ContainerStartTimings struct {
+	StartedAt time.Time             `json:"started_at" yaml:"started_at"`
+	Total     int64                 `json:"total" yaml:"total"`
+	Phases    []ContainerStartPhase `json:"phases" yaml:"phases"`
+}
+
+// ContainerStartPhase represents the duration of one phase of a LXD container
+// start (in milliseconds)
+//
+// API extension: container_start_timings
+type ContainerStartPhase struct {
+	Name     string `json:"name" yaml:"name"`
+	Duration int64  `json:"duration" yaml:"duration"`
 }
 
 // ContainerStateDisk represents the disk information section of a LXD container's state
@@ -68,3 +118,22 @@ type ContainerStateNetworkCounters struct {
 	PacketsReceived int64 `json:"packets_received" yaml:"packets_received"`
 	PacketsSent     int64 `json:"packets_sent" yaml:"packets_sent"`
 }
+
+// ContainerUsage represents the recent resource usage history of a LXD container
+//
+// API extension: container_usage_history
+type ContainerUsage struct {
+	Interval int64                  `json:"interval" yaml:"interval"`
+	Samples  []ContainerUsageSample `json:"samples" yaml:"samples"`
+}
+
+// ContainerUsageSample represents a single resource usage sample of a LXD container
+//
+// API extension: container_usage_history
+type ContainerUsageSample struct {
+	Timestamp   time.Time `json:"timestamp" yaml:"timestamp"`
+	CPUUsage    int64     `json:"cpu_usage" yaml:"cpu_usage"`
+	MemoryUsage int64     `json:"memory_usage" yaml:"memory_usage"`
+	DiskUsage   int64     `json:"disk_usage" yaml:"disk_usage"`
+	Processes   int64     `json:"processes" yaml:"processes"`
+}
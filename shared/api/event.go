@@ -10,6 +10,10 @@ type Event struct {
 	Type      string          `yaml:"type" json:"type"`
 	Timestamp time.Time       `yaml:"timestamp" json:"timestamp"`
 	Metadata  json.RawMessage `yaml:"metadata" json:"metadata"`
+
+	// API extension: event_batching
+	Sequence  int64 `yaml:"sequence" json:"sequence"`
+	Coalesced int   `yaml:"coalesced,omitempty" json:"coalesced,omitempty"`
 }
 
 // EventLogging represents a logging type event entry (admin only)
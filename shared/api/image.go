@@ -28,12 +28,20 @@ type ImagesPostSource struct {
 	// For protocol "direct"
 	URL string `json:"url" yaml:"url"`
 
+	// API extension: image_url_checksum
+	SHA256 string `json:"sha256" yaml:"sha256"`
+
 	// For type "container"
 	Name string `json:"name" yaml:"name"`
 
 	// For type "image"
 	Fingerprint string `json:"fingerprint" yaml:"fingerprint"`
 	Secret      string `json:"secret" yaml:"secret"`
+
+	// For type "build", a YAML build definition
+	//
+	// API extension: images_build
+	Definition string `json:"definition" yaml:"definition"`
 }
 
 // ImagePut represents the modifiable fields of a LXD image
@@ -119,3 +127,21 @@ type ImageMetadataTemplate struct {
 	Template   string            `json:"template" yaml:"template"`
 	Properties map[string]string `json:"properties" yaml:"properties"`
 }
+
+// ImageRetentionPolicy represents the retention rules applied to cached images
+// originating from a given remote server and alias
+//
+// API extension: image_retention_policies
+type ImageRetentionPolicy struct {
+	Server string `json:"server" yaml:"server"`
+	Alias  string `json:"alias" yaml:"alias"`
+	Expiry int64  `json:"expiry" yaml:"expiry"`
+	Keep   int    `json:"keep" yaml:"keep"`
+}
+
+// ImageRetentionPut represents the modifiable list of image retention policies
+//
+// API extension: image_retention_policies
+type ImageRetentionPut struct {
+	Policies []ImageRetentionPolicy `json:"policies" yaml:"policies"`
+}
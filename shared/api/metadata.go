@@ -0,0 +1,19 @@
+package api
+
+// MetadataConfiguration represents the configuration keys known to the server
+//
+// API extension: metadata_configuration
+type MetadataConfiguration struct {
+	Container map[string]MetadataConfigurationKey `json:"container" yaml:"container"`
+	Server    map[string]MetadataConfigurationKey `json:"server" yaml:"server"`
+}
+
+// MetadataConfigurationKey describes a configuration key
+//
+// API extension: metadata_configuration
+type MetadataConfigurationKey struct {
+	Type       string `json:"type" yaml:"type"`
+	Default    string `json:"default" yaml:"default"`
+	LiveUpdate bool   `json:"live_update" yaml:"live_update"`
+	Deprecated string `json:"deprecated" yaml:"deprecated"`
+}
@@ -57,6 +57,10 @@ type NetworkLease struct {
 	Hwaddr   string `json:"hwaddr" yaml:"hwaddr"`
 	Address  string `json:"address" yaml:"address"`
 	Type     string `json:"type" yaml:"type"`
+
+	// API extension: network_leases_location
+	Container string `json:"container" yaml:"container"`
+	Location  string `json:"location" yaml:"location"`
 }
 
 // NetworkState represents the network state
@@ -67,6 +71,9 @@ type NetworkState struct {
 	Mtu       int                   `json:"mtu" yaml:"mtu"`
 	State     string                `json:"state" yaml:"state"`
 	Type      string                `json:"type" yaml:"type"`
+
+	// API extension: network_usage
+	Usage *NetworkStateUsage `json:"usage" yaml:"usage"`
 }
 
 // NetworkStateAddress represents a network address
@@ -77,6 +84,16 @@ type NetworkStateAddress struct {
 	Scope   string `json:"scope" yaml:"scope"`
 }
 
+// NetworkStateUsage represents the usage of a bridge, aggregated over the
+// interfaces attached to it
+//
+// API extension: network_usage
+type NetworkStateUsage struct {
+	Counters     NetworkStateCounters `json:"counters" yaml:"counters"`
+	Interfaces   int                  `json:"interfaces" yaml:"interfaces"`
+	ActiveLeases int                  `json:"active_leases" yaml:"active_leases"`
+}
+
 // NetworkStateCounters represents packet counters
 type NetworkStateCounters struct {
 	BytesReceived   int64 `json:"bytes_received" yaml:"bytes_received"`
@@ -33,3 +33,33 @@ type Profile struct {
 func (profile *Profile) Writable() ProfilePut {
 	return profile.ProfilePut
 }
+
+// ProfileUsedByContainer represents a container using a LXD profile
+//
+// API extension: profile_update_preview
+type ProfileUsedByContainer struct {
+	Name     string   `json:"name" yaml:"name"`
+	Location string   `json:"location" yaml:"location"`
+	Profiles []string `json:"profiles" yaml:"profiles"`
+}
+
+// ProfileUpdatePreview represents what an update of a LXD profile would do
+// to the containers using it
+//
+// API extension: profile_update_preview
+type ProfileUpdatePreview struct {
+	Containers []ProfileUpdatePreviewContainer `json:"containers" yaml:"containers"`
+}
+
+// ProfileUpdatePreviewContainer represents the effect of a profile update on
+// a single container
+//
+// API extension: profile_update_preview
+type ProfileUpdatePreviewContainer struct {
+	Name            string   `json:"name" yaml:"name"`
+	Running         bool     `json:"running" yaml:"running"`
+	ChangedConfig   []string `json:"changed_config" yaml:"changed_config"`
+	ChangedDevices  []string `json:"changed_devices" yaml:"changed_devices"`
+	RestartRequired bool     `json:"restart_required" yaml:"restart_required"`
+	Error           string   `json:"error" yaml:"error"`
+}
@@ -6,6 +6,19 @@ type Resources struct {
 	CPU         ResourcesCPU         `json:"cpu,omitempty" yaml:"cpu,omitempty"`
 	Memory      ResourcesMemory      `json:"memory,omitempty" yaml:"memory,omitempty"`
 	StoragePool ResourcesStoragePool `json:"pool,omitempty" yaml:"pool,omitempty"`
+
+	// API extension: resources_storage_pools
+	StoragePools []ResourcesStoragePoolUsage `json:"storage_pools" yaml:"storage_pools"`
+
+	// API extension: resources_gpu_usb
+	GPU ResourcesGPU `json:"gpu" yaml:"gpu"`
+	USB ResourcesUSB `json:"usb" yaml:"usb"`
+
+	// API extension: resources_network
+	Network ResourcesNetwork `json:"network" yaml:"network"`
+
+	// API extension: resources_numa
+	NUMA ResourcesNUMA `json:"numa" yaml:"numa"`
 }
 
 // ResourcesCPUSocket represents a cpu socket on the system
@@ -24,6 +37,25 @@ type ResourcesCPUSocket struct {
 type ResourcesCPU struct {
 	Sockets []ResourcesCPUSocket `json:"sockets" yaml:"sockets"`
 	Total   uint64               `json:"total" yaml:"total"`
+
+	// API extension: resources_cpu_flags
+	Flags           []string                   `json:"flags" yaml:"flags"`
+	Vulnerabilities map[string]string          `json:"vulnerabilities,omitempty" yaml:"vulnerabilities,omitempty"`
+	Virtualization  ResourcesCPUVirtualization `json:"virtualization" yaml:"virtualization"`
+}
+
+// ResourcesCPUVirtualization represents the hardware virtualization support
+// of the system
+// API extension: resources_cpu_flags
+type ResourcesCPUVirtualization struct {
+	// Hardware virtualization extension of the CPU (vmx or svm), if any
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// Whether /dev/kvm is available
+	KVM bool `json:"kvm" yaml:"kvm"`
+
+	// Whether KVM allows running virtual machines inside of virtual machines
+	Nested bool `json:"nested" yaml:"nested"`
 }
 
 // ResourcesMemory represents the memory resources available on the system
@@ -31,6 +63,18 @@ type ResourcesCPU struct {
 type ResourcesMemory struct {
 	Used  uint64 `json:"used" yaml:"used"`
 	Total uint64 `json:"total" yaml:"total"`
+
+	// API extension: resources_numa
+	Hugepages []ResourcesMemoryHugepages `json:"hugepages,omitempty" yaml:"hugepages,omitempty"`
+}
+
+// ResourcesMemoryHugepages represents the configured hugepages of a given size
+// API extension: resources_numa
+type ResourcesMemoryHugepages struct {
+	// Size of the pages in bytes
+	Size  uint64 `json:"size" yaml:"size"`
+	Total uint64 `json:"total" yaml:"total"`
+	Free  uint64 `json:"free" yaml:"free"`
 }
 
 // ResourcesStoragePool represents the resources available to a given storage pool
@@ -53,3 +97,116 @@ type ResourcesStoragePoolInodes struct {
 	Used  uint64 `json:"used" yaml:"used"`
 	Total uint64 `json:"total" yaml:"total"`
 }
+
+// ResourcesStoragePoolUsage represents the space and inodes available to a
+// storage pool of the server, along with what's using it
+// API extension: resources_storage_pools
+type ResourcesStoragePoolUsage struct {
+	ResourcesStoragePool `yaml:",inline"`
+
+	Name   string   `json:"name" yaml:"name"`
+	Driver string   `json:"driver" yaml:"driver"`
+	UsedBy []string `json:"used_by" yaml:"used_by"`
+
+	// Resources of the pool on each node of a cluster, by node name
+	Nodes map[string]ResourcesStoragePool `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+}
+
+// ResourcesGPU represents the GPUs available on the system
+// API extension: resources_gpu_usb
+type ResourcesGPU struct {
+	Cards []ResourcesGPUCard `json:"cards" yaml:"cards"`
+	Total uint64             `json:"total" yaml:"total"`
+}
+
+// ResourcesGPUCard represents a GPU on the system
+// API extension: resources_gpu_usb
+type ResourcesGPUCard struct {
+	// DRM card ID, as used by the "id" key of gpu devices
+	ID         string `json:"id,omitempty" yaml:"id,omitempty"`
+	PCIAddress string `json:"pci_address" yaml:"pci_address"`
+	Driver     string `json:"driver,omitempty" yaml:"driver,omitempty"`
+	VendorID   string `json:"vendor_id" yaml:"vendor_id"`
+	ProductID  string `json:"product_id" yaml:"product_id"`
+
+	// Mediated device types supported by the GPU, by type name
+	Mdev map[string]ResourcesGPUCardMdev `json:"mdev,omitempty" yaml:"mdev,omitempty"`
+}
+
+// ResourcesGPUCardMdev represents a mediated device type of a GPU
+// API extension: resources_gpu_usb
+type ResourcesGPUCardMdev struct {
+	API         string `json:"api" yaml:"api"`
+	Available   uint64 `json:"available" yaml:"available"`
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// ResourcesUSB represents the USB devices available on the system
+// API extension: resources_gpu_usb
+type ResourcesUSB struct {
+	Devices []ResourcesUSBDevice `json:"devices" yaml:"devices"`
+	Total   uint64               `json:"total" yaml:"total"`
+}
+
+// ResourcesUSBDevice represents a USB device on the system
+// API extension: resources_gpu_usb
+type ResourcesUSBDevice struct {
+	BusAddress    uint64 `json:"bus_address" yaml:"bus_address"`
+	DeviceAddress uint64 `json:"device_address" yaml:"device_address"`
+	VendorID      string `json:"vendor_id" yaml:"vendor_id"`
+	ProductID     string `json:"product_id" yaml:"product_id"`
+	Vendor        string `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Product       string `json:"product,omitempty" yaml:"product,omitempty"`
+}
+
+// ResourcesNetwork represents the physical network interfaces of the system
+// API extension: resources_network
+type ResourcesNetwork struct {
+	Cards []ResourcesNetworkCard `json:"cards" yaml:"cards"`
+	Total uint64                 `json:"total" yaml:"total"`
+}
+
+// ResourcesNetworkCard represents a physical network interface of the system
+// API extension: resources_network
+type ResourcesNetworkCard struct {
+	Name       string `json:"name" yaml:"name"`
+	Address    string `json:"address" yaml:"address"`
+	MTU        uint64 `json:"mtu" yaml:"mtu"`
+	State      string `json:"state" yaml:"state"`
+	Driver     string `json:"driver,omitempty" yaml:"driver,omitempty"`
+	PCIAddress string `json:"pci_address,omitempty" yaml:"pci_address,omitempty"`
+
+	// Bridge the interface is a port of, if any
+	Bridge string `json:"bridge,omitempty" yaml:"bridge,omitempty"`
+
+	// Only set for interfaces supporting SR-IOV
+	SRIOV *ResourcesNetworkCardSRIOV `json:"sriov,omitempty" yaml:"sriov,omitempty"`
+}
+
+// ResourcesNetworkCardSRIOV represents the SR-IOV virtual functions of a
+// network interface
+// API extension: resources_network
+type ResourcesNetworkCardSRIOV struct {
+	CurrentVFs uint64 `json:"current_vfs" yaml:"current_vfs"`
+	MaximumVFs uint64 `json:"maximum_vfs" yaml:"maximum_vfs"`
+
+	// Virtual functions not passed to a container yet, including the ones
+	// which can still be enabled
+	AvailableVFs uint64 `json:"available_vfs" yaml:"available_vfs"`
+}
+
+// ResourcesNUMA represents the NUMA topology of the system
+// API extension: resources_numa
+type ResourcesNUMA struct {
+	Nodes []ResourcesNUMANode `json:"nodes" yaml:"nodes"`
+	Total uint64              `json:"total" yaml:"total"`
+}
+
+// ResourcesNUMANode represents the CPUs and memory of a NUMA node
+// API extension: resources_numa
+type ResourcesNUMANode struct {
+	ID     uint64          `json:"id" yaml:"id"`
+	CPUs   []uint64        `json:"cpus" yaml:"cpus"`
+	Memory ResourcesMemory `json:"memory" yaml:"memory"`
+}
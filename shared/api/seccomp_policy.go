@@ -0,0 +1,34 @@
+package api
+
+// SeccompPoliciesPost represents the fields of a new LXD seccomp policy
+//
+// API extension: seccomp_policies
+type SeccompPoliciesPost struct {
+	SeccompPolicyPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// SeccompPolicyPut represents the modifiable fields of a LXD seccomp policy
+//
+// API extension: seccomp_policies
+type SeccompPolicyPut struct {
+	Config      map[string]string `json:"config" yaml:"config"`
+	Description string            `json:"description" yaml:"description"`
+}
+
+// SeccompPolicy represents a LXD seccomp policy
+//
+// API extension: seccomp_policies
+type SeccompPolicy struct {
+	SeccompPolicyPut `yaml:",inline"`
+
+	Name   string   `json:"name" yaml:"name"`
+	UsedBy []string `json:"used_by" yaml:"used_by"`
+}
+
+// Writable converts a full SeccompPolicy struct into a SeccompPolicyPut struct
+// (filters read-only fields)
+func (policy *SeccompPolicy) Writable() SeccompPolicyPut {
+	return policy.SeccompPolicyPut
+}
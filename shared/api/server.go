@@ -20,6 +20,9 @@ type ServerEnvironment struct {
 	// API extension: clustering
 	ServerClustered bool   `json:"server_clustered" yaml:"server_clustered"`
 	ServerName      string `json:"server_name" yaml:"server_name"`
+
+	// API extension: resources_cpu_flags
+	KernelFeatures map[string]string `json:"kernel_features" yaml:"kernel_features"`
 }
 
 // ServerPut represents the modifiable fields of a LXD server configuration
@@ -23,6 +23,25 @@ type StoragePool struct {
 	// API extension: clustering
 	Status    string   `json:"status" yaml:"status"`
 	Locations []string `json:"locations" yaml:"locations"`
+
+	// API extension: storage_pool_availability
+	Availability       string `json:"availability" yaml:"availability"`
+	AvailabilityReason string `json:"availability_reason,omitempty" yaml:"availability_reason,omitempty"`
+
+	// API extension: storage_pool_state
+	State       string `json:"state" yaml:"state"`
+	StateReason string `json:"state_reason,omitempty" yaml:"state_reason,omitempty"`
+
+	// State of the pool on each node of a cluster, by node name
+	NodesState map[string]StoragePoolState `json:"nodes_state,omitempty" yaml:"nodes_state,omitempty"`
+}
+
+// StoragePoolState represents the state of a LXD storage pool on a node.
+//
+// API extension: storage_pool_state
+type StoragePoolState struct {
+	State  string `json:"state" yaml:"state"`
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
 }
 
 // StoragePoolPut represents the modifiable fields of a LXD storage pool.
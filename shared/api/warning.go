@@ -0,0 +1,29 @@
+package api
+
+import (
+	"time"
+)
+
+// Warning represents a condition detected by a LXD node which needs the
+// attention of the administrator, such as an expiring certificate.
+//
+// API extension: warnings
+type Warning struct {
+	WarningPut `yaml:",inline"`
+
+	UUID        string    `json:"uuid" yaml:"uuid"`
+	Location    string    `json:"location" yaml:"location"`
+	Type        string    `json:"type" yaml:"type"`
+	Entity      string    `json:"entity" yaml:"entity"`
+	Count       int       `json:"count" yaml:"count"`
+	FirstSeenAt time.Time `json:"first_seen_at" yaml:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" yaml:"last_seen_at"`
+	LastMessage string    `json:"last_message" yaml:"last_message"`
+}
+
+// WarningPut represents the modifiable fields of a warning.
+//
+// API extension: warnings
+type WarningPut struct {
+	Status string `json:"status" yaml:"status"`
+}
@@ -74,6 +74,17 @@ func KeyPairAndCA(dir, prefix string, kind CertKind) (*CertInfo, error) {
 	return info, nil
 }
 
+// KeyPairFromRaw returns a CertInfo object with the key pair built from the
+// given PEM-encoded certificate and private key, without any CA.
+func KeyPairFromRaw(certificate []byte, key []byte) (*CertInfo, error) {
+	keypair, err := tls.X509KeyPair(certificate, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertInfo{keypair: keypair}, nil
+}
+
 // CertInfo captures TLS certificate information about a certain public/private
 // keypair and an optional CA certificate.
 //
@@ -87,6 +87,41 @@ func IsAny(value string) error {
 	return nil
 }
 
+// IsAbsPath returns an error if the given value isn't an absolute path.
+func IsAbsPath(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(value, "/") {
+		return fmt.Errorf("Invalid value for an absolute path: %s", value)
+	}
+
+	return nil
+}
+
+// IsEnvironmentName returns true if the given string can be used as the name
+// of an environment variable, that is it only contains letters, digits and
+// underscores and doesn't start with a digit.
+func IsEnvironmentName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case r == '_':
+		case r >= 'a' && r <= 'z':
+		case r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
 // IsRootDiskDevice returns true if the given device representation is
 // configured as root disk for a container. It typically get passed a specific
 // entry of api.Container.Devices.
@@ -121,18 +156,61 @@ func GetRootDiskDevice(devices map[string]map[string]string) (string, map[string
 	return "", nil, fmt.Errorf("No root device could be found.")
 }
 
+// ContainerConfigKey describes a well-known container configuration key.
+type ContainerConfigKey struct {
+	Type       string // Type of the value (string, integer, boolean or blob)
+	Default    string // Value used when the key isn't set, if any
+	LiveUpdate bool   // Whether changes apply to running containers without a restart
+	Deprecated string // Optional message set when the key shouldn't be used anymore
+
+	// Checks whether a value is legal.
+	Validator func(value string) error
+}
+
 // KnownContainerConfigKeys maps all fully defined, well-known config keys
 // to an appropriate checker function, which validates whether or not a
-// given value is syntactically legal.
-var KnownContainerConfigKeys = map[string]func(value string) error{
-	"boot.autostart":             IsBool,
-	"boot.autostart.delay":       IsInt64,
-	"boot.autostart.priority":    IsInt64,
-	"boot.stop.priority":         IsInt64,
-	"boot.host_shutdown_timeout": IsInt64,
-
-	"limits.cpu": IsAny,
-	"limits.cpu.allowance": func(value string) error {
+// given value is syntactically legal. It's derived from
+// ContainerConfigSchema.
+var KnownContainerConfigKeys = map[string]func(value string) error{}
+
+func init() {
+	for key := range ContainerConfigSchema {
+		key := key
+		KnownContainerConfigKeys[key] = func(value string) error {
+			return ContainerConfigSchema[key].Validator(value)
+		}
+	}
+}
+
+// ContainerConfigSchema is the schema of all fully defined, well-known
+// config keys.
+var ContainerConfigSchema = map[string]ContainerConfigKey{
+	"boot.autostart":             {Type: "boolean", Validator: IsBool},
+	"boot.autostart.delay":       {Type: "integer", Default: "0", Validator: IsInt64},
+	"boot.autostart.priority":    {Type: "integer", Default: "0", Validator: IsInt64},
+	"boot.depends_on":            {Type: "string", Validator: IsAny},
+	"boot.stop.priority":         {Type: "integer", Default: "0", Validator: IsInt64},
+	"boot.host_shutdown_timeout": {Type: "integer", Default: "30", LiveUpdate: true, Validator: IsInt64},
+
+	"healthcheck.action": {Type: "string", Default: "none", LiveUpdate: true, Validator: func(value string) error {
+		return IsOneOf(value, []string{"none", "restart", "stop"})
+	}},
+	"healthcheck.exec": {Type: "string", LiveUpdate: true, Validator: IsAny},
+	"healthcheck.interval": {Type: "integer", Default: "30", LiveUpdate: true, Validator: func(value string) error {
+		if value == "0" {
+			return fmt.Errorf("Invalid value for healthcheck.interval: %s", value)
+		}
+
+		return IsUint32(value)
+	}},
+	"healthcheck.retries": {Type: "integer", Default: "3", LiveUpdate: true, Validator: IsUint32},
+
+	"hooks.pre-start":  {Type: "string", LiveUpdate: true, Validator: IsAbsPath},
+	"hooks.post-start": {Type: "string", LiveUpdate: true, Validator: IsAbsPath},
+	"hooks.pre-stop":   {Type: "string", LiveUpdate: true, Validator: IsAbsPath},
+
+	"limits.cpu": {Type: "string", LiveUpdate: true, Validator: IsAny},
+	"limits.cpu.allowance": {Type: "string", Default: "100%", LiveUpdate: true, Validator: func(value string) error {
 		if value == "" {
 			return nil
 		}
@@ -164,12 +242,13 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 		}
 
 		return nil
-	},
-	"limits.cpu.priority": IsPriority,
+	}},
+	"limits.cpu.isolated": {Type: "boolean", Default: "false", LiveUpdate: true, Validator: IsBool},
+	"limits.cpu.priority": {Type: "integer", Default: "10", LiveUpdate: true, Validator: IsPriority},
 
-	"limits.disk.priority": IsPriority,
+	"limits.disk.priority": {Type: "integer", Default: "5", LiveUpdate: true, Validator: IsPriority},
 
-	"limits.memory": func(value string) error {
+	"limits.memory": {Type: "string", LiveUpdate: true, Validator: func(value string) error {
 		if value == "" {
 			return nil
 		}
@@ -189,54 +268,109 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 		}
 
 		return nil
-	},
-	"limits.memory.enforce": func(value string) error {
+	}},
+	"limits.memory.enforce": {Type: "string", Default: "hard", LiveUpdate: true, Validator: func(value string) error {
 		return IsOneOf(value, []string{"soft", "hard"})
-	},
-	"limits.memory.swap":          IsBool,
-	"limits.memory.swap.priority": IsPriority,
+	}},
+	"limits.memory.swap":          {Type: "boolean", Default: "true", LiveUpdate: true, Validator: IsBool},
+	"limits.memory.swap.priority": {Type: "integer", Default: "10", LiveUpdate: true, Validator: IsPriority},
+
+	"limits.network.egress": {Type: "string", LiveUpdate: true, Validator: func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := ParseBitSizeString(value)
+		return err
+	}},
+	"limits.network.priority": {Type: "integer", Default: "0", LiveUpdate: true, Validator: IsPriority},
+
+	"limits.processes": {Type: "integer", LiveUpdate: true, Validator: func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		// A limit of 0 would prevent the container from starting at all
+		processes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || processes < 1 {
+			return fmt.Errorf("Invalid value for limits.processes: %s", value)
+		}
+
+		return nil
+	}},
+
+	"linux.exec_agent": {Type: "boolean", Default: "false", LiveUpdate: true, Validator: IsBool},
+	"linux.exec_environment": {Type: "string", Default: "host", LiveUpdate: true, Validator: func(value string) error {
+		return IsOneOf(value, []string{"host", "container"})
+	}},
+	"linux.kernel_modules": {Type: "string", LiveUpdate: true, Validator: IsAny},
+
+	"linux.lxcfs":       {Type: "boolean", Default: "true", Validator: IsBool},
+	"linux.lxcfs.files": {Type: "string", Validator: IsAny},
+
+	"migration.incremental.memory":            {Type: "boolean", Default: "false", LiveUpdate: true, Validator: IsBool},
+	"migration.incremental.memory.iterations": {Type: "integer", Default: "10", LiveUpdate: true, Validator: IsUint32},
+	"migration.incremental.memory.goal":       {Type: "integer", Default: "70", LiveUpdate: true, Validator: IsUint32},
 
-	"limits.network.priority": IsPriority,
+	"nvidia.runtime": {Type: "boolean", Default: "false", Validator: IsBool},
 
-	"limits.processes": IsInt64,
+	"placement.affinity":      {Type: "string", LiveUpdate: true, Validator: IsAny},
+	"placement.anti-affinity": {Type: "string", LiveUpdate: true, Validator: IsAny},
+	"placement.group":         {Type: "string", LiveUpdate: true, Validator: IsAny},
 
-	"linux.kernel_modules": IsAny,
+	"security.nesting":       {Type: "boolean", Default: "false", Validator: IsBool},
+	"security.privileged":    {Type: "boolean", Default: "false", Validator: IsBool},
+	"security.devlxd":        {Type: "boolean", Default: "true", LiveUpdate: true, Validator: IsBool},
+	"security.devlxd.images": {Type: "boolean", Default: "false", LiveUpdate: true, Validator: IsBool},
 
-	"migration.incremental.memory":            IsBool,
-	"migration.incremental.memory.iterations": IsUint32,
-	"migration.incremental.memory.goal":       IsUint32,
+	"security.devices.fuse": {Type: "boolean", Default: "false", Validator: IsBool},
+	"security.devices.tun":  {Type: "boolean", Default: "false", Validator: IsBool},
 
-	"nvidia.runtime": IsBool,
+	"security.protection.delete": {Type: "boolean", Default: "false", LiveUpdate: true, Validator: IsBool},
+	"security.protection.shift":  {Type: "boolean", Default: "false", LiveUpdate: true, Validator: IsBool},
+
+	"security.idmap.base":     {Type: "integer", Validator: IsUint32},
+	"security.idmap.isolated": {Type: "boolean", Default: "false", Validator: IsBool},
+	"security.idmap.size":     {Type: "integer", Validator: IsUint32},
+
+	"security.seccomp.policy": {Type: "string", Validator: IsAny},
+
+	"snapshots.retention": {Type: "string", LiveUpdate: true, Validator: IsAny},
+	"snapshots.schedule": {Type: "string", LiveUpdate: true, Validator: func(value string) error {
+		if value == "" {
+			return nil
+		}
 
-	"security.nesting":       IsBool,
-	"security.privileged":    IsBool,
-	"security.devlxd":        IsBool,
-	"security.devlxd.images": IsBool,
+		return IsOneOf(value, []string{"hourly", "daily", "weekly", "monthly", "yearly"})
+	}},
 
-	"security.protection.delete": IsBool,
+	"security.syscalls.blacklist_default": {Type: "boolean", Default: "true", Validator: IsBool},
+	"security.syscalls.blacklist_compat":  {Type: "boolean", Default: "false", Validator: IsBool},
+	"security.syscalls.blacklist":         {Type: "string", Validator: IsAny},
+	"security.syscalls.whitelist":         {Type: "string", Validator: IsAny},
 
-	"security.idmap.base":     IsUint32,
-	"security.idmap.isolated": IsBool,
-	"security.idmap.size":     IsUint32,
+	"zfs.delegate": {Type: "boolean", Default: "false", Validator: IsBool},
 
-	"security.syscalls.blacklist_default": IsBool,
-	"security.syscalls.blacklist_compat":  IsBool,
-	"security.syscalls.blacklist":         IsAny,
-	"security.syscalls.whitelist":         IsAny,
+	"run.once": {Type: "blob", Validator: IsAny},
 
 	// Caller is responsible for full validation of any raw.* value
-	"raw.apparmor": IsAny,
-	"raw.lxc":      IsAny,
-	"raw.seccomp":  IsAny,
-	"raw.idmap":    IsAny,
-
-	"volatile.apply_template":   IsAny,
-	"volatile.base_image":       IsAny,
-	"volatile.last_state.idmap": IsAny,
-	"volatile.last_state.power": IsAny,
-	"volatile.idmap.next":       IsAny,
-	"volatile.idmap.base":       IsAny,
-	"volatile.apply_quota":      IsAny,
+	"raw.apparmor": {Type: "blob", LiveUpdate: true, Validator: IsAny},
+	"raw.lxc":      {Type: "blob", Validator: IsAny},
+	"raw.seccomp":  {Type: "blob", Validator: IsAny},
+	"raw.idmap":    {Type: "blob", Validator: IsAny},
+
+	"volatile.apply_template":   {Type: "string", Validator: IsAny},
+	"volatile.base_image":       {Type: "string", Validator: IsAny},
+	"volatile.last_state.idmap": {Type: "string", Validator: IsAny},
+	"volatile.last_state.power": {Type: "string", Validator: IsAny},
+	"volatile.idmap.next":       {Type: "string", Validator: IsAny},
+	"volatile.idmap.base":       {Type: "string", Validator: IsAny},
+	"volatile.apply_quota":      {Type: "string", Validator: IsAny},
+	"volatile.trash.date":       {Type: "string", Validator: IsAny},
+	"volatile.thaw.date":        {Type: "string", Validator: IsAny},
+	"volatile.suspended":        {Type: "string", Validator: IsAny},
+	"volatile.run_once.status":  {Type: "string", Validator: IsAny},
+	"volatile.image_build":      {Type: "string", Validator: IsAny},
 }
 
 // ConfigKeyChecker returns a function that will check whether or not
@@ -0,0 +1,62 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsEnvironmentName(t *testing.T) {
+	for _, name := range []string{"PATH", "http_proxy", "_FOO", "LC_ALL", "X1"} {
+		assert.True(t, IsEnvironmentName(name), name)
+	}
+
+	for _, name := range []string{"", "1X", "FOO=BAR", "FOO BAR", "FOO-BAR", "FÖÖ"} {
+		assert.False(t, IsEnvironmentName(name), name)
+	}
+}
+
+// Every key of the schema has a type and a validator, and the legacy map of
+// checkers covers the same keys.
+func TestContainerConfigSchema(t *testing.T) {
+	types := []string{"string", "integer", "boolean", "blob"}
+
+	assert.Equal(t, len(ContainerConfigSchema), len(KnownContainerConfigKeys))
+	for name, key := range ContainerConfigSchema {
+		assert.Contains(t, types, key.Type, name)
+		assert.NotNil(t, key.Validator, name)
+		assert.NotNil(t, KnownContainerConfigKeys[name], name)
+	}
+
+	assert.True(t, ContainerConfigSchema["limits.memory"].LiveUpdate)
+	assert.False(t, ContainerConfigSchema["security.nesting"].LiveUpdate)
+	assert.False(t, ContainerConfigSchema["security.privileged"].LiveUpdate)
+}
+
+func TestConfigKeyChecker(t *testing.T) {
+	cases := map[string]map[string]bool{
+		"limits.processes":      {"": true, "10": true, "0": false, "-1": false, "abc": false},
+		"healthcheck.interval":  {"30": true, "0": false, "-5": false},
+		"limits.memory":         {"1GB": true, "50%": true, "lots": false},
+		"limits.network.egress": {"": true, "100Mbit": true, "1Gbit": true, "fast": false},
+		"boot.autostart":        {"true": true, "maybe": false},
+		"user.foo":              {"anything": true},
+	}
+
+	for key, values := range cases {
+		checker, err := ConfigKeyChecker(key)
+		require.NoError(t, err)
+
+		for value, valid := range values {
+			if valid {
+				assert.NoError(t, checker(value), "%s=%s", key, value)
+			} else {
+				assert.Error(t, checker(value), "%s=%s", key, value)
+			}
+		}
+	}
+
+	_, err := ConfigKeyChecker("bogus.key")
+	assert.EqualError(t, err, "Unknown configuration key: bogus.key")
+}
@@ -0,0 +1,428 @@
+// +build linux
+// +build cgo
+
+package shared
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// DirSyncOptions tunes the behaviour of DirSync.
+type DirSyncOptions struct {
+	// Compare the content of regular files of the same size to decide
+	// whether to copy them again, instead of their modification time.
+	Checksum bool
+
+	// Maximum number of bytes written per second, 0 for no limit.
+	BandwidthLimit int64
+}
+
+type dirSyncInode struct {
+	dev uint64
+	ino uint64
+}
+
+type dirSync struct {
+	options DirSyncOptions
+
+	// Destination path of the first copy of hardlinked files
+	links map[dirSyncInode]string
+
+	started time.Time
+	written int64
+}
+
+// Size of the blocks files are copied in, and checked for holes.
+const dirSyncBlockSize = 128 * 1024
+
+// From fcntl.h, for utimensat()
+const (
+	dirSyncAtFdCwd           = -100
+	dirSyncAtSymlinkNoFollow = 0x100
+)
+
+// DirSync makes dest an exact copy of source, much like "rsync -aHAX --sparse
+// --devices --delete --numeric-ids" would: ownership, permissions,
+// timestamps, extended attributes (and so ACLs), hardlinks, device nodes and
+// holes in files are all preserved, and whatever isn't in source gets
+// removed from dest. Files which already match aren't copied again.
+//
+// Files which vanish from source while it's being walked are skipped.
+func DirSync(source string, dest string, options DirSyncOptions) error {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("Source \"%s\" isn't a directory", source)
+	}
+
+	err = os.MkdirAll(dest, 0755)
+	if err != nil {
+		return err
+	}
+
+	s := &dirSync{
+		options: options,
+		links:   map[dirSyncInode]string{},
+		started: time.Now(),
+	}
+
+	return s.sync(source, dest, info)
+}
+
+func (s *dirSync) sync(src string, dst string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("Failed to get the details of \"%s\"", src)
+	}
+
+	// Get rid of whatever is in the way
+	dstInfo, err := os.Lstat(dst)
+	if err == nil && dstInfo.Mode()&os.ModeType != info.Mode()&os.ModeType {
+		err = os.RemoveAll(dst)
+		if err != nil {
+			return err
+		}
+
+		dstInfo = nil
+	} else if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		dstInfo = nil
+	}
+
+	switch {
+	case info.IsDir():
+		if dstInfo == nil {
+			err := os.Mkdir(dst, 0700)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := s.syncDir(src, dst)
+		if err != nil {
+			return err
+		}
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+
+		if dstInfo != nil {
+			current, err := os.Readlink(dst)
+			if err != nil || current != target {
+				err := os.Remove(dst)
+				if err != nil {
+					return err
+				}
+
+				dstInfo = nil
+			}
+		}
+
+		if dstInfo == nil {
+			err := os.Symlink(target, dst)
+			if err != nil {
+				return err
+			}
+		}
+	case info.Mode().IsRegular():
+		// Never write through a hardlink of the destination
+		if dstInfo != nil && dstInfo.Sys().(*syscall.Stat_t).Nlink > 1 {
+			err := os.Remove(dst)
+			if err != nil {
+				return err
+			}
+
+			dstInfo = nil
+		}
+
+		if st.Nlink > 1 {
+			key := dirSyncInode{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+			first, ok := s.links[key]
+			if ok {
+				// The metadata is shared with the first copy
+				if dstInfo != nil {
+					err := os.Remove(dst)
+					if err != nil {
+						return err
+					}
+				}
+
+				return os.Link(first, dst)
+			}
+
+			s.links[key] = dst
+		}
+
+		err := s.syncFile(src, dst, info, dstInfo)
+		if err != nil {
+			return err
+		}
+	default:
+		// Device nodes, fifos and sockets
+		if dstInfo != nil {
+			dstSt := dstInfo.Sys().(*syscall.Stat_t)
+			if dstSt.Mode != st.Mode || dstSt.Rdev != st.Rdev {
+				err := os.Remove(dst)
+				if err != nil {
+					return err
+				}
+
+				dstInfo = nil
+			}
+		}
+
+		if dstInfo == nil {
+			err := syscall.Mknod(dst, st.Mode, int(st.Rdev))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return dirSyncMetadata(src, dst, info, st)
+}
+
+func (s *dirSync) syncDir(src string, dst string) error {
+	entries, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for _, entry := range entries {
+		names[entry.Name()] = true
+
+		srcPath := filepath.Join(src, entry.Name())
+		err := s.sync(srcPath, filepath.Join(dst, entry.Name()), entry)
+		if err != nil {
+			// Skip what vanished in the meantime
+			_, statErr := os.Lstat(srcPath)
+			if os.IsNotExist(statErr) {
+				continue
+			}
+
+			return err
+		}
+	}
+
+	dstEntries, err := ioutil.ReadDir(dst)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range dstEntries {
+		if names[entry.Name()] {
+			continue
+		}
+
+		err := os.RemoveAll(filepath.Join(dst, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *dirSync) syncFile(src string, dst string, info os.FileInfo, dstInfo os.FileInfo) error {
+	if dstInfo != nil && dstInfo.Size() == info.Size() {
+		same := dstInfo.ModTime().Equal(info.ModTime())
+		if s.options.Checksum {
+			var err error
+			same, err = dirSyncSameContent(src, dst)
+			if err != nil {
+				return err
+			}
+		}
+
+		if same {
+			return nil
+		}
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// Leave holes where the source only has zeroes
+	buf := make([]byte, dirSyncBlockSize)
+	zero := make([]byte, dirSyncBlockSize)
+	size := int64(0)
+	for {
+		n, err := io.ReadFull(in, buf)
+		if n > 0 {
+			if bytes.Equal(buf[:n], zero[:n]) {
+				_, err := out.Seek(int64(n), io.SeekCurrent)
+				if err != nil {
+					return err
+				}
+			} else {
+				_, err := out.Write(buf[:n])
+				if err != nil {
+					return err
+				}
+
+				s.throttle(int64(n))
+			}
+
+			size += int64(n)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// Account for a trailing hole
+	return out.Truncate(size)
+}
+
+// Wait long enough for the data written so far to fit the bandwidth limit.
+func (s *dirSync) throttle(n int64) {
+	if s.options.BandwidthLimit <= 0 {
+		return
+	}
+
+	s.written += n
+	expected := time.Duration(s.written * int64(time.Second) / s.options.BandwidthLimit)
+	elapsed := time.Since(s.started)
+	if expected > elapsed {
+		time.Sleep(expected - elapsed)
+	}
+}
+
+func dirSyncSameContent(a string, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, dirSyncBlockSize)
+	bufB := make([]byte, dirSyncBlockSize)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+
+		if errA != nil {
+			return false, errA
+		}
+
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+// Apply the ownership, permissions, extended attributes and timestamps of
+// src to dst, in that order as changing the owner clears some of the others.
+func dirSyncMetadata(src string, dst string, info os.FileInfo, st *syscall.Stat_t) error {
+	err := os.Lchown(dst, int(st.Uid), int(st.Gid))
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		err = syscall.Chmod(dst, st.Mode&07777)
+		if err != nil {
+			return err
+		}
+
+		err = dirSyncXattrs(src, dst)
+		if err != nil {
+			return err
+		}
+	}
+
+	times := [2]syscall.Timespec{st.Atim, st.Mtim}
+	path, err := syscall.BytePtrFromString(dst)
+	if err != nil {
+		return err
+	}
+
+	dirfd := dirSyncAtFdCwd
+	_, _, errno := syscall.Syscall6(syscall.SYS_UTIMENSAT, uintptr(dirfd), uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&times[0])), dirSyncAtSymlinkNoFollow, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+func dirSyncXattrs(src string, dst string) error {
+	xattrs, err := GetAllXattr(src)
+	if err != nil {
+		return err
+	}
+
+	current, err := GetAllXattr(dst)
+	if err != nil {
+		return err
+	}
+
+	for key := range current {
+		_, ok := xattrs[key]
+		if ok {
+			continue
+		}
+
+		err := syscall.Removexattr(dst, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key, value := range xattrs {
+		if current[key] == value {
+			continue
+		}
+
+		err := syscall.Setxattr(dst, key, []byte(value), 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
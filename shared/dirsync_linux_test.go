@@ -0,0 +1,95 @@
+package shared
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirSync(t *testing.T) {
+	source, err := ioutil.TempDir("", "lxd-dirsync-source")
+	require.NoError(t, err)
+	defer os.RemoveAll(source)
+
+	dest, err := ioutil.TempDir("", "lxd-dirsync-dest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dest)
+
+	// A regular file with extended attributes, hardlinked
+	require.NoError(t, os.MkdirAll(filepath.Join(source, "dir"), 0711))
+	file := filepath.Join(source, "dir", "file")
+	require.NoError(t, ioutil.WriteFile(file, []byte("content"), 0640))
+	require.NoError(t, syscall.Setxattr(file, "user.test", []byte("value"), 0))
+	require.NoError(t, os.Link(file, filepath.Join(source, "link")))
+
+	// A sparse file
+	sparse, err := os.Create(filepath.Join(source, "sparse"))
+	require.NoError(t, err)
+	_, err = sparse.WriteAt([]byte("end"), 10*1024*1024)
+	require.NoError(t, err)
+	sparse.Close()
+
+	require.NoError(t, os.Symlink("dir/file", filepath.Join(source, "symlink")))
+	require.NoError(t, syscall.Mkfifo(filepath.Join(source, "fifo"), 0600))
+
+	// Something which shouldn't survive the sync
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dest, "stale"), []byte("stale"), 0644))
+
+	err = DirSync(source, dest, DirSyncOptions{Checksum: true})
+	require.NoError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "dir", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	info, err := os.Stat(filepath.Join(dest, "dir"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0711), info.Mode().Perm())
+
+	xattrs, err := GetAllXattr(filepath.Join(dest, "dir", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", xattrs["user.test"])
+
+	fileInfo, err := os.Stat(filepath.Join(dest, "dir", "file"))
+	require.NoError(t, err)
+	linkInfo, err := os.Stat(filepath.Join(dest, "link"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(fileInfo, linkInfo))
+	assert.Equal(t, os.FileMode(0640), fileInfo.Mode().Perm())
+
+	sourceInfo, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.True(t, sourceInfo.ModTime().Equal(fileInfo.ModTime()))
+
+	sparseInfo, err := os.Stat(filepath.Join(dest, "sparse"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024+3), sparseInfo.Size())
+	assert.True(t, sparseInfo.Sys().(*syscall.Stat_t).Blocks*512 < sparseInfo.Size())
+
+	target, err := os.Readlink(filepath.Join(dest, "symlink"))
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file", target)
+
+	fifoInfo, err := os.Lstat(filepath.Join(dest, "fifo"))
+	require.NoError(t, err)
+	assert.True(t, fifoInfo.Mode()&os.ModeNamedPipe != 0)
+
+	assert.False(t, PathExists(filepath.Join(dest, "stale")))
+
+	// Changes are picked up by a second run
+	require.NoError(t, ioutil.WriteFile(file, []byte("changed"), 0640))
+	require.NoError(t, os.Remove(filepath.Join(source, "symlink")))
+
+	err = DirSync(source, dest, DirSyncOptions{Checksum: true})
+	require.NoError(t, err)
+
+	content, err = ioutil.ReadFile(filepath.Join(dest, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "changed", string(content))
+	assert.False(t, PathExists(filepath.Join(dest, "symlink")))
+}
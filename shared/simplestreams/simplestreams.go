@@ -115,6 +115,7 @@ func (s *SimpleStreamsManifest) ToLXD() ([]api.Image, map[string][][]string) {
 			var meta SimpleStreamsManifestProductVersionItem
 			var rootTar SimpleStreamsManifestProductVersionItem
 			var rootSquash SimpleStreamsManifestProductVersionItem
+			var combined SimpleStreamsManifestProductVersionItem
 			deltas := []SimpleStreamsManifestProductVersionItem{}
 
 			for _, item := range version.Items {
@@ -124,7 +125,7 @@ func (s *SimpleStreamsManifest) ToLXD() ([]api.Image, map[string][][]string) {
 				}
 
 				// Skip the files we don't care about
-				if !shared.StringInSlice(item.FileType, []string{"root.tar.xz", "lxd.tar.xz", "squashfs"}) {
+				if !shared.StringInSlice(item.FileType, []string{"root.tar.xz", "lxd.tar.xz", "lxd_combined.tar.gz", "squashfs"}) {
 					continue
 				}
 
@@ -134,10 +135,21 @@ func (s *SimpleStreamsManifest) ToLXD() ([]api.Image, map[string][][]string) {
 					rootSquash = item
 				} else if item.FileType == "root.tar.xz" {
 					rootTar = item
+				} else if item.FileType == "lxd_combined.tar.gz" {
+					combined = item
 				}
 			}
 
-			if meta.FileType == "" || (rootTar.FileType == "" && rootSquash.FileType == "") {
+			// Unified images are made of a single file, which is what
+			// their fingerprint is the hash of.
+			if combined.FileType != "" {
+				meta = combined
+				meta.LXDHashSha256 = combined.HashSha256
+				rootTar = SimpleStreamsManifestProductVersionItem{}
+				rootSquash = SimpleStreamsManifestProductVersionItem{}
+			}
+
+			if meta.FileType == "" || (combined.FileType == "" && rootTar.FileType == "" && rootSquash.FileType == "") {
 				// Invalid image
 				continue
 			}
@@ -230,8 +242,11 @@ func (s *SimpleStreamsManifest) ToLXD() ([]api.Image, map[string][][]string) {
 			}
 
 			imgDownloads := [][]string{
-				{metaPath, metaHash, "meta", fmt.Sprintf("%d", metaSize)},
-				{rootfsPath, rootfsHash, "root", fmt.Sprintf("%d", rootfsSize)}}
+				{metaPath, metaHash, "meta", fmt.Sprintf("%d", metaSize)}}
+
+			if rootfsPath != "" {
+				imgDownloads = append(imgDownloads, []string{rootfsPath, rootfsHash, "root", fmt.Sprintf("%d", rootfsSize)})
+			}
 
 			// Add the deltas
 			for _, delta := range deltas {
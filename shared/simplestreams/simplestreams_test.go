@@ -0,0 +1,40 @@
+package simplestreams
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Unified images come as a single lxd_combined.tar.gz file.
+func TestSimpleStreamsManifest_ToLXDCombined(t *testing.T) {
+	manifest := SimpleStreamsManifest{
+		Products: map[string]SimpleStreamsManifestProduct{
+			"lxd:abcd": {
+				Aliases:         "alpine",
+				Architecture:    "x86_64",
+				OperatingSystem: "Alpine",
+				Versions: map[string]SimpleStreamsManifestProductVersion{
+					"20181022_1200": {
+						Items: map[string]SimpleStreamsManifestProductVersionItem{
+							"lxd_combined.tar.gz": {
+								Path:       "streams/images/abcd/lxd_combined.tar.gz",
+								FileType:   "lxd_combined.tar.gz",
+								HashSha256: "abcd",
+								Size:       42,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	images, downloads := manifest.ToLXD()
+	require.Len(t, images, 1)
+	assert.Equal(t, "abcd", images[0].Fingerprint)
+	assert.Equal(t, int64(42), images[0].Size)
+	assert.Equal(t, "lxd_combined.tar.gz", images[0].Filename)
+	assert.Equal(t, [][]string{{"streams/images/abcd/lxd_combined.tar.gz", "abcd", "meta", "42"}}, downloads["abcd"])
+}
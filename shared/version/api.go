@@ -113,6 +113,92 @@ var APIExtensions = []string{
 	"network_state",
 	"proxy_unix_dac_properties",
 	"container_protection_delete",
+	"compliance_checks",
+	"image_retention_policies",
+	"container_usage_history",
+	"container_exec_agent",
+	"container_exec_environment",
+	"seccomp_policies",
+	"container_exec_user_group_cwd",
+	"container_start_timings",
+	"container_provenance",
+	"container_disk_weight",
+	"container_profile_priorities",
+	"storage_pool_availability",
+	"profile_update_preview",
+	"container_atomic_patch",
+	"container_annotations",
+	"snapshot_image_protection",
+	"container_protection_shift",
+	"storage_shifted",
+	"storage_block_volumes",
+	"storage_volume_cluster_migration",
+	"storage_transfer_tuning",
+	"container_zfs_delegate",
+	"storage_lvm_thinpool_monitoring",
+	"container_backup_ceph_optimized",
+	"storage_driver_external",
+	"storage_pool_state",
+	"container_trash",
+	"container_copy_regenerate_identity",
+	"container_snapshot_partial_restore",
+	"snapshot_retention",
+	"container_freeze_duration",
+	"container_hooks",
+	"container_auto_suspend",
+	"proxy_wake_on_connect",
+	"storage_pool_recover",
+	"event_batching",
+	"migration_compression",
+	"transfer_checksums",
+	"container_export_oci",
+	"image_source_oci",
+	"image_url_checksum",
+	"images_simplestreams",
+	"images_mirrors",
+	"certificate_rotation",
+	"acme",
+	"cluster_https_address",
+	"unix_groups",
+	"bgp",
+	"network_dns",
+	"network_usage",
+	"network_leases_location",
+	"nic_port_isolation",
+	"nic_vlan_tagged",
+	"nic_mtu_tracking",
+	"container_nic_network",
+	"container_disk_ceph",
+	"container_disk_image",
+	"container_disk_fuse",
+	"container_device_nodes",
+	"container_security_devices",
+	"container_lxcfs",
+	"container_cpu_isolated",
+	"container_memory_unified",
+	"cgroup_unified",
+	"container_network_egress_limit",
+	"metadata_configuration",
+	"migration_features",
+	"resources_storage_pools",
+	"resources_gpu_usb",
+	"resources_network",
+	"resources_numa",
+	"resources_cpu_flags",
+	"container_placement",
+	"cluster_balance",
+	"storage_shared",
+	"operations_offline_failure",
+	"clustering_node_config",
+	"cluster_upgrade",
+	"cluster_replica",
+	"container_depends_on",
+	"container_healthcheck",
+	"container_healthcheck_action",
+	"container_run_once",
+	"images_build",
+	"console_protocol",
+	"warnings",
 }
 
 // APIExtensionsCount returns the number of available API extensions.